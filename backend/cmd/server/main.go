@@ -2,55 +2,325 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
-	"mime"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"evd/internal/application/auth"
 	"evd/internal/application/media"
+	"evd/internal/application/progress"
+	"evd/internal/application/quota"
 	"evd/internal/application/torrent"
+	"evd/internal/application/upload"
 	"evd/internal/application/watchparty"
 	"evd/internal/config"
+	mediadomain "evd/internal/domain/media"
 	"evd/internal/infrastructure/ffmpeg"
 	"evd/internal/infrastructure/filesystem"
+	"evd/internal/infrastructure/metrics"
 	"evd/internal/infrastructure/transmission"
 	httptransport "evd/internal/transport/http"
 	"github.com/rs/cors"
 )
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config load failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+
+	// ctx ends on SIGINT or SIGTERM and stops background workers and
+	// long-lived requests.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	_ = mime.AddExtensionType(".m3u8", "application/vnd.apple.mpegurl")
-	_ = mime.AddExtensionType(".ts", "video/mp2t")
+	if err := mediadomain.ConfigureVideoExts(cfg.SupportedExts); err != nil {
+		log.Fatalf("invalid SUPPORTED_EXTS: %v", err)
+	}
 
-	store := filesystem.NewStore(cfg.VideosDir, cfg.HLSDir, cfg.MP4Dir)
+	store := filesystem.NewStore(cfg.VideosDir, cfg.HLSDir, cfg.MP4Dir, cfg.ThumbsDir)
+	store.NamesFile = cfg.OutputNamesFile
 	if err := store.EnsureDirs(); err != nil {
 		log.Fatalf("storage init failed: %v", err)
 	}
 
-	converter := ffmpeg.NewConverter("v4", "v4", cfg.HlsSegmentSeconds)
-	mediaService := media.NewService(store, converter, log.Default())
-	mediaService.StartMP4Prewarm(context.Background(), 45*time.Second)
+	hlsFormat, err := mediadomain.ParseHLSFormat(cfg.HLSFormat)
+	if err != nil {
+		log.Fatalf("invalid HLS_FORMAT %q: %v", cfg.HLSFormat, err)
+	}
+
+	var hlsRenditions []mediadomain.Rendition
+	if cfg.HLSAdaptive {
+		hlsRenditions, err = mediadomain.ParseRenditions(cfg.HLSRenditions)
+		if err != nil {
+			log.Fatalf("invalid HLS_RENDITIONS %q: %v", cfg.HLSRenditions, err)
+		}
+	}
+
+	if cfg.MP4Concurrency < 1 {
+		log.Fatalf("invalid MP4_CONCURRENCY %d: must be at least 1", cfg.MP4Concurrency)
+	}
+
+	encoding := ffmpeg.Encoding{
+		Preset:    cfg.VideoPreset,
+		CRF:       cfg.VideoCRF,
+		AudioKbps: cfg.AudioBitrateKbps,
+	}
+	if err := encoding.Validate(); err != nil {
+		log.Fatalf("invalid encoding settings: %v", err)
+	}
+
+	converter := ffmpeg.NewConverter("v4", "v4", cfg.HlsSegmentSeconds, cfg.VideoEncoder, encoding)
+	converter.VAAPIDevice = cfg.VAAPIDevice
+	converter.SetHLSLayout(hlsRenditions, cfg.HLSTrimEnabled)
+	log.Printf("video encoder: %s", converter.Encoder())
+	versionCtx, cancelVersion := context.WithTimeout(ctx, 10*time.Second)
+	ffmpegVersion, ffprobeVersion, err := converter.Version(versionCtx)
+	cancelVersion()
+	if err != nil {
+		if cfg.RequireFFmpeg {
+			log.Fatalf("ffmpeg check failed: %v", err)
+		}
+		log.Printf("WARNING: ffmpeg check failed, conversions and probes will fail: %v", err)
+	} else {
+		log.Printf("ffmpeg %s, ffprobe %s", ffmpegVersion, ffprobeVersion)
+	}
+	var recorder *metrics.Recorder
+	var conversionMetrics media.ConversionMetrics
+	if cfg.MetricsEnabled {
+		recorder = metrics.NewRecorder()
+		conversionMetrics = recorder
+	}
+
+	quotaService, err := quota.NewService(cfg.QuotasFile, quota.Limits{
+		StorageBytes:       int64(cfg.QuotaStorageBytes),
+		MonthlyStreamBytes: int64(cfg.QuotaMonthlyStreamBytes),
+	})
+	if err != nil {
+		log.Fatalf("quota init failed: %v", err)
+	}
+	quotaService.StartFlusher(ctx, 30*time.Second)
+
+	// Purged trash no longer needs its uploaders for a restore.
+	forgetTrashed := func(names []string) {
+		if err := quotaService.ForgetTrashed(names); err != nil {
+			log.Printf("quota accounting failed for emptied trash: %v", err)
+		}
+	}
+	mediaService := media.NewService(store, converter, log.Default(), media.Options{
+		MP4ReadyMinBytes:      int64(cfg.MP4ReadyMinBytes),
+		MP4Concurrency:        cfg.MP4Concurrency,
+		LiveStreamConcurrency: cfg.LiveStreamConcurrency,
+		PrewarmStableFor:      time.Duration(cfg.PrewarmStableSeconds) * time.Second,
+		ThumbnailPrewarm:      cfg.ThumbnailPrewarm,
+		ThumbnailConcurrency:  cfg.ThumbnailConcurrency,
+		HLSFormat:             hlsFormat,
+		HLSTrimEnabled:        cfg.HLSTrimEnabled,
+		HLSRenditions:         hlsRenditions,
+		MinFreeBytes:          int64(cfg.ConvertMinFreeBytes),
+		MaxTranscodeBytes:     int64(cfg.MaxTranscodeBytes),
+		TrashRetention:        time.Duration(cfg.TrashRetentionHours) * time.Hour,
+		TrashEmptied:          forgetTrashed,
+		Metrics:               conversionMetrics,
+		Ingest: media.IngestOptions{
+			Enabled:      cfg.IngestEnabled,
+			AllowedHosts: cfg.IngestAllowedHosts,
+			MaxBytes:     int64(cfg.IngestMaxBytes),
+			MaxDuration:  time.Duration(cfg.IngestMaxMinutes) * time.Minute,
+		},
+	})
+	mediaService.CleanInterruptedOutputs()
+	mediaService.StartPrewarm(ctx, time.Duration(cfg.PrewarmIntervalSeconds)*time.Second)
+	if cfg.WatchVideos {
+		go func() {
+			if err := store.WatchVideos(ctx, mediaService.RescanPrewarm); err != nil {
+				log.Printf("videos directory watch stopped, falling back to periodic scans: %v", err)
+			}
+		}()
+	}
+	mediaService.StartEvictionSweeper(ctx, 10*time.Minute)
+	mediaService.StartTrashSweeper(ctx, time.Hour)
+
+	uploadService := upload.NewService(cfg.VideosDir, time.Duration(cfg.UploadSessionTTLMinutes)*time.Minute)
+	uploadService.SetLimits(upload.Limits{
+		MaxChunkBytes: int64(cfg.UploadMaxChunkBytes),
+		MaxFileBytes:  int64(cfg.UploadMaxFileBytes),
+	})
+	uploadService.StartSweeper(ctx, 10*time.Minute)
 
 	transmissionClient := transmission.NewClient(cfg.TransmissionURL, cfg.TransmissionUser, cfg.TransmissionPass, cfg.TransmissionDownloadDir, store)
+	if recorder != nil {
+		transmissionClient.OnRPCError = recorder.TorrentRPCFailed
+	}
 	torrentService := torrent.NewService(transmissionClient)
+	if cfg.TorrentReadyPrewarm && torrentService.Enabled() {
+		go torrentService.WatchCompletions(ctx, time.Duration(cfg.TorrentPollSeconds)*time.Second, mediaService.PrewarmNow)
+	}
 
-	authService, err := auth.NewService(cfg.UsersFile, time.Duration(cfg.SessionTTLHours)*time.Hour)
+	authService, err := auth.NewService(cfg.UsersFile, time.Duration(cfg.SessionTTLHours)*time.Hour, cfg.AdminUsers)
 	if err != nil {
 		log.Fatalf("auth init failed: %v", err)
 	}
-	watchPartyService := watchparty.NewService()
+	authService.SetLockout(auth.LockoutPolicy{
+		Threshold: cfg.LoginLockoutThreshold,
+		Window:    time.Duration(cfg.LoginLockoutMinutes) * time.Minute,
+		Cooldown:  time.Duration(cfg.LoginLockoutMinutes) * time.Minute,
+	})
+	watchPartyService, err := watchparty.NewServiceWithStore(filesystem.NewHubStore(cfg.WatchHubsDir), log.Default(), watchparty.Options{
+		IdleTTL:               time.Duration(cfg.WatchHubIdleMinutes) * time.Minute,
+		MaxMembers:            cfg.WatchHubMaxMembers,
+		AutoTransferOwnership: cfg.WatchHubAutoTransfer,
+		VideoAccess:           watchVideoAccess(store, authService, cfg.UserLibraries),
+	})
+	if err != nil {
+		log.Fatalf("watch party init failed: %v", err)
+	}
 
-	handler := httptransport.NewHandler(mediaService, torrentService, store, authService, watchPartyService)
-	router := httptransport.NewRouter(handler, cfg.HLSDir)
+	var progressService *progress.Service
+	if cfg.ProgressEnabled {
+		progressService, err = progress.NewService(cfg.ProgressFile)
+		if err != nil {
+			log.Fatalf("progress init failed: %v", err)
+		}
+		progressService.StartFlusher(ctx, 30*time.Second)
+	}
 
-	c := cors.New(cors.Options{
-		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+	handler := httptransport.NewHandler(mediaService, torrentService, store, uploadService, authService, watchPartyService, quotaService)
+	handler.SetClientConfig(httptransport.ClientConfig{
+		HLSSegmentSeconds:  cfg.HlsSegmentSeconds,
+		HLSFormat:          hlsFormat,
+		IngestEnabled:      cfg.IngestEnabled,
+		GuestAllowed:       cfg.GuestLogin,
+		MaxUploadBytes:     int64(cfg.UploadMaxFileBytes),
+		MonthlyStreamBytes: int64(cfg.QuotaMonthlyStreamBytes),
 	})
+	if cfg.AccessLog {
+		handler.EnableAccessLog(log.Default())
+	}
+	if cfg.StatusPage {
+		handler.EnableStatusPage()
+	}
+	handler.EnableStreamThrottle(cfg.MaxStreamKbps)
+	if progressService != nil {
+		handler.EnableProgress(progressService)
+	}
+	handler.EnableAuthRateLimit(ctx, httptransport.AuthRateLimit{
+		PerMinute:         cfg.AuthRatePerMinute,
+		Burst:             cfg.AuthRateBurst,
+		TrustProxyHeaders: cfg.TrustProxyHeaders,
+	})
+	if cfg.UserLibraries {
+		handler.EnableUserLibraries()
+	}
+	handler.SetReadinessChecks(
+		httptransport.ReadinessCheck{Name: "ffmpeg", Check: converter.CheckTools, Detail: func() string {
+			if ffmpegVersion == "" {
+				return ""
+			}
+			return "ffmpeg " + ffmpegVersion + ", ffprobe " + ffprobeVersion
+		}},
+		httptransport.ReadinessCheck{Name: "storage", Check: store.CheckWritable},
+		httptransport.ReadinessCheck{Name: "users", Check: authService.CheckUsersFile},
+	)
+	if recorder != nil {
+		handler.EnableMetrics(recorder, recorder.Handler())
+	}
+	router := httptransport.NewRouter(handler, cfg.HLSDir)
+
+	corsOptions, err := newCORSOptions(cfg.CORSAllowedOrigins, cfg.CORSAllowCredentials)
+	if err != nil {
+		log.Fatalf("invalid CORS config: %v", err)
+	}
+	c := cors.New(corsOptions)
 
+	server := &http.Server{
+		Addr:        cfg.ServerAddr,
+		Handler:     c.Handler(router),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("server failed: %v", err)
+		}
+	}()
 	log.Printf("Server started on %s", cfg.ServerAddr)
-	log.Fatal(http.ListenAndServe(cfg.ServerAddr, c.Handler(router)))
+
+	<-ctx.Done()
+	stop()
+	drainTimeout := time.Duration(cfg.ShutdownTimeoutSeconds) * time.Second
+	log.Printf("Shutting down, draining for up to %s", drainTimeout)
+	drainCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(drainCtx); err != nil {
+		log.Printf("HTTP drain incomplete: %v", err)
+		_ = server.Close()
+	}
+	finished, stopped := mediaService.Shutdown(drainCtx)
+	watchPartyService.Close()
+	if err := quotaService.Flush(); err != nil {
+		log.Printf("quota flush failed: %v", err)
+	}
+	if progressService != nil {
+		if err := progressService.Flush(); err != nil {
+			log.Printf("progress flush failed: %v", err)
+		}
+	}
+	log.Printf("Shutdown complete: %d conversions finished, %d stopped", finished, stopped)
+}
+
+// watchVideoAccess resolves the library videos a watch hub may be created on or
+// switched to. With user libraries, non-admins are limited to their own folder.
+// The folder is checked before the file, so other users' videos cannot be
+// probed for existence.
+func watchVideoAccess(store *filesystem.Store, users *auth.Service, userLibraries bool) func(userID, videoPath string) (string, error) {
+	return func(userID, videoPath string) (string, error) {
+		rel, full, err := store.ResolveVideoPath(videoPath)
+		if err != nil {
+			return "", err
+		}
+		if userLibraries && !users.IsAdmin(auth.User{ID: userID}) && !strings.HasPrefix(rel, userID+"/") {
+			return "", errors.New("video is outside the user's library")
+		}
+		if _, err := os.Stat(full); err != nil {
+			return "", err
+		}
+		return rel, nil
+	}
+}
+
+// corsAllowedHeaders are the request headers clients send beyond the CORS
+// safelisted ones: bearer sessions, idempotent retries and private hub keys.
+var corsAllowedHeaders = []string{"Authorization", "Content-Type", "Idempotency-Key", "X-Hub-Key", "X-Requested-With"}
+
+// newCORSOptions allows every origin when none are configured. Credentialed
+// requests need explicit origins: the matched one is echoed back, and browsers
+// refuse credentials with a wildcard anyway.
+func newCORSOptions(origins []string, allowCredentials bool) (cors.Options, error) {
+	if len(origins) == 0 {
+		origins = []string{"*"}
+	}
+	if allowCredentials {
+		for _, origin := range origins {
+			if strings.Contains(origin, "*") {
+				return cors.Options{}, fmt.Errorf("CORS_ALLOW_CREDENTIALS requires explicit CORS_ALLOWED_ORIGINS, got %q", origin)
+			}
+		}
+	}
+	return cors.Options{
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   corsAllowedHeaders,
+		AllowCredentials: allowCredentials,
+	}, nil
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/cors"
+)
+
+func TestNewCORSOptions_PreflightAllowsClientHeaders(t *testing.T) {
+	opts, err := newCORSOptions([]string{"https://app.example"}, true)
+	if err != nil {
+		t.Fatalf("options: %v", err)
+	}
+	handler := cors.New(opts).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, header := range []string{"Authorization", "Idempotency-Key", "X-Hub-Key", "Content-Type"} {
+		req := httptest.NewRequest(http.MethodOptions, "/api/videos", nil)
+		req.Header.Set("Origin", "https://app.example")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", header)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example" {
+			t.Fatalf("expected preflight with %s to be allowed, got origin %q", header, got)
+		}
+		if rec.Header().Get("Access-Control-Allow-Headers") == "" {
+			t.Fatalf("expected %s to be listed as an allowed header", header)
+		}
+	}
+}
+
+func TestNewCORSOptions_RefusesCredentialsWithWildcard(t *testing.T) {
+	if _, err := newCORSOptions(nil, true); err == nil {
+		t.Fatalf("expected credentials with the default wildcard to be refused")
+	}
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrAccountLocked is returned by Login while an account is locked after too
+// many failed attempts, even for the correct password.
+var ErrAccountLocked = errors.New("account temporarily locked after too many failed logins")
+
+// LockoutPolicy locks an account for Cooldown once Threshold logins failed within
+// Window. A zero Threshold disables lockout.
+type LockoutPolicy struct {
+	Threshold int
+	Window    time.Duration
+	Cooldown  time.Duration
+}
+
+// loginFailures tracks recent failed logins of one username key.
+type loginFailures struct {
+	at          []time.Time
+	lockedUntil time.Time
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// SetLockout changes the failed-login lockout policy. Failures already counted
+// are kept.
+func (s *Service) SetLockout(policy LockoutPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lockout = policy
+}
+
+// lockedLocked reports whether usernameKey is locked at now.
+func (s *Service) lockedLocked(usernameKey string, now time.Time) bool {
+	entry, ok := s.failures[usernameKey]
+	return ok && now.Before(entry.lockedUntil)
+}
+
+// recordFailureLocked counts a failed login for usernameKey and locks it once the
+// policy threshold is reached within the window. Unknown usernames are counted
+// alike so lockouts do not reveal which accounts exist.
+func (s *Service) recordFailureLocked(usernameKey string, now time.Time) {
+	if s.lockout.Threshold <= 0 {
+		return
+	}
+
+	entry := s.failures[usernameKey]
+	recent := entry.at[:0]
+	for _, at := range entry.at {
+		if now.Sub(at) < s.lockout.Window {
+			recent = append(recent, at)
+		}
+	}
+	entry.at = append(recent, now)
+	if len(entry.at) >= s.lockout.Threshold {
+		entry.at = nil
+		entry.lockedUntil = now.Add(s.lockout.Cooldown)
+	}
+	s.failures[usernameKey] = entry
+}
+
+// pruneFailuresLocked drops entries that are neither locked nor inside the window.
+func (s *Service) pruneFailuresLocked(now time.Time) {
+	for key, entry := range s.failures {
+		if now.Before(entry.lockedUntil) {
+			continue
+		}
+		if len(entry.at) == 0 || now.Sub(entry.at[len(entry.at)-1]) >= s.lockout.Window {
+			delete(s.failures, key)
+		}
+	}
+}
+
+// verifyDummyPassword spends the same time as checking a real password, so
+// unknown usernames cannot be told apart by response time.
+func verifyDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = hashPassword("evd-dummy-password")
+	})
+	_, _ = verifyPassword(password, dummyHash)
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+)
+
+// Roles a registered account can hold. Accounts listed in the configured admin
+// usernames are admins regardless of their stored role; guests never are.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrLastAdmin    = errors.New("cannot demote the last admin")
+)
+
+// SetRole promotes or demotes the registered user userID and persists the
+// change. Demoting the only remaining admin is refused with ErrLastAdmin.
+func (s *Service) SetRole(userID, role string) (User, error) {
+	userID = strings.TrimSpace(userID)
+	if role != RoleUser && role != RoleAdmin {
+		return User{}, ErrInvalidInput
+	}
+	if strings.HasPrefix(userID, guestIDPrefix) {
+		return User{}, ErrGuestAccount
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.usersByID[userID]
+	if !exists {
+		return User{}, ErrUserNotFound
+	}
+	if role == RoleUser && s.isAdminLocked(user) && s.adminCountLocked() == 1 {
+		if _, configured := s.admins[user.UsernameKey]; !configured {
+			return User{}, ErrLastAdmin
+		}
+	}
+
+	previous := user.Role
+	user.Role = role
+	s.usersByID[userID] = user
+	s.usersByKey[user.UsernameKey] = user
+	if err := s.saveUsersLocked(); err != nil {
+		user.Role = previous
+		s.usersByID[userID] = user
+		s.usersByKey[user.UsernameKey] = user
+		return User{}, err
+	}
+
+	return s.publicUserLocked(user), nil
+}
+
+// isAdminLocked reports whether a registered account has admin rights, either by
+// stored role or by configured username.
+func (s *Service) isAdminLocked(user storedUser) bool {
+	if user.Role == RoleAdmin {
+		return true
+	}
+	_, admin := s.admins[user.UsernameKey]
+	return admin
+}
+
+func (s *Service) adminCountLocked() int {
+	count := 0
+	for _, user := range s.usersByID {
+		if s.isAdminLocked(user) {
+			count++
+		}
+	}
+	return count
+}
+
+// publicUserLocked returns the client view of user with its effective role.
+func (s *Service) publicUserLocked(user storedUser) User {
+	public := user.toPublic()
+	public.Role = RoleUser
+	if s.isAdminLocked(user) {
+		public.Role = RoleAdmin
+	}
+	return public
+}
@@ -16,13 +16,29 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"golang.org/x/crypto/argon2"
 )
 
 const (
 	passwordSaltBytes = 16
-	passwordRounds    = 100000
-	userIDBytes       = 12
-	sessionIDBytes    = 32
+
+	argonAlgorithm = "argon2id"
+	argonTime      = 3
+	argonMemoryKiB = 64 * 1024
+	argonThreads   = 4
+	argonKeyBytes  = 32
+
+	// legacyPasswordRounds is the iteration count of the old SHA-256 scheme,
+	// kept only to verify and migrate existing records.
+	legacyPasswordRounds = 100000
+	userIDBytes          = 12
+	sessionIDBytes       = 32
+
+	guestIDPrefix = "guest_"
+
+	minSessionTTL = time.Minute
+	maxSessionTTL = 90 * 24 * time.Hour
 )
 
 var (
@@ -30,6 +46,7 @@ var (
 	ErrInvalidCredentials = errors.New("invalid username or password")
 	ErrUserExists         = errors.New("username already exists")
 	ErrInvalidInput       = errors.New("invalid username or password format")
+	ErrGuestAccount       = errors.New("guest accounts have no password")
 
 	usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{3,32}$`)
 )
@@ -39,6 +56,7 @@ type User struct {
 	ID        string `json:"id"`
 	Username  string `json:"username"`
 	CreatedAt int64  `json:"createdAt"`
+	Role      string `json:"role"`
 }
 
 type storedUser struct {
@@ -47,13 +65,27 @@ type storedUser struct {
 	UsernameKey  string `json:"usernameKey"`
 	PasswordHash string `json:"passwordHash"`
 	CreatedAt    int64  `json:"createdAt"`
+	// Role is empty for accounts created before roles existed, meaning RoleUser.
+	Role string `json:"role,omitempty"`
 }
 
 type session struct {
 	User      User
+	CreatedAt time.Time
 	ExpiresAt time.Time
 }
 
+// SessionInfo describes an active session without exposing its token.
+type SessionInfo struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Current   bool      `json:"current"`
+}
+
+// sessionIDLength is the number of hex characters kept from a token digest.
+const sessionIDLength = 12
+
 // Service manages user accounts and active sessions.
 type Service struct {
 	mu sync.RWMutex
@@ -62,12 +94,23 @@ type Service struct {
 	usersByID  map[string]storedUser
 	sessions   map[string]session
 
-	usersFile  string
-	sessionTTL time.Duration
+	usersFile    string
+	settingsFile string
+	sessionTTL   time.Duration
+	admins       map[string]struct{}
+
+	lockout  LockoutPolicy
+	failures map[string]loginFailures
+}
+
+// settings holds runtime-adjustable auth parameters persisted next to the users file.
+type settings struct {
+	SessionTTLSeconds int64 `json:"sessionTtlSeconds"`
 }
 
 // NewService creates an auth service and loads persisted users from disk.
-func NewService(usersFile string, sessionTTL time.Duration) (*Service, error) {
+// adminUsernames lists accounts allowed to use administrative endpoints.
+func NewService(usersFile string, sessionTTL time.Duration, adminUsernames []string) (*Service, error) {
 	if sessionTTL <= 0 {
 		sessionTTL = 72 * time.Hour
 	}
@@ -78,21 +121,93 @@ func NewService(usersFile string, sessionTTL time.Duration) (*Service, error) {
 		sessions:   map[string]session{},
 		usersFile:  strings.TrimSpace(usersFile),
 		sessionTTL: sessionTTL,
+		admins:     map[string]struct{}{},
+		failures:   map[string]loginFailures{},
+	}
+	if svc.usersFile != "" {
+		svc.settingsFile = filepath.Join(filepath.Dir(svc.usersFile), "auth_settings.json")
+	}
+	for _, name := range adminUsernames {
+		if key := strings.ToLower(strings.TrimSpace(name)); key != "" {
+			svc.admins[key] = struct{}{}
+		}
 	}
 
 	if err := svc.loadUsers(); err != nil {
 		return nil, err
 	}
+	if err := svc.loadSettings(); err != nil {
+		return nil, err
+	}
 
 	return svc, nil
 }
 
 // SessionTTL returns the configured session lifetime.
 func (s *Service) SessionTTL() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.sessionTTL
 }
 
+// SetSessionTTL changes the lifetime of new sessions at runtime and shortens
+// existing sessions that would otherwise outlive the new limit.
+func (s *Service) SetSessionTTL(ttl time.Duration) error {
+	if ttl < minSessionTTL || ttl > maxSessionTTL {
+		return ErrInvalidInput
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.sessionTTL
+	s.sessionTTL = ttl
+	if err := s.saveSettingsLocked(); err != nil {
+		s.sessionTTL = previous
+		return err
+	}
+
+	limit := time.Now().Add(ttl)
+	for token, entry := range s.sessions {
+		if entry.ExpiresAt.After(limit) {
+			entry.ExpiresAt = limit
+			s.sessions[token] = entry
+		}
+	}
+	return nil
+}
+
+// PurgeSessions removes every active session except exceptToken (when non-empty)
+// and returns how many sessions were dropped.
+func (s *Service) PurgeSessions(exceptToken string) int {
+	exceptToken = strings.TrimSpace(exceptToken)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for token := range s.sessions {
+		if exceptToken != "" && token == exceptToken {
+			continue
+		}
+		delete(s.sessions, token)
+		removed++
+	}
+	return removed
+}
+
+// IsAdmin reports whether user may use administrative endpoints: registered
+// accounts with the admin role or a configured admin username. Guests never are.
+func (s *Service) IsAdmin(user User) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, ok := s.usersByID[user.ID]
+	return ok && s.isAdminLocked(stored)
+}
+
 // Register creates a new user account and immediately returns a fresh session.
+// The first account registered gets the admin role.
 func (s *Service) Register(username, password string) (User, string, error) {
 	normalizedUsername, usernameKey, err := validateCredentials(username, password)
 	if err != nil {
@@ -125,6 +240,11 @@ func (s *Service) Register(username, password string) (User, string, error) {
 		UsernameKey:  usernameKey,
 		PasswordHash: passwordHash,
 		CreatedAt:    now,
+		Role:         RoleUser,
+	}
+	// The first account of a fresh instance administers it.
+	if len(s.usersByID) == 0 {
+		user.Role = RoleAdmin
 	}
 
 	s.usersByKey[usernameKey] = user
@@ -136,7 +256,7 @@ func (s *Service) Register(username, password string) (User, string, error) {
 		return User{}, "", err
 	}
 
-	publicUser := user.toPublic()
+	publicUser := s.publicUserLocked(user)
 	token, err := s.createSessionLocked(publicUser)
 	if err != nil {
 		return User{}, "", err
@@ -145,7 +265,8 @@ func (s *Service) Register(username, password string) (User, string, error) {
 	return publicUser, token, nil
 }
 
-// Login authenticates user credentials and returns a fresh session token.
+// Login authenticates user credentials and returns a fresh session token. Failed
+// attempts count towards the lockout policy; a successful login resets them.
 func (s *Service) Login(username, password string) (User, string, error) {
 	normalized := strings.TrimSpace(username)
 	password = strings.TrimSpace(password)
@@ -157,17 +278,30 @@ func (s *Service) Login(username, password string) (User, string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.cleanupExpiredSessionsLocked(time.Now())
+	now := time.Now()
+	s.cleanupExpiredSessionsLocked(now)
+	s.pruneFailuresLocked(now)
 
+	if s.lockedLocked(usernameKey, now) {
+		return User{}, "", ErrAccountLocked
+	}
 	user, exists := s.usersByKey[usernameKey]
 	if !exists {
+		verifyDummyPassword(password)
+		s.recordFailureLocked(usernameKey, now)
 		return User{}, "", ErrInvalidCredentials
 	}
-	if !verifyPassword(password, user.PasswordHash) {
+	ok, needsRehash := verifyPassword(password, user.PasswordHash)
+	if !ok {
+		s.recordFailureLocked(usernameKey, now)
 		return User{}, "", ErrInvalidCredentials
 	}
+	delete(s.failures, usernameKey)
+	if needsRehash {
+		s.rehashPasswordLocked(user, password)
+	}
 
-	publicUser := user.toPublic()
+	publicUser := s.publicUserLocked(user)
 	token, err := s.createSessionLocked(publicUser)
 	if err != nil {
 		return User{}, "", err
@@ -176,6 +310,104 @@ func (s *Service) Login(username, password string) (User, string, error) {
 	return publicUser, token, nil
 }
 
+// ChangePassword replaces the password of a registered user after verifying the current one.
+func (s *Service) ChangePassword(userID, oldPassword, newPassword string) error {
+	userID = strings.TrimSpace(userID)
+	if strings.HasPrefix(userID, guestIDPrefix) {
+		return ErrGuestAccount
+	}
+	oldPassword = strings.TrimSpace(oldPassword)
+	newPassword = strings.TrimSpace(newPassword)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.usersByID[userID]
+	if !exists {
+		return ErrUnauthorized
+	}
+	if _, _, err := validateCredentials(user.Username, newPassword); err != nil {
+		return err
+	}
+	if ok, _ := verifyPassword(oldPassword, user.PasswordHash); !ok {
+		return ErrInvalidCredentials
+	}
+
+	passwordHash, err := hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	previous := user
+	user.PasswordHash = passwordHash
+	s.usersByKey[user.UsernameKey] = user
+	s.usersByID[user.ID] = user
+	if err := s.saveUsersLocked(); err != nil {
+		s.usersByKey[previous.UsernameKey] = previous
+		s.usersByID[previous.ID] = previous
+		return err
+	}
+	return nil
+}
+
+// RevokeUserSessions ends every session of userID except exceptToken and
+// returns how many were removed.
+func (s *Service) RevokeUserSessions(userID, exceptToken string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cleanupExpiredSessionsLocked(time.Now())
+
+	removed := 0
+	for token, current := range s.sessions {
+		if current.User.ID != userID || (exceptToken != "" && token == exceptToken) {
+			continue
+		}
+		delete(s.sessions, token)
+		removed++
+	}
+	return removed
+}
+
+// ListSessions returns the active sessions of userID, newest first.
+func (s *Service) ListSessions(userID string) []SessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cleanupExpiredSessionsLocked(time.Now())
+
+	out := make([]SessionInfo, 0)
+	for token, current := range s.sessions {
+		if current.User.ID != userID {
+			continue
+		}
+		out = append(out, SessionInfo{
+			ID:        SessionID(token),
+			CreatedAt: current.CreatedAt,
+			ExpiresAt: current.ExpiresAt,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.After(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// RevokeAllSessions signs userID out everywhere and returns the number of sessions removed.
+func (s *Service) RevokeAllSessions(userID string) int {
+	return s.RevokeUserSessions(userID, "")
+}
+
+// SessionID derives the public identifier of a session token: a truncated
+// digest, so listings never reveal usable token material.
+func SessionID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])[:sessionIDLength]
+}
+
 // LoginGuest creates an anonymous guest session without user registration.
 func (s *Service) LoginGuest() (User, string, error) {
 	s.mu.Lock()
@@ -189,9 +421,10 @@ func (s *Service) LoginGuest() (User, string, error) {
 	}
 
 	guestUser := User{
-		ID:        "guest_" + guestID,
+		ID:        guestIDPrefix + guestID,
 		Username:  "guest",
 		CreatedAt: time.Now().UnixMilli(),
+		Role:      RoleUser,
 	}
 
 	token, err := s.createSessionLocked(guestUser)
@@ -226,6 +459,10 @@ func (s *Service) Authenticate(token string) (User, error) {
 		return User{}, ErrUnauthorized
 	}
 
+	// Roles may have changed since the session started.
+	if stored, ok := s.usersByID[record.User.ID]; ok {
+		return s.publicUserLocked(stored), nil
+	}
 	return record.User, nil
 }
 
@@ -241,15 +478,36 @@ func (s *Service) Logout(token string) {
 	delete(s.sessions, token)
 }
 
+// rehashPasswordLocked upgrades a stored hash to the current scheme after a successful
+// login. Failures are ignored: the old hash keeps working and migration is retried later.
+func (s *Service) rehashPasswordLocked(user storedUser, password string) {
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return
+	}
+
+	previous := user.PasswordHash
+	user.PasswordHash = passwordHash
+	s.usersByKey[user.UsernameKey] = user
+	s.usersByID[user.ID] = user
+	if err := s.saveUsersLocked(); err != nil {
+		user.PasswordHash = previous
+		s.usersByKey[user.UsernameKey] = user
+		s.usersByID[user.ID] = user
+	}
+}
+
 func (s *Service) createSessionLocked(user User) (string, error) {
 	token, err := randomToken(sessionIDBytes)
 	if err != nil {
 		return "", err
 	}
 
+	now := time.Now()
 	s.sessions[token] = session{
 		User:      user,
-		ExpiresAt: time.Now().Add(s.sessionTTL),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.sessionTTL),
 	}
 
 	return token, nil
@@ -263,27 +521,19 @@ func (s *Service) cleanupExpiredSessionsLocked(now time.Time) {
 	}
 }
 
-func (s *Service) loadUsers() error {
-	if s.usersFile == "" {
-		return nil
-	}
+// CheckUsersFile verifies that the users file can still be read and decoded.
+// A missing file is fine; it is created on the first registration.
+func (s *Service) CheckUsersFile() error {
+	_, err := s.readUsersFile()
+	return err
+}
 
-	raw, err := os.ReadFile(s.usersFile)
+func (s *Service) loadUsers() error {
+	list, err := s.readUsersFile()
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return nil
-		}
 		return err
 	}
 
-	var list []storedUser
-	if len(raw) == 0 {
-		return nil
-	}
-	if err := json.Unmarshal(raw, &list); err != nil {
-		return fmt.Errorf("decode users file: %w", err)
-	}
-
 	for _, item := range list {
 		if item.ID == "" || item.PasswordHash == "" {
 			continue
@@ -301,6 +551,29 @@ func (s *Service) loadUsers() error {
 	return nil
 }
 
+func (s *Service) readUsersFile() ([]storedUser, error) {
+	if s.usersFile == "" {
+		return nil, nil
+	}
+
+	raw, err := os.ReadFile(s.usersFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var list []storedUser
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("decode users file: %w", err)
+	}
+	return list, nil
+}
+
 func (s *Service) saveUsersLocked() error {
 	if s.usersFile == "" {
 		return nil
@@ -331,6 +604,54 @@ func (s *Service) saveUsersLocked() error {
 	return os.Rename(tmpPath, s.usersFile)
 }
 
+func (s *Service) loadSettings() error {
+	if s.settingsFile == "" {
+		return nil
+	}
+
+	raw, err := os.ReadFile(s.settingsFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var stored settings
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return fmt.Errorf("decode auth settings: %w", err)
+	}
+	if ttl := time.Duration(stored.SessionTTLSeconds) * time.Second; ttl >= minSessionTTL && ttl <= maxSessionTTL {
+		s.sessionTTL = ttl
+	}
+	return nil
+}
+
+func (s *Service) saveSettingsLocked() error {
+	if s.settingsFile == "" {
+		return nil
+	}
+
+	raw, err := json.MarshalIndent(settings{SessionTTLSeconds: int64(s.sessionTTL / time.Second)}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.settingsFile), 0o755); err != nil {
+		return err
+	}
+
+	tmpPath := s.settingsFile + ".tmp"
+	if err := os.WriteFile(tmpPath, raw, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, s.settingsFile)
+}
+
 func validateCredentials(username, password string) (string, string, error) {
 	cleanUsername := strings.TrimSpace(username)
 	cleanPassword := strings.TrimSpace(password)
@@ -354,23 +675,70 @@ func randomToken(size int) (string, error) {
 	return base64.RawURLEncoding.EncodeToString(buf), nil
 }
 
+// hashPassword encodes password with argon2id, keeping algorithm and parameters inline:
+// `argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>` (unpadded base64).
 func hashPassword(password string) (string, error) {
 	salt := make([]byte, passwordSaltBytes)
 	if _, err := rand.Read(salt); err != nil {
 		return "", err
 	}
 
-	sum := sha256.Sum256(append(salt, []byte(password)...))
-	current := sum[:]
-	for i := 0; i < passwordRounds; i++ {
-		next := sha256.Sum256(append(current, salt...))
-		current = next[:]
+	key := argon2.IDKey([]byte(password), salt, argonTime, argonMemoryKiB, argonThreads, argonKeyBytes)
+	return fmt.Sprintf("%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argonAlgorithm, argon2.Version, argonMemoryKiB, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// verifyPassword checks password against an encoded hash. needsRehash is set when
+// the hash uses the legacy iterated SHA-256 `salt:hash` format or outdated parameters.
+func verifyPassword(password, encoded string) (ok bool, needsRehash bool) {
+	if strings.HasPrefix(encoded, argonAlgorithm+"$") {
+		return verifyArgon2id(password, encoded)
+	}
+	return verifyLegacyPassword(password, encoded), true
+}
+
+func verifyArgon2id(password, encoded string) (bool, bool) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 {
+		return false, false
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[1], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[2], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false, false
+	}
+	if memory == 0 || iterations == 0 || threads == 0 {
+		return false, false
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(salt) == 0 {
+		return false, false
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(expected) == 0 {
+		return false, false
+	}
+
+	actual := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(expected)))
+	if subtle.ConstantTimeCompare(actual, expected) != 1 {
+		return false, false
 	}
 
-	return hex.EncodeToString(salt) + ":" + hex.EncodeToString(current), nil
+	outdated := memory != argonMemoryKiB || iterations != argonTime || threads != argonThreads || len(expected) != argonKeyBytes
+	return true, outdated
 }
 
-func verifyPassword(password, encoded string) bool {
+// verifyLegacyPassword checks the pre-argon2 `hex(salt):hex(hash)` format.
+func verifyLegacyPassword(password, encoded string) bool {
 	parts := strings.Split(encoded, ":")
 	if len(parts) != 2 {
 		return false
@@ -387,7 +755,7 @@ func verifyPassword(password, encoded string) bool {
 
 	sum := sha256.Sum256(append(salt, []byte(password)...))
 	current := sum[:]
-	for i := 0; i < passwordRounds; i++ {
+	for i := 0; i < legacyPasswordRounds; i++ {
 		next := sha256.Sum256(append(current, salt...))
 		current = next[:]
 	}
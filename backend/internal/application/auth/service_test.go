@@ -0,0 +1,337 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestService(t *testing.T, admins ...string) *Service {
+	t.Helper()
+	svc, err := NewService(filepath.Join(t.TempDir(), "users.json"), time.Hour, admins)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	return svc
+}
+
+func TestPurgeSessions_KeepsOnlyCaller(t *testing.T) {
+	svc := newTestService(t)
+	_, keep, err := svc.Register("alice", "secret1")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	_, other, _ := svc.LoginGuest()
+	_, third, _ := svc.Login("alice", "secret1")
+
+	if removed := svc.PurgeSessions(keep); removed != 2 {
+		t.Fatalf("expected 2 sessions removed, got %d", removed)
+	}
+	if _, err := svc.Authenticate(keep); err != nil {
+		t.Fatalf("expected caller session to survive, got %v", err)
+	}
+	for _, token := range []string{other, third} {
+		if _, err := svc.Authenticate(token); !errors.Is(err, ErrUnauthorized) {
+			t.Fatalf("expected purged session to be rejected, got %v", err)
+		}
+	}
+
+	if removed := svc.PurgeSessions(""); removed != 1 {
+		t.Fatalf("expected remaining session removed, got %d", removed)
+	}
+}
+
+func TestSetSessionTTL_ShortensAndPersists(t *testing.T) {
+	usersFile := filepath.Join(t.TempDir(), "users.json")
+	svc, err := NewService(usersFile, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	_, token, _ := svc.LoginGuest()
+
+	if err := svc.SetSessionTTL(time.Second); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected too-short TTL to be rejected, got %v", err)
+	}
+	if err := svc.SetSessionTTL(10 * time.Minute); err != nil {
+		t.Fatalf("set ttl: %v", err)
+	}
+	if svc.SessionTTL() != 10*time.Minute {
+		t.Fatalf("expected ttl 10m, got %s", svc.SessionTTL())
+	}
+	if expires := svc.sessions[token].ExpiresAt; time.Until(expires) > 10*time.Minute {
+		t.Fatalf("expected existing session clamped to new ttl, expires in %s", time.Until(expires))
+	}
+
+	reloaded, err := NewService(usersFile, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if reloaded.SessionTTL() != 10*time.Minute {
+		t.Fatalf("expected persisted ttl 10m, got %s", reloaded.SessionTTL())
+	}
+}
+
+func TestIsAdmin_UsesConfiguredUsernames(t *testing.T) {
+	svc := newTestService(t, "Root")
+	admin, _, _ := svc.Register("root", "secret1")
+	user, _, _ := svc.Register("bob", "secret1")
+	guest, _, _ := svc.LoginGuest()
+
+	if !svc.IsAdmin(admin) {
+		t.Fatalf("expected configured user to be admin")
+	}
+	if svc.IsAdmin(user) || svc.IsAdmin(guest) {
+		t.Fatalf("expected regular users and guests not to be admin")
+	}
+}
+
+func TestLogin_MigratesLegacyPasswordHash(t *testing.T) {
+	usersFile := filepath.Join(t.TempDir(), "users.json")
+	// "secret1" hashed with the legacy iterated SHA-256 scheme.
+	salt := []byte("0123456789abcdef")
+	sum := sha256.Sum256(append(salt, []byte("secret1")...))
+	current := sum[:]
+	for i := 0; i < legacyPasswordRounds; i++ {
+		next := sha256.Sum256(append(current, salt...))
+		current = next[:]
+	}
+	legacy := []storedUser{{
+		ID:           "legacy-id",
+		Username:     "alice",
+		UsernameKey:  "alice",
+		PasswordHash: hex.EncodeToString(salt) + ":" + hex.EncodeToString(current),
+		CreatedAt:    1,
+	}}
+	raw, _ := json.Marshal(legacy)
+	if err := os.WriteFile(usersFile, raw, 0o600); err != nil {
+		t.Fatalf("write users: %v", err)
+	}
+
+	svc, err := NewService(usersFile, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	if _, _, err := svc.Login("alice", "wrong-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected wrong password to fail, got %v", err)
+	}
+	if _, _, err := svc.Login("alice", "secret1"); err != nil {
+		t.Fatalf("expected legacy login to succeed, got %v", err)
+	}
+
+	reloaded, err := NewService(usersFile, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	migrated := reloaded.usersByKey["alice"].PasswordHash
+	if !strings.HasPrefix(migrated, "argon2id$v=19$m=65536,t=3,p=4$") {
+		t.Fatalf("expected hash migrated to argon2id, got %q", migrated)
+	}
+	if _, _, err := reloaded.Login("alice", "secret1"); err != nil {
+		t.Fatalf("expected login with migrated hash, got %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	svc := newTestService(t)
+	user, _, err := svc.Register("alice", "secret1")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	guest, _, _ := svc.LoginGuest()
+
+	if err := svc.ChangePassword(guest.ID, "x", "secret2"); !errors.Is(err, ErrGuestAccount) {
+		t.Fatalf("expected guest to be rejected, got %v", err)
+	}
+	if err := svc.ChangePassword(user.ID, "wrong1", "secret2"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected wrong current password to be rejected, got %v", err)
+	}
+	if err := svc.ChangePassword(user.ID, "secret1", "123"); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected short new password to be rejected, got %v", err)
+	}
+	if err := svc.ChangePassword(user.ID, "secret1", "secret2"); err != nil {
+		t.Fatalf("change password: %v", err)
+	}
+
+	if _, _, err := svc.Login("alice", "secret1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected old password to stop working, got %v", err)
+	}
+	if _, _, err := svc.Login("alice", "secret2"); err != nil {
+		t.Fatalf("expected new password to work, got %v", err)
+	}
+}
+
+func TestRevokeUserSessions_KeepsCurrent(t *testing.T) {
+	svc := newTestService(t)
+	user, current, _ := svc.Register("alice", "secret1")
+	_, other, _ := svc.Login("alice", "secret1")
+	_, bob, _ := svc.Register("bob", "secret1")
+
+	if removed := svc.RevokeUserSessions(user.ID, current); removed != 1 {
+		t.Fatalf("expected one session revoked, got %d", removed)
+	}
+	if _, err := svc.Authenticate(other); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected other session revoked, got %v", err)
+	}
+	for _, token := range []string{current, bob} {
+		if _, err := svc.Authenticate(token); err != nil {
+			t.Fatalf("expected session to survive, got %v", err)
+		}
+	}
+}
+
+func TestListSessions_HidesTokensAndExpired(t *testing.T) {
+	svc := newTestService(t)
+	user, first, _ := svc.Register("alice", "secret1")
+	_, second, _ := svc.Login("alice", "secret1")
+	_, expired, _ := svc.Login("alice", "secret1")
+	svc.Register("bob", "secret1")
+
+	entry := svc.sessions[expired]
+	entry.ExpiresAt = time.Now().Add(-time.Second)
+	svc.sessions[expired] = entry
+
+	sessions := svc.ListSessions(user.ID)
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 active sessions, got %+v", sessions)
+	}
+	ids := map[string]bool{SessionID(first): false, SessionID(second): false}
+	for _, info := range sessions {
+		if _, ok := ids[info.ID]; !ok || len(info.ID) != sessionIDLength {
+			t.Fatalf("unexpected session id %q", info.ID)
+		}
+		if strings.Contains(first, info.ID) || strings.Contains(second, info.ID) {
+			t.Fatalf("expected session id not to reveal token material")
+		}
+		if !info.ExpiresAt.After(info.CreatedAt) {
+			t.Fatalf("expected expiry after creation, got %+v", info)
+		}
+	}
+	if _, ok := svc.sessions[expired]; ok {
+		t.Fatalf("expected expired session to be cleaned up")
+	}
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	svc := newTestService(t)
+	user, current, _ := svc.Register("alice", "secret1")
+	_, other, _ := svc.Login("alice", "secret1")
+	_, bob, _ := svc.Register("bob", "secret1")
+
+	if removed := svc.RevokeUserSessions(user.ID, current); removed != 1 {
+		t.Fatalf("expected other session revoked while keeping the caller's, got %d", removed)
+	}
+	if _, err := svc.Authenticate(current); err != nil {
+		t.Fatalf("expected caller session to survive, got %v", err)
+	}
+
+	_, another, _ := svc.Login("alice", "secret1")
+	if removed := svc.RevokeAllSessions(user.ID); removed != 2 {
+		t.Fatalf("expected every remaining session revoked, got %d", removed)
+	}
+	for _, token := range []string{current, other, another} {
+		if _, err := svc.Authenticate(token); !errors.Is(err, ErrUnauthorized) {
+			t.Fatalf("expected revoked session to be rejected, got %v", err)
+		}
+	}
+	if _, err := svc.Authenticate(bob); err != nil {
+		t.Fatalf("expected other users' sessions to survive, got %v", err)
+	}
+	if sessions := svc.ListSessions(user.ID); len(sessions) != 0 {
+		t.Fatalf("expected no sessions listed, got %+v", sessions)
+	}
+}
+
+func TestLogin_LocksAccountAfterRepeatedFailures(t *testing.T) {
+	svc := newTestService(t)
+	svc.SetLockout(LockoutPolicy{Threshold: 3, Window: time.Minute, Cooldown: time.Minute})
+	if _, _, err := svc.Register("alice", "correct-horse"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	// A success in between resets the count.
+	for _, password := range []string{"wrong", "wrong", "correct-horse", "wrong", "wrong"} {
+		if _, _, err := svc.Login("alice", password); err != nil && !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("login %q: %v", password, err)
+		}
+	}
+	if _, _, err := svc.Login("Alice", "correct-horse"); err != nil {
+		t.Fatalf("expected login before the threshold, got %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, _, _ = svc.Login("alice", "wrong")
+	}
+	if _, _, err := svc.Login("alice", "correct-horse"); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("expected locked account to refuse the right password, got %v", err)
+	}
+
+	// Unknown usernames lock the same way, so lockouts do not reveal accounts.
+	for i := 0; i < 3; i++ {
+		if _, _, err := svc.Login("nobody", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("expected invalid credentials, got %v", err)
+		}
+	}
+	if _, _, err := svc.Login("nobody", "wrong"); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("expected unknown username to lock too, got %v", err)
+	}
+
+	svc.mu.Lock()
+	entry := svc.failures["alice"]
+	entry.lockedUntil = time.Now().Add(-time.Second)
+	svc.failures["alice"] = entry
+	svc.mu.Unlock()
+	if _, _, err := svc.Login("alice", "correct-horse"); err != nil {
+		t.Fatalf("expected login after the cooldown, got %v", err)
+	}
+}
+
+func TestRoles_FirstUserAdminAndPersistedChanges(t *testing.T) {
+	usersFile := filepath.Join(t.TempDir(), "users.json")
+	svc, err := NewService(usersFile, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+
+	owner, _, err := svc.Register("owner", "password-1")
+	if err != nil {
+		t.Fatalf("register owner: %v", err)
+	}
+	member, _, err := svc.Register("member", "password-2")
+	if err != nil {
+		t.Fatalf("register member: %v", err)
+	}
+	guest, _, _ := svc.LoginGuest()
+	if owner.Role != RoleAdmin || member.Role != RoleUser || !svc.IsAdmin(owner) || svc.IsAdmin(member) || svc.IsAdmin(guest) {
+		t.Fatalf("expected only the first account to be admin, got %+v %+v", owner, member)
+	}
+
+	if _, err := svc.SetRole(owner.ID, RoleUser); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("expected demoting the last admin to fail, got %v", err)
+	}
+	if _, err := svc.SetRole(guest.ID, RoleAdmin); !errors.Is(err, ErrGuestAccount) {
+		t.Fatalf("expected guests to stay non-admin, got %v", err)
+	}
+	if _, err := svc.SetRole(member.ID, "root"); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected unknown role to be rejected, got %v", err)
+	}
+	if promoted, err := svc.SetRole(member.ID, RoleAdmin); err != nil || promoted.Role != RoleAdmin {
+		t.Fatalf("promote: %+v %v", promoted, err)
+	}
+	if _, err := svc.SetRole(owner.ID, RoleUser); err != nil {
+		t.Fatalf("expected demotion with another admin left, got %v", err)
+	}
+
+	reloaded, err := NewService(usersFile, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if !reloaded.IsAdmin(member) || reloaded.IsAdmin(owner) {
+		t.Fatalf("expected roles persisted across restarts")
+	}
+}
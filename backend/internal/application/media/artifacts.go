@@ -0,0 +1,162 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"evd/internal/domain/media"
+)
+
+// ErrJobRunning is returned when outputs cannot be removed because a conversion
+// is writing them.
+var ErrJobRunning = errors.New("conversion is running")
+
+// ClearArtifacts deletes derived HLS and/or MP4 outputs of a library video while
+// keeping the source. Every stream selection known from the source's probe is
+// cleared: both HLS segment formats, each audio track and, for MP4, each subtitle
+// selection. Finished, failed and paused jobs for those outputs return to idle.
+// Nothing is removed while any of those conversions is running. The MP4 marker
+// is shared by all outputs in a directory and is removed with the last of them.
+func (s *Service) ClearArtifacts(rawPath string, clearHLS, clearMP4 bool) error {
+	rel, full, err := s.store.ResolveVideoPath(rawPath)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(full); err != nil {
+		return err
+	}
+
+	hlsVariants, mp4Variants := s.artifactVariants(rel)
+	if !clearHLS {
+		hlsVariants = nil
+	}
+	if !clearMP4 {
+		mp4Variants = nil
+	}
+	if err := s.removeArtifacts(hlsVariants, mp4Variants); err != nil {
+		return err
+	}
+
+	s.logger.Printf("Artifacts cleared: %s (hls=%t, mp4=%t)", rel, clearHLS, clearMP4)
+	return nil
+}
+
+// MoveVideo renames or moves a library video. Its HLS, MP4 and thumbnail outputs
+// are deleted rather than moved and are regenerated under the new name on
+// demand. Moves onto an existing file fail with os.ErrExist, and nothing is
+// moved while a conversion of the video is running.
+func (s *Service) MoveVideo(rawFrom, rawTo string) (string, error) {
+	from, full, err := s.store.ResolveVideoPath(rawFrom)
+	if err != nil {
+		return "", err
+	}
+	to, _, err := s.store.ResolveVideoPath(rawTo)
+	if err != nil {
+		return "", err
+	}
+	if from == to {
+		return "", errors.New("source and destination are the same")
+	}
+	if _, err := os.Stat(full); err != nil {
+		return "", err
+	}
+
+	hlsVariants, mp4Variants := s.artifactVariants(from)
+	for _, key := range artifactJobKeys(hlsVariants, mp4Variants) {
+		if s.jobs.IsRunning(key) {
+			return "", ErrJobRunning
+		}
+	}
+	if err := s.store.MoveVideo(from, to); err != nil {
+		return "", err
+	}
+
+	if err := s.removeArtifacts(hlsVariants, mp4Variants); err != nil {
+		// A conversion started in the meantime; it fails on the missing source.
+		s.logger.Printf("Artifacts kept after move: %s: %v", from, err)
+	}
+	_ = os.Remove(s.store.ThumbnailPath(from))
+
+	s.logger.Printf("Video moved: %s -> %s", from, to)
+	return to, nil
+}
+
+// removeArtifacts deletes the HLS outputs of hlsVariants and the MP4 outputs of
+// mp4Variants and returns their jobs to idle. Nothing is removed while any of
+// those conversions is running, and none of them can start during the removal.
+func (s *Service) removeArtifacts(hlsVariants, mp4Variants []string) error {
+	return s.jobs.ClearIdle(artifactJobKeys(hlsVariants, mp4Variants), func() error {
+		for _, variant := range hlsVariants {
+			for _, format := range []media.HLSFormat{media.HLSFormatTS, media.HLSFormatFMP4} {
+				outputDir, _, _ := s.store.HLSPaths(variant, format)
+				_ = os.RemoveAll(outputDir)
+			}
+		}
+		for _, variant := range mp4Variants {
+			outputDir, outputPath, _ := s.store.MP4Paths(variant)
+			_ = os.Remove(outputPath)
+			_ = os.Remove(outputPath + ".tmp.mp4")
+			s.forgetVerified(outputPath)
+			if !hasMP4Outputs(outputDir) {
+				_ = os.Remove(filepath.Join(outputDir, mp4MarkerFile))
+			}
+		}
+		return nil
+	})
+}
+
+// hasMP4Outputs reports whether dir still holds an MP4 output sharing its marker.
+func hasMP4Outputs(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".mp4" {
+			return true
+		}
+	}
+	return false
+}
+
+func artifactJobKeys(hlsVariants, mp4Variants []string) []string {
+	keys := make([]string, 0, 2*len(hlsVariants)+len(mp4Variants))
+	for _, variant := range hlsVariants {
+		keys = append(keys, jobKey(media.JobHLS, variant), jobKey(media.JobHLSFMP4, variant))
+	}
+	for _, variant := range mp4Variants {
+		keys = append(keys, jobKey(media.JobMP4, variant))
+	}
+	return keys
+}
+
+// artifactVariants lists the output names rel may have been converted under: the
+// default selection plus each audio track and subtitle selection reported by the
+// probe. A failed probe yields only the default selection.
+func (s *Service) artifactVariants(rel string) ([]string, []string) {
+	audio := []int{media.DefaultAudioTrack}
+	subs := []media.SubtitleSelection{media.NoSubtitles}
+
+	ctx, cancel := context.WithTimeout(context.Background(), readinessProbeTimeout)
+	defer cancel()
+	if info, err := s.Probe(ctx, rel); err == nil {
+		for i := range info.AudioTracks {
+			audio = append(audio, i)
+		}
+		for i := range info.SubtitleTracks {
+			subs = append(subs, media.SubtitleSelection{Index: i}, media.SubtitleSelection{Index: i, Burn: true})
+		}
+	}
+
+	hls := make([]string, 0, len(audio))
+	mp4 := make([]string, 0, len(audio)*len(subs))
+	for _, track := range audio {
+		hls = append(hls, media.AudioVariantPath(rel, track))
+		for _, selection := range subs {
+			mp4 = append(mp4, mp4Variant(rel, track, selection))
+		}
+	}
+	return hls, mp4
+}
@@ -0,0 +1,152 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+func TestClearArtifacts_RemovesSelectedOutputs(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	full := store.writeVideo(t, "movie.mkv", 1024)
+	converter.durations[full] = 60
+	converter.subtitles = []media.SubtitleTrack{{Index: 0, Text: true}}
+
+	hlsDir, _, _ := store.HLSPaths("movie.mkv", media.HLSFormatTS)
+	fmp4Dir, _, _ := store.HLSPaths("movie.mkv", media.HLSFormatFMP4)
+	for _, dir := range []string{hlsDir, fmp4Dir} {
+		if err := svc.prepareHLSOutput(dir); err != nil {
+			t.Fatalf("prepare hls: %v", err)
+		}
+	}
+	mp4Dir, mp4Path := writeMP4Output(t, store, "movie.mkv", 64)
+	_, burnedPath := writeMP4Output(t, store, media.SubtitleVariantPath("movie.mkv", media.SubtitleSelection{Index: 0, Burn: true}), 64)
+	_, otherPath := writeMP4Output(t, store, "other.mkv", 64)
+
+	converter.hlsRelease = make(chan struct{})
+	if _, err := svc.StartHLS(context.Background(), "movie.mkv", false, "", media.DefaultAudioTrack, ""); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := svc.ClearArtifacts("movie.mkv", true, false); !errors.Is(err, ErrJobRunning) {
+		t.Fatalf("expected running conversion to block clearing, got %v", err)
+	}
+	if _, err := os.Stat(hlsDir); err != nil {
+		t.Fatalf("expected output kept while converting: %v", err)
+	}
+	close(converter.hlsRelease)
+	waitForJobState(t, svc, jobKey(media.JobHLS, "movie.mkv"), media.StateReady)
+
+	if err := svc.ClearArtifacts("movie.mkv", true, false); err != nil {
+		t.Fatalf("clear hls: %v", err)
+	}
+	for _, dir := range []string{hlsDir, fmp4Dir} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Fatalf("expected %s removed, got %v", dir, err)
+		}
+	}
+	if state, _, _ := svc.jobs.Status(jobKey(media.JobHLS, "movie.mkv")); state != media.StateIdle {
+		t.Fatalf("expected HLS job reset to idle, got %s", state)
+	}
+	if _, err := os.Stat(mp4Path); err != nil {
+		t.Fatalf("expected MP4 kept when clearing HLS only: %v", err)
+	}
+
+	if err := svc.ClearArtifacts("movie.mkv", false, true); err != nil {
+		t.Fatalf("clear mp4: %v", err)
+	}
+	for _, path := range []string{mp4Path, burnedPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected %s removed, got %v", path, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(mp4Dir, mp4MarkerFile)); err != nil {
+		t.Fatalf("expected the marker kept for another video's output: %v", err)
+	}
+	if _, err := os.Stat(full); err != nil {
+		t.Fatalf("expected source kept: %v", err)
+	}
+
+	store.writeVideo(t, "other.mkv", 1024)
+	if err := svc.ClearArtifacts("other.mkv", false, true); err != nil {
+		t.Fatalf("clear other mp4: %v", err)
+	}
+	if _, err := os.Stat(otherPath); !os.IsNotExist(err) {
+		t.Fatalf("expected %s removed, got %v", otherPath, err)
+	}
+	if _, err := os.Stat(filepath.Join(mp4Dir, mp4MarkerFile)); !os.IsNotExist(err) {
+		t.Fatalf("expected the marker removed with the last output, got %v", err)
+	}
+}
+
+func TestMoveVideo_MovesSourceAndDropsOutputs(t *testing.T) {
+	store := newFakeStore(t)
+	converter := &fakeConverter{durations: map[string]float64{}}
+	svc := NewService(targetStore{store}, converter, log.New(io.Discard, "", 0), Options{})
+	full := store.writeVideo(t, "movie.mkv", 1024)
+	converter.durations[full] = 60
+	store.writeVideo(t, "taken.mkv", 16)
+
+	hlsDir, _, _ := store.HLSPaths("movie.mkv", media.HLSFormatTS)
+	if err := svc.prepareHLSOutput(hlsDir); err != nil {
+		t.Fatalf("prepare hls: %v", err)
+	}
+	_, mp4Path := writeMP4Output(t, store, "movie.mkv", 64)
+
+	if _, err := svc.MoveVideo("movie.mkv", "taken.mkv"); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected existing destination to be refused, got %v", err)
+	}
+	if _, err := os.Stat(mp4Path); err != nil {
+		t.Fatalf("expected outputs kept after a refused move: %v", err)
+	}
+
+	moved, err := svc.MoveVideo("movie.mkv", "/films/Movie.mkv")
+	if err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if moved != "films/Movie.mkv" {
+		t.Fatalf("expected normalized destination, got %q", moved)
+	}
+	if _, err := os.Stat(filepath.Join(store.videosDir, "films", "Movie.mkv")); err != nil {
+		t.Fatalf("expected moved source: %v", err)
+	}
+	for _, path := range []string{full, hlsDir, mp4Path} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected %s gone after move, got %v", path, err)
+		}
+	}
+
+	if _, err := svc.MoveVideo("../etc/passwd.mkv", "x.mkv"); err == nil {
+		t.Fatalf("expected traversal outside the library to be rejected")
+	}
+}
+
+func TestClearIdle_HoldsOffJobStartsDuringRemoval(t *testing.T) {
+	jobs := newJobRegistry(nil)
+	started := make(chan struct{})
+	err := jobs.ClearIdle([]string{"mp4:movie.mkv"}, func() error {
+		go func() {
+			jobs.Start("mp4:movie.mkv")
+			close(started)
+		}()
+		select {
+		case <-started:
+			t.Errorf("expected the job start to wait for the removal")
+		case <-time.After(50 * time.Millisecond):
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	<-started
+	if err := jobs.ClearIdle([]string{"mp4:movie.mkv"}, func() error { return nil }); !errors.Is(err, ErrJobRunning) {
+		t.Fatalf("expected a running job to block clearing, got %v", err)
+	}
+}
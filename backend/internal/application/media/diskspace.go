@@ -0,0 +1,33 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrInsufficientSpace is returned when a conversion would not fit on the output
+// filesystem.
+var ErrInsufficientSpace = errors.New("insufficient disk space for conversion")
+
+// checkFreeSpace refuses a conversion of sourcePath into outputDir when the free
+// space there is below the source size plus the configured reserve. The source
+// size is a heuristic for the output size; transcodes of efficient codecs can
+// exceed it. Filesystems whose free space cannot be read are not checked.
+func (s *Service) checkFreeSpace(sourcePath, outputDir string) error {
+	info, err := os.Stat(sourcePath)
+	if err != nil {
+		return err
+	}
+	free, err := s.store.FreeSpace(outputDir)
+	if err != nil {
+		s.logger.Printf("Free space check skipped for %s: %v", outputDir, err)
+		return nil
+	}
+
+	required := uint64(info.Size()) + uint64(s.minFreeBytes)
+	if free < required {
+		return fmt.Errorf("%w: %d bytes required, %d available", ErrInsufficientSpace, required, free)
+	}
+	return nil
+}
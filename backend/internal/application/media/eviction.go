@@ -0,0 +1,207 @@
+package media
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+const defaultEvictionInterval = 10 * time.Minute
+
+// outputSet is one evictable transcode output: an HLS output directory or an MP4
+// file. lastUsed is when it was last streamed, or else last written.
+type outputSet struct {
+	path     string
+	hls      bool
+	bytes    int64
+	lastUsed time.Time
+}
+
+// StartEvictionSweeper periodically evicts least-recently-used transcode outputs
+// while HLS and MP4 output together exceed Options.MaxTranscodeBytes. It does
+// nothing without a budget.
+func (s *Service) StartEvictionSweeper(ctx context.Context, interval time.Duration) {
+	if s.maxTranscodeBytes <= 0 {
+		return
+	}
+	if interval <= 0 {
+		interval = defaultEvictionInterval
+	}
+
+	s.evictOnce.Do(func() {
+		s.logger.Printf("Transcode eviction enabled: budget=%d bytes interval=%s", s.maxTranscodeBytes, interval)
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				s.EvictOutputs()
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	})
+}
+
+// EvictOutputs deletes least-recently-used HLS and MP4 outputs until their
+// combined size fits the budget. Outputs of running conversions are never
+// evicted. It returns the number of outputs removed.
+func (s *Service) EvictOutputs() int {
+	if s.maxTranscodeBytes <= 0 {
+		return 0
+	}
+
+	sets := s.listOutputSets()
+	var total int64
+	for _, set := range sets {
+		total += set.bytes
+	}
+	if total <= s.maxTranscodeBytes {
+		return 0
+	}
+
+	sort.Slice(sets, func(i, k int) bool { return sets[i].lastUsed.Before(sets[k].lastUsed) })
+	removed := 0
+	for _, set := range sets {
+		if total <= s.maxTranscodeBytes {
+			break
+		}
+		// Checked per output, as conversions may have started since the listing.
+		if s.activeOutputs()[set.path] {
+			continue
+		}
+
+		var err error
+		if set.hls {
+			err = os.RemoveAll(set.path)
+		} else {
+			err = os.Remove(set.path)
+			s.forgetVerified(set.path)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			s.logger.Printf("Transcode eviction failed: %s: %v", set.path, err)
+			continue
+		}
+
+		s.servedMu.Lock()
+		delete(s.lastServed, set.path)
+		s.servedMu.Unlock()
+		total -= set.bytes
+		removed++
+		s.logger.Printf("Transcode output evicted: %s (%d bytes, last used %s)", set.path, set.bytes, set.lastUsed.Format(time.RFC3339))
+	}
+	return removed
+}
+
+// MarkServed records that path, a file inside an HLS output or an MP4 output,
+// was just streamed, so its output is evicted last.
+func (s *Service) MarkServed(path string) {
+	if s.maxTranscodeBytes <= 0 {
+		return
+	}
+
+	hlsRoot, mp4Root := s.store.OutputRoots()
+	path = filepath.Clean(path)
+	key := ""
+	switch {
+	case withinRoot(mp4Root, path):
+		key = path
+	case withinRoot(hlsRoot, path):
+		for dir := filepath.Dir(path); withinRoot(hlsRoot, dir); dir = filepath.Dir(dir) {
+			if _, err := os.Stat(filepath.Join(dir, hlsMarkerFile)); err == nil {
+				key = dir
+				break
+			}
+		}
+	}
+	if key == "" {
+		return
+	}
+
+	s.servedMu.Lock()
+	s.lastServed[key] = time.Now()
+	s.servedMu.Unlock()
+}
+
+// listOutputSets finds HLS output directories (those holding a marker) and MP4
+// files below the output roots.
+func (s *Service) listOutputSets() []outputSet {
+	hlsRoot, mp4Root := s.store.OutputRoots()
+	var sets []outputSet
+
+	_ = filepath.WalkDir(hlsRoot, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		if _, err := os.Stat(filepath.Join(path, hlsMarkerFile)); err != nil {
+			return nil
+		}
+		set := outputSet{path: path, hls: true}
+		_ = filepath.WalkDir(path, func(_ string, file fs.DirEntry, err error) error {
+			if err != nil || file.IsDir() {
+				return nil
+			}
+			if info, err := file.Info(); err == nil {
+				set.bytes += info.Size()
+				if info.ModTime().After(set.lastUsed) {
+					set.lastUsed = info.ModTime()
+				}
+			}
+			return nil
+		})
+		sets = append(sets, set)
+		return filepath.SkipDir
+	})
+
+	_ = filepath.WalkDir(mp4Root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(entry.Name(), ".mp4") || strings.HasSuffix(entry.Name(), ".tmp.mp4") {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			sets = append(sets, outputSet{path: path, bytes: info.Size(), lastUsed: info.ModTime()})
+		}
+		return nil
+	})
+
+	s.servedMu.Lock()
+	for i := range sets {
+		if served, ok := s.lastServed[sets[i].path]; ok && served.After(sets[i].lastUsed) {
+			sets[i].lastUsed = served
+		}
+	}
+	s.servedMu.Unlock()
+	return sets
+}
+
+// activeOutputs returns the output paths of processing HLS and MP4 conversions.
+func (s *Service) activeOutputs() map[string]bool {
+	out := make(map[string]bool)
+	for _, job := range s.jobs.Active() {
+		switch job.Type {
+		case media.JobHLS:
+			outputDir, _, _ := s.store.HLSPaths(job.Path, media.HLSFormatTS)
+			out[filepath.Clean(outputDir)] = true
+		case media.JobHLSFMP4:
+			outputDir, _, _ := s.store.HLSPaths(job.Path, media.HLSFormatFMP4)
+			out[filepath.Clean(outputDir)] = true
+		case media.JobMP4:
+			_, outputPath, _ := s.store.MP4Paths(job.Path)
+			out[filepath.Clean(outputPath)] = true
+		}
+	}
+	return out
+}
+
+func withinRoot(root, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(root), path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
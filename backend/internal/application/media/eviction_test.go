@@ -0,0 +1,66 @@
+package media
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+func TestEvictOutputs_RemovesLeastRecentlyUsedFirst(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{MaxTranscodeBytes: 250})
+	setAge := func(path string, age time.Duration) {
+		t.Helper()
+		when := time.Now().Add(-age)
+		_ = filepath.Walk(path, func(p string, _ os.FileInfo, _ error) error {
+			return os.Chtimes(p, when, when)
+		})
+	}
+
+	oldDir, _, _ := store.HLSPaths("old.mkv", media.HLSFormatTS)
+	if err := svc.prepareHLSOutput(oldDir); err != nil {
+		t.Fatalf("prepare hls: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(oldDir, "segment00000.ts"), make([]byte, 100), 0o644); err != nil {
+		t.Fatalf("write segment: %v", err)
+	}
+	setAge(oldDir, 3*time.Hour)
+
+	_, watchedPath := writeMP4Output(t, store, "watched.mkv", 100)
+	setAge(watchedPath, 4*time.Hour)
+	_, recentPath := writeMP4Output(t, store, "recent.mkv", 100)
+	setAge(recentPath, time.Hour)
+
+	// A running conversion is never evicted, however old its output.
+	store.writeVideo(t, "busy.mkv", 10)
+	converter.hlsRelease = make(chan struct{})
+	defer close(converter.hlsRelease)
+	if _, err := svc.StartHLS(context.Background(), "busy.mkv", false, "", media.DefaultAudioTrack, ""); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	busyDir, _, _ := store.HLSPaths("busy.mkv", media.HLSFormatTS)
+	waitForJobState(t, svc, jobKey(media.JobHLS, "busy.mkv"), media.StateProcessing)
+	setAge(busyDir, 24*time.Hour)
+
+	svc.MarkServed(watchedPath)
+	svc.MarkServed(filepath.Join(oldDir, "index.m3u8"))
+	setAge(oldDir, 3*time.Hour)
+
+	if removed := svc.EvictOutputs(); removed != 1 {
+		t.Fatalf("expected one eviction, got %d", removed)
+	}
+	if _, err := os.Stat(recentPath); !os.IsNotExist(err) {
+		t.Fatalf("expected the least recently used output evicted, got %v", err)
+	}
+	for _, path := range []string{watchedPath, oldDir, busyDir} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("expected %s kept: %v", path, err)
+		}
+	}
+	if removed := svc.EvictOutputs(); removed != 0 {
+		t.Fatalf("expected nothing evicted under budget, got %d", removed)
+	}
+}
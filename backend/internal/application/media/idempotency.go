@@ -0,0 +1,95 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+// idempotencyWindow is how long a client-supplied Idempotency-Key is remembered.
+const idempotencyWindow = 10 * time.Minute
+
+var ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different request")
+
+type idempotencyEntry struct {
+	jobKey  string
+	expires time.Time
+	done    chan struct{}
+	status  media.JobStatus
+	err     error
+}
+
+// idempotencyCache remembers start requests by client key so retries share one outcome.
+type idempotencyCache struct {
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+}
+
+func newIdempotencyCache() *idempotencyCache {
+	return &idempotencyCache{entries: make(map[string]*idempotencyEntry)}
+}
+
+// begin returns the entry for key. owner reports whether the caller must run the
+// request and call finish; otherwise it should wait for entry.done.
+func (c *idempotencyCache) begin(key, jobKey string, now time.Time) (*idempotencyEntry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	if entry, ok := c.entries[key]; ok {
+		if entry.jobKey != jobKey {
+			return nil, false, ErrIdempotencyKeyReused
+		}
+		return entry, false, nil
+	}
+
+	entry := &idempotencyEntry{jobKey: jobKey, expires: now.Add(idempotencyWindow), done: make(chan struct{})}
+	c.entries[key] = entry
+	return entry, true, nil
+}
+
+// finish publishes the outcome. Failed requests are forgotten so a retry can run again.
+func (c *idempotencyCache) finish(key string, entry *idempotencyEntry, status media.JobStatus, err error) {
+	entry.status = status
+	entry.err = err
+
+	c.mu.Lock()
+	if err != nil && c.entries[key] == entry {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	close(entry.done)
+}
+
+// idempotent runs start once per idempotency key; repeated calls with the same key
+// return the first call's status instead of re-preparing outputs.
+func (s *Service) idempotent(ctx context.Context, key, jobKey string, start func() (media.JobStatus, error)) (media.JobStatus, error) {
+	if key == "" {
+		return start()
+	}
+
+	entry, owner, err := s.idempotency.begin(key, jobKey, time.Now())
+	if err != nil {
+		return media.JobStatus{}, err
+	}
+	if !owner {
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return media.JobStatus{}, ctx.Err()
+		}
+		return entry.status, entry.err
+	}
+
+	status, err := start()
+	s.idempotency.finish(key, entry, status, err)
+	return status, err
+}
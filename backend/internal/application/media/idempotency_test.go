@@ -0,0 +1,71 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+func TestStartHLS_SameIdempotencyKeyKeepsInProgressOutput(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	converter.hlsRelease = make(chan struct{})
+	defer close(converter.hlsRelease)
+	store.writeVideo(t, "movie.mkv", 1024)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.StartHLS(context.Background(), "movie.mkv", false, "", media.DefaultAudioTrack, "retry-1"); err != nil {
+				t.Errorf("start hls: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	outputDir, _, _ := store.HLSPaths("movie.mkv", media.HLSFormatTS)
+	segment := filepath.Join(outputDir, "segment00000.ts")
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := os.Stat(segment); err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err := svc.StartHLS(context.Background(), "movie.mkv", false, "", media.DefaultAudioTrack, "retry-1"); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if _, err := os.Stat(segment); err != nil {
+		t.Fatalf("expected in-progress segment to survive retries, got %v", err)
+	}
+
+	converter.mu.Lock()
+	calls := converter.hlsCalls
+	converter.mu.Unlock()
+	if calls != 1 {
+		t.Fatalf("expected a single conversion, got %d", calls)
+	}
+}
+
+func TestIdempotencyKey_RejectsReuseForOtherJob(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	converter.hlsRelease = make(chan struct{})
+	defer close(converter.hlsRelease)
+	store.writeVideo(t, "a.mkv", 1024)
+	store.writeVideo(t, "b.mkv", 1024)
+
+	if _, err := svc.StartHLS(context.Background(), "a.mkv", false, "", media.DefaultAudioTrack, "key"); err != nil {
+		t.Fatalf("start hls: %v", err)
+	}
+	if _, err := svc.StartHLS(context.Background(), "b.mkv", false, "", media.DefaultAudioTrack, "key"); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Fatalf("expected key reuse to be rejected, got %v", err)
+	}
+}
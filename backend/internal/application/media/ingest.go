@@ -0,0 +1,300 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+const (
+	defaultIngestMaxBytes    = 8 << 30
+	defaultIngestMaxDuration = 4 * time.Hour
+	ingestResolveTimeout     = 15 * time.Second
+	ingestMaxRedirects       = 5
+
+	// ingestPartSuffix keeps in-flight ingests out of library listings until complete.
+	ingestPartSuffix = ".ingest"
+	// ingestSourceSuffix names the downloaded copy of the source ffmpeg reads from.
+	ingestSourceSuffix = ".ingest-src"
+)
+
+var (
+	ErrIngestDisabled   = errors.New("URL ingest is disabled")
+	ErrIngestNotAllowed = errors.New("URL is not allowed")
+	ErrIngestExists     = errors.New("target file already exists")
+)
+
+// IngestOptions configures remote URL ingestion. It is disabled unless Enabled is set.
+type IngestOptions struct {
+	Enabled bool
+	// AllowedHosts lists hosts the source may be downloaded from. Entries may carry a port
+	// ("host:8080") or a leading wildcard ("*.example.com").
+	AllowedHosts []string
+	MaxBytes     int64
+	MaxDuration  time.Duration
+}
+
+// Ingest starts fetching a remote HTTP(S) video into the library as an MP4 and
+// returns the library path it will land at. When rawName is empty the file name
+// is derived from the URL path.
+func (s *Service) Ingest(ctx context.Context, rawURL, rawName string) (string, media.JobStatus, error) {
+	if !s.ingest.Enabled {
+		return "", media.JobStatus{}, ErrIngestDisabled
+	}
+
+	source, err := s.validateIngestURL(rawURL)
+	if err != nil {
+		return "", media.JobStatus{}, err
+	}
+
+	if strings.TrimSpace(rawName) == "" {
+		rawName = path.Base(source.Path)
+	}
+	rawName = strings.TrimSuffix(rawName, path.Ext(rawName)) + ".mp4"
+	rel, full, err := s.store.ResolveVideoPath(rawName)
+	if err != nil {
+		return "", media.JobStatus{}, err
+	}
+	streamURL := ingestURL(rel)
+
+	jobKey := jobKey(media.JobIngest, rel)
+	if s.jobs.IsRunning(jobKey) {
+		_, _, progress := s.jobs.Status(jobKey)
+		return rel, media.JobStatus{State: media.StateProcessing, Processing: true, URL: streamURL, Progress: progress}, nil
+	}
+	if _, err := os.Stat(full); err == nil {
+		return "", media.JobStatus{}, ErrIngestExists
+	}
+
+	resolved, err := s.resolveIngestURL(ctx, source)
+	if err != nil {
+		return "", media.JobStatus{}, err
+	}
+
+	jobCtx := s.jobs.Start(jobKey)
+	s.logger.Printf("URL ingest started: %s <- %s", rel, resolved.Redacted())
+	go func() {
+		partPath := full + ingestPartSuffix
+		sourcePath := full + ingestSourceSuffix
+		defer os.Remove(sourcePath)
+
+		// ffmpeg only ever sees the local copy, so it cannot be redirected to a
+		// host the allow-list would refuse.
+		err := s.downloadIngest(jobCtx, resolved, sourcePath, func(progress int) {
+			s.jobs.Progress(jobKey, progress/2)
+		})
+		if err == nil {
+			err = s.converter.IngestMP4(jobCtx, sourcePath, partPath, s.ingest.MaxBytes, s.ingest.MaxDuration, func(progress int) {
+				s.jobs.Progress(jobKey, 50+progress/2)
+			})
+		}
+		if err == nil {
+			err = os.Rename(partPath, full)
+		}
+		if errors.Is(err, media.ErrConversionStopped) || jobCtx.Err() != nil {
+			s.logger.Printf("URL ingest stopped: %s", rel)
+			_ = os.Remove(partPath)
+			s.jobs.Stopped(jobKey)
+			return
+		}
+		if err != nil {
+			s.logger.Printf("URL ingest failed: %s: %v", rel, err)
+			_ = os.Remove(partPath)
+			s.jobs.Fail(jobKey, err)
+			return
+		}
+		// The new file is picked up by the regular library scan and prewarm passes.
+		s.logger.Printf("URL ingest finished: %s", rel)
+		s.jobs.Ready(jobKey)
+	}()
+
+	return rel, media.JobStatus{State: media.StateProcessing, Processing: true, URL: streamURL}, nil
+}
+
+// IngestStatus returns the state of a URL ingest targeting rawPath.
+func (s *Service) IngestStatus(rawPath string) (media.JobStatus, error) {
+	rel, full, err := s.store.ResolveVideoPath(rawPath)
+	if err != nil {
+		return media.JobStatus{}, err
+	}
+	streamURL := ingestURL(rel)
+
+	state, jobErr, progress := s.jobs.Status(jobKey(media.JobIngest, rel))
+	switch state {
+	case media.StateFailed:
+		return media.JobStatus{State: media.StateFailed, Error: jobErr, URL: streamURL, Progress: progress}, nil
+	case media.StateProcessing:
+		return media.JobStatus{State: media.StateProcessing, Processing: true, URL: streamURL, Progress: progress}, nil
+	}
+
+	if _, err := os.Stat(full); err == nil {
+		return media.JobStatus{State: media.StateReady, Ready: true, URL: streamURL, Progress: 100}, nil
+	}
+	return media.JobStatus{State: media.StateIdle, URL: streamURL}, nil
+}
+
+func ingestURL(relPath string) string {
+	return "/api/stream/" + relPath
+}
+
+// validateIngestURL accepts only absolute http(s) URLs on allow-listed hosts.
+func (s *Service) validateIngestURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || strings.IndexFunc(raw, func(r rune) bool { return r <= ' ' || r == 0x7f }) >= 0 {
+		return nil, ErrIngestNotAllowed
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, ErrIngestNotAllowed
+	}
+	if err := s.checkIngestURL(u); err != nil {
+		return nil, err
+	}
+	u.Fragment = ""
+	return u, nil
+}
+
+func (s *Service) checkIngestURL(u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return ErrIngestNotAllowed
+	}
+	if u.Opaque != "" || u.User != nil || u.Hostname() == "" {
+		return ErrIngestNotAllowed
+	}
+	if !ingestHostAllowed(u, s.ingest.AllowedHosts) {
+		return ErrIngestNotAllowed
+	}
+	return nil
+}
+
+func ingestHostAllowed(u *url.URL, allowed []string) bool {
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+
+	for _, entry := range allowed {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		entryHost, entryPort := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			entryHost, entryPort = h, p
+		}
+		if entryPort != port {
+			continue
+		}
+		if entryHost == host {
+			return true
+		}
+		if strings.HasPrefix(entryHost, "*.") && strings.HasSuffix(host, entryHost[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ingestClient returns an HTTP client that checks every redirect hop against
+// the allow-list.
+func (s *Service) ingestClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= ingestMaxRedirects {
+				return errors.New("too many redirects")
+			}
+			return s.checkIngestURL(req.URL)
+		},
+	}
+}
+
+// resolveIngestURL follows redirects up front so a refused source fails the
+// request instead of the background job, and returns the final URL.
+func (s *Service) resolveIngestURL(ctx context.Context, source *url.URL) (*url.URL, error) {
+	client := s.ingestClient(ingestResolveTimeout)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, source.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusMethodNotAllowed {
+		return nil, fmt.Errorf("source returned %s", resp.Status)
+	}
+	return resp.Request.URL, nil
+}
+
+// downloadIngest copies source into dst through the allow-list checked client,
+// stopping once MaxBytes is exceeded. Progress is reported when the source
+// announces its length.
+func (s *Service) downloadIngest(ctx context.Context, source *url.URL, dst string, onProgress func(int)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.ingestClient(0).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("source returned %s", resp.Status)
+	}
+
+	maxBytes := s.ingest.MaxBytes
+	if maxBytes > 0 && resp.ContentLength > maxBytes {
+		return fmt.Errorf("source is larger than %d bytes", maxBytes)
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	var body io.Reader = resp.Body
+	if maxBytes > 0 {
+		body = io.LimitReader(resp.Body, maxBytes+1)
+	}
+	written, err := io.Copy(out, &ingestProgressReader{r: body, total: resp.ContentLength, onProgress: onProgress})
+	if err != nil {
+		return err
+	}
+	if maxBytes > 0 && written > maxBytes {
+		return fmt.Errorf("source is larger than %d bytes", maxBytes)
+	}
+	return out.Close()
+}
+
+type ingestProgressReader struct {
+	r          io.Reader
+	read       int64
+	total      int64
+	last       int
+	onProgress func(int)
+}
+
+func (p *ingestProgressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if p.total > 0 {
+		if percent := int(p.read * 100 / p.total); percent > p.last && percent <= 100 {
+			p.last = percent
+			p.onProgress(percent)
+		}
+	}
+	return n, err
+}
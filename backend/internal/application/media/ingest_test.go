@@ -0,0 +1,166 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+// targetStore resolves paths that do not exist yet, like the filesystem store
+// does for ingest and move destinations.
+type targetStore struct {
+	*fakeStore
+}
+
+func (f targetStore) ResolveVideoPath(raw string) (string, string, error) {
+	rel, err := media.NormalizeVideoPath(raw)
+	if err != nil {
+		return "", "", err
+	}
+	return rel, filepath.Join(f.videosDir, filepath.FromSlash(rel)), nil
+}
+
+func newIngestService(t *testing.T, allowed ...string) (*Service, *fakeStore) {
+	t.Helper()
+	store := newFakeStore(t)
+	converter := &fakeConverter{durations: map[string]float64{}}
+	svc := NewService(targetStore{store}, converter, log.New(io.Discard, "", 0), Options{
+		Ingest: IngestOptions{Enabled: true, AllowedHosts: allowed},
+	})
+	return svc, store
+}
+
+func TestValidateIngestURL(t *testing.T) {
+	svc, _ := newIngestService(t, "media.example.com", "*.cdn.example.org", "nas.local:8096")
+
+	cases := map[string]bool{
+		"https://media.example.com/a.mkv":        true,
+		"http://edge.cdn.example.org/a.mkv":      true,
+		"http://nas.local:8096/a.mkv":            true,
+		"https://media.example.com:8443/a.mkv":   false,
+		"http://nas.local/a.mkv":                 false,
+		"https://evil.com/a.mkv":                 false,
+		"https://media.example.com.evil.com/a":   false,
+		"https://user:pw@media.example.com/a":    false,
+		"file:///etc/passwd":                     false,
+		"ftp://media.example.com/a.mkv":          false,
+		"https://media.example.com/a b.mkv":      false,
+		"//media.example.com/a.mkv":              false,
+		"https://cdn.example.org.attacker/a.mkv": false,
+	}
+	for raw, want := range cases {
+		_, err := svc.validateIngestURL(raw)
+		if got := err == nil; got != want {
+			t.Errorf("%s: expected allowed=%v, got err=%v", raw, want, err)
+		}
+	}
+}
+
+func TestIngest_DisabledByDefault(t *testing.T) {
+	svc, _, _ := newTestService(t, Options{})
+	if _, _, err := svc.Ingest(context.Background(), "https://media.example.com/a.mkv", ""); !errors.Is(err, ErrIngestDisabled) {
+		t.Fatalf("expected ingest to be disabled, got %v", err)
+	}
+}
+
+func TestIngest_RejectsRedirectToDisallowedHost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://internal.invalid/secret.mkv", http.StatusFound)
+	}))
+	defer server.Close()
+
+	svc, _ := newIngestService(t, hostOf(t, server.URL))
+	if _, _, err := svc.Ingest(context.Background(), server.URL+"/movie.mkv", ""); !errors.Is(err, ErrIngestNotAllowed) {
+		t.Fatalf("expected redirect to be rejected, got %v", err)
+	}
+}
+
+func TestIngest_WritesLibraryMP4(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("source"))
+	}))
+	defer server.Close()
+
+	svc, store := newIngestService(t, hostOf(t, server.URL))
+	relPath, status, err := svc.Ingest(context.Background(), server.URL+"/shows/episode.mkv", "")
+	if err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	if relPath != "episode.mp4" || status.URL != "/api/stream/episode.mp4" {
+		t.Fatalf("expected derived mp4 name, got %q (%q)", relPath, status.URL)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		status, _ = svc.IngestStatus(relPath)
+		if status.State != media.StateProcessing || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !status.Ready {
+		t.Fatalf("expected ingest to finish, got %+v", status)
+	}
+	if _, err := os.Stat(filepath.Join(store.videosDir, "episode.mp4")); err != nil {
+		t.Fatalf("expected library file, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(store.videosDir, "episode.mp4"+ingestSourceSuffix)); !os.IsNotExist(err) {
+		t.Fatalf("expected the downloaded source removed, got %v", err)
+	}
+	if _, _, err := svc.Ingest(context.Background(), server.URL+"/episode.mkv", ""); !errors.Is(err, ErrIngestExists) {
+		t.Fatalf("expected existing target to be rejected, got %v", err)
+	}
+}
+
+func TestDownloadIngest_ChecksRedirectsAndSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect.mkv" {
+			http.Redirect(w, r, "http://internal.invalid/secret.mkv", http.StatusFound)
+			return
+		}
+		w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	svc, store := newIngestService(t, hostOf(t, server.URL))
+	svc.ingest.MaxBytes = 8
+	dst := filepath.Join(store.videosDir, "movie.mp4"+ingestSourceSuffix)
+	source := func(p string) *url.URL {
+		u, _ := url.Parse(server.URL + p)
+		return u
+	}
+	if err := svc.downloadIngest(context.Background(), source("/redirect.mkv"), dst, func(int) {}); !errors.Is(err, ErrIngestNotAllowed) {
+		t.Fatalf("expected the download to refuse the redirect, got %v", err)
+	}
+	if err := svc.downloadIngest(context.Background(), source("/movie.mkv"), dst, func(int) {}); err == nil {
+		t.Fatalf("expected a source over the size cap to fail")
+	}
+
+	svc.ingest.MaxBytes = 0
+	var progress int
+	if err := svc.downloadIngest(context.Background(), source("/movie.mkv"), dst, func(p int) { progress = p }); err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "0123456789" || progress != 100 {
+		t.Fatalf("expected the full source with progress 100, got %q at %d", data, progress)
+	}
+}
+
+func hostOf(t *testing.T, raw string) string {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse url: %v", err)
+	}
+	return u.Host
+}
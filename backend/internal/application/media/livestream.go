@@ -0,0 +1,28 @@
+package media
+
+import "errors"
+
+// ErrLiveStreamsFull is returned when a live stream would exceed
+// Options.LiveStreamConcurrency.
+var ErrLiveStreamsFull = errors.New("too many live streams running")
+
+func newLiveStreamSlots(limit int) chan struct{} {
+	if limit <= 0 {
+		return nil
+	}
+	return make(chan struct{}, limit)
+}
+
+// acquireLiveStream takes a live stream slot without waiting. ok is false when
+// every slot is taken; otherwise release must be called when the stream ends.
+func (s *Service) acquireLiveStream() (release func(), ok bool) {
+	if s.liveStreams == nil {
+		return func() {}, true
+	}
+	select {
+	case s.liveStreams <- struct{}{}:
+		return func() { <-s.liveStreams }, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,49 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestStreamMP4_RefusesStreamsOverTheCap(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{LiveStreamConcurrency: 1})
+	store.writeVideo(t, "movie.mkv", 1024)
+	store.writeVideo(t, "other.mkv", 1024)
+	converter.streamRelease = make(chan struct{})
+
+	first := make(chan error, 1)
+	go func() { first <- svc.StreamMP4(context.Background(), "movie.mkv", false, io.Discard) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(svc.liveStreams) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the first stream to take the only slot")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := svc.StreamMP4(context.Background(), "other.mkv", false, io.Discard); !errors.Is(err, ErrLiveStreamsFull) {
+		t.Fatalf("expected ErrLiveStreamsFull while the slot is taken, got %v", err)
+	}
+
+	close(converter.streamRelease)
+	if err := <-first; err != nil {
+		t.Fatalf("first stream: %v", err)
+	}
+	if err := svc.StreamMP4(context.Background(), "other.mkv", false, io.Discard); err != nil {
+		t.Fatalf("expected a stream once the slot is free, got %v", err)
+	}
+}
+
+func TestStreamMP4_UnlimitedWithoutCap(t *testing.T) {
+	svc, store, _ := newTestService(t, Options{})
+	store.writeVideo(t, "movie.mkv", 1024)
+	if svc.liveStreams != nil {
+		t.Fatalf("expected no live stream slots when the cap is zero")
+	}
+	if err := svc.StreamMP4(context.Background(), "movie.mkv", false, io.Discard); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+}
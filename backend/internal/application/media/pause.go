@@ -0,0 +1,289 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+// hlsPauseFile marks a paused HLS output and records where to resume it.
+const hlsPauseFile = ".paused"
+
+// jobStopTimeout bounds how long a pause waits for ffmpeg to exit.
+const jobStopTimeout = 30 * time.Second
+
+var (
+	ErrJobNotRunning  = errors.New("no running conversion")
+	ErrJobNotPaused   = errors.New("conversion is not paused")
+	ErrJobPausing     = errors.New("conversion is being paused")
+	ErrNotCancellable = errors.New("conversion type cannot be cancelled")
+	errJobStopTimeout = errors.New("conversion did not stop in time")
+
+	errStoppedUnexpectedly = errors.New("conversion stopped unexpectedly")
+)
+
+// PauseHLS stops a running HLS conversion to free CPU. The complete segments are
+// kept and the conversion can later continue from the last one via ResumeHLS.
+// Adaptive (multi-rendition) outputs are discarded instead.
+func (s *Service) PauseHLS(rawPath string, format media.HLSFormat, audio int) (media.JobStatus, error) {
+	rel, _, err := s.store.ResolveVideoPath(rawPath)
+	if err != nil {
+		return media.JobStatus{}, err
+	}
+
+	format = s.resolveHLSFormat(format)
+	variant := media.AudioVariantPath(rel, audio)
+	outputDir, playlist, url := s.store.HLSPaths(variant, format)
+	key := jobKey(hlsJobType(format), variant)
+	defer s.jobs.Release(key)
+	if err := s.stopJob(key); err != nil {
+		return media.JobStatus{}, err
+	}
+	if state, _, _ := s.jobs.Status(key); state != media.StatePaused {
+		// The conversion ended on its own before it could be stopped.
+		return s.HLSStatus(rel, format, audio)
+	}
+
+	if _, master := masterVariants(outputDir, playlist); master {
+		// Adaptive outputs have no single resume point and restart from scratch.
+		s.logger.Printf("HLS conversion paused: %s (adaptive output discarded)", variant)
+		_ = os.RemoveAll(outputDir)
+		return media.JobStatus{State: media.StatePaused, URL: url}, nil
+	}
+
+	point, err := captureHLSResumePoint(outputDir, playlist)
+	if err == nil && point.Segments > 0 {
+		err = writeHLSPause(outputDir, point)
+	}
+	if err != nil || point.Segments == 0 {
+		if err != nil {
+			s.logger.Printf("HLS pause could not keep output: %s: %v", variant, err)
+		}
+		_ = os.RemoveAll(outputDir)
+		return media.JobStatus{State: media.StatePaused, URL: url}, nil
+	}
+
+	s.logger.Printf("HLS conversion paused: %s after %d segments", variant, point.Segments)
+	return media.JobStatus{State: media.StatePaused, Resumable: true, URL: url, Segments: point.Segments}, nil
+}
+
+// ResumeHLS continues a paused HLS conversion after its last complete segment.
+func (s *Service) ResumeHLS(rawPath string, format media.HLSFormat, audio int) (media.JobStatus, error) {
+	rel, full, err := s.store.ResolveVideoPath(rawPath)
+	if err != nil {
+		return media.JobStatus{}, err
+	}
+
+	format = s.resolveHLSFormat(format)
+	variant := media.AudioVariantPath(rel, audio)
+	outputDir, _, url := s.store.HLSPaths(variant, format)
+	if s.jobs.IsHeld(jobKey(hlsJobType(format), variant)) {
+		return media.JobStatus{}, ErrJobPausing
+	}
+	if s.jobs.IsRunning(jobKey(hlsJobType(format), variant)) {
+		return media.JobStatus{State: media.StateProcessing, Processing: true, URL: url}, nil
+	}
+
+	point, paused := readHLSPause(outputDir)
+	if !paused {
+		return media.JobStatus{}, ErrJobNotPaused
+	}
+	return s.resumeHLS(rel, full, format, audio, point)
+}
+
+func (s *Service) resumeHLS(rel, full string, format media.HLSFormat, audio int, point media.HLSResumePoint) (media.JobStatus, error) {
+	variant := media.AudioVariantPath(rel, audio)
+	outputDir, playlist, url := s.store.HLSPaths(variant, format)
+	if err := os.Remove(filepath.Join(outputDir, hlsPauseFile)); err != nil {
+		return media.JobStatus{}, err
+	}
+
+	s.logger.Printf("HLS conversion resumed: %s from segment %d", variant, point.Segments)
+	s.runHLS(variant, jobKey(hlsJobType(format), variant), outputDir, func(ctx context.Context) error {
+		return s.converter.ResumeHLS(ctx, full, outputDir, playlist, format, audio, point)
+	})
+
+	return media.JobStatus{State: media.StateProcessing, Processing: true, URL: url, Segments: point.Segments}, nil
+}
+
+// PauseMP4 stops a running MP4 conversion. MP4 output cannot be resumed, so the
+// partial file is discarded and a later start converts from scratch.
+func (s *Service) PauseMP4(rawPath string, audio int, subs media.SubtitleSelection) (media.JobStatus, error) {
+	rel, _, err := s.store.ResolveVideoPath(rawPath)
+	if err != nil {
+		return media.JobStatus{}, err
+	}
+
+	key := jobKey(media.JobMP4, mp4Variant(rel, audio, subs))
+	defer s.jobs.Release(key)
+	if err := s.stopJob(key); err != nil {
+		return media.JobStatus{}, err
+	}
+	if state, _, _ := s.jobs.Status(key); state != media.StatePaused {
+		return s.MP4Status(rel, audio, subs)
+	}
+
+	s.logger.Printf("MP4 conversion paused: %s", mp4Variant(rel, audio, subs))
+	_, _, url := s.mp4Paths(rel, audio, subs)
+	return media.JobStatus{State: media.StatePaused, URL: url}, nil
+}
+
+// CancelJob stops an HLS or MP4 conversion for good. Unlike a pause, the partial
+// output is removed and the job returns to idle, so a later start converts from
+// scratch. A paused HLS conversion can be cancelled as well, discarding its segments.
+func (s *Service) CancelJob(rawPath string, jobType media.JobType, audio int, subs media.SubtitleSelection) error {
+	rel, _, err := s.store.ResolveVideoPath(rawPath)
+	if err != nil {
+		return err
+	}
+
+	variant := mp4Variant(rel, audio, subs)
+	var hlsDir, mp4Path string
+	switch jobType {
+	case media.JobHLS:
+		hlsDir, _, _ = s.store.HLSPaths(variant, media.HLSFormatTS)
+	case media.JobHLSFMP4:
+		hlsDir, _, _ = s.store.HLSPaths(variant, media.HLSFormatFMP4)
+	case media.JobMP4:
+		_, mp4Path, _ = s.store.MP4Paths(variant)
+	default:
+		return ErrNotCancellable
+	}
+
+	key := jobKey(jobType, variant)
+	defer s.jobs.Release(key)
+	err = s.stopJob(key)
+	switch {
+	case err == nil:
+		if state, _, _ := s.jobs.Status(key); state != media.StatePaused {
+			// The conversion ended on its own before it could be stopped.
+			return ErrJobNotRunning
+		}
+	case errors.Is(err, ErrJobNotRunning) && hlsDir != "" && hlsPaused(hlsDir):
+		// Nothing runs; only the paused output is left to discard.
+	default:
+		return err
+	}
+
+	if hlsDir != "" {
+		_ = os.RemoveAll(hlsDir)
+	} else {
+		_ = os.Remove(mp4Path)
+		s.forgetVerified(mp4Path)
+	}
+	s.jobs.Forget(key)
+	s.logger.Printf("%s conversion cancelled: %s", jobType, variant)
+	return nil
+}
+
+// stopJob cancels a running job and waits for its goroutine to exit. The job
+// stays held, so starts and resumes are refused until the caller releases it.
+func (s *Service) stopJob(key string) error {
+	done, ok := s.jobs.Hold(key)
+	if !ok {
+		return ErrJobNotRunning
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(jobStopTimeout):
+		return errJobStopTimeout
+	}
+}
+
+func readHLSPause(outputDir string) (media.HLSResumePoint, bool) {
+	raw, err := os.ReadFile(filepath.Join(outputDir, hlsPauseFile))
+	if err != nil {
+		return media.HLSResumePoint{}, false
+	}
+
+	var point media.HLSResumePoint
+	if err := json.Unmarshal(raw, &point); err != nil || point.Segments <= 0 {
+		return media.HLSResumePoint{}, false
+	}
+	return point, true
+}
+
+func hlsPaused(outputDir string) bool {
+	_, paused := readHLSPause(outputDir)
+	return paused
+}
+
+func writeHLSPause(outputDir string, point media.HLSResumePoint) error {
+	raw, err := json.Marshal(point)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(outputDir, hlsPauseFile), raw, 0o644)
+}
+
+// captureHLSResumePoint trims a stopped HLS output to its complete segments: leftover
+// `.tmp` segments are removed and the playlist is rewritten to end at the last segment
+// present on disk. It returns how many segments remain and the media time they cover.
+func captureHLSResumePoint(outputDir, playlistPath string) (media.HLSResumePoint, error) {
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		return media.HLSResumePoint{}, err
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			_ = os.Remove(filepath.Join(outputDir, entry.Name()))
+		}
+	}
+
+	data, err := os.ReadFile(playlistPath)
+	if err != nil {
+		return media.HLSResumePoint{}, err
+	}
+
+	var (
+		point   media.HLSResumePoint
+		kept    []string
+		pending []string
+		seconds float64
+	)
+	for _, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(raw)
+		switch {
+		case line == "" || line == "#EXT-X-ENDLIST":
+			continue
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			seconds, _ = strconv.ParseFloat(value, 64)
+			pending = append(pending, line)
+		case strings.HasPrefix(line, "#"):
+			if point.Segments == 0 && len(pending) == 0 {
+				kept = append(kept, line)
+			} else {
+				pending = append(pending, line)
+			}
+		default:
+			if !segmentComplete(outputDir, line) || seconds <= 0 {
+				return point, rewritePlaylist(playlistPath, kept)
+			}
+			kept = append(kept, pending...)
+			kept = append(kept, line)
+			pending = nil
+			point.Segments++
+			point.OffsetSeconds += seconds
+			seconds = 0
+		}
+	}
+	return point, rewritePlaylist(playlistPath, kept)
+}
+
+func rewritePlaylist(playlistPath string, lines []string) error {
+	tmpPath := playlistPath + ".resume"
+	if err := os.WriteFile(tmpPath, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, playlistPath)
+}
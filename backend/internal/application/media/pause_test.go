@@ -0,0 +1,217 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+func waitForJobState(t *testing.T, svc *Service, key string, want media.JobState) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if state, _, _ := svc.jobs.Status(key); state == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s to become %s", key, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPauseResumeHLS_ContinuesFromLastCompleteSegment(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	converter.hlsRelease = make(chan struct{})
+	defer close(converter.hlsRelease)
+	store.writeVideo(t, "movie.mkv", 1024)
+
+	if _, err := svc.StartHLS(context.Background(), "movie.mkv", false, "", media.DefaultAudioTrack, ""); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	outputDir, playlist, _ := store.HLSPaths("movie.mkv", media.HLSFormatTS)
+	// Two complete segments and a third still being written when the job stops.
+	for name, data := range map[string]string{
+		"segment00001.ts":     "seg",
+		"segment00002.ts.tmp": "partial",
+		"index.m3u8":          "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6.0,\nsegment00000.ts\n#EXTINF:5.5,\nsegment00001.ts\n#EXTINF:6.0,\nsegment00002.ts\n",
+	} {
+		if err := os.WriteFile(filepath.Join(outputDir, name), []byte(data), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	paused, err := svc.PauseHLS("movie.mkv", "", media.DefaultAudioTrack)
+	if err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if paused.State != media.StatePaused || !paused.Resumable || paused.Segments != 2 {
+		t.Fatalf("unexpected pause status %+v", paused)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "segment00002.ts.tmp")); !os.IsNotExist(err) {
+		t.Fatalf("expected partial segment removed, got %v", err)
+	}
+	if data, _ := os.ReadFile(playlist); strings.Contains(string(data), "segment00002") {
+		t.Fatalf("expected playlist trimmed to complete segments, got %q", data)
+	}
+
+	if status, _ := svc.HLSStatus("movie.mkv", "", media.DefaultAudioTrack); status.State != media.StatePaused || !status.Resumable || status.Ready {
+		t.Fatalf("expected paused status, got %+v", status)
+	}
+	if _, err := svc.PauseHLS("movie.mkv", "", media.DefaultAudioTrack); !errors.Is(err, ErrJobNotRunning) {
+		t.Fatalf("expected pausing an idle job to fail, got %v", err)
+	}
+
+	if _, err := svc.ResumeHLS("movie.mkv", "", media.DefaultAudioTrack); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	waitForJobState(t, svc, jobKey(media.JobHLS, "movie.mkv"), media.StateReady)
+
+	if len(converter.resumedAt) != 1 || converter.resumedAt[0] != (media.HLSResumePoint{Segments: 2, OffsetSeconds: 11.5}) {
+		t.Fatalf("unexpected resume points %+v", converter.resumedAt)
+	}
+	status, err := svc.HLSStatus("movie.mkv", "", media.DefaultAudioTrack)
+	if err != nil || !status.Ready || status.Segments != 3 {
+		t.Fatalf("expected resumed output ready with 3 segments, got %+v (%v)", status, err)
+	}
+	if _, err := svc.ResumeHLS("movie.mkv", "", media.DefaultAudioTrack); !errors.Is(err, ErrJobNotPaused) {
+		t.Fatalf("expected resuming a finished job to fail, got %v", err)
+	}
+}
+
+func TestPauseMP4_IsNotResumable(t *testing.T) {
+	svc, store, _ := newTestService(t, Options{})
+	store.writeVideo(t, "movie.mkv", 1024)
+	key := jobKey(media.JobMP4, "movie.mkv")
+	// Hold the only conversion slot so the job waits and can be stopped.
+	_ = svc.mp4Slots.acquire(context.Background(), "held", false, nil)
+	defer svc.mp4Slots.release("held")
+
+	if _, err := svc.StartMP4(context.Background(), "movie.mkv", media.DefaultAudioTrack, media.NoSubtitles, ""); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	status, err := svc.PauseMP4("movie.mkv", media.DefaultAudioTrack, media.NoSubtitles)
+	if err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if status.State != media.StatePaused || status.Resumable {
+		t.Fatalf("expected non-resumable pause, got %+v", status)
+	}
+	if svc.jobs.IsRunning(key) {
+		t.Fatalf("expected job to stop")
+	}
+	if status, _ := svc.MP4Status("movie.mkv", media.DefaultAudioTrack, media.NoSubtitles); status.State != media.StatePaused {
+		t.Fatalf("expected paused MP4 status, got %+v", status)
+	}
+}
+
+func TestCancelJob_DiscardsOutputAndReturnsToIdle(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	converter.hlsRelease = make(chan struct{})
+	defer close(converter.hlsRelease)
+	store.writeVideo(t, "movie.mkv", 1024)
+
+	if _, err := svc.StartHLS(context.Background(), "movie.mkv", false, media.HLSFormatTS, media.DefaultAudioTrack, ""); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	outputDir, _, _ := store.HLSPaths("movie.mkv", media.HLSFormatTS)
+
+	if err := svc.CancelJob("movie.mkv", media.JobHLS, media.DefaultAudioTrack, media.NoSubtitles); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Fatalf("expected output removed, got %v", err)
+	}
+	if status, _ := svc.HLSStatus("movie.mkv", media.HLSFormatTS, media.DefaultAudioTrack); status.State != media.StateIdle {
+		t.Fatalf("expected idle after cancel, got %+v", status)
+	}
+	if err := svc.CancelJob("movie.mkv", media.JobHLS, media.DefaultAudioTrack, media.NoSubtitles); !errors.Is(err, ErrJobNotRunning) {
+		t.Fatalf("expected ErrJobNotRunning, got %v", err)
+	}
+	if err := svc.CancelJob("movie.mkv", media.JobIngest, media.DefaultAudioTrack, media.NoSubtitles); !errors.Is(err, ErrNotCancellable) {
+		t.Fatalf("expected ErrNotCancellable, got %v", err)
+	}
+}
+
+func TestCancelJob_MP4AndPausedHLS(t *testing.T) {
+	svc, store, _ := newTestService(t, Options{})
+	store.writeVideo(t, "movie.mkv", 1024)
+	// Hold the only conversion slot so the MP4 job waits and can be cancelled.
+	_ = svc.mp4Slots.acquire(context.Background(), "held", false, nil)
+	defer svc.mp4Slots.release("held")
+
+	if _, err := svc.StartMP4(context.Background(), "movie.mkv", media.DefaultAudioTrack, media.NoSubtitles, ""); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := svc.CancelJob("movie.mkv", media.JobMP4, media.DefaultAudioTrack, media.NoSubtitles); err != nil {
+		t.Fatalf("cancel mp4: %v", err)
+	}
+	if status, _ := svc.MP4Status("movie.mkv", media.DefaultAudioTrack, media.NoSubtitles); status.State != media.StateIdle {
+		t.Fatalf("expected idle MP4 status, got %+v", status)
+	}
+
+	outputDir, _, _ := store.HLSPaths("movie.mkv", media.HLSFormatTS)
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeHLSPause(outputDir, media.HLSResumePoint{Segments: 2, OffsetSeconds: 12}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.CancelJob("movie.mkv", media.JobHLS, media.DefaultAudioTrack, media.NoSubtitles); err != nil {
+		t.Fatalf("cancel paused hls: %v", err)
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Fatalf("expected paused output removed, got %v", err)
+	}
+}
+
+func TestStartMP4_UnrequestedStopReportsFailure(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	converter.mp4Err = fmt.Errorf("ffmpeg %w", media.ErrConversionStopped)
+	store.writeVideo(t, "movie.mkv", 1024)
+
+	if _, err := svc.StartMP4(context.Background(), "movie.mkv", media.DefaultAudioTrack, media.NoSubtitles, ""); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	waitForJobState(t, svc, jobKey(media.JobMP4, "movie.mkv"), media.StateFailed)
+	if status, _ := svc.MP4Status("movie.mkv", media.DefaultAudioTrack, media.NoSubtitles); status.State != media.StateFailed {
+		t.Fatalf("expected a stop nobody asked for to report failed, got %+v", status)
+	}
+}
+
+func TestStartHLS_RefusedWhilePauseIsPending(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	converter.hlsRelease = make(chan struct{})
+	defer close(converter.hlsRelease)
+	store.writeVideo(t, "movie.mkv", 1024)
+
+	if _, err := svc.StartHLS(context.Background(), "movie.mkv", false, "", media.DefaultAudioTrack, ""); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	key := jobKey(media.JobHLS, "movie.mkv")
+	// A pause that has stopped ffmpeg but is still capturing the resume point.
+	done, ok := svc.jobs.Hold(key)
+	if !ok {
+		t.Fatalf("expected running job to be held")
+	}
+	<-done
+
+	if _, err := svc.StartHLS(context.Background(), "movie.mkv", false, "", media.DefaultAudioTrack, ""); !errors.Is(err, ErrJobPausing) {
+		t.Fatalf("expected ErrJobPausing from start, got %v", err)
+	}
+	if _, err := svc.ResumeHLS("movie.mkv", "", media.DefaultAudioTrack); !errors.Is(err, ErrJobPausing) {
+		t.Fatalf("expected ErrJobPausing from resume, got %v", err)
+	}
+
+	svc.jobs.Release(key)
+	if _, err := svc.StartHLS(context.Background(), "movie.mkv", false, "", media.DefaultAudioTrack, ""); err != nil {
+		t.Fatalf("expected start after the pause settled, got %v", err)
+	}
+}
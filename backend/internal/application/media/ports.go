@@ -12,16 +12,41 @@ import (
 type VideoRepository interface {
 	ListVideos() ([]mediadomain.Video, error)
 	ResolveVideoPath(raw string) (string, string, error)
-	HLSPaths(relPath string) (string, string, string)
+	HLSPaths(relPath string, format mediadomain.HLSFormat) (string, string, string)
 	MP4Paths(relPath string) (string, string, string)
+	ThumbnailPath(relPath string) string
+	// RecordOutputName notes the source of outputs stored under a hashed long name.
+	RecordOutputName(relPath string) error
+	FreeSpace(path string) (uint64, error)
+	OutputRoots() (hlsRoot, mp4Root string)
+	MoveVideo(srcRel, dstRel string) error
+	TrashVideo(relPath string) (string, error)
+	EmptyTrash(olderThan time.Duration) ([]string, error)
+}
+
+// ConversionMetrics is an application port told about every conversion that
+// stops, with its outcome (ready, failed or paused) and how long it ran.
+type ConversionMetrics interface {
+	ConversionFinished(jobType mediadomain.JobType, outcome mediadomain.JobState, took time.Duration)
 }
 
 // Converter is an application port for media transcoding and streaming operations.
 type Converter interface {
 	HLSMarkerVersion() string
 	MP4MarkerVersion() string
-	ConvertHLS(ctx context.Context, inputPath, outputDir, playlistPath string) error
-	ConvertHLSFollow(ctx context.Context, inputPath, outputDir, playlistPath string, idleTimeout time.Duration) error
-	ConvertMP4WithProgress(ctx context.Context, inputPath, outputPath string, onProgress func(int)) error
+	ProbeDuration(ctx context.Context, inputPath string) (float64, error)
+	Probe(ctx context.Context, inputPath string) (mediadomain.MediaInfo, error)
+	ConvertHLSWithProgress(ctx context.Context, inputPath, outputDir, playlistPath string, format mediadomain.HLSFormat, audioTrack int, onProgress func(int)) error
+	ConvertHLSLadder(ctx context.Context, inputPath, outputDir, playlistPath string, renditions []mediadomain.Rendition, format mediadomain.HLSFormat, audioTrack int, onProgress func(int)) error
+	ConvertHLSFollow(ctx context.Context, inputPath, outputDir, playlistPath string, format mediadomain.HLSFormat, audioTrack int, idleTimeout time.Duration) error
+	ResumeHLS(ctx context.Context, inputPath, outputDir, playlistPath string, format mediadomain.HLSFormat, audioTrack int, from mediadomain.HLSResumePoint) error
+	ConvertMP4WithProgress(ctx context.Context, inputPath, outputPath string, audioTrack int, onProgress func(int)) error
+	ConvertMP4WithSubtitles(ctx context.Context, inputPath, outputPath string, audioTrack, subIndex int, burn bool, onProgress func(int)) error
+	// Faststart reports whether an MP4 source already has its moov box up front.
+	Faststart(inputPath string) (bool, error)
+	RemuxMP4(ctx context.Context, inputPath, outputPath string, onProgress func(int)) error
+	// IngestMP4 converts a downloaded ingest source into an MP4.
+	IngestMP4(ctx context.Context, inputPath, outputPath string, maxBytes int64, maxDuration time.Duration, onProgress func(int)) error
+	ExtractThumbnail(ctx context.Context, inputPath, outputPath string, atSeconds float64) error
 	StreamMP4(ctx context.Context, inputPath string, out io.Writer, follow bool, idleTimeout time.Duration) error
 }
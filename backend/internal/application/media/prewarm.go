@@ -0,0 +1,294 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+type prewarmObservation struct {
+	size       int64
+	modifiedAt time.Time
+	firstSeen  time.Time
+}
+
+// prewarmQueue is a bounded, de-duplicated queue of relative media paths.
+type prewarmQueue struct {
+	items  chan string
+	mu     sync.Mutex
+	queued map[string]struct{}
+}
+
+func newPrewarmQueue(size int) *prewarmQueue {
+	return &prewarmQueue{
+		items:  make(chan string, size),
+		queued: make(map[string]struct{}),
+	}
+}
+
+// push enqueues relPath unless it is already pending. It reports false when the queue is full.
+func (q *prewarmQueue) push(relPath string) bool {
+	q.mu.Lock()
+	if _, ok := q.queued[relPath]; ok {
+		q.mu.Unlock()
+		return true
+	}
+	q.queued[relPath] = struct{}{}
+	q.mu.Unlock()
+
+	select {
+	case q.items <- relPath:
+		return true
+	default:
+		q.forget(relPath)
+		return false
+	}
+}
+
+func (q *prewarmQueue) forget(relPath string) {
+	q.mu.Lock()
+	delete(q.queued, relPath)
+	q.mu.Unlock()
+}
+
+// StartPrewarm periodically starts MP4 conversion for downloaded non-MP4 videos
+// that stayed unchanged for a short time window, and optionally pre-generates
+// poster thumbnails for the same stable files.
+func (s *Service) StartPrewarm(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultPrewarmInterval
+	}
+
+	s.prewarmOnce.Do(func() {
+		s.logger.Printf("MP4 prewarm enabled: interval=%s stable=%s concurrency=%d", interval, s.prewarmStableFor, s.mp4Slots.size)
+		for i := 0; i < s.mp4Slots.size; i++ {
+			go s.runMP4PrewarmWorker(ctx)
+		}
+		if s.thumbnailPrewarm {
+			s.logger.Printf("Thumbnail prewarm enabled: concurrency=%d", s.thumbnailConcurrency)
+			s.thumbWorkers.Add(s.thumbnailConcurrency)
+			for i := 0; i < s.thumbnailConcurrency; i++ {
+				go s.runThumbnailPrewarmWorker(ctx)
+			}
+		}
+		go s.runPrewarmScanner(ctx, interval)
+	})
+}
+
+func (s *Service) runPrewarmScanner(ctx context.Context, interval time.Duration) {
+	s.enqueuePrewarmCandidates()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.enqueuePrewarmCandidates()
+		case <-s.prewarmKick:
+			s.enqueuePrewarmCandidates()
+		}
+	}
+}
+
+// RescanPrewarm asks the prewarm scanner to look for new videos now instead of
+// at its next tick, e.g. after the library changed on disk. Requests made while
+// a scan is already pending are merged.
+func (s *Service) RescanPrewarm() {
+	select {
+	case s.prewarmKick <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Service) runMP4PrewarmWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case relPath := <-s.mp4Queue.items:
+			s.mp4Queue.forget(relPath)
+
+			status, err := s.startPrewarmMP4(relPath)
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					s.logger.Printf("MP4 prewarm skipped: %s: %v", relPath, err)
+				}
+				continue
+			}
+
+			// Each worker converts one video at a time, so prewarm stays
+			// within the MP4 concurrency.
+			if status.Processing {
+				s.waitForJobCompletion(ctx, jobKey(media.JobMP4, relPath))
+			}
+		}
+	}
+}
+
+// startPrewarmMP4 starts a background MP4 conversion, which yields to
+// user-started conversions.
+func (s *Service) startPrewarmMP4(relPath string) (media.JobStatus, error) {
+	rel, full, err := s.store.ResolveVideoPath(relPath)
+	if err != nil {
+		return media.JobStatus{}, err
+	}
+	return s.startMP4(rel, full, media.DefaultAudioTrack, media.NoSubtitles, true)
+}
+
+// runThumbnailPrewarmWorker extracts queued thumbnails until ctx is done. An
+// extraction in progress then finishes under thumbCtx, which Shutdown drains.
+func (s *Service) runThumbnailPrewarmWorker(ctx context.Context) {
+	defer s.thumbWorkers.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case relPath := <-s.thumbQueue.items:
+			s.thumbQueue.forget(relPath)
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err := s.prewarmThumbnail(s.thumbCtx, relPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				s.logger.Printf("Thumbnail prewarm failed: %s: %v", relPath, err)
+			}
+		}
+	}
+}
+
+func (s *Service) enqueuePrewarmCandidates() {
+	videos, err := s.store.ListVideos()
+	if err != nil {
+		s.logger.Printf("Prewarm scan failed: %v", err)
+		return
+	}
+
+	now := time.Now()
+	seen := make(map[string]struct{}, len(videos))
+
+	for _, video := range videos {
+		relPath := video.Path
+		seen[relPath] = struct{}{}
+
+		obs, stable := s.observeStability(relPath, video.Size, video.ModifiedAt, now)
+		if !stable || now.Sub(obs.firstSeen) < s.prewarmStableFor {
+			continue
+		}
+
+		if s.needsMP4Prewarm(relPath) && !s.mp4Queue.push(relPath) {
+			s.logger.Printf("MP4 prewarm queue full, skipping: %s", relPath)
+		}
+		if s.needsThumbnailPrewarm(relPath, video.ModifiedAt) && !s.thumbQueue.push(relPath) {
+			s.logger.Printf("Thumbnail prewarm queue full, skipping: %s", relPath)
+		}
+	}
+
+	s.gcPrewarmObservations(seen)
+}
+
+// PrewarmNow queues MP4 and thumbnail prewarm for relPaths right away, without
+// waiting for the scanner to see them stable. It is meant for files known to be
+// complete, such as those of a finished torrent. Missing files are skipped.
+func (s *Service) PrewarmNow(relPaths []string) {
+	for _, raw := range relPaths {
+		relPath, fullPath, err := s.store.ResolveVideoPath(raw)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(fullPath)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		if s.needsMP4Prewarm(relPath) && !s.mp4Queue.push(relPath) {
+			s.logger.Printf("MP4 prewarm queue full, skipping: %s", relPath)
+		}
+		if s.needsThumbnailPrewarm(relPath, info.ModTime()) && !s.thumbQueue.push(relPath) {
+			s.logger.Printf("Thumbnail prewarm queue full, skipping: %s", relPath)
+		}
+	}
+}
+
+func (s *Service) needsMP4Prewarm(relPath string) bool {
+	if isMP4Source(relPath) {
+		return false
+	}
+
+	outputDir, outputPath, _ := s.store.MP4Paths(relPath)
+	if s.mp4Ready(outputDir, outputPath) {
+		return false
+	}
+
+	return !s.jobs.IsRunning(jobKey(media.JobMP4, relPath))
+}
+
+func (s *Service) needsThumbnailPrewarm(relPath string, modifiedAt time.Time) bool {
+	if !s.thumbnailPrewarm {
+		return false
+	}
+
+	s.prewarmMu.Lock()
+	failedAt, failed := s.thumbFailed[relPath]
+	s.prewarmMu.Unlock()
+	if failed && failedAt.Equal(modifiedAt) {
+		return false
+	}
+
+	return !thumbnailFresh(s.store.ThumbnailPath(relPath), modifiedAt)
+}
+
+func (s *Service) observeStability(relPath string, size int64, modifiedAt time.Time, now time.Time) (prewarmObservation, bool) {
+	s.prewarmMu.Lock()
+	defer s.prewarmMu.Unlock()
+
+	prev, ok := s.prewarmObserved[relPath]
+	if !ok || prev.size != size || !prev.modifiedAt.Equal(modifiedAt) {
+		next := prewarmObservation{
+			size:       size,
+			modifiedAt: modifiedAt,
+			firstSeen:  now,
+		}
+		s.prewarmObserved[relPath] = next
+		return next, false
+	}
+
+	return prev, true
+}
+
+func (s *Service) gcPrewarmObservations(seen map[string]struct{}) {
+	s.prewarmMu.Lock()
+	defer s.prewarmMu.Unlock()
+
+	for relPath := range s.prewarmObserved {
+		if _, ok := seen[relPath]; !ok {
+			delete(s.prewarmObserved, relPath)
+			delete(s.thumbFailed, relPath)
+			s.mp4Queue.forget(relPath)
+			s.thumbQueue.forget(relPath)
+		}
+	}
+}
+
+func (s *Service) waitForJobCompletion(ctx context.Context, key string) {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		if !s.jobs.IsRunning(key) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+// maxProbeCacheEntries bounds the metadata cache; it is reset when full.
+const maxProbeCacheEntries = 1024
+
+// ErrProbeFailed reports a source ffprobe could not read.
+var ErrProbeFailed = errors.New("unable to read media metadata")
+
+// probeCache remembers MediaInfo per library path and source revision.
+type probeCache struct {
+	mu      sync.Mutex
+	entries map[string]probeEntry
+}
+
+type probeEntry struct {
+	size       int64
+	modifiedAt time.Time
+	info       media.MediaInfo
+}
+
+func newProbeCache() *probeCache {
+	return &probeCache{entries: make(map[string]probeEntry)}
+}
+
+func (c *probeCache) get(rel string, size int64, modifiedAt time.Time) (media.MediaInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[rel]
+	if !ok || entry.size != size || !entry.modifiedAt.Equal(modifiedAt) {
+		return media.MediaInfo{}, false
+	}
+	return entry.info, true
+}
+
+func (c *probeCache) put(rel string, size int64, modifiedAt time.Time, info media.MediaInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxProbeCacheEntries {
+		c.entries = make(map[string]probeEntry)
+	}
+	c.entries[rel] = probeEntry{size: size, modifiedAt: modifiedAt, info: info}
+}
+
+// Probe returns duration, resolution, codecs and audio tracks of a library video.
+// Results are cached until the file's size or modification time changes.
+func (s *Service) Probe(ctx context.Context, rawPath string) (media.MediaInfo, error) {
+	rel, full, err := s.store.ResolveVideoPath(rawPath)
+	if err != nil {
+		return media.MediaInfo{}, err
+	}
+	info, err := os.Stat(full)
+	if err != nil {
+		return media.MediaInfo{}, err
+	}
+
+	if cached, ok := s.probes.get(rel, info.Size(), info.ModTime()); ok {
+		return cached, nil
+	}
+
+	probed, err := s.converter.Probe(ctx, full)
+	if err != nil {
+		if ctx.Err() != nil {
+			return media.MediaInfo{}, ctx.Err()
+		}
+		return media.MediaInfo{}, fmt.Errorf("%w: %v", ErrProbeFailed, err)
+	}
+	s.probes.put(rel, info.Size(), info.ModTime(), probed)
+	return probed, nil
+}
+
+// checkSubtitles validates a subtitle selection against the source's cached
+// probe. Sources without subtitle streams pass, as their conversion falls back to
+// a plain MP4; probe failures are left for the conversion itself to report.
+func (s *Service) checkSubtitles(ctx context.Context, rel string, subs media.SubtitleSelection) error {
+	if !subs.Enabled() {
+		return nil
+	}
+	info, err := s.Probe(ctx, rel)
+	if err != nil || len(info.SubtitleTracks) == 0 {
+		return nil
+	}
+	if subs.Index >= len(info.SubtitleTracks) {
+		return media.ErrSubtitleTrackNotFound
+	}
+	if !subs.Burn && !info.SubtitleTracks[subs.Index].Text {
+		return media.ErrImageSubtitleMux
+	}
+	return nil
+}
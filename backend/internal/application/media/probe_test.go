@@ -0,0 +1,89 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+func TestProbe_CachesUntilSourceChanges(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	full := store.writeVideo(t, "movie.mkv", 1024)
+	converter.durations[full] = 90
+
+	for i := 0; i < 2; i++ {
+		info, err := svc.Probe(context.Background(), "movie.mkv")
+		if err != nil || info.Duration != 90 {
+			t.Fatalf("probe: %+v, %v", info, err)
+		}
+	}
+	if converter.probes != 1 {
+		t.Fatalf("expected cached probe, got %d ffprobe runs", converter.probes)
+	}
+
+	modified := time.Now().Add(time.Minute)
+	if err := os.Chtimes(full, modified, modified); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if _, err := svc.Probe(context.Background(), "movie.mkv"); err != nil {
+		t.Fatalf("probe after change: %v", err)
+	}
+	if converter.probes != 2 {
+		t.Fatalf("expected a changed source to be probed again, got %d runs", converter.probes)
+	}
+
+	store.writeVideo(t, "broken.mkv", 10)
+	if _, err := svc.Probe(context.Background(), "broken.mkv"); !errors.Is(err, ErrProbeFailed) {
+		t.Fatalf("expected ErrProbeFailed, got %v", err)
+	}
+}
+
+func TestStartMP4_ValidatesSubtitleSelection(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	full := store.writeVideo(t, "movie.mkv", 1024)
+	converter.durations[full] = 90
+	converter.subtitles = []media.SubtitleTrack{
+		{Index: 0, Codec: "subrip", Text: true},
+		{Index: 1, Codec: "hdmv_pgs_subtitle"},
+	}
+	ctx := context.Background()
+
+	if _, err := svc.StartMP4(ctx, "movie.mkv", media.DefaultAudioTrack, media.SubtitleSelection{Index: 2}, ""); !errors.Is(err, media.ErrSubtitleTrackNotFound) {
+		t.Fatalf("expected ErrSubtitleTrackNotFound, got %v", err)
+	}
+	if _, err := svc.StartMP4(ctx, "movie.mkv", media.DefaultAudioTrack, media.SubtitleSelection{Index: 1}, ""); !errors.Is(err, media.ErrImageSubtitleMux) {
+		t.Fatalf("expected ErrImageSubtitleMux, got %v", err)
+	}
+
+	status, err := svc.StartMP4(ctx, "movie.mkv", media.DefaultAudioTrack, media.SubtitleSelection{Index: 1, Burn: true}, "")
+	if err != nil {
+		t.Fatalf("burn image subtitles: %v", err)
+	}
+	if status.URL != "/api/stream-mp4/movie.mkv?burn=1&subs=1" {
+		t.Fatalf("expected selection in stream URL, got %q", status.URL)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		converter.mu.Lock()
+		subtitled := append([]media.SubtitleSelection(nil), converter.subtitled...)
+		converter.mu.Unlock()
+		if len(subtitled) == 1 {
+			if subtitled[0] != (media.SubtitleSelection{Index: 1, Burn: true}) {
+				t.Fatalf("unexpected subtitle conversion: %+v", subtitled[0])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected a subtitle conversion")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(converter.mp4Audio) != 0 {
+		t.Fatalf("expected no plain conversion, got %v", converter.mp4Audio)
+	}
+}
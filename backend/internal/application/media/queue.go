@@ -0,0 +1,178 @@
+package media
+
+import (
+	"context"
+	"sync"
+)
+
+// conversionSlots caps concurrent MP4 conversions. Waiting user-started
+// conversions are served before waiting prewarm ones, each in arrival order,
+// and a user conversion that finds every slot taken preempts one running
+// prewarm conversion.
+type conversionSlots struct {
+	mu      sync.Mutex
+	size    int
+	running map[string]*slotHolder
+	waiting []*slotWaiter
+}
+
+type slotHolder struct {
+	prewarm bool
+	// preempt stops a prewarm conversion; nil once used.
+	preempt func()
+}
+
+type slotWaiter struct {
+	key     string
+	prewarm bool
+	preempt func()
+	granted chan struct{}
+}
+
+func newConversionSlots(size int) *conversionSlots {
+	return &conversionSlots{size: size, running: make(map[string]*slotHolder)}
+}
+
+// acquire waits for a slot for the conversion under key until ctx is done.
+// preempt is called, at most once, when a prewarm conversion must give its slot
+// to a user conversion; it is ignored for user conversions.
+func (q *conversionSlots) acquire(ctx context.Context, key string, prewarm bool, preempt func()) error {
+	q.mu.Lock()
+	if !prewarm {
+		preempt = nil
+	}
+	if len(q.running) < q.size && len(q.waiting) == 0 {
+		q.running[key] = &slotHolder{prewarm: prewarm, preempt: preempt}
+		q.mu.Unlock()
+		return nil
+	}
+
+	waiter := &slotWaiter{key: key, prewarm: prewarm, preempt: preempt, granted: make(chan struct{})}
+	q.enqueueLocked(waiter)
+	var victim func()
+	if !prewarm {
+		victim = q.victimLocked()
+	}
+	q.mu.Unlock()
+
+	if victim != nil {
+		victim()
+	}
+
+	select {
+	case <-waiter.granted:
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, w := range q.waiting {
+		if w == waiter {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return ctx.Err()
+		}
+	}
+	// The slot was granted while giving up; pass it on.
+	q.releaseLocked(key)
+	return ctx.Err()
+}
+
+// release frees the slot held by key and hands it to the next waiter.
+func (q *conversionSlots) release(key string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.releaseLocked(key)
+}
+
+// position returns the 1-based place of key among waiting conversions, or 0
+// when it is not waiting.
+func (q *conversionSlots) position(key string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, w := range q.waiting {
+		if w.key == key {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// promote turns a waiting prewarm conversion into a user conversion, e.g.
+// when a user starts the same conversion. It reports whether key was waiting.
+func (q *conversionSlots) promote(key string) bool {
+	q.mu.Lock()
+	for i, w := range q.waiting {
+		if w.key != key {
+			continue
+		}
+		if w.prewarm {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			w.prewarm, w.preempt = false, nil
+			q.enqueueLocked(w)
+		}
+		victim := q.victimLocked()
+		q.mu.Unlock()
+		if victim != nil {
+			victim()
+		}
+		return true
+	}
+	q.mu.Unlock()
+	return false
+}
+
+// enqueueLocked adds w behind the waiters of its own priority.
+func (q *conversionSlots) enqueueLocked(w *slotWaiter) {
+	at := len(q.waiting)
+	if !w.prewarm {
+		for i, other := range q.waiting {
+			if other.prewarm {
+				at = i
+				break
+			}
+		}
+	}
+	q.waiting = append(q.waiting, nil)
+	copy(q.waiting[at+1:], q.waiting[at:])
+	q.waiting[at] = w
+}
+
+// victimLocked picks a running prewarm conversion to preempt when user
+// conversions wait and no slot is free. Each waiting user conversion preempts at
+// most one prewarm conversion.
+func (q *conversionSlots) victimLocked() func() {
+	users := 0
+	for _, w := range q.waiting {
+		if !w.prewarm {
+			users++
+		}
+	}
+	for _, holder := range q.running {
+		if holder.prewarm && holder.preempt == nil {
+			// Already preempted and about to release its slot.
+			users--
+		}
+	}
+	if users <= 0 || len(q.running) < q.size {
+		return nil
+	}
+	for _, holder := range q.running {
+		if holder.prewarm && holder.preempt != nil {
+			preempt := holder.preempt
+			holder.preempt = nil
+			return preempt
+		}
+	}
+	return nil
+}
+
+func (q *conversionSlots) releaseLocked(key string) {
+	delete(q.running, key)
+	for len(q.running) < q.size && len(q.waiting) > 0 {
+		next := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		q.running[next.key] = &slotHolder{prewarm: next.prewarm, preempt: next.preempt}
+		close(next.granted)
+	}
+}
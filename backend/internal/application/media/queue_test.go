@@ -0,0 +1,109 @@
+package media
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+func waitForQueuePosition(t *testing.T, svc *Service, key string, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for svc.mp4Slots.position(key) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s at queue position %d, at %d", key, want, svc.mp4Slots.position(key))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStartMP4_UserConversionJumpsAheadOfPrewarm(t *testing.T) {
+	svc, store, _ := newTestService(t, Options{})
+	store.writeVideo(t, "prewarm.mkv", 1024)
+	store.writeVideo(t, "user.mkv", 1024)
+	_ = svc.mp4Slots.acquire(context.Background(), "held", false, nil)
+	defer svc.mp4Slots.release("held")
+
+	if _, err := svc.startPrewarmMP4("prewarm.mkv"); err != nil {
+		t.Fatalf("prewarm: %v", err)
+	}
+	prewarmKey := jobKey(media.JobMP4, "prewarm.mkv")
+	waitForQueuePosition(t, svc, prewarmKey, 1)
+
+	if _, err := svc.StartMP4(context.Background(), "user.mkv", media.DefaultAudioTrack, media.NoSubtitles, ""); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	waitForQueuePosition(t, svc, jobKey(media.JobMP4, "user.mkv"), 1)
+	waitForQueuePosition(t, svc, prewarmKey, 2)
+
+	status, err := svc.MP4Status("prewarm.mkv", media.DefaultAudioTrack, media.NoSubtitles)
+	if err != nil || status.QueuePosition != 2 {
+		t.Fatalf("expected prewarm reported second in queue, got %+v (%v)", status, err)
+	}
+
+	// Starting the queued prewarm conversion as a user moves it up as well.
+	if _, err := svc.StartMP4(context.Background(), "prewarm.mkv", media.DefaultAudioTrack, media.NoSubtitles, ""); err != nil {
+		t.Fatalf("start prewarmed: %v", err)
+	}
+	waitForQueuePosition(t, svc, prewarmKey, 2)
+	if pos := svc.mp4Slots.position(jobKey(media.JobMP4, "user.mkv")); pos != 1 {
+		t.Fatalf("expected earlier user conversion to stay first, at %d", pos)
+	}
+}
+
+func TestConversionSlots_UserPreemptsRunningPrewarm(t *testing.T) {
+	slots := newConversionSlots(1)
+	preempted := make(chan struct{})
+	if err := slots.acquire(context.Background(), "prewarm", true, func() {
+		close(preempted)
+		slots.release("prewarm")
+	}); err != nil {
+		t.Fatalf("prewarm acquire: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := slots.acquire(ctx, "user", false, nil); err != nil {
+		t.Fatalf("expected the user conversion to get the prewarm slot: %v", err)
+	}
+	select {
+	case <-preempted:
+	default:
+		t.Fatalf("expected the prewarm conversion to be preempted")
+	}
+
+	// A second user conversion waits rather than preempting another user.
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelWait()
+	if err := slots.acquire(waitCtx, "user2", false, nil); err == nil {
+		t.Fatalf("expected the second user conversion to wait")
+	}
+	if pos := slots.position("user2"); pos != 0 {
+		t.Fatalf("expected an abandoned wait to leave the queue, at %d", pos)
+	}
+}
+
+func TestStartMP4_ReportsQueuedUntilSlotIsFree(t *testing.T) {
+	svc, store, _ := newTestService(t, Options{})
+	store.writeVideo(t, "movie.mkv", 1024)
+	_ = svc.mp4Slots.acquire(context.Background(), "held", false, nil)
+
+	started, err := svc.StartMP4(context.Background(), "movie.mkv", media.DefaultAudioTrack, media.NoSubtitles, "")
+	if err != nil || started.State != media.StateQueued || !started.Processing {
+		t.Fatalf("expected the conversion accepted as queued, got %+v (%v)", started, err)
+	}
+	key := jobKey(media.JobMP4, "movie.mkv")
+	waitForQueuePosition(t, svc, key, 1)
+	status, err := svc.MP4Status("movie.mkv", media.DefaultAudioTrack, media.NoSubtitles)
+	if err != nil || status.State != media.StateQueued || status.QueuePosition != 1 {
+		t.Fatalf("expected queued status at position 1, got %+v (%v)", status, err)
+	}
+	if !svc.jobs.IsRunning(key) || len(svc.ActiveJobs()) != 1 {
+		t.Fatalf("expected the queued conversion to count as running")
+	}
+
+	svc.mp4Slots.release("held")
+	waitForJobState(t, svc, key, media.StateReady)
+}
@@ -0,0 +1,98 @@
+package media
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"evd/internal/domain/media"
+)
+
+// CleanInterruptedOutputs removes what conversions cut short by a restart left
+// behind: temporary MP4 files, MP4 outputs below the readiness floor, MP4
+// markers of folders without outputs, and HLS outputs without a playable
+// playlist. Paused HLS outputs are kept for resuming. Outputs of running
+// conversions are skipped, but it is meant to run at startup before any start.
+func (s *Service) CleanInterruptedOutputs() {
+	hlsRoot, mp4Root := s.store.OutputRoots()
+	active := s.activeOutputs()
+
+	_ = filepath.WalkDir(mp4Root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(entry.Name(), ".mp4") {
+			return nil
+		}
+		if strings.HasSuffix(entry.Name(), ".tmp.mp4") {
+			final := strings.TrimSuffix(path, ".tmp.mp4")
+			if !active[filepath.Clean(final)] && os.Remove(path) == nil {
+				s.logger.Printf("Removed interrupted MP4 output: %s", path)
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err == nil && info.Size() < s.mp4ReadyMinBytes && !active[filepath.Clean(path)] && os.Remove(path) == nil {
+			s.forgetVerified(path)
+			s.logger.Printf("Removed incomplete MP4 output: %s (%d bytes)", path, info.Size())
+		}
+		return nil
+	})
+	_ = filepath.WalkDir(mp4Root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || entry.Name() != mp4MarkerFile {
+			return nil
+		}
+		if !dirHasMP4(filepath.Dir(path)) && os.Remove(path) == nil {
+			s.logger.Printf("Removed stale MP4 marker: %s", path)
+		}
+		return nil
+	})
+
+	_ = filepath.WalkDir(hlsRoot, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		if _, err := os.Stat(filepath.Join(path, hlsMarkerFile)); err != nil {
+			return nil
+		}
+		// Outputs of an older converter version are rebuilt on their next start.
+		version := s.converter.HLSMarkerVersion()
+		if active[filepath.Clean(path)] || hlsPaused(path) || !markerMatches(path, hlsMarkerFile, version) {
+			return filepath.SkipDir
+		}
+
+		if ready, _ := hlsReady(path, filepath.Join(path, "index.m3u8"), version, hlsOutputFormat(path)); !ready {
+			if os.RemoveAll(path) == nil {
+				s.logger.Printf("Removed interrupted HLS output: %s", path)
+			}
+		}
+		return filepath.SkipDir
+	})
+}
+
+// hlsOutputFormat tells fMP4 outputs, including adaptive ones whose
+// initialization segments live in variant folders, from MPEG-TS ones.
+func hlsOutputFormat(outputDir string) media.HLSFormat {
+	format := media.HLSFormatTS
+	_ = filepath.WalkDir(outputDir, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() && (entry.Name() == fmp4InitFile || strings.HasSuffix(entry.Name(), ".m4s")) {
+			format = media.HLSFormatFMP4
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return format
+}
+
+// dirHasMP4 reports whether dir directly holds a finished MP4 output.
+func dirHasMP4(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasSuffix(name, ".mp4") && !strings.HasSuffix(name, ".tmp.mp4") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package media
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"evd/internal/domain/media"
+)
+
+func TestCleanInterruptedOutputs_RemovesPartialMP4(t *testing.T) {
+	svc, store, _ := newTestService(t, Options{MP4ReadyMinBytes: 1024})
+	doneDir, donePath := writeMP4Output(t, store, "done.mkv", 2048)
+	cutDir, cutPath := writeMP4Output(t, store, "shows/cut.mkv", 100)
+	tmpPath := filepath.Join(doneDir, "other.mp4.tmp.mp4")
+	if err := os.WriteFile(tmpPath, []byte("partial"), 0o644); err != nil {
+		t.Fatalf("write temp: %v", err)
+	}
+
+	svc.CleanInterruptedOutputs()
+
+	if _, err := os.Stat(donePath); err != nil {
+		t.Fatalf("expected finished output to stay: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(doneDir, mp4MarkerFile)); err != nil {
+		t.Fatalf("expected marker of finished output to stay: %v", err)
+	}
+	for _, gone := range []string{tmpPath, cutPath, filepath.Join(cutDir, mp4MarkerFile)} {
+		if _, err := os.Stat(gone); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be removed, got %v", gone, err)
+		}
+	}
+}
+
+func TestCleanInterruptedOutputs_RemovesHLSWithoutPlaylist(t *testing.T) {
+	svc, store, _ := newTestService(t, Options{})
+	readyDir, _ := writeHLSOutput(t, store, "ready.mkv", 3)
+	brokenDir, brokenPlaylist := writeHLSOutput(t, store, "broken.mkv", 0)
+	if err := os.Remove(brokenPlaylist); err != nil {
+		t.Fatalf("remove playlist: %v", err)
+	}
+	pausedDir, _ := writeHLSOutput(t, store, "paused.mkv", 2)
+	if err := writeHLSPause(pausedDir, media.HLSResumePoint{Segments: 2}); err != nil {
+		t.Fatalf("write pause file: %v", err)
+	}
+
+	svc.CleanInterruptedOutputs()
+
+	if _, err := os.Stat(brokenDir); !os.IsNotExist(err) {
+		t.Fatalf("expected output without playlist to be removed, got %v", err)
+	}
+	for _, kept := range []string{readyDir, pausedDir} {
+		if _, err := os.Stat(kept); err != nil {
+			t.Fatalf("expected %s to stay: %v", kept, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package media
+
+import (
+	"context"
+	"os"
+
+	"evd/internal/domain/media"
+)
+
+// RedoHLS discards the HLS output of a media file and audio track, whether
+// ready, failed or paused, and converts it again. The other segment format is
+// kept. It refuses with ErrJobRunning while that conversion runs. An empty
+// format selects the configured default.
+func (s *Service) RedoHLS(ctx context.Context, rawPath string, format media.HLSFormat, audio int, idempotencyKey string) (media.JobStatus, error) {
+	rel, full, err := s.store.ResolveVideoPath(rawPath)
+	if err != nil {
+		return media.JobStatus{}, err
+	}
+
+	format = s.resolveHLSFormat(format)
+	variant := media.AudioVariantPath(rel, audio)
+	key := jobKey(hlsJobType(format), variant)
+	return s.idempotent(ctx, idempotencyKey, key, func() (media.JobStatus, error) {
+		outputDir, _, _ := s.store.HLSPaths(variant, format)
+		if err := s.jobs.ClearIdle([]string{key}, func() error { return os.RemoveAll(outputDir) }); err != nil {
+			return media.JobStatus{}, err
+		}
+		s.logger.Printf("HLS output discarded for a redo: %s (%s)", variant, format)
+		return s.startHLS(rel, full, false, format, audio)
+	})
+}
+
+// RedoMP4 discards the MP4 output of a source, audio track and subtitle
+// selection and converts it again. Like StartMP4 it refuses faststart MP4
+// sources, and it refuses with ErrJobRunning while that conversion is queued
+// or running.
+func (s *Service) RedoMP4(ctx context.Context, rawPath string, audio int, subs media.SubtitleSelection, idempotencyKey string) (media.JobStatus, error) {
+	rel, full, err := s.store.ResolveVideoPath(rawPath)
+	if err != nil {
+		return media.JobStatus{}, err
+	}
+	if err := s.checkMP4Request(ctx, rel, full, subs); err != nil {
+		return media.JobStatus{}, err
+	}
+
+	variant := mp4Variant(rel, audio, subs)
+	return s.idempotent(ctx, idempotencyKey, jobKey(media.JobMP4, variant), func() (media.JobStatus, error) {
+		if err := s.removeArtifacts(nil, []string{variant}); err != nil {
+			return media.JobStatus{}, err
+		}
+		s.logger.Printf("MP4 output discarded for a redo: %s", variant)
+		return s.startMP4(rel, full, audio, subs, false)
+	})
+}
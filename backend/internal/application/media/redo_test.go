@@ -0,0 +1,73 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"evd/internal/domain/media"
+)
+
+func TestRedoMP4_ReconvertsReadyOutput(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	store.writeVideo(t, "movie.mkv", 1024)
+	_, outputPath := writeMP4Output(t, store, "movie.mkv", 64*1024)
+	converter.durations[outputPath] = 60
+
+	status, err := svc.StartMP4(context.Background(), "movie.mkv", media.DefaultAudioTrack, media.NoSubtitles, "")
+	if err != nil || status.State != media.StateReady {
+		t.Fatalf("expected ready output to be reused, got %+v, %v", status, err)
+	}
+
+	status, err = svc.RedoMP4(context.Background(), "movie.mkv", media.DefaultAudioTrack, media.NoSubtitles, "")
+	if err != nil {
+		t.Fatalf("redo: %v", err)
+	}
+	if status.Ready {
+		t.Fatalf("expected redo to start a conversion, got %+v", status)
+	}
+	waitForJobState(t, svc, jobKey(media.JobMP4, "movie.mkv"), media.StateReady)
+
+	converter.mu.Lock()
+	converted := len(converter.mp4Audio)
+	converter.mu.Unlock()
+	if converted != 1 {
+		t.Fatalf("expected one conversion after redo, got %d", converted)
+	}
+}
+
+func TestRedoHLS_RefusedWhileConverting(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	full := store.writeVideo(t, "movie.mkv", 1024)
+	converter.durations[full] = 60
+
+	converter.hlsRelease = make(chan struct{})
+	if _, err := svc.StartHLS(context.Background(), "movie.mkv", false, "", media.DefaultAudioTrack, ""); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if _, err := svc.RedoHLS(context.Background(), "movie.mkv", "", media.DefaultAudioTrack, ""); !errors.Is(err, ErrJobRunning) {
+		t.Fatalf("expected running conversion to block a redo, got %v", err)
+	}
+	hlsDir, _, _ := store.HLSPaths("movie.mkv", media.HLSFormatTS)
+	if _, err := os.Stat(hlsDir); err != nil {
+		t.Fatalf("expected output kept while converting: %v", err)
+	}
+	close(converter.hlsRelease)
+	waitForJobState(t, svc, jobKey(media.JobHLS, "movie.mkv"), media.StateReady)
+
+	converter.mu.Lock()
+	converter.hlsRelease = nil
+	converter.mu.Unlock()
+	if _, err := svc.RedoHLS(context.Background(), "movie.mkv", "", media.DefaultAudioTrack, ""); err != nil {
+		t.Fatalf("redo: %v", err)
+	}
+	waitForJobState(t, svc, jobKey(media.JobHLS, "movie.mkv"), media.StateReady)
+
+	converter.mu.Lock()
+	calls := converter.hlsCalls
+	converter.mu.Unlock()
+	if calls != 2 {
+		t.Fatalf("expected the redo to convert again, got %d conversions", calls)
+	}
+}
@@ -0,0 +1,17 @@
+package media
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// ErrAlreadyFaststart is returned when an MP4 conversion is requested for an
+// MP4 source that players can already stream and seek directly.
+var ErrAlreadyFaststart = errors.New("MP4 source is already faststart")
+
+// isMP4Source reports whether rel is an MP4 file. Those stream directly unless
+// their moov box sits at the end, in which case the MP4 pipeline remuxes them.
+func isMP4Source(rel string) bool {
+	return strings.ToLower(filepath.Ext(rel)) == ".mp4"
+}
@@ -0,0 +1,35 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"evd/internal/domain/media"
+)
+
+func TestStartMP4_RemuxesOnlyNonFaststartMP4(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	fast := store.writeVideo(t, "fast.mp4", 1024)
+	slow := store.writeVideo(t, "slow.mp4", 1024)
+	converter.faststart = map[string]bool{fast: true}
+	ctx := context.Background()
+
+	if _, err := svc.StartMP4(ctx, "fast.mp4", media.DefaultAudioTrack, media.NoSubtitles, ""); !errors.Is(err, ErrAlreadyFaststart) {
+		t.Fatalf("expected ErrAlreadyFaststart, got %v", err)
+	}
+
+	if _, err := svc.StartMP4(ctx, "slow.mp4", media.DefaultAudioTrack, media.NoSubtitles, ""); err != nil {
+		t.Fatalf("start remux: %v", err)
+	}
+	waitForJobState(t, svc, jobKey(media.JobMP4, "slow.mp4"), media.StateReady)
+
+	converter.mu.Lock()
+	defer converter.mu.Unlock()
+	if len(converter.remuxed) != 1 || converter.remuxed[0] != slow {
+		t.Fatalf("expected %s to be remuxed, got %v", slow, converter.remuxed)
+	}
+	if len(converter.mp4Audio) != 0 {
+		t.Fatalf("expected no conversion for a remux, got %v", converter.mp4Audio)
+	}
+}
@@ -5,26 +5,36 @@ import (
 	"errors"
 	"io"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"evd/internal/domain/media"
 )
 
-const mp4ReadyMinBytes = 512 * 1024
+const (
+	defaultMP4ReadyMinBytes = 32 * 1024
+	readinessProbeTimeout   = 15 * time.Second
+)
+
 const (
 	hlsMarkerFile = ".transcoded"
 	mp4MarkerFile = ".mp4transcoded"
+	fmp4InitFile  = "init.mp4"
 )
 
 const (
-	defaultMP4Concurrency   = 1
-	defaultPrewarmInterval  = 45 * time.Second
-	defaultPrewarmStableFor = 40 * time.Second
-	prewarmQueueSize        = 512
+	defaultMP4Concurrency       = 1
+	defaultThumbnailConcurrency = 1
+	defaultPrewarmInterval      = 45 * time.Second
+	defaultPrewarmStableFor     = 40 * time.Second
+	prewarmQueueSize            = 512
 )
 
 // Service handles media-related use cases.
@@ -34,241 +44,274 @@ type Service struct {
 	logger    *log.Logger
 	jobs      *jobRegistry
 
-	mp4Slots chan struct{}
+	idempotency *idempotencyCache
+	probes      *probeCache
+
+	mp4Slots *conversionSlots
+	// liveStreams holds a token per running live StreamMP4 ffmpeg; nil
+	// leaves them unlimited.
+	liveStreams chan struct{}
+
+	mp4ReadyMinBytes int64
+	verifiedMu       sync.Mutex
+	verifiedOutputs  map[string]verifiedOutput
+
+	thumbnailPrewarm     bool
+	thumbnailConcurrency int
+
+	ingest IngestOptions
+
+	hlsFormat      media.HLSFormat
+	hlsTrimEnabled bool
+	hlsRenditions  []media.Rendition
+
+	minFreeBytes int64
+
+	maxTranscodeBytes int64
+	evictOnce         sync.Once
+	servedMu          sync.Mutex
+	lastServed        map[string]time.Time
+
+	trashRetention time.Duration
+	trashEmptied   func(names []string)
+	trashOnce      sync.Once
+
+	prewarmStableFor time.Duration
+	prewarmOnce      sync.Once
+	prewarmKick      chan struct{}
+	mp4Queue         *prewarmQueue
+	thumbQueue       *prewarmQueue
+	prewarmObserved  map[string]prewarmObservation
+	thumbFailed      map[string]time.Time
+	thumbBusy        map[string]chan struct{}
+	prewarmMu        sync.Mutex
+	// Thumbnail prewarm extractions outlive the worker context so Shutdown can
+	// drain them; stopThumbs cancels thumbCtx once the drain deadline passes.
+	thumbWorkers sync.WaitGroup
+	thumbCtx     context.Context
+	stopThumbs   context.CancelFunc
+}
 
-	prewarmOnce     sync.Once
-	prewarmQueue    chan string
-	prewarmQueued   map[string]struct{}
-	prewarmObserved map[string]prewarmObservation
-	prewarmMu       sync.Mutex
+// Options tunes media service behavior. Zero values fall back to defaults.
+type Options struct {
+	// MP4ReadyMinBytes is the cheap size floor an MP4 output must reach
+	// before its duration is probed to confirm readiness.
+	MP4ReadyMinBytes int64
+
+	// MP4Concurrency caps MP4 conversions running at once, both prewarm and
+	// user-started; further ones wait for a slot.
+	MP4Concurrency int
+	// PrewarmStableFor is how long a video must stay unchanged before prewarm
+	// converts it or generates its poster.
+	PrewarmStableFor time.Duration
+
+	// ThumbnailPrewarm enables background poster generation for stable files.
+	ThumbnailPrewarm bool
+	// ThumbnailConcurrency caps parallel ffmpeg thumbnail extractions.
+	ThumbnailConcurrency int
+
+	// Ingest configures fetching remote URLs into the library.
+	Ingest IngestOptions
+
+	// HLSFormat is the segment format used when a request does not ask for one.
+	// Defaults to MPEG-TS.
+	HLSFormat media.HLSFormat
+
+	// HLSTrimEnabled allows deleting already-watched segments from finished
+	// HLS outputs via TrimHLS. Off by default.
+	HLSTrimEnabled bool
+
+	// HLSRenditions enables adaptive HLS: non-follow conversions produce one
+	// variant per rendition behind a master playlist. Empty keeps the single
+	// rendition output.
+	HLSRenditions []media.Rendition
+
+	// MinFreeBytes is kept free on the output filesystem on top of the
+	// estimated output size; conversions that would cut into it are refused
+	// with ErrInsufficientSpace.
+	MinFreeBytes int64
+
+	// MaxTranscodeBytes caps the combined size of HLS and MP4 outputs; the
+	// eviction sweeper removes least-recently-used outputs above it. Zero
+	// disables eviction.
+	MaxTranscodeBytes int64
+
+	// TrashRetention is how long deleted videos stay restorable before the
+	// trash sweeper removes them for good. Zero keeps them until emptied.
+	TrashRetention time.Duration
+
+	// TrashEmptied, when set, receives the trash names the sweeper deleted.
+	TrashEmptied func(names []string)
+
+	// Metrics, when set, records every conversion that stops.
+	Metrics ConversionMetrics
+
+	// LiveStreamConcurrency caps live StreamMP4 ffmpeg processes, one per
+	// viewer. Streams over the cap fail with ErrLiveStreamsFull instead of
+	// waiting. Zero leaves them unlimited. MP4 conversions have their own
+	// slots (MP4Concurrency).
+	LiveStreamConcurrency int
 }
 
 // NewService creates a media use-case service with injected ports.
-func NewService(store VideoRepository, converter Converter, logger *log.Logger) *Service {
-	return &Service{
-		store:     store,
-		converter: converter,
-		logger:    logger,
-		jobs:      newJobRegistry(),
-		mp4Slots:  make(chan struct{}, defaultMP4Concurrency),
-
-		prewarmQueue:    make(chan string, prewarmQueueSize),
-		prewarmQueued:   make(map[string]struct{}),
-		prewarmObserved: make(map[string]prewarmObservation),
+func NewService(store VideoRepository, converter Converter, logger *log.Logger, opts Options) *Service {
+	if opts.MP4ReadyMinBytes <= 0 {
+		opts.MP4ReadyMinBytes = defaultMP4ReadyMinBytes
 	}
-}
-
-type prewarmObservation struct {
-	size       int64
-	modifiedAt time.Time
-	firstSeen  time.Time
-}
-
-// ListVideos returns discoverable media files from the library.
-func (s *Service) ListVideos() ([]media.Video, error) {
-	return s.store.ListVideos()
-}
-
-// StartMP4Prewarm periodically starts MP4 conversion for downloaded non-MP4 videos
-// that stayed unchanged for a short time window.
-func (s *Service) StartMP4Prewarm(ctx context.Context, interval time.Duration) {
-	if interval <= 0 {
-		interval = defaultPrewarmInterval
+	if opts.MP4Concurrency <= 0 {
+		opts.MP4Concurrency = defaultMP4Concurrency
 	}
-
-	s.prewarmOnce.Do(func() {
-		s.logger.Printf("MP4 prewarm enabled: interval=%s", interval)
-		go s.runMP4PrewarmWorker(ctx)
-		go s.runMP4PrewarmScanner(ctx, interval)
-	})
-}
-
-func (s *Service) runMP4PrewarmScanner(ctx context.Context, interval time.Duration) {
-	s.enqueuePrewarmCandidates()
-
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			s.enqueuePrewarmCandidates()
-		}
+	if opts.PrewarmStableFor <= 0 {
+		opts.PrewarmStableFor = defaultPrewarmStableFor
 	}
-}
-
-func (s *Service) runMP4PrewarmWorker(ctx context.Context) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case relPath := <-s.prewarmQueue:
-			s.dequeuePrewarm(relPath)
-
-			status, err := s.StartMP4(context.Background(), relPath)
-			if err != nil {
-				if !errors.Is(err, os.ErrNotExist) {
-					s.logger.Printf("MP4 prewarm skipped: %s: %v", relPath, err)
-				}
-				continue
-			}
-
-			// Keep prewarm conversions sequential to avoid CPU spikes.
-			if status.State == media.StateProcessing {
-				s.waitForJobCompletion(ctx, jobKey(media.JobMP4, relPath))
-			}
-		}
+	if opts.ThumbnailConcurrency <= 0 {
+		opts.ThumbnailConcurrency = defaultThumbnailConcurrency
 	}
-}
-
-func (s *Service) enqueuePrewarmCandidates() {
-	videos, err := s.store.ListVideos()
-	if err != nil {
-		s.logger.Printf("MP4 prewarm scan failed: %v", err)
-		return
+	if opts.Ingest.MaxBytes <= 0 {
+		opts.Ingest.MaxBytes = defaultIngestMaxBytes
+	}
+	if opts.Ingest.MaxDuration <= 0 {
+		opts.Ingest.MaxDuration = defaultIngestMaxDuration
+	}
+	if opts.HLSFormat == "" {
+		opts.HLSFormat = media.HLSFormatTS
 	}
 
-	now := time.Now()
-	seen := make(map[string]struct{}, len(videos))
-
-	for _, video := range videos {
-		relPath := video.Path
-		seen[relPath] = struct{}{}
-
-		ext := strings.ToLower(filepath.Ext(relPath))
-		if ext == ".mp4" {
-			continue
-		}
-
-		outputDir, outputPath, _ := s.store.MP4Paths(relPath)
-		if mp4Ready(outputDir, outputPath, s.converter.MP4MarkerVersion()) {
-			continue
-		}
-
-		mp4JobKey := jobKey(media.JobMP4, relPath)
-		if s.jobs.IsRunning(mp4JobKey) {
-			continue
-		}
+	svc := &Service{
+		store:     store,
+		converter: converter,
+		logger:    logger,
+		jobs:      newJobRegistry(opts.Metrics),
+		mp4Slots:  newConversionSlots(opts.MP4Concurrency),
 
-		obs, stable := s.observeStability(relPath, video.Size, video.ModifiedAt, now)
-		if !stable || now.Sub(obs.firstSeen) < defaultPrewarmStableFor {
-			continue
-		}
+		liveStreams: newLiveStreamSlots(opts.LiveStreamConcurrency),
 
-		s.enqueuePrewarm(relPath)
-	}
+		idempotency: newIdempotencyCache(),
+		probes:      newProbeCache(),
 
-	s.gcPrewarmObservations(seen)
-}
+		mp4ReadyMinBytes: opts.MP4ReadyMinBytes,
+		verifiedOutputs:  make(map[string]verifiedOutput),
 
-func (s *Service) observeStability(relPath string, size int64, modifiedAt time.Time, now time.Time) (prewarmObservation, bool) {
-	s.prewarmMu.Lock()
-	defer s.prewarmMu.Unlock()
+		thumbnailPrewarm:     opts.ThumbnailPrewarm,
+		thumbnailConcurrency: opts.ThumbnailConcurrency,
 
-	prev, ok := s.prewarmObserved[relPath]
-	if !ok || prev.size != size || !prev.modifiedAt.Equal(modifiedAt) {
-		next := prewarmObservation{
-			size:       size,
-			modifiedAt: modifiedAt,
-			firstSeen:  now,
-		}
-		s.prewarmObserved[relPath] = next
-		return next, false
-	}
+		ingest: opts.Ingest,
 
-	return prev, true
-}
+		hlsFormat:      opts.HLSFormat,
+		hlsTrimEnabled: opts.HLSTrimEnabled,
+		hlsRenditions:  opts.HLSRenditions,
 
-func (s *Service) gcPrewarmObservations(seen map[string]struct{}) {
-	s.prewarmMu.Lock()
-	defer s.prewarmMu.Unlock()
+		minFreeBytes: opts.MinFreeBytes,
 
-	for relPath := range s.prewarmObserved {
-		if _, ok := seen[relPath]; !ok {
-			delete(s.prewarmObserved, relPath)
-			delete(s.prewarmQueued, relPath)
-		}
-	}
-}
+		maxTranscodeBytes: opts.MaxTranscodeBytes,
+		lastServed:        make(map[string]time.Time),
 
-func (s *Service) enqueuePrewarm(relPath string) {
-	s.prewarmMu.Lock()
-	if _, ok := s.prewarmQueued[relPath]; ok {
-		s.prewarmMu.Unlock()
-		return
-	}
-	s.prewarmQueued[relPath] = struct{}{}
-	s.prewarmMu.Unlock()
+		trashRetention: opts.TrashRetention,
+		trashEmptied:   opts.TrashEmptied,
 
-	select {
-	case s.prewarmQueue <- relPath:
-	default:
-		s.prewarmMu.Lock()
-		delete(s.prewarmQueued, relPath)
-		s.prewarmMu.Unlock()
-		s.logger.Printf("MP4 prewarm queue full, skipping: %s", relPath)
+		prewarmStableFor: opts.PrewarmStableFor,
+		prewarmKick:      make(chan struct{}, 1),
+		mp4Queue:         newPrewarmQueue(prewarmQueueSize),
+		thumbQueue:       newPrewarmQueue(prewarmQueueSize),
+		prewarmObserved:  make(map[string]prewarmObservation),
+		thumbFailed:      make(map[string]time.Time),
+		thumbBusy:        make(map[string]chan struct{}),
 	}
+	svc.thumbCtx, svc.stopThumbs = context.WithCancel(context.Background())
+	return svc
 }
 
-func (s *Service) dequeuePrewarm(relPath string) {
-	s.prewarmMu.Lock()
-	delete(s.prewarmQueued, relPath)
-	s.prewarmMu.Unlock()
+// verifiedOutput caches a successful duration probe for an output file revision.
+type verifiedOutput struct {
+	size       int64
+	modifiedAt time.Time
 }
 
-func (s *Service) waitForJobCompletion(ctx context.Context, key string) {
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
+// maxVerifiedOutputs bounds the probe cache. Removals drop their entries, but
+// outputs deleted behind the service's back would otherwise stay forever.
+const maxVerifiedOutputs = 4096
 
-	for {
-		state, _, _ := s.jobs.Status(key)
-		if state != media.StateProcessing {
-			return
-		}
-
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-		}
-	}
+// ListVideos returns discoverable media files from the library.
+func (s *Service) ListVideos() ([]media.Video, error) {
+	return s.store.ListVideos()
 }
 
 // StartHLS ensures HLS conversion is scheduled for requested media file.
-func (s *Service) StartHLS(ctx context.Context, rawPath string, follow bool) (media.JobStatus, error) {
+// An empty format selects the configured default. audio selects the audio track,
+// or media.DefaultAudioTrack; each explicit track converts into its own rendition.
+// A non-empty idempotencyKey makes retries within a short window share one outcome.
+func (s *Service) StartHLS(ctx context.Context, rawPath string, follow bool, format media.HLSFormat, audio int, idempotencyKey string) (media.JobStatus, error) {
 	rel, full, err := s.store.ResolveVideoPath(rawPath)
 	if err != nil {
 		return media.JobStatus{}, err
 	}
 
-	outputDir, playlist, url := s.store.HLSPaths(rel)
-	ready, segments := hlsReady(outputDir, playlist, s.converter.HLSMarkerVersion())
+	format = s.resolveHLSFormat(format)
+	return s.idempotent(ctx, idempotencyKey, jobKey(hlsJobType(format), media.AudioVariantPath(rel, audio)), func() (media.JobStatus, error) {
+		return s.startHLS(rel, full, follow, format, audio)
+	})
+}
+
+func (s *Service) startHLS(rel, full string, follow bool, format media.HLSFormat, audio int) (media.JobStatus, error) {
+	variant := media.AudioVariantPath(rel, audio)
+	outputDir, playlist, url := s.store.HLSPaths(variant, format)
+	ready, segments := hlsReady(outputDir, playlist, s.converter.HLSMarkerVersion(), format)
 
-	jobKey := jobKey(media.JobHLS, rel)
+	jobKey := jobKey(hlsJobType(format), variant)
+	if s.jobs.IsHeld(jobKey) {
+		return media.JobStatus{}, ErrJobPausing
+	}
 	if s.jobs.IsRunning(jobKey) {
-		return media.JobStatus{State: media.StateProcessing, Processing: true, URL: url, Segments: segments, Ready: ready}, nil
+		_, _, progress := s.jobs.Status(jobKey)
+		return media.JobStatus{State: media.StateProcessing, Processing: true, URL: url, Segments: segments, Ready: ready, Progress: progress}, nil
 	}
 
 	if ready {
-		return media.JobStatus{State: media.StateReady, Ready: true, URL: url, Segments: segments}, nil
+		return media.JobStatus{State: media.StateReady, Ready: true, URL: url, Segments: segments, Progress: 100}, nil
+	}
+
+	if err := s.checkFreeSpace(full, outputDir); err != nil {
+		return media.JobStatus{}, err
+	}
+	s.recordOutputName(variant)
+
+	if point, paused := readHLSPause(outputDir); paused {
+		return s.resumeHLS(rel, full, format, audio, point)
 	}
 
 	if err := s.prepareHLSOutput(outputDir); err != nil {
 		return media.JobStatus{}, err
 	}
 
-	s.jobs.Start(jobKey)
-	s.logger.Printf("HLS conversion started: %s", rel)
-	go func() {
-		var err error
-		if follow {
-			err = s.converter.ConvertHLSFollow(context.Background(), full, outputDir, playlist, 2*time.Minute)
-		} else {
-			err = s.converter.ConvertHLS(context.Background(), full, outputDir, playlist)
+	s.logger.Printf("HLS conversion started: %s (%s)", variant, format)
+	s.runHLS(variant, jobKey, outputDir, func(ctx context.Context) error {
+		onProgress := func(progress int) {
+			s.jobs.Progress(jobKey, progress)
 		}
-		if err != nil {
+		switch {
+		case follow:
+			return s.converter.ConvertHLSFollow(ctx, full, outputDir, playlist, format, audio, 2*time.Minute)
+		case len(s.hlsRenditions) > 0:
+			return s.converter.ConvertHLSLadder(ctx, full, outputDir, playlist, s.hlsRenditions, format, audio, onProgress)
+		default:
+			return s.converter.ConvertHLSWithProgress(ctx, full, outputDir, playlist, format, audio, onProgress)
+		}
+	})
+
+	return media.JobStatus{State: media.StateProcessing, Processing: true, URL: url, Segments: segments}, nil
+}
+
+// runHLS runs convert as the job key in the background. A conversion stopped
+// through its context keeps its output so it can be resumed.
+func (s *Service) runHLS(rel, jobKey, outputDir string, convert func(ctx context.Context) error) {
+	ctx := s.jobs.Start(jobKey)
+	go func() {
+		if err := convert(ctx); err != nil {
+			if ctx.Err() != nil || errors.Is(err, media.ErrConversionStopped) {
+				s.logger.Printf("HLS conversion stopped: %s", rel)
+				s.jobs.Stopped(jobKey)
+				return
+			}
 			s.logger.Printf("HLS conversion failed: %s: %v", rel, err)
 			_ = os.RemoveAll(outputDir)
 			s.jobs.Fail(jobKey, err)
@@ -277,113 +320,261 @@ func (s *Service) StartHLS(ctx context.Context, rawPath string, follow bool) (me
 		s.logger.Printf("HLS conversion finished: %s", rel)
 		s.jobs.Ready(jobKey)
 	}()
-
-	return media.JobStatus{State: media.StateProcessing, Processing: true, URL: url, Segments: segments}, nil
 }
 
-// HLSStatus returns current HLS conversion state for a media file.
-func (s *Service) HLSStatus(rawPath string) (media.JobStatus, error) {
+// HLSStatus returns current HLS conversion state for a media file and audio track.
+// An empty format selects the configured default.
+func (s *Service) HLSStatus(rawPath string, format media.HLSFormat, audio int) (media.JobStatus, error) {
 	rel, _, err := s.store.ResolveVideoPath(rawPath)
 	if err != nil {
 		return media.JobStatus{}, err
 	}
 
-	outputDir, playlist, url := s.store.HLSPaths(rel)
-	ready, segments := hlsReady(outputDir, playlist, s.converter.HLSMarkerVersion())
+	format = s.resolveHLSFormat(format)
+	key := jobKey(hlsJobType(format), media.AudioVariantPath(rel, audio))
+	return s.withTiming(key, s.hlsStatus(rel, format, audio, key)), nil
+}
+
+func (s *Service) hlsStatus(rel string, format media.HLSFormat, audio int, jobKey string) media.JobStatus {
+	variant := media.AudioVariantPath(rel, audio)
+	outputDir, playlist, url := s.store.HLSPaths(variant, format)
+	ready, segments := hlsReady(outputDir, playlist, s.converter.HLSMarkerVersion(), format)
 
-	jobKey := jobKey(media.JobHLS, rel)
 	state, jobErr, progress := s.jobs.Status(jobKey)
 	if state == media.StateFailed {
-		return media.JobStatus{State: media.StateFailed, Error: jobErr, URL: url, Progress: progress}, nil
+		return media.JobStatus{State: media.StateFailed, Error: jobErr, URL: url, Progress: progress}
 	}
 	if state == media.StateProcessing {
-		return media.JobStatus{State: media.StateProcessing, Processing: true, URL: url, Segments: segments, Ready: ready, Progress: progress}, nil
+		return media.JobStatus{State: media.StateProcessing, Processing: true, URL: url, Segments: segments, Ready: ready, Progress: progress}
 	}
 
 	if ready {
-		return media.JobStatus{State: media.StateReady, Ready: true, URL: url, Segments: segments}, nil
+		return media.JobStatus{State: media.StateReady, Ready: true, URL: url, Segments: segments, Progress: 100}
+	}
+
+	if point, paused := readHLSPause(outputDir); paused {
+		return media.JobStatus{State: media.StatePaused, Resumable: true, URL: url, Segments: point.Segments, Progress: progress}
+	}
+	if state == media.StatePaused {
+		return media.JobStatus{State: media.StatePaused, URL: url}
 	}
 
-	return media.JobStatus{State: media.StateIdle, URL: url, Segments: segments, Ready: false}, nil
+	return media.JobStatus{State: media.StateIdle, URL: url, Segments: segments, Ready: false}
 }
 
-// StartMP4 ensures MP4 conversion is scheduled for a non-mp4 source file.
-func (s *Service) StartMP4(ctx context.Context, rawPath string) (media.JobStatus, error) {
+// StartMP4 ensures MP4 conversion is scheduled for a source file.
+// audio selects the audio track, or media.DefaultAudioTrack; subs optionally adds
+// a subtitle stream. Each selection converts into its own output.
+// MP4 sources are only accepted when they are not faststart already; their
+// default selection is remuxed rather than converted.
+// A non-empty idempotencyKey makes retries within a short window share one outcome.
+func (s *Service) StartMP4(ctx context.Context, rawPath string, audio int, subs media.SubtitleSelection, idempotencyKey string) (media.JobStatus, error) {
 	rel, full, err := s.store.ResolveVideoPath(rawPath)
 	if err != nil {
 		return media.JobStatus{}, err
 	}
+	if err := s.checkMP4Request(ctx, rel, full, subs); err != nil {
+		return media.JobStatus{}, err
+	}
+
+	return s.idempotent(ctx, idempotencyKey, jobKey(media.JobMP4, mp4Variant(rel, audio, subs)), func() (media.JobStatus, error) {
+		return s.startMP4(rel, full, audio, subs, false)
+	})
+}
 
-	ext := strings.ToLower(filepath.Ext(rel))
-	if ext == ".mp4" {
-		return media.JobStatus{}, errors.New("unsupported file type")
+// checkMP4Request refuses MP4 sources that are faststart already and subtitle
+// selections the source cannot provide.
+func (s *Service) checkMP4Request(ctx context.Context, rel, full string, subs media.SubtitleSelection) error {
+	if isMP4Source(rel) {
+		fast, err := s.converter.Faststart(full)
+		if err != nil {
+			return err
+		}
+		if fast {
+			return ErrAlreadyFaststart
+		}
 	}
+	return s.checkSubtitles(ctx, rel, subs)
+}
 
-	outputDir, outputPath, url := s.store.MP4Paths(rel)
-	ready := mp4Ready(outputDir, outputPath, s.converter.MP4MarkerVersion())
+// startMP4 schedules the conversion. Prewarm conversions wait behind
+// user-started ones and give up their slot to them.
+func (s *Service) startMP4(rel, full string, audio int, subs media.SubtitleSelection, prewarm bool) (media.JobStatus, error) {
+	variant := mp4Variant(rel, audio, subs)
+	outputDir, outputPath, url := s.mp4Paths(rel, audio, subs)
+	ready := s.mp4Ready(outputDir, outputPath)
 
-	jobKey := jobKey(media.JobMP4, rel)
+	jobKey := jobKey(media.JobMP4, variant)
+	if s.jobs.IsHeld(jobKey) {
+		return media.JobStatus{}, ErrJobPausing
+	}
 	if s.jobs.IsRunning(jobKey) {
-		_, _, progress := s.jobs.Status(jobKey)
-		return media.JobStatus{State: media.StateProcessing, Processing: true, URL: url, Ready: ready, Progress: progress}, nil
+		if !prewarm {
+			s.mp4Slots.promote(jobKey)
+		}
+		state, _, progress := s.jobs.Status(jobKey)
+		return media.JobStatus{State: state, Processing: true, URL: url, Ready: ready, Progress: progress, QueuePosition: s.mp4Slots.position(jobKey)}, nil
 	}
 
 	if ready {
 		return media.JobStatus{State: media.StateReady, Ready: true, URL: url}, nil
 	}
 
+	if err := s.checkFreeSpace(full, outputDir); err != nil {
+		return media.JobStatus{}, err
+	}
+	s.recordOutputName(variant)
+
 	if err := s.prepareMP4Output(outputDir, outputPath); err != nil {
 		return media.JobStatus{}, err
 	}
 
-	s.jobs.Start(jobKey)
-	s.logger.Printf("MP4 conversion started: %s", rel)
+	ctx := s.jobs.Start(jobKey)
+	s.jobs.Queued(jobKey)
+	s.logger.Printf("MP4 conversion started: %s", variant)
+	var preempted atomic.Bool
+	preempt := func() {
+		preempted.Store(true)
+		s.jobs.Pause(jobKey)
+	}
 	go func() {
-		s.mp4Slots <- struct{}{}
-		defer func() { <-s.mp4Slots }()
+		if err := s.mp4Slots.acquire(ctx, jobKey, prewarm, preempt); err != nil {
+			s.jobs.Stopped(jobKey)
+			return
+		}
+		defer s.mp4Slots.release(jobKey)
+		s.jobs.Dequeued(jobKey)
 
-		err := s.converter.ConvertMP4WithProgress(context.Background(), full, outputPath, func(progress int) {
-			s.jobs.Progress(jobKey, progress)
-		})
+		var err error
+		switch {
+		case isMP4Source(rel) && audio == media.DefaultAudioTrack && !subs.Enabled():
+			err = s.converter.RemuxMP4(ctx, full, outputPath, func(progress int) {
+				s.jobs.Progress(jobKey, progress)
+			})
+		case subs.Enabled():
+			err = s.converter.ConvertMP4WithSubtitles(ctx, full, outputPath, audio, subs.Index, subs.Burn, func(progress int) {
+				s.jobs.Progress(jobKey, progress)
+			})
+		default:
+			err = s.converter.ConvertMP4WithProgress(ctx, full, outputPath, audio, func(progress int) {
+				s.jobs.Progress(jobKey, progress)
+			})
+		}
 		if err != nil {
-			s.logger.Printf("MP4 conversion failed: %s: %v", rel, err)
 			_ = os.Remove(outputPath)
+			s.forgetVerified(outputPath)
 			_ = os.Remove(filepath.Join(outputDir, mp4MarkerFile))
+			if ctx.Err() != nil || errors.Is(err, media.ErrConversionStopped) {
+				s.jobs.Stopped(jobKey)
+				if preempted.Load() {
+					// Prewarm picks the video up again on a later scan.
+					s.logger.Printf("MP4 prewarm preempted by a user conversion: %s", variant)
+					s.jobs.Forget(jobKey)
+					return
+				}
+				s.logger.Printf("MP4 conversion stopped: %s", variant)
+				return
+			}
+			s.logger.Printf("MP4 conversion failed: %s: %v", variant, err)
 			s.jobs.Fail(jobKey, err)
 			return
 		}
 		_ = os.WriteFile(filepath.Join(outputDir, mp4MarkerFile), []byte(s.converter.MP4MarkerVersion()), 0o644)
-		s.logger.Printf("MP4 conversion finished: %s", rel)
+		s.logger.Printf("MP4 conversion finished: %s", variant)
 		s.jobs.Ready(jobKey)
 	}()
 
-	return media.JobStatus{State: media.StateProcessing, Processing: true, URL: url, Progress: 0}, nil
+	return media.JobStatus{State: media.StateQueued, Processing: true, URL: url, Progress: 0, QueuePosition: s.mp4Slots.position(jobKey)}, nil
 }
 
-// MP4Status returns MP4 conversion state and readiness.
-func (s *Service) MP4Status(rawPath string) (media.JobStatus, error) {
+// MP4Status returns MP4 conversion state and readiness for an audio track and
+// subtitle selection.
+func (s *Service) MP4Status(rawPath string, audio int, subs media.SubtitleSelection) (media.JobStatus, error) {
 	rel, _, err := s.store.ResolveVideoPath(rawPath)
 	if err != nil {
 		return media.JobStatus{}, err
 	}
 
-	outputDir, outputPath, url := s.store.MP4Paths(rel)
-	ready := mp4Ready(outputDir, outputPath, s.converter.MP4MarkerVersion())
+	key := jobKey(media.JobMP4, mp4Variant(rel, audio, subs))
+	return s.withTiming(key, s.mp4Status(rel, audio, subs, key)), nil
+}
+
+func (s *Service) mp4Status(rel string, audio int, subs media.SubtitleSelection, jobKey string) media.JobStatus {
+	outputDir, outputPath, url := s.mp4Paths(rel, audio, subs)
+	ready := s.mp4Ready(outputDir, outputPath)
 
-	jobKey := jobKey(media.JobMP4, rel)
 	state, jobErr, progress := s.jobs.Status(jobKey)
 	if state == media.StateFailed {
-		return media.JobStatus{State: media.StateFailed, Error: jobErr, URL: url, Progress: progress}, nil
+		return media.JobStatus{State: media.StateFailed, Error: jobErr, URL: url, Progress: progress}
 	}
-	if state == media.StateProcessing {
-		return media.JobStatus{State: media.StateProcessing, Processing: true, URL: url, Ready: ready, Progress: progress}, nil
+	if state == media.StateProcessing || state == media.StateQueued {
+		return media.JobStatus{State: state, Processing: true, URL: url, Ready: ready, Progress: progress, QueuePosition: s.mp4Slots.position(jobKey)}
+	}
+	if state == media.StatePaused && !ready {
+		return media.JobStatus{State: media.StatePaused, URL: url}
 	}
 
 	if ready {
-		return media.JobStatus{State: media.StateReady, Ready: true, URL: url, Progress: 100}, nil
+		return media.JobStatus{State: media.StateReady, Ready: true, URL: url, Progress: 100}
 	}
 
-	return media.JobStatus{State: media.StateIdle, URL: url, Ready: false, Progress: progress}, nil
+	return media.JobStatus{State: media.StateIdle, URL: url, Ready: false, Progress: progress}
+}
+
+// withTiming adds start time, elapsed time and ETA of the job under key to status.
+// The ETA extrapolates the average rate so far and is only known once a running
+// job has reported progress; ready jobs have none left.
+func (s *Service) withTiming(key string, status media.JobStatus) media.JobStatus {
+	startedAt, endedAt := s.jobs.Times(key)
+	if startedAt.IsZero() {
+		return status
+	}
+	if endedAt.IsZero() {
+		endedAt = time.Now()
+	}
+
+	status.StartedAt = startedAt
+	status.Elapsed = endedAt.Sub(startedAt)
+	switch {
+	case status.State == media.StateReady:
+		status.ETA, status.ETAKnown = 0, true
+	case status.State == media.StateProcessing && status.Progress > 0 && status.Progress < 100:
+		status.ETA = status.Elapsed * time.Duration(100-status.Progress) / time.Duration(status.Progress)
+		status.ETAKnown = true
+	}
+	return status
+}
+
+// ActiveJobs lists conversions that are currently processing.
+func (s *Service) ActiveJobs() []media.JobInfo {
+	return s.jobs.Active()
+}
+
+// ArtifactStatuses reports generated outputs for many paths at once. It only inspects
+// markers, playlists and the job registry, so it is cheap enough for library grids.
+// Invalid paths are reported with an error entry instead of failing the batch.
+func (s *Service) ArtifactStatuses(rawPaths []string) []media.ArtifactStatus {
+	out := make([]media.ArtifactStatus, 0, len(rawPaths))
+	for _, rawPath := range rawPaths {
+		rel, _, err := s.store.ResolveVideoPath(rawPath)
+		if err != nil {
+			out = append(out, media.ArtifactStatus{Path: rawPath, Error: err.Error()})
+			continue
+		}
+
+		hlsDir, playlist, _ := s.store.HLSPaths(rel, s.hlsFormat)
+		hlsReady, _ := hlsReady(hlsDir, playlist, s.converter.HLSMarkerVersion(), s.hlsFormat)
+		mp4Dir, mp4Path, _ := s.store.MP4Paths(rel)
+
+		out = append(out, media.ArtifactStatus{
+			Path:          rel,
+			HLSReady:      hlsReady,
+			MP4Ready:      s.mp4LikelyReady(mp4Dir, mp4Path),
+			HLSProcessing: s.jobs.IsRunning(jobKey(hlsJobType(s.hlsFormat), rel)),
+			MP4Processing: s.jobs.IsRunning(jobKey(media.JobMP4, rel)),
+		})
+	}
+	return out
 }
 
 // MP4Processing reports whether MP4 conversion is currently running.
@@ -398,52 +589,201 @@ func (s *Service) MP4Processing(rawPath string) (bool, error) {
 }
 
 // StreamMP4 writes an MP4 stream directly from source file (or growing file when follow=true).
+// A stream ended by ctx, such as a client disconnecting, returns nil. When the
+// live stream cap is reached it returns ErrLiveStreamsFull before writing anything.
 func (s *Service) StreamMP4(ctx context.Context, rawPath string, follow bool, out io.Writer) error {
 	_, full, err := s.store.ResolveVideoPath(rawPath)
 	if err != nil {
 		return err
 	}
+	release, ok := s.acquireLiveStream()
+	if !ok {
+		return ErrLiveStreamsFull
+	}
+	defer release()
+
 	idleTimeout := 10 * time.Minute
 	if follow {
 		idleTimeout = 0
 	}
-	return s.converter.StreamMP4(ctx, full, out, follow, idleTimeout)
+	if err := s.converter.StreamMP4(ctx, full, out, follow, idleTimeout); err != nil && !errors.Is(err, media.ErrConversionStopped) {
+		return err
+	}
+	return nil
 }
 
-func hlsReady(outputDir, playlistPath, version string) (bool, int) {
+// hlsReady reports whether an HLS output is playable and how many segments exist.
+// fMP4 renditions additionally need their `init.mp4` initialization segment. An
+// adaptive output's playlist is a master playlist; every variant it lists must be
+// ready, and the segment count is that of the shortest variant.
+func hlsReady(outputDir, playlistPath, version string, format media.HLSFormat) (bool, int) {
 	if !markerMatches(outputDir, hlsMarkerFile, version) {
 		return false, 0
 	}
 
+	playlists := []string{playlistPath}
+	if variants, master := masterVariants(outputDir, playlistPath); master {
+		if len(variants) == 0 {
+			return false, 0
+		}
+		playlists = variants
+	}
+
+	ready, segments := true, -1
+	for _, playlist := range playlists {
+		variantReady, count := mediaPlaylistReady(filepath.Dir(playlist), playlist, format)
+		ready = ready && variantReady
+		if segments < 0 || count < segments {
+			segments = count
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, hlsPauseFile)); err == nil {
+		return false, segments
+	}
+	return ready, segments
+}
+
+// mediaPlaylistReady reports whether the media playlist in dir ends with a
+// complete segment, and how many segments dir holds.
+func mediaPlaylistReady(dir, playlistPath string, format media.HLSFormat) (bool, int) {
 	info, err := os.Stat(playlistPath)
 	if err != nil || info.Size() == 0 {
 		return false, 0
 	}
 
+	segmentExt := ".ts"
+	if format == media.HLSFormatFMP4 {
+		segmentExt = ".m4s"
+		initInfo, err := os.Stat(filepath.Join(dir, fmp4InitFile))
+		if err != nil || initInfo.Size() == 0 {
+			return false, 0
+		}
+	}
+
 	segments := 0
-	entries, err := os.ReadDir(outputDir)
+	entries, err := os.ReadDir(dir)
 	if err == nil {
 		for _, entry := range entries {
-			if strings.HasSuffix(entry.Name(), ".ts") {
+			if strings.HasSuffix(entry.Name(), segmentExt) {
 				segments++
 			}
 		}
 	}
+	return segments > 0 && lastSegmentComplete(dir, playlistPath), segments
+}
+
+// masterVariants reports whether playlistPath is a master playlist and returns
+// the variant playlists it lists, resolved inside outputDir. A variant URI that
+// points outside outputDir yields no variants, so the output is never ready.
+func masterVariants(outputDir, playlistPath string) ([]string, bool) {
+	data, err := os.ReadFile(playlistPath)
+	if err != nil || !strings.Contains(string(data), "#EXT-X-STREAM-INF") {
+		return nil, false
+	}
 
-	return segments > 0, segments
+	var variants []string
+	streamInf := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF"):
+			streamInf = true
+		case line == "" || strings.HasPrefix(line, "#"):
+		case streamInf:
+			if strings.Contains(line, "..") || filepath.IsAbs(line) || strings.Contains(line, "://") {
+				return nil, true
+			}
+			variants = append(variants, filepath.Join(outputDir, filepath.FromSlash(line)))
+			streamInf = false
+		}
+	}
+	return variants, true
 }
 
-func mp4Ready(outputDir, outputPath, version string) bool {
-	if !markerMatches(outputDir, mp4MarkerFile, version) {
+// lastSegmentComplete reports whether the final segment referenced by the playlist
+// exists under its final (non-temporary) name. ffmpeg's temp_file flag writes
+// `<segment>.tmp` first and renames it when the segment is complete.
+func lastSegmentComplete(outputDir, playlistPath string) bool {
+	data, err := os.ReadFile(playlistPath)
+	if err != nil {
+		return false
+	}
+
+	last := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			last = line
+		}
+	}
+	return last != "" && segmentComplete(outputDir, last)
+}
+
+// segmentComplete reports whether a playlist segment URI names a finished, non-empty
+// file inside outputDir.
+func segmentComplete(outputDir, name string) bool {
+	if strings.HasSuffix(name, ".tmp") || strings.Contains(name, "..") || filepath.IsAbs(name) {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(name)))
+	return err == nil && info.Mode().IsRegular() && info.Size() > 0
+}
+
+// mp4Ready reports whether the MP4 output is complete: the marker must match,
+// the file must pass the size floor, and ffprobe must report a positive duration.
+func (s *Service) mp4Ready(outputDir, outputPath string) bool {
+	if !markerMatches(outputDir, mp4MarkerFile, s.converter.MP4MarkerVersion()) {
 		return false
 	}
 
 	info, err := os.Stat(outputPath)
-	if err != nil {
+	if err != nil || info.Size() < s.mp4ReadyMinBytes {
+		return false
+	}
+
+	s.verifiedMu.Lock()
+	cached, ok := s.verifiedOutputs[outputPath]
+	s.verifiedMu.Unlock()
+	if ok && cached.size == info.Size() && cached.modifiedAt.Equal(info.ModTime()) {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), readinessProbeTimeout)
+	defer cancel()
+	duration, err := s.converter.ProbeDuration(ctx, outputPath)
+	if err != nil || duration <= 0 {
 		return false
 	}
 
-	return info.Size() >= mp4ReadyMinBytes
+	s.verifiedMu.Lock()
+	if _, ok := s.verifiedOutputs[outputPath]; !ok && len(s.verifiedOutputs) >= maxVerifiedOutputs {
+		// Evict an arbitrary entry; it costs one probe if that output is asked for again.
+		for path := range s.verifiedOutputs {
+			delete(s.verifiedOutputs, path)
+			break
+		}
+	}
+	s.verifiedOutputs[outputPath] = verifiedOutput{size: info.Size(), modifiedAt: info.ModTime()}
+	s.verifiedMu.Unlock()
+	return true
+}
+
+// forgetVerified drops the cached probe of an MP4 output that is being removed or rewritten.
+func (s *Service) forgetVerified(outputPath string) {
+	s.verifiedMu.Lock()
+	delete(s.verifiedOutputs, outputPath)
+	s.verifiedMu.Unlock()
+}
+
+// mp4LikelyReady is the probe-free variant of mp4Ready: marker and size floor only.
+func (s *Service) mp4LikelyReady(outputDir, outputPath string) bool {
+	if !markerMatches(outputDir, mp4MarkerFile, s.converter.MP4MarkerVersion()) {
+		return false
+	}
+
+	info, err := os.Stat(outputPath)
+	return err == nil && info.Size() >= s.mp4ReadyMinBytes
 }
 
 func markerMatches(outputDir, markerFile, version string) bool {
@@ -464,6 +804,7 @@ func (s *Service) prepareHLSOutput(outputDir string) error {
 
 func (s *Service) prepareMP4Output(outputDir, outputPath string) error {
 	_ = os.Remove(outputPath)
+	s.forgetVerified(outputPath)
 	_ = os.Remove(filepath.Join(outputDir, mp4MarkerFile))
 	if err := os.MkdirAll(outputDir, 0o755); err != nil {
 		return err
@@ -472,31 +813,187 @@ func (s *Service) prepareMP4Output(outputDir, outputPath string) error {
 }
 
 type jobRegistry struct {
-	mu   sync.Mutex
-	jobs map[string]*jobState
+	mu      sync.Mutex
+	jobs    map[string]*jobState
+	metrics ConversionMetrics
 }
 
 type jobState struct {
 	state    media.JobState
 	err      string
 	progress int
+
+	startedAt time.Time
+	endedAt   time.Time
+	// held is set while a pause or cancel is still tidying the job's output.
+	held bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// active reports whether the job is processing or waiting to.
+func (s *jobState) active() bool {
+	return s.state == media.StateProcessing || s.state == media.StateQueued
+}
+
+// finish releases the job context and wakes anyone waiting for the job to end.
+// It reports whether a started job ended with this call.
+func (s *jobState) finish() bool {
+	ended := s.endedAt.IsZero() && !s.startedAt.IsZero()
+	if ended {
+		s.endedAt = time.Now()
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
+	if s.done != nil {
+		select {
+		case <-s.done:
+		default:
+			close(s.done)
+		}
+	}
+	return ended
+}
+
+func newJobRegistry(metrics ConversionMetrics) *jobRegistry {
+	return &jobRegistry{jobs: make(map[string]*jobState), metrics: metrics}
 }
 
-func newJobRegistry() *jobRegistry {
-	return &jobRegistry{jobs: make(map[string]*jobState)}
+// finishLocked finishes the job under key and records it once it ended.
+func (j *jobRegistry) finishLocked(key string, state *jobState) {
+	if state.finish() && j.metrics != nil {
+		jobType, _, _ := strings.Cut(key, ":")
+		j.metrics.ConversionFinished(media.JobType(jobType), state.state, state.endedAt.Sub(state.startedAt))
+	}
 }
 
 func (j *jobRegistry) IsRunning(key string) bool {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 	state, ok := j.jobs[key]
-	return ok && state.state == media.StateProcessing
+	return ok && state.active()
+}
+
+// Start marks key as processing and returns the context the job must run under.
+func (j *jobRegistry) Start(key string) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.jobs[key] = &jobState{state: media.StateProcessing, startedAt: time.Now(), cancel: cancel, done: make(chan struct{})}
+	return ctx
+}
+
+// Queued marks a processing job as waiting for a conversion slot.
+func (j *jobRegistry) Queued(key string) {
+	j.setActiveState(key, media.StateQueued)
+}
+
+// Dequeued marks a queued job as processing once it got its slot.
+func (j *jobRegistry) Dequeued(key string) {
+	j.setActiveState(key, media.StateProcessing)
+}
+
+func (j *jobRegistry) setActiveState(key string, state media.JobState) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if job := j.jobs[key]; job != nil && job.active() {
+		job.state = state
+	}
+}
+
+// Pause marks a processing job as paused and cancels its context. The returned
+// channel is closed once the job goroutine has exited.
+func (j *jobRegistry) Pause(key string) (<-chan struct{}, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	state, ok := j.jobs[key]
+	if !ok || !state.active() {
+		return nil, false
+	}
+	state.state = media.StatePaused
+	state.cancel()
+	return state.done, true
 }
 
-func (j *jobRegistry) Start(key string) {
+// Hold pauses a job like Pause and keeps its key held until Release, so the
+// caller can tidy the output without a new conversion starting on it.
+func (j *jobRegistry) Hold(key string) (<-chan struct{}, bool) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
-	j.jobs[key] = &jobState{state: media.StateProcessing}
+	state, ok := j.jobs[key]
+	if !ok || !state.active() {
+		return nil, false
+	}
+	state.state = media.StatePaused
+	state.held = true
+	state.cancel()
+	return state.done, true
+}
+
+// Release ends a Hold.
+func (j *jobRegistry) Release(key string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if state := j.jobs[key]; state != nil {
+		state.held = false
+	}
+}
+
+// IsHeld reports whether a pause or cancel of the job is still in progress.
+func (j *jobRegistry) IsHeld(key string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	state, ok := j.jobs[key]
+	return ok && state.held
+}
+
+// Stopped records that a cancelled job has exited, keeping the state set by Pause.
+// A job that reports a stop nobody asked for is marked failed, so it does not
+// pass for idle.
+func (j *jobRegistry) Stopped(key string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	state := j.jobs[key]
+	if state == nil {
+		return
+	}
+	if state.active() {
+		state.state = media.StateFailed
+		state.err = errStoppedUnexpectedly.Error()
+	}
+	j.finishLocked(key, state)
+}
+
+// ClearIdle runs remove with the registry locked unless a job under keys is
+// processing or queued, and then forgets those jobs. Jobs cannot start while
+// remove runs, so outputs are never removed under a conversion writing them.
+func (j *jobRegistry) ClearIdle(keys []string, remove func() error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, key := range keys {
+		if state := j.jobs[key]; state != nil && (state.active() || state.held) {
+			return ErrJobRunning
+		}
+	}
+	if err := remove(); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		delete(j.jobs, key)
+	}
+	return nil
+}
+
+// Forget drops a job that is not processing, so its key reports idle again.
+// Forgetting a held job also ends the hold.
+func (j *jobRegistry) Forget(key string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if state := j.jobs[key]; state != nil && !state.active() {
+		delete(j.jobs, key)
+	}
 }
 
 func (j *jobRegistry) Ready(key string) {
@@ -508,6 +1005,7 @@ func (j *jobRegistry) Ready(key string) {
 	}
 	state.state = media.StateReady
 	state.progress = 100
+	j.finishLocked(key, state)
 	j.jobs[key] = state
 }
 
@@ -520,6 +1018,7 @@ func (j *jobRegistry) Fail(key string, err error) {
 	}
 	state.state = media.StateFailed
 	state.err = err.Error()
+	j.finishLocked(key, state)
 	j.jobs[key] = state
 }
 
@@ -533,6 +1032,18 @@ func (j *jobRegistry) Status(key string) (media.JobState, string, int) {
 	return state.state, state.err, state.progress
 }
 
+// Times returns when the job under key started and, once it has stopped, ended.
+// Both are zero for jobs the registry has not seen start.
+func (j *jobRegistry) Times(key string) (time.Time, time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	state, ok := j.jobs[key]
+	if !ok {
+		return time.Time{}, time.Time{}
+	}
+	return state.startedAt, state.endedAt
+}
+
 func (j *jobRegistry) Progress(key string, value int) {
 	if value < 0 {
 		value = 0
@@ -552,6 +1063,100 @@ func (j *jobRegistry) Progress(key string, value int) {
 	j.jobs[key] = state
 }
 
+// Running returns the done channels of processing jobs by key.
+func (j *jobRegistry) Running() map[string]<-chan struct{} {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	out := make(map[string]<-chan struct{})
+	for key, state := range j.jobs {
+		if state.active() && state.done != nil {
+			out[key] = state.done
+		}
+	}
+	return out
+}
+
+// Active returns processing and queued jobs ordered by key.
+func (j *jobRegistry) Active() []media.JobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	out := make([]media.JobInfo, 0)
+	for key, state := range j.jobs {
+		if !state.active() {
+			continue
+		}
+		jobType, relPath, _ := strings.Cut(key, ":")
+		out = append(out, media.JobInfo{
+			Type:     media.JobType(jobType),
+			Path:     relPath,
+			State:    state.state,
+			Progress: state.progress,
+		})
+	}
+	sort.Slice(out, func(i, k int) bool {
+		if out[i].Type != out[k].Type {
+			return out[i].Type < out[k].Type
+		}
+		return out[i].Path < out[k].Path
+	})
+	return out
+}
+
+// mp4Paths returns the MP4 output for rel converted with the given audio track
+// and subtitle selection. The stream URL keeps the source path and carries the
+// selection as query parameters.
+func (s *Service) mp4Paths(rel string, audio int, subs media.SubtitleSelection) (string, string, string) {
+	outputDir, outputPath, url := s.store.MP4Paths(mp4Variant(rel, audio, subs))
+	if query := selectionQuery(audio, subs); query != "" {
+		_, _, url = s.store.MP4Paths(rel)
+		url += "?" + query
+	}
+	return outputDir, outputPath, url
+}
+
+// recordOutputName notes the source of outputs stored under a hashed long name.
+func (s *Service) recordOutputName(variant string) {
+	if err := s.store.RecordOutputName(variant); err != nil {
+		s.logger.Printf("Output name not recorded: %s: %v", variant, err)
+	}
+}
+
+// mp4Variant names the MP4 artifacts of rel for an audio track and subtitle selection.
+func mp4Variant(rel string, audio int, subs media.SubtitleSelection) string {
+	return media.SubtitleVariantPath(media.AudioVariantPath(rel, audio), subs)
+}
+
+// selectionQuery encodes non-default stream selections as stream URL parameters.
+func selectionQuery(audio int, subs media.SubtitleSelection) string {
+	query := url.Values{}
+	if audio >= 0 {
+		query.Set("audio", strconv.Itoa(audio))
+	}
+	if subs.Enabled() {
+		query.Set("subs", strconv.Itoa(subs.Index))
+		if subs.Burn {
+			query.Set("burn", "1")
+		}
+	}
+	return query.Encode()
+}
+
+func (s *Service) resolveHLSFormat(format media.HLSFormat) media.HLSFormat {
+	if format == "" {
+		return s.hlsFormat
+	}
+	return format
+}
+
+func hlsJobType(format media.HLSFormat) media.JobType {
+	if format == media.HLSFormatFMP4 {
+		return media.JobHLSFMP4
+	}
+	return media.JobHLS
+}
+
 func jobKey(jobType media.JobType, relPath string) string {
 	return string(jobType) + ":" + relPath
 }
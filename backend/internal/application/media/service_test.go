@@ -0,0 +1,794 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+type fakeStore struct {
+	videosDir string
+	hlsDir    string
+	mp4Dir    string
+	thumbsDir string
+	// free is reported by FreeSpace for every path.
+	free uint64
+}
+
+func newFakeStore(t *testing.T) *fakeStore {
+	t.Helper()
+	root := t.TempDir()
+	store := &fakeStore{
+		videosDir: filepath.Join(root, "videos"),
+		hlsDir:    filepath.Join(root, "hls"),
+		mp4Dir:    filepath.Join(root, "mp4"),
+		thumbsDir: filepath.Join(root, "thumbs"),
+		free:      1 << 40,
+	}
+	for _, dir := range []string{store.videosDir, store.hlsDir, store.mp4Dir, store.thumbsDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	return store
+}
+
+func (f *fakeStore) ListVideos() ([]media.Video, error) {
+	videos := []media.Video{}
+	entries, err := os.ReadDir(f.videosDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		videos = append(videos, media.Video{Name: entry.Name(), Path: entry.Name(), Size: info.Size(), ModifiedAt: info.ModTime()})
+	}
+	return videos, nil
+}
+
+func (f *fakeStore) ResolveVideoPath(raw string) (string, string, error) {
+	rel, err := media.NormalizeVideoPath(raw)
+	if err != nil {
+		return "", "", err
+	}
+	full := filepath.Join(f.videosDir, filepath.FromSlash(rel))
+	if _, err := os.Stat(full); err != nil {
+		return "", "", err
+	}
+	return rel, full, nil
+}
+
+func (f *fakeStore) HLSPaths(relPath string, format media.HLSFormat) (string, string, string) {
+	base := strings.TrimSuffix(relPath, path.Ext(relPath))
+	if format == media.HLSFormatFMP4 {
+		base = "_fmp4/" + base
+	}
+	outputDir := filepath.Join(f.hlsDir, filepath.FromSlash(base))
+	return outputDir, filepath.Join(outputDir, "index.m3u8"), "/hls/" + base + "/index.m3u8"
+}
+
+func (f *fakeStore) MP4Paths(relPath string) (string, string, string) {
+	base := strings.TrimSuffix(relPath, path.Ext(relPath))
+	outputPath := filepath.Join(f.mp4Dir, filepath.FromSlash(base)+".mp4")
+	return filepath.Dir(outputPath), outputPath, "/api/stream-mp4/" + relPath
+}
+
+func (f *fakeStore) ThumbnailPath(relPath string) string {
+	base := strings.TrimSuffix(relPath, path.Ext(relPath))
+	return filepath.Join(f.thumbsDir, filepath.FromSlash(base)+".jpg")
+}
+
+func (f *fakeStore) FreeSpace(string) (uint64, error) { return f.free, nil }
+
+func (f *fakeStore) OutputRoots() (string, string) { return f.hlsDir, f.mp4Dir }
+
+func (f *fakeStore) RecordOutputName(string) error { return nil }
+
+func (f *fakeStore) TrashVideo(relPath string) (string, error) {
+	name := ".trash/" + relPath
+	dst := filepath.Join(f.videosDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", err
+	}
+	return name, os.Rename(filepath.Join(f.videosDir, filepath.FromSlash(relPath)), dst)
+}
+
+func (f *fakeStore) EmptyTrash(time.Duration) ([]string, error) { return nil, nil }
+
+func (f *fakeStore) MoveVideo(srcRel, dstRel string) error {
+	dst := filepath.Join(f.videosDir, filepath.FromSlash(dstRel))
+	if _, err := os.Lstat(dst); err == nil {
+		return &os.PathError{Op: "move", Path: dstRel, Err: os.ErrExist}
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(f.videosDir, filepath.FromSlash(srcRel)), dst)
+}
+
+func (f *fakeStore) writeVideo(t *testing.T, relPath string, size int) string {
+	t.Helper()
+	full := filepath.Join(f.videosDir, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(full, make([]byte, size), 0o644); err != nil {
+		t.Fatalf("write video: %v", err)
+	}
+	return full
+}
+
+type fakeConverter struct {
+	durations map[string]float64
+
+	mu         sync.Mutex
+	thumbnails []string
+	probes     int
+	hlsCalls   int
+	// hlsRelease, when set, blocks ConvertHLSWithProgress until it is closed or the job is stopped.
+	hlsRelease chan struct{}
+	resumedAt  []media.HLSResumePoint
+	ladders    [][]media.Rendition
+	mp4Audio   []int
+	// mp4Err, when set, is returned by ConvertMP4WithProgress.
+	mp4Err error
+	// faststart lists MP4 sources reported as faststart; remuxed records RemuxMP4 inputs.
+	faststart map[string]bool
+	remuxed   []string
+	// subtitles are reported by Probe; subtitled records subtitle conversions.
+	subtitles []media.SubtitleTrack
+	subtitled []media.SubtitleSelection
+	// streamRelease, when set, blocks StreamMP4 until it is closed or ctx ends.
+	streamRelease chan struct{}
+	// thumbRelease, when set, blocks ExtractThumbnail until it is closed or ctx ends.
+	thumbRelease chan struct{}
+}
+
+func (f *fakeConverter) HLSMarkerVersion() string { return "test" }
+
+func (f *fakeConverter) MP4MarkerVersion() string { return "test" }
+
+func (f *fakeConverter) ProbeDuration(_ context.Context, inputPath string) (float64, error) {
+	if duration, ok := f.durations[inputPath]; ok {
+		return duration, nil
+	}
+	return 0, errors.New("invalid data found when processing input")
+}
+
+func (f *fakeConverter) Probe(_ context.Context, inputPath string) (media.MediaInfo, error) {
+	f.mu.Lock()
+	f.probes++
+	f.mu.Unlock()
+	duration, ok := f.durations[inputPath]
+	if !ok {
+		return media.MediaInfo{}, errors.New("invalid data found when processing input")
+	}
+	return media.MediaInfo{Duration: duration, VideoCodec: "h264", AudioTracks: []media.AudioTrack{}, SubtitleTracks: f.subtitles}, nil
+}
+
+func (f *fakeConverter) ConvertHLSWithProgress(ctx context.Context, _, outputDir, _ string, format media.HLSFormat, _ int, onProgress func(int)) error {
+	f.mu.Lock()
+	f.hlsCalls++
+	release := f.hlsRelease
+	f.mu.Unlock()
+
+	segment := "segment00000.ts"
+	if format == media.HLSFormatFMP4 {
+		segment = "segment00000.m4s"
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, segment), []byte("seg"), 0o644); err != nil {
+		return err
+	}
+	onProgress(40)
+	if release != nil {
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (f *fakeConverter) ResumeHLS(_ context.Context, _, outputDir, playlistPath string, _ media.HLSFormat, _ int, from media.HLSResumePoint) error {
+	f.mu.Lock()
+	f.resumedAt = append(f.resumedAt, from)
+	f.mu.Unlock()
+
+	segment := fmt.Sprintf("segment%05d.ts", from.Segments)
+	if err := os.WriteFile(filepath.Join(outputDir, segment), []byte("seg"), 0o644); err != nil {
+		return err
+	}
+	playlist, err := os.OpenFile(playlistPath, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer playlist.Close()
+	_, err = fmt.Fprintf(playlist, "#EXTINF:6.0,\n%s\n#EXT-X-ENDLIST\n", segment)
+	return err
+}
+
+func (f *fakeConverter) ConvertHLSLadder(_ context.Context, _, _, _ string, renditions []media.Rendition, _ media.HLSFormat, _ int, _ func(int)) error {
+	f.mu.Lock()
+	f.ladders = append(f.ladders, renditions)
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeConverter) ConvertHLSFollow(context.Context, string, string, string, media.HLSFormat, int, time.Duration) error {
+	return nil
+}
+
+func (f *fakeConverter) ConvertMP4WithProgress(_ context.Context, _, _ string, audioTrack int, _ func(int)) error {
+	f.mu.Lock()
+	f.mp4Audio = append(f.mp4Audio, audioTrack)
+	err := f.mp4Err
+	f.mu.Unlock()
+	return err
+}
+
+func (f *fakeConverter) Faststart(inputPath string) (bool, error) {
+	return f.faststart[inputPath], nil
+}
+
+func (f *fakeConverter) RemuxMP4(_ context.Context, inputPath, _ string, _ func(int)) error {
+	f.mu.Lock()
+	f.remuxed = append(f.remuxed, inputPath)
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeConverter) ConvertMP4WithSubtitles(_ context.Context, _, _ string, _, subIndex int, burn bool, onProgress func(int)) error {
+	onProgress(100)
+	f.mu.Lock()
+	f.subtitled = append(f.subtitled, media.SubtitleSelection{Index: subIndex, Burn: burn})
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeConverter) IngestMP4(_ context.Context, _, outputPath string, _ int64, _ time.Duration, onProgress func(int)) error {
+	onProgress(100)
+	return os.WriteFile(outputPath, []byte("mp4"), 0o644)
+}
+
+func (f *fakeConverter) ExtractThumbnail(ctx context.Context, inputPath, outputPath string, _ float64) error {
+	f.mu.Lock()
+	f.thumbnails = append(f.thumbnails, inputPath)
+	release := f.thumbRelease
+	f.mu.Unlock()
+	if release != nil {
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return os.WriteFile(outputPath, []byte("jpeg"), 0o644)
+}
+
+func (f *fakeConverter) StreamMP4(ctx context.Context, _ string, _ io.Writer, _ bool, _ time.Duration) error {
+	f.mu.Lock()
+	release := f.streamRelease
+	f.mu.Unlock()
+	if release != nil {
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func newTestService(t *testing.T, opts Options) (*Service, *fakeStore, *fakeConverter) {
+	t.Helper()
+	store := newFakeStore(t)
+	converter := &fakeConverter{durations: map[string]float64{}}
+	return NewService(store, converter, log.New(io.Discard, "", 0), opts), store, converter
+}
+
+func writeMP4Output(t *testing.T, store *fakeStore, relPath string, size int) (string, string) {
+	t.Helper()
+	outputDir, outputPath, _ := store.MP4Paths(relPath)
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(outputPath, make([]byte, size), 0o644); err != nil {
+		t.Fatalf("write output: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, mp4MarkerFile), []byte("test"), 0o644); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+	return outputDir, outputPath
+}
+
+func TestMP4Ready_AcceptsSmallValidClip(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	outputDir, outputPath := writeMP4Output(t, store, "clip.mkv", 200*1024)
+	converter.durations[outputPath] = 5
+
+	if !svc.mp4Ready(outputDir, outputPath) {
+		t.Fatalf("expected 200KB clip with valid duration to be ready")
+	}
+}
+
+func TestMP4Ready_RejectsGarbageAboveFloor(t *testing.T) {
+	svc, store, _ := newTestService(t, Options{})
+	outputDir, outputPath := writeMP4Output(t, store, "broken.mkv", 600*1024)
+
+	if svc.mp4Ready(outputDir, outputPath) {
+		t.Fatalf("expected unprobeable 600KB file to be not ready")
+	}
+}
+
+func TestMP4Ready_RespectsConfiguredFloor(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{MP4ReadyMinBytes: 1 << 20})
+	outputDir, outputPath := writeMP4Output(t, store, "clip.mkv", 200*1024)
+	converter.durations[outputPath] = 5
+
+	if svc.mp4Ready(outputDir, outputPath) {
+		t.Fatalf("expected output below configured floor to be not ready")
+	}
+}
+
+func TestMP4Ready_ProbeCacheIsDroppedAndBounded(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	outputDir, outputPath := writeMP4Output(t, store, "clip.mkv", 200*1024)
+	converter.durations[outputPath] = 5
+
+	if !svc.mp4Ready(outputDir, outputPath) {
+		t.Fatalf("expected clip to be ready")
+	}
+	if err := svc.prepareMP4Output(outputDir, outputPath); err != nil {
+		t.Fatalf("prepare output: %v", err)
+	}
+	if _, ok := svc.verifiedOutputs[outputPath]; ok {
+		t.Fatalf("expected rewritten output to leave the probe cache")
+	}
+
+	for i := 0; i < maxVerifiedOutputs; i++ {
+		svc.verifiedOutputs[fmt.Sprintf("stale-%d.mp4", i)] = verifiedOutput{}
+	}
+	_, outputPath = writeMP4Output(t, store, "clip.mkv", 200*1024)
+	if !svc.mp4Ready(outputDir, outputPath) {
+		t.Fatalf("expected clip to be ready")
+	}
+	if len(svc.verifiedOutputs) > maxVerifiedOutputs {
+		t.Fatalf("expected probe cache capped at %d, got %d", maxVerifiedOutputs, len(svc.verifiedOutputs))
+	}
+	if _, ok := svc.verifiedOutputs[outputPath]; !ok {
+		t.Fatalf("expected the new probe to be cached")
+	}
+}
+
+func TestStartMP4_RunsUpToConfiguredConcurrency(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{MP4Concurrency: 2})
+	store.writeVideo(t, "movie.mkv", 1024)
+	// Another conversion holds one of the two slots.
+	_ = svc.mp4Slots.acquire(context.Background(), "held", false, nil)
+	defer svc.mp4Slots.release("held")
+
+	if _, err := svc.StartMP4(context.Background(), "movie.mkv", media.DefaultAudioTrack, media.NoSubtitles, ""); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		converter.mu.Lock()
+		converted := len(converter.mp4Audio)
+		converter.mu.Unlock()
+		if converted == 1 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the conversion to take the free slot")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+type recordedConversion struct {
+	jobType media.JobType
+	outcome media.JobState
+}
+
+type fakeMetrics struct {
+	finished []recordedConversion
+}
+
+func (f *fakeMetrics) ConversionFinished(jobType media.JobType, outcome media.JobState, took time.Duration) {
+	f.finished = append(f.finished, recordedConversion{jobType, outcome})
+}
+
+func TestJobRegistry_RecordsFinishedConversionsOnce(t *testing.T) {
+	metrics := &fakeMetrics{}
+	jobs := newJobRegistry(metrics)
+
+	jobs.Start(jobKey(media.JobMP4, "a.mkv"))
+	jobs.Ready(jobKey(media.JobMP4, "a.mkv"))
+	jobs.Start(jobKey(media.JobHLS, "b.mkv"))
+	jobs.Pause(jobKey(media.JobHLS, "b.mkv"))
+	jobs.Stopped(jobKey(media.JobHLS, "b.mkv"))
+	jobs.Stopped(jobKey(media.JobHLS, "b.mkv"))
+	// Outputs found ready without a conversion are not recorded.
+	jobs.Ready(jobKey(media.JobMP4, "c.mkv"))
+
+	want := []recordedConversion{{media.JobMP4, media.StateReady}, {media.JobHLS, media.StatePaused}}
+	if len(metrics.finished) != len(want) || metrics.finished[0] != want[0] || metrics.finished[1] != want[1] {
+		t.Fatalf("expected %v recorded, got %v", want, metrics.finished)
+	}
+}
+
+func TestVariantPaths_CannotCollideWithLibraryNames(t *testing.T) {
+	_, store, _ := newTestService(t, Options{})
+
+	variant := mp4Variant("shows/movie.mkv", 1, media.SubtitleSelection{Index: 0, Burn: true})
+	if variant != "shows/.variants/audio1~burn0/movie.mkv" {
+		t.Fatalf("unexpected variant path %q", variant)
+	}
+	_, variantOutput, _ := store.MP4Paths(variant)
+	for _, name := range []string{"shows/movie~audio1~burn0.mkv", "shows/movie~audio1.mkv"} {
+		if _, output, _ := store.MP4Paths(name); output == variantOutput {
+			t.Fatalf("library video %q shares the output of %q", name, variant)
+		}
+	}
+	if _, err := media.NormalizeVideoPath(variant); err == nil {
+		t.Fatalf("expected a path inside %s to be rejected as a library name", media.VariantDir)
+	}
+}
+
+func TestStartMP4_AudioTrackConvertsSeparately(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	store.writeVideo(t, "movie.mkv", 1024)
+	// Hold the only conversion slot so both jobs stay queued while inspected.
+	_ = svc.mp4Slots.acquire(context.Background(), "held", false, nil)
+
+	if _, err := svc.StartMP4(context.Background(), "movie.mkv", media.DefaultAudioTrack, media.NoSubtitles, ""); err != nil {
+		t.Fatalf("start default track: %v", err)
+	}
+	status, err := svc.StartMP4(context.Background(), "movie.mkv", 1, media.NoSubtitles, "")
+	if err != nil {
+		t.Fatalf("start track 1: %v", err)
+	}
+	if status.URL != "/api/stream-mp4/movie.mkv?audio=1" {
+		t.Fatalf("expected track-specific stream URL, got %q", status.URL)
+	}
+	if !svc.jobs.IsRunning(jobKey(media.JobMP4, "movie.mkv")) || !svc.jobs.IsRunning(jobKey(media.JobMP4, media.AudioVariantPath("movie.mkv", 1))) {
+		t.Fatalf("expected one job per audio track, got %+v", svc.ActiveJobs())
+	}
+	svc.mp4Slots.release("held")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		converter.mu.Lock()
+		tracks := append([]int(nil), converter.mp4Audio...)
+		converter.mu.Unlock()
+		if len(tracks) == 2 {
+			if tracks[0] == tracks[1] || (tracks[0] != 1 && tracks[1] != 1) {
+				t.Fatalf("expected default and explicit tracks, got %v", tracks)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected two conversions, got %v", tracks)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStartHLS_ReportsProgress(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	converter.hlsRelease = make(chan struct{})
+	store.writeVideo(t, "movie.mkv", 1024)
+
+	if _, err := svc.StartHLS(context.Background(), "movie.mkv", false, "", media.DefaultAudioTrack, ""); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		status, err := svc.HLSStatus("movie.mkv", "", media.DefaultAudioTrack)
+		if err != nil {
+			t.Fatalf("status: %v", err)
+		}
+		if status.Processing && status.Progress == 40 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected reported progress, got %+v", status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	close(converter.hlsRelease)
+	key := jobKey(media.JobHLS, "movie.mkv")
+	waitForJobState(t, svc, key, media.StateReady)
+	if _, _, progress := svc.jobs.Status(key); progress != 100 {
+		t.Fatalf("expected finished job at 100%%, got %d", progress)
+	}
+}
+
+func TestThumbnailPrewarm_SkipsFreshThumbnails(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{ThumbnailPrewarm: true})
+	full := store.writeVideo(t, "movie.mkv", 1024)
+	info, _ := os.Stat(full)
+
+	if !svc.needsThumbnailPrewarm("movie.mkv", info.ModTime()) {
+		t.Fatalf("expected missing thumbnail to need prewarm")
+	}
+	if err := svc.prewarmThumbnail(context.Background(), "movie.mkv"); err != nil {
+		t.Fatalf("expected thumbnail generation to succeed, got %v", err)
+	}
+	if svc.needsThumbnailPrewarm("movie.mkv", info.ModTime()) {
+		t.Fatalf("expected cached thumbnail to be skipped")
+	}
+	if err := svc.prewarmThumbnail(context.Background(), "movie.mkv"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(converter.thumbnails) != 1 {
+		t.Fatalf("expected a single ffmpeg run, got %d", len(converter.thumbnails))
+	}
+
+	modified := info.ModTime().Add(time.Minute)
+	if err := os.Chtimes(full, modified, modified); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if !svc.needsThumbnailPrewarm("movie.mkv", modified) {
+		t.Fatalf("expected thumbnail to be regenerated after source changed")
+	}
+}
+
+func TestThumbnail_GeneratesOnceOnDemand(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	store.writeVideo(t, "movie.mkv", 1024)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			thumbPath, err := svc.Thumbnail(context.Background(), "movie.mkv")
+			if err != nil || thumbPath != store.ThumbnailPath("movie.mkv") {
+				t.Errorf("thumbnail: %q, %v", thumbPath, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(converter.thumbnails) != 1 {
+		t.Fatalf("expected a single ffmpeg run for concurrent requests, got %d", len(converter.thumbnails))
+	}
+	if _, err := svc.Thumbnail(context.Background(), "missing.mkv"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected missing video to report not exist, got %v", err)
+	}
+}
+
+func TestThumbnailPrewarm_DisabledByDefault(t *testing.T) {
+	svc, store, _ := newTestService(t, Options{})
+	full := store.writeVideo(t, "movie.mkv", 1024)
+	info, _ := os.Stat(full)
+
+	if svc.needsThumbnailPrewarm("movie.mkv", info.ModTime()) {
+		t.Fatalf("expected thumbnail prewarm to be disabled")
+	}
+}
+
+func TestArtifactStatuses_MixedStates(t *testing.T) {
+	svc, store, _ := newTestService(t, Options{})
+	for _, name := range []string{"hls.mkv", "mp4.mkv", "busy.mkv", "idle.mkv"} {
+		store.writeVideo(t, name, 1024)
+	}
+
+	hlsDir, playlist, _ := store.HLSPaths("hls.mkv", media.HLSFormatTS)
+	if err := svc.prepareHLSOutput(hlsDir); err != nil {
+		t.Fatalf("prepare hls: %v", err)
+	}
+	for name, data := range map[string]string{playlist: "#EXTM3U\n#EXTINF:6.0,\nsegment00000.ts\n", filepath.Join(hlsDir, "segment00000.ts"): "ts"} {
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatalf("write hls: %v", err)
+		}
+	}
+	writeMP4Output(t, store, "mp4.mkv", 64*1024)
+	svc.jobs.Start(jobKey(media.JobMP4, "busy.mkv"))
+
+	statuses := svc.ArtifactStatuses([]string{"hls.mkv", "mp4.mkv", "busy.mkv", "idle.mkv", "notes.txt"})
+	want := []media.ArtifactStatus{
+		{Path: "hls.mkv", HLSReady: true},
+		{Path: "mp4.mkv", MP4Ready: true},
+		{Path: "busy.mkv", MP4Processing: true},
+		{Path: "idle.mkv"},
+	}
+	if len(statuses) != 5 {
+		t.Fatalf("expected 5 entries, got %d", len(statuses))
+	}
+	for i, expected := range want {
+		if statuses[i] != expected {
+			t.Fatalf("entry %d: expected %+v, got %+v", i, expected, statuses[i])
+		}
+	}
+	if invalid := statuses[4]; invalid.Path != "notes.txt" || invalid.Error == "" {
+		t.Fatalf("expected error entry for invalid path, got %+v", invalid)
+	}
+}
+
+func TestHLSReady_WaitsForLastSegmentRename(t *testing.T) {
+	svc, store, _ := newTestService(t, Options{})
+	outputDir, playlist, _ := store.HLSPaths("movie.mkv", media.HLSFormatTS)
+	if err := svc.prepareHLSOutput(outputDir); err != nil {
+		t.Fatalf("prepare hls: %v", err)
+	}
+
+	write := func(name, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(outputDir, name), []byte(data), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	write("segment00000.ts", "ts")
+	write("segment00001.ts.tmp", "ts")
+	write("index.m3u8", "#EXTM3U\n#EXTINF:6.0,\nsegment00000.ts\n#EXTINF:4.0,\nsegment00001.ts\n#EXT-X-ENDLIST\n")
+
+	if ready, _ := hlsReady(outputDir, playlist, "test", media.HLSFormatTS); ready {
+		t.Fatalf("expected playlist referencing a temp segment to be not ready")
+	}
+
+	if err := os.Rename(filepath.Join(outputDir, "segment00001.ts.tmp"), filepath.Join(outputDir, "segment00001.ts")); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if ready, segments := hlsReady(outputDir, playlist, "test", media.HLSFormatTS); !ready || segments != 2 {
+		t.Fatalf("expected ready with 2 segments, got ready=%v segments=%d", ready, segments)
+	}
+}
+
+func TestHLSReady_FMP4NeedsInitSegment(t *testing.T) {
+	svc, store, _ := newTestService(t, Options{})
+	store.writeVideo(t, "movie.mkv", 1024)
+	outputDir, playlist, url := store.HLSPaths("movie.mkv", media.HLSFormatFMP4)
+	if err := svc.prepareHLSOutput(outputDir); err != nil {
+		t.Fatalf("prepare hls: %v", err)
+	}
+
+	write := func(name, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(outputDir, name), []byte(data), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	write("segment00000.m4s", "moof")
+	write("index.m3u8", "#EXTM3U\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:6.0,\nsegment00000.m4s\n#EXT-X-ENDLIST\n")
+
+	if ready, _ := hlsReady(outputDir, playlist, "test", media.HLSFormatFMP4); ready {
+		t.Fatalf("expected fMP4 rendition without init segment to be not ready")
+	}
+
+	write(fmp4InitFile, "ftyp")
+	if ready, segments := hlsReady(outputDir, playlist, "test", media.HLSFormatFMP4); !ready || segments != 1 {
+		t.Fatalf("expected ready with 1 segment, got ready=%v segments=%d", ready, segments)
+	}
+	if ready, _ := hlsReady(outputDir, playlist, "test", media.HLSFormatTS); ready {
+		t.Fatalf("expected fMP4 segments not to satisfy the TS layout")
+	}
+
+	status, err := svc.HLSStatus("movie.mkv", media.HLSFormatFMP4, media.DefaultAudioTrack)
+	if err != nil || !status.Ready || status.URL != url {
+		t.Fatalf("expected fMP4 status ready at %q, got %+v (%v)", url, status, err)
+	}
+	if status, _ := svc.HLSStatus("movie.mkv", "", media.DefaultAudioTrack); status.Ready {
+		t.Fatalf("expected default TS rendition to be unaffected, got %+v", status)
+	}
+}
+
+func TestHLSReady_MasterPlaylistNeedsEveryVariant(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{HLSRenditions: media.DefaultRenditions[1:3]})
+	outputDir, playlist, _ := store.HLSPaths("movie.mkv", media.HLSFormatTS)
+	if err := svc.prepareHLSOutput(outputDir); err != nil {
+		t.Fatalf("prepare hls: %v", err)
+	}
+
+	write := func(name, data string) {
+		t.Helper()
+		path := filepath.Join(outputDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	write("index.m3u8", "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=3100000\n720p/index.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=1600000\n480p/index.m3u8\n")
+	write("720p/segment00000.ts", "ts")
+	write("720p/segment00001.ts", "ts")
+	write("720p/index.m3u8", "#EXTM3U\n#EXTINF:6.0,\nsegment00000.ts\n#EXTINF:6.0,\nsegment00001.ts\n#EXT-X-ENDLIST\n")
+
+	if ready, _ := hlsReady(outputDir, playlist, "test", media.HLSFormatTS); ready {
+		t.Fatalf("expected master with a missing variant to be not ready")
+	}
+
+	write("480p/segment00000.ts", "ts")
+	write("480p/index.m3u8", "#EXTM3U\n#EXTINF:6.0,\nsegment00000.ts\n")
+	if ready, segments := hlsReady(outputDir, playlist, "test", media.HLSFormatTS); !ready || segments != 1 {
+		t.Fatalf("expected ready with the shortest variant's 1 segment, got ready=%v segments=%d", ready, segments)
+	}
+
+	write("index.m3u8", "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\n../other/index.m3u8\n")
+	if ready, _ := hlsReady(outputDir, playlist, "test", media.HLSFormatTS); ready {
+		t.Fatalf("expected variants outside the output to be rejected")
+	}
+
+	_ = os.RemoveAll(outputDir)
+	store.writeVideo(t, "movie.mkv", 1024)
+	if _, err := svc.StartHLS(context.Background(), "movie.mkv", false, "", media.DefaultAudioTrack, ""); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	waitForJobState(t, svc, jobKey(media.JobHLS, "movie.mkv"), media.StateReady)
+	if got := converter.ladders; len(got) != 1 || len(got[0]) != 2 || got[0][0].Height != 720 || converter.hlsCalls != 0 {
+		t.Fatalf("expected the configured ladder, got %+v", got)
+	}
+}
+
+func TestStartConversions_RefuseWithoutFreeSpace(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{MinFreeBytes: 1000})
+	store.writeVideo(t, "movie.mkv", 4096)
+	store.free = 4096 + 999
+
+	if _, err := svc.StartMP4(context.Background(), "movie.mkv", media.DefaultAudioTrack, media.NoSubtitles, ""); !errors.Is(err, ErrInsufficientSpace) {
+		t.Fatalf("expected MP4 start refused, got %v", err)
+	}
+	if _, err := svc.StartHLS(context.Background(), "movie.mkv", false, "", media.DefaultAudioTrack, ""); !errors.Is(err, ErrInsufficientSpace) {
+		t.Fatalf("expected HLS start refused, got %v", err)
+	}
+	outputDir, _, _ := store.HLSPaths("movie.mkv", media.HLSFormatTS)
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) || converter.hlsCalls != 0 {
+		t.Fatalf("expected no output prepared and no conversion, got %v (%d calls)", err, converter.hlsCalls)
+	}
+
+	store.free = 4096 + 1000
+	if _, err := svc.StartHLS(context.Background(), "movie.mkv", false, "", media.DefaultAudioTrack, ""); err != nil {
+		t.Fatalf("expected HLS start with exactly the reserve free, got %v", err)
+	}
+	waitForJobState(t, svc, jobKey(media.JobHLS, "movie.mkv"), media.StateReady)
+}
+
+func TestWithTiming_EstimatesRemainingTime(t *testing.T) {
+	svc, _, _ := newTestService(t, Options{})
+	key := jobKey(media.JobMP4, "movie.mkv")
+
+	if status := svc.withTiming(key, media.JobStatus{State: media.StateIdle}); !status.StartedAt.IsZero() || status.ETAKnown {
+		t.Fatalf("expected no timing without a job, got %+v", status)
+	}
+
+	svc.jobs.Start(key)
+	svc.jobs.mu.Lock()
+	svc.jobs.jobs[key].startedAt = time.Now().Add(-10 * time.Second)
+	svc.jobs.mu.Unlock()
+
+	status := svc.withTiming(key, media.JobStatus{State: media.StateProcessing})
+	if status.StartedAt.IsZero() || status.Elapsed < 10*time.Second || status.ETAKnown {
+		t.Fatalf("expected elapsed time but no ETA before progress, got %+v", status)
+	}
+
+	status = svc.withTiming(key, media.JobStatus{State: media.StateProcessing, Progress: 25})
+	if !status.ETAKnown || status.ETA < 30*time.Second || status.ETA > 31*time.Second {
+		t.Fatalf("expected ~30s remaining at 25%% after 10s, got %+v", status)
+	}
+
+	svc.jobs.Ready(key)
+	first := svc.withTiming(key, media.JobStatus{State: media.StateReady, Progress: 100})
+	time.Sleep(5 * time.Millisecond)
+	second := svc.withTiming(key, media.JobStatus{State: media.StateReady, Progress: 100})
+	if !first.ETAKnown || first.ETA != 0 || first.Elapsed != second.Elapsed {
+		t.Fatalf("expected finished job to have no ETA and a fixed elapsed time, got %+v / %+v", first, second)
+	}
+}
@@ -0,0 +1,64 @@
+package media
+
+import (
+	"context"
+	"time"
+)
+
+// Shutdown waits for running conversions to finish until ctx is done, then stops
+// the rest and waits for their ffmpeg processes to exit. Stopped conversions
+// start over when requested again. Thumbnail prewarm extractions, whose workers
+// end with the context passed to StartPrewarm, are drained the same way. It
+// returns how many conversions finished and how many were stopped.
+func (s *Service) Shutdown(ctx context.Context) (finished, stopped int) {
+	defer s.drainThumbnailPrewarm(ctx)
+
+	running := s.jobs.Running()
+	for key, done := range running {
+		select {
+		case <-done:
+			finished++
+			delete(running, key)
+		case <-ctx.Done():
+		}
+	}
+
+	for key, done := range running {
+		select {
+		case <-done:
+			finished++
+			continue
+		default:
+		}
+		s.jobs.Pause(key)
+		select {
+		case <-done:
+		case <-time.After(jobStopTimeout):
+			s.logger.Printf("Conversion did not stop in time: %s", key)
+		}
+		stopped++
+	}
+	return finished, stopped
+}
+
+// drainThumbnailPrewarm waits for thumbnail prewarm workers to exit until ctx is
+// done, then cancels their extractions and waits for ffmpeg to exit.
+func (s *Service) drainThumbnailPrewarm(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.thumbWorkers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+	s.stopThumbs()
+	select {
+	case <-done:
+	case <-time.After(jobStopTimeout):
+		s.logger.Printf("Thumbnail prewarm did not stop in time")
+	}
+}
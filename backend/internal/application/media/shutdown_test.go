@@ -0,0 +1,108 @@
+package media
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+func TestShutdown_StopsConversionsStillRunningAtDeadline(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	converter.hlsRelease = make(chan struct{})
+	defer close(converter.hlsRelease)
+	store.writeVideo(t, "movie.mkv", 1024)
+
+	if _, err := svc.StartHLS(context.Background(), "movie.mkv", false, "", media.DefaultAudioTrack, ""); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	key := jobKey(media.JobHLS, "movie.mkv")
+	waitForJobState(t, svc, key, media.StateProcessing)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	finished, stopped := svc.Shutdown(ctx)
+	if finished != 0 || stopped != 1 {
+		t.Fatalf("expected one stopped conversion, got finished=%d stopped=%d", finished, stopped)
+	}
+	if svc.jobs.IsRunning(key) {
+		t.Fatalf("expected the conversion to be stopped")
+	}
+}
+
+func TestShutdown_WaitsForConversionsToFinish(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	converter.hlsRelease = make(chan struct{})
+	store.writeVideo(t, "movie.mkv", 1024)
+
+	if _, err := svc.StartHLS(context.Background(), "movie.mkv", false, "", media.DefaultAudioTrack, ""); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	close(converter.hlsRelease)
+
+	finished, stopped := svc.Shutdown(context.Background())
+	if finished != 1 || stopped != 0 {
+		t.Fatalf("expected one finished conversion, got finished=%d stopped=%d", finished, stopped)
+	}
+	waitForJobState(t, svc, jobKey(media.JobHLS, "movie.mkv"), media.StateReady)
+}
+
+// startThumbnailPrewarm runs one thumbnail prewarm worker under workerCtx and
+// waits until it is extracting relPath.
+func startThumbnailPrewarm(t *testing.T, svc *Service, converter *fakeConverter, workerCtx context.Context, relPath string) {
+	t.Helper()
+	svc.thumbWorkers.Add(1)
+	go svc.runThumbnailPrewarmWorker(workerCtx)
+	svc.thumbQueue.push(relPath)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		converter.mu.Lock()
+		started := len(converter.thumbnails) > 0
+		converter.mu.Unlock()
+		if started {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("thumbnail extraction did not start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestShutdown_DrainsThumbnailPrewarm(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	converter.thumbRelease = make(chan struct{})
+	store.writeVideo(t, "movie.mkv", 1024)
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	startThumbnailPrewarm(t, svc, converter, workerCtx, "movie.mkv")
+	// The signal context ends while the extraction is still running.
+	stopWorkers()
+	time.AfterFunc(50*time.Millisecond, func() { close(converter.thumbRelease) })
+
+	svc.Shutdown(context.Background())
+	if _, err := os.Stat(store.ThumbnailPath("movie.mkv")); err != nil {
+		t.Fatalf("expected the running extraction to finish: %v", err)
+	}
+}
+
+func TestShutdown_StopsThumbnailPrewarmAtDeadline(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	converter.thumbRelease = make(chan struct{})
+	defer close(converter.thumbRelease)
+	store.writeVideo(t, "movie.mkv", 1024)
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	startThumbnailPrewarm(t, svc, converter, workerCtx, "movie.mkv")
+	stopWorkers()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	svc.Shutdown(ctx)
+	if _, err := os.Stat(store.ThumbnailPath("movie.mkv")); !os.IsNotExist(err) {
+		t.Fatalf("expected the extraction to be stopped, got %v", err)
+	}
+}
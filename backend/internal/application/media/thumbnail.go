@@ -0,0 +1,147 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+const (
+	// thumbnailMarkerSuffix names the sidecar that records which source revision a thumbnail was cut from.
+	thumbnailMarkerSuffix   = ".src"
+	thumbnailPositionRatio  = 0.1
+	thumbnailFallbackOffset = 1.0
+	// thumbnailTimeout bounds on-demand extraction, which outlives the request that started it.
+	thumbnailTimeout = time.Minute
+)
+
+// ErrThumbnailUnavailable reports a source whose poster frame could not be extracted.
+var ErrThumbnailUnavailable = errors.New("thumbnail unavailable")
+
+// Thumbnail returns the poster thumbnail path for a video, extracting it on first
+// use. Sources that already failed extraction are not retried until they change.
+func (s *Service) Thumbnail(ctx context.Context, rawPath string) (string, error) {
+	rel, _, err := s.store.ResolveVideoPath(rawPath)
+	if err != nil {
+		return "", err
+	}
+
+	// A client hanging up should not waste an extraction another request may need.
+	genCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), thumbnailTimeout)
+	defer cancel()
+	if err := s.generateThumbnail(genCtx, rel); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		return "", fmt.Errorf("%w: %v", ErrThumbnailUnavailable, err)
+	}
+	return s.store.ThumbnailPath(rel), nil
+}
+
+func (s *Service) prewarmThumbnail(ctx context.Context, relPath string) error {
+	rel, _, err := s.store.ResolveVideoPath(relPath)
+	if err != nil {
+		return err
+	}
+	return s.generateThumbnail(ctx, rel)
+}
+
+// generateThumbnail extracts the thumbnail for rel once, with concurrent callers
+// for the same video waiting for the running extraction.
+func (s *Service) generateThumbnail(ctx context.Context, rel string) error {
+	for {
+		s.prewarmMu.Lock()
+		busy, running := s.thumbBusy[rel]
+		if !running {
+			busy = make(chan struct{})
+			s.thumbBusy[rel] = busy
+		}
+		s.prewarmMu.Unlock()
+		if !running {
+			break
+		}
+		select {
+		case <-busy:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	defer func() {
+		s.prewarmMu.Lock()
+		close(s.thumbBusy[rel])
+		delete(s.thumbBusy, rel)
+		s.prewarmMu.Unlock()
+	}()
+
+	_, full, err := s.store.ResolveVideoPath(rel)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(full)
+	if err != nil {
+		return err
+	}
+	thumbPath := s.store.ThumbnailPath(rel)
+	if thumbnailFresh(thumbPath, info.ModTime()) {
+		return nil
+	}
+
+	s.prewarmMu.Lock()
+	failedAt, failed := s.thumbFailed[rel]
+	s.prewarmMu.Unlock()
+	if failed && failedAt.Equal(info.ModTime()) {
+		return errors.New("extraction failed for this revision")
+	}
+
+	s.recordOutputName(rel)
+	if err := s.ensureThumbnail(ctx, full, thumbPath, info.ModTime()); err != nil {
+		if ctx.Err() == nil && !errors.Is(err, media.ErrConversionStopped) {
+			s.prewarmMu.Lock()
+			s.thumbFailed[rel] = info.ModTime()
+			s.prewarmMu.Unlock()
+		}
+		return err
+	}
+	return nil
+}
+
+// ensureThumbnail extracts a poster frame unless one already exists for the source revision.
+func (s *Service) ensureThumbnail(ctx context.Context, fullPath, thumbPath string, modifiedAt time.Time) error {
+	if thumbnailFresh(thumbPath, modifiedAt) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(thumbPath), 0o755); err != nil {
+		return err
+	}
+
+	offset := thumbnailFallbackOffset
+	if duration, err := s.converter.ProbeDuration(ctx, fullPath); err == nil && duration > 0 {
+		offset = duration * thumbnailPositionRatio
+	}
+
+	if err := s.converter.ExtractThumbnail(ctx, fullPath, thumbPath, offset); err != nil {
+		return err
+	}
+
+	marker := strconv.FormatInt(modifiedAt.UnixNano(), 10)
+	return os.WriteFile(thumbPath+thumbnailMarkerSuffix, []byte(marker), 0o644)
+}
+
+func thumbnailFresh(thumbPath string, modifiedAt time.Time) bool {
+	if _, err := os.Stat(thumbPath); err != nil {
+		return false
+	}
+	data, err := os.ReadFile(thumbPath + thumbnailMarkerSuffix)
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(data)) == strconv.FormatInt(modifiedAt.UnixNano(), 10)
+}
@@ -0,0 +1,78 @@
+package media
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+const defaultTrashSweepInterval = time.Hour
+
+// DeleteVideo moves a library video into the trash, from where it can be
+// restored until the retention runs out. Its HLS, MP4 and thumbnail outputs are
+// deleted. Nothing is deleted while a conversion of the video is running.
+func (s *Service) DeleteVideo(rawPath string) (string, error) {
+	rel, full, err := s.store.ResolveVideoPath(rawPath)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(full); err != nil {
+		return "", err
+	}
+
+	hlsVariants, mp4Variants := s.artifactVariants(rel)
+	for _, key := range artifactJobKeys(hlsVariants, mp4Variants) {
+		if s.jobs.IsRunning(key) {
+			return "", ErrJobRunning
+		}
+	}
+	name, err := s.store.TrashVideo(rel)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.removeArtifacts(hlsVariants, mp4Variants); err != nil {
+		s.logger.Printf("Artifacts kept after delete: %s: %v", rel, err)
+	}
+	_ = os.Remove(s.store.ThumbnailPath(rel))
+
+	s.logger.Printf("Video trashed: %s -> %s", rel, name)
+	return name, nil
+}
+
+// StartTrashSweeper periodically deletes videos trashed longer than
+// Options.TrashRetention ago. It does nothing without a retention.
+func (s *Service) StartTrashSweeper(ctx context.Context, interval time.Duration) {
+	if s.trashRetention <= 0 {
+		return
+	}
+	if interval <= 0 {
+		interval = defaultTrashSweepInterval
+	}
+
+	s.trashOnce.Do(func() {
+		s.logger.Printf("Trash auto-empty enabled: retention=%s interval=%s", s.trashRetention, interval)
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				removed, err := s.store.EmptyTrash(s.trashRetention)
+				if err != nil {
+					s.logger.Printf("Trash sweep failed: %v", err)
+				}
+				if len(removed) > 0 {
+					s.logger.Printf("Trash sweep removed %d videos", len(removed))
+					if s.trashEmptied != nil {
+						s.trashEmptied(removed)
+					}
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	})
+}
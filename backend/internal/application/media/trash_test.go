@@ -0,0 +1,31 @@
+package media
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeleteVideo_TrashesSourceAndDropsOutputs(t *testing.T) {
+	svc, store, converter := newTestService(t, Options{})
+	full := store.writeVideo(t, "movie.mkv", 1024)
+	converter.durations[full] = 60
+	_, mp4Path := writeMP4Output(t, store, "movie.mkv", 64)
+
+	name, err := svc.DeleteVideo("movie.mkv")
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(store.videosDir, filepath.FromSlash(name))); err != nil {
+		t.Fatalf("expected source in trash: %v", err)
+	}
+	for _, path := range []string{full, mp4Path} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected %s gone after delete, got %v", path, err)
+		}
+	}
+
+	if _, err := svc.DeleteVideo("movie.mkv"); !os.IsNotExist(err) {
+		t.Fatalf("expected deleting again to report not found, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"evd/internal/domain/media"
+)
+
+// hlsTrimFile records the first media sequence number still on disk after a trim.
+const hlsTrimFile = ".trimmed"
+
+var (
+	ErrTrimDisabled = errors.New("HLS trimming is disabled")
+	ErrTrimNotReady = errors.New("HLS output is not complete")
+	ErrInvalidTrim  = errors.New("invalid trim range")
+)
+
+// TrimHLS deletes the segments of a finished HLS output that come before the
+// segment with media sequence number before, and rewrites the playlist so the
+// remaining tail stays playable. It is irreversible for the output on disk; the
+// full rendition only comes back by converting the source again.
+// It returns the number of segments removed.
+func (s *Service) TrimHLS(rawPath string, format media.HLSFormat, before int) (int, error) {
+	if !s.hlsTrimEnabled {
+		return 0, ErrTrimDisabled
+	}
+	rel, _, err := s.store.ResolveVideoPath(rawPath)
+	if err != nil {
+		return 0, err
+	}
+
+	format = s.resolveHLSFormat(format)
+	outputDir, playlist, _ := s.store.HLSPaths(rel, format)
+	key := jobKey(hlsJobType(format), rel)
+	if s.jobs.IsRunning(key) {
+		return 0, ErrTrimNotReady
+	}
+	if ready, _ := hlsReady(outputDir, playlist, s.converter.HLSMarkerVersion(), format); !ready {
+		return 0, ErrTrimNotReady
+	}
+
+	if _, master := masterVariants(outputDir, playlist); master {
+		return 0, fmt.Errorf("%w: adaptive outputs cannot be trimmed", ErrInvalidTrim)
+	}
+	if trimmed, ok := readHLSTrim(outputDir); ok && before <= trimmed {
+		// An earlier trim already went at least this far.
+		return 0, nil
+	}
+
+	data, err := os.ReadFile(playlist)
+	if err != nil {
+		return 0, err
+	}
+	lines, removed, err := trimPlaylist(strings.Split(string(data), "\n"), before)
+	if err != nil || len(removed) == 0 {
+		return 0, err
+	}
+	for _, line := range lines {
+		if line != "" && !strings.HasPrefix(line, "#") && !segmentComplete(outputDir, line) {
+			return 0, fmt.Errorf("%w: segment %s is missing", ErrTrimNotReady, line)
+		}
+	}
+
+	// The playlist stops referencing the segments before any of them disappear.
+	if err := rewritePlaylist(playlist, lines); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, hlsTrimFile), []byte(strconv.Itoa(before)), 0o644); err != nil {
+		return 0, err
+	}
+	for _, name := range removed {
+		if strings.Contains(name, "..") || filepath.IsAbs(name) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(outputDir, filepath.FromSlash(name))); err != nil && !os.IsNotExist(err) {
+			s.logger.Printf("HLS trim could not remove %s: %v", name, err)
+		}
+	}
+
+	s.logger.Printf("HLS output trimmed: %s before segment %d (%d removed)", rel, before, len(removed))
+	return len(removed), nil
+}
+
+// readHLSTrim returns the first segment kept by the last trim of outputDir.
+func readHLSTrim(outputDir string) (int, bool) {
+	raw, err := os.ReadFile(filepath.Join(outputDir, hlsTrimFile))
+	if err != nil {
+		return 0, false
+	}
+	before, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	return before, err == nil && before > 0
+}
+
+// trimPlaylist drops the media segments numbered below before and returns the
+// rewritten playlist with the URIs it dropped. The media sequence moves to before
+// and the playlist type tag goes away, since EVENT and VOD lists may not lose
+// segments. At least one segment must remain.
+func trimPlaylist(lines []string, before int) ([]string, []string, error) {
+	var (
+		header   []string
+		segments [][]string
+		pending  []string
+		sequence int
+		ended    bool
+	)
+	for _, raw := range lines {
+		line := strings.TrimSpace(raw)
+		switch {
+		case line == "":
+			continue
+		case line == "#EXT-X-ENDLIST":
+			ended = true
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			value, err := strconv.Atoi(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"))
+			if err != nil || value < 0 {
+				return nil, nil, ErrInvalidTrim
+			}
+			sequence = value
+		case strings.HasPrefix(line, "#EXT-X-PLAYLIST-TYPE:"):
+			continue
+		case strings.HasPrefix(line, "#"):
+			if len(segments) == 0 && len(pending) == 0 && !strings.HasPrefix(line, "#EXTINF:") {
+				header = append(header, line)
+			} else {
+				pending = append(pending, line)
+			}
+		default:
+			segments = append(segments, append(pending, line))
+			pending = nil
+		}
+	}
+
+	drop := before - sequence
+	if drop <= 0 {
+		return nil, nil, nil
+	}
+	if drop >= len(segments) {
+		return nil, nil, ErrInvalidTrim
+	}
+
+	removed := make([]string, 0, drop)
+	for _, segment := range segments[:drop] {
+		removed = append(removed, segment[len(segment)-1])
+	}
+
+	out := append(header, "#EXT-X-MEDIA-SEQUENCE:"+strconv.Itoa(before))
+	for _, segment := range segments[drop:] {
+		out = append(out, segment...)
+	}
+	out = append(out, pending...)
+	if ended {
+		out = append(out, "#EXT-X-ENDLIST")
+	}
+	return out, removed, nil
+}
@@ -0,0 +1,93 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"evd/internal/domain/media"
+)
+
+func writeHLSOutput(t *testing.T, store *fakeStore, relPath string, segments int) (string, string) {
+	t.Helper()
+	outputDir, playlist, _ := store.HLSPaths(relPath, media.HLSFormatTS)
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	lines := []string{"#EXTM3U", "#EXT-X-VERSION:3", "#EXT-X-TARGETDURATION:6", "#EXT-X-MEDIA-SEQUENCE:0", "#EXT-X-PLAYLIST-TYPE:EVENT"}
+	for i := 0; i < segments; i++ {
+		name := fmt.Sprintf("segment%05d.ts", i)
+		if err := os.WriteFile(filepath.Join(outputDir, name), []byte("ts"), 0o644); err != nil {
+			t.Fatalf("write segment: %v", err)
+		}
+		lines = append(lines, "#EXTINF:6.000000,", name)
+	}
+	lines = append(lines, "#EXT-X-ENDLIST")
+	if err := os.WriteFile(playlist, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatalf("write playlist: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, hlsMarkerFile), []byte("test"), 0o644); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+	return outputDir, playlist
+}
+
+func TestTrimHLS_DropsLeadingSegments(t *testing.T) {
+	svc, store, _ := newTestService(t, Options{HLSTrimEnabled: true})
+	store.writeVideo(t, "movie.mkv", 1024)
+	outputDir, playlist := writeHLSOutput(t, store, "movie.mkv", 5)
+
+	removed, err := svc.TrimHLS("movie.mkv", "", 3)
+	if err != nil || removed != 3 {
+		t.Fatalf("expected 3 segments trimmed, got %d, %v", removed, err)
+	}
+
+	for i := 0; i < 5; i++ {
+		_, err := os.Stat(filepath.Join(outputDir, fmt.Sprintf("segment%05d.ts", i)))
+		if gone := errors.Is(err, os.ErrNotExist); gone != (i < 3) {
+			t.Fatalf("segment %d: unexpected presence (err=%v)", i, err)
+		}
+	}
+
+	data, _ := os.ReadFile(playlist)
+	text := string(data)
+	if !strings.Contains(text, "#EXT-X-MEDIA-SEQUENCE:3\n#EXTINF:6.000000,\nsegment00003.ts") {
+		t.Fatalf("expected playlist to start at segment 3, got:\n%s", text)
+	}
+	if strings.Contains(text, "segment00002.ts") || strings.Contains(text, "PLAYLIST-TYPE") || !strings.HasSuffix(text, "#EXT-X-ENDLIST\n") {
+		t.Fatalf("unexpected trimmed playlist:\n%s", text)
+	}
+	if status, _ := svc.HLSStatus("movie.mkv", "", media.DefaultAudioTrack); !status.Ready {
+		t.Fatalf("expected trimmed output to stay ready, got %+v", status)
+	}
+
+	if removed, err := svc.TrimHLS("movie.mkv", "", 2); err != nil || removed != 0 {
+		t.Fatalf("expected trimming an already trimmed range to be a no-op, got %d, %v", removed, err)
+	}
+	// The marker alone decides that a repeat trim has nothing to do.
+	if err := os.WriteFile(filepath.Join(outputDir, hlsTrimFile), []byte("4"), 0o644); err != nil {
+		t.Fatalf("write trim marker: %v", err)
+	}
+	if removed, err := svc.TrimHLS("movie.mkv", "", 4); err != nil || removed != 0 {
+		t.Fatalf("expected a trim covered by the marker to be skipped, got %d, %v", removed, err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "segment00003.ts")); err != nil {
+		t.Fatalf("expected segment 3 kept when the trim is skipped: %v", err)
+	}
+	if _, err := svc.TrimHLS("movie.mkv", "", 5); !errors.Is(err, ErrInvalidTrim) {
+		t.Fatalf("expected trimming every segment to be rejected, got %v", err)
+	}
+}
+
+func TestTrimHLS_DisabledByDefault(t *testing.T) {
+	svc, store, _ := newTestService(t, Options{})
+	store.writeVideo(t, "movie.mkv", 1024)
+	writeHLSOutput(t, store, "movie.mkv", 2)
+
+	if _, err := svc.TrimHLS("movie.mkv", "", 1); !errors.Is(err, ErrTrimDisabled) {
+		t.Fatalf("expected trimming to be disabled, got %v", err)
+	}
+}
@@ -0,0 +1,2 @@
+// Package progress remembers where each user stopped watching each video.
+package progress
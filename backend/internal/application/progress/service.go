@@ -0,0 +1,333 @@
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFlushInterval = 30 * time.Second
+
+	// watchedFraction is how far into a video playback counts as finished.
+	watchedFraction = 0.95
+	// maxEntriesPerUser bounds each user's list; the oldest entries go first.
+	maxEntriesPerUser = 100
+	// maxWatchedPerUser bounds each user's watched set; the oldest go first.
+	maxWatchedPerUser = 1000
+)
+
+var ErrInvalidPosition = errors.New("invalid playback position")
+
+// Entry is a video a user stopped part-way through.
+type Entry struct {
+	Path      string  `json:"path"`
+	Position  float64 `json:"position"`
+	Duration  float64 `json:"duration"`
+	UpdatedAt int64   `json:"updatedAt"`
+}
+
+// Status is what a user's library listing shows about one video.
+type Status struct {
+	PositionSeconds float64
+	Watched         bool
+}
+
+type state struct {
+	// Users maps user IDs to their entries keyed by library path.
+	Users map[string]map[string]Entry `json:"users"`
+	// Watched maps user IDs to the library paths they finished and when.
+	Watched map[string]map[string]int64 `json:"watched,omitempty"`
+}
+
+// Service keeps playback positions in memory and persists them to a JSON file.
+// Heartbeats only mark the state dirty; the flusher writes it.
+type Service struct {
+	mu    sync.Mutex
+	file  string
+	state state
+	dirty bool
+	now   func() time.Time
+
+	flushOnce sync.Once
+}
+
+// NewService creates a progress service and loads persisted positions from file.
+// An empty file keeps positions in memory only.
+func NewService(file string) (*Service, error) {
+	svc := &Service{
+		file:  strings.TrimSpace(file),
+		state: state{Users: map[string]map[string]Entry{}, Watched: map[string]map[string]int64{}},
+		now:   time.Now,
+	}
+	if err := svc.load(); err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
+
+// SavePosition records that userID reached seconds into the video at path.
+// Reaching the last 5% of a known duration marks the video watched and drops
+// its entry, so it leaves the continue-watching list. A watched video stays
+// watched when it is played again.
+func (s *Service) SavePosition(userID, path string, seconds, duration float64) error {
+	userID = strings.TrimSpace(userID)
+	path = strings.TrimSpace(path)
+	if userID == "" || path == "" || !validSeconds(seconds) || !validSeconds(duration) {
+		return ErrInvalidPosition
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := s.state.Users[userID]
+	if duration > 0 && seconds >= duration*watchedFraction {
+		delete(entries, path)
+		if len(entries) == 0 {
+			delete(s.state.Users, userID)
+		}
+		watched := s.state.Watched[userID]
+		if watched == nil {
+			watched = map[string]int64{}
+			s.state.Watched[userID] = watched
+		}
+		watched[path] = s.now().UnixMilli()
+		if len(watched) > maxWatchedPerUser {
+			oldest := ""
+			for key, at := range watched {
+				if oldest == "" || at < watched[oldest] {
+					oldest = key
+				}
+			}
+			delete(watched, oldest)
+		}
+		s.dirty = true
+		return nil
+	}
+
+	if entries == nil {
+		entries = map[string]Entry{}
+		s.state.Users[userID] = entries
+	}
+	entries[path] = Entry{
+		Path:      path,
+		Position:  seconds,
+		Duration:  duration,
+		UpdatedAt: s.now().UnixMilli(),
+	}
+	if len(entries) > maxEntriesPerUser {
+		oldest := ""
+		for key, entry := range entries {
+			if oldest == "" || entry.UpdatedAt < entries[oldest].UpdatedAt {
+				oldest = key
+			}
+		}
+		delete(entries, oldest)
+	}
+	s.dirty = true
+	return nil
+}
+
+// GetPositions returns the unfinished videos of userID, most recent first.
+func (s *Service) GetPositions(userID string) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := s.state.Users[strings.TrimSpace(userID)]
+	out := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		out = append(out, entry)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].UpdatedAt != out[j].UpdatedAt {
+			return out[i].UpdatedAt > out[j].UpdatedAt
+		}
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
+
+// Statuses returns the position and watched flag of every video userID has
+// played, keyed by library path.
+func (s *Service) Statuses(userID string) map[string]Status {
+	userID = strings.TrimSpace(userID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]Status, len(s.state.Users[userID])+len(s.state.Watched[userID]))
+	for path := range s.state.Watched[userID] {
+		out[path] = Status{Watched: true}
+	}
+	for path, entry := range s.state.Users[userID] {
+		status := out[path]
+		status.PositionSeconds = entry.Position
+		out[path] = status
+	}
+	return out
+}
+
+// MovePath re-keys every user's position and watched flag for the video at
+// from so they follow it to to.
+func (s *Service) MovePath(from, to string) {
+	from = strings.TrimSpace(from)
+	to = strings.TrimSpace(to)
+	if from == "" || to == "" || from == to {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entries := range s.state.Users {
+		if entry, ok := entries[from]; ok {
+			delete(entries, from)
+			entry.Path = to
+			entries[to] = entry
+			s.dirty = true
+		}
+	}
+	for _, watched := range s.state.Watched {
+		if at, ok := watched[from]; ok {
+			delete(watched, from)
+			watched[to] = at
+			s.dirty = true
+		}
+	}
+}
+
+// RemovePath drops every user's position and watched flag for the video at
+// path, once it has left the library.
+func (s *Service) RemovePath(path string) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for userID, entries := range s.state.Users {
+		if _, ok := entries[path]; ok {
+			delete(entries, path)
+			if len(entries) == 0 {
+				delete(s.state.Users, userID)
+			}
+			s.dirty = true
+		}
+	}
+	for userID, watched := range s.state.Watched {
+		if _, ok := watched[path]; ok {
+			delete(watched, path)
+			if len(watched) == 0 {
+				delete(s.state.Watched, userID)
+			}
+			s.dirty = true
+		}
+	}
+}
+
+// StartFlusher periodically persists positions recorded since the last write.
+func (s *Service) StartFlusher(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+
+	s.flushOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					_ = s.Flush()
+					return
+				case <-ticker.C:
+					_ = s.Flush()
+				}
+			}
+		}()
+	})
+}
+
+// Flush persists pending position changes.
+func (s *Service) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+	return s.saveLocked()
+}
+
+func validSeconds(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0) && value >= 0
+}
+
+func (s *Service) load() error {
+	if s.file == "" {
+		return nil
+	}
+
+	raw, err := os.ReadFile(s.file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var stored state
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return fmt.Errorf("decode progress file: %w", err)
+	}
+	for userID, entries := range stored.Users {
+		if len(entries) > 0 {
+			s.state.Users[userID] = entries
+		}
+	}
+	for userID, watched := range stored.Watched {
+		if len(watched) > 0 {
+			s.state.Watched[userID] = watched
+		}
+	}
+	return nil
+}
+
+func (s *Service) saveLocked() error {
+	if s.file == "" {
+		s.dirty = false
+		return nil
+	}
+
+	raw, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.file), 0o755); err != nil {
+		return err
+	}
+
+	tmpPath := s.file + ".tmp"
+	if err := os.WriteFile(tmpPath, raw, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, s.file); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
@@ -0,0 +1,165 @@
+package progress
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestService(t *testing.T, file string) *Service {
+	t.Helper()
+	svc, err := NewService(file)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	return svc
+}
+
+func TestSavePosition_PersistsPerUserAndOrdersByRecency(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "progress.json")
+	svc := newTestService(t, file)
+	now := time.Unix(1000, 0)
+	svc.now = func() time.Time { return now }
+
+	if err := svc.SavePosition("u1", "a.mkv", 120, 3600); err != nil {
+		t.Fatalf("save a: %v", err)
+	}
+	now = now.Add(time.Minute)
+	if err := svc.SavePosition("u1", "b.mkv", 30, 0); err != nil {
+		t.Fatalf("save b: %v", err)
+	}
+	if err := svc.SavePosition("u2", "a.mkv", 10, 3600); err != nil {
+		t.Fatalf("save other user: %v", err)
+	}
+	if err := svc.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	reloaded := newTestService(t, file)
+	entries := reloaded.GetPositions("u1")
+	if len(entries) != 2 || entries[0].Path != "b.mkv" || entries[1].Path != "a.mkv" || entries[1].Position != 120 {
+		t.Fatalf("unexpected positions for u1: %+v", entries)
+	}
+	if entries := reloaded.GetPositions("u2"); len(entries) != 1 || entries[0].Position != 10 {
+		t.Fatalf("unexpected positions for u2: %+v", entries)
+	}
+}
+
+func TestSavePosition_NearEndMarksWatched(t *testing.T) {
+	svc := newTestService(t, "")
+
+	if err := svc.SavePosition("u1", "a.mkv", 600, 1000); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := svc.SavePosition("u1", "a.mkv", 960, 1000); err != nil {
+		t.Fatalf("save near end: %v", err)
+	}
+	if entries := svc.GetPositions("u1"); len(entries) != 0 {
+		t.Fatalf("expected watched video to be cleared, got %+v", entries)
+	}
+}
+
+func TestSavePosition_RejectsInvalidInput(t *testing.T) {
+	svc := newTestService(t, "")
+
+	for _, tc := range []struct {
+		userID, path      string
+		seconds, duration float64
+	}{
+		{"", "a.mkv", 1, 10},
+		{"u1", " ", 1, 10},
+		{"u1", "a.mkv", -1, 10},
+		{"u1", "a.mkv", math.NaN(), 10},
+		{"u1", "a.mkv", 1, math.Inf(1)},
+	} {
+		if err := svc.SavePosition(tc.userID, tc.path, tc.seconds, tc.duration); !errors.Is(err, ErrInvalidPosition) {
+			t.Fatalf("expected ErrInvalidPosition for %+v, got %v", tc, err)
+		}
+	}
+}
+
+func TestStatuses_JoinPositionsAndWatched(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "progress.json")
+	svc := newTestService(t, file)
+
+	if err := svc.SavePosition("u1", "a.mkv", 990, 1000); err != nil {
+		t.Fatalf("finish a: %v", err)
+	}
+	if err := svc.SavePosition("u1", "b.mkv", 42, 1000); err != nil {
+		t.Fatalf("save b: %v", err)
+	}
+	// Rewatching a finished video keeps it watched and tracks the new position.
+	if err := svc.SavePosition("u1", "a.mkv", 60, 1000); err != nil {
+		t.Fatalf("rewatch a: %v", err)
+	}
+	if err := svc.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	statuses := newTestService(t, file).Statuses("u1")
+	want := map[string]Status{
+		"a.mkv": {PositionSeconds: 60, Watched: true},
+		"b.mkv": {PositionSeconds: 42},
+	}
+	if len(statuses) != len(want) {
+		t.Fatalf("expected %d statuses, got %+v", len(want), statuses)
+	}
+	for path, status := range want {
+		if statuses[path] != status {
+			t.Fatalf("status of %s = %+v, want %+v", path, statuses[path], status)
+		}
+	}
+	if statuses := svc.Statuses("u2"); len(statuses) != 0 {
+		t.Fatalf("expected no statuses for another user, got %+v", statuses)
+	}
+}
+
+func TestMoveAndRemovePath_FollowTheVideo(t *testing.T) {
+	svc := newTestService(t, "")
+
+	if err := svc.SavePosition("u1", "a.mkv", 42, 1000); err != nil {
+		t.Fatalf("save a: %v", err)
+	}
+	if err := svc.SavePosition("u2", "a.mkv", 990, 1000); err != nil {
+		t.Fatalf("finish a: %v", err)
+	}
+
+	svc.MovePath("a.mkv", "shows/b.mkv")
+	if entries := svc.GetPositions("u1"); len(entries) != 1 || entries[0].Path != "shows/b.mkv" || entries[0].Position != 42 {
+		t.Fatalf("expected position to follow the move, got %+v", entries)
+	}
+	if statuses := svc.Statuses("u2"); len(statuses) != 1 || !statuses["shows/b.mkv"].Watched {
+		t.Fatalf("expected watched flag to follow the move, got %+v", statuses)
+	}
+
+	svc.RemovePath("shows/b.mkv")
+	if statuses := svc.Statuses("u1"); len(statuses) != 0 {
+		t.Fatalf("expected deleted video dropped for u1, got %+v", statuses)
+	}
+	if statuses := svc.Statuses("u2"); len(statuses) != 0 {
+		t.Fatalf("expected deleted video dropped for u2, got %+v", statuses)
+	}
+}
+
+func TestSavePosition_BoundsWatchedPerUser(t *testing.T) {
+	svc := newTestService(t, "")
+	now := time.Unix(1000, 0)
+	svc.now = func() time.Time { return now }
+
+	for i := 0; i <= maxWatchedPerUser; i++ {
+		now = now.Add(time.Second)
+		if err := svc.SavePosition("u1", fmt.Sprintf("%04d.mkv", i), 990, 1000); err != nil {
+			t.Fatalf("finish %d: %v", i, err)
+		}
+	}
+	statuses := svc.Statuses("u1")
+	if len(statuses) != maxWatchedPerUser {
+		t.Fatalf("expected %d watched videos, got %d", maxWatchedPerUser, len(statuses))
+	}
+	if _, ok := statuses["0000.mkv"]; ok {
+		t.Fatalf("expected the oldest watched video to be evicted")
+	}
+}
@@ -0,0 +1,2 @@
+// Package quota tracks per-user storage and monthly streaming usage against limits.
+package quota
@@ -0,0 +1,469 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultFlushInterval = 30 * time.Second
+
+var (
+	ErrStorageQuotaExceeded = errors.New("storage quota exceeded")
+	ErrStreamQuotaExceeded  = errors.New("monthly streaming quota exceeded")
+	ErrInvalidLimit         = errors.New("invalid quota limit")
+)
+
+// Limits caps usage per user. Zero means unlimited.
+type Limits struct {
+	StorageBytes       int64
+	MonthlyStreamBytes int64
+}
+
+// Usage is the public view of a user's quota state.
+type Usage struct {
+	UserID            string `json:"userId"`
+	Username          string `json:"username,omitempty"`
+	UploadedBytes     int64  `json:"uploadedBytes"`
+	StorageLimitBytes int64  `json:"storageLimitBytes"`
+	Month             string `json:"month"`
+	StreamedBytes     int64  `json:"streamedBytes"`
+	StreamLimitBytes  int64  `json:"streamLimitBytes"`
+}
+
+type record struct {
+	Username      string `json:"username,omitempty"`
+	UploadedBytes int64  `json:"uploadedBytes"`
+	Month         string `json:"month"`
+	StreamedBytes int64  `json:"streamedBytes"`
+	// Per-user overrides; nil falls back to the configured defaults.
+	StorageLimit *int64 `json:"storageLimit,omitempty"`
+	StreamLimit  *int64 `json:"streamLimit,omitempty"`
+}
+
+type fileOwner struct {
+	UserID string `json:"userId"`
+	Size   int64  `json:"size"`
+}
+
+type state struct {
+	Users map[string]*record   `json:"users"`
+	Files map[string]fileOwner `json:"files"`
+	// Trashed keeps the owners of released files by trash name, so a restore
+	// charges them again.
+	Trashed map[string]fileOwner `json:"trashed,omitempty"`
+}
+
+// Service keeps quota usage in memory and persists it to a JSON file.
+type Service struct {
+	mu       sync.Mutex
+	file     string
+	defaults Limits
+	state    state
+	dirty    bool
+	now      func() time.Time
+
+	flushOnce sync.Once
+}
+
+// NewService creates a quota service and loads persisted usage from file.
+func NewService(file string, defaults Limits) (*Service, error) {
+	svc := &Service{
+		file:     strings.TrimSpace(file),
+		defaults: defaults,
+		state:    state{Users: map[string]*record{}, Files: map[string]fileOwner{}, Trashed: map[string]fileOwner{}},
+		now:      time.Now,
+	}
+	if err := svc.load(); err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
+
+// CheckUpload reports ErrStorageQuotaExceeded when storing additionalBytes more
+// would push userID over its storage limit.
+func (s *Service) CheckUpload(userID string, additionalBytes int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit := s.storageLimitLocked(s.state.Users[userID])
+	if limit <= 0 {
+		return nil
+	}
+	used := int64(0)
+	if rec := s.state.Users[userID]; rec != nil {
+		used = rec.UploadedBytes
+	}
+	if used+additionalBytes > limit {
+		return ErrStorageQuotaExceeded
+	}
+	return nil
+}
+
+// RecordUpload attributes a finalized library file to userID. Re-uploading a path
+// releases the previous owner's bytes first.
+func (s *Service) RecordUpload(userID, username, relPath string, size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.releaseLocked(relPath)
+	rec := s.recordLocked(userID, username)
+	rec.UploadedBytes += size
+	s.state.Files[relPath] = fileOwner{UserID: userID, Size: size}
+	return s.saveLocked()
+}
+
+// ReleaseFile returns the bytes of a deleted library file to its uploader's quota.
+func (s *Service) ReleaseFile(relPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.releaseLocked(relPath) {
+		return nil
+	}
+	return s.saveLocked()
+}
+
+// MoveFile keeps a renamed or moved library file charged to its uploader.
+func (s *Service) MoveFile(fromPath, toPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owner, ok := s.state.Files[fromPath]
+	if !ok {
+		return nil
+	}
+	s.releaseLocked(toPath)
+	delete(s.state.Files, fromPath)
+	s.state.Files[toPath] = owner
+	return s.saveLocked()
+}
+
+// TrashFile returns the bytes of a library file moved to the trash as
+// trashName to its uploader's quota, remembering the uploader for RestoreFile.
+func (s *Service) TrashFile(relPath, trashName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owner, ok := s.state.Files[relPath]
+	if !ok {
+		return nil
+	}
+	s.releaseLocked(relPath)
+	s.state.Trashed[trashName] = owner
+	return s.saveLocked()
+}
+
+// CheckRestore reports ErrStorageQuotaExceeded when restoring trashName would
+// push its uploader over their storage limit.
+func (s *Service) CheckRestore(trashName string) error {
+	s.mu.Lock()
+	owner, ok := s.state.Trashed[trashName]
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return s.CheckUpload(owner.UserID, owner.Size)
+}
+
+// RestoreFile charges a file restored from trashName to relPath back to its
+// uploader.
+func (s *Service) RestoreFile(trashName, relPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owner, ok := s.state.Trashed[trashName]
+	if !ok {
+		return nil
+	}
+	delete(s.state.Trashed, trashName)
+	s.releaseLocked(relPath)
+	rec := s.recordLocked(owner.UserID, "")
+	rec.UploadedBytes += owner.Size
+	s.state.Files[relPath] = owner
+	return s.saveLocked()
+}
+
+// ForgetTrashed drops the uploaders of trashed files that were deleted for good.
+func (s *Service) ForgetTrashed(trashNames []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for _, name := range trashNames {
+		if _, ok := s.state.Trashed[name]; ok {
+			delete(s.state.Trashed, name)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.saveLocked()
+}
+
+// CheckStream reports ErrStreamQuotaExceeded once userID used up this month's bandwidth.
+func (s *Service) CheckStream(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.state.Users[userID]
+	limit := s.streamLimitLocked(rec)
+	if limit <= 0 || rec == nil {
+		return nil
+	}
+	s.rollMonthLocked(rec)
+	if rec.StreamedBytes >= limit {
+		return ErrStreamQuotaExceeded
+	}
+	return nil
+}
+
+// AddStreamed accounts bytes delivered to userID. Changes are persisted by the flusher.
+func (s *Service) AddStreamed(userID, username string, n int64) {
+	if n <= 0 || userID == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.recordLocked(userID, username)
+	rec.StreamedBytes += n
+	s.dirty = true
+}
+
+// Usage returns the quota state of userID.
+func (s *Service) Usage(userID string) Usage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.state.Users[userID]
+	if rec == nil {
+		rec = &record{}
+	}
+	return s.usageLocked(userID, rec)
+}
+
+// List returns quota state for every tracked user ordered by username.
+func (s *Service) List() []Usage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]Usage, 0, len(s.state.Users))
+	for userID, rec := range s.state.Users {
+		out = append(out, s.usageLocked(userID, rec))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Username != out[j].Username {
+			return out[i].Username < out[j].Username
+		}
+		return out[i].UserID < out[j].UserID
+	})
+	return out
+}
+
+// SetLimits overrides the limits of userID. A nil limit restores the default; zero is unlimited.
+func (s *Service) SetLimits(userID string, storageBytes, streamBytes *int64) (Usage, error) {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return Usage{}, ErrInvalidLimit
+	}
+	for _, limit := range []*int64{storageBytes, streamBytes} {
+		if limit != nil && *limit < 0 {
+			return Usage{}, ErrInvalidLimit
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.recordLocked(userID, "")
+	rec.StorageLimit = storageBytes
+	rec.StreamLimit = streamBytes
+	if err := s.saveLocked(); err != nil {
+		return Usage{}, err
+	}
+	return s.usageLocked(userID, rec), nil
+}
+
+// StartFlusher periodically persists streamed-byte counters.
+func (s *Service) StartFlusher(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+
+	s.flushOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					_ = s.Flush()
+					return
+				case <-ticker.C:
+					_ = s.Flush()
+				}
+			}
+		}()
+	})
+}
+
+// Flush persists pending usage changes.
+func (s *Service) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+	return s.saveLocked()
+}
+
+func (s *Service) recordLocked(userID, username string) *record {
+	rec := s.state.Users[userID]
+	if rec == nil {
+		rec = &record{Month: s.month()}
+		s.state.Users[userID] = rec
+	}
+	if username != "" {
+		rec.Username = username
+	}
+	s.rollMonthLocked(rec)
+	return rec
+}
+
+func (s *Service) rollMonthLocked(rec *record) {
+	if month := s.month(); rec.Month != month {
+		rec.Month = month
+		rec.StreamedBytes = 0
+		s.dirty = true
+	}
+}
+
+func (s *Service) releaseLocked(relPath string) bool {
+	owner, ok := s.state.Files[relPath]
+	if !ok {
+		return false
+	}
+	delete(s.state.Files, relPath)
+	if rec := s.state.Users[owner.UserID]; rec != nil {
+		rec.UploadedBytes -= owner.Size
+		if rec.UploadedBytes < 0 {
+			rec.UploadedBytes = 0
+		}
+	}
+	return true
+}
+
+func (s *Service) usageLocked(userID string, rec *record) Usage {
+	s.rollMonthLocked(rec)
+	if rec.Month == "" {
+		rec.Month = s.month()
+	}
+	return Usage{
+		UserID:            userID,
+		Username:          rec.Username,
+		UploadedBytes:     rec.UploadedBytes,
+		StorageLimitBytes: s.storageLimitLocked(rec),
+		Month:             rec.Month,
+		StreamedBytes:     rec.StreamedBytes,
+		StreamLimitBytes:  s.streamLimitLocked(rec),
+	}
+}
+
+func (s *Service) storageLimitLocked(rec *record) int64 {
+	if rec != nil && rec.StorageLimit != nil {
+		return *rec.StorageLimit
+	}
+	return s.defaults.StorageBytes
+}
+
+func (s *Service) streamLimitLocked(rec *record) int64 {
+	if rec != nil && rec.StreamLimit != nil {
+		return *rec.StreamLimit
+	}
+	return s.defaults.MonthlyStreamBytes
+}
+
+func (s *Service) month() string {
+	return s.now().UTC().Format("2006-01")
+}
+
+func (s *Service) load() error {
+	if s.file == "" {
+		return nil
+	}
+
+	raw, err := os.ReadFile(s.file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var stored state
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return fmt.Errorf("decode quota file: %w", err)
+	}
+	for userID, rec := range stored.Users {
+		if rec != nil {
+			s.state.Users[userID] = rec
+		}
+	}
+	for relPath, owner := range stored.Files {
+		s.state.Files[relPath] = owner
+	}
+	for name, owner := range stored.Trashed {
+		s.state.Trashed[name] = owner
+	}
+	return nil
+}
+
+// saveLocked persists usage. Users with nothing stored, no overrides and no
+// streaming in the current month are dropped so short-lived guests do not accumulate.
+func (s *Service) saveLocked() error {
+	month := s.month()
+	for userID, rec := range s.state.Users {
+		if rec.UploadedBytes == 0 && rec.StorageLimit == nil && rec.StreamLimit == nil &&
+			(rec.StreamedBytes == 0 || rec.Month != month) {
+			delete(s.state.Users, userID)
+		}
+	}
+
+	if s.file == "" {
+		s.dirty = false
+		return nil
+	}
+
+	raw, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.file), 0o755); err != nil {
+		return err
+	}
+
+	tmpPath := s.file + ".tmp"
+	if err := os.WriteFile(tmpPath, raw, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, s.file); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
@@ -0,0 +1,145 @@
+package quota
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestService(t *testing.T, file string, defaults Limits) *Service {
+	t.Helper()
+	svc, err := NewService(file, defaults)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	return svc
+}
+
+func TestUploadQuota_EnforcedAndReleased(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "quotas.json")
+	svc := newTestService(t, file, Limits{StorageBytes: 100})
+
+	if err := svc.CheckUpload("u1", 100); err != nil {
+		t.Fatalf("expected upload within quota, got %v", err)
+	}
+	if err := svc.RecordUpload("u1", "alice", "movie.mkv", 80); err != nil {
+		t.Fatalf("record upload: %v", err)
+	}
+	if err := svc.CheckUpload("u1", 21); !errors.Is(err, ErrStorageQuotaExceeded) {
+		t.Fatalf("expected storage quota error, got %v", err)
+	}
+
+	// Re-uploading the same path replaces, rather than adds to, the stored size.
+	if err := svc.RecordUpload("u1", "alice", "movie.mkv", 60); err != nil {
+		t.Fatalf("record re-upload: %v", err)
+	}
+	reloaded := newTestService(t, file, Limits{StorageBytes: 100})
+	if used := reloaded.Usage("u1").UploadedBytes; used != 60 {
+		t.Fatalf("expected persisted 60 uploaded bytes, got %d", used)
+	}
+
+	if err := reloaded.MoveFile("movie.mkv", "films/movie.mkv"); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if err := reloaded.ReleaseFile("movie.mkv"); err != nil {
+		t.Fatalf("release old path: %v", err)
+	}
+	if used := reloaded.Usage("u1").UploadedBytes; used != 60 {
+		t.Fatalf("expected a moved file to stay charged, got %d", used)
+	}
+	if err := reloaded.ReleaseFile("films/movie.mkv"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if used := reloaded.Usage("u1").UploadedBytes; used != 0 {
+		t.Fatalf("expected storage released, got %d", used)
+	}
+}
+
+func TestStreamQuota_ResetsMonthly(t *testing.T) {
+	svc := newTestService(t, "", Limits{MonthlyStreamBytes: 10})
+	now := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	svc.AddStreamed("u1", "alice", 10)
+	if err := svc.CheckStream("u1"); !errors.Is(err, ErrStreamQuotaExceeded) {
+		t.Fatalf("expected stream quota error, got %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if err := svc.CheckStream("u1"); err != nil {
+		t.Fatalf("expected quota reset in the new month, got %v", err)
+	}
+	if usage := svc.Usage("u1"); usage.StreamedBytes != 0 || usage.Month != "2026-04" {
+		t.Fatalf("unexpected usage after rollover %+v", usage)
+	}
+}
+
+func TestSetLimits_OverridesDefaults(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "quotas.json")
+	svc := newTestService(t, file, Limits{StorageBytes: 100, MonthlyStreamBytes: 100})
+
+	unlimited := int64(0)
+	if _, err := svc.SetLimits("u1", &unlimited, nil); err != nil {
+		t.Fatalf("set limits: %v", err)
+	}
+	negative := int64(-1)
+	if _, err := svc.SetLimits("u1", nil, &negative); !errors.Is(err, ErrInvalidLimit) {
+		t.Fatalf("expected negative limit to be rejected, got %v", err)
+	}
+
+	reloaded := newTestService(t, file, Limits{StorageBytes: 100, MonthlyStreamBytes: 100})
+	if err := reloaded.CheckUpload("u1", 1<<40); err != nil {
+		t.Fatalf("expected unlimited storage override, got %v", err)
+	}
+	if usage := reloaded.Usage("u1"); usage.StreamLimitBytes != 100 {
+		t.Fatalf("expected default stream limit, got %d", usage.StreamLimitBytes)
+	}
+}
+
+func TestTrashFile_RestoreChargesTheUploaderAgain(t *testing.T) {
+	svc, err := NewService("", Limits{StorageBytes: 1000})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	if err := svc.RecordUpload("u1", "alice", "a.mkv", 600); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if err := svc.TrashFile("a.mkv", "a.deleted-1.mkv"); err != nil {
+		t.Fatalf("trash: %v", err)
+	}
+	if used := svc.Usage("u1").UploadedBytes; used != 0 {
+		t.Fatalf("expected trashing to release the bytes, got %d", used)
+	}
+
+	// The freed space is used up before the restore.
+	if err := svc.RecordUpload("u1", "alice", "b.mkv", 600); err != nil {
+		t.Fatalf("record b: %v", err)
+	}
+	if err := svc.CheckRestore("a.deleted-1.mkv"); !errors.Is(err, ErrStorageQuotaExceeded) {
+		t.Fatalf("expected the restore to exceed the quota, got %v", err)
+	}
+
+	if err := svc.ReleaseFile("b.mkv"); err != nil {
+		t.Fatalf("release b: %v", err)
+	}
+	if err := svc.CheckRestore("a.deleted-1.mkv"); err != nil {
+		t.Fatalf("expected the restore to fit, got %v", err)
+	}
+	if err := svc.RestoreFile("a.deleted-1.mkv", "a.mkv"); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if used := svc.Usage("u1").UploadedBytes; used != 600 {
+		t.Fatalf("expected the restored file charged again, got %d", used)
+	}
+
+	if err := svc.TrashFile("a.mkv", "a.deleted-2.mkv"); err != nil {
+		t.Fatalf("trash again: %v", err)
+	}
+	if err := svc.ForgetTrashed([]string{"a.deleted-2.mkv"}); err != nil {
+		t.Fatalf("forget: %v", err)
+	}
+	if len(svc.state.Trashed) != 0 {
+		t.Fatalf("expected emptied trash to be forgotten, got %v", svc.state.Trashed)
+	}
+}
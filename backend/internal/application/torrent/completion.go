@@ -0,0 +1,70 @@
+package torrent
+
+import (
+	"context"
+	"time"
+
+	"evd/internal/domain/torrent"
+)
+
+const defaultCompletionPollInterval = 10 * time.Second
+
+// WatchCompletions polls the torrent list every interval and calls onComplete
+// with the library paths of the streamable files of each torrent that finishes
+// downloading. Torrents already complete at the first poll are not reported,
+// and each torrent is reported once. It returns when ctx ends.
+func (s *Service) WatchCompletions(ctx context.Context, interval time.Duration, onComplete func(paths []string)) {
+	if interval <= 0 {
+		interval = defaultCompletionPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var done map[int]bool
+	for {
+		items, err := s.gateway.List()
+		if err == nil {
+			done = s.reportCompletions(items, done, onComplete)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reportCompletions calls onComplete for torrents in items that are complete
+// but not in done, and returns the complete set. A nil done only records it.
+func (s *Service) reportCompletions(items []torrent.Info, done map[int]bool, onComplete func(paths []string)) map[int]bool {
+	next := make(map[int]bool, len(items))
+	for _, item := range items {
+		if !downloadComplete(item) {
+			continue
+		}
+		next[item.ID] = true
+		if done == nil || done[item.ID] {
+			continue
+		}
+
+		var paths []string
+		for _, file := range item.Files {
+			// Streamable files exist in the library under their path.
+			if file.Streamable {
+				paths = append(paths, file.Path)
+			}
+		}
+		if len(paths) > 0 {
+			onComplete(paths)
+		}
+	}
+	return next
+}
+
+// downloadComplete reports whether every wanted byte of item is on disk.
+// Transmission's isFinished means seeding is over, which also implies it.
+func downloadComplete(item torrent.Info) bool {
+	return item.PercentDone >= 1 || item.IsFinished
+}
@@ -6,7 +6,16 @@ import domain "evd/internal/domain/torrent"
 type Gateway interface {
 	Enabled() bool
 	List() ([]domain.Info, error)
+	SessionStats() (domain.SessionStats, error)
 	AddTorrent(metainfo string) error
+	AddMagnet(uri string) error
+	RemoveTorrent(id int, deleteData bool) error
+	SetPaused(id int, paused bool) error
+	VerifyTorrent(id int) error
+	SetSeedRatioLimit(id int, ratio float64) error
+	SetUploadLimit(id int, kbps int) error
+	SetFilesWanted(id int, wanted, unwanted []int) error
 	SetSequentialDownload(id int, enabled bool) error
 	SetStreamingFocus(id, fileIndex int, positionRatio float64) error
+	FocusState(id int) (domain.FocusState, error)
 }
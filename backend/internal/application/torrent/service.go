@@ -3,12 +3,21 @@ package torrent
 import (
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
 	"math"
+	"net/url"
+	"strings"
 
 	"evd/internal/domain/torrent"
 )
 
+// ErrInvalidMagnet reports a magnet URI without a BitTorrent info hash.
+var ErrInvalidMagnet = errors.New("invalid magnet link")
+
+// maxMagnetLength bounds magnet URIs; real ones with trackers stay far below.
+const maxMagnetLength = 8 << 10
+
 // Service handles torrent use cases.
 type Service struct {
 	gateway Gateway
@@ -29,8 +38,19 @@ func (s *Service) List() ([]torrent.Info, error) {
 	return s.gateway.List()
 }
 
+// SessionStats returns engine-wide transfer rates, torrent counts and totals.
+func (s *Service) SessionStats() (torrent.SessionStats, error) {
+	if !s.Enabled() {
+		return torrent.SessionStats{}, errors.New("Transmission is not configured")
+	}
+	return s.gateway.SessionStats()
+}
+
 // AddTorrent validates and submits torrent metadata.
 func (s *Service) AddTorrent(r io.Reader) error {
+	if !s.Enabled() {
+		return errors.New("Transmission is not configured")
+	}
 	data, err := io.ReadAll(io.LimitReader(r, 5<<20))
 	if err != nil {
 		return err
@@ -42,6 +62,124 @@ func (s *Service) AddTorrent(r io.Reader) error {
 	return s.gateway.AddTorrent(metainfo)
 }
 
+// AddMagnet validates and submits a magnet URI.
+func (s *Service) AddMagnet(uri string) error {
+	if !s.Enabled() {
+		return errors.New("Transmission is not configured")
+	}
+	uri = strings.TrimSpace(uri)
+	if len(uri) > maxMagnetLength || !strings.HasPrefix(uri, "magnet:?") {
+		return ErrInvalidMagnet
+	}
+	params, err := url.ParseQuery(strings.TrimPrefix(uri, "magnet:?"))
+	if err != nil {
+		return ErrInvalidMagnet
+	}
+	hasHash := false
+	for _, topic := range params["xt"] {
+		if strings.HasPrefix(topic, "urn:btih:") || strings.HasPrefix(topic, "urn:btmh:") {
+			hasHash = true
+			break
+		}
+	}
+	if !hasHash {
+		return ErrInvalidMagnet
+	}
+	return s.gateway.AddMagnet(uri)
+}
+
+// RemoveTorrent removes a torrent, optionally deleting its downloaded data.
+func (s *Service) RemoveTorrent(id int, deleteData bool) error {
+	if !s.Enabled() {
+		return errors.New("Transmission is not configured")
+	}
+	if id <= 0 {
+		return errors.New("invalid torrent id")
+	}
+	return s.gateway.RemoveTorrent(id, deleteData)
+}
+
+// SetPaused pauses or resumes a torrent download without removing it.
+func (s *Service) SetPaused(id int, paused bool) error {
+	if !s.Enabled() {
+		return errors.New("Transmission is not configured")
+	}
+	if id <= 0 {
+		return errors.New("invalid torrent id")
+	}
+	return s.gateway.SetPaused(id, paused)
+}
+
+// VerifyTorrent rechecks a torrent's downloaded data, for example after its
+// files were moved or the host crashed mid-write.
+func (s *Service) VerifyTorrent(id int) error {
+	if !s.Enabled() {
+		return errors.New("Transmission is not configured")
+	}
+	if id <= 0 {
+		return errors.New("invalid torrent id")
+	}
+	return s.gateway.VerifyTorrent(id)
+}
+
+const (
+	maxSeedRatio  = 1000
+	maxUploadKbps = 1 << 20
+)
+
+// SetLimits sets a torrent's seed ratio limit and upload cap in KB/s. A nil
+// value is left unchanged and 0 clears that limit. Both are validated before
+// either is applied.
+func (s *Service) SetLimits(id int, seedRatio *float64, uploadKbps *int) error {
+	if !s.Enabled() {
+		return errors.New("Transmission is not configured")
+	}
+	if id <= 0 {
+		return errors.New("invalid torrent id")
+	}
+	if seedRatio == nil && uploadKbps == nil {
+		return fmt.Errorf("%w: seedRatio or uploadKbps is required", torrent.ErrInvalidLimit)
+	}
+	if seedRatio != nil && (*seedRatio < 0 || *seedRatio > maxSeedRatio) {
+		return fmt.Errorf("%w: seedRatio must be between 0 and %d", torrent.ErrInvalidLimit, maxSeedRatio)
+	}
+	if uploadKbps != nil && (*uploadKbps < 0 || *uploadKbps > maxUploadKbps) {
+		return fmt.Errorf("%w: uploadKbps must be between 0 and %d", torrent.ErrInvalidLimit, maxUploadKbps)
+	}
+
+	if seedRatio != nil {
+		if err := s.gateway.SetSeedRatioLimit(id, *seedRatio); err != nil {
+			return err
+		}
+	}
+	if uploadKbps != nil {
+		return s.gateway.SetUploadLimit(id, *uploadKbps)
+	}
+	return nil
+}
+
+// SetFilesWanted chooses which files of a torrent are downloaded. An index may
+// not appear in both lists, and at least one index is required.
+func (s *Service) SetFilesWanted(id int, wanted, unwanted []int) error {
+	if !s.Enabled() {
+		return errors.New("Transmission is not configured")
+	}
+	if id <= 0 {
+		return errors.New("invalid torrent id")
+	}
+	if len(wanted)+len(unwanted) == 0 {
+		return torrent.ErrInvalidFileIndex
+	}
+	seen := make(map[int]bool, len(wanted)+len(unwanted))
+	for _, index := range append(append([]int(nil), wanted...), unwanted...) {
+		if index < 0 || seen[index] {
+			return torrent.ErrInvalidFileIndex
+		}
+		seen[index] = true
+	}
+	return s.gateway.SetFilesWanted(id, wanted, unwanted)
+}
+
 // EnableStreaming enables sequential download for faster preview playback.
 func (s *Service) EnableStreaming(id int) error {
 	if !s.Enabled() {
@@ -74,3 +212,14 @@ func (s *Service) SetStreamingFocus(id, fileIndex int, currentTime, duration flo
 
 	return s.gateway.SetStreamingFocus(id, fileIndex, positionRatio)
 }
+
+// FocusState reports which streaming focus mode is in effect for a torrent.
+func (s *Service) FocusState(id int) (torrent.FocusState, error) {
+	if !s.Enabled() {
+		return torrent.FocusState{}, errors.New("Transmission is not configured")
+	}
+	if id <= 0 {
+		return torrent.FocusState{}, errors.New("invalid torrent id")
+	}
+	return s.gateway.FocusState(id)
+}
@@ -12,20 +12,69 @@ type stubGateway struct {
 	enabled bool
 
 	lastID        int
+	lastPaused    *bool
+	lastMagnet    string
 	lastFileIndex int
 	lastRatio     float64
+	lastWanted    []int
+	lastUnwanted  []int
+
+	lastSeedRatio  *float64
+	lastUploadKbps *int
 
 	focusErr error
+	focus    domain.FocusState
 }
 
 func (s *stubGateway) Enabled() bool { return s.enabled }
 
 func (s *stubGateway) List() ([]domain.Info, error) { return nil, nil }
 
+func (s *stubGateway) SessionStats() (domain.SessionStats, error) {
+	return domain.SessionStats{TorrentCount: 1}, nil
+}
+
 func (s *stubGateway) AddTorrent(_ string) error { return nil }
 
+func (s *stubGateway) AddMagnet(uri string) error {
+	s.lastMagnet = uri
+	return nil
+}
+
+func (s *stubGateway) RemoveTorrent(_ int, _ bool) error { return nil }
+
+func (s *stubGateway) SetPaused(id int, paused bool) error {
+	s.lastID = id
+	s.lastPaused = &paused
+	return nil
+}
+
 func (s *stubGateway) SetSequentialDownload(_ int, _ bool) error { return nil }
 
+func (s *stubGateway) VerifyTorrent(id int) error {
+	s.lastID = id
+	return nil
+}
+
+func (s *stubGateway) SetSeedRatioLimit(id int, ratio float64) error {
+	s.lastID = id
+	s.lastSeedRatio = &ratio
+	return nil
+}
+
+func (s *stubGateway) SetUploadLimit(id int, kbps int) error {
+	s.lastID = id
+	s.lastUploadKbps = &kbps
+	return nil
+}
+
+func (s *stubGateway) SetFilesWanted(id int, wanted, unwanted []int) error {
+	s.lastID = id
+	s.lastWanted = wanted
+	s.lastUnwanted = unwanted
+	return nil
+}
+
 func (s *stubGateway) SetStreamingFocus(id, fileIndex int, positionRatio float64) error {
 	s.lastID = id
 	s.lastFileIndex = fileIndex
@@ -33,6 +82,12 @@ func (s *stubGateway) SetStreamingFocus(id, fileIndex int, positionRatio float64
 	return s.focusErr
 }
 
+func (s *stubGateway) FocusState(id int) (domain.FocusState, error) {
+	state := s.focus
+	state.TorrentID = id
+	return state, nil
+}
+
 func TestSetStreamingFocus_UsesPlaybackRatio(t *testing.T) {
 	gw := &stubGateway{enabled: true}
 	svc := NewService(gw)
@@ -111,3 +166,156 @@ func TestAddTorrent_RejectsEmptyPayload(t *testing.T) {
 type emptyReader struct{}
 
 func (r *emptyReader) Read(_ []byte) (int, error) { return 0, io.EOF }
+
+func TestFocusState_ValidatesTorrentID(t *testing.T) {
+	gw := &stubGateway{enabled: true, focus: domain.FocusState{Mode: "basic"}}
+	svc := NewService(gw)
+
+	if _, err := svc.FocusState(0); err == nil {
+		t.Fatalf("expected error for invalid torrent id")
+	}
+	state, err := svc.FocusState(5)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if state.TorrentID != 5 || state.Mode != "basic" {
+		t.Fatalf("unexpected focus state: %+v", state)
+	}
+}
+
+func TestSetPaused_ForwardsDirectionAndValidatesID(t *testing.T) {
+	gw := &stubGateway{enabled: true}
+	svc := NewService(gw)
+
+	for _, paused := range []bool{true, false} {
+		if err := svc.SetPaused(7, paused); err != nil {
+			t.Fatalf("set paused %v: %v", paused, err)
+		}
+		if gw.lastID != 7 || gw.lastPaused == nil || *gw.lastPaused != paused {
+			t.Fatalf("expected paused=%v for torrent 7, got id=%d paused=%v", paused, gw.lastID, gw.lastPaused)
+		}
+	}
+
+	gw.lastPaused = nil
+	if err := svc.SetPaused(0, true); err == nil || gw.lastPaused != nil {
+		t.Fatalf("expected invalid id to be rejected before the gateway")
+	}
+}
+
+func TestAddMagnet_ValidatesURI(t *testing.T) {
+	gw := &stubGateway{enabled: true}
+	svc := NewService(gw)
+
+	valid := "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&dn=movie"
+	if err := svc.AddMagnet("  " + valid + " "); err != nil {
+		t.Fatalf("expected valid magnet to be accepted, got %v", err)
+	}
+	if gw.lastMagnet != valid {
+		t.Fatalf("expected trimmed magnet to reach the gateway, got %q", gw.lastMagnet)
+	}
+
+	for _, uri := range []string{"", "http://example.com/a.torrent", "magnet:?dn=movie", "magnet:?xt=urn:sha1:abc", "magnet:?xt=%zz"} {
+		if err := svc.AddMagnet(uri); !errors.Is(err, ErrInvalidMagnet) {
+			t.Fatalf("expected %q to be rejected, got %v", uri, err)
+		}
+	}
+
+	disabled := &stubGateway{}
+	if err := NewService(disabled).AddMagnet(valid); err == nil || disabled.lastMagnet != "" {
+		t.Fatalf("expected magnet to be refused without Transmission, got %v", err)
+	}
+}
+
+func TestSetFilesWanted_ValidatesIndices(t *testing.T) {
+	gw := &stubGateway{enabled: true}
+	svc := NewService(gw)
+
+	for _, tc := range []struct {
+		wanted, unwanted []int
+	}{
+		{nil, nil},
+		{[]int{-1}, nil},
+		{[]int{1}, []int{1}},
+	} {
+		if err := svc.SetFilesWanted(3, tc.wanted, tc.unwanted); !errors.Is(err, domain.ErrInvalidFileIndex) {
+			t.Fatalf("expected ErrInvalidFileIndex for %+v, got %v", tc, err)
+		}
+	}
+
+	if err := svc.SetFilesWanted(3, []int{0}, []int{1, 2}); err != nil {
+		t.Fatalf("set files: %v", err)
+	}
+	if gw.lastID != 3 || len(gw.lastWanted) != 1 || len(gw.lastUnwanted) != 2 {
+		t.Fatalf("expected selection to reach the gateway, got id=%d wanted=%v unwanted=%v", gw.lastID, gw.lastWanted, gw.lastUnwanted)
+	}
+}
+
+func TestReportCompletions_ReportsEachTorrentOnce(t *testing.T) {
+	svc := NewService(&stubGateway{enabled: true})
+	var reported [][]string
+	onComplete := func(paths []string) { reported = append(reported, paths) }
+
+	downloading := domain.Info{ID: 2, PercentDone: 0.5, Files: []domain.File{{Path: "b/movie.mkv", Streamable: true}}}
+	seeded := domain.Info{ID: 1, PercentDone: 1, Files: []domain.File{{Path: "a/old.mp4", Streamable: true}}}
+
+	done := svc.reportCompletions([]domain.Info{seeded, downloading}, nil, onComplete)
+	if len(reported) != 0 {
+		t.Fatalf("expected torrents complete at startup to be skipped, got %v", reported)
+	}
+
+	downloading.PercentDone = 1
+	downloading.Files = append(downloading.Files, domain.File{Path: "b/sample.nfo"})
+	done = svc.reportCompletions([]domain.Info{seeded, downloading}, done, onComplete)
+	done = svc.reportCompletions([]domain.Info{seeded, downloading}, done, onComplete)
+	if len(reported) != 1 || len(reported[0]) != 1 || reported[0][0] != "b/movie.mkv" {
+		t.Fatalf("expected one report of the finished file, got %v", reported)
+	}
+	if !done[1] || !done[2] {
+		t.Fatalf("expected both torrents to be tracked as complete, got %v", done)
+	}
+}
+
+func TestVerifyTorrent_ValidatesIDAndEnabled(t *testing.T) {
+	if err := NewService(&stubGateway{}).VerifyTorrent(4); err == nil {
+		t.Fatalf("expected verify to fail without Transmission")
+	}
+
+	gw := &stubGateway{enabled: true}
+	svc := NewService(gw)
+	if err := svc.VerifyTorrent(-1); err == nil || gw.lastID != 0 {
+		t.Fatalf("expected invalid id to be rejected before the gateway")
+	}
+	if err := svc.VerifyTorrent(4); err != nil || gw.lastID != 4 {
+		t.Fatalf("expected verify of torrent 4 to reach the gateway, got id=%d err=%v", gw.lastID, err)
+	}
+}
+
+func TestSetLimits_ValidatesBeforeApplying(t *testing.T) {
+	gw := &stubGateway{enabled: true}
+	svc := NewService(gw)
+	ratio, negative, tooFast := 2.5, -1.0, maxUploadKbps+1
+
+	for _, tc := range []struct {
+		ratio *float64
+		kbps  *int
+	}{
+		{nil, nil},
+		{&negative, nil},
+		{&ratio, &tooFast},
+	} {
+		if err := svc.SetLimits(3, tc.ratio, tc.kbps); !errors.Is(err, domain.ErrInvalidLimit) {
+			t.Fatalf("expected ErrInvalidLimit for %+v, got %v", tc, err)
+		}
+	}
+	if gw.lastSeedRatio != nil || gw.lastUploadKbps != nil {
+		t.Fatalf("expected invalid limits to be rejected before the gateway")
+	}
+
+	kbps := 0
+	if err := svc.SetLimits(3, &ratio, &kbps); err != nil {
+		t.Fatalf("set limits: %v", err)
+	}
+	if gw.lastSeedRatio == nil || *gw.lastSeedRatio != 2.5 || gw.lastUploadKbps == nil || *gw.lastUploadKbps != 0 {
+		t.Fatalf("expected both limits to reach the gateway, got ratio=%v kbps=%v", gw.lastSeedRatio, gw.lastUploadKbps)
+	}
+}
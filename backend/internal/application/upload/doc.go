@@ -0,0 +1,2 @@
+// Package upload manages chunked upload sessions and their temporary files.
+package upload
@@ -0,0 +1,512 @@
+package upload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+const (
+	// partSuffix marks in-progress uploads; the file is renamed once the last chunk arrives.
+	partSuffix = ".part"
+	// stateSuffix names the file next to the upload that records its chunk
+	// order and running checksum, so the upload can resume after a restart.
+	stateSuffix = ".partial"
+
+	defaultSessionTTL    = 6 * time.Hour
+	defaultSweepInterval = 10 * time.Minute
+)
+
+var (
+	ErrInvalidChunk    = errors.New("invalid chunk index")
+	ErrSessionNotFound = errors.New("upload session not found")
+	// ErrChunkTooLarge and ErrUploadTooLarge are wrapped with the exceeded limit.
+	ErrChunkTooLarge  = errors.New("upload chunk too large")
+	ErrUploadTooLarge = errors.New("upload too large")
+	// ErrChunkOutOfOrder is wrapped with the chunk index the upload expects next.
+	ErrChunkOutOfOrder  = errors.New("upload chunk out of order")
+	ErrInvalidChecksum  = errors.New("invalid sha256 checksum")
+	ErrChecksumMismatch = errors.New("upload checksum mismatch")
+)
+
+// Limits caps upload sizes. Zero fields leave the size unlimited.
+type Limits struct {
+	// MaxChunkBytes caps a single chunk.
+	MaxChunkBytes int64
+	// MaxFileBytes caps a whole upload. With MaxChunkBytes set, an upload is
+	// also refused at chunk 0 when its chunk count times MaxChunkBytes exceeds it.
+	MaxFileBytes int64
+}
+
+type session struct {
+	mu          sync.Mutex
+	relPath     string
+	partPath    string
+	statePath   string
+	finalPath   string
+	nextChunk   int
+	totalChunks int
+	// checksum is the expected hex SHA-256 of the whole file, if given.
+	checksum string
+	// hashState is the marshaled running SHA-256 of the chunks written so far.
+	hashState []byte
+	updatedAt time.Time
+	closed    bool
+}
+
+// Progress tells how far an unfinished upload got. Chunks are located by index,
+// so a resuming client must keep the chunk size it started with.
+type Progress struct {
+	// NextChunk is the index the upload continues with; 0 when nothing is kept.
+	NextChunk     int   `json:"nextChunk"`
+	TotalChunks   int   `json:"totalChunks"`
+	ReceivedBytes int64 `json:"receivedBytes"`
+}
+
+// uploadState is the persisted form of a session.
+type uploadState struct {
+	NextChunk   int    `json:"nextChunk"`
+	TotalChunks int    `json:"totalChunks"`
+	Checksum    string `json:"sha256,omitempty"`
+	HashState   []byte `json:"hashState"`
+}
+
+// Service writes chunked uploads into the library through `.part` temporaries.
+type Service struct {
+	root   string
+	ttl    time.Duration
+	limits Limits
+
+	mu       sync.Mutex
+	sessions map[string]*session
+
+	sweepOnce sync.Once
+}
+
+// NewService creates an upload service writing into root.
+// Sessions idle longer than ttl are purged by the background sweep.
+func NewService(root string, ttl time.Duration) *Service {
+	if ttl <= 0 {
+		ttl = defaultSessionTTL
+	}
+	return &Service{
+		root:     root,
+		ttl:      ttl,
+		sessions: make(map[string]*session),
+	}
+}
+
+// SetLimits changes the upload size limits for chunks written from now on.
+// It must not be called concurrently with WriteChunk.
+func (s *Service) SetLimits(limits Limits) {
+	s.limits = limits
+}
+
+// Limits returns the upload size limits.
+func (s *Service) Limits() Limits {
+	return s.limits
+}
+
+// WriteChunk appends a chunk to the upload's `.part` file. Chunk 0 (re)starts the upload;
+// later chunks must follow in order or are refused with ErrChunkOutOfOrder, except
+// that chunks already received are acknowledged again without being written.
+// It returns the normalized relative path and whether the upload is complete.
+// A chunk over the limits is refused with ErrChunkTooLarge or ErrUploadTooLarge
+// and leaves the `.part` file as it was, so the chunk can be retried.
+//
+// checksum, when not empty on any chunk, is the expected hex SHA-256 of the
+// whole file. The last chunk then compares it with the digest of the written
+// chunks; on ErrChecksumMismatch the upload is deleted.
+func (s *Service) WriteChunk(rawName string, chunkIndex, totalChunks int, checksum string, chunk io.Reader) (string, bool, error) {
+	relPath, err := media.NormalizeVideoPath(rawName)
+	if err != nil {
+		return "", false, err
+	}
+	if chunkIndex < 0 || totalChunks <= 0 || chunkIndex >= totalChunks {
+		return "", false, ErrInvalidChunk
+	}
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	if decoded, err := hex.DecodeString(checksum); err != nil || (checksum != "" && len(decoded) != sha256.Size) {
+		return "", false, ErrInvalidChecksum
+	}
+	if chunkIndex == 0 && s.limits.MaxChunkBytes > 0 && s.limits.MaxFileBytes > 0 &&
+		int64(totalChunks) > s.limits.MaxFileBytes/s.limits.MaxChunkBytes {
+		return "", false, s.uploadTooLarge()
+	}
+
+	sess := s.session(relPath, chunkIndex == 0)
+	if sess == nil {
+		return "", false, ErrSessionNotFound
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.closed {
+		return "", false, ErrSessionNotFound
+	}
+	if chunkIndex > 0 && totalChunks != sess.totalChunks {
+		return "", false, ErrInvalidChunk
+	}
+	if chunkIndex > 0 && chunkIndex < sess.nextChunk {
+		return relPath, false, nil
+	}
+	if chunkIndex > 0 && chunkIndex != sess.nextChunk {
+		return "", false, fmt.Errorf("%w: expected chunk %d", ErrChunkOutOfOrder, sess.nextChunk)
+	}
+
+	if chunkIndex == 0 {
+		sess.checksum, sess.hashState = "", nil
+	}
+	if checksum != "" {
+		sess.checksum = checksum
+	}
+	if err := s.appendChunk(sess, chunkIndex, chunk); err != nil {
+		return "", false, err
+	}
+	sess.nextChunk = chunkIndex + 1
+	sess.totalChunks = totalChunks
+	sess.updatedAt = time.Now()
+
+	if sess.nextChunk < totalChunks {
+		// Without the state file the upload cannot resume after a restart,
+		// which the client notices then; it is not worth failing the chunk.
+		_ = saveState(sess)
+		return relPath, false, nil
+	}
+
+	if sess.checksum != "" {
+		if sum, err := hashSum(sess.hashState); err != nil || sum != sess.checksum {
+			s.dropLocked(sess)
+			_ = os.Remove(sess.partPath)
+			_ = os.Remove(sess.statePath)
+			return "", false, fmt.Errorf("%w: expected sha256 %s, got %s", ErrChecksumMismatch, sess.checksum, sum)
+		}
+	}
+	if err := os.Rename(sess.partPath, sess.finalPath); err != nil {
+		return "", false, err
+	}
+	_ = os.Remove(sess.statePath)
+	s.dropLocked(sess)
+	return relPath, true, nil
+}
+
+// Cancel removes the `.part` file of an active upload and forgets its session.
+// Completed uploads are never touched.
+func (s *Service) Cancel(rawName string) error {
+	relPath, err := media.NormalizeVideoPath(rawName)
+	if err != nil {
+		return err
+	}
+
+	sess := s.session(relPath, false)
+	if sess == nil {
+		return ErrSessionNotFound
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.closed {
+		return ErrSessionNotFound
+	}
+
+	s.dropLocked(sess)
+	_ = os.Remove(sess.statePath)
+	if err := os.Remove(sess.partPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Status returns the progress of the unfinished upload of rawName, also one
+// interrupted by a restart. Without one, the upload starts at chunk 0.
+func (s *Service) Status(rawName string) (Progress, error) {
+	relPath, err := media.NormalizeVideoPath(rawName)
+	if err != nil {
+		return Progress{}, err
+	}
+
+	sess := s.session(relPath, false)
+	if sess == nil {
+		return Progress{}, nil
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.closed {
+		return Progress{}, nil
+	}
+	info, err := os.Stat(sess.partPath)
+	if err != nil {
+		return Progress{}, nil
+	}
+	return Progress{NextChunk: sess.nextChunk, TotalChunks: sess.totalChunks, ReceivedBytes: info.Size()}, nil
+}
+
+// PartialSize returns the bytes already written for an unfinished upload, or 0.
+func (s *Service) PartialSize(rawName string) int64 {
+	relPath, err := media.NormalizeVideoPath(rawName)
+	if err != nil {
+		return 0
+	}
+
+	sess := s.session(relPath, false)
+	if sess == nil {
+		return 0
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.closed {
+		return 0
+	}
+	info, err := os.Stat(sess.partPath)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// StartSweeper periodically purges upload sessions idle beyond the configured TTL.
+func (s *Service) StartSweeper(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultSweepInterval
+	}
+
+	s.sweepOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				s.Sweep(time.Now())
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	})
+}
+
+// Sweep removes sessions and orphaned `.part` files idle since before now-ttl.
+// It returns the number of partial files removed.
+func (s *Service) Sweep(now time.Time) int {
+	cutoff := now.Add(-s.ttl)
+	removed := 0
+
+	s.mu.Lock()
+	tracked := make([]*session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		tracked = append(tracked, sess)
+	}
+	s.mu.Unlock()
+
+	for _, sess := range tracked {
+		sess.mu.Lock()
+		if !sess.closed && sess.updatedAt.Before(cutoff) {
+			s.dropLocked(sess)
+			_ = os.Remove(sess.statePath)
+			if err := os.Remove(sess.partPath); err == nil {
+				removed++
+			}
+		}
+		sess.mu.Unlock()
+	}
+
+	// Partial files left behind by a previous process have no in-memory session.
+	_ = filepath.WalkDir(s.root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(entry.Name(), partSuffix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, strings.TrimSuffix(filePath, partSuffix))
+		if err != nil {
+			return nil
+		}
+		s.mu.Lock()
+		_, active := s.sessions[filepath.ToSlash(rel)]
+		s.mu.Unlock()
+		if active {
+			return nil
+		}
+
+		_ = os.Remove(strings.TrimSuffix(filePath, partSuffix) + stateSuffix)
+		if err := os.Remove(filePath); err == nil {
+			removed++
+		}
+		return nil
+	})
+
+	return removed
+}
+
+// session returns the tracked session for relPath. A session is created when
+// starting a new upload, or adopted when a `.part` file and its state file
+// survived a restart.
+func (s *Service) session(relPath string, create bool) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sess, ok := s.sessions[relPath]; ok {
+		return sess
+	}
+
+	finalPath := filepath.Join(s.root, filepath.FromSlash(relPath))
+	partPath := finalPath + partSuffix
+	sess := &session{relPath: relPath, partPath: partPath, statePath: finalPath + stateSuffix, finalPath: finalPath}
+	if !create {
+		info, err := os.Stat(partPath)
+		if err != nil || loadState(sess) != nil {
+			return nil
+		}
+		sess.updatedAt = info.ModTime()
+	}
+
+	s.sessions[relPath] = sess
+	return sess
+}
+
+func (s *Service) appendChunk(sess *session, chunkIndex int, chunk io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(sess.partPath), 0o755); err != nil {
+		return err
+	}
+
+	flags := os.O_APPEND | os.O_WRONLY
+	if chunkIndex == 0 {
+		flags = os.O_CREATE | os.O_TRUNC | os.O_WRONLY
+	}
+	dst, err := os.OpenFile(sess.partPath, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	var offset int64
+	if chunkIndex > 0 {
+		info, err := dst.Stat()
+		if err != nil {
+			return err
+		}
+		offset = info.Size()
+	}
+
+	hasher, err := resumeHash(sess.hashState)
+	if err != nil {
+		return err
+	}
+
+	// Read at most one byte past the tighter limit to tell an overflow apart.
+	limit, tooLarge := int64(-1), error(nil)
+	if s.limits.MaxChunkBytes > 0 {
+		limit, tooLarge = s.limits.MaxChunkBytes, s.chunkTooLarge()
+	}
+	if s.limits.MaxFileBytes > 0 && (limit < 0 || s.limits.MaxFileBytes-offset < limit) {
+		limit, tooLarge = max(s.limits.MaxFileBytes-offset, 0), s.uploadTooLarge()
+	}
+	if limit >= 0 {
+		chunk = io.LimitReader(chunk, limit+1)
+	}
+
+	written, err := dst.ReadFrom(io.TeeReader(chunk, hasher))
+	if err == nil && limit >= 0 && written > limit {
+		err = tooLarge
+	}
+	if err == nil {
+		sess.hashState, err = hasher.(encoding.BinaryMarshaler).MarshalBinary()
+	}
+	if err != nil {
+		_ = dst.Truncate(offset)
+	}
+	return err
+}
+
+// resumeHash returns a SHA-256 hash continuing from a marshaled state, or a new
+// one for an empty state.
+func resumeHash(state []byte) (hash.Hash, error) {
+	hasher := sha256.New()
+	if len(state) == 0 {
+		return hasher, nil
+	}
+	if err := hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+		return nil, err
+	}
+	return hasher, nil
+}
+
+// hashSum returns the hex digest of a marshaled SHA-256 state.
+func hashSum(state []byte) (string, error) {
+	hasher, err := resumeHash(state)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// saveState persists the order and checksum tracking of sess next to its `.part` file.
+func saveState(sess *session) error {
+	raw, err := json.Marshal(uploadState{
+		NextChunk:   sess.nextChunk,
+		TotalChunks: sess.totalChunks,
+		Checksum:    sess.checksum,
+		HashState:   sess.hashState,
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(sess.statePath, raw, 0o644)
+}
+
+// loadState restores the tracking saved by saveState into sess.
+func loadState(sess *session) error {
+	raw, err := os.ReadFile(sess.statePath)
+	if err != nil {
+		return err
+	}
+	var state uploadState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return err
+	}
+	if state.NextChunk <= 0 || state.TotalChunks <= state.NextChunk {
+		return errors.New("invalid upload state")
+	}
+	sess.nextChunk, sess.totalChunks = state.NextChunk, state.TotalChunks
+	sess.checksum, sess.hashState = state.Checksum, state.HashState
+	return nil
+}
+
+func (s *Service) chunkTooLarge() error {
+	return fmt.Errorf("%w: chunks are limited to %d bytes", ErrChunkTooLarge, s.limits.MaxChunkBytes)
+}
+
+func (s *Service) uploadTooLarge() error {
+	return fmt.Errorf("%w: files are limited to %d bytes", ErrUploadTooLarge, s.limits.MaxFileBytes)
+}
+
+// dropLocked forgets sess; the caller must hold sess.mu.
+func (s *Service) dropLocked(sess *session) {
+	sess.closed = true
+	s.mu.Lock()
+	if s.sessions[sess.relPath] == sess {
+		delete(s.sessions, sess.relPath)
+	}
+	s.mu.Unlock()
+}
@@ -0,0 +1,175 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSweep_RemovesAbandonedPartial(t *testing.T) {
+	root := t.TempDir()
+	svc := NewService(root, time.Hour)
+
+	if _, complete, err := svc.WriteChunk("movie.mkv", 0, 3, "", strings.NewReader("abc")); err != nil || complete {
+		t.Fatalf("write chunk: complete=%v err=%v", complete, err)
+	}
+	partPath := filepath.Join(root, "movie.mkv"+partSuffix)
+	if _, err := os.Stat(partPath); err != nil {
+		t.Fatalf("expected partial file, got %v", err)
+	}
+
+	if removed := svc.Sweep(time.Now()); removed != 0 {
+		t.Fatalf("expected fresh upload to survive sweep, removed %d", removed)
+	}
+	if removed := svc.Sweep(time.Now().Add(2 * time.Hour)); removed != 1 {
+		t.Fatalf("expected abandoned upload to be swept, removed %d", removed)
+	}
+	if _, err := os.Stat(partPath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected partial file removed, got %v", err)
+	}
+	if _, _, err := svc.WriteChunk("movie.mkv", 1, 3, "", strings.NewReader("def")); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected swept session to be gone, got %v", err)
+	}
+}
+
+func TestCancel_KeepsCompletedUploads(t *testing.T) {
+	root := t.TempDir()
+	svc := NewService(root, time.Hour)
+
+	if _, complete, err := svc.WriteChunk("done.mkv", 0, 1, "", strings.NewReader("data")); err != nil || !complete {
+		t.Fatalf("write chunk: complete=%v err=%v", complete, err)
+	}
+	if err := svc.Cancel("done.mkv"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected completed upload to have no session, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "done.mkv")); err != nil {
+		t.Fatalf("expected completed file to remain, got %v", err)
+	}
+
+	if _, _, err := svc.WriteChunk("stuck.mkv", 0, 2, "", strings.NewReader("data")); err != nil {
+		t.Fatalf("write chunk: %v", err)
+	}
+	if err := svc.Cancel("stuck.mkv"); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "stuck.mkv"+partSuffix)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected partial file removed, got %v", err)
+	}
+}
+
+func TestWriteChunk_EnforcesLimits(t *testing.T) {
+	root := t.TempDir()
+	svc := NewService(root, time.Hour)
+	svc.SetLimits(Limits{MaxChunkBytes: 4, MaxFileBytes: 10})
+
+	if _, _, err := svc.WriteChunk("movie.mkv", 0, 3, "", strings.NewReader("abcd")); !errors.Is(err, ErrUploadTooLarge) {
+		t.Fatalf("expected 3 chunks of 4 bytes to exceed 10 bytes, got %v", err)
+	}
+	if _, _, err := svc.WriteChunk("movie.mkv", 0, 2, "", strings.NewReader("abcd")); err != nil {
+		t.Fatalf("write chunk: %v", err)
+	}
+	if _, _, err := svc.WriteChunk("movie.mkv", 1, 2, "", strings.NewReader("efghi")); !errors.Is(err, ErrChunkTooLarge) {
+		t.Fatalf("expected oversized chunk refused, got %v", err)
+	}
+	if size := svc.PartialSize("movie.mkv"); size != 4 {
+		t.Fatalf("expected refused chunk rolled back to 4 bytes, got %d", size)
+	}
+	if _, complete, err := svc.WriteChunk("movie.mkv", 1, 2, "", strings.NewReader("efgh")); err != nil || !complete {
+		t.Fatalf("expected retried chunk to complete, complete=%v err=%v", complete, err)
+	}
+}
+
+func TestWriteChunk_VerifiesChecksumAcrossRestart(t *testing.T) {
+	root := t.TempDir()
+	digest := sha256.Sum256([]byte("abcdef"))
+	checksum := hex.EncodeToString(digest[:])
+
+	if _, _, err := NewService(root, time.Hour).WriteChunk("movie.mkv", 0, 2, checksum, strings.NewReader("abc")); err != nil {
+		t.Fatalf("write chunk: %v", err)
+	}
+	// A restarted process resumes the running hash from the state file.
+	if _, complete, err := NewService(root, time.Hour).WriteChunk("movie.mkv", 1, 2, "", strings.NewReader("def")); err != nil || !complete {
+		t.Fatalf("expected matching upload to complete, complete=%v err=%v", complete, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "movie.mkv"+stateSuffix)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected state file removed, got %v", err)
+	}
+
+	svc := NewService(root, time.Hour)
+	if _, _, err := svc.WriteChunk("bad.mkv", 0, 2, checksum, strings.NewReader("abc")); err != nil {
+		t.Fatalf("write chunk: %v", err)
+	}
+	if _, _, err := svc.WriteChunk("bad.mkv", 1, 2, "", strings.NewReader("xyz")); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	for _, name := range []string{"bad.mkv", "bad.mkv" + partSuffix, "bad.mkv" + stateSuffix} {
+		if _, err := os.Stat(filepath.Join(root, name)); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected %s removed after mismatch, got %v", name, err)
+		}
+	}
+}
+
+func TestWriteChunk_RejectsOutOfOrderChunks(t *testing.T) {
+	svc := NewService(t.TempDir(), time.Hour)
+
+	if _, _, err := svc.WriteChunk("movie.mkv", 0, 3, "", strings.NewReader("abc")); err != nil {
+		t.Fatalf("write chunk: %v", err)
+	}
+	if _, _, err := svc.WriteChunk("movie.mkv", 2, 3, "", strings.NewReader("ghi")); !errors.Is(err, ErrChunkOutOfOrder) {
+		t.Fatalf("expected skipped chunk refused, got %v", err)
+	}
+	if _, _, err := svc.WriteChunk("movie.mkv", 1, 3, "", strings.NewReader("def")); err != nil {
+		t.Fatalf("expected the expected chunk accepted, got %v", err)
+	}
+	if _, _, err := svc.WriteChunk("movie.mkv", 1, 3, "", strings.NewReader("def")); err != nil {
+		t.Fatalf("expected repeated chunk acknowledged, got %v", err)
+	}
+	if size := svc.PartialSize("movie.mkv"); size != 6 {
+		t.Fatalf("expected repeated chunk not written again, got %d bytes", size)
+	}
+}
+
+func TestStatus_ResumesAfterInterruption(t *testing.T) {
+	root := t.TempDir()
+	chunk := func(i int) string { return strings.Repeat(string(rune('a'+i)), 4) }
+
+	svc := NewService(root, time.Hour)
+	for i := 0; i <= 3; i++ {
+		if _, _, err := svc.WriteChunk("movie.mkv", i, 10, "", strings.NewReader(chunk(i))); err != nil {
+			t.Fatalf("write chunk %d: %v", i, err)
+		}
+	}
+
+	// The server restarts after chunk 3 of 10; the client asks where to go on.
+	svc = NewService(root, time.Hour)
+	progress, err := svc.Status("movie.mkv")
+	if err != nil || progress != (Progress{NextChunk: 4, TotalChunks: 10, ReceivedBytes: 16}) {
+		t.Fatalf("unexpected progress %+v, %v", progress, err)
+	}
+	// Chunk 3 may have been sent again before the client learned it had arrived.
+	if _, _, err := svc.WriteChunk("movie.mkv", 3, 10, "", strings.NewReader(chunk(3))); err != nil {
+		t.Fatalf("expected received chunk acknowledged, got %v", err)
+	}
+	for i := progress.NextChunk; i < 10; i++ {
+		_, complete, err := svc.WriteChunk("movie.mkv", i, 10, "", strings.NewReader(chunk(i)))
+		if err != nil || complete != (i == 9) {
+			t.Fatalf("write chunk %d: complete=%v err=%v", i, complete, err)
+		}
+	}
+
+	var want strings.Builder
+	for i := 0; i < 10; i++ {
+		want.WriteString(chunk(i))
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "movie.mkv")); err != nil || string(data) != want.String() {
+		t.Fatalf("expected resumed file %q, got %q, %v", want.String(), data, err)
+	}
+	if progress, _ := svc.Status("movie.mkv"); progress != (Progress{}) {
+		t.Fatalf("expected no progress after completion, got %+v", progress)
+	}
+}
@@ -0,0 +1,50 @@
+package watchparty
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Hub passwords guard a shared link rather than an account, so they use the
+// lighter OWASP minimum argon2id parameters; each member's key is only
+// verified once per hub (see Authorize).
+const (
+	hubPasswordSaltBytes = 16
+	hubPasswordTime      = 2
+	hubPasswordMemoryKiB = 19 * 1024
+	hubPasswordThreads   = 1
+	hubPasswordKeyBytes  = 32
+	maxHubPasswordLength = 128
+)
+
+// hashHubPassword encodes password as `<salt>$<hash>` (unpadded base64).
+func hashHubPassword(password string) (string, error) {
+	salt := make([]byte, hubPasswordSaltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, hubPasswordTime, hubPasswordMemoryKiB, hubPasswordThreads, hubPasswordKeyBytes)
+	return base64.RawStdEncoding.EncodeToString(salt) + "$" + base64.RawStdEncoding.EncodeToString(key), nil
+}
+
+// verifyHubPassword reports whether password matches a hashHubPassword encoding.
+func verifyHubPassword(password, encoded string) bool {
+	saltPart, keyPart, ok := strings.Cut(encoded, "$")
+	if !ok {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(saltPart)
+	if err != nil || len(salt) == 0 {
+		return false
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(keyPart)
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+	key := argon2.IDKey([]byte(password), salt, hubPasswordTime, hubPasswordMemoryKiB, hubPasswordThreads, uint32(len(expected)))
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
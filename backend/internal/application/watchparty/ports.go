@@ -0,0 +1,10 @@
+package watchparty
+
+import watchpartydomain "evd/internal/domain/watchparty"
+
+// HubStore is an application port for persisting hubs across restarts.
+type HubStore interface {
+	Save(record watchpartydomain.HubRecord) error
+	Load() ([]watchpartydomain.HubRecord, error)
+	Delete(hubID string) error
+}
@@ -2,13 +2,21 @@ package watchparty
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"errors"
+	"log"
 	"math"
+	"net/url"
+	"path"
 	"sort"
 	"strings"
 	"sync"
 	"time"
+
+	mediadomain "evd/internal/domain/media"
+	watchpartydomain "evd/internal/domain/watchparty"
 )
 
 const (
@@ -17,22 +25,86 @@ const (
 	ActionSeek  = "seek"
 	ActionVideo = "video"
 	ActionChat  = "chat"
+	ActionRate  = "rate"
+	// ActionOwner is the control event sent when hub ownership changes.
+	ActionOwner = "owner"
+)
+
+// Ephemeral event types. They are broadcast to subscribers but never stored.
+const (
+	EventTyping   = "typing"
+	EventReaction = "reaction"
+)
+
+// Hub kinds. Library hubs play files served by this server; external hubs only
+// sync playback state for a third-party URL played by the client.
+const (
+	HubKindLibrary  = "library"
+	HubKindExternal = "external"
 )
 
 var (
-	ErrHubNotFound  = errors.New("watch hub not found")
-	ErrInvalidHubID = errors.New("invalid hub id")
-	ErrInvalidInput = errors.New("invalid control payload")
+	ErrHubNotFound        = errors.New("watch hub not found")
+	ErrInvalidHubID       = errors.New("invalid hub id")
+	ErrInvalidInput       = errors.New("invalid control payload")
+	ErrInvalidExternalURL = errors.New("invalid external video url")
+	ErrHubFull            = errors.New("watch hub is full")
+	ErrNotHubOwner        = errors.New("only the hub owner can do this")
+	ErrMemberNotFound     = errors.New("user is not in this watch hub")
+	ErrKicked             = errors.New("removed from this watch hub by its owner")
+	ErrHubForbidden       = errors.New("watch hub password required")
+	ErrHubKeyRateLimited  = errors.New("too many wrong watch hub passwords, try again later")
+)
+
+const (
+	maxChatMessages      = 200
+	maxExternalURLLength = 2048
+
+	// Playback rates accepted by the rate action; hubs start at defaultPlaybackRate.
+	minPlaybackRate     = 0.25
+	maxPlaybackRate     = 4.0
+	defaultPlaybackRate = 1.0
+
+	// recentEventLimit is how many sequenced events a hub keeps for replay
+	// to reconnecting subscribers. It stays below the subscriber channel size.
+	recentEventLimit = 24
+
+	// Failed private hub key checks allowed within hubKeyFailureWindow, per
+	// user and across all users of a hub. Each check costs an argon2id run.
+	hubKeyUserLimit     = 5
+	hubKeyHubLimit      = 30
+	hubKeyFailureWindow = time.Minute
+
+	// typingInterval is the minimum gap between broadcast typing events of a user.
+	typingInterval = 2 * time.Second
+
+	// saveDebounce coalesces bursts of control and chat updates into one store write.
+	saveDebounce = 2 * time.Second
+
+	defaultIdleTTL      = 30 * time.Minute
+	defaultReapInterval = time.Minute
 )
 
-const maxChatMessages = 200
+// reactionEmojis are the reactions React accepts.
+var reactionEmojis = map[string]bool{
+	"👍":  true,
+	"❤️": true,
+	"😂":  true,
+	"😮":  true,
+	"😢":  true,
+	"👏":  true,
+	"🔥":  true,
+	"🎉":  true,
+}
 
-// ControlInput is a player update pushed by a participant.
+// ControlInput is a player update pushed by a participant. PlaybackRate is
+// only read by the rate action.
 type ControlInput struct {
-	Action      string
-	VideoPath   string
-	CurrentTime float64
-	Playing     *bool
+	Action       string
+	VideoPath    string
+	CurrentTime  float64
+	Playing      *bool
+	PlaybackRate float64
 }
 
 // Member represents a current hub participant.
@@ -42,69 +114,281 @@ type Member struct {
 }
 
 // Snapshot contains the current shared playback state.
+//
+// CurrentTime is the playback position as of ServerTime (Unix milliseconds,
+// never decreasing within a hub). While Playing, clients should extrapolate the
+// expected position as CurrentTime + PlaybackRate*(clientNow - ServerTime)/1000,
+// using their estimate of the server clock, and correct local playback when it
+// drifts.
+// UpdatedAt is when the hub state last changed. Preparing is set while the
+// hub's video is still being converted for playback.
 type Snapshot struct {
-	ID          string        `json:"id"`
-	OwnerID     string        `json:"ownerId"`
-	OwnerName   string        `json:"ownerName"`
-	VideoPath   string        `json:"videoPath"`
-	CurrentTime float64       `json:"currentTime"`
-	Playing     bool          `json:"playing"`
-	UpdatedAt   int64         `json:"updatedAt"`
-	Members     []Member      `json:"members"`
-	Messages    []ChatMessage `json:"messages"`
-}
-
-// ChatMessage stores a text entry inside a watch hub.
-type ChatMessage struct {
-	ID        string `json:"id"`
-	UserID    string `json:"userId"`
-	Username  string `json:"username"`
-	Text      string `json:"text"`
-	CreatedAt int64  `json:"createdAt"`
+	ID           string                         `json:"id"`
+	OwnerID      string                         `json:"ownerId"`
+	OwnerName    string                         `json:"ownerName"`
+	Kind         string                         `json:"kind"`
+	VideoPath    string                         `json:"videoPath"`
+	CurrentTime  float64                        `json:"currentTime"`
+	Playing      bool                           `json:"playing"`
+	PlaybackRate float64                        `json:"playbackRate"`
+	UpdatedAt    int64                          `json:"updatedAt"`
+	ServerTime   int64                          `json:"serverTime"`
+	Preparing    bool                           `json:"preparing"`
+	Private      bool                           `json:"private"`
+	Members      []Member                       `json:"members"`
+	Messages     []watchpartydomain.ChatMessage `json:"messages"`
+	MessageCount int                            `json:"messageCount"`
 }
 
 // Event is emitted to subscribers via SSE.
 type Event struct {
-	Type      string       `json:"type"`
-	Action    string       `json:"action,omitempty"`
-	ActorID   string       `json:"actorId,omitempty"`
-	ActorName string       `json:"actorName,omitempty"`
-	Chat      *ChatMessage `json:"chat,omitempty"`
-	Hub       Snapshot     `json:"hub"`
+	// Seq numbers a hub's events in order. Ephemeral events have none, and
+	// sync events carry the latest number without taking a new one.
+	Seq       uint64                        `json:"seq,omitempty"`
+	Type      string                        `json:"type"`
+	Action    string                        `json:"action,omitempty"`
+	ActorID   string                        `json:"actorId,omitempty"`
+	ActorName string                        `json:"actorName,omitempty"`
+	Chat      *watchpartydomain.ChatMessage `json:"chat,omitempty"`
+	Emoji     string                        `json:"emoji,omitempty"`
+	Hub       Snapshot                      `json:"hub"`
 }
 
 type hub struct {
 	ID        string
 	OwnerID   string
 	OwnerName string
+	Kind      string
 
-	VideoPath   string
-	CurrentTime float64
-	Playing     bool
-	UpdatedAt   time.Time
+	VideoPath    string
+	CurrentTime  float64
+	Playing      bool
+	PlaybackRate float64
+	UpdatedAt    time.Time
+
+	Preparing bool
+
+	// passwordHash, when set, makes the hub private; see Authorize.
+	passwordHash string
+	// granted holds members whose key has been verified.
+	granted map[string]bool
+	// keyDigest is the SHA-256 of the last key that verified, so members
+	// presenting the same key skip the argon2id derivation.
+	keyDigest []byte
+	// keyFailures holds recent failed key checks per user, and hubFailures
+	// those of all users; see hubKeyUserLimit and hubKeyHubLimit.
+	keyFailures map[string][]time.Time
+	hubFailures []time.Time
+
+	// positionAt is the instant CurrentTime refers to; while playing the
+	// position advances from there in real time.
+	positionAt time.Time
+	// lastServerTime keeps snapshot server times monotonic across clock steps.
+	lastServerTime int64
 
 	memberRefs map[string]int
 	memberInfo map[string]string
-	messages   []ChatMessage
+	messages   []watchpartydomain.ChatMessage
+
+	subscribers map[string]*subscriber
+	// kicked holds users the owner removed; they cannot rejoin. It is persisted.
+	kicked map[string]bool
+	// lastTyping throttles typing events per user.
+	lastTyping map[string]time.Time
+	// memberSince orders current members by when they joined, from joinSeq.
+	memberSince map[string]uint64
+	joinSeq     uint64
+
+	// seq is the last event sequence number; recent holds the latest
+	// sequenced events for SubscribeAfter.
+	seq    uint64
+	recent []Event
 
-	subscribers map[string]chan Event
+	// saveTimer is the pending debounced store write, if any.
+	saveTimer *time.Timer
+}
+
+// subscriber is one event channel of a member. closed guards against a
+// second close when Kick has already ended the subscription.
+type subscriber struct {
+	userID string
+	ch     chan Event
+	closed bool
+}
+
+// closeLocked closes the channel once; callers hold Service.mu.
+func (sub *subscriber) closeLocked() {
+	if !sub.closed {
+		sub.closed = true
+		close(sub.ch)
+	}
 }
 
 // Service stores hubs in memory and fan-outs control events.
+// With a HubStore, hub state is also persisted so hubs survive restarts.
 type Service struct {
 	mu   sync.Mutex
 	hubs map[string]*hub
+
+	store  HubStore
+	logger *log.Logger
+	// saveMu serializes store writes so a slow write never lands after a newer one.
+	saveMu sync.Mutex
+
+	idleTTL    time.Duration
+	maxMembers int
+	// autoTransfer hands ownership to the earliest-joined member when the owner leaves.
+	autoTransfer bool
+	videoAccess  func(userID, videoPath string) (string, error)
+	stop         chan struct{}
+	reaperWG     sync.WaitGroup
+	closeOnce    sync.Once
+}
+
+// Options controls hub lifetime. Zero values select the defaults.
+type Options struct {
+	// IdleTTL is how long a hub without subscribers is kept after its last update.
+	IdleTTL time.Duration
+	// ReapInterval is how often idle hubs are looked for.
+	ReapInterval time.Duration
+	// MaxMembers caps the distinct users subscribed to a hub; the owner can
+	// always join. Zero means no limit.
+	MaxMembers int
+	// AutoTransferOwnership hands a hub to its earliest-joined remaining
+	// member when the owner's last subscription ends.
+	AutoTransferOwnership bool
+	// VideoAccess resolves a library video a member switches the hub to and
+	// fails when the member may not play it. Nil accepts any path with a
+	// supported video extension.
+	VideoAccess func(userID, videoPath string) (string, error)
+}
+
+// NewService creates an in-memory watch party service and starts its idle hub
+// reaper. Call Close to stop it.
+func NewService(opts Options) *Service {
+	if opts.IdleTTL <= 0 {
+		opts.IdleTTL = defaultIdleTTL
+	}
+	if opts.ReapInterval <= 0 {
+		opts.ReapInterval = defaultReapInterval
+	}
+
+	s := &Service{
+		hubs:         map[string]*hub{},
+		idleTTL:      opts.IdleTTL,
+		maxMembers:   opts.MaxMembers,
+		autoTransfer: opts.AutoTransferOwnership,
+		videoAccess:  opts.VideoAccess,
+		stop:         make(chan struct{}),
+	}
+	s.reaperWG.Add(1)
+	go s.runReaper(opts.ReapInterval)
+	return s
 }
 
-// NewService creates a watch party service.
-func NewService() *Service {
-	return &Service{
-		hubs: map[string]*hub{},
+// NewServiceWithStore creates a watch party service persisting hubs to store and
+// restores the hubs saved there. Restored hubs start without members.
+func NewServiceWithStore(store HubStore, logger *log.Logger, opts Options) (*Service, error) {
+	records, err := store.Load()
+	if err != nil {
+		return nil, err
 	}
+
+	s := NewService(opts)
+	s.store = store
+	s.logger = logger
+	s.mu.Lock()
+	for _, record := range records {
+		if record.ID == "" {
+			continue
+		}
+		s.hubs[record.ID] = hubFromRecord(record)
+	}
+	s.mu.Unlock()
+	return s, nil
 }
 
-// CreateHub creates a new watch hub.
-func (s *Service) CreateHub(ownerID, ownerName, videoPath string, currentTime float64, playing bool) (Snapshot, error) {
+// Close stops the idle hub reaper. It is safe to call more than once.
+func (s *Service) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		s.reaperWG.Wait()
+	})
+}
+
+func (s *Service) runReaper(interval time.Duration) {
+	defer s.reaperWG.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.reapIdle(now)
+		}
+	}
+}
+
+// reapIdle removes hubs that have had no subscribers for longer than the idle TTL.
+// The subscriber check and removal happen under one lock, so a member joining
+// concurrently either keeps the hub alive or gets ErrHubNotFound.
+func (s *Service) reapIdle(now time.Time) {
+	s.mu.Lock()
+	expired := make([]string, 0)
+	for hubID, h := range s.hubs {
+		if len(h.subscribers) > 0 || now.Sub(h.UpdatedAt) < s.idleTTL {
+			continue
+		}
+		if h.saveTimer != nil {
+			h.saveTimer.Stop()
+			h.saveTimer = nil
+		}
+		delete(s.hubs, hubID)
+		expired = append(expired, hubID)
+	}
+	s.mu.Unlock()
+
+	if s.store == nil || len(expired) == 0 {
+		return
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	for _, hubID := range expired {
+		if err := s.store.Delete(hubID); err != nil && s.logger != nil {
+			s.logger.Printf("Watch hub delete failed: %s: %v", hubID, err)
+		}
+	}
+}
+
+// Flush writes every hub with a pending debounced save to the store immediately.
+func (s *Service) Flush() {
+	if s.store == nil {
+		return
+	}
+
+	s.mu.Lock()
+	pending := make([]string, 0)
+	for hubID, h := range s.hubs {
+		if h.saveTimer != nil && h.saveTimer.Stop() {
+			h.saveTimer = nil
+			pending = append(pending, hubID)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, hubID := range pending {
+		s.saveHub(hubID)
+	}
+}
+
+// CreateHub creates a new watch hub of the given kind. Library video paths are
+// checked and resolved through VideoAccess for the owner, like a video switch;
+// external hubs validate videoPath as an http(s) URL. A non-empty password
+// makes the hub private: other users must pass it to Authorize.
+func (s *Service) CreateHub(ownerID, ownerName, kind, videoPath string, currentTime float64, playing bool, password string) (Snapshot, error) {
 	ownerID = strings.TrimSpace(ownerID)
 	ownerName = strings.TrimSpace(ownerName)
 	videoPath = strings.TrimSpace(videoPath)
@@ -112,6 +396,36 @@ func (s *Service) CreateHub(ownerID, ownerName, videoPath string, currentTime fl
 		return Snapshot{}, ErrInvalidInput
 	}
 
+	switch kind {
+	case "", HubKindLibrary:
+		kind = HubKindLibrary
+		resolved, err := s.resolveLibraryVideo(ownerID, videoPath)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		videoPath = resolved
+	case HubKindExternal:
+		normalized, err := NormalizeExternalURL(videoPath)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		videoPath = normalized
+	default:
+		return Snapshot{}, ErrInvalidInput
+	}
+
+	if len(password) > maxHubPasswordLength {
+		return Snapshot{}, ErrInvalidInput
+	}
+	var passwordHash string
+	if password != "" {
+		hashed, err := hashHubPassword(password)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		passwordHash = hashed
+	}
+
 	hubID, err := randomID(10)
 	if err != nil {
 		return Snapshot{}, err
@@ -119,26 +433,224 @@ func (s *Service) CreateHub(ownerID, ownerName, videoPath string, currentTime fl
 
 	now := time.Now()
 	h := &hub{
-		ID:          hubID,
-		OwnerID:     ownerID,
-		OwnerName:   ownerName,
-		VideoPath:   videoPath,
-		CurrentTime: normalizeTime(currentTime),
-		Playing:     playing,
-		UpdatedAt:   now,
-		memberRefs:  map[string]int{},
-		memberInfo:  map[string]string{},
-		messages:    []ChatMessage{},
-		subscribers: map[string]chan Event{},
+		passwordHash: passwordHash,
+		ID:           hubID,
+		OwnerID:      ownerID,
+		OwnerName:    ownerName,
+		Kind:         kind,
+		VideoPath:    videoPath,
+		CurrentTime:  normalizeTime(currentTime),
+		Playing:      playing,
+		PlaybackRate: defaultPlaybackRate,
+		UpdatedAt:    now,
+		positionAt:   now,
+		memberRefs:   map[string]int{},
+		memberInfo:   map[string]string{},
+		messages:     []watchpartydomain.ChatMessage{},
+		subscribers:  map[string]*subscriber{},
+		kicked:       map[string]bool{},
+		lastTyping:   map[string]time.Time{},
+		memberSince:  map[string]uint64{},
+		granted:      map[string]bool{},
+		keyFailures:  map[string][]time.Time{},
 	}
 
 	s.mu.Lock()
 	s.hubs[hubID] = h
+	s.scheduleSaveLocked(h)
 	s.mu.Unlock()
 
 	return snapshotFromHub(h), nil
 }
 
+// SetPreparing marks whether the hub's video is still being prepared and tells
+// subscribers when that changes. Preparing is live state and is not persisted.
+func (s *Service) SetPreparing(hubID string, preparing bool) error {
+	hubID = strings.TrimSpace(hubID)
+	if hubID == "" {
+		return ErrInvalidHubID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.hubs[hubID]
+	if !ok {
+		return ErrHubNotFound
+	}
+	if h.Preparing == preparing {
+		return nil
+	}
+	h.Preparing = preparing
+	h.UpdatedAt = time.Now()
+
+	action := "ready"
+	if preparing {
+		action = "preparing"
+	}
+	s.broadcastLocked(h, Event{
+		Type:   "state",
+		Action: action,
+		Hub:    snapshotFromHub(h),
+	})
+	return nil
+}
+
+// Authorize checks that userID may use a hub. Passwordless hubs and the owner
+// are always allowed; anyone else needs the hub password as key, and is
+// remembered once it matched. It fails with ErrHubForbidden otherwise.
+func (s *Service) Authorize(hubID, userID, key string) error {
+	hubID = strings.TrimSpace(hubID)
+	userID = strings.TrimSpace(userID)
+	if hubID == "" {
+		return ErrInvalidHubID
+	}
+
+	s.mu.Lock()
+	h, ok := s.hubs[hubID]
+	if !ok {
+		s.mu.Unlock()
+		return ErrHubNotFound
+	}
+	if h.passwordHash == "" || (userID != "" && (userID == h.OwnerID || h.granted[userID])) {
+		s.mu.Unlock()
+		return nil
+	}
+	if key == "" || len(key) > maxHubPasswordLength {
+		s.mu.Unlock()
+		return ErrHubForbidden
+	}
+	digest := sha256.Sum256([]byte(key))
+	if h.keyDigest != nil && subtle.ConstantTimeCompare(digest[:], h.keyDigest) == 1 {
+		h.grantLocked(userID)
+		s.mu.Unlock()
+		return nil
+	}
+	// The attempt counts as failed until it verifies, so parallel guesses
+	// cannot exceed the limits while their hashes run.
+	now := time.Now()
+	if !h.recordKeyAttemptLocked(userID, now) {
+		s.mu.Unlock()
+		return ErrHubKeyRateLimited
+	}
+	passwordHash := h.passwordHash
+	s.mu.Unlock()
+
+	// Hashing is slow, so it runs outside the lock.
+	if !verifyHubPassword(key, passwordHash) {
+		return ErrHubForbidden
+	}
+	s.mu.Lock()
+	if current, ok := s.hubs[hubID]; ok && current.passwordHash == passwordHash {
+		current.keyDigest = digest[:]
+		delete(current.keyFailures, userID)
+		current.grantLocked(userID)
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// grantLocked lets userID skip key checks from now on.
+func (h *hub) grantLocked(userID string) {
+	if userID != "" {
+		h.granted[userID] = true
+	}
+}
+
+// recordKeyAttemptLocked counts a key check by userID at now, dropping checks
+// older than hubKeyFailureWindow. It reports false without counting when the
+// user or the hub already reached its limit.
+func (h *hub) recordKeyAttemptLocked(userID string, now time.Time) bool {
+	recent := func(times []time.Time) []time.Time {
+		kept := times[:0]
+		for _, at := range times {
+			if now.Sub(at) < hubKeyFailureWindow {
+				kept = append(kept, at)
+			}
+		}
+		return kept
+	}
+	for id, times := range h.keyFailures {
+		if times = recent(times); len(times) == 0 {
+			delete(h.keyFailures, id)
+		} else {
+			h.keyFailures[id] = times
+		}
+	}
+	h.hubFailures = recent(h.hubFailures)
+
+	if len(h.keyFailures[userID]) >= hubKeyUserLimit || len(h.hubFailures) >= hubKeyHubLimit {
+		return false
+	}
+	h.keyFailures[userID] = append(h.keyFailures[userID], now)
+	h.hubFailures = append(h.hubFailures, now)
+	return true
+}
+
+// ListHubsForUser returns the hubs userID owns or is subscribed to, most
+// recently updated first. The snapshots leave out the chat history;
+// MessageCount tells its length.
+func (s *Service) ListHubsForUser(userID string) []Snapshot {
+	userID = strings.TrimSpace(userID)
+	hubs := []Snapshot{}
+	if userID == "" {
+		return hubs
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, h := range s.hubs {
+		if h.OwnerID != userID && h.memberRefs[userID] == 0 {
+			continue
+		}
+		hubs = append(hubs, summaryFromHub(h))
+	}
+	sort.Slice(hubs, func(i, j int) bool {
+		if hubs[i].UpdatedAt != hubs[j].UpdatedAt {
+			return hubs[i].UpdatedAt > hubs[j].UpdatedAt
+		}
+		return hubs[i].ID < hubs[j].ID
+	})
+	return hubs
+}
+
+// checkVideo validates the video a library hub is switched to and resolves it
+// through VideoAccess for userID. External URLs are checked by Control. The
+// callback may touch storage, so it runs without s.mu.
+func (s *Service) checkVideo(hubID, userID, videoPath string) (string, error) {
+	s.mu.Lock()
+	h, ok := s.hubs[hubID]
+	external := ok && h.Kind == HubKindExternal
+	s.mu.Unlock()
+	if !ok {
+		return "", ErrHubNotFound
+	}
+
+	videoPath = strings.TrimSpace(videoPath)
+	if external || videoPath == "" {
+		return videoPath, nil
+	}
+	return s.resolveLibraryVideo(userID, videoPath)
+}
+
+// resolveLibraryVideo checks the extension of a library video and resolves it
+// through VideoAccess for userID. Missing and forbidden videos fail alike, so
+// the answer does not reveal which files exist.
+func (s *Service) resolveLibraryVideo(userID, videoPath string) (string, error) {
+	if !mediadomain.IsSupportedVideoExt(path.Ext(videoPath)) {
+		return "", ErrInvalidInput
+	}
+	if s.videoAccess == nil {
+		return videoPath, nil
+	}
+	resolved, err := s.videoAccess(userID, videoPath)
+	if err != nil {
+		return "", ErrInvalidInput
+	}
+	return resolved, nil
+}
+
 // GetHub returns current state for a hub.
 func (s *Service) GetHub(hubID string) (Snapshot, error) {
 	hubID = strings.TrimSpace(hubID)
@@ -157,7 +669,17 @@ func (s *Service) GetHub(hubID string) (Snapshot, error) {
 }
 
 // Subscribe joins a hub and returns an event channel + cleanup callback.
+// It fails with ErrHubFull when a new member would exceed the member cap and
+// with ErrKicked for users the owner removed. The channel is closed by cleanup
+// or when the member is kicked.
 func (s *Service) Subscribe(hubID, userID, username string) (<-chan Event, func(), error) {
+	return s.SubscribeAfter(hubID, userID, username, 0)
+}
+
+// SubscribeAfter is Subscribe for a client resuming after event lastSeq. When
+// the hub still holds every later event, those are replayed instead of the
+// initial sync event, so nothing is missed or shown twice.
+func (s *Service) SubscribeAfter(hubID, userID, username string, lastSeq uint64) (<-chan Event, func(), error) {
 	hubID = strings.TrimSpace(hubID)
 	userID = strings.TrimSpace(userID)
 	username = strings.TrimSpace(username)
@@ -181,16 +703,39 @@ func (s *Service) Subscribe(hubID, userID, username string) (<-chan Event, func(
 		return nil, nil, ErrHubNotFound
 	}
 
-	h.subscribers[subID] = ch
+	if h.kicked[userID] {
+		s.mu.Unlock()
+		close(ch)
+		return nil, nil, ErrKicked
+	}
+	if s.maxMembers > 0 && h.memberRefs[userID] == 0 && userID != h.OwnerID && len(h.memberRefs) >= s.maxMembers {
+		s.mu.Unlock()
+		close(ch)
+		return nil, nil, ErrHubFull
+	}
+
+	sub := &subscriber{userID: userID, ch: ch}
+	h.subscribers[subID] = sub
+	if h.memberRefs[userID] == 0 {
+		h.joinSeq++
+		h.memberSince[userID] = h.joinSeq
+	}
 	h.memberRefs[userID]++
 	h.memberInfo[userID] = username
 	h.UpdatedAt = time.Now()
 
 	snapshot := snapshotFromHub(h)
-	ch <- Event{
-		Type:   "sync",
-		Action: "sync",
-		Hub:    snapshot,
+	if missed, ok := h.eventsAfter(lastSeq); ok {
+		for _, event := range missed {
+			ch <- event
+		}
+	} else {
+		ch <- Event{
+			Seq:    h.seq,
+			Type:   "sync",
+			Action: "sync",
+			Hub:    snapshot,
+		}
 	}
 
 	joinEvent := Event{
@@ -209,19 +754,22 @@ func (s *Service) Subscribe(hubID, userID, username string) (<-chan Event, func(
 			defer s.mu.Unlock()
 
 			current, exists := s.hubs[hubID]
-			if !exists {
-				close(ch)
+			if !exists || current.subscribers[subID] != sub {
+				// The hub is gone or Kick already removed this member.
+				sub.closeLocked()
 				return
 			}
 
 			delete(current.subscribers, subID)
-			close(ch)
+			sub.closeLocked()
 
 			if refs := current.memberRefs[userID]; refs > 1 {
 				current.memberRefs[userID] = refs - 1
 			} else {
 				delete(current.memberRefs, userID)
 				delete(current.memberInfo, userID)
+				delete(current.lastTyping, userID)
+				delete(current.memberSince, userID)
 			}
 			current.UpdatedAt = time.Now()
 
@@ -233,6 +781,12 @@ func (s *Service) Subscribe(hubID, userID, username string) (<-chan Event, func(
 				Hub:       snapshotFromHub(current),
 			}
 			s.broadcastLocked(current, leaveEvent)
+
+			if s.autoTransfer && userID == current.OwnerID && current.memberRefs[userID] == 0 {
+				if heir := current.earliestMember(); heir != "" {
+					s.transferLocked(current, heir)
+				}
+			}
 		})
 	}
 
@@ -248,6 +802,13 @@ func (s *Service) Control(hubID, userID, username string, input ControlInput) (E
 	if hubID == "" || userID == "" || username == "" {
 		return Event{}, ErrInvalidInput
 	}
+	if action == ActionVideo {
+		videoPath, err := s.checkVideo(hubID, userID, input.VideoPath)
+		if err != nil {
+			return Event{}, err
+		}
+		input.VideoPath = videoPath
+	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -256,6 +817,14 @@ func (s *Service) Control(hubID, userID, username string, input ControlInput) (E
 	if !ok {
 		return Event{}, ErrHubNotFound
 	}
+	if err := h.checkMember(userID); err != nil {
+		return Event{}, err
+	}
+
+	// Actions without an explicit time keep the position the hub has reached.
+	now := time.Now()
+	h.CurrentTime = h.position(now)
+	h.positionAt = now
 
 	switch action {
 	case ActionPlay:
@@ -273,11 +842,24 @@ func (s *Service) Control(hubID, userID, username string, input ControlInput) (E
 			return Event{}, ErrInvalidInput
 		}
 		h.CurrentTime = normalizeTime(input.CurrentTime)
+	case ActionRate:
+		rate := input.PlaybackRate
+		if !isFiniteTime(rate) || rate < minPlaybackRate || rate > maxPlaybackRate {
+			return Event{}, ErrInvalidInput
+		}
+		h.PlaybackRate = rate
 	case ActionVideo:
 		videoPath := strings.TrimSpace(input.VideoPath)
 		if videoPath == "" {
 			return Event{}, ErrInvalidInput
 		}
+		if h.Kind == HubKindExternal {
+			normalized, err := NormalizeExternalURL(videoPath)
+			if err != nil {
+				return Event{}, err
+			}
+			videoPath = normalized
+		}
 		h.VideoPath = videoPath
 		if isFiniteTime(input.CurrentTime) {
 			h.CurrentTime = normalizeTime(input.CurrentTime)
@@ -293,7 +875,8 @@ func (s *Service) Control(hubID, userID, username string, input ControlInput) (E
 		return Event{}, ErrInvalidInput
 	}
 
-	h.UpdatedAt = time.Now()
+	h.UpdatedAt = now
+	s.scheduleSaveLocked(h)
 	event := Event{
 		Type:      "control",
 		Action:    action,
@@ -326,6 +909,9 @@ func (s *Service) Chat(hubID, userID, username, text string) (Event, error) {
 	if !ok {
 		return Event{}, ErrHubNotFound
 	}
+	if err := h.checkMember(userID); err != nil {
+		return Event{}, err
+	}
 
 	messageID, err := randomID(14)
 	if err != nil {
@@ -333,7 +919,7 @@ func (s *Service) Chat(hubID, userID, username, text string) (Event, error) {
 	}
 
 	now := time.Now()
-	message := ChatMessage{
+	message := watchpartydomain.ChatMessage{
 		ID:        messageID,
 		UserID:    userID,
 		Username:  username,
@@ -343,9 +929,10 @@ func (s *Service) Chat(hubID, userID, username, text string) (Event, error) {
 
 	h.messages = append(h.messages, message)
 	if len(h.messages) > maxChatMessages {
-		h.messages = append([]ChatMessage(nil), h.messages[len(h.messages)-maxChatMessages:]...)
+		h.messages = append([]watchpartydomain.ChatMessage(nil), h.messages[len(h.messages)-maxChatMessages:]...)
 	}
 	h.UpdatedAt = now
+	s.scheduleSaveLocked(h)
 
 	event := Event{
 		Type:      "chat",
@@ -360,17 +947,367 @@ func (s *Service) Chat(hubID, userID, username, text string) (Event, error) {
 	return event, nil
 }
 
+// Typing tells subscribers that a user is writing a chat message. Typing
+// events are ephemeral, and calls within typingInterval of the user's last
+// broadcast one are dropped.
+func (s *Service) Typing(hubID, userID, username string) error {
+	hubID = strings.TrimSpace(hubID)
+	userID = strings.TrimSpace(userID)
+	username = strings.TrimSpace(username)
+	if hubID == "" || userID == "" || username == "" {
+		return ErrInvalidInput
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.hubs[hubID]
+	if !ok {
+		return ErrHubNotFound
+	}
+	if err := h.checkMember(userID); err != nil {
+		return err
+	}
+	now := time.Now()
+	if last, seen := h.lastTyping[userID]; seen && now.Sub(last) < typingInterval {
+		return nil
+	}
+	h.lastTyping[userID] = now
+
+	s.broadcastLocked(h, Event{
+		Type:      EventTyping,
+		ActorID:   userID,
+		ActorName: username,
+		Hub:       snapshotFromHub(h),
+	})
+	return nil
+}
+
+// React broadcasts an emoji reaction from the reactionEmojis allowlist.
+// Reactions are ephemeral and never join the chat history.
+func (s *Service) React(hubID, userID, username, emoji string) error {
+	hubID = strings.TrimSpace(hubID)
+	userID = strings.TrimSpace(userID)
+	username = strings.TrimSpace(username)
+	emoji = strings.TrimSpace(emoji)
+	if hubID == "" || userID == "" || username == "" || !reactionEmojis[emoji] {
+		return ErrInvalidInput
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.hubs[hubID]
+	if !ok {
+		return ErrHubNotFound
+	}
+	if err := h.checkMember(userID); err != nil {
+		return err
+	}
+
+	s.broadcastLocked(h, Event{
+		Type:      EventReaction,
+		ActorID:   userID,
+		ActorName: username,
+		Emoji:     emoji,
+		Hub:       snapshotFromHub(h),
+	})
+	return nil
+}
+
+// Kick removes targetUserID from a hub on behalf of its owner. The target's
+// subscriptions receive the "kick" presence event and are then closed, and the
+// target cannot rejoin the hub.
+func (s *Service) Kick(hubID, ownerID, targetUserID string) error {
+	hubID = strings.TrimSpace(hubID)
+	ownerID = strings.TrimSpace(ownerID)
+	targetUserID = strings.TrimSpace(targetUserID)
+	if hubID == "" {
+		return ErrInvalidHubID
+	}
+	if ownerID == "" || targetUserID == "" {
+		return ErrInvalidInput
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.hubs[hubID]
+	if !ok {
+		return ErrHubNotFound
+	}
+	if h.OwnerID != ownerID {
+		return ErrNotHubOwner
+	}
+	if targetUserID == ownerID {
+		return ErrInvalidInput
+	}
+	username, member := h.memberInfo[targetUserID]
+	if !member {
+		return ErrMemberNotFound
+	}
+
+	h.kicked[targetUserID] = true
+	delete(h.memberRefs, targetUserID)
+	delete(h.memberInfo, targetUserID)
+	delete(h.lastTyping, targetUserID)
+	delete(h.memberSince, targetUserID)
+	h.UpdatedAt = time.Now()
+	s.scheduleSaveLocked(h)
+
+	s.broadcastLocked(h, Event{
+		Type:      "presence",
+		Action:    "kick",
+		ActorID:   targetUserID,
+		ActorName: username,
+		Hub:       snapshotFromHub(h),
+	})
+	for subID, sub := range h.subscribers {
+		if sub.userID == targetUserID {
+			delete(h.subscribers, subID)
+			sub.closeLocked()
+		}
+	}
+	return nil
+}
+
+// TransferOwnership hands a hub to newOwnerID, who must be a current member.
+// Only the current owner may transfer; subscribers get an "owner" control event.
+func (s *Service) TransferOwnership(hubID, currentOwnerID, newOwnerID string) error {
+	hubID = strings.TrimSpace(hubID)
+	currentOwnerID = strings.TrimSpace(currentOwnerID)
+	newOwnerID = strings.TrimSpace(newOwnerID)
+	if hubID == "" {
+		return ErrInvalidHubID
+	}
+	if currentOwnerID == "" || newOwnerID == "" || newOwnerID == currentOwnerID {
+		return ErrInvalidInput
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.hubs[hubID]
+	if !ok {
+		return ErrHubNotFound
+	}
+	if h.OwnerID != currentOwnerID {
+		return ErrNotHubOwner
+	}
+	if _, member := h.memberInfo[newOwnerID]; !member {
+		return ErrMemberNotFound
+	}
+
+	s.transferLocked(h, newOwnerID)
+	return nil
+}
+
+// transferLocked makes member userID the owner of h and tells subscribers.
+func (s *Service) transferLocked(h *hub, userID string) {
+	h.OwnerID = userID
+	h.OwnerName = h.memberInfo[userID]
+	h.UpdatedAt = time.Now()
+	s.scheduleSaveLocked(h)
+
+	s.broadcastLocked(h, Event{
+		Type:      "control",
+		Action:    ActionOwner,
+		ActorID:   h.OwnerID,
+		ActorName: h.OwnerName,
+		Hub:       snapshotFromHub(h),
+	})
+}
+
+// earliestMember returns the current member that joined first, or "".
+func (h *hub) earliestMember() string {
+	earliest := ""
+	for userID, since := range h.memberSince {
+		if earliest == "" || since < h.memberSince[earliest] {
+			earliest = userID
+		}
+	}
+	return earliest
+}
+
 func (s *Service) broadcastLocked(h *hub, event Event) {
+	if event.Type != EventTyping && event.Type != EventReaction {
+		h.seq++
+		event.Seq = h.seq
+		h.recent = append(h.recent, event)
+		if len(h.recent) > recentEventLimit {
+			h.recent = append(h.recent[:0:0], h.recent[len(h.recent)-recentEventLimit:]...)
+		}
+	}
 	for _, subscriber := range h.subscribers {
 		select {
-		case subscriber <- event:
+		case subscriber.ch <- event:
 		default:
 			// Drop stale events for slow clients.
 		}
 	}
 }
 
+// eventsAfter returns the buffered events after lastSeq. ok is false when
+// lastSeq is unset, ahead of the hub (as after a restart) or older than the
+// buffer, and the client needs a full sync instead.
+func (h *hub) eventsAfter(lastSeq uint64) ([]Event, bool) {
+	if lastSeq == 0 || lastSeq > h.seq {
+		return nil, false
+	}
+	if lastSeq < h.seq && (len(h.recent) == 0 || h.recent[0].Seq > lastSeq+1) {
+		return nil, false
+	}
+	var missed []Event
+	for _, event := range h.recent {
+		if event.Seq > lastSeq {
+			missed = append(missed, event)
+		}
+	}
+	return missed, true
+}
+
+// scheduleSaveLocked arranges for h to be persisted after the debounce window.
+func (s *Service) scheduleSaveLocked(h *hub) {
+	if s.store == nil || h.saveTimer != nil {
+		return
+	}
+	hubID := h.ID
+	h.saveTimer = time.AfterFunc(saveDebounce, func() {
+		s.mu.Lock()
+		if current, ok := s.hubs[hubID]; ok {
+			current.saveTimer = nil
+		}
+		s.mu.Unlock()
+		s.saveHub(hubID)
+	})
+}
+
+func (s *Service) saveHub(hubID string) {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	h, ok := s.hubs[hubID]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	record := recordFromHub(h)
+	s.mu.Unlock()
+
+	if err := s.store.Save(record); err != nil && s.logger != nil {
+		s.logger.Printf("Watch hub save failed: %s: %v", hubID, err)
+	}
+}
+
+func recordFromHub(h *hub) watchpartydomain.HubRecord {
+	messages := make([]watchpartydomain.ChatMessage, len(h.messages))
+	copy(messages, h.messages)
+	kicked := make([]string, 0, len(h.kicked))
+	for userID := range h.kicked {
+		kicked = append(kicked, userID)
+	}
+	sort.Strings(kicked)
+
+	return watchpartydomain.HubRecord{
+		ID:           h.ID,
+		OwnerID:      h.OwnerID,
+		OwnerName:    h.OwnerName,
+		Kind:         h.Kind,
+		VideoPath:    h.VideoPath,
+		CurrentTime:  h.position(time.Now()),
+		Playing:      h.Playing,
+		PlaybackRate: h.PlaybackRate,
+		PasswordHash: h.passwordHash,
+		Seq:          h.seq,
+		UpdatedAt:    h.UpdatedAt.UnixMilli(),
+		Messages:     messages,
+		Kicked:       kicked,
+	}
+}
+
+func hubFromRecord(record watchpartydomain.HubRecord) *hub {
+	kind := record.Kind
+	if kind == "" {
+		kind = HubKindLibrary
+	}
+	messages := record.Messages
+	if messages == nil {
+		messages = []watchpartydomain.ChatMessage{}
+	}
+	if len(messages) > maxChatMessages {
+		messages = messages[len(messages)-maxChatMessages:]
+	}
+	kicked := make(map[string]bool, len(record.Kicked))
+	for _, userID := range record.Kicked {
+		kicked[userID] = true
+	}
+	playbackRate := record.PlaybackRate
+	if !isFiniteTime(playbackRate) || playbackRate < minPlaybackRate || playbackRate > maxPlaybackRate {
+		playbackRate = defaultPlaybackRate
+	}
+
+	return &hub{
+		ID:           record.ID,
+		OwnerID:      record.OwnerID,
+		OwnerName:    record.OwnerName,
+		Kind:         kind,
+		VideoPath:    record.VideoPath,
+		CurrentTime:  normalizeTime(record.CurrentTime),
+		Playing:      record.Playing,
+		PlaybackRate: playbackRate,
+		UpdatedAt:    time.UnixMilli(record.UpdatedAt),
+		passwordHash: record.PasswordHash,
+		seq:          record.Seq,
+		// Playback does not advance while the server is down.
+		positionAt:  time.Now(),
+		memberRefs:  map[string]int{},
+		memberInfo:  map[string]string{},
+		messages:    messages,
+		subscribers: map[string]*subscriber{},
+		kicked:      kicked,
+		lastTyping:  map[string]time.Time{},
+		memberSince: map[string]uint64{},
+		granted:     map[string]bool{},
+		keyFailures: map[string][]time.Time{},
+	}
+}
+
+// checkMember allows hub actions only from its owner and current members.
+// Users the owner kicked get ErrKicked, everyone else not subscribed
+// ErrMemberNotFound.
+func (h *hub) checkMember(userID string) error {
+	switch {
+	case h.kicked[userID]:
+		return ErrKicked
+	case userID == h.OwnerID, h.memberRefs[userID] > 0:
+		return nil
+	default:
+		return ErrMemberNotFound
+	}
+}
+
+// position returns the playback position at now, advancing it while playing.
+func (h *hub) position(now time.Time) float64 {
+	if !h.Playing {
+		return h.CurrentTime
+	}
+	elapsed := now.Sub(h.positionAt).Seconds()
+	if elapsed <= 0 {
+		return h.CurrentTime
+	}
+	return h.CurrentTime + elapsed*h.PlaybackRate
+}
+
 func snapshotFromHub(h *hub) Snapshot {
+	snapshot := summaryFromHub(h)
+	snapshot.Messages = make([]watchpartydomain.ChatMessage, len(h.messages))
+	copy(snapshot.Messages, h.messages)
+	return snapshot
+}
+
+// summaryFromHub is snapshotFromHub without the chat history.
+func summaryFromHub(h *hub) Snapshot {
 	memberIDs := make([]string, 0, len(h.memberRefs))
 	for memberID := range h.memberRefs {
 		memberIDs = append(memberIDs, memberID)
@@ -385,20 +1322,48 @@ func snapshotFromHub(h *hub) Snapshot {
 		})
 	}
 
-	messages := make([]ChatMessage, len(h.messages))
-	copy(messages, h.messages)
+	serverTime := time.Now().UnixMilli()
+	if serverTime < h.lastServerTime {
+		serverTime = h.lastServerTime
+	}
+	h.lastServerTime = serverTime
 
 	return Snapshot{
-		ID:          h.ID,
-		OwnerID:     h.OwnerID,
-		OwnerName:   h.OwnerName,
-		VideoPath:   h.VideoPath,
-		CurrentTime: h.CurrentTime,
-		Playing:     h.Playing,
-		UpdatedAt:   h.UpdatedAt.UnixMilli(),
-		Members:     members,
-		Messages:    messages,
+		ID:           h.ID,
+		OwnerID:      h.OwnerID,
+		OwnerName:    h.OwnerName,
+		Kind:         h.Kind,
+		VideoPath:    h.VideoPath,
+		CurrentTime:  h.position(time.UnixMilli(serverTime)),
+		Playing:      h.Playing,
+		PlaybackRate: h.PlaybackRate,
+		UpdatedAt:    h.UpdatedAt.UnixMilli(),
+		ServerTime:   serverTime,
+		Preparing:    h.Preparing,
+		Private:      h.passwordHash != "",
+		Members:      members,
+		MessageCount: len(h.messages),
+	}
+}
+
+// NormalizeExternalURL validates an external video URL for external hubs.
+// The server never fetches it; only absolute http(s) URLs without credentials are accepted.
+func NormalizeExternalURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || len(raw) > maxExternalURLLength {
+		return "", ErrInvalidExternalURL
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", ErrInvalidExternalURL
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if (scheme != "http" && scheme != "https") || u.Opaque != "" || u.User != nil || u.Hostname() == "" {
+		return "", ErrInvalidExternalURL
 	}
+	u.Scheme = scheme
+	return u.String(), nil
 }
 
 func randomID(size int) (string, error) {
@@ -0,0 +1,629 @@
+package watchparty
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	watchpartydomain "evd/internal/domain/watchparty"
+)
+
+func TestExternalHub_CreateAndControl(t *testing.T) {
+	svc := NewService(Options{})
+	defer svc.Close()
+
+	hub, err := svc.CreateHub("u1", "alice", HubKindExternal, "HTTPS://www.youtube.com/watch?v=abc", 12, true, "")
+	if err != nil {
+		t.Fatalf("create hub: %v", err)
+	}
+	if hub.Kind != HubKindExternal || hub.VideoPath != "https://www.youtube.com/watch?v=abc" {
+		t.Fatalf("unexpected external hub snapshot: %+v", hub)
+	}
+
+	event, err := svc.Control(hub.ID, "u1", "alice", ControlInput{Action: ActionVideo, VideoPath: "https://vimeo.com/42"})
+	if err != nil {
+		t.Fatalf("switch video: %v", err)
+	}
+	if event.Hub.VideoPath != "https://vimeo.com/42" || event.Hub.Kind != HubKindExternal {
+		t.Fatalf("expected external video switch, got %+v", event.Hub)
+	}
+
+	if _, err := svc.Control(hub.ID, "u1", "alice", ControlInput{Action: ActionVideo, VideoPath: "movies/local.mkv"}); !errors.Is(err, ErrInvalidExternalURL) {
+		t.Fatalf("expected library path to be rejected in external hub, got %v", err)
+	}
+	if _, err := svc.Control(hub.ID, "u1", "alice", ControlInput{Action: ActionSeek, CurrentTime: 30}); err != nil {
+		t.Fatalf("seek: %v", err)
+	}
+}
+
+func TestCreateHub_RejectsInvalidExternalURLs(t *testing.T) {
+	svc := NewService(Options{})
+	defer svc.Close()
+	for _, raw := range []string{"javascript:alert(1)", "file:///etc/passwd", "https://user:pw@example.com/v", "//example.com/v", "not a url"} {
+		if _, err := svc.CreateHub("u1", "alice", HubKindExternal, raw, 0, false, ""); !errors.Is(err, ErrInvalidExternalURL) {
+			t.Errorf("%q: expected invalid external url, got %v", raw, err)
+		}
+	}
+
+	hub, err := svc.CreateHub("u1", "alice", "", "movies/local.mkv", 0, false, "")
+	if err != nil || hub.Kind != HubKindLibrary {
+		t.Fatalf("expected library hub by default, got %+v, %v", hub, err)
+	}
+}
+
+func TestReapIdle_RemovesStaleEmptyHubsOnly(t *testing.T) {
+	svc := NewService(Options{IdleTTL: time.Minute, ReapInterval: time.Hour})
+	defer svc.Close()
+
+	stale, err := svc.CreateHub("u1", "alice", HubKindLibrary, "a.mkv", 0, false, "")
+	if err != nil {
+		t.Fatalf("create stale hub: %v", err)
+	}
+	active, err := svc.CreateHub("u2", "bob", HubKindLibrary, "b.mkv", 0, false, "")
+	if err != nil {
+		t.Fatalf("create active hub: %v", err)
+	}
+	_, unsubscribe, err := svc.Subscribe(active.ID, "u2", "bob")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer unsubscribe()
+
+	svc.reapIdle(time.Now().Add(2 * time.Minute))
+
+	if _, err := svc.GetHub(stale.ID); !errors.Is(err, ErrHubNotFound) {
+		t.Fatalf("expected stale empty hub to be reaped, got %v", err)
+	}
+	if _, err := svc.GetHub(active.ID); err != nil {
+		t.Fatalf("expected hub with subscribers to survive, got %v", err)
+	}
+}
+
+func TestControl_PlaySnapshotAdvancesWithServerTime(t *testing.T) {
+	svc := NewService(Options{})
+	defer svc.Close()
+
+	hub, err := svc.CreateHub("u1", "alice", HubKindLibrary, "a.mkv", 0, false, "")
+	if err != nil {
+		t.Fatalf("create hub: %v", err)
+	}
+	event, err := svc.Control(hub.ID, "u1", "alice", ControlInput{Action: ActionPlay, CurrentTime: 10})
+	if err != nil {
+		t.Fatalf("play: %v", err)
+	}
+	if !event.Hub.Playing || event.Hub.CurrentTime != 10 || event.Hub.ServerTime == 0 {
+		t.Fatalf("unexpected play snapshot: %+v", event.Hub)
+	}
+
+	// Pretend the play happened two seconds earlier.
+	svc.mu.Lock()
+	svc.hubs[hub.ID].positionAt = svc.hubs[hub.ID].positionAt.Add(-2 * time.Second)
+	svc.mu.Unlock()
+
+	later, err := svc.GetHub(hub.ID)
+	if err != nil {
+		t.Fatalf("get hub: %v", err)
+	}
+	if later.ServerTime < event.Hub.ServerTime {
+		t.Fatalf("expected monotonic server time, got %d after %d", later.ServerTime, event.Hub.ServerTime)
+	}
+	if later.CurrentTime < 11.99 || later.CurrentTime > 12.5 {
+		t.Fatalf("expected position extrapolated to ~12s, got %v", later.CurrentTime)
+	}
+
+	paused, err := svc.Control(hub.ID, "u1", "alice", ControlInput{Action: ActionPause, CurrentTime: math.NaN()})
+	if err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if paused.Hub.CurrentTime < 11.99 {
+		t.Fatalf("expected pause without a time to keep the reached position, got %v", paused.Hub.CurrentTime)
+	}
+}
+
+func TestSubscribe_EnforcesMemberCap(t *testing.T) {
+	svc := NewService(Options{MaxMembers: 2})
+	defer svc.Close()
+
+	hub, err := svc.CreateHub("owner", "olive", HubKindLibrary, "a.mkv", 0, false, "")
+	if err != nil {
+		t.Fatalf("create hub: %v", err)
+	}
+	for _, user := range []string{"u1", "u1", "u2"} {
+		if _, _, err := svc.Subscribe(hub.ID, user, user); err != nil {
+			t.Fatalf("subscribe %s: %v", user, err)
+		}
+	}
+	if _, _, err := svc.Subscribe(hub.ID, "u3", "u3"); !errors.Is(err, ErrHubFull) {
+		t.Fatalf("expected ErrHubFull, got %v", err)
+	}
+	if _, _, err := svc.Subscribe(hub.ID, "u2", "u2"); err != nil {
+		t.Fatalf("expected another connection of a member to be allowed, got %v", err)
+	}
+	if _, _, err := svc.Subscribe(hub.ID, "owner", "olive"); err != nil {
+		t.Fatalf("expected the owner to join a full hub, got %v", err)
+	}
+}
+
+func TestKick_OwnerOnlyAndClosesSubscriptions(t *testing.T) {
+	svc := NewService(Options{})
+	defer svc.Close()
+
+	hub, err := svc.CreateHub("owner", "olive", HubKindLibrary, "a.mkv", 0, false, "")
+	if err != nil {
+		t.Fatalf("create hub: %v", err)
+	}
+	ownerEvents, ownerDone, err := svc.Subscribe(hub.ID, "owner", "olive")
+	if err != nil {
+		t.Fatalf("subscribe owner: %v", err)
+	}
+	defer ownerDone()
+	targetEvents, targetDone, err := svc.Subscribe(hub.ID, "u1", "bob")
+	if err != nil {
+		t.Fatalf("subscribe target: %v", err)
+	}
+
+	if err := svc.Kick(hub.ID, "u1", "owner"); !errors.Is(err, ErrNotHubOwner) {
+		t.Fatalf("expected ErrNotHubOwner, got %v", err)
+	}
+	if err := svc.Kick(hub.ID, "owner", "nobody"); !errors.Is(err, ErrMemberNotFound) {
+		t.Fatalf("expected ErrMemberNotFound, got %v", err)
+	}
+	if err := svc.Kick(hub.ID, "owner", "u1"); err != nil {
+		t.Fatalf("kick: %v", err)
+	}
+
+	var last Event
+	for event := range targetEvents {
+		last = event
+	}
+	if last.Type != "presence" || last.Action != "kick" || last.ActorID != "u1" {
+		t.Fatalf("expected kick event before close, got %+v", last)
+	}
+	targetDone()
+
+	var kick Event
+	for len(ownerEvents) > 0 {
+		kick = <-ownerEvents
+	}
+	if kick.Action != "kick" || len(kick.Hub.Members) != 1 || kick.Hub.Members[0].ID != "owner" {
+		t.Fatalf("expected owner to see the kick, got %+v", kick)
+	}
+	if _, _, err := svc.Subscribe(hub.ID, "u1", "bob"); !errors.Is(err, ErrKicked) {
+		t.Fatalf("expected kicked user to stay out, got %v", err)
+	}
+	if _, err := svc.Control(hub.ID, "u1", "bob", ControlInput{Action: ActionPlay}); !errors.Is(err, ErrKicked) {
+		t.Fatalf("expected kicked user's control to be refused, got %v", err)
+	}
+	if _, err := svc.Chat(hub.ID, "u1", "bob", "hi"); !errors.Is(err, ErrKicked) {
+		t.Fatalf("expected kicked user's chat to be refused, got %v", err)
+	}
+	if _, err := svc.Chat(hub.ID, "u2", "carol", "hi"); !errors.Is(err, ErrMemberNotFound) {
+		t.Fatalf("expected non-member's chat to be refused, got %v", err)
+	}
+
+	svc.mu.Lock()
+	restored := hubFromRecord(recordFromHub(svc.hubs[hub.ID]))
+	svc.mu.Unlock()
+	if !restored.kicked["u1"] {
+		t.Fatalf("expected the kick to survive a restart")
+	}
+}
+
+func TestControl_RateAction(t *testing.T) {
+	svc := NewService(Options{})
+	defer svc.Close()
+
+	hub, err := svc.CreateHub("u1", "alice", HubKindLibrary, "a.mkv", 10, false, "")
+	if err != nil {
+		t.Fatalf("create hub: %v", err)
+	}
+	if hub.PlaybackRate != 1 {
+		t.Fatalf("expected new hubs at 1x, got %v", hub.PlaybackRate)
+	}
+
+	_, done, err := svc.Subscribe(hub.ID, "u2", "bob")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer done()
+
+	event, err := svc.Control(hub.ID, "u2", "bob", ControlInput{Action: ActionRate, PlaybackRate: 1.5})
+	if err != nil {
+		t.Fatalf("rate: %v", err)
+	}
+	if event.Action != ActionRate || event.Hub.PlaybackRate != 1.5 || event.Hub.CurrentTime != 10 {
+		t.Fatalf("expected rate change to be broadcast, got %+v", event)
+	}
+
+	for _, rate := range []float64{0, 0.1, 4.5, math.NaN(), math.Inf(1)} {
+		if _, err := svc.Control(hub.ID, "u2", "bob", ControlInput{Action: ActionRate, PlaybackRate: rate}); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("rate %v: expected ErrInvalidInput, got %v", rate, err)
+		}
+	}
+
+	restored := hubFromRecord(watchpartydomain.HubRecord{ID: "old", VideoPath: "a.mkv"})
+	if restored.PlaybackRate != 1 {
+		t.Fatalf("expected records without a rate to load at 1x, got %v", restored.PlaybackRate)
+	}
+}
+
+func TestTypingAndReactions_AreEphemeral(t *testing.T) {
+	svc := NewService(Options{})
+	defer svc.Close()
+
+	hub, err := svc.CreateHub("u1", "alice", HubKindLibrary, "a.mkv", 0, false, "")
+	if err != nil {
+		t.Fatalf("create hub: %v", err)
+	}
+	events, done, err := svc.Subscribe(hub.ID, "u1", "alice")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer done()
+	_, memberDone, err := svc.Subscribe(hub.ID, "u2", "bob")
+	if err != nil {
+		t.Fatalf("subscribe member: %v", err)
+	}
+	defer memberDone()
+	for len(events) > 0 {
+		<-events
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := svc.Typing(hub.ID, "u2", "bob"); err != nil {
+			t.Fatalf("typing: %v", err)
+		}
+	}
+	if err := svc.React(hub.ID, "u2", "bob", "🔥"); err != nil {
+		t.Fatalf("react: %v", err)
+	}
+	if err := svc.React(hub.ID, "u2", "bob", "<script>"); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected unlisted emoji to be rejected, got %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected one throttled typing event and one reaction, got %d events", len(events))
+	}
+	if typing := <-events; typing.Type != EventTyping || typing.ActorID != "u2" {
+		t.Fatalf("unexpected typing event: %+v", typing)
+	}
+	if reaction := <-events; reaction.Type != EventReaction || reaction.Emoji != "🔥" {
+		t.Fatalf("unexpected reaction event: %+v", reaction)
+	}
+
+	snapshot, err := svc.GetHub(hub.ID)
+	if err != nil {
+		t.Fatalf("get hub: %v", err)
+	}
+	if len(snapshot.Messages) != 0 {
+		t.Fatalf("expected no chat history from ephemeral events, got %+v", snapshot.Messages)
+	}
+}
+
+func TestTransferOwnership_OwnerOnly(t *testing.T) {
+	svc := NewService(Options{})
+	defer svc.Close()
+
+	hub, err := svc.CreateHub("owner", "olive", HubKindLibrary, "a.mkv", 0, false, "")
+	if err != nil {
+		t.Fatalf("create hub: %v", err)
+	}
+	_, done, err := svc.Subscribe(hub.ID, "u1", "bob")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer done()
+
+	if err := svc.TransferOwnership(hub.ID, "u1", "u1"); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected self transfer to be rejected, got %v", err)
+	}
+	if err := svc.TransferOwnership(hub.ID, "u1", "owner"); !errors.Is(err, ErrNotHubOwner) {
+		t.Fatalf("expected ErrNotHubOwner, got %v", err)
+	}
+	if err := svc.TransferOwnership(hub.ID, "owner", "stranger"); !errors.Is(err, ErrMemberNotFound) {
+		t.Fatalf("expected ErrMemberNotFound, got %v", err)
+	}
+	if err := svc.TransferOwnership(hub.ID, "owner", "u1"); err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	snapshot, _ := svc.GetHub(hub.ID)
+	if snapshot.OwnerID != "u1" || snapshot.OwnerName != "bob" {
+		t.Fatalf("expected bob to own the hub, got %+v", snapshot)
+	}
+}
+
+func TestAutoTransfer_HandsHubToEarliestMember(t *testing.T) {
+	svc := NewService(Options{AutoTransferOwnership: true})
+	defer svc.Close()
+
+	hub, err := svc.CreateHub("owner", "olive", HubKindLibrary, "a.mkv", 0, false, "")
+	if err != nil {
+		t.Fatalf("create hub: %v", err)
+	}
+	_, ownerDone, err := svc.Subscribe(hub.ID, "owner", "olive")
+	if err != nil {
+		t.Fatalf("subscribe owner: %v", err)
+	}
+	for _, user := range []string{"u1", "u2"} {
+		if _, _, err := svc.Subscribe(hub.ID, user, user); err != nil {
+			t.Fatalf("subscribe %s: %v", user, err)
+		}
+	}
+	events, _, err := svc.Subscribe(hub.ID, "u3", "u3")
+	if err != nil {
+		t.Fatalf("subscribe u3: %v", err)
+	}
+	for len(events) > 0 {
+		<-events
+	}
+
+	ownerDone()
+
+	snapshot, _ := svc.GetHub(hub.ID)
+	if snapshot.OwnerID != "u1" {
+		t.Fatalf("expected the earliest remaining member to own the hub, got %q", snapshot.OwnerID)
+	}
+	var last Event
+	for len(events) > 0 {
+		last = <-events
+	}
+	if last.Type != "control" || last.Action != ActionOwner || last.ActorID != "u1" {
+		t.Fatalf("expected an owner control event, got %+v", last)
+	}
+}
+
+func TestAuthorize_LimitsFailedKeysAndCachesTheVerifiedKey(t *testing.T) {
+	svc := NewService(Options{})
+	defer svc.Close()
+
+	hub, err := svc.CreateHub("owner", "olive", HubKindLibrary, "a.mkv", 0, false, "s3cret")
+	if err != nil {
+		t.Fatalf("create hub: %v", err)
+	}
+	for i := 0; i < hubKeyUserLimit; i++ {
+		if err := svc.Authorize(hub.ID, "u1", "wrong"); !errors.Is(err, ErrHubForbidden) {
+			t.Fatalf("attempt %d: expected ErrHubForbidden, got %v", i, err)
+		}
+	}
+	if err := svc.Authorize(hub.ID, "u1", "s3cret"); !errors.Is(err, ErrHubKeyRateLimited) {
+		t.Fatalf("expected the user to be rate limited, got %v", err)
+	}
+	if err := svc.Authorize(hub.ID, "u2", "s3cret"); err != nil {
+		t.Fatalf("expected other users to keep trying, got %v", err)
+	}
+
+	svc.mu.Lock()
+	h := svc.hubs[hub.ID]
+	h.hubFailures = make([]time.Time, hubKeyHubLimit)
+	for i := range h.hubFailures {
+		h.hubFailures[i] = time.Now()
+	}
+	svc.mu.Unlock()
+	if err := svc.Authorize(hub.ID, "u3", "guess"); !errors.Is(err, ErrHubKeyRateLimited) {
+		t.Fatalf("expected the hub to be rate limited, got %v", err)
+	}
+	if err := svc.Authorize(hub.ID, "u3", "s3cret"); err != nil {
+		t.Fatalf("expected the cached key to pass without a derivation, got %v", err)
+	}
+}
+
+func TestAuthorize_PrivateHubRequiresKey(t *testing.T) {
+	svc := NewService(Options{})
+	defer svc.Close()
+
+	if _, err := svc.CreateHub("owner", "olive", HubKindLibrary, "a.mkv", 0, false, strings.Repeat("x", maxHubPasswordLength+1)); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected overlong password to be rejected, got %v", err)
+	}
+	hub, err := svc.CreateHub("owner", "olive", HubKindLibrary, "a.mkv", 0, false, "s3cret")
+	if err != nil {
+		t.Fatalf("create hub: %v", err)
+	}
+	if !hub.Private {
+		t.Fatalf("expected private hub snapshot, got %+v", hub)
+	}
+
+	if err := svc.Authorize(hub.ID, "owner", ""); err != nil {
+		t.Fatalf("owner should not need the key: %v", err)
+	}
+	if err := svc.Authorize(hub.ID, "u1", ""); !errors.Is(err, ErrHubForbidden) {
+		t.Fatalf("expected missing key to be forbidden, got %v", err)
+	}
+	if err := svc.Authorize(hub.ID, "u1", "wrong"); !errors.Is(err, ErrHubForbidden) {
+		t.Fatalf("expected wrong key to be forbidden, got %v", err)
+	}
+	if err := svc.Authorize(hub.ID, "u1", "s3cret"); err != nil {
+		t.Fatalf("authorize with key: %v", err)
+	}
+	if err := svc.Authorize(hub.ID, "u1", ""); err != nil {
+		t.Fatalf("granted member should not need the key again: %v", err)
+	}
+
+	public, err := svc.CreateHub("owner", "olive", HubKindLibrary, "a.mkv", 0, false, "")
+	if err != nil {
+		t.Fatalf("create public hub: %v", err)
+	}
+	if public.Private {
+		t.Fatalf("expected public hub snapshot, got %+v", public)
+	}
+	if err := svc.Authorize(public.ID, "u1", ""); err != nil {
+		t.Fatalf("public hub should not need a key: %v", err)
+	}
+}
+
+func TestControl_VideoAccessDeniedIsNotBroadcast(t *testing.T) {
+	svc := NewService(Options{VideoAccess: func(userID, videoPath string) (string, error) {
+		if userID == "u1" && strings.HasPrefix(videoPath, "private/") {
+			return "", errors.New("not visible")
+		}
+		return videoPath, nil
+	}})
+	defer svc.Close()
+
+	hub, err := svc.CreateHub("owner", "olive", HubKindLibrary, "a.mkv", 0, false, "")
+	if err != nil {
+		t.Fatalf("create hub: %v", err)
+	}
+	events, done, err := svc.Subscribe(hub.ID, "u1", "bob")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer done()
+	for len(events) > 0 {
+		<-events
+	}
+
+	if _, err := svc.Control(hub.ID, "u1", "bob", ControlInput{Action: ActionVideo, VideoPath: "private/b.mkv"}); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected denied video to be rejected, got %v", err)
+	}
+	if _, err := svc.Control(hub.ID, "u1", "bob", ControlInput{Action: ActionVideo, VideoPath: "notes.txt"}); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected unsupported extension to be rejected, got %v", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected no broadcast for rejected switches, got %+v", <-events)
+	}
+	if snapshot, _ := svc.GetHub(hub.ID); snapshot.VideoPath != "a.mkv" {
+		t.Fatalf("expected hub video unchanged, got %q", snapshot.VideoPath)
+	}
+
+	if _, err := svc.Control(hub.ID, "owner", "olive", ControlInput{Action: ActionVideo, VideoPath: "private/b.mkv"}); err != nil {
+		t.Fatalf("owner switch: %v", err)
+	}
+	if snapshot, _ := svc.GetHub(hub.ID); snapshot.VideoPath != "private/b.mkv" {
+		t.Fatalf("expected allowed switch to apply, got %q", snapshot.VideoPath)
+	}
+}
+
+func TestEvents_SeqIsMonotonicAndReplayable(t *testing.T) {
+	svc := NewService(Options{})
+	defer svc.Close()
+
+	hub, err := svc.CreateHub("owner", "olive", HubKindLibrary, "a.mkv", 0, false, "")
+	if err != nil {
+		t.Fatalf("create hub: %v", err)
+	}
+	events, done, err := svc.Subscribe(hub.ID, "owner", "olive")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer done()
+	if sync := <-events; sync.Type != "sync" {
+		t.Fatalf("expected initial sync event, got %+v", sync)
+	}
+
+	if _, err := svc.Control(hub.ID, "owner", "olive", ControlInput{Action: ActionPlay}); err != nil {
+		t.Fatalf("play: %v", err)
+	}
+	if _, err := svc.Chat(hub.ID, "owner", "olive", "hi"); err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	if err := svc.Typing(hub.ID, "owner", "olive"); err != nil {
+		t.Fatalf("typing: %v", err)
+	}
+	_, guestDone, err := svc.Subscribe(hub.ID, "u1", "bob")
+	if err != nil {
+		t.Fatalf("subscribe guest: %v", err)
+	}
+	guestDone()
+
+	var last uint64
+	var seen []Event
+	for len(events) > 0 {
+		event := <-events
+		if event.Type == EventTyping {
+			if event.Seq != 0 {
+				t.Fatalf("expected ephemeral event without seq, got %d", event.Seq)
+			}
+			continue
+		}
+		if event.Seq <= last {
+			t.Fatalf("expected increasing seq, got %d after %d (%+v)", event.Seq, last, event)
+		}
+		last = event.Seq
+		seen = append(seen, event)
+	}
+	if len(seen) != 5 {
+		t.Fatalf("expected join, play, chat, join and leave events, got %+v", seen)
+	}
+
+	// A client that saw the chat event gets only the two presence events
+	// after it, followed by its own join, and no sync.
+	chatSeq := seen[2].Seq
+	resumed, resumedDone, err := svc.SubscribeAfter(hub.ID, "u2", "carol", chatSeq)
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	defer resumedDone()
+	var replayed []Event
+	for len(resumed) > 0 {
+		replayed = append(replayed, <-resumed)
+	}
+	if len(replayed) != 3 || replayed[0].Seq != chatSeq+1 || replayed[0].Type == "sync" || replayed[2].ActorID != "u2" {
+		t.Fatalf("unexpected replay: %+v", replayed)
+	}
+
+	// An unknown position falls back to a full sync.
+	fresh, freshDone, err := svc.SubscribeAfter(hub.ID, "u3", "dave", last+100)
+	if err != nil {
+		t.Fatalf("resume ahead: %v", err)
+	}
+	defer freshDone()
+	if sync := <-fresh; sync.Type != "sync" || sync.Seq != last+1 {
+		t.Fatalf("expected sync at the current seq, got %+v", sync)
+	}
+}
+
+func TestListHubsForUser_OwnedAndJoinedHubs(t *testing.T) {
+	svc := NewService(Options{})
+	defer svc.Close()
+
+	owned, err := svc.CreateHub("u1", "alice", HubKindLibrary, "a.mkv", 0, false, "")
+	if err != nil {
+		t.Fatalf("create owned hub: %v", err)
+	}
+	joined, err := svc.CreateHub("u2", "bob", HubKindLibrary, "b.mkv", 0, false, "")
+	if err != nil {
+		t.Fatalf("create joined hub: %v", err)
+	}
+	left, err := svc.CreateHub("u3", "carol", HubKindLibrary, "c.mkv", 0, false, "")
+	if err != nil {
+		t.Fatalf("create left hub: %v", err)
+	}
+	if _, err := svc.Chat(joined.ID, "u2", "bob", "hello"); err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+
+	_, joinedDone, err := svc.Subscribe(joined.ID, "u1", "alice")
+	if err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	defer joinedDone()
+	_, leftDone, err := svc.Subscribe(left.ID, "u1", "alice")
+	if err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	leftDone()
+
+	hubs := svc.ListHubsForUser("u1")
+	ids := map[string]Snapshot{}
+	for _, hub := range hubs {
+		ids[hub.ID] = hub
+	}
+	if len(hubs) != 2 {
+		t.Fatalf("expected the owned and joined hubs, got %+v", hubs)
+	}
+	if _, ok := ids[owned.ID]; !ok {
+		t.Fatalf("expected owned hub %s in %+v", owned.ID, hubs)
+	}
+	got, ok := ids[joined.ID]
+	if !ok {
+		t.Fatalf("expected joined hub %s in %+v", joined.ID, hubs)
+	}
+	if got.Messages != nil || got.MessageCount != 1 {
+		t.Fatalf("expected a summary without history, got %+v", got)
+	}
+	if hubs := svc.ListHubsForUser("nobody"); len(hubs) != 0 {
+		t.Fatalf("expected no hubs for a stranger, got %+v", hubs)
+	}
+}
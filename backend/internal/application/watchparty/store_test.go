@@ -0,0 +1,91 @@
+package watchparty
+
+import (
+	"sync"
+	"testing"
+
+	watchpartydomain "evd/internal/domain/watchparty"
+)
+
+type memoryHubStore struct {
+	mu      sync.Mutex
+	records map[string]watchpartydomain.HubRecord
+}
+
+func (m *memoryHubStore) Save(record watchpartydomain.HubRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[record.ID] = record
+	return nil
+}
+
+func (m *memoryHubStore) Load() ([]watchpartydomain.HubRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]watchpartydomain.HubRecord, 0, len(m.records))
+	for _, record := range m.records {
+		out = append(out, record)
+	}
+	return out, nil
+}
+
+func (m *memoryHubStore) Delete(hubID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, hubID)
+	return nil
+}
+
+func TestServiceWithStore_RestoresHubsAfterRestart(t *testing.T) {
+	store := &memoryHubStore{records: map[string]watchpartydomain.HubRecord{}}
+	svc, err := NewServiceWithStore(store, nil, Options{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	defer svc.Close()
+
+	hub, err := svc.CreateHub("u1", "alice", HubKindLibrary, "movies/a.mkv", 0, false, "")
+	if err != nil {
+		t.Fatalf("create hub: %v", err)
+	}
+	_, unsubscribe, err := svc.Subscribe(hub.ID, "u1", "alice")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer unsubscribe()
+	if _, err := svc.Control(hub.ID, "u1", "alice", ControlInput{Action: ActionPlay, CurrentTime: 42}); err != nil {
+		t.Fatalf("control: %v", err)
+	}
+	if _, err := svc.Chat(hub.ID, "u1", "alice", "hello"); err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	svc.Flush()
+
+	restarted, err := NewServiceWithStore(store, nil, Options{})
+	if err != nil {
+		t.Fatalf("reload service: %v", err)
+	}
+	defer restarted.Close()
+	got, err := restarted.GetHub(hub.ID)
+	if err != nil {
+		t.Fatalf("get restored hub: %v", err)
+	}
+	if got.VideoPath != "movies/a.mkv" || got.CurrentTime < 42 || got.CurrentTime > 43 || !got.Playing || got.OwnerID != "u1" {
+		t.Fatalf("unexpected restored hub: %+v", got)
+	}
+	if len(got.Messages) != 1 || got.Messages[0].Text != "hello" {
+		t.Fatalf("expected chat history to survive restart, got %+v", got.Messages)
+	}
+	if len(got.Members) != 0 {
+		t.Fatalf("expected restored hub without members, got %+v", got.Members)
+	}
+
+	events, done, err := restarted.Subscribe(hub.ID, "u1", "alice")
+	if err != nil {
+		t.Fatalf("subscribe restored hub: %v", err)
+	}
+	defer done()
+	if sync := <-events; sync.Seq < 3 {
+		t.Fatalf("expected the event sequence to survive restart, got %d", sync.Seq)
+	}
+}
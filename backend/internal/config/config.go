@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -12,49 +15,224 @@ type Config struct {
 	VideosDir               string
 	HLSDir                  string
 	MP4Dir                  string
+	ThumbsDir               string
+	OutputNamesFile         string
 	UsersFile               string
 	SessionTTLHours         int
+	AdminUsers              []string
+	AuthRatePerMinute       int
+	AuthRateBurst           int
+	TrustProxyHeaders       bool
+	LoginLockoutThreshold   int
+	LoginLockoutMinutes     int
+	UserLibraries           bool
+	GuestLogin              bool
+	CORSAllowedOrigins      []string
+	CORSAllowCredentials    bool
+	ShutdownTimeoutSeconds  int
+	RequireFFmpeg           bool
+	MetricsEnabled          bool
+	StatusPage              bool
+	SupportedExts           []string
 	TransmissionURL         string
 	TransmissionUser        string
 	TransmissionPass        string
 	TransmissionDownloadDir string
+	TorrentReadyPrewarm     bool
+	TorrentPollSeconds      int
 	HlsSegmentSeconds       int
+	VideoEncoder            string
+	VideoPreset             string
+	VideoCRF                int
+	AudioBitrateKbps        int
+	VAAPIDevice             string
+	HLSFormat               string
+	HLSTrimEnabled          bool
+	HLSAdaptive             bool
+	HLSRenditions           string
+	ConvertMinFreeBytes     int
+	MaxTranscodeBytes       int
+	TrashRetentionHours     int
+	MP4ReadyMinBytes        int
+	ThumbnailPrewarm        bool
+	WatchVideos             bool
+	ThumbnailConcurrency    int
+	MP4Concurrency          int
+	LiveStreamConcurrency   int
+	PrewarmStableSeconds    int
+	PrewarmIntervalSeconds  int
+	UploadSessionTTLMinutes int
+	UploadMaxChunkBytes     int
+	UploadMaxFileBytes      int
+	IngestEnabled           bool
+	IngestAllowedHosts      []string
+	IngestMaxBytes          int
+	IngestMaxMinutes        int
+	AccessLog               bool
+	MaxStreamKbps           int
+	QuotasFile              string
+	QuotaStorageBytes       int
+	QuotaMonthlyStreamBytes int
+	ProgressEnabled         bool
+	ProgressFile            string
+	WatchHubsDir            string
+	WatchHubIdleMinutes     int
+	WatchHubMaxMembers      int
+	WatchHubAutoTransfer    bool
 }
 
-// Load reads environment variables and returns normalized runtime config.
-func Load() Config {
-	return Config{
-		ServerAddr:              getEnv("SERVER_ADDR", ":8080"),
-		VideosDir:               getEnv("VIDEOS_DIR", "./videos"),
-		HLSDir:                  getEnv("HLS_DIR", "./hls"),
-		MP4Dir:                  getEnv("MP4_DIR", "./mp4"),
-		UsersFile:               getEnv("USERS_FILE", "./data/users.json"),
-		SessionTTLHours:         getEnvInt("SESSION_TTL_HOURS", 72),
-		TransmissionURL:         strings.TrimSpace(os.Getenv("TRANSMISSION_URL")),
-		TransmissionUser:        os.Getenv("TRANSMISSION_USER"),
-		TransmissionPass:        os.Getenv("TRANSMISSION_PASS"),
-		TransmissionDownloadDir: getEnv("TRANSMISSION_DOWNLOAD_DIR", "/downloads"),
-		HlsSegmentSeconds:       getEnvInt("HLS_SEGMENT_SECONDS", 20),
+// Load returns normalized runtime config. Each setting comes from its
+// environment variable, else from the JSON or YAML file named by CONFIG_FILE,
+// else from its default. Unknown keys in the file are logged and ignored.
+// Numbers that do not parse or are out of range fail the load, all reported
+// together.
+func Load() (Config, error) {
+	src, err := newSource(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return Config{}, err
 	}
+
+	cfg := Config{
+		ServerAddr:              src.get("SERVER_ADDR", ":8080"),
+		VideosDir:               src.get("VIDEOS_DIR", "./videos"),
+		HLSDir:                  src.get("HLS_DIR", "./hls"),
+		MP4Dir:                  src.get("MP4_DIR", "./mp4"),
+		ThumbsDir:               src.get("THUMBS_DIR", "./thumbs"),
+		OutputNamesFile:         src.get("OUTPUT_NAMES_FILE", "./data/output-names.json"),
+		UsersFile:               src.get("USERS_FILE", "./data/users.json"),
+		SessionTTLHours:         src.getInt("SESSION_TTL_HOURS", 72),
+		AdminUsers:              src.getList("ADMIN_USERS"),
+		AuthRatePerMinute:       src.getNonNegativeInt("AUTH_RATE_PER_MINUTE", 10),
+		AuthRateBurst:           src.getInt("AUTH_RATE_BURST", 10),
+		TrustProxyHeaders:       src.getBool("TRUST_PROXY_HEADERS", false),
+		LoginLockoutThreshold:   src.getNonNegativeInt("LOGIN_LOCKOUT_THRESHOLD", 5),
+		LoginLockoutMinutes:     src.getInt("LOGIN_LOCKOUT_MINUTES", 15),
+		UserLibraries:           src.getBool("USER_LIBRARIES", false),
+		GuestLogin:              src.getBool("GUEST_LOGIN", true),
+		CORSAllowedOrigins:      src.getList("CORS_ALLOWED_ORIGINS"),
+		CORSAllowCredentials:    src.getBool("CORS_ALLOW_CREDENTIALS", false),
+		ShutdownTimeoutSeconds:  src.getInt("SHUTDOWN_TIMEOUT_SECONDS", 30),
+		RequireFFmpeg:           src.getBool("REQUIRE_FFMPEG", false),
+		MetricsEnabled:          src.getBool("METRICS_ENABLED", false),
+		StatusPage:              src.getBool("STATUS_PAGE", false),
+		SupportedExts:           src.getList("SUPPORTED_EXTS"),
+		TransmissionURL:         strings.TrimSpace(src.raw("TRANSMISSION_URL")),
+		TransmissionUser:        src.raw("TRANSMISSION_USER"),
+		TransmissionPass:        src.raw("TRANSMISSION_PASS"),
+		TransmissionDownloadDir: src.get("TRANSMISSION_DOWNLOAD_DIR", "/downloads"),
+		TorrentReadyPrewarm:     src.getBool("TORRENT_READY_PREWARM", true),
+		TorrentPollSeconds:      src.getInt("TORRENT_POLL_SECONDS", 10),
+		HlsSegmentSeconds:       src.getInt("HLS_SEGMENT_SECONDS", 20),
+		VideoEncoder:            src.get("VIDEO_ENCODER", "libx264"),
+		VideoPreset:             src.get("VIDEO_PRESET", "veryfast"),
+		VideoCRF:                src.getNonNegativeInt("VIDEO_CRF", 20),
+		AudioBitrateKbps:        src.getInt("AUDIO_BITRATE_KBPS", 192),
+		VAAPIDevice:             src.get("VAAPI_DEVICE", "/dev/dri/renderD128"),
+		HLSFormat:               src.get("HLS_FORMAT", "ts"),
+		HLSTrimEnabled:          src.getBool("HLS_TRIM_ENABLED", false),
+		HLSAdaptive:             src.getBool("HLS_ADAPTIVE", false),
+		HLSRenditions:           src.get("HLS_RENDITIONS", ""),
+		ConvertMinFreeBytes:     src.getNonNegativeInt("CONVERT_MIN_FREE_BYTES", 1<<30),
+		MaxTranscodeBytes:       src.getNonNegativeInt("MAX_TRANSCODE_BYTES", 0),
+		TrashRetentionHours:     src.getNonNegativeInt("TRASH_RETENTION_HOURS", 720),
+		MP4ReadyMinBytes:        src.getInt("MP4_READY_MIN_BYTES", 32*1024),
+		ThumbnailPrewarm:        src.getBool("THUMBNAIL_PREWARM", true),
+		WatchVideos:             src.getBool("WATCH_VIDEOS", true),
+		ThumbnailConcurrency:    src.getInt("THUMBNAIL_CONCURRENCY", 1),
+		MP4Concurrency:          src.getInt("MP4_CONCURRENCY", 1),
+		LiveStreamConcurrency:   src.getInt("LIVE_STREAM_CONCURRENCY", 4),
+		PrewarmStableSeconds:    src.getInt("PREWARM_STABLE_SECONDS", 40),
+		PrewarmIntervalSeconds:  src.getInt("PREWARM_INTERVAL_SECONDS", 45),
+		UploadSessionTTLMinutes: src.getInt("UPLOAD_SESSION_TTL_MINUTES", 360),
+		UploadMaxChunkBytes:     src.getNonNegativeInt("UPLOAD_MAX_CHUNK_BYTES", 10<<20),
+		UploadMaxFileBytes:      src.getNonNegativeInt("UPLOAD_MAX_FILE_BYTES", 0),
+		IngestEnabled:           src.getBool("INGEST_ENABLED", false),
+		IngestAllowedHosts:      src.getList("INGEST_ALLOWED_HOSTS"),
+		IngestMaxBytes:          src.getInt("INGEST_MAX_BYTES", 8<<30),
+		IngestMaxMinutes:        src.getInt("INGEST_MAX_MINUTES", 240),
+		AccessLog:               src.getBool("ACCESS_LOG", false),
+		MaxStreamKbps:           src.getNonNegativeInt("MAX_STREAM_KBPS", 0),
+		QuotasFile:              src.get("QUOTAS_FILE", "./data/quotas.json"),
+		QuotaStorageBytes:       src.getNonNegativeInt("QUOTA_STORAGE_BYTES", 0),
+		QuotaMonthlyStreamBytes: src.getNonNegativeInt("QUOTA_MONTHLY_STREAM_BYTES", 0),
+		ProgressEnabled:         src.getBool("PROGRESS_ENABLED", true),
+		ProgressFile:            src.get("PROGRESS_FILE", "./data/progress.json"),
+		WatchHubsDir:            src.get("WATCH_HUBS_DIR", "./data/watch-hubs"),
+		WatchHubIdleMinutes:     src.getInt("WATCH_HUB_IDLE_MINUTES", 30),
+		WatchHubMaxMembers:      src.getNonNegativeInt("WATCH_HUB_MAX_MEMBERS", 0),
+		WatchHubAutoTransfer:    src.getBool("WATCH_HUB_AUTO_TRANSFER", true),
+	}
+
+	if err := errors.Join(src.errs...); err != nil {
+		return Config{}, err
+	}
+	for _, key := range src.unknownKeys() {
+		log.Printf("WARNING: unknown key %q in CONFIG_FILE, ignored", key)
+	}
+	return cfg, nil
 }
 
-func getEnv(key, fallback string) string {
-	value := strings.TrimSpace(os.Getenv(key))
+func (s *source) get(key, fallback string) string {
+	value := strings.TrimSpace(s.raw(key))
 	if value == "" {
 		return fallback
 	}
 	return value
 }
 
-func getEnvInt(key string, fallback int) int {
-	value := strings.TrimSpace(os.Getenv(key))
-	if value == "" {
+// getInt reads a positive integer, reporting malformed and non-positive values.
+func (s *source) getInt(key string, fallback int) int {
+	out, ok := s.parseInt(key, fallback)
+	if ok && out <= 0 {
+		s.errs = append(s.errs, fmt.Errorf("%s: must be positive, got %d", key, out))
+		return fallback
+	}
+	return out
+}
+
+// getNonNegativeInt reads an integer for settings where 0 means off or
+// unlimited, reporting malformed and negative values.
+func (s *source) getNonNegativeInt(key string, fallback int) int {
+	out, ok := s.parseInt(key, fallback)
+	if ok && out < 0 {
+		s.errs = append(s.errs, fmt.Errorf("%s: must not be negative, got %d", key, out))
 		return fallback
 	}
-	var out int
-	_, err := fmt.Sscanf(value, "%d", &out)
-	if err != nil || out <= 0 {
+	return out
+}
+
+// parseInt returns the integer set for key and true, or fallback and false
+// when it is unset or malformed. Malformed values are recorded in s.errs.
+func (s *source) parseInt(key string, fallback int) (int, bool) {
+	value := strings.TrimSpace(s.raw(key))
+	if value == "" {
+		return fallback, false
+	}
+	out, err := strconv.Atoi(value)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s: %q is not an integer", key, value))
+		return fallback, false
+	}
+	return out, true
+}
+
+func (s *source) getBool(key string, fallback bool) bool {
+	switch strings.ToLower(strings.TrimSpace(s.raw(key))) {
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	default:
 		return fallback
 	}
+}
+
+func (s *source) getList(key string) []string {
+	out := []string{}
+	for _, item := range strings.Split(s.raw(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
 	return out
 }
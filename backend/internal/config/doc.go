@@ -1,2 +1,3 @@
-// Package config provides runtime configuration loading from environment.
+// Package config provides runtime configuration loading from environment
+// variables and an optional JSON config file.
 package config
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// source looks settings up by environment variable name, falling back to a
+// config file keyed by the same names. It records the keys Load asks for so
+// unknown file keys can be reported, and the values it could not accept.
+type source struct {
+	file map[string]string
+	used map[string]bool
+	errs []error
+}
+
+// newSource reads the object in path, if set: YAML for a .yaml or .yml file,
+// JSON otherwise. Values may be strings, numbers, booleans or lists of
+// strings; lists are read like comma-separated environment values.
+func newSource(path string) (*source, error) {
+	s := &source{file: map[string]string{}, used: map[string]bool{}}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read CONFIG_FILE: %w", err)
+	}
+	values, err := decodeFile(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("parse CONFIG_FILE %s: %w", path, err)
+	}
+
+	for key, value := range values {
+		text, err := fileValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("CONFIG_FILE key %q: %w", key, err)
+		}
+		s.file[key] = text
+	}
+	return s, nil
+}
+
+// decodeFile decodes a config file by its extension. Both formats yield the
+// same kinds of values, so fileValue treats them alike.
+func decodeFile(path string, data []byte) (map[string]interface{}, error) {
+	var values map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, err
+		}
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&values); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func fileValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			text, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("list items must be strings")
+			}
+			items = append(items, text)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+// raw returns the environment value of key when it is set and not blank,
+// otherwise the file's value.
+func (s *source) raw(key string) string {
+	s.used[key] = true
+	if value := os.Getenv(key); strings.TrimSpace(value) != "" {
+		return value
+	}
+	return s.file[key]
+}
+
+// unknownKeys lists file keys that no setting asked for, sorted.
+func (s *source) unknownKeys() []string {
+	var keys []string
+	for key := range s.file {
+		if !s.used[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoad_EnvOverridesFileOverridesDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "evd.json")
+	data := `{
+		"VIDEOS_DIR": "/srv/videos",
+		"HLS_SEGMENT_SECONDS": 6,
+		"THUMBNAIL_PREWARM": false,
+		"ADMIN_USERS": ["alice", "bob"],
+		"SERVER_ADDR": ":9000",
+		"NOT_A_SETTING": "x"
+	}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("SERVER_ADDR", ":7000")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.ServerAddr != ":7000" {
+		t.Fatalf("expected env to override the file, got %q", cfg.ServerAddr)
+	}
+	if cfg.VideosDir != "/srv/videos" || cfg.HlsSegmentSeconds != 6 || cfg.ThumbnailPrewarm {
+		t.Fatalf("expected file values, got videos=%q segments=%d thumbs=%v", cfg.VideosDir, cfg.HlsSegmentSeconds, cfg.ThumbnailPrewarm)
+	}
+	if !reflect.DeepEqual(cfg.AdminUsers, []string{"alice", "bob"}) {
+		t.Fatalf("expected file list, got %v", cfg.AdminUsers)
+	}
+	if cfg.HLSDir != "./hls" {
+		t.Fatalf("expected default for a key in neither, got %q", cfg.HLSDir)
+	}
+}
+
+func TestNewSource_ReportsUnknownKeysAndBadValues(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "evd.json")
+	if err := os.WriteFile(path, []byte(`{"VIDEOS_DIR": "/v", "VIDEO_DIR": "/typo"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := newSource(path)
+	if err != nil {
+		t.Fatalf("new source: %v", err)
+	}
+	src.get("VIDEOS_DIR", "")
+	if got := src.unknownKeys(); !reflect.DeepEqual(got, []string{"VIDEO_DIR"}) {
+		t.Fatalf("expected VIDEO_DIR to be unknown, got %v", got)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"ADMIN_USERS": [1, 2]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newSource(bad); err == nil {
+		t.Fatalf("expected a non-string list to be rejected")
+	}
+}
+
+func TestLoad_ReadsYAMLFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "evd.yaml")
+	data := `# Shared settings for every instance.
+VIDEOS_DIR: /srv/videos
+HLS_SEGMENT_SECONDS: 6
+THUMBNAIL_PREWARM: false
+ADMIN_USERS:
+  - alice
+  - bob
+SERVER_ADDR: ":9000"
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("SERVER_ADDR", ":7000")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.ServerAddr != ":7000" {
+		t.Fatalf("expected env to override the file, got %q", cfg.ServerAddr)
+	}
+	if cfg.VideosDir != "/srv/videos" || cfg.HlsSegmentSeconds != 6 || cfg.ThumbnailPrewarm {
+		t.Fatalf("expected file values, got videos=%q segments=%d thumbs=%v", cfg.VideosDir, cfg.HlsSegmentSeconds, cfg.ThumbnailPrewarm)
+	}
+	if !reflect.DeepEqual(cfg.AdminUsers, []string{"alice", "bob"}) {
+		t.Fatalf("expected file list, got %v", cfg.AdminUsers)
+	}
+	if cfg.HLSDir != "./hls" {
+		t.Fatalf("expected default for a key in neither, got %q", cfg.HLSDir)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.yml")
+	if err := os.WriteFile(bad, []byte("ADMIN_USERS: [1, 2]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newSource(bad); err == nil {
+		t.Fatalf("expected a non-string YAML list to be rejected")
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+const maxHLSSegmentSeconds = 60
+
+// Validate reports settings that would otherwise only fail at first use. All
+// problems are returned together, each naming its environment variable.
+func (c Config) Validate() error {
+	var errs []error
+
+	dirs := []struct{ env, path string }{
+		{"VIDEOS_DIR", c.VideosDir},
+		{"HLS_DIR", c.HLSDir},
+		{"MP4_DIR", c.MP4Dir},
+		{"THUMBS_DIR", c.ThumbsDir},
+		{"WATCH_HUBS_DIR", c.WatchHubsDir},
+		{"USERS_FILE", filepath.Dir(c.UsersFile)},
+		{"QUOTAS_FILE", filepath.Dir(c.QuotasFile)},
+	}
+	if c.ProgressEnabled {
+		dirs = append(dirs, struct{ env, path string }{"PROGRESS_FILE", filepath.Dir(c.ProgressFile)})
+	}
+	for _, dir := range dirs {
+		if err := checkCreatableDir(dir.path); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", dir.env, err))
+		}
+	}
+
+	// Outputs inside the library would be listed and prewarmed as sources.
+	videos := filepath.Clean(c.VideosDir)
+	for _, out := range []struct{ env, path string }{
+		{"HLS_DIR", c.HLSDir},
+		{"MP4_DIR", c.MP4Dir},
+		{"THUMBS_DIR", c.ThumbsDir},
+	} {
+		if c.VideosDir != "" && filepath.Clean(out.path) == videos {
+			errs = append(errs, fmt.Errorf("%s: must differ from VIDEOS_DIR %q", out.env, c.VideosDir))
+		}
+	}
+
+	if c.SessionTTLHours <= 0 {
+		errs = append(errs, fmt.Errorf("SESSION_TTL_HOURS: must be positive, got %d", c.SessionTTLHours))
+	}
+	if c.HlsSegmentSeconds < 1 || c.HlsSegmentSeconds > maxHLSSegmentSeconds {
+		errs = append(errs, fmt.Errorf("HLS_SEGMENT_SECONDS: must be between 1 and %d, got %d", maxHLSSegmentSeconds, c.HlsSegmentSeconds))
+	}
+	if c.TransmissionURL != "" {
+		if err := checkHTTPURL(c.TransmissionURL); err != nil {
+			errs = append(errs, fmt.Errorf("TRANSMISSION_URL: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkCreatableDir reports whether dir exists as a directory or could be
+// created: its nearest existing ancestor must be a directory.
+func checkCreatableDir(dir string) error {
+	if dir == "" {
+		return errors.New("path is empty")
+	}
+	for path := filepath.Clean(dir); ; path = filepath.Dir(path) {
+		info, err := os.Stat(path)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%q is not a directory", path)
+			}
+			return nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if parent := filepath.Dir(path); parent == path {
+			return nil
+		}
+	}
+}
+
+func checkHTTPURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q must be an http or https URL", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", raw)
+	}
+	return nil
+}
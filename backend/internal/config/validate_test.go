@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func validConfig(t *testing.T) Config {
+	t.Helper()
+	root := t.TempDir()
+	return Config{
+		VideosDir:         filepath.Join(root, "videos"),
+		HLSDir:            filepath.Join(root, "hls"),
+		MP4Dir:            filepath.Join(root, "mp4"),
+		ThumbsDir:         filepath.Join(root, "thumbs"),
+		WatchHubsDir:      filepath.Join(root, "data", "watch-hubs"),
+		UsersFile:         filepath.Join(root, "data", "users.json"),
+		QuotasFile:        filepath.Join(root, "data", "quotas.json"),
+		ProgressEnabled:   true,
+		ProgressFile:      filepath.Join(root, "data", "progress.json"),
+		SessionTTLHours:   72,
+		HlsSegmentSeconds: 20,
+		TransmissionURL:   "http://transmission:9091/transmission/rpc",
+	}
+}
+
+func TestValidate_AcceptsDefaultsUnderNewDirectories(t *testing.T) {
+	if err := validConfig(t).Validate(); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+}
+
+func TestValidate_RejectsBadSettings(t *testing.T) {
+	for name, tc := range map[string]struct {
+		mutate func(t *testing.T, c *Config)
+		want   string
+	}{
+		"file in the way of a directory": {func(t *testing.T, c *Config) {
+			blocker := filepath.Join(t.TempDir(), "blocker")
+			if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			c.HLSDir = filepath.Join(blocker, "hls")
+		}, "HLS_DIR"},
+		"empty videos dir":          {func(_ *testing.T, c *Config) { c.VideosDir = "" }, "VIDEOS_DIR"},
+		"output inside the library": {func(_ *testing.T, c *Config) { c.MP4Dir = c.VideosDir + "/" }, "MP4_DIR"},
+		"zero session ttl":          {func(_ *testing.T, c *Config) { c.SessionTTLHours = 0 }, "SESSION_TTL_HOURS"},
+		"huge segments":             {func(_ *testing.T, c *Config) { c.HlsSegmentSeconds = 600 }, "HLS_SEGMENT_SECONDS"},
+		"transmission without scheme": {func(_ *testing.T, c *Config) {
+			c.TransmissionURL = "transmission:9091/transmission/rpc"
+		}, "TRANSMISSION_URL"},
+		"transmission without host": {func(_ *testing.T, c *Config) { c.TransmissionURL = "http:///rpc" }, "TRANSMISSION_URL"},
+		"malformed transmission url": {func(_ *testing.T, c *Config) {
+			c.TransmissionURL = "http://[::1"
+		}, "TRANSMISSION_URL"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := validConfig(t)
+			tc.mutate(t, &cfg)
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected an error naming %s, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.SessionTTLHours = -1
+	cfg.HlsSegmentSeconds = 0
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "SESSION_TTL_HOURS") || !strings.Contains(err.Error(), "HLS_SEGMENT_SECONDS") {
+		t.Fatalf("expected both problems to be reported, got %v", err)
+	}
+}
+
+func TestLoad_RejectsBadNumbers(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("SESSION_TTL_HOURS", "0")
+	t.Setenv("HLS_SEGMENT_SECONDS", "abc")
+	t.Setenv("TORRENT_POLL_SECONDS", "-5")
+	t.Setenv("MAX_STREAM_KBPS", "-1")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatalf("expected bad numbers to fail the load")
+	}
+	for _, want := range []string{"SESSION_TTL_HOURS", "HLS_SEGMENT_SECONDS", "TORRENT_POLL_SECONDS", "MAX_STREAM_KBPS"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the error to name %s, got %v", want, err)
+		}
+	}
+}
+
+func TestLoad_ZeroDisablesWhereDocumented(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "0")
+	t.Setenv("TRASH_RETENTION_HOURS", "0")
+	t.Setenv("HLS_SEGMENT_SECONDS", "600")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.LoginLockoutThreshold != 0 || cfg.TrashRetentionHours != 0 {
+		t.Fatalf("expected 0 to be kept, got lockout=%d trash=%d", cfg.LoginLockoutThreshold, cfg.TrashRetentionHours)
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "HLS_SEGMENT_SECONDS") {
+		t.Fatalf("expected an oversized segment length loaded from env to fail validation, got %v", err)
+	}
+}
+
+func TestLoad_VideoCRF(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("VIDEO_CRF", "0")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.VideoCRF != 0 {
+		t.Fatalf("expected lossless CRF 0 to be kept, got %d", cfg.VideoCRF)
+	}
+
+	for _, bad := range []string{"-3", "high"} {
+		t.Setenv("VIDEO_CRF", bad)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), "VIDEO_CRF") {
+			t.Fatalf("expected VIDEO_CRF=%s to fail the load, got %v", bad, err)
+		}
+	}
+}
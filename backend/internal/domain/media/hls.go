@@ -0,0 +1,104 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HLSFormat selects the container used for HLS segments.
+type HLSFormat string
+
+const (
+	// HLSFormatTS writes MPEG-TS segments; the default, playable everywhere.
+	HLSFormatTS HLSFormat = "ts"
+	// HLSFormatFMP4 writes fragmented MP4 (`init.mp4` + `.m4s`) for modern players.
+	HLSFormatFMP4 HLSFormat = "fmp4"
+)
+
+// HLSResumePoint is where a paused HLS conversion continues: after Segments
+// complete segments covering OffsetSeconds of the source.
+type HLSResumePoint struct {
+	Segments      int     `json:"segments"`
+	OffsetSeconds float64 `json:"offsetSeconds"`
+}
+
+// ErrUnsupportedHLSFormat is returned for unknown HLS format names.
+var ErrUnsupportedHLSFormat = errors.New("unsupported HLS format")
+
+// ParseHLSFormat parses a format name. An empty name yields an empty format,
+// leaving the choice to the configured default.
+func ParseHLSFormat(raw string) (HLSFormat, error) {
+	switch HLSFormat(strings.ToLower(strings.TrimSpace(raw))) {
+	case "":
+		return "", nil
+	case HLSFormatTS:
+		return HLSFormatTS, nil
+	case HLSFormatFMP4:
+		return HLSFormatFMP4, nil
+	default:
+		return "", ErrUnsupportedHLSFormat
+	}
+}
+
+// Rendition is one quality level of an adaptive HLS ladder. Width follows the
+// source aspect ratio.
+type Rendition struct {
+	Height int `json:"height"`
+	// VideoBitrate and AudioBitrate are in kbit/s.
+	VideoBitrate int `json:"videoBitrate"`
+	AudioBitrate int `json:"audioBitrate"`
+}
+
+// Name is the rendition's subdirectory below an adaptive HLS output, e.g. `720p`.
+func (r Rendition) Name() string {
+	return strconv.Itoa(r.Height) + "p"
+}
+
+// DefaultRenditions is the ladder used when adaptive HLS is enabled without an
+// explicit list.
+var DefaultRenditions = []Rendition{
+	{Height: 1080, VideoBitrate: 5000, AudioBitrate: 192},
+	{Height: 720, VideoBitrate: 2800, AudioBitrate: 128},
+	{Height: 480, VideoBitrate: 1400, AudioBitrate: 96},
+	{Height: 360, VideoBitrate: 800, AudioBitrate: 96},
+}
+
+// ErrInvalidRenditions is returned for malformed rendition lists.
+var ErrInvalidRenditions = errors.New("invalid HLS renditions")
+
+// ParseRenditions parses a comma-separated ladder of `height:videoKbps:audioKbps`
+// entries, e.g. `1080:5000:192,720:2800:128`. An empty list yields DefaultRenditions.
+// The result is ordered from the highest to the lowest resolution.
+func ParseRenditions(raw string) ([]Rendition, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return append([]Rendition(nil), DefaultRenditions...), nil
+	}
+
+	var out []Rendition
+	seen := make(map[int]bool)
+	for _, entry := range strings.Split(raw, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRenditions, entry)
+		}
+		var values [3]int
+		for i, part := range parts {
+			value, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || value <= 0 {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidRenditions, entry)
+			}
+			values[i] = value
+		}
+		if seen[values[0]] {
+			return nil, fmt.Errorf("%w: duplicate height %d", ErrInvalidRenditions, values[0])
+		}
+		seen[values[0]] = true
+		out = append(out, Rendition{Height: values[0], VideoBitrate: values[1], AudioBitrate: values[2]})
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].Height > out[k].Height })
+	return out, nil
+}
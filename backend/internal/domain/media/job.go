@@ -1,11 +1,25 @@
 package media
 
+import (
+	"errors"
+	"time"
+)
+
+// ErrConversionStopped is returned by converters when a tool run ended
+// because its context was cancelled or the process was killed by a signal.
+// Callers treat it as a normal termination rather than a conversion failure.
+var ErrConversionStopped = errors.New("conversion stopped")
+
 // JobType describes the kind of conversion.
 type JobType string
 
 const (
 	JobHLS JobType = "hls"
-	JobMP4 JobType = "mp4"
+	// JobHLSFMP4 is an HLS conversion with fragmented MP4 segments.
+	JobHLSFMP4 JobType = "hls-fmp4"
+	JobMP4     JobType = "mp4"
+	// JobIngest fetches a remote URL into the library as MP4.
+	JobIngest JobType = "ingest"
 )
 
 // JobState describes conversion status.
@@ -14,8 +28,13 @@ type JobState string
 const (
 	StateIdle       JobState = "idle"
 	StateProcessing JobState = "processing"
-	StateReady      JobState = "ready"
-	StateFailed     JobState = "failed"
+	// StateQueued is an accepted conversion waiting for a free conversion slot.
+	StateQueued JobState = "queued"
+	StateReady  JobState = "ready"
+	StateFailed JobState = "failed"
+	// StatePaused is a conversion stopped on request. Resumable jobs continue
+	// from their last complete output; others restart from scratch.
+	StatePaused JobState = "paused"
 )
 
 // JobStatus is the DTO used by application layer.
@@ -27,4 +46,34 @@ type JobStatus struct {
 	Segments   int
 	Error      string
 	Progress   int
+	Resumable  bool
+	// QueuePosition is the 1-based place of a conversion waiting for a slot,
+	// or 0 once it runs.
+	QueuePosition int
+
+	// StartedAt is zero when no conversion has run since the server started.
+	StartedAt time.Time
+	// Elapsed runs until the conversion stops.
+	Elapsed time.Duration
+	// ETA estimates the remaining time; it is meaningful only when ETAKnown.
+	ETA      time.Duration
+	ETAKnown bool
+}
+
+// JobInfo describes a tracked conversion job for operational reporting.
+type JobInfo struct {
+	Type     JobType  `json:"type"`
+	Path     string   `json:"path"`
+	State    JobState `json:"state"`
+	Progress int      `json:"progress"`
+}
+
+// ArtifactStatus summarizes generated outputs for a single library path.
+type ArtifactStatus struct {
+	Path          string `json:"path"`
+	HLSReady      bool   `json:"hlsReady"`
+	MP4Ready      bool   `json:"mp4Ready"`
+	HLSProcessing bool   `json:"hlsProcessing"`
+	MP4Processing bool   `json:"mp4Processing"`
+	Error         string `json:"error,omitempty"`
 }
@@ -2,22 +2,124 @@ package media
 
 import (
 	"errors"
+	"fmt"
 	"path"
+	"sort"
 	"strings"
+	"sync"
 )
 
-var allowedVideoExts = map[string]bool{
-	".mp4": true,
-	".mkv": true,
-	".avi": true,
-	".mov": true,
+// defaultVideoExts are supported without configuration.
+var defaultVideoExts = []string{".mp4", ".mkv", ".avi", ".mov", ".webm", ".m4v", ".ts"}
+
+// videoContentTypes maps video extensions to MIME types; the standard library
+// knows few of them.
+var videoContentTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".mkv":  "video/x-matroska",
+	".webm": "video/webm",
+	".avi":  "video/x-msvideo",
+	".mov":  "video/quicktime",
+	".ts":   "video/mp2t",
+	".m2ts": "video/mp2t",
+	".mpg":  "video/mpeg",
+	".mpeg": "video/mpeg",
+	".wmv":  "video/x-ms-wmv",
+	".flv":  "video/x-flv",
+	".3gp":  "video/3gpp",
+	".ogv":  "video/ogg",
+}
+
+var (
+	videoExtsMu      sync.RWMutex
+	allowedVideoExts = videoExtSet(nil)
+)
+
+// ConfigureVideoExts supports extra extensions, such as ".wmv" or "flv", on top
+// of the defaults, replacing extras configured before. It is meant for startup.
+func ConfigureVideoExts(extra []string) error {
+	for _, ext := range extra {
+		if !validVideoExt(normalizeVideoExt(ext)) {
+			return fmt.Errorf("invalid video extension %q", ext)
+		}
+	}
+	exts := videoExtSet(extra)
+
+	videoExtsMu.Lock()
+	defer videoExtsMu.Unlock()
+	allowedVideoExts = exts
+	return nil
 }
 
 // IsSupportedVideoExt reports whether extension is supported by the media domain.
 func IsSupportedVideoExt(ext string) bool {
-	return allowedVideoExts[strings.ToLower(strings.TrimSpace(ext))]
+	videoExtsMu.RLock()
+	defer videoExtsMu.RUnlock()
+	return allowedVideoExts[normalizeVideoExt(ext)]
+}
+
+// SupportedVideoExts returns the supported video extensions in sorted order.
+func SupportedVideoExts() []string {
+	videoExtsMu.RLock()
+	defer videoExtsMu.RUnlock()
+	exts := make([]string, 0, len(allowedVideoExts))
+	for ext := range allowedVideoExts {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// VideoContentType returns the MIME type of a video extension, or "" when unknown.
+func VideoContentType(ext string) string {
+	return videoContentTypes[normalizeVideoExt(ext)]
 }
 
+// VideoContentTypes returns the known MIME types by video extension.
+func VideoContentTypes() map[string]string {
+	out := make(map[string]string, len(videoContentTypes))
+	for ext, contentType := range videoContentTypes {
+		out[ext] = contentType
+	}
+	return out
+}
+
+func videoExtSet(extra []string) map[string]bool {
+	exts := make(map[string]bool, len(defaultVideoExts)+len(extra))
+	for _, ext := range append(append([]string{}, defaultVideoExts...), extra...) {
+		exts[normalizeVideoExt(ext)] = true
+	}
+	return exts
+}
+
+// normalizeVideoExt lowercases ext and gives it a leading dot.
+func normalizeVideoExt(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+func validVideoExt(ext string) bool {
+	if len(ext) < 2 {
+		return false
+	}
+	for _, r := range ext[1:] {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// VariantDir is the folder, next to a source, whose paths name the artifacts of
+// a track or subtitle selection (see AudioVariantPath). It is reserved: library
+// paths with a segment of this name are rejected, so a real video can never share
+// a variant's outputs.
+const VariantDir = ".variants"
+
 // NormalizeVideoPath validates and normalizes incoming media path.
 func NormalizeVideoPath(raw string) (string, error) {
 	value := strings.TrimSpace(raw)
@@ -35,6 +137,24 @@ func NormalizeVideoPath(raw string) (string, error) {
 	if !IsSupportedVideoExt(path.Ext(cleaned)) {
 		return "", errors.New("unsupported file type")
 	}
+	for _, part := range strings.Split(cleaned, "/") {
+		if part == VariantDir {
+			return "", errors.New("invalid file name")
+		}
+	}
 
 	return cleaned, nil
 }
+
+// NormalizeDirPath validates and normalizes a library directory path. An empty
+// path or "/" yields "", the library root.
+func NormalizeDirPath(raw string) (string, error) {
+	value := strings.ReplaceAll(strings.TrimSpace(raw), "\\", "/")
+	cleaned := strings.TrimPrefix(path.Clean("/"+value), "/")
+	for _, part := range strings.Split(value, "/") {
+		if part == ".." {
+			return "", errors.New("invalid directory path")
+		}
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,150 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// DefaultAudioTrack selects the source's default audio track (or its first one
+// when none is flagged) instead of an explicit index.
+const DefaultAudioTrack = -1
+
+// maxAudioTrack and maxSubtitleTrack bound explicit stream indexes.
+const (
+	maxAudioTrack    = 63
+	maxSubtitleTrack = 63
+)
+
+var (
+	// ErrInvalidAudioTrack is returned for audio track indexes that are not a
+	// small non-negative number.
+	ErrInvalidAudioTrack = errors.New("invalid audio track")
+	// ErrInvalidSubtitleTrack is returned for malformed subtitle track indexes.
+	ErrInvalidSubtitleTrack = errors.New("invalid subtitle track")
+	// ErrSubtitleTrackNotFound is returned when a source has subtitle streams
+	// but none at the requested index.
+	ErrSubtitleTrackNotFound = errors.New("subtitle track not found")
+	// ErrImageSubtitleMux is returned when an image-based subtitle stream is
+	// requested as a soft track; those can only be burned in.
+	ErrImageSubtitleMux = errors.New("image-based subtitles can only be burned in")
+)
+
+// MediaInfo describes a source file's container and streams as reported by ffprobe.
+type MediaInfo struct {
+	Duration       float64         `json:"duration"`
+	Width          int             `json:"width"`
+	Height         int             `json:"height"`
+	VideoCodec     string          `json:"videoCodec"`
+	AudioCodec     string          `json:"audioCodec"`
+	Bitrate        int64           `json:"bitrate"`
+	AudioTracks    []AudioTrack    `json:"audioTracks"`
+	SubtitleTracks []SubtitleTrack `json:"subtitleTracks"`
+}
+
+// AudioTrack describes one audio stream. Index counts audio streams only, in
+// source order, matching ffmpeg's `0:a:<index>` stream specifier.
+type AudioTrack struct {
+	Index    int    `json:"index"`
+	Codec    string `json:"codec"`
+	Language string `json:"language,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Channels int    `json:"channels"`
+	// SampleRate is in Hz; 0 when ffprobe did not report it.
+	SampleRate int  `json:"sampleRate,omitempty"`
+	Default    bool `json:"default"`
+}
+
+// SubtitleTrack describes one subtitle stream. Index counts subtitle streams
+// only, matching ffmpeg's `0:s:<index>` specifier. Text is false for
+// image-based formats such as PGS or VobSub, which can only be burned in.
+type SubtitleTrack struct {
+	Index    int    `json:"index"`
+	Codec    string `json:"codec"`
+	Language string `json:"language,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Default  bool   `json:"default"`
+	Forced   bool   `json:"forced"`
+	Text     bool   `json:"text"`
+}
+
+// SubtitleSelection picks an embedded subtitle stream for MP4 output: muxed as
+// a soft mov_text track, or rendered into the video when Burn is set.
+type SubtitleSelection struct {
+	Index int
+	Burn  bool
+}
+
+// NoSubtitles converts without subtitles.
+var NoSubtitles = SubtitleSelection{Index: -1}
+
+// Enabled reports whether a subtitle stream is selected.
+func (s SubtitleSelection) Enabled() bool {
+	return s.Index >= 0
+}
+
+// ParseAudioTrack parses an audio track index. An empty value yields
+// DefaultAudioTrack.
+func ParseAudioTrack(raw string) (int, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return DefaultAudioTrack, nil
+	}
+	index, err := strconv.Atoi(value)
+	if err != nil || index < 0 || index > maxAudioTrack {
+		return 0, ErrInvalidAudioTrack
+	}
+	return index, nil
+}
+
+// AudioVariantPath names the artifacts converted with an explicit audio track
+// after the source path, so every track gets its own output. The default track
+// keeps the source path.
+func AudioVariantPath(relPath string, track int) string {
+	if track < 0 {
+		return relPath
+	}
+	return variantPath(relPath, fmt.Sprintf("audio%d", track))
+}
+
+// variantPath files a variant of relPath under the reserved VariantDir next to
+// it, as `<dir>/.variants/<tags>/<name>`. Tagging a variant again adds to its
+// tags, joined by "~", so each selection has exactly one name.
+func variantPath(relPath, tag string) string {
+	dir, name := path.Split(relPath)
+	parent, tags := path.Split(strings.TrimSuffix(dir, "/"))
+	if path.Base(parent) == VariantDir {
+		return path.Join(parent, tags+"~"+tag, name)
+	}
+	return path.Join(dir, VariantDir, tag, name)
+}
+
+// ParseSubtitleSelection parses a subtitle track index and burn flag. An empty
+// index yields NoSubtitles.
+func ParseSubtitleSelection(rawIndex string, burn bool) (SubtitleSelection, error) {
+	value := strings.TrimSpace(rawIndex)
+	if value == "" {
+		return NoSubtitles, nil
+	}
+	index, err := strconv.Atoi(value)
+	if err != nil || index < 0 || index > maxSubtitleTrack {
+		return NoSubtitles, ErrInvalidSubtitleTrack
+	}
+	return SubtitleSelection{Index: index, Burn: burn}, nil
+}
+
+// SubtitleVariantPath names the artifacts converted with a subtitle selection
+// after the source path, keeping soft and burned renditions apart. Without a
+// selection it returns relPath unchanged.
+func SubtitleVariantPath(relPath string, subs SubtitleSelection) string {
+	if !subs.Enabled() {
+		return relPath
+	}
+	mode := "sub"
+	if subs.Burn {
+		mode = "burn"
+	}
+	return variantPath(relPath, fmt.Sprintf("%s%d", mode, subs.Index))
+}
@@ -0,0 +1,10 @@
+package media
+
+// DiskUsage describes capacity of a filesystem backing one of the media roots.
+type DiskUsage struct {
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	TotalBytes uint64 `json:"totalBytes"`
+	FreeBytes  uint64 `json:"freeBytes"`
+	UsedBytes  uint64 `json:"usedBytes"`
+}
@@ -9,3 +9,23 @@ type Video struct {
 	Size       int64
 	ModifiedAt time.Time
 }
+
+// Entry is one item of a library directory listing: a video file or a subdirectory.
+type Entry struct {
+	Name       string
+	Path       string
+	IsDir      bool
+	Size       int64
+	ModifiedAt time.Time
+	// Children counts the videos and subdirectories directly inside a directory.
+	Children int
+}
+
+// TrashedVideo is a deleted video kept in the trash until restored or expired.
+type TrashedVideo struct {
+	// Name identifies the item for restoring; it is unique within the trash.
+	Name         string
+	OriginalPath string
+	Size         int64
+	DeletedAt    time.Time
+}
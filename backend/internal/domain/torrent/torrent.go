@@ -1,5 +1,16 @@
 package torrent
 
+import "errors"
+
+var (
+	// ErrNotFound reports that the torrent engine has no torrent with the given id.
+	ErrNotFound = errors.New("torrent not found")
+	// ErrInvalidFileIndex reports a file index outside the torrent's file list.
+	ErrInvalidFileIndex = errors.New("invalid torrent file index")
+	// ErrInvalidLimit reports a seed ratio or upload limit out of range.
+	ErrInvalidLimit = errors.New("invalid torrent limit")
+)
+
 // File describes a media file inside torrent payload.
 type File struct {
 	Index          int    `json:"index"`
@@ -26,3 +37,33 @@ type Info struct {
 	IsFinished     bool    `json:"isFinished"`
 	Files          []File  `json:"files"`
 }
+
+// FocusState describes how streaming focus is currently applied to a torrent.
+type FocusState struct {
+	TorrentID int    `json:"torrentId"`
+	Mode      string `json:"mode"`
+	FileIndex *int   `json:"fileIndex,omitempty"`
+	LastPiece *int   `json:"lastPiece,omitempty"`
+}
+
+// TransferStats are the totals the torrent engine keeps for a period.
+type TransferStats struct {
+	UploadedBytes   int64 `json:"uploadedBytes"`
+	DownloadedBytes int64 `json:"downloadedBytes"`
+	FilesAdded      int64 `json:"filesAdded"`
+	SessionCount    int64 `json:"sessionCount"`
+	SecondsActive   int64 `json:"secondsActive"`
+}
+
+// SessionStats describes the torrent engine as a whole: current transfer rates
+// in bytes per second, torrent counts, and totals for the running session and
+// across all sessions.
+type SessionStats struct {
+	DownloadSpeed      int64         `json:"downloadSpeed"`
+	UploadSpeed        int64         `json:"uploadSpeed"`
+	TorrentCount       int           `json:"torrentCount"`
+	ActiveTorrentCount int           `json:"activeTorrentCount"`
+	PausedTorrentCount int           `json:"pausedTorrentCount"`
+	Current            TransferStats `json:"current"`
+	Cumulative         TransferStats `json:"cumulative"`
+}
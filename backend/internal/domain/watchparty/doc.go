@@ -0,0 +1,2 @@
+// Package watchparty defines the persisted watch-party hub and chat models.
+package watchparty
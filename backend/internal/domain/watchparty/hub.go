@@ -0,0 +1,32 @@
+package watchparty
+
+// HubRecord is the persisted part of a hub. Members and subscribers are live
+// connection state and are never stored.
+type HubRecord struct {
+	ID          string  `json:"id"`
+	OwnerID     string  `json:"ownerId"`
+	OwnerName   string  `json:"ownerName"`
+	Kind        string  `json:"kind"`
+	VideoPath   string  `json:"videoPath"`
+	CurrentTime float64 `json:"currentTime"`
+	Playing     bool    `json:"playing"`
+	// PlaybackRate is absent from records written before rate sync; those load at 1.0.
+	PlaybackRate float64 `json:"playbackRate,omitempty"`
+	// PasswordHash is the hashed password of a private hub.
+	PasswordHash string `json:"passwordHash,omitempty"`
+	// Seq is the hub's last event sequence number.
+	Seq       uint64        `json:"seq,omitempty"`
+	UpdatedAt int64         `json:"updatedAt"`
+	Messages  []ChatMessage `json:"messages"`
+	// Kicked lists users the owner removed, so they stay out after a restart.
+	Kicked []string `json:"kicked,omitempty"`
+}
+
+// ChatMessage stores a text entry inside a watch hub.
+type ChatMessage struct {
+	ID        string `json:"id"`
+	UserID    string `json:"userId"`
+	Username  string `json:"username"`
+	Text      string `json:"text"`
+	CreatedAt int64  `json:"createdAt"`
+}
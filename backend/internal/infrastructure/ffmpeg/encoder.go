@@ -0,0 +1,232 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Video encoder backends selectable with NewConverter.
+const (
+	EncoderSoftware = "libx264"
+	EncoderNVENC    = "h264_nvenc"
+	EncoderVAAPI    = "h264_vaapi"
+	EncoderQSV      = "h264_qsv"
+)
+
+// DefaultVAAPIDevice is the render node the VAAPI encoder uploads frames to.
+const DefaultVAAPIDevice = "/dev/dri/renderD128"
+
+// Encoding holds the quality settings of transcoded output. Preset and CRF
+// apply to libx264; hardware encoders keep their own tuning. AudioKbps is the
+// AAC bitrate of re-encoded audio outside the adaptive HLS ladder.
+type Encoding struct {
+	Preset    string
+	CRF       int
+	AudioKbps int
+}
+
+// DefaultEncoding is the encoding conversions used before it was configurable.
+var DefaultEncoding = Encoding{Preset: "veryfast", CRF: 20, AudioKbps: 192}
+
+// x264Presets are the presets libx264 accepts, fastest first.
+var x264Presets = []string{"ultrafast", "superfast", "veryfast", "faster", "fast", "medium", "slow", "slower", "veryslow", "placebo"}
+
+// Validate reports an unknown preset, a CRF outside 0-51 or a non-positive
+// audio bitrate.
+func (e Encoding) Validate() error {
+	known := false
+	for _, preset := range x264Presets {
+		known = known || e.Preset == preset
+	}
+	if !known {
+		return fmt.Errorf("unknown x264 preset %q, want one of %s", e.Preset, strings.Join(x264Presets, ", "))
+	}
+	if e.CRF < 0 || e.CRF > 51 {
+		return fmt.Errorf("CRF %d is outside 0-51", e.CRF)
+	}
+	if e.AudioKbps <= 0 {
+		return fmt.Errorf("audio bitrate %dk must be positive", e.AudioKbps)
+	}
+	return nil
+}
+
+// encoderErrors are ffmpeg messages that mean the hardware encoder or its device
+// is unusable, as opposed to a problem with the source.
+var encoderErrors = []string{
+	"unknown encoder",
+	"error while opening encoder",
+	"error initializing output stream",
+	"device creation failed",
+	"failed to initialise vaapi",
+	"no capable devices found",
+	"no nvenc capable devices",
+	"cannot load",
+	"openencodesessionex failed",
+	"failed to set value",
+	"error creating a mfx session",
+}
+
+// videoEncoder holds the ffmpeg options one H.264 encoder needs.
+type videoEncoder struct {
+	name string
+	// input options go before -i, e.g. the device a hardware encoder uses.
+	input []string
+	// upload ends the video filter chain, moving frames to the encoder's device.
+	upload string
+	codec  []string
+	// rate selects the encoder without a quality target, for bitrate-capped output.
+	rate []string
+}
+
+func newVideoEncoder(name, vaapiDevice string, encoding Encoding) videoEncoder {
+	switch name {
+	case EncoderNVENC:
+		return videoEncoder{
+			name:  name,
+			codec: []string{"-c:v", "h264_nvenc", "-preset", "fast", "-rc", "vbr", "-cq", "23"},
+			rate:  []string{"-c:v", "h264_nvenc", "-preset", "fast", "-rc", "vbr"},
+		}
+	case EncoderVAAPI:
+		return videoEncoder{
+			name:   name,
+			input:  []string{"-vaapi_device", vaapiDevice},
+			upload: "format=nv12,hwupload",
+			codec:  []string{"-c:v", "h264_vaapi", "-qp", "20"},
+			rate:   []string{"-c:v", "h264_vaapi"},
+		}
+	case EncoderQSV:
+		return videoEncoder{
+			name:   name,
+			upload: "format=nv12",
+			codec:  []string{"-c:v", "h264_qsv", "-preset", "veryfast", "-global_quality", "20"},
+			rate:   []string{"-c:v", "h264_qsv", "-preset", "veryfast"},
+		}
+	default:
+		return videoEncoder{
+			name:  EncoderSoftware,
+			codec: []string{"-c:v", "libx264", "-preset", encoding.Preset, "-crf", strconv.Itoa(encoding.CRF)},
+			rate:  []string{"-c:v", "libx264", "-preset", encoding.Preset},
+		}
+	}
+}
+
+// videoArgs returns output options that run filter (possibly empty) and encode
+// the video. yuv420p forces 8-bit 4:2:0 output; hardware uploads set their own
+// pixel format.
+func (e videoEncoder) videoArgs(filter string, yuv420p bool) []string {
+	var args []string
+	if chain := e.filterChain(filter); chain != "" {
+		args = append(args, "-vf", chain)
+	}
+	return append(args, e.encodeArgs(yuv420p)...)
+}
+
+// encodeArgs returns the codec options alone, for outputs fed by -filter_complex.
+func (e videoEncoder) encodeArgs(yuv420p bool) []string {
+	args := append([]string(nil), e.codec...)
+	if yuv420p && e.upload == "" {
+		args = append(args, "-pix_fmt", "yuv420p")
+	}
+	return args
+}
+
+// bitrateArgs returns codec options that cap the video at kbps.
+func (e videoEncoder) bitrateArgs(kbps int) []string {
+	return append(append([]string(nil), e.rate...),
+		"-b:v", fmt.Sprintf("%dk", kbps),
+		"-maxrate", fmt.Sprintf("%dk", kbps*107/100),
+		"-bufsize", fmt.Sprintf("%dk", kbps*2),
+	)
+}
+
+// filterChain appends the encoder's upload step to filter.
+func (e videoEncoder) filterChain(filter string) string {
+	switch {
+	case filter == "":
+		return e.upload
+	case e.upload == "":
+		return filter
+	default:
+		return filter + "," + e.upload
+	}
+}
+
+// Encoder reports the video encoder conversions currently use.
+func (c *Converter) Encoder() string {
+	enc, _ := c.currentEncoder()
+	return enc.name
+}
+
+// currentEncoder returns the encoder for the next transcode and whether that run
+// is the hardware encoder's trial.
+func (c *Converter) currentEncoder() (videoEncoder, bool) {
+	c.encoderMu.Lock()
+	defer c.encoderMu.Unlock()
+	if c.encoder == EncoderSoftware || c.hwFailed {
+		return newVideoEncoder(EncoderSoftware, "", c.encoding), false
+	}
+	return newVideoEncoder(c.encoder, c.VAAPIDevice, c.encoding), !c.hwVerified
+}
+
+// settledEncoder returns a hardware encoder only once it has proven to work, for
+// runs that cannot be retried, such as streams already writing to a client.
+func (c *Converter) settledEncoder() videoEncoder {
+	enc, trial := c.currentEncoder()
+	if trial {
+		return newVideoEncoder(EncoderSoftware, "", c.encoding)
+	}
+	return enc
+}
+
+// withEncoder runs a transcode attempt with the configured video encoder. Until
+// a hardware encoder has completed a run, a failure that ffmpeg attributes to the
+// encoder or its device retries the attempt with libx264, and the software
+// encoder is used for every later run.
+func (c *Converter) withEncoder(ctx context.Context, attempt func(videoEncoder) error) error {
+	enc, trial := c.currentEncoder()
+	err := attempt(enc)
+	if !trial {
+		return err
+	}
+	if err == nil {
+		c.recordEncoder(true)
+		return nil
+	}
+	if ctx.Err() != nil || !isEncoderError(err) {
+		return err
+	}
+
+	c.recordEncoder(false)
+	return attempt(newVideoEncoder(EncoderSoftware, "", c.encoding))
+}
+
+// transcodeIf runs attempt through withEncoder when it transcodes video and
+// directly otherwise, so stream-copy runs never decide the hardware trial.
+func (c *Converter) transcodeIf(ctx context.Context, transcode bool, attempt func(videoEncoder) error) error {
+	if !transcode {
+		return attempt(newVideoEncoder(EncoderSoftware, "", c.encoding))
+	}
+	return c.withEncoder(ctx, attempt)
+}
+
+func (c *Converter) recordEncoder(works bool) {
+	c.encoderMu.Lock()
+	defer c.encoderMu.Unlock()
+	if works {
+		c.hwVerified = true
+	} else {
+		c.hwFailed = true
+	}
+}
+
+func isEncoderError(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, marker := range encoderErrors {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
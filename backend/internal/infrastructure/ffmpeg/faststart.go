@@ -0,0 +1,101 @@
+package ffmpeg
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// maxFaststartBoxes bounds the top-level box walk; real files have a handful.
+const maxFaststartBoxes = 64
+
+// Faststart reports whether the MP4 at inputPath has its moov box ahead of the
+// media data, so players can start and seek without reading the file's end.
+// Only top-level box headers are read.
+func (c *Converter) Faststart(inputPath string) (bool, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	return faststart(file)
+}
+
+func faststart(r io.ReadSeeker) (bool, error) {
+	header := make([]byte, 16)
+	var offset int64
+	for i := 0; i < maxFaststartBoxes; i++ {
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return false, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			// The box runs to the end of the file.
+			size = -1
+		case 1:
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return false, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+
+		switch string(header[4:8]) {
+		case "moov":
+			return true, nil
+		case "mdat":
+			return false, nil
+		}
+		if size < 0 {
+			break
+		}
+		if size < headerSize {
+			return false, errors.New("invalid MP4 box size")
+		}
+		offset += size
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return false, err
+		}
+	}
+	return false, errors.New("no moov or mdat box found")
+}
+
+// RemuxMP4 copies every stream of an MP4 source into outputPath with the moov
+// box moved to the front. Nothing is re-encoded.
+func (c *Converter) RemuxMP4(ctx context.Context, inputPath, outputPath string, onProgress func(int)) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		return err
+	}
+
+	tmpPath := outputPath + ".tmp.mp4"
+	duration, _ := c.probeDuration(ctx, inputPath)
+	if err := runWithProgress(ctx, remuxArgs(inputPath, tmpPath), int64(duration*1000), onProgress); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	_ = os.Remove(outputPath)
+	return os.Rename(tmpPath, outputPath)
+}
+
+func remuxArgs(inputPath, tmpPath string) []string {
+	return []string{
+		"-y",
+		"-i", inputPath,
+		"-map", "0",
+		"-dn",
+		"-c", "copy",
+		"-progress", "pipe:1", "-nostats",
+		"-f", "mp4",
+		"-movflags", "+faststart",
+		tmpPath,
+	}
+}
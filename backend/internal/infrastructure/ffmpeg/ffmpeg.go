@@ -4,6 +4,10 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -11,24 +15,110 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"evd/internal/domain/media"
 )
 
 const (
 	HLSMarkerFile = ".transcoded"
 	MP4MarkerFile = ".mp4transcoded"
+
+	// FMP4InitFile is the initialization segment of fragmented-MP4 HLS output.
+	FMP4InitFile = "init.mp4"
 )
 
+// remoteProtocols restricts what ffmpeg may open when the input is a URL, so a remote
+// playlist cannot pull in local files or other protocols.
+const remoteProtocols = "http,https,tcp,tls"
+
+// localProtocols keeps local inputs local, so a downloaded playlist cannot make
+// ffmpeg fetch segments from the network.
+const localProtocols = "file"
+
+// defaultHLSSegmentSeconds is the HLS_SEGMENT_SECONDS default. Outputs made
+// with it and the other defaults keep their bare base marker version.
+const defaultHLSSegmentSeconds = 20
+
 // Converter wraps ffmpeg/ffprobe calls.
 type Converter struct {
 	HLSVersion        string
 	MP4Version        string
 	HLSSegmentSeconds int
+	// hlsBase is the HLS marker version before the settings hash.
+	hlsBase string
+	// VAAPIDevice is the render node used by the h264_vaapi encoder.
+	VAAPIDevice string
+
+	encoderMu sync.Mutex
+	encoder   string
+	// hwVerified and hwFailed record the outcome of the hardware encoder's first run.
+	hwVerified bool
+	hwFailed   bool
+
+	encoding Encoding
+
+	toolsMu    sync.Mutex
+	toolsFound bool
+
+	probes *probeCache
 }
 
-// NewConverter creates ffmpeg adapter with marker versions and segment duration.
-func NewConverter(hlsVersion, mp4Version string, hlsSegmentSeconds int) *Converter {
-	return &Converter{HLSVersion: hlsVersion, MP4Version: mp4Version, HLSSegmentSeconds: hlsSegmentSeconds}
+// NewConverter creates ffmpeg adapter with marker versions, segment duration,
+// video encoder backend and output encoding. Unknown encoder names select
+// libx264. The marker versions are tagged with a hash of the settings that
+// shape each output, so changing any of them re-transcodes existing output.
+func NewConverter(hlsVersion, mp4Version string, hlsSegmentSeconds int, encoder string, encoding Encoding) *Converter {
+	encoder = newVideoEncoder(encoder, "", encoding).name
+	return &Converter{
+		HLSVersion:        markerVersion(hlsVersion, hlsMarkerParams(encoder, encoding, hlsSegmentSeconds, nil, false), defaultHLSMarkerParams),
+		MP4Version:        markerVersion(mp4Version, mp4MarkerParams(encoder, encoding), defaultMP4MarkerParams),
+		HLSSegmentSeconds: hlsSegmentSeconds,
+		hlsBase:           hlsVersion,
+		VAAPIDevice:       DefaultVAAPIDevice,
+		encoding:          encoding,
+		encoder:           encoder,
+		probes:            newProbeCache(),
+	}
+}
+
+// SetHLSLayout folds the adaptive ladder and the trim setting into the HLS
+// marker version. renditions is nil when adaptive output is off.
+func (c *Converter) SetHLSLayout(renditions []media.Rendition, trimEnabled bool) {
+	c.HLSVersion = markerVersion(c.hlsBase, hlsMarkerParams(c.encoder, c.encoding, c.HLSSegmentSeconds, renditions, trimEnabled), defaultHLSMarkerParams)
+}
+
+var (
+	defaultMP4MarkerParams = mp4MarkerParams(EncoderSoftware, DefaultEncoding)
+	defaultHLSMarkerParams = hlsMarkerParams(EncoderSoftware, DefaultEncoding, defaultHLSSegmentSeconds, nil, false)
+)
+
+// mp4MarkerParams spells out the settings that shape MP4 output, one
+// key=value pair per setting in a fixed order.
+func mp4MarkerParams(encoder string, encoding Encoding) string {
+	return fmt.Sprintf("encoder=%s;preset=%s;crf=%d;audio_kbps=%d", encoder, encoding.Preset, encoding.CRF, encoding.AudioKbps)
+}
+
+// hlsMarkerParams is mp4MarkerParams plus the HLS segment length, adaptive
+// ladder and trim setting.
+func hlsMarkerParams(encoder string, encoding Encoding, segmentSeconds int, renditions []media.Rendition, trimEnabled bool) string {
+	ladder := make([]string, len(renditions))
+	for i, r := range renditions {
+		ladder[i] = fmt.Sprintf("%d:%d:%d", r.Height, r.VideoBitrate, r.AudioBitrate)
+	}
+	return fmt.Sprintf("%s;segment_seconds=%d;renditions=%s;trim=%t", mp4MarkerParams(encoder, encoding), segmentSeconds, strings.Join(ladder, ","), trimEnabled)
+}
+
+// markerVersion appends a short hash of params to base. It only depends on
+// params, so the same configuration keeps its markers across restarts, and
+// the defaults keep the bare base so upgrading does not re-transcode.
+func markerVersion(base, params, defaults string) string {
+	if params == defaults {
+		return base
+	}
+	sum := sha256.Sum256([]byte(params))
+	return base + "-" + hex.EncodeToString(sum[:4])
 }
 
 // HLSMarkerVersion returns current HLS transcoding marker value.
@@ -41,158 +131,521 @@ func (c *Converter) MP4MarkerVersion() string {
 	return c.MP4Version
 }
 
+// ProbeDuration returns media duration in seconds as reported by ffprobe.
+func (c *Converter) ProbeDuration(ctx context.Context, inputPath string) (float64, error) {
+	return c.probeDuration(ctx, inputPath)
+}
+
+// Probe reports container and stream details for inputPath.
+func (c *Converter) Probe(ctx context.Context, inputPath string) (media.MediaInfo, error) {
+	return c.probeInfo(ctx, inputPath)
+}
+
 // ConvertHLS converts a source media file into HLS playlist and segments.
-func (c *Converter) ConvertHLS(ctx context.Context, inputPath, outputDir, playlistPath string) error {
+// audioTrack selects the audio stream, or media.DefaultAudioTrack.
+func (c *Converter) ConvertHLS(ctx context.Context, inputPath, outputDir, playlistPath string, format media.HLSFormat, audioTrack int) error {
 	if err := os.MkdirAll(outputDir, 0o755); err != nil {
 		return err
 	}
 
-	gop := c.HLSSegmentSeconds * 30
-	segmentPattern := filepath.Join(outputDir, "segment%05d.ts")
-	args := []string{
-		"-y",
-		"-i", inputPath,
-		"-sn",
-		"-c:v", "libx264",
-		"-preset", "veryfast",
-		"-crf", "20",
-		"-g", fmt.Sprintf("%d", gop),
-		"-keyint_min", fmt.Sprintf("%d", gop),
-		"-sc_threshold", "0",
-		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", c.HLSSegmentSeconds),
-		"-c:a", "aac",
-		"-ac", "2",
-		"-b:a", "192k",
-		"-ar", "48000",
-		"-f", "hls",
-		"-hls_time", fmt.Sprintf("%d", c.HLSSegmentSeconds),
-		"-hls_list_size", "0",
-		"-hls_playlist_type", "event",
-		"-hls_flags", "independent_segments+temp_file",
-		"-hls_segment_filename", segmentPattern,
-		playlistPath,
+	audioIndex := c.sourceAudioIndex(ctx, inputPath, audioTrack)
+	return c.withEncoder(ctx, func(enc videoEncoder) error {
+		return run(ctx, "ffmpeg", c.hlsArgs(enc, inputPath, outputDir, playlistPath, audioIndex, format, nil)...)
+	})
+}
+
+// ConvertHLSWithProgress converts like ConvertHLS and reports conversion
+// percentage from ffmpeg's progress output against the probed duration. When the
+// duration cannot be probed it converts without progress reports.
+func (c *Converter) ConvertHLSWithProgress(ctx context.Context, inputPath, outputDir, playlistPath string, format media.HLSFormat, audioTrack int, onProgress func(int)) error {
+	duration, _ := c.probeDuration(ctx, inputPath)
+	totalMs := int64(duration * 1000)
+	if totalMs <= 0 {
+		return c.ConvertHLS(ctx, inputPath, outputDir, playlistPath, format, audioTrack)
 	}
 
-	return run(ctx, "ffmpeg", args...)
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return err
+	}
+
+	audioIndex := c.sourceAudioIndex(ctx, inputPath, audioTrack)
+	return c.withEncoder(ctx, func(enc videoEncoder) error {
+		args := append([]string{"-progress", "pipe:1", "-nostats"}, c.hlsArgs(enc, inputPath, outputDir, playlistPath, audioIndex, format, nil)...)
+		return runWithProgress(ctx, args, totalMs, onProgress)
+	})
+}
+
+// ResumeHLS continues a stopped HLS conversion after the complete segments
+// described by from, appending to the existing playlist.
+func (c *Converter) ResumeHLS(ctx context.Context, inputPath, outputDir, playlistPath string, format media.HLSFormat, audioTrack int, from media.HLSResumePoint) error {
+	audioIndex := c.sourceAudioIndex(ctx, inputPath, audioTrack)
+	return c.withEncoder(ctx, func(enc videoEncoder) error {
+		return run(ctx, "ffmpeg", c.hlsArgs(enc, inputPath, outputDir, playlistPath, audioIndex, format, &from)...)
+	})
 }
 
 // ConvertHLSFollow converts a growing file into HLS until idle timeout.
-func (c *Converter) ConvertHLSFollow(ctx context.Context, inputPath, outputDir, playlistPath string, idleTimeout time.Duration) error {
+func (c *Converter) ConvertHLSFollow(ctx context.Context, inputPath, outputDir, playlistPath string, format media.HLSFormat, audioTrack int, idleTimeout time.Duration) error {
 	if err := os.MkdirAll(outputDir, 0o755); err != nil {
 		return err
 	}
 
-	reader, err := newGrowReader(ctx, inputPath, 500*time.Millisecond, idleTimeout)
+	audioIndex := c.sourceAudioIndex(ctx, inputPath, audioTrack)
+	return c.withEncoder(ctx, func(enc videoEncoder) error {
+		reader, err := newGrowReader(ctx, inputPath, 500*time.Millisecond, idleTimeout)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		args := append([]string{"-fflags", "+genpts"}, c.hlsArgs(enc, "pipe:0", outputDir, playlistPath, audioIndex, format, nil)...)
+		return runWithInput(ctx, reader, "ffmpeg", args...)
+	})
+}
+
+// ConvertMP4 converts media into seekable MP4 output.
+func (c *Converter) ConvertMP4(ctx context.Context, inputPath, outputPath string, audioTrack int) error {
+	outputDir := filepath.Dir(outputPath)
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return err
+	}
+
+	transcodeVideo := c.transcodeVideo(ctx, inputPath)
+
+	tmpPath := outputPath + ".tmp.mp4"
+	audioIndex := c.sourceAudioIndex(ctx, inputPath, audioTrack)
+	audio := c.mp4AudioArgs(ctx, inputPath, audioIndex)
+	err := mp4WithCopyFallback(ctx, transcodeVideo, nil, func(transcode bool, _ func(int)) error {
+		return c.transcodeIf(ctx, transcode, func(enc videoEncoder) error {
+			_ = os.Remove(tmpPath)
+			return run(ctx, "ffmpeg", mp4Args(enc, inputPath, tmpPath, transcode, attemptAudioArgs(audio, audioIndex, c.encoding.AudioKbps, transcodeVideo, transcode), false)...)
+		})
+	})
 	if err != nil {
+		_ = os.Remove(tmpPath)
 		return err
 	}
-	defer reader.Close()
 
-	gop := c.HLSSegmentSeconds * 30
-	segmentPattern := filepath.Join(outputDir, "segment%05d.ts")
+	_ = os.Remove(outputPath)
+	return os.Rename(tmpPath, outputPath)
+}
+
+// ConvertMP4WithProgress converts media into MP4 and reports conversion percentage.
+func (c *Converter) ConvertMP4WithProgress(ctx context.Context, inputPath, outputPath string, audioTrack int, onProgress func(int)) error {
+	duration, _ := c.probeDuration(ctx, inputPath)
+	totalMs := int64(duration * 1000)
+	if totalMs <= 0 {
+		return c.ConvertMP4(ctx, inputPath, outputPath, audioTrack)
+	}
+
+	outputDir := filepath.Dir(outputPath)
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return err
+	}
+
+	transcodeVideo := c.transcodeVideo(ctx, inputPath)
+
+	tmpPath := outputPath + ".tmp.mp4"
+	audioIndex := c.sourceAudioIndex(ctx, inputPath, audioTrack)
+	audio := c.mp4AudioArgs(ctx, inputPath, audioIndex)
+	err := mp4WithCopyFallback(ctx, transcodeVideo, onProgress, func(transcode bool, report func(int)) error {
+		return c.transcodeIf(ctx, transcode, func(enc videoEncoder) error {
+			_ = os.Remove(tmpPath)
+			return runWithProgress(ctx, mp4Args(enc, inputPath, tmpPath, transcode, attemptAudioArgs(audio, audioIndex, c.encoding.AudioKbps, transcodeVideo, transcode), true), totalMs, report)
+		})
+	})
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	_ = os.Remove(outputPath)
+	return os.Rename(tmpPath, outputPath)
+}
+
+// mp4WithCopyFallback runs attempt once and, when a video stream-copy run fails,
+// once more with full transcoding; some h264 bitstreams only fail at mux time.
+// Progress never moves backwards: the retry reports its progress scaled into
+// the range left over by the failed copy attempt.
+func mp4WithCopyFallback(ctx context.Context, transcodeVideo bool, onProgress func(int), attempt func(transcode bool, report func(int)) error) error {
+	reached := 0
+	err := attempt(transcodeVideo, func(percent int) {
+		if percent > reached {
+			reached = percent
+		}
+		if onProgress != nil {
+			onProgress(percent)
+		}
+	})
+	if err == nil || transcodeVideo || ctx.Err() != nil || errors.Is(err, media.ErrConversionStopped) {
+		return err
+	}
+
+	base := reached
+	return attempt(true, func(percent int) {
+		if onProgress != nil {
+			onProgress(base + (100-base)*percent/100)
+		}
+	})
+}
+
+// IngestMP4 converts a downloaded ingest source into an MP4 at outputPath,
+// stopping at maxBytes of output or maxDuration of media, whichever comes first.
+func (c *Converter) IngestMP4(ctx context.Context, inputPath, outputPath string, maxBytes int64, maxDuration time.Duration, onProgress func(int)) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		return err
+	}
+
+	duration, _ := c.probeDuration(ctx, inputPath)
+	if limit := maxDuration.Seconds(); limit > 0 && (duration <= 0 || duration > limit) {
+		duration = limit
+	}
+
+	transcodeVideo := c.transcodeVideo(ctx, inputPath)
+
+	return c.transcodeIf(ctx, transcodeVideo, func(enc videoEncoder) error {
+		args := ingestArgs(enc, inputPath, outputPath, transcodeVideo, c.encoding.AudioKbps, maxBytes, maxDuration)
+		return runWithProgress(ctx, args, int64(duration*1000), onProgress)
+	})
+}
+
+// ExtractThumbnail writes a single JPEG frame taken at atSeconds into outputPath.
+func (c *Converter) ExtractThumbnail(ctx context.Context, inputPath, outputPath string, atSeconds float64) error {
+	if atSeconds < 0 {
+		atSeconds = 0
+	}
+
+	tmpPath := outputPath + ".tmp.jpg"
+	_ = os.Remove(tmpPath)
+
 	args := []string{
 		"-y",
-		"-fflags", "+genpts",
-		"-i", "pipe:0",
-		"-sn",
-		"-c:v", "libx264",
-		"-preset", "veryfast",
-		"-crf", "20",
+		"-ss", formatSeconds(atSeconds),
+		"-i", inputPath,
+		"-frames:v", "1",
+		"-vf", "scale=480:-2",
+		"-q:v", "4",
+		"-f", "image2",
+		tmpPath,
+	}
+	if err := run(ctx, "ffmpeg", args...); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	_ = os.Remove(outputPath)
+	return os.Rename(tmpPath, outputPath)
+}
+
+// StreamMP4 writes fragmented MP4 stream to out.
+func (c *Converter) StreamMP4(ctx context.Context, inputPath string, out io.Writer, follow bool, idleTimeout time.Duration) error {
+	transcodeVideo := c.transcodeVideo(ctx, inputPath)
+
+	input := inputPath
+	if follow {
+		input = "pipe:0"
+	}
+	audioIndex := c.sourceAudioIndex(ctx, inputPath, media.DefaultAudioTrack)
+	args := streamMP4Args(c.settledEncoder(), input, transcodeVideo, c.mp4AudioArgs(ctx, inputPath, audioIndex))
+
+	if follow {
+		reader, err := newGrowReader(ctx, inputPath, 500*time.Millisecond, idleTimeout)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		return runWithInputOutput(ctx, reader, out, "ffmpeg", args...)
+	}
+
+	return runWithOutput(ctx, out, "ffmpeg", args...)
+}
+
+// hlsArgs builds ffmpeg arguments for HLS output with the chosen audio track mapped first
+// (or no audio at all for noAudio).
+// fMP4 output writes an `init.mp4` initialization segment followed by `.m4s` fragments.
+// A non-nil resume seeks the input to the resume point and appends to the existing
+// playlist, keeping segment numbers and timestamps continuous.
+func (c *Converter) hlsArgs(enc videoEncoder, input, outputDir, playlistPath string, audioIndex int, format media.HLSFormat, resume *media.HLSResumePoint) []string {
+	hlsFlags := "independent_segments+temp_file"
+	args := append([]string{"-y"}, enc.input...)
+	if resume != nil {
+		hlsFlags += "+append_list"
+		args = append(args, "-ss", formatSeconds(resume.OffsetSeconds))
+	}
+	args = append(args, "-i", input, "-sn", "-map", "0:v:0?")
+	args = append(args, audioArgs(audioIndex, c.encoding.AudioKbps)...)
+	args = append(args, enc.videoArgs("", false)...)
+	args = append(args, c.hlsMuxerArgs(hlsFlags)...)
+	if resume != nil {
+		args = append(args,
+			"-output_ts_offset", formatSeconds(resume.OffsetSeconds),
+			"-start_number", strconv.Itoa(resume.Segments),
+		)
+	}
+	args = append(args, hlsSegmentArgs(outputDir, format)...)
+	args = append(args, playlistPath)
+	return args
+}
+
+// hlsMuxerArgs returns keyframe placement and HLS muxer options shared by every
+// HLS output, with keyframes aligned to segment boundaries.
+func (c *Converter) hlsMuxerArgs(hlsFlags string) []string {
+	gop := c.HLSSegmentSeconds * 30
+	return []string{
 		"-g", fmt.Sprintf("%d", gop),
 		"-keyint_min", fmt.Sprintf("%d", gop),
 		"-sc_threshold", "0",
 		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", c.HLSSegmentSeconds),
-		"-c:a", "aac",
-		"-ac", "2",
-		"-b:a", "192k",
-		"-ar", "48000",
 		"-f", "hls",
 		"-hls_time", fmt.Sprintf("%d", c.HLSSegmentSeconds),
 		"-hls_list_size", "0",
 		"-hls_playlist_type", "event",
-		"-hls_flags", "independent_segments+temp_file",
-		"-hls_segment_filename", segmentPattern,
-		playlistPath,
+		"-hls_flags", hlsFlags,
 	}
-
-	return runWithInput(ctx, reader, "ffmpeg", args...)
 }
 
-// ConvertMP4 converts media into seekable MP4 output.
-func (c *Converter) ConvertMP4(ctx context.Context, inputPath, outputPath string) error {
-	outputDir := filepath.Dir(outputPath)
-	if err := os.MkdirAll(outputDir, 0o755); err != nil {
-		return err
+// hlsSegmentArgs names the segments written into outputDir for format.
+func hlsSegmentArgs(outputDir string, format media.HLSFormat) []string {
+	if format == media.HLSFormatFMP4 {
+		return []string{
+			"-hls_segment_type", "fmp4",
+			"-hls_fmp4_init_filename", FMP4InitFile,
+			"-hls_segment_filename", filepath.Join(outputDir, "segment%05d.m4s"),
+		}
 	}
+	return []string{"-hls_segment_filename", filepath.Join(outputDir, "segment%05d.ts")}
+}
 
-	codec, _ := probeVideoCodec(ctx, inputPath)
-	transcodeVideo := codec == "" || codec != "h264"
-
-	tmpPath := outputPath + ".tmp.mp4"
-	_ = os.Remove(tmpPath)
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
 
-	args := []string{"-y", "-i", inputPath, "-sn", "-map", "0:v:0?", "-map", "0:a:0?"}
+// mp4Args builds ffmpeg arguments for seekable MP4 output written to tmpPath.
+func mp4Args(enc videoEncoder, inputPath, tmpPath string, transcodeVideo bool, audio []string, progress bool) []string {
+	args := []string{"-y"}
 	if transcodeVideo {
-		args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-crf", "20")
+		args = append(args, enc.input...)
+	}
+	args = append(args, "-i", inputPath, "-sn", "-map", "0:v:0?")
+	args = append(args, audio...)
+	if progress {
+		args = append(args, "-progress", "pipe:1", "-nostats")
+	}
+	if transcodeVideo {
+		args = append(args, enc.videoArgs("", false)...)
 	} else {
 		args = append(args, "-c:v", "copy")
 	}
 
-	args = append(args,
-		"-c:a", "aac",
-		"-ac", "2",
-		"-b:a", "192k",
-		"-ar", "48000",
+	return append(args,
 		"-f", "mp4",
 		"-movflags", "+faststart",
 		tmpPath,
 	)
+}
 
-	if err := run(ctx, "ffmpeg", args...); err != nil {
-		_ = os.Remove(tmpPath)
-		return err
+// streamMP4Args builds ffmpeg arguments for fragmented MP4 written to stdout.
+func streamMP4Args(enc videoEncoder, input string, transcodeVideo bool, audio []string) []string {
+	var args []string
+	if transcodeVideo {
+		args = append(args, enc.input...)
+	}
+	args = append(args, "-i", input, "-fflags", "+genpts", "-sn", "-map", "0:v:0?")
+	args = append(args, audio...)
+	if transcodeVideo {
+		args = append(args, enc.videoArgs("", true)...)
+	} else {
+		args = append(args, "-c:v", "copy")
 	}
 
-	_ = os.Remove(outputPath)
-	return os.Rename(tmpPath, outputPath)
+	return append(args,
+		"-movflags", "frag_keyframe+empty_moov+default_base_moof",
+		"-f", "mp4",
+		"pipe:1",
+	)
 }
 
-// ConvertMP4WithProgress converts media into MP4 and reports conversion percentage.
-func (c *Converter) ConvertMP4WithProgress(ctx context.Context, inputPath, outputPath string, onProgress func(int)) error {
-	duration, _ := probeDuration(ctx, inputPath)
-	totalMs := int64(duration * 1000)
-	if totalMs <= 0 {
-		return c.ConvertMP4(ctx, inputPath, outputPath)
+// ingestArgs builds ffmpeg arguments for MP4 output from a remote URL with output caps.
+func ingestArgs(enc videoEncoder, inputPath, outputPath string, transcodeVideo bool, audioKbps int, maxBytes int64, maxDuration time.Duration) []string {
+	args := append(inputOptions(inputPath), mp4Args(enc, inputPath, outputPath, transcodeVideo, audioArgs(0, audioKbps), true)...)
+
+	limits := []string{}
+	if maxDuration > 0 {
+		limits = append(limits, "-t", strconv.FormatFloat(maxDuration.Seconds(), 'f', 0, 64))
+	}
+	if maxBytes > 0 {
+		limits = append(limits, "-fs", strconv.FormatInt(maxBytes, 10))
 	}
 
-	outputDir := filepath.Dir(outputPath)
-	if err := os.MkdirAll(outputDir, 0o755); err != nil {
-		return err
+	// Output options must precede the output path.
+	last := len(args) - 1
+	return append(append(args[:last:last], limits...), args[last])
+}
+
+// inputOptions returns ffmpeg/ffprobe input options required for the given input.
+func inputOptions(input string) []string {
+	if strings.HasPrefix(input, "http://") || strings.HasPrefix(input, "https://") {
+		return []string{"-protocol_whitelist", remoteProtocols}
 	}
+	return []string{"-protocol_whitelist", localProtocols}
+}
 
-	codec, _ := probeVideoCodec(ctx, inputPath)
-	transcodeVideo := codec == "" || codec != "h264"
+// noAudio is the audio index for sources without audio streams.
+const noAudio = -1
 
-	tmpPath := outputPath + ".tmp.mp4"
-	_ = os.Remove(tmpPath)
+// chooseAudioArgs maps source audio track audioIndex like audioArgs, but copies
+// it when info shows it already is AAC with at most two channels at 44.1 or
+// 48 kHz, which MP4 players handle as is. Otherwise it encodes at kbps.
+func chooseAudioArgs(info media.MediaInfo, audioIndex, kbps int) []string {
+	if audioIndex < 0 || audioIndex >= len(info.AudioTracks) {
+		return audioArgs(audioIndex, kbps)
+	}
+	track := info.AudioTracks[audioIndex]
+	if track.Codec != "aac" || track.Channels < 1 || track.Channels > 2 || (track.SampleRate != 44100 && track.SampleRate != 48000) {
+		return audioArgs(audioIndex, kbps)
+	}
+	return []string{
+		"-map", fmt.Sprintf("0:a:%d?", audioIndex),
+		"-disposition:a:0", "default",
+		"-c:a", "copy",
+	}
+}
 
-	args := []string{"-y", "-i", inputPath, "-sn", "-map", "0:v:0?", "-map", "0:a:0?", "-progress", "pipe:1", "-nostats"}
-	if transcodeVideo {
-		args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-crf", "20")
-	} else {
-		args = append(args, "-c:v", "copy")
+// mp4AudioArgs probes inputPath for chooseAudioArgs. Probe failures re-encode.
+func (c *Converter) mp4AudioArgs(ctx context.Context, inputPath string, audioIndex int) []string {
+	info, err := c.probeInfo(ctx, inputPath)
+	if err != nil {
+		return audioArgs(audioIndex, c.encoding.AudioKbps)
 	}
+	return chooseAudioArgs(info, audioIndex, c.encoding.AudioKbps)
+}
 
-	args = append(args,
+// attemptAudioArgs returns the audio arguments for an mp4WithCopyFallback
+// attempt: the retry after a failed stream copy re-encodes audio at kbps as well.
+func attemptAudioArgs(audio []string, audioIndex, kbps int, transcodeVideo, transcode bool) []string {
+	if transcode && !transcodeVideo {
+		return audioArgs(audioIndex, kbps)
+	}
+	return audio
+}
+
+// audioArgs maps the chosen source audio track as the first output audio stream,
+// flags it default so players that ignore map order still pick it, and encodes it
+// as stereo AAC at kbps. Sources without audio get -an and no audio options at all.
+func audioArgs(audioIndex, kbps int) []string {
+	if audioIndex == noAudio {
+		return []string{"-an"}
+	}
+	if audioIndex < 0 {
+		audioIndex = 0
+	}
+	return []string{
+		"-map", fmt.Sprintf("0:a:%d?", audioIndex),
+		"-disposition:a:0", "default",
 		"-c:a", "aac",
 		"-ac", "2",
-		"-b:a", "192k",
+		"-b:a", fmt.Sprintf("%dk", kbps),
 		"-ar", "48000",
-		"-f", "mp4",
-		"-movflags", "+faststart",
-		tmpPath,
-	)
+	}
+}
+
+type audioStream struct {
+	Index       int `json:"index"`
+	Disposition struct {
+		Default int `json:"default"`
+	} `json:"disposition"`
+}
 
+type probeOutput struct {
+	Streams []struct {
+		CodecType   string `json:"codec_type"`
+		CodecName   string `json:"codec_name"`
+		Width       int    `json:"width"`
+		Height      int    `json:"height"`
+		Channels    int    `json:"channels"`
+		SampleRate  string `json:"sample_rate"`
+		Disposition struct {
+			Default     int `json:"default"`
+			Forced      int `json:"forced"`
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
+		Tags struct {
+			Language string `json:"language"`
+			Title    string `json:"title"`
+		} `json:"tags"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+		BitRate  string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+// parseProbe maps ffprobe's JSON into MediaInfo. The first real video stream
+// (not cover art) wins; the audio codec is the one of the default audio track.
+func parseProbe(raw []byte) (media.MediaInfo, error) {
+	var parsed probeOutput
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return media.MediaInfo{}, err
+	}
+
+	info := media.MediaInfo{AudioTracks: []media.AudioTrack{}, SubtitleTracks: []media.SubtitleTrack{}}
+	info.Duration, _ = strconv.ParseFloat(parsed.Format.Duration, 64)
+	info.Bitrate, _ = strconv.ParseInt(parsed.Format.BitRate, 10, 64)
+
+	audioStreams := make([]audioStream, 0)
+	for _, stream := range parsed.Streams {
+		switch stream.CodecType {
+		case "video":
+			if info.VideoCodec == "" && stream.Disposition.AttachedPic == 0 {
+				info.VideoCodec = stream.CodecName
+				info.Width = stream.Width
+				info.Height = stream.Height
+			}
+		case "audio":
+			var s audioStream
+			s.Disposition.Default = stream.Disposition.Default
+			audioStreams = append(audioStreams, s)
+			sampleRate, _ := strconv.Atoi(stream.SampleRate)
+			info.AudioTracks = append(info.AudioTracks, media.AudioTrack{
+				Index:      len(info.AudioTracks),
+				Codec:      stream.CodecName,
+				Language:   stream.Tags.Language,
+				Title:      stream.Tags.Title,
+				Channels:   stream.Channels,
+				SampleRate: sampleRate,
+			})
+		case "subtitle":
+			info.SubtitleTracks = append(info.SubtitleTracks, media.SubtitleTrack{
+				Index:    len(info.SubtitleTracks),
+				Codec:    stream.CodecName,
+				Language: stream.Tags.Language,
+				Title:    stream.Tags.Title,
+				Default:  stream.Disposition.Default == 1,
+				Forced:   stream.Disposition.Forced == 1,
+				Text:     textSubtitleCodecs[stream.CodecName],
+			})
+		}
+	}
+	if len(info.AudioTracks) > 0 {
+		chosen := defaultAudioIndex(audioStreams)
+		info.AudioTracks[chosen].Default = true
+		info.AudioCodec = info.AudioTracks[chosen].Codec
+	}
+	return info, nil
+}
+
+// defaultAudioIndex returns the audio-relative index of the source's default track, or 0.
+func defaultAudioIndex(streams []audioStream) int {
+	for i, stream := range streams {
+		if stream.Disposition.Default == 1 {
+			return i
+		}
+	}
+	return 0
+}
+
+// runWithProgress runs ffmpeg with `-progress pipe:1` and reports percentage of totalMs.
+func runWithProgress(ctx context.Context, args []string, totalMs int64, onProgress func(int)) error {
 	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -209,7 +662,7 @@ func (c *Converter) ConvertMP4WithProgress(ctx context.Context, inputPath, outpu
 	lastProgress := 0
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
+		if line == "" || totalMs <= 0 {
 			continue
 		}
 		parts := strings.SplitN(line, "=", 2)
@@ -237,95 +690,29 @@ func (c *Converter) ConvertMP4WithProgress(ctx context.Context, inputPath, outpu
 	}
 
 	if err := cmd.Wait(); err != nil {
-		_ = os.Remove(tmpPath)
-		return fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+		return runError(ctx, "ffmpeg", err, &stderr)
 	}
 
 	if onProgress != nil {
 		onProgress(100)
 	}
-
-	_ = os.Remove(outputPath)
-	return os.Rename(tmpPath, outputPath)
-}
-
-// StreamMP4 writes fragmented MP4 stream to out.
-func (c *Converter) StreamMP4(ctx context.Context, inputPath string, out io.Writer, follow bool, idleTimeout time.Duration) error {
-	codec, _ := probeVideoCodec(ctx, inputPath)
-	transcodeVideo := codec == "" || codec != "h264"
-
-	args := []string{"-fflags", "+genpts", "-sn", "-map", "0:v:0?", "-map", "0:a:0?"}
-	if follow {
-		args = append([]string{"-i", "pipe:0"}, args...)
-	} else {
-		args = append([]string{"-i", inputPath}, args...)
-	}
-
-	if transcodeVideo {
-		args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-crf", "20", "-pix_fmt", "yuv420p")
-	} else {
-		args = append(args, "-c:v", "copy")
-	}
-
-	args = append(args,
-		"-c:a", "aac",
-		"-ac", "2",
-		"-b:a", "192k",
-		"-ar", "48000",
-		"-movflags", "frag_keyframe+empty_moov+default_base_moof",
-		"-f", "mp4",
-		"pipe:1",
-	)
-
-	if follow {
-		reader, err := newGrowReader(ctx, inputPath, 500*time.Millisecond, idleTimeout)
-		if err != nil {
-			return err
-		}
-		defer reader.Close()
-		return runWithInputOutput(ctx, reader, out, "ffmpeg", args...)
-	}
-
-	return runWithOutput(ctx, out, "ffmpeg", args...)
-}
-
-func probeVideoCodec(ctx context.Context, inputPath string) (string, error) {
-	args := []string{
-		"-v", "error",
-		"-select_streams", "v:0",
-		"-show_entries", "stream=codec_name",
-		"-of", "default=nokey=1:noprint_wrappers=1",
-		inputPath,
-	}
-	cmd := exec.CommandContext(ctx, "ffprobe", args...)
-	out, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-	return strings.TrimSpace(string(out)), nil
+	return nil
 }
 
-func probeDuration(ctx context.Context, inputPath string) (float64, error) {
-	args := []string{
-		"-v", "error",
-		"-show_entries", "format=duration",
-		"-of", "default=nokey=1:noprint_wrappers=1",
-		inputPath,
+// runError wraps the error of a finished tool run. Runs ended by ctx wrap
+// media.ErrConversionStopped so callers can tell them from genuine failures;
+// stderr is only attached to the latter. A process killed by a signal nobody
+// asked for, such as the kernel's OOM killer, is a failure.
+func runError(ctx context.Context, name string, err error, stderr *bytes.Buffer) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%s %w: %w", name, media.ErrConversionStopped, ctxErr)
 	}
-	cmd := exec.CommandContext(ctx, "ffprobe", args...)
-	out, err := cmd.Output()
-	if err != nil {
-		return 0, err
-	}
-	value := strings.TrimSpace(string(out))
-	if value == "" {
-		return 0, fmt.Errorf("duration missing")
-	}
-	parsed, err := strconv.ParseFloat(value, 64)
-	if err != nil {
-		return 0, err
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == -1 {
+		// ExitCode reports -1 for processes terminated by a signal.
+		return fmt.Errorf("%s was killed (%w); the host may have run out of memory", name, err)
 	}
-	return parsed, nil
+	return fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(stderr.String()))
 }
 
 func run(ctx context.Context, name string, args ...string) error {
@@ -334,7 +721,7 @@ func run(ctx context.Context, name string, args ...string) error {
 	cmd.Stderr = &stderr
 	cmd.Stdout = &stderr
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+		return runError(ctx, name, err, &stderr)
 	}
 	return nil
 }
@@ -346,7 +733,7 @@ func runWithInput(ctx context.Context, input io.Reader, name string, args ...str
 	cmd.Stdout = &stderr
 	cmd.Stdin = input
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+		return runError(ctx, name, err, &stderr)
 	}
 	return nil
 }
@@ -357,7 +744,7 @@ func runWithOutput(ctx context.Context, out io.Writer, name string, args ...stri
 	cmd.Stderr = &stderr
 	cmd.Stdout = out
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+		return runError(ctx, name, err, &stderr)
 	}
 	return nil
 }
@@ -369,7 +756,7 @@ func runWithInputOutput(ctx context.Context, input io.Reader, out io.Writer, nam
 	cmd.Stdout = out
 	cmd.Stdin = input
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+		return runError(ctx, name, err, &stderr)
 	}
 	return nil
 }
@@ -443,3 +830,51 @@ func (g *growReader) Close() error {
 	g.closed = true
 	return g.file.Close()
 }
+
+// CheckTools reports whether ffmpeg and ffprobe are on PATH. A successful lookup
+// is cached for the life of the process.
+func (c *Converter) CheckTools() error {
+	c.toolsMu.Lock()
+	defer c.toolsMu.Unlock()
+	if c.toolsFound {
+		return nil
+	}
+	for _, tool := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return fmt.Errorf("%s not found: %w", tool, err)
+		}
+	}
+	c.toolsFound = true
+	return nil
+}
+
+// Version runs `ffmpeg -version` and `ffprobe -version` and returns the
+// versions they report.
+func (c *Converter) Version(ctx context.Context) (ffmpegVer, ffprobeVer string, err error) {
+	if ffmpegVer, err = toolVersion(ctx, "ffmpeg"); err != nil {
+		return "", "", err
+	}
+	if ffprobeVer, err = toolVersion(ctx, "ffprobe"); err != nil {
+		return "", "", err
+	}
+	return ffmpegVer, ffprobeVer, nil
+}
+
+func toolVersion(ctx context.Context, tool string) (string, error) {
+	out, err := exec.CommandContext(ctx, tool, "-version").Output()
+	if err != nil {
+		return "", fmt.Errorf("%s -version failed: %w", tool, err)
+	}
+	return parseVersion(tool, out)
+}
+
+// parseVersion extracts the version from the "<tool> version <version> ..."
+// banner line.
+func parseVersion(tool string, out []byte) (string, error) {
+	line, _, _ := strings.Cut(string(out), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 3 || fields[0] != tool || fields[1] != "version" {
+		return "", fmt.Errorf("unexpected %s -version output: %q", tool, line)
+	}
+	return fields[2], nil
+}
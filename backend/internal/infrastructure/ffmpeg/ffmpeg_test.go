@@ -0,0 +1,557 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+var x264 = newVideoEncoder(EncoderSoftware, "", DefaultEncoding)
+
+func indexOf(args []string, value string) int {
+	for i, arg := range args {
+		if arg == value {
+			return i
+		}
+	}
+	return -1
+}
+
+func assertAudioDefault(t *testing.T, args []string, audioIndex string) {
+	t.Helper()
+	joined := strings.Join(args, " ")
+	if !strings.Contains(joined, "-map 0:a:"+audioIndex+"?") {
+		t.Fatalf("expected audio track %s to be mapped, got %q", audioIndex, joined)
+	}
+	if !strings.Contains(joined, "-disposition:a:0 default") {
+		t.Fatalf("expected default disposition on first output audio, got %q", joined)
+	}
+	videoMap := indexOf(args, "0:v:0?")
+	audioMap := indexOf(args, "0:a:"+audioIndex+"?")
+	if videoMap < 0 || audioMap < videoMap {
+		t.Fatalf("expected chosen audio mapped right after video, got %q", joined)
+	}
+	if strings.Count(joined, "0:a:") != 1 {
+		t.Fatalf("expected exactly one audio mapping, got %q", joined)
+	}
+}
+
+func TestDefaultAudioIndex_PrefersFlaggedTrack(t *testing.T) {
+	streams := make([]audioStream, 3)
+	streams[2].Disposition.Default = 1
+	if got := defaultAudioIndex(streams); got != 2 {
+		t.Fatalf("expected default track 2, got %d", got)
+	}
+	if got := defaultAudioIndex(nil); got != 0 {
+		t.Fatalf("expected first track without probe data, got %d", got)
+	}
+}
+
+func TestArgs_MapChosenAudioFirstAsDefault(t *testing.T) {
+	c := NewConverter("v", "v", 6, EncoderSoftware, DefaultEncoding)
+
+	assertAudioDefault(t, c.hlsArgs(x264, "in.mkv", "out", "index.m3u8", 2, media.HLSFormatTS, nil), "2")
+	assertAudioDefault(t, mp4Args(x264, "in.mkv", "out.tmp.mp4", true, audioArgs(2, 192), true), "2")
+	assertAudioDefault(t, streamMP4Args(x264, "pipe:0", false, audioArgs(1, 192)), "1")
+}
+
+func TestArgs_UseConfiguredEncoding(t *testing.T) {
+	encoding := Encoding{Preset: "slow", CRF: 16, AudioKbps: 256}
+	c := NewConverter("v", "v", 6, EncoderSoftware, encoding)
+	enc, _ := c.currentEncoder()
+
+	hls := c.hlsArgs(enc, "in.mkv", "out", "index.m3u8", 0, media.HLSFormatTS, nil)
+	if i := indexOf(hls, "-preset"); i < 0 || hls[i+1] != "slow" {
+		t.Fatalf("expected configured preset in %v", hls)
+	}
+	if i := indexOf(hls, "-crf"); i < 0 || hls[i+1] != "16" {
+		t.Fatalf("expected configured CRF in %v", hls)
+	}
+	if i := indexOf(hls, "-b:a"); i < 0 || hls[i+1] != "256k" {
+		t.Fatalf("expected configured audio bitrate in %v", hls)
+	}
+	mp4 := ingestArgs(enc, "https://example.com/a.mkv", "out.mp4", true, c.encoding.AudioKbps, 0, 0)
+	if i := indexOf(mp4, "-b:a"); i < 0 || mp4[i+1] != "256k" {
+		t.Fatalf("expected configured audio bitrate in %v", mp4)
+	}
+}
+
+func TestMarkerVersions_FollowEncodingParameters(t *testing.T) {
+	defaults := NewConverter("v4", "v4", 20, EncoderSoftware, DefaultEncoding)
+	if defaults.HLSMarkerVersion() != "v4" || defaults.MP4MarkerVersion() != "v4" {
+		t.Fatalf("expected the defaults to keep the bare version, got %q and %q", defaults.HLSMarkerVersion(), defaults.MP4MarkerVersion())
+	}
+
+	base := NewConverter("v4", "v4", 6, EncoderSoftware, DefaultEncoding)
+	same := NewConverter("v4", "v4", 6, EncoderSoftware, DefaultEncoding)
+	if base.HLSMarkerVersion() != same.HLSMarkerVersion() || base.MP4MarkerVersion() != same.MP4MarkerVersion() {
+		t.Fatalf("expected the same settings to give the same markers")
+	}
+	if !strings.HasPrefix(base.HLSMarkerVersion(), "v4-") {
+		t.Fatalf("expected the base version to prefix the marker, got %q", base.HLSMarkerVersion())
+	}
+	if base.MP4MarkerVersion() != "v4" {
+		t.Fatalf("expected the segment length to leave the default MP4 marker alone, got %q", base.MP4MarkerVersion())
+	}
+
+	crf := DefaultEncoding
+	crf.CRF = 23
+	for name, c := range map[string]*Converter{
+		"crf":     NewConverter("v4", "v4", 6, EncoderSoftware, crf),
+		"encoder": NewConverter("v4", "v4", 6, EncoderNVENC, DefaultEncoding),
+		"version": NewConverter("v5", "v5", 6, EncoderSoftware, DefaultEncoding),
+	} {
+		if c.HLSMarkerVersion() == base.HLSMarkerVersion() || c.MP4MarkerVersion() == base.MP4MarkerVersion() {
+			t.Fatalf("%s: expected both markers to change", name)
+		}
+	}
+
+	segments := NewConverter("v4", "v4", 10, EncoderSoftware, DefaultEncoding)
+	if segments.HLSMarkerVersion() == base.HLSMarkerVersion() {
+		t.Fatalf("expected a new segment length to change the HLS marker")
+	}
+	if segments.MP4MarkerVersion() != base.MP4MarkerVersion() {
+		t.Fatalf("expected the segment length to leave the MP4 marker alone")
+	}
+
+	layout := NewConverter("v4", "v4", 20, EncoderSoftware, DefaultEncoding)
+	layout.SetHLSLayout(nil, false)
+	if layout.HLSMarkerVersion() != "v4" {
+		t.Fatalf("expected the default layout to keep the bare version, got %q", layout.HLSMarkerVersion())
+	}
+	seen := map[string]bool{"v4": true}
+	for _, set := range []func(){
+		func() {
+			layout.SetHLSLayout([]media.Rendition{{Height: 720, VideoBitrate: 2800, AudioBitrate: 128}}, false)
+		},
+		func() {
+			layout.SetHLSLayout([]media.Rendition{{Height: 720, VideoBitrate: 2000, AudioBitrate: 128}}, false)
+		},
+		func() { layout.SetHLSLayout(nil, true) },
+	} {
+		set()
+		if seen[layout.HLSMarkerVersion()] || layout.MP4MarkerVersion() != "v4" {
+			t.Fatalf("expected the layout to change only the HLS marker, got %q / %q", layout.HLSMarkerVersion(), layout.MP4MarkerVersion())
+		}
+		seen[layout.HLSMarkerVersion()] = true
+	}
+}
+
+func TestEncodingValidate_RejectsBadSettings(t *testing.T) {
+	if err := DefaultEncoding.Validate(); err != nil {
+		t.Fatalf("expected defaults to be valid, got %v", err)
+	}
+	for _, encoding := range []Encoding{
+		{Preset: "turbo", CRF: 20, AudioKbps: 192},
+		{Preset: "fast", CRF: 52, AudioKbps: 192},
+		{Preset: "fast", CRF: -1, AudioKbps: 192},
+		{Preset: "fast", CRF: 20, AudioKbps: 0},
+	} {
+		if err := encoding.Validate(); err == nil {
+			t.Fatalf("expected %+v to be rejected", encoding)
+		}
+	}
+}
+
+func TestChooseAudioArgs_CopiesPlayableAAC(t *testing.T) {
+	info := media.MediaInfo{AudioTracks: []media.AudioTrack{
+		{Index: 0, Codec: "aac", Channels: 2, SampleRate: 48000},
+		{Index: 1, Codec: "aac", Channels: 6, SampleRate: 48000},
+		{Index: 2, Codec: "ac3", Channels: 2, SampleRate: 48000},
+		{Index: 3, Codec: "aac", Channels: 2, SampleRate: 22050},
+		{Index: 4, Codec: "aac", Channels: 1, SampleRate: 44100},
+	}}
+
+	for index, wantCopy := range map[int]bool{0: true, 1: false, 2: false, 3: false, 4: true, 5: false} {
+		args := chooseAudioArgs(info, index, 192)
+		copied := strings.Contains(strings.Join(args, " "), "-c:a copy")
+		if copied != wantCopy {
+			t.Fatalf("track %d: expected copy=%v, got %q", index, wantCopy, args)
+		}
+		if !copied && indexOf(args, "-ac") < 0 {
+			t.Fatalf("track %d: expected stereo AAC re-encode, got %q", index, args)
+		}
+		if !strings.Contains(strings.Join(args, " "), "-map 0:a:"+strconv.Itoa(index)+"?") {
+			t.Fatalf("track %d: expected the track mapped, got %q", index, args)
+		}
+	}
+	if args := chooseAudioArgs(info, noAudio, 192); indexOf(args, "-an") < 0 {
+		t.Fatalf("expected audio disabled, got %q", args)
+	}
+
+	if got := attemptAudioArgs(chooseAudioArgs(info, 0, 192), 0, 192, false, true); indexOf(got, "aac") < 0 {
+		t.Fatalf("expected the retry after a failed copy to re-encode audio, got %q", got)
+	}
+}
+
+func TestArgs_OmitAudioForVideoOnlySource(t *testing.T) {
+	c := NewConverter("v", "v", 6, EncoderSoftware, DefaultEncoding)
+
+	for name, args := range map[string][]string{
+		"hls":    c.hlsArgs(x264, "in.mkv", "out", "index.m3u8", noAudio, media.HLSFormatTS, nil),
+		"mp4":    mp4Args(x264, "in.mkv", "out.tmp.mp4", true, audioArgs(noAudio, 192), true),
+		"stream": streamMP4Args(x264, "pipe:0", false, audioArgs(noAudio, 192)),
+	} {
+		joined := strings.Join(args, " ")
+		if strings.Contains(joined, "-c:a") || strings.Contains(joined, "0:a:") || strings.Contains(joined, "-b:a") {
+			t.Fatalf("%s: expected no audio options, got %q", name, joined)
+		}
+		if indexOf(args, "-an") < 0 {
+			t.Fatalf("%s: expected audio disabled, got %q", name, joined)
+		}
+	}
+}
+
+func TestHLSArgs_SegmentFormat(t *testing.T) {
+	c := NewConverter("v", "v", 6, EncoderSoftware, DefaultEncoding)
+
+	ts := c.hlsArgs(x264, "in.mkv", "out", "out/index.m3u8", 0, media.HLSFormatTS, nil)
+	if indexOf(ts, "-hls_segment_type") >= 0 || !strings.HasSuffix(ts[indexOf(ts, "-hls_segment_filename")+1], ".ts") {
+		t.Fatalf("expected default TS segments, got %v", ts)
+	}
+
+	fmp4 := c.hlsArgs(x264, "in.mkv", "out", "out/index.m3u8", 0, media.HLSFormatFMP4, nil)
+	if i := indexOf(fmp4, "-hls_segment_type"); i < 0 || fmp4[i+1] != "fmp4" {
+		t.Fatalf("expected fmp4 segment type, got %v", fmp4)
+	}
+	if i := indexOf(fmp4, "-hls_fmp4_init_filename"); i < 0 || fmp4[i+1] != FMP4InitFile {
+		t.Fatalf("expected init segment name, got %v", fmp4)
+	}
+	if !strings.HasSuffix(fmp4[indexOf(fmp4, "-hls_segment_filename")+1], ".m4s") {
+		t.Fatalf("expected .m4s segments, got %v", fmp4)
+	}
+	if fmp4[len(fmp4)-1] != "out/index.m3u8" {
+		t.Fatalf("expected playlist as the output, got %v", fmp4)
+	}
+}
+
+func TestHLSArgs_ResumeContinuesPlaylist(t *testing.T) {
+	c := NewConverter("v", "v", 6, EncoderSoftware, DefaultEncoding)
+	args := c.hlsArgs(x264, "in.mkv", "out", "out/index.m3u8", 0, media.HLSFormatTS, &media.HLSResumePoint{Segments: 3, OffsetSeconds: 18})
+
+	if seek, input := indexOf(args, "-ss"), indexOf(args, "-i"); seek < 0 || seek > input || args[seek+1] != "18.000" {
+		t.Fatalf("expected input seek to the resume offset, got %v", args)
+	}
+	if i := indexOf(args, "-output_ts_offset"); i < 0 || args[i+1] != "18.000" {
+		t.Fatalf("expected timestamps shifted to the resume offset, got %v", args)
+	}
+	if i := indexOf(args, "-start_number"); i < 0 || args[i+1] != "3" {
+		t.Fatalf("expected segment numbering to continue, got %v", args)
+	}
+	if i := indexOf(args, "-hls_flags"); i < 0 || !strings.Contains(args[i+1], "append_list") {
+		t.Fatalf("expected append_list flag, got %v", args)
+	}
+	if args[len(args)-1] != "out/index.m3u8" {
+		t.Fatalf("expected playlist as the output, got %v", args)
+	}
+}
+
+func TestIngestArgs_RestrictProtocolsAndCapOutput(t *testing.T) {
+	args := ingestArgs(x264, "/videos/clip.mp4.ingest-src", "out.mp4.ingest", true, 192, 1<<30, 2*time.Hour)
+
+	whitelist := indexOf(args, "-protocol_whitelist")
+	input := indexOf(args, "-i")
+	if whitelist < 0 || whitelist > input || args[whitelist+1] != localProtocols {
+		t.Fatalf("expected protocol whitelist before input, got %q", args)
+	}
+	if got := args[indexOf(args, "-t")+1]; got != "7200" {
+		t.Fatalf("expected duration cap 7200, got %q", got)
+	}
+	if got := args[indexOf(args, "-fs")+1]; got != "1073741824" {
+		t.Fatalf("expected size cap, got %q", got)
+	}
+	if args[len(args)-1] != "out.mp4.ingest" {
+		t.Fatalf("expected output path last, got %q", args)
+	}
+	if got := inputOptions("https://media.example.com/clip.mkv"); len(got) != 2 || got[1] != remoteProtocols {
+		t.Fatalf("expected network protocols for URLs, got %q", got)
+	}
+}
+
+func TestMP4WithCopyFallback_RetriesWithTranscode(t *testing.T) {
+	var modes []bool
+	var progress []int
+	err := mp4WithCopyFallback(context.Background(), false, func(p int) { progress = append(progress, p) }, func(transcode bool, report func(int)) error {
+		modes = append(modes, transcode)
+		if !transcode {
+			report(40)
+			return errors.New("copy failed")
+		}
+		report(50)
+		report(100)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected transcode retry to succeed, got %v", err)
+	}
+	if len(modes) != 2 || modes[0] || !modes[1] {
+		t.Fatalf("expected copy then transcode attempts, got %v", modes)
+	}
+	if want := []int{40, 70, 100}; len(progress) != len(want) || progress[0] != want[0] || progress[1] != want[1] || progress[2] != want[2] {
+		t.Fatalf("expected monotonic progress %v, got %v", want, progress)
+	}
+
+	modes = nil
+	_ = mp4WithCopyFallback(context.Background(), true, nil, func(transcode bool, _ func(int)) error {
+		modes = append(modes, transcode)
+		return errors.New("transcode failed")
+	})
+	if len(modes) != 1 {
+		t.Fatalf("expected no retry for a transcode run, got %v", modes)
+	}
+}
+
+func TestParseProbe_MapsStreamsAndFormat(t *testing.T) {
+	raw := []byte(`{
+		"streams": [
+			{"codec_type": "video", "codec_name": "mjpeg", "width": 600, "height": 600, "disposition": {"attached_pic": 1}},
+			{"codec_type": "video", "codec_name": "hevc", "width": 1920, "height": 1080},
+			{"codec_type": "audio", "codec_name": "ac3", "channels": 6, "tags": {"language": "eng"}},
+			{"codec_type": "audio", "codec_name": "aac", "channels": 2, "disposition": {"default": 1}, "tags": {"language": "jpn", "title": "Original"}},
+			{"codec_type": "subtitle", "codec_name": "subrip"},
+			{"codec_type": "subtitle", "codec_name": "hdmv_pgs_subtitle", "disposition": {"forced": 1}, "tags": {"language": "ger"}}
+		],
+		"format": {"duration": "5400.250000", "bit_rate": "8000000"}
+	}`)
+
+	info, err := parseProbe(raw)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if info.VideoCodec != "hevc" || info.Width != 1920 || info.Height != 1080 {
+		t.Fatalf("expected the real video stream, got %+v", info)
+	}
+	if info.Duration != 5400.25 || info.Bitrate != 8000000 {
+		t.Fatalf("unexpected format values: %+v", info)
+	}
+	if len(info.AudioTracks) != 2 || info.AudioTracks[1].Index != 1 || !info.AudioTracks[1].Default || info.AudioTracks[0].Default {
+		t.Fatalf("unexpected audio tracks: %+v", info.AudioTracks)
+	}
+	if info.AudioCodec != "aac" || info.AudioTracks[0].Language != "eng" || info.AudioTracks[0].Channels != 6 {
+		t.Fatalf("unexpected audio details: %+v", info)
+	}
+	if len(info.SubtitleTracks) != 2 || !info.SubtitleTracks[0].Text || info.SubtitleTracks[1].Text {
+		t.Fatalf("unexpected subtitle tracks: %+v", info.SubtitleTracks)
+	}
+	if sub := info.SubtitleTracks[1]; sub.Index != 1 || !sub.Forced || sub.Language != "ger" {
+		t.Fatalf("unexpected subtitle details: %+v", sub)
+	}
+}
+
+func TestSubtitleMP4Args_MuxOrBurn(t *testing.T) {
+	text := media.SubtitleTrack{Index: 1, Codec: "subrip", Text: true}
+	image := media.SubtitleTrack{Index: 0, Codec: "hdmv_pgs_subtitle"}
+
+	soft := strings.Join(subtitleMP4Args(x264, "in.mkv", "out.tmp.mp4", false, audioArgs(0, 192), text, false), " ")
+	if !strings.Contains(soft, "-map 0:s:1 -c:s mov_text") || !strings.Contains(soft, "-c:v copy") || strings.Contains(soft, "-sn") || !strings.Contains(soft, "-progress pipe:1") {
+		t.Fatalf("expected soft mov_text track, got %q", soft)
+	}
+
+	burned := subtitleMP4Args(x264, "/videos/a:b.mkv", "out.tmp.mp4", true, audioArgs(0, 192), text, true)
+	if i := indexOf(burned, "-vf"); i < 0 || burned[i+1] != `subtitles=/videos/a\\:b.mkv:si=1` {
+		t.Fatalf("expected subtitles filter on the escaped input, got %q", burned)
+	}
+	if strings.Contains(strings.Join(burned, " "), "mov_text") {
+		t.Fatalf("expected no soft track when burning, got %q", burned)
+	}
+
+	overlay := subtitleMP4Args(x264, "in.mkv", "out.tmp.mp4", true, audioArgs(0, 192), image, true)
+	if i := indexOf(overlay, "-filter_complex"); i < 0 || overlay[i+1] != "[0:v:0][0:s:0]overlay[v]" || indexOf(overlay, "[v]") < 0 {
+		t.Fatalf("expected overlay for image subtitles, got %q", overlay)
+	}
+}
+
+func TestEscapeFilterPath(t *testing.T) {
+	if got := escapeFilterPath(`/v/it's [x], y.mkv`); got != `/v/it\\\'s \[x\]\, y.mkv` {
+		t.Fatalf("unexpected escaping: %s", got)
+	}
+}
+
+func TestVideoEncoder_HardwareArgs(t *testing.T) {
+	c := NewConverter("v", "v", 6, EncoderVAAPI, DefaultEncoding)
+	vaapi := newVideoEncoder(EncoderVAAPI, DefaultVAAPIDevice, DefaultEncoding)
+
+	hls := c.hlsArgs(vaapi, "in.mkv", "out", "out/index.m3u8", 0, media.HLSFormatTS, nil)
+	if i := indexOf(hls, "-vaapi_device"); i < 0 || i > indexOf(hls, "-i") || hls[i+1] != DefaultVAAPIDevice {
+		t.Fatalf("expected VAAPI device before input, got %v", hls)
+	}
+	if i := indexOf(hls, "-c:v"); i < 0 || hls[i+1] != "h264_vaapi" || indexOf(hls, "libx264") >= 0 {
+		t.Fatalf("expected h264_vaapi codec, got %v", hls)
+	}
+	if i := indexOf(hls, "-vf"); i < 0 || hls[i+1] != "format=nv12,hwupload" {
+		t.Fatalf("expected hwupload filter, got %v", hls)
+	}
+
+	burned := subtitleMP4Args(vaapi, "in.mkv", "out.tmp.mp4", true, audioArgs(0, 192), media.SubtitleTrack{Index: 1, Text: true}, true)
+	if i := indexOf(burned, "-vf"); i < 0 || burned[i+1] != "subtitles=in.mkv:si=1,format=nv12,hwupload" {
+		t.Fatalf("expected upload after subtitles filter, got %v", burned)
+	}
+	if indexOf(burned, "-pix_fmt") >= 0 {
+		t.Fatalf("expected no software pixel format for hardware upload, got %v", burned)
+	}
+
+	copied := mp4Args(vaapi, "in.mkv", "out.tmp.mp4", false, audioArgs(0, 192), false)
+	if indexOf(copied, "-vaapi_device") >= 0 || indexOf(copied, "copy") < 0 {
+		t.Fatalf("expected stream copy without hardware options, got %v", copied)
+	}
+
+	if got := NewConverter("v", "v", 6, "h265_magic", DefaultEncoding).Encoder(); got != EncoderSoftware {
+		t.Fatalf("expected unknown encoder to select libx264, got %s", got)
+	}
+}
+
+func TestWithEncoder_FallsBackOnceAndRemembers(t *testing.T) {
+	c := NewConverter("v", "v", 6, EncoderNVENC, DefaultEncoding)
+	ctx := context.Background()
+
+	var used []string
+	err := c.withEncoder(ctx, func(enc videoEncoder) error {
+		used = append(used, enc.name)
+		if enc.name == EncoderNVENC {
+			return errors.New("ffmpeg failed: exit status 1: [h264_nvenc] No NVENC capable devices found")
+		}
+		return nil
+	})
+	if err != nil || len(used) != 2 || used[1] != EncoderSoftware {
+		t.Fatalf("expected libx264 retry after encoder error, got %v, %v", used, err)
+	}
+
+	used = nil
+	_ = c.withEncoder(ctx, func(enc videoEncoder) error {
+		used = append(used, enc.name)
+		return nil
+	})
+	if len(used) != 1 || used[0] != EncoderSoftware || c.Encoder() != EncoderSoftware {
+		t.Fatalf("expected later jobs to skip the failed encoder, got %v", used)
+	}
+
+	c = NewConverter("v", "v", 6, EncoderNVENC, DefaultEncoding)
+	used = nil
+	err = c.withEncoder(ctx, func(enc videoEncoder) error {
+		used = append(used, enc.name)
+		return errors.New("ffmpeg failed: exit status 1: in.mkv: Invalid data found when processing input")
+	})
+	if err == nil || len(used) != 1 || c.Encoder() != EncoderNVENC {
+		t.Fatalf("expected source errors not to trigger fallback, got %v, %v", used, err)
+	}
+	if enc := c.settledEncoder(); enc.name != EncoderSoftware {
+		t.Fatalf("expected unverified hardware encoder not to serve streams, got %s", enc.name)
+	}
+
+	_ = c.withEncoder(ctx, func(videoEncoder) error { return nil })
+	if enc := c.settledEncoder(); enc.name != EncoderNVENC {
+		t.Fatalf("expected verified hardware encoder to serve streams, got %s", enc.name)
+	}
+}
+
+func TestLadder_MasterPlaylistReferencesEveryVariant(t *testing.T) {
+	c := NewConverter("v", "v", 6, EncoderSoftware, DefaultEncoding)
+	renditions := fitRenditions(media.DefaultRenditions, 720)
+	if len(renditions) != 3 || renditions[0].Height != 720 {
+		t.Fatalf("expected renditions above the source dropped, got %+v", renditions)
+	}
+
+	dir := t.TempDir()
+	master := filepath.Join(dir, "index.m3u8")
+	if err := writeMasterPlaylist(master, renditions, 1280, 720); err != nil {
+		t.Fatalf("write master: %v", err)
+	}
+	raw, err := os.ReadFile(master)
+	if err != nil {
+		t.Fatalf("read master: %v", err)
+	}
+	playlist := string(raw)
+	if strings.Count(playlist, "#EXT-X-STREAM-INF:") != len(renditions) {
+		t.Fatalf("expected one stream entry per rendition, got %q", playlist)
+	}
+	for _, rendition := range renditions {
+		if !strings.Contains(playlist, "\n"+rendition.Name()+"/"+VariantPlaylist+"\n") {
+			t.Fatalf("expected master to reference %s, got %q", rendition.Name(), playlist)
+		}
+	}
+	if !strings.Contains(playlist, "BANDWIDTH=3124000,AVERAGE-BANDWIDTH=2928000,RESOLUTION=1280x720") {
+		t.Fatalf("unexpected 720p stream attributes: %q", playlist)
+	}
+	if !strings.Contains(playlist, "RESOLUTION=640x360") {
+		t.Fatalf("expected width to follow the source aspect ratio: %q", playlist)
+	}
+
+	args := c.ladderArgs(x264, "in.mkv", "out", renditions, 1, media.HLSFormatTS)
+	if i := indexOf(args, "-filter_complex"); i < 0 || !strings.HasPrefix(args[i+1], "[0:v:0]split=3[s0][s1][s2];[s0]scale=-2:720[v0]") {
+		t.Fatalf("expected one scaled split per rendition, got %v", args)
+	}
+	for i, rendition := range renditions {
+		if indexOf(args, filepath.Join("out", rendition.Name(), VariantPlaylist)) < 0 || indexOf(args, fmt.Sprintf("[v%d]", i)) < 0 {
+			t.Fatalf("expected an output for %s, got %v", rendition.Name(), args)
+		}
+		if indexOf(args, fmt.Sprintf("%dk", rendition.VideoBitrate)) < 0 {
+			t.Fatalf("expected %s video bitrate, got %v", rendition.Name(), args)
+		}
+	}
+	if strings.Count(strings.Join(args, " "), "-map 0:a:1?") != len(renditions) {
+		t.Fatalf("expected the audio track mapped into every variant, got %v", args)
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	out := []byte("ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers\nbuilt with gcc 13\n")
+	if got, err := parseVersion("ffmpeg", out); err != nil || got != "6.1.1-3ubuntu5" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	if _, err := parseVersion("ffprobe", out); err == nil {
+		t.Fatalf("expected a banner of another tool to be rejected")
+	}
+}
+
+func TestRunError_StoppedRunsWrapSentinel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := run(ctx, "sleep", "5"); !errors.Is(err, media.ErrConversionStopped) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled run to be stopped, got %v", err)
+	}
+
+	err := run(context.Background(), "sh", "-c", "kill -KILL $$")
+	if err == nil || errors.Is(err, media.ErrConversionStopped) || !strings.Contains(err.Error(), "killed") {
+		t.Fatalf("expected a run killed from outside to fail, got %v", err)
+	}
+
+	err = run(context.Background(), "sh", "-c", "echo boom >&2; exit 1")
+	if err == nil || errors.Is(err, media.ErrConversionStopped) || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected failed run with stderr, got %v", err)
+	}
+}
+
+func TestFaststart_ChecksMoovBeforeMdat(t *testing.T) {
+	box := func(kind string, payload int) []byte {
+		b := make([]byte, 8+payload)
+		b[3] = byte(8 + payload)
+		copy(b[4:8], kind)
+		return b
+	}
+	join := func(boxes ...[]byte) *bytes.Reader {
+		var all []byte
+		for _, b := range boxes {
+			all = append(all, b...)
+		}
+		return bytes.NewReader(all)
+	}
+
+	if fast, err := faststart(join(box("ftyp", 16), box("moov", 32), box("mdat", 64))); err != nil || !fast {
+		t.Fatalf("expected faststart file, got %v %v", fast, err)
+	}
+	if fast, err := faststart(join(box("ftyp", 16), box("free", 4), box("mdat", 64), box("moov", 32))); err != nil || fast {
+		t.Fatalf("expected moov at the end, got %v %v", fast, err)
+	}
+	if _, err := faststart(join(box("ftyp", 16))); err == nil {
+		t.Fatalf("expected an error without moov or mdat")
+	}
+}
@@ -0,0 +1,122 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"evd/internal/domain/media"
+)
+
+// VariantPlaylist is the playlist name inside each rendition directory of an
+// adaptive HLS output.
+const VariantPlaylist = "index.m3u8"
+
+var errNoRenditions = errors.New("no HLS renditions")
+
+// ConvertHLSLadder converts media into adaptive HLS: one variant playlist per
+// rendition in its own subdirectory of outputDir (see media.Rendition.Name) and a
+// master playlist at playlistPath listing them. Renditions taller than the source
+// are dropped, keeping at least the smallest one. All variants come from a single
+// ffmpeg run, so the source is decoded once.
+func (c *Converter) ConvertHLSLadder(ctx context.Context, inputPath, outputDir, playlistPath string, renditions []media.Rendition, format media.HLSFormat, audioTrack int, onProgress func(int)) error {
+	if len(renditions) == 0 {
+		return errNoRenditions
+	}
+
+	// Probe failures only cost the resolution filter and progress reports.
+	info, _ := c.Probe(ctx, inputPath)
+	renditions = fitRenditions(renditions, info.Height)
+	for _, rendition := range renditions {
+		if err := os.MkdirAll(filepath.Join(outputDir, rendition.Name()), 0o755); err != nil {
+			return err
+		}
+	}
+	if err := writeMasterPlaylist(playlistPath, renditions, info.Width, info.Height); err != nil {
+		return err
+	}
+
+	audioIndex := c.sourceAudioIndex(ctx, inputPath, audioTrack)
+	totalMs := int64(info.Duration * 1000)
+	return c.withEncoder(ctx, func(enc videoEncoder) error {
+		args := append([]string{"-progress", "pipe:1", "-nostats"}, c.ladderArgs(enc, inputPath, outputDir, renditions, audioIndex, format)...)
+		return runWithProgress(ctx, args, totalMs, onProgress)
+	})
+}
+
+// fitRenditions drops renditions taller than sourceHeight, keeping the smallest
+// one when none fits. An unknown source height keeps every rendition.
+func fitRenditions(renditions []media.Rendition, sourceHeight int) []media.Rendition {
+	if sourceHeight <= 0 {
+		return renditions
+	}
+
+	out := make([]media.Rendition, 0, len(renditions))
+	smallest := renditions[0]
+	for _, rendition := range renditions {
+		if rendition.Height <= sourceHeight {
+			out = append(out, rendition)
+		}
+		if rendition.Height < smallest.Height {
+			smallest = rendition
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, smallest)
+	}
+	return out
+}
+
+// ladderArgs builds ffmpeg arguments that split the first video stream into one
+// scaled copy per rendition and write each as its own HLS output.
+func (c *Converter) ladderArgs(enc videoEncoder, input, outputDir string, renditions []media.Rendition, audioIndex int, format media.HLSFormat) []string {
+	args := append([]string{"-y"}, enc.input...)
+	args = append(args, "-i", input, "-filter_complex", ladderFilter(enc, renditions))
+	for i, rendition := range renditions {
+		dir := filepath.Join(outputDir, rendition.Name())
+		args = append(args, "-sn", "-map", fmt.Sprintf("[v%d]", i))
+		args = append(args, audioArgs(audioIndex, rendition.AudioBitrate)...)
+		args = append(args, enc.bitrateArgs(rendition.VideoBitrate)...)
+		args = append(args, c.hlsMuxerArgs("independent_segments+temp_file")...)
+		args = append(args, hlsSegmentArgs(dir, format)...)
+		args = append(args, filepath.Join(dir, VariantPlaylist))
+	}
+	return args
+}
+
+// ladderFilter returns the filter graph feeding output pads [v0], [v1], ...
+func ladderFilter(enc videoEncoder, renditions []media.Rendition) string {
+	var split strings.Builder
+	fmt.Fprintf(&split, "[0:v:0]split=%d", len(renditions))
+	chains := make([]string, 0, len(renditions)+1)
+	for i, rendition := range renditions {
+		fmt.Fprintf(&split, "[s%d]", i)
+		chains = append(chains, fmt.Sprintf("[s%d]%s[v%d]", i, enc.filterChain(fmt.Sprintf("scale=-2:%d", rendition.Height)), i))
+	}
+	return strings.Join(append([]string{split.String()}, chains...), ";")
+}
+
+// writeMasterPlaylist writes the master playlist of an adaptive output. BANDWIDTH
+// uses the capped peak rate; RESOLUTION is omitted when the source size is unknown.
+func writeMasterPlaylist(playlistPath string, renditions []media.Rendition, sourceWidth, sourceHeight int) error {
+	lines := []string{"#EXTM3U", "#EXT-X-INDEPENDENT-SEGMENTS"}
+	for _, rendition := range renditions {
+		peak := (rendition.VideoBitrate*107/100 + rendition.AudioBitrate) * 1000
+		average := (rendition.VideoBitrate + rendition.AudioBitrate) * 1000
+		inf := fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,AVERAGE-BANDWIDTH=%d", peak, average)
+		if sourceWidth > 0 && sourceHeight > 0 {
+			width := (sourceWidth*rendition.Height/sourceHeight + 1) &^ 1
+			inf += fmt.Sprintf(",RESOLUTION=%dx%d", width, rendition.Height)
+		}
+		lines = append(lines, inf, rendition.Name()+"/"+VariantPlaylist)
+	}
+
+	tmpPath := playlistPath + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, playlistPath)
+}
@@ -0,0 +1,138 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+const (
+	// probeCacheTTL bounds how long ffprobe results are reused; the conversions
+	// that share them start within moments of each other.
+	probeCacheTTL = 2 * time.Minute
+	// maxProbeCacheEntries bounds the cache; it is reset when full.
+	maxProbeCacheEntries = 256
+)
+
+// probeCache remembers ffprobe results per local file revision, so streaming
+// and converting a file probe it once.
+type probeCache struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	entries map[string]probeEntry
+}
+
+type probeEntry struct {
+	size       int64
+	modifiedAt time.Time
+	storedAt   time.Time
+	info       media.MediaInfo
+}
+
+func newProbeCache() *probeCache {
+	return &probeCache{now: time.Now, entries: make(map[string]probeEntry)}
+}
+
+func (c *probeCache) get(path string, size int64, modifiedAt time.Time) (media.MediaInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[path]
+	if !ok || entry.size != size || !entry.modifiedAt.Equal(modifiedAt) || c.now().Sub(entry.storedAt) >= probeCacheTTL {
+		return media.MediaInfo{}, false
+	}
+	return cloneMediaInfo(entry.info), true
+}
+
+func (c *probeCache) put(path string, size int64, modifiedAt time.Time, info media.MediaInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxProbeCacheEntries {
+		c.entries = make(map[string]probeEntry)
+	}
+	c.entries[path] = probeEntry{size: size, modifiedAt: modifiedAt, storedAt: c.now(), info: cloneMediaInfo(info)}
+}
+
+// probeInfo runs ffprobe on inputPath once per file revision. Results for local
+// files are cached until their size or modification time changes, which keeps
+// growing downloads current; URLs are always probed.
+func (c *Converter) probeInfo(ctx context.Context, inputPath string) (media.MediaInfo, error) {
+	stat, statErr := os.Stat(inputPath)
+	if statErr == nil {
+		if info, ok := c.probes.get(inputPath, stat.Size(), stat.ModTime()); ok {
+			return info, nil
+		}
+	}
+
+	args := append(inputOptions(inputPath),
+		"-v", "error",
+		"-show_streams",
+		"-show_format",
+		"-of", "json",
+		inputPath,
+	)
+	out, err := exec.CommandContext(ctx, "ffprobe", args...).Output()
+	if err != nil {
+		return media.MediaInfo{}, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	info, err := parseProbe(out)
+	if err != nil {
+		return media.MediaInfo{}, err
+	}
+	if statErr == nil {
+		c.probes.put(inputPath, stat.Size(), stat.ModTime(), info)
+	}
+	return info, nil
+}
+
+// sourceAudioIndex picks the audio track to convert: an explicit track as is, else
+// the source's default track, or noAudio when ffprobe reports no audio streams.
+// Probe failures keep the first track, whose optional mapping is harmless if it
+// turns out to be missing.
+func (c *Converter) sourceAudioIndex(ctx context.Context, inputPath string, track int) int {
+	if track >= 0 {
+		return track
+	}
+	info, err := c.probeInfo(ctx, inputPath)
+	if err != nil {
+		return 0
+	}
+	if len(info.AudioTracks) == 0 {
+		return noAudio
+	}
+	for _, audio := range info.AudioTracks {
+		if audio.Default {
+			return audio.Index
+		}
+	}
+	return 0
+}
+
+// transcodeVideo reports whether the source's video must be re-encoded for MP4
+// output; only h264 is copied. Probe failures transcode to be safe.
+func (c *Converter) transcodeVideo(ctx context.Context, inputPath string) bool {
+	info, err := c.probeInfo(ctx, inputPath)
+	return err != nil || info.VideoCodec != "h264"
+}
+
+// probeDuration returns the media duration in seconds.
+func (c *Converter) probeDuration(ctx context.Context, inputPath string) (float64, error) {
+	info, err := c.probeInfo(ctx, inputPath)
+	if err != nil {
+		return 0, err
+	}
+	if info.Duration <= 0 {
+		return 0, fmt.Errorf("duration missing")
+	}
+	return info.Duration, nil
+}
+
+func cloneMediaInfo(info media.MediaInfo) media.MediaInfo {
+	info.AudioTracks = append([]media.AudioTrack{}, info.AudioTracks...)
+	info.SubtitleTracks = append([]media.SubtitleTrack{}, info.SubtitleTracks...)
+	return info
+}
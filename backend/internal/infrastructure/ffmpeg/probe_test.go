@@ -0,0 +1,51 @@
+package ffmpeg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+func TestProbeInfo_CachedPerFileRevision(t *testing.T) {
+	c := NewConverter("v1", "v1", 6, "libx264", DefaultEncoding)
+	now := time.Now()
+	c.probes.now = func() time.Time { return now }
+
+	input := filepath.Join(t.TempDir(), "movie.mkv")
+	if err := os.WriteFile(input, []byte("data"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	stat, _ := os.Stat(input)
+	c.probes.put(input, stat.Size(), stat.ModTime(), media.MediaInfo{
+		VideoCodec:  "h264",
+		AudioTracks: []media.AudioTrack{{Index: 0}, {Index: 1, Default: true}},
+	})
+
+	if c.transcodeVideo(context.Background(), input) {
+		t.Fatalf("expected cached h264 source to be copied")
+	}
+	if got := c.sourceAudioIndex(context.Background(), input, media.DefaultAudioTrack); got != 1 {
+		t.Fatalf("expected cached default audio track 1, got %d", got)
+	}
+
+	// A growing download changes size and modification time.
+	if err := os.WriteFile(input, []byte("more data"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	grown, _ := os.Stat(input)
+	if _, ok := c.probes.get(input, grown.Size(), grown.ModTime()); ok {
+		t.Fatalf("expected a changed file to miss the cache")
+	}
+	if _, ok := c.probes.get(input, stat.Size(), stat.ModTime()); !ok {
+		t.Fatalf("expected the old revision to still be cached")
+	}
+
+	now = now.Add(probeCacheTTL)
+	if _, ok := c.probes.get(input, stat.Size(), stat.ModTime()); ok {
+		t.Fatalf("expected entries to expire after the TTL")
+	}
+}
@@ -0,0 +1,120 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"evd/internal/domain/media"
+)
+
+// textSubtitleCodecs are subtitle formats that convert to mov_text and render
+// through the subtitles filter. Anything else is image-based.
+var textSubtitleCodecs = map[string]bool{
+	"subrip":   true,
+	"srt":      true,
+	"ass":      true,
+	"ssa":      true,
+	"webvtt":   true,
+	"mov_text": true,
+	"text":     true,
+}
+
+// ConvertMP4WithSubtitles converts media into MP4 with the subIndex-th subtitle
+// stream muxed as a soft mov_text track or, with burn, rendered into the video.
+// Burning always re-encodes the video. Sources without any subtitle stream are
+// converted without subtitles. Only MP4 output carries subtitles; HLS drops them.
+func (c *Converter) ConvertMP4WithSubtitles(ctx context.Context, inputPath, outputPath string, audioTrack, subIndex int, burn bool, onProgress func(int)) error {
+	info, err := c.Probe(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	if len(info.SubtitleTracks) == 0 {
+		return c.ConvertMP4WithProgress(ctx, inputPath, outputPath, audioTrack, onProgress)
+	}
+	if subIndex < 0 || subIndex >= len(info.SubtitleTracks) {
+		return fmt.Errorf("%w: %d", media.ErrSubtitleTrackNotFound, subIndex)
+	}
+	track := info.SubtitleTracks[subIndex]
+	if !burn && !track.Text {
+		return media.ErrImageSubtitleMux
+	}
+
+	outputDir := filepath.Dir(outputPath)
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return err
+	}
+
+	transcodeVideo := burn || info.VideoCodec != "h264"
+	tmpPath := outputPath + ".tmp.mp4"
+	audioIndex := c.sourceAudioIndex(ctx, inputPath, audioTrack)
+	audio := chooseAudioArgs(info, audioIndex, c.encoding.AudioKbps)
+	totalMs := int64(info.Duration * 1000)
+	err = mp4WithCopyFallback(ctx, transcodeVideo, onProgress, func(transcode bool, report func(int)) error {
+		return c.transcodeIf(ctx, transcode, func(enc videoEncoder) error {
+			_ = os.Remove(tmpPath)
+			return runWithProgress(ctx, subtitleMP4Args(enc, inputPath, tmpPath, transcode, attemptAudioArgs(audio, audioIndex, c.encoding.AudioKbps, transcodeVideo, transcode), track, burn), totalMs, report)
+		})
+	})
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	_ = os.Remove(outputPath)
+	return os.Rename(tmpPath, outputPath)
+}
+
+// subtitleMP4Args builds ffmpeg arguments for MP4 output carrying track. Burned
+// text subtitles go through the subtitles filter, burned image subtitles are
+// overlaid, and soft subtitles are converted to mov_text.
+func subtitleMP4Args(enc videoEncoder, inputPath, tmpPath string, transcodeVideo bool, audio []string, track media.SubtitleTrack, burn bool) []string {
+	args := []string{"-y"}
+	if transcodeVideo {
+		args = append(args, enc.input...)
+	}
+	args = append(args, "-i", inputPath)
+
+	filter := ""
+	switch {
+	case burn && track.Text:
+		args = append(args, "-sn", "-map", "0:v:0")
+		filter = fmt.Sprintf("subtitles=%s:si=%d", escapeFilterPath(inputPath), track.Index)
+	case burn:
+		overlay := fmt.Sprintf("[0:v:0][0:s:%d]overlay", track.Index)
+		if upload := enc.filterChain(""); upload != "" {
+			overlay += "," + upload
+		}
+		args = append(args, "-sn", "-filter_complex", overlay+"[v]", "-map", "[v]")
+	default:
+		args = append(args, "-map", "0:v:0?")
+	}
+	args = append(args, audio...)
+	if !burn {
+		args = append(args, "-map", fmt.Sprintf("0:s:%d", track.Index), "-c:s", "mov_text")
+	}
+	args = append(args, "-progress", "pipe:1", "-nostats")
+	switch {
+	case !transcodeVideo:
+		args = append(args, "-c:v", "copy")
+	case burn && !track.Text:
+		args = append(args, enc.encodeArgs(true)...)
+	default:
+		args = append(args, enc.videoArgs(filter, true)...)
+	}
+
+	return append(args,
+		"-f", "mp4",
+		"-movflags", "+faststart",
+		tmpPath,
+	)
+}
+
+// escapeFilterPath escapes a file path for use as a filter option inside a
+// filtergraph: once for the option value, then once for the graph itself.
+func escapeFilterPath(path string) string {
+	value := strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`).Replace(path)
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `[`, `\[`, `]`, `\]`, `,`, `\,`, `;`, `\;`).Replace(value)
+}
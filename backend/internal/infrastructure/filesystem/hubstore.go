@@ -0,0 +1,93 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"evd/internal/domain/watchparty"
+)
+
+const hubFileExt = ".json"
+
+// HubStore persists watch-party hubs as one JSON file per hub.
+type HubStore struct {
+	dir string
+}
+
+// NewHubStore creates a hub store rooted at dir.
+func NewHubStore(dir string) *HubStore {
+	return &HubStore{dir: dir}
+}
+
+// Save writes record atomically, replacing any earlier copy.
+func (s *HubStore) Save(record watchparty.HubRecord) error {
+	path, err := s.hubPath(record.ID)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, raw, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Load returns every stored hub. Unreadable files are skipped.
+func (s *HubStore) Load() ([]watchparty.HubRecord, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	records := make([]watchparty.HubRecord, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), hubFileExt) {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var record watchparty.HubRecord
+		if err := json.Unmarshal(raw, &record); err != nil || record.ID == "" {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Delete removes a stored hub. Missing hubs are not an error.
+func (s *HubStore) Delete(hubID string) error {
+	path, err := s.hubPath(hubID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *HubStore) hubPath(hubID string) (string, error) {
+	if hubID == "" || strings.ContainsAny(hubID, `/\.`) {
+		return "", fmt.Errorf("invalid hub id %q", hubID)
+	}
+	return filepath.Join(s.dir, hubID+hubFileExt), nil
+}
@@ -0,0 +1,40 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"evd/internal/domain/watchparty"
+)
+
+func TestHubStore_SaveLoadDelete(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hubs")
+	store := NewHubStore(dir)
+
+	record := watchparty.HubRecord{ID: "abc123", OwnerID: "u1", VideoPath: "a.mkv", CurrentTime: 12}
+	if err := store.Save(record); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0o600); err != nil {
+		t.Fatalf("write broken file: %v", err)
+	}
+
+	records, err := store.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(records) != 1 || records[0].ID != "abc123" || records[0].CurrentTime != 12 {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+
+	if err := store.Delete("abc123"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := store.Delete("abc123"); err != nil {
+		t.Fatalf("expected deleting a missing hub to succeed, got %v", err)
+	}
+	if err := store.Save(watchparty.HubRecord{ID: "../escape"}); err == nil {
+		t.Fatal("expected path-like hub id to be rejected")
+	}
+}
@@ -1,6 +1,9 @@
 package filesystem
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"io/fs"
 	"os"
@@ -8,9 +11,30 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"evd/internal/domain/media"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// maxOutputNameBytes is the common per-component filename limit (ext4, xfs, btrfs).
+	maxOutputNameBytes = 255
+	// maxOutputPathBytes stays safely below PATH_MAX.
+	maxOutputPathBytes = 4000
+	// outputSuffixReserve covers the longest suffix appended to a derived name
+	// (".mp4.tmp.mp4" for MP4 temp files, "/segment00000.ts.tmp" inside HLS dirs).
+	outputSuffixReserve = 24
+
+	// videoListTTL is how long SearchVideos reuses the last library walk when
+	// the videos directory is not being watched.
+	videoListTTL = 5 * time.Second
+
+	longNamesDir = "_long"
+
+	// fmp4HLSDir keeps fragmented-MP4 HLS renditions apart from the default TS ones.
+	fmp4HLSDir = "_fmp4"
 )
 
 // Store manages media files and output paths.
@@ -18,11 +42,26 @@ type Store struct {
 	VideosDir string
 	HLSDir    string
 	MP4Dir    string
+	ThumbsDir string
+	// NamesFile, when set, records the source of every output stored under a
+	// hashed long name. It belongs outside the served output roots.
+	NamesFile string
+
+	shortNamesMu sync.Mutex
+	shortNames   map[string]string
+
+	videoListMu       sync.Mutex
+	videoList         []media.Video
+	videoListListedAt time.Time
+	// videoListGen changes on every invalidation, so a walk that raced with a
+	// change does not cache its result.
+	videoListGen uint64
+	watching     bool
 }
 
 // NewStore creates filesystem adapter with configured roots.
-func NewStore(videosDir, hlsDir, mp4Dir string) *Store {
-	return &Store{VideosDir: videosDir, HLSDir: hlsDir, MP4Dir: mp4Dir}
+func NewStore(videosDir, hlsDir, mp4Dir, thumbsDir string) *Store {
+	return &Store{VideosDir: videosDir, HLSDir: hlsDir, MP4Dir: mp4Dir, ThumbsDir: thumbsDir}
 }
 
 // EnsureDirs creates filesystem roots used by service.
@@ -36,6 +75,26 @@ func (s *Store) EnsureDirs() error {
 	if err := os.MkdirAll(s.MP4Dir, 0o755); err != nil {
 		return err
 	}
+	if err := os.MkdirAll(s.ThumbsDir, 0o755); err != nil {
+		return err
+	}
+	return nil
+}
+
+// writeCheckPrefix names CheckWritable's probe files, which WatchVideos ignores.
+const writeCheckPrefix = ".evd-write-check-"
+
+// CheckWritable verifies that a file can be created in the videos, HLS and MP4
+// directories.
+func (s *Store) CheckWritable() error {
+	for _, dir := range []string{s.VideosDir, s.HLSDir, s.MP4Dir} {
+		file, err := os.CreateTemp(dir, writeCheckPrefix+"*")
+		if err != nil {
+			return err
+		}
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}
 	return nil
 }
 
@@ -46,8 +105,16 @@ func (s *Store) VideosRoot() string {
 
 // ListVideos scans media library and returns normalized entries.
 func (s *Store) ListVideos() ([]media.Video, error) {
+	s.videoListMu.Lock()
+	gen := s.videoListGen
+	s.videoListMu.Unlock()
+
 	videos := make([]media.Video, 0)
+	trashDir := filepath.Join(s.VideosDir, TrashDir)
 	_ = filepath.WalkDir(s.VideosDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.IsDir() && filePath == trashDir {
+			return filepath.SkipDir
+		}
 		if err != nil || entry.IsDir() {
 			return nil
 		}
@@ -79,40 +146,362 @@ func (s *Store) ListVideos() ([]media.Video, error) {
 		return videos[i].ModifiedAt.After(videos[j].ModifiedAt)
 	})
 
+	s.videoListMu.Lock()
+	if gen == s.videoListGen {
+		s.videoList, s.videoListListedAt = videos, time.Now()
+	}
+	s.videoListMu.Unlock()
 	return videos, nil
 }
 
+// SearchVideos returns library videos whose relative path contains every
+// whitespace-separated term of query, ignoring case. Matches in the file name
+// come before matches only in the folder path; otherwise the ListVideos order is
+// kept. Listings from the last few seconds are reused, so searching as the user
+// types does not walk the library on every keystroke.
+func (s *Store) SearchVideos(query string) ([]media.Video, error) {
+	terms := strings.Fields(strings.ToLower(norm.NFC.String(query)))
+
+	s.videoListMu.Lock()
+	videos := s.videoList
+	fresh := videos != nil && (s.watching || time.Since(s.videoListListedAt) < videoListTTL)
+	s.videoListMu.Unlock()
+	if !fresh {
+		var err error
+		if videos, err = s.ListVideos(); err != nil {
+			return nil, err
+		}
+	}
+
+	var byName, byPath []media.Video
+	for _, video := range videos {
+		relPath := strings.ToLower(norm.NFC.String(video.Path))
+		name := path.Base(relPath)
+		inName, inPath := true, true
+		for _, term := range terms {
+			inName = inName && strings.Contains(name, term)
+			inPath = inPath && strings.Contains(relPath, term)
+		}
+		switch {
+		case inName:
+			byName = append(byName, video)
+		case inPath:
+			byPath = append(byPath, video)
+		}
+	}
+	return append(byName, byPath...), nil
+}
+
+// ListDir returns the videos and immediate subdirectories of relDir, a path
+// relative to the library root ("" for the root). Directories come first, then
+// files, each sorted by name. Hidden entries and unsupported files are skipped.
+func (s *Store) ListDir(relDir string) ([]media.Entry, error) {
+	rel, err := media.NormalizeDirPath(relDir)
+	if err != nil {
+		return nil, err
+	}
+	full := filepath.Join(s.VideosDir, filepath.FromSlash(rel))
+	if !isWithinDir(s.VideosDir, full) {
+		return nil, errors.New("invalid directory path")
+	}
+
+	dirEntries, err := os.ReadDir(full)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]media.Entry, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if !listable(dirEntry) {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+
+		entry := media.Entry{
+			Name:       dirEntry.Name(),
+			Path:       path.Join(rel, dirEntry.Name()),
+			IsDir:      dirEntry.IsDir(),
+			ModifiedAt: info.ModTime(),
+		}
+		if entry.IsDir {
+			if children, err := os.ReadDir(filepath.Join(full, dirEntry.Name())); err == nil {
+				for _, child := range children {
+					if listable(child) {
+						entry.Children++
+					}
+				}
+			}
+		} else {
+			entry.Size = info.Size()
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+// listable reports whether a directory entry appears in ListDir: visible
+// directories and supported video files.
+func listable(entry fs.DirEntry) bool {
+	if strings.HasPrefix(entry.Name(), ".") {
+		return false
+	}
+	if entry.IsDir() {
+		return true
+	}
+	return entry.Type().IsRegular() && media.IsSupportedVideoExt(filepath.Ext(entry.Name()))
+}
+
 // ResolveVideoPath validates a request path and returns relative/absolute forms.
+// Trashed videos are not addressable.
 func (s *Store) ResolveVideoPath(raw string) (string, string, error) {
 	rel, err := media.NormalizeVideoPath(raw)
 	if err != nil {
 		return "", "", err
 	}
 	full := filepath.Join(s.VideosDir, filepath.FromSlash(rel))
-	if !isWithinDir(s.VideosDir, full) {
+	if !isWithinDir(s.VideosDir, full) || inTrash(rel) {
 		return "", "", errors.New("invalid file path")
 	}
 	return rel, full, nil
 }
 
-// HLSPaths builds output paths and URL for HLS artifacts.
-func (s *Store) HLSPaths(relPath string) (string, string, string) {
-	base := strings.TrimSuffix(relPath, path.Ext(relPath))
-	outputDir := filepath.Join(s.HLSDir, filepath.FromSlash(base))
+// MoveVideo renames srcRel to dstRel inside the library, creating missing
+// destination folders. Both paths are validated like ResolveVideoPath. An
+// existing destination is never overwritten; the error then wraps os.ErrExist.
+func (s *Store) MoveVideo(srcRel, dstRel string) error {
+	_, src, err := s.ResolveVideoPath(srcRel)
+	if err != nil {
+		return err
+	}
+	_, dst, err := s.ResolveVideoPath(dstRel)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(dst); err == nil {
+		return &fs.PathError{Op: "move", Path: dstRel, Err: fs.ErrExist}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return err
+	}
+
+	s.invalidateVideoList()
+	return nil
+}
+
+// HLSPaths builds output paths and URL for HLS artifacts in the given segment format.
+func (s *Store) HLSPaths(relPath string, format media.HLSFormat) (string, string, string) {
+	root, prefix := s.HLSDir, ""
+	if format == media.HLSFormatFMP4 {
+		root, prefix = filepath.Join(s.HLSDir, fmp4HLSDir), fmp4HLSDir+"/"
+	}
+
+	base := outputBase(root, relPath)
+	outputDir := filepath.Join(root, filepath.FromSlash(base))
 	outputPath := filepath.Join(outputDir, "index.m3u8")
-	urlPath := "/hls/" + base + "/index.m3u8"
+	urlPath := "/hls/" + prefix + naturalBase(relPath) + "/index.m3u8"
 	return outputDir, outputPath, urlPath
 }
 
+// HLSFileName maps a file name below the HLS URL root to its name below HLSDir.
+// URLs always follow the source path, so outputs stored under a hashed long name
+// are found by hashing the directory part the way HLSPaths does. Files sit at
+// most one rendition folder deep inside an output.
+func (s *Store) HLSFileName(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	root, prefix := s.HLSDir, "/"
+	if rest, ok := strings.CutPrefix(name, fmp4HLSDir+"/"); ok {
+		root, prefix, name = filepath.Join(s.HLSDir, fmp4HLSDir), "/"+fmp4HLSDir+"/", rest
+	}
+
+	parts := strings.Split(name, "/")
+	mapped := name
+	for inner := 1; inner <= 2 && inner < len(parts); inner++ {
+		base := norm.NFC.String(strings.Join(parts[:len(parts)-inner], "/"))
+		if fitsOutputLimits(root, base) {
+			break
+		}
+		candidate := hashedOutputBase(base) + "/" + strings.Join(parts[len(parts)-inner:], "/")
+		if inner == 1 {
+			mapped = candidate
+		}
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(candidate))); err == nil {
+			mapped = candidate
+			break
+		}
+	}
+	return prefix + mapped
+}
+
 // MP4Paths builds output paths and URL for MP4 artifacts.
 func (s *Store) MP4Paths(relPath string) (string, string, string) {
-	base := strings.TrimSuffix(relPath, path.Ext(relPath))
+	base := outputBase(s.MP4Dir, relPath)
 	outputPath := filepath.Join(s.MP4Dir, filepath.FromSlash(base)+".mp4")
 	outputDir := filepath.Dir(outputPath)
 	urlPath := "/api/stream-mp4/" + relPath
 	return outputDir, outputPath, urlPath
 }
 
+// ThumbnailPath builds the output path for a video's poster thumbnail.
+func (s *Store) ThumbnailPath(relPath string) string {
+	base := outputBase(s.ThumbsDir, relPath)
+	return filepath.Join(s.ThumbsDir, filepath.FromSlash(base)+".jpg")
+}
+
+// outputBase derives the extension-less output name for relPath under root.
+// Names are NFC-normalized; when the derived path would exceed filesystem limits a
+// deterministic hashed name is used instead (see RecordOutputName).
+func outputBase(root, relPath string) string {
+	base := naturalBase(relPath)
+	if fitsOutputLimits(root, base) {
+		return base
+	}
+	return hashedOutputBase(base)
+}
+
+// naturalBase is the NFC-normalized, extension-less output name of relPath.
+func naturalBase(relPath string) string {
+	return norm.NFC.String(strings.TrimSuffix(relPath, path.Ext(relPath)))
+}
+
+func hashedOutputBase(base string) string {
+	sum := sha256.Sum256([]byte(base))
+	return longNamesDir + "/" + hex.EncodeToString(sum[:12])
+}
+
+func fitsOutputLimits(root, base string) bool {
+	if len(filepath.Join(root, filepath.FromSlash(base)))+outputSuffixReserve > maxOutputPathBytes {
+		return false
+	}
+	for _, part := range strings.Split(base, "/") {
+		if len(part)+outputSuffixReserve > maxOutputNameBytes {
+			return false
+		}
+	}
+	return true
+}
+
+// RecordOutputName adds relPath to NamesFile when any of its outputs is stored
+// under a hashed long name, so operators can tell which source a hashed folder
+// belongs to. Conversions call it before they write output.
+func (s *Store) RecordOutputName(relPath string) error {
+	if s.NamesFile == "" {
+		return nil
+	}
+	base := naturalBase(relPath)
+	hashed := false
+	for _, root := range []string{s.HLSDir, filepath.Join(s.HLSDir, fmp4HLSDir), s.MP4Dir, s.ThumbsDir} {
+		hashed = hashed || !fitsOutputLimits(root, base)
+	}
+	if !hashed {
+		return nil
+	}
+	short := path.Base(hashedOutputBase(base))
+
+	s.shortNamesMu.Lock()
+	defer s.shortNamesMu.Unlock()
+
+	if s.shortNames == nil {
+		s.shortNames = map[string]string{}
+		if raw, err := os.ReadFile(s.NamesFile); err == nil {
+			_ = json.Unmarshal(raw, &s.shortNames)
+		}
+	}
+	previous, known := s.shortNames[short]
+	if known && previous == relPath {
+		return nil
+	}
+	s.shortNames[short] = relPath
+	if err := s.saveShortNamesLocked(); err != nil {
+		if known {
+			s.shortNames[short] = previous
+		} else {
+			delete(s.shortNames, short)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Store) saveShortNamesLocked() error {
+	raw, err := json.MarshalIndent(s.shortNames, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.NamesFile), 0o755); err != nil {
+		return err
+	}
+	tmpPath := s.NamesFile + ".tmp"
+	if err := os.WriteFile(tmpPath, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.NamesFile)
+}
+
+// DiskUsage reports capacity for each configured media root.
+func (s *Store) DiskUsage() ([]media.DiskUsage, error) {
+	roots := []struct{ name, path string }{
+		{"videos", s.VideosDir},
+		{"hls", s.HLSDir},
+		{"mp4", s.MP4Dir},
+		{"thumbs", s.ThumbsDir},
+	}
+
+	out := make([]media.DiskUsage, 0, len(roots))
+	for _, root := range roots {
+		total, free, err := statDisk(root.path)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, media.DiskUsage{
+			Name:       root.name,
+			Path:       root.path,
+			TotalBytes: total,
+			FreeBytes:  free,
+			UsedBytes:  total - free,
+		})
+	}
+	return out, nil
+}
+
+// OutputRoots returns the directories holding HLS and MP4 outputs.
+func (s *Store) OutputRoots() (string, string) {
+	return s.HLSDir, s.MP4Dir
+}
+
+// FreeSpace reports the bytes available to the server on the filesystem holding
+// path. A path that does not exist yet is measured at its nearest existing parent.
+func (s *Store) FreeSpace(path string) (uint64, error) {
+	dir := filepath.Clean(path)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	_, free, err := statDisk(dir)
+	return free, err
+}
+
 // FileExists checks if a media file exists in source library.
 func (s *Store) FileExists(relPath string) bool {
 	full := filepath.Join(s.VideosDir, filepath.FromSlash(relPath))
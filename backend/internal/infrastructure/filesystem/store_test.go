@@ -0,0 +1,402 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	root := t.TempDir()
+	store := NewStore(
+		filepath.Join(root, "videos"),
+		filepath.Join(root, "hls"),
+		filepath.Join(root, "mp4"),
+		filepath.Join(root, "thumbs"),
+	)
+	if err := store.EnsureDirs(); err != nil {
+		t.Fatalf("ensure dirs: %v", err)
+	}
+	return store
+}
+
+func TestOutputPaths_HashesOverlongNames(t *testing.T) {
+	store := newTestStore(t)
+	store.NamesFile = filepath.Join(t.TempDir(), "data", "output-names.json")
+	relPath := "shows/" + strings.Repeat("я", 300) + ".mkv"
+
+	for _, format := range []media.HLSFormat{media.HLSFormatTS, media.HLSFormatFMP4} {
+		outputDir, playlistPath, hlsURL := store.HLSPaths(relPath, format)
+		if !strings.Contains(outputDir, longNamesDir) {
+			t.Fatalf("expected a hashed HLS dir, got %q", outputDir)
+		}
+		if err := os.MkdirAll(filepath.Join(outputDir, "720p"), 0o755); err != nil {
+			t.Fatalf("expected hashed HLS dir to be creatable, got %v", err)
+		}
+		for _, name := range []string{playlistPath, filepath.Join(outputDir, "720p", "index.m3u8")} {
+			if err := os.WriteFile(name, []byte("#EXTM3U\n"), 0o644); err != nil {
+				t.Fatalf("write playlist: %v", err)
+			}
+		}
+		if again, _, _ := store.HLSPaths(relPath, format); again != outputDir {
+			t.Fatalf("expected deterministic output dir, got %q and %q", outputDir, again)
+		}
+
+		// The URL keeps the source path and resolves back to the hashed files.
+		prefix := "/hls/"
+		if format == media.HLSFormatFMP4 {
+			prefix += fmp4HLSDir + "/"
+		}
+		if want := prefix + "shows/" + strings.Repeat("я", 300) + "/index.m3u8"; hlsURL != want {
+			t.Fatalf("expected the HLS url to follow the source path, got %q", hlsURL)
+		}
+		for urlPath, want := range map[string]string{
+			hlsURL: playlistPath,
+			strings.TrimSuffix(hlsURL, "index.m3u8") + "720p/index.m3u8": filepath.Join(outputDir, "720p", "index.m3u8"),
+		} {
+			got := filepath.Join(store.HLSDir, filepath.FromSlash(store.HLSFileName(strings.TrimPrefix(urlPath, "/hls/"))))
+			if got != want {
+				t.Fatalf("expected %q to resolve to %q, got %q", urlPath, want, got)
+			}
+		}
+	}
+	if name := store.HLSFileName("shows/short/index.m3u8"); name != "/shows/short/index.m3u8" {
+		t.Fatalf("expected short names to map to themselves, got %q", name)
+	}
+
+	mp4Dir, mp4Path, mp4URL := store.MP4Paths(relPath)
+	if err := os.MkdirAll(mp4Dir, 0o755); err != nil {
+		t.Fatalf("mkdir mp4: %v", err)
+	}
+	if err := os.WriteFile(mp4Path+".tmp.mp4", []byte("data"), 0o644); err != nil {
+		t.Fatalf("expected hashed MP4 temp file to be writable, got %v", err)
+	}
+	if mp4URL != "/api/stream-mp4/"+relPath {
+		t.Fatalf("expected MP4 url to keep source path, got %q", mp4URL)
+	}
+
+	if _, err := os.Stat(store.NamesFile); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected path lookups not to write the name mapping, got %v", err)
+	}
+	if err := store.RecordOutputName(relPath); err != nil {
+		t.Fatalf("record name: %v", err)
+	}
+	raw, err := os.ReadFile(store.NamesFile)
+	if err != nil {
+		t.Fatalf("read mapping: %v", err)
+	}
+	mapping := map[string]string{}
+	if err := json.Unmarshal(raw, &mapping); err != nil {
+		t.Fatalf("decode mapping: %v", err)
+	}
+	if mapping[strings.TrimSuffix(filepath.Base(mp4Path), ".mp4")] != relPath {
+		t.Fatalf("expected mapping to record source path, got %v", mapping)
+	}
+	if _, err := os.Stat(filepath.Join(store.HLSDir, longNamesDir, "names.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no mapping inside the served HLS root, got %v", err)
+	}
+}
+
+func TestOutputPaths_NormalizesUnicode(t *testing.T) {
+	store := newTestStore(t)
+	nfc := "Am\u00e9lie.mkv"
+	nfd := "Ame\u0301lie.mkv"
+
+	nfcDir, _, nfcURL := store.HLSPaths(nfc, media.HLSFormatTS)
+	nfdDir, _, nfdURL := store.HLSPaths(nfd, media.HLSFormatTS)
+	if nfcDir != nfdDir || nfcURL != nfdURL {
+		t.Fatalf("expected NFC and NFD names to share output, got %q and %q", nfcDir, nfdDir)
+	}
+	if store.ThumbnailPath(nfc) != store.ThumbnailPath(nfd) {
+		t.Fatalf("expected NFC and NFD names to share thumbnail path")
+	}
+}
+
+func TestHLSPaths_SeparatesFMP4Renditions(t *testing.T) {
+	store := newTestStore(t)
+
+	tsDir, _, tsURL := store.HLSPaths("shows/movie.mkv", media.HLSFormatTS)
+	fmp4Dir, _, fmp4URL := store.HLSPaths("shows/movie.mkv", media.HLSFormatFMP4)
+	if tsURL != "/hls/shows/movie/index.m3u8" {
+		t.Fatalf("expected TS layout to stay unchanged, got %q", tsURL)
+	}
+	if fmp4URL != "/hls/_fmp4/shows/movie/index.m3u8" {
+		t.Fatalf("unexpected fMP4 url %q", fmp4URL)
+	}
+	if fmp4Dir != filepath.Join(store.HLSDir, "_fmp4", "shows", "movie") || fmp4Dir == tsDir {
+		t.Fatalf("expected separate fMP4 output dir, got %q", fmp4Dir)
+	}
+}
+
+func TestListDir_ListsFoldersThenVideos(t *testing.T) {
+	store := newTestStore(t)
+	write := func(rel string) {
+		t.Helper()
+		full := filepath.Join(store.VideosDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte("video"), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	write("b.mkv")
+	write("notes.txt")
+	write("upload.mkv.part")
+	write("shows/s01/e01.mkv")
+	write("shows/e00.mp4")
+	write("shows/cover.jpg")
+	write(".hidden/x.mkv")
+
+	root, err := store.ListDir("")
+	if err != nil {
+		t.Fatalf("list root: %v", err)
+	}
+	if len(root) != 2 || !root[0].IsDir || root[0].Path != "shows" || root[0].Children != 2 {
+		t.Fatalf("expected shows folder with 2 children first, got %+v", root)
+	}
+	if root[1].IsDir || root[1].Path != "b.mkv" || root[1].Size != 5 {
+		t.Fatalf("expected b.mkv after folders, got %+v", root[1])
+	}
+
+	shows, err := store.ListDir("/shows/")
+	if err != nil {
+		t.Fatalf("list shows: %v", err)
+	}
+	if len(shows) != 2 || shows[0].Path != "shows/s01" || shows[1].Path != "shows/e00.mp4" {
+		t.Fatalf("unexpected shows listing: %+v", shows)
+	}
+
+	if _, err := store.ListDir("../"); err == nil {
+		t.Fatalf("expected traversal to be rejected")
+	}
+	if _, err := store.ListDir("missing"); !os.IsNotExist(err) {
+		t.Fatalf("expected not-exist error, got %v", err)
+	}
+}
+
+func TestSearchVideos_MatchesTermsNamesFirst(t *testing.T) {
+	store := newTestStore(t)
+	for _, rel := range []string{"Holiday/beach.mkv", "holiday-recap.mp4", "work/talk.mkv", "notes.txt"} {
+		full := filepath.Join(store.VideosDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte("video"), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	results, err := store.SearchVideos("HOLIDAY")
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 2 || results[0].Path != "holiday-recap.mp4" || results[1].Path != "Holiday/beach.mkv" {
+		t.Fatalf("expected name match before folder match, got %+v", results)
+	}
+
+	results, err = store.SearchVideos("holiday beach")
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 1 || results[0].Path != "Holiday/beach.mkv" {
+		t.Fatalf("expected every term to match, got %+v", results)
+	}
+}
+
+func TestWatchVideos_InvalidatesListingAndNotifies(t *testing.T) {
+	store := newTestStore(t)
+	if err := os.MkdirAll(filepath.Join(store.VideosDir, "shows"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	changed := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = store.WatchVideos(ctx, func() {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		})
+	}()
+
+	// Wait for the watch to start, then prime the cached listing.
+	waitForWatch(t, store)
+	if results, err := store.SearchVideos("episode"); err != nil || len(results) != 0 {
+		t.Fatalf("expected no results yet, got %v (%v)", results, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(store.VideosDir, "shows", "episode.mkv"), []byte("video"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(watchDebounce + 3*time.Second):
+		t.Fatalf("expected change notification")
+	}
+
+	results, err := store.SearchVideos("episode")
+	if err != nil || len(results) != 1 || results[0].Path != "shows/episode.mkv" {
+		t.Fatalf("expected new video after change, got %v (%v)", results, err)
+	}
+}
+
+func TestWatchVideos_IgnoresWriteChecks(t *testing.T) {
+	store := newTestStore(t)
+	changed := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = store.WatchVideos(ctx, func() { changed <- struct{}{} })
+	}()
+	waitForWatch(t, store)
+
+	store.videoListMu.Lock()
+	gen := store.videoListGen
+	store.videoListMu.Unlock()
+	if err := store.CheckWritable(); err != nil {
+		t.Fatalf("check writable: %v", err)
+	}
+	select {
+	case <-changed:
+		t.Fatalf("expected the write check not to notify")
+	case <-time.After(watchDebounce + 500*time.Millisecond):
+	}
+	store.videoListMu.Lock()
+	defer store.videoListMu.Unlock()
+	if store.videoListGen != gen {
+		t.Fatalf("expected the write check not to invalidate the listing")
+	}
+}
+
+func waitForWatch(t *testing.T, store *Store) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		store.videoListMu.Lock()
+		watching := store.watching
+		store.videoListMu.Unlock()
+		if watching {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("watch did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMoveVideo_RefusesOverwrite(t *testing.T) {
+	store := newTestStore(t)
+	for _, name := range []string{"a.mkv", "b.mkv"} {
+		if err := os.WriteFile(filepath.Join(store.VideosDir, name), []byte(name), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	if err := store.MoveVideo("a.mkv", "b.mkv"); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected os.ErrExist, got %v", err)
+	}
+	if err := store.MoveVideo("a.mkv", "b.txt"); err == nil {
+		t.Fatalf("expected unsupported destination to be rejected")
+	}
+	if err := store.MoveVideo("a.mkv", "sub/dir/a.mp4"); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(store.VideosDir, "sub", "dir", "a.mp4")); err != nil || string(data) != "a.mkv" {
+		t.Fatalf("expected moved file, got %q (%v)", data, err)
+	}
+}
+
+func TestTrash_TrashRestoreAndEmpty(t *testing.T) {
+	store := newTestStore(t)
+	full := filepath.Join(store.VideosDir, "shows", "e01.mkv")
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(full, []byte("video"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	name, err := store.TrashVideo("shows/e01.mkv")
+	if err != nil {
+		t.Fatalf("trash: %v", err)
+	}
+	if videos, _ := store.ListVideos(); len(videos) != 0 {
+		t.Fatalf("expected trashed video hidden from listing, got %+v", videos)
+	}
+	if _, _, err := store.ResolveVideoPath(TrashDir + "/" + name); err == nil {
+		t.Fatalf("expected trashed video to be unaddressable")
+	}
+	items, err := store.ListTrash()
+	if err != nil || len(items) != 1 || items[0].Name != name || items[0].OriginalPath != "shows/e01.mkv" || items[0].Size != 5 {
+		t.Fatalf("unexpected trash listing %+v (%v)", items, err)
+	}
+
+	if err := os.WriteFile(full, []byte("new"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := store.RestoreFromTrash(name); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected restore over an existing file to fail, got %v", err)
+	}
+	if err := os.Remove(full); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if restored, err := store.RestoreFromTrash(name); err != nil || restored != "shows/e01.mkv" {
+		t.Fatalf("restore: %q %v", restored, err)
+	}
+
+	if _, err := store.TrashVideo("shows/e01.mkv"); err != nil {
+		t.Fatalf("trash again: %v", err)
+	}
+	if removed, err := store.EmptyTrash(time.Hour); err != nil || len(removed) != 0 {
+		t.Fatalf("expected recent deletions kept, removed %v (%v)", removed, err)
+	}
+	if removed, err := store.EmptyTrash(0); err != nil || len(removed) != 1 || !strings.HasPrefix(removed[0], "shows/e01.deleted-") {
+		t.Fatalf("expected trash emptied, removed %v (%v)", removed, err)
+	}
+	if _, err := os.Stat(filepath.Join(store.VideosDir, TrashDir, "shows")); !os.IsNotExist(err) {
+		t.Fatalf("expected empty trash folders pruned, got %v", err)
+	}
+}
+
+func TestListVideos_IncludesConfiguredExtensions(t *testing.T) {
+	if err := media.ConfigureVideoExts([]string{"flv"}); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	t.Cleanup(func() { _ = media.ConfigureVideoExts(nil) })
+	store := newTestStore(t)
+	for _, name := range []string{"clip.flv", "clip.webm", "clip.wmv"} {
+		if err := os.WriteFile(filepath.Join(store.VideosDir, name), []byte("data"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	videos, err := store.ListVideos()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var names []string
+	for _, video := range videos {
+		names = append(names, video.Path)
+	}
+	if strings.Join(names, ",") != "clip.flv,clip.webm" {
+		t.Fatalf("expected configured and default extensions listed, got %v", names)
+	}
+	if _, _, err := store.ResolveVideoPath("clip.wmv"); err == nil {
+		t.Fatalf("expected unconfigured extension to be rejected")
+	}
+	if err := media.ConfigureVideoExts([]string{"../x"}); err == nil {
+		t.Fatalf("expected invalid extension to be refused")
+	}
+}
@@ -0,0 +1,196 @@
+package filesystem
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"evd/internal/domain/media"
+)
+
+const (
+	// TrashDir holds deleted videos below VideosDir until they are restored or
+	// expire. Being hidden keeps it out of folder listings.
+	TrashDir = ".trash"
+
+	trashStampLayout = "20060102T150405Z"
+)
+
+// trashedName matches the deletion stamp TrashVideo inserts before the extension.
+var trashedName = regexp.MustCompile(`^(.*)\.deleted-(\d{8}T\d{6}Z)$`)
+
+// TrashVideo moves the library video relPath into the trash, keeping its folder
+// and adding a deletion stamp to the name so repeated deletions do not collide.
+// It returns the trash name, which RestoreFromTrash accepts.
+func (s *Store) TrashVideo(relPath string) (string, error) {
+	rel, src, err := s.ResolveVideoPath(relPath)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(src); err != nil {
+		return "", err
+	}
+
+	ext := path.Ext(rel)
+	name := strings.TrimSuffix(rel, ext) + ".deleted-" + time.Now().UTC().Format(trashStampLayout) + ext
+	dst := filepath.Join(s.VideosDir, TrashDir, filepath.FromSlash(name))
+	if _, err := os.Lstat(dst); err == nil {
+		return "", &fs.PathError{Op: "trash", Path: rel, Err: fs.ErrExist}
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return "", err
+	}
+
+	s.invalidateVideoList()
+	return name, nil
+}
+
+// ListTrash returns trashed videos, most recently deleted first.
+func (s *Store) ListTrash() ([]media.TrashedVideo, error) {
+	root := filepath.Join(s.VideosDir, TrashDir)
+	items := make([]media.TrashedVideo, 0)
+	err := filepath.WalkDir(root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if filePath == root && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return nil
+		}
+		item, ok := parseTrashName(filepath.ToSlash(rel))
+		if !ok {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			item.Size = info.Size()
+		}
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+	return items, nil
+}
+
+// RestoreFromTrash moves the trashed video name back to its original library
+// path and returns that path. An existing file there is never overwritten; the
+// error then wraps os.ErrExist.
+func (s *Store) RestoreFromTrash(name string) (string, error) {
+	name, src, err := s.resolveTrashName(name)
+	if err != nil {
+		return "", err
+	}
+	item, ok := parseTrashName(name)
+	if !ok {
+		return "", errors.New("invalid trash name")
+	}
+	_, dst, err := s.ResolveVideoPath(item.OriginalPath)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(src); err != nil {
+		return "", err
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return "", &fs.PathError{Op: "restore", Path: item.OriginalPath, Err: fs.ErrExist}
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return "", err
+	}
+
+	s.pruneTrashDirs(filepath.Dir(src))
+	s.invalidateVideoList()
+	return item.OriginalPath, nil
+}
+
+// EmptyTrash permanently deletes videos trashed more than olderThan ago; zero
+// empties the whole trash. It returns the trash names of the files removed.
+func (s *Store) EmptyTrash(olderThan time.Duration) ([]string, error) {
+	items, err := s.ListTrash()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	var removed []string
+	for _, item := range items {
+		if item.DeletedAt.After(cutoff) {
+			continue
+		}
+		full := filepath.Join(s.VideosDir, TrashDir, filepath.FromSlash(item.Name))
+		if err := os.Remove(full); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		s.pruneTrashDirs(filepath.Dir(full))
+		removed = append(removed, item.Name)
+	}
+	return removed, nil
+}
+
+// resolveTrashName validates a trash name and returns its cleaned and absolute forms.
+func (s *Store) resolveTrashName(raw string) (string, string, error) {
+	name, err := media.NormalizeVideoPath(raw)
+	if err != nil {
+		return "", "", err
+	}
+	root := filepath.Join(s.VideosDir, TrashDir)
+	full := filepath.Join(root, filepath.FromSlash(name))
+	if !isWithinDir(root, full) {
+		return "", "", errors.New("invalid trash name")
+	}
+	return name, full, nil
+}
+
+// pruneTrashDirs removes dir and its parents while they are empty, stopping at
+// the trash root.
+func (s *Store) pruneTrashDirs(dir string) {
+	root := filepath.Join(s.VideosDir, TrashDir)
+	for dir != root && isWithinDir(root, dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func parseTrashName(name string) (media.TrashedVideo, bool) {
+	ext := path.Ext(name)
+	match := trashedName.FindStringSubmatch(strings.TrimSuffix(name, ext))
+	if match == nil || !media.IsSupportedVideoExt(ext) {
+		return media.TrashedVideo{}, false
+	}
+	deletedAt, err := time.Parse(trashStampLayout, match[2])
+	if err != nil {
+		return media.TrashedVideo{}, false
+	}
+	return media.TrashedVideo{Name: name, OriginalPath: match[1] + ext, DeletedAt: deletedAt}, true
+}
+
+// inTrash reports whether the library-relative path rel lies inside the trash.
+func inTrash(rel string) bool {
+	return rel == TrashDir || strings.HasPrefix(rel, TrashDir+"/")
+}
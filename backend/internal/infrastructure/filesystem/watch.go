@@ -0,0 +1,112 @@
+package filesystem
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long the videos directory must stay quiet before a
+// burst of changes (a large copy, a torrent finishing) is reported once.
+const watchDebounce = 2 * time.Second
+
+// WatchVideos watches VideosDir and its subdirectories for files being created,
+// removed or renamed. Each debounced burst invalidates the cached listing and
+// calls onChange, which may be nil. While the watch runs the cached listing is
+// kept until a change arrives instead of expiring after videoListTTL.
+//
+// WatchVideos blocks until ctx is done or the watcher fails. Either way the
+// cache falls back to expiring, so listings stay correct, just rescanned
+// periodically.
+func (s *Store) WatchVideos(ctx context.Context, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := addWatchTree(watcher, s.VideosDir); err != nil {
+		return err
+	}
+
+	s.setWatching(true)
+	defer s.setWatching(false)
+
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+			// Readiness probes would otherwise rescan the library every few seconds.
+			if strings.HasPrefix(filepath.Base(event.Name), writeCheckPrefix) {
+				continue
+			}
+			// Watches are per directory; follow folders created or moved in.
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := addWatchTree(watcher, event.Name); err != nil {
+						return err
+					}
+				}
+			}
+			s.invalidateVideoList()
+			debounce.Reset(watchDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		case <-debounce.C:
+			s.invalidateVideoList()
+			if onChange != nil {
+				onChange()
+			}
+		}
+	}
+}
+
+// addWatchTree watches root and every directory below it.
+func addWatchTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(dirPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// The directory may be gone again already; its parent's events cover it.
+			if dirPath != root && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		return watcher.Add(dirPath)
+	})
+}
+
+func (s *Store) setWatching(watching bool) {
+	s.videoListMu.Lock()
+	s.watching = watching
+	s.videoListMu.Unlock()
+	s.invalidateVideoList()
+}
+
+func (s *Store) invalidateVideoList() {
+	s.videoListMu.Lock()
+	s.videoList, s.videoListListedAt = nil, time.Time{}
+	s.videoListGen++
+	s.videoListMu.Unlock()
+}
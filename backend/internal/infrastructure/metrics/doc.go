@@ -0,0 +1,2 @@
+// Package metrics provides a Prometheus metrics adapter.
+package metrics
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"evd/internal/domain/media"
+)
+
+// Recorder collects conversion, stream and torrent metrics in its own registry.
+type Recorder struct {
+	registry *prometheus.Registry
+
+	conversions   *prometheus.HistogramVec
+	streams       prometheus.Gauge
+	streamBytes   prometheus.Counter
+	subscribers   prometheus.Gauge
+	torrentErrors *prometheus.CounterVec
+}
+
+// NewRecorder creates a recorder that also exports Go runtime and process metrics.
+func NewRecorder() *Recorder {
+	r := &Recorder{
+		registry: prometheus.NewRegistry(),
+		conversions: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "evd_conversion_duration_seconds",
+			Help: "Duration of finished conversions, including time queued for a slot, by job type and outcome.",
+			// Conversions take from seconds (remuxes) to hours (long transcodes).
+			Buckets: []float64{5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200, 14400},
+		}, []string{"type", "outcome"}),
+		streams: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "evd_active_streams",
+			Help: "Video, MP4 and HLS file responses currently being served.",
+		}),
+		streamBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "evd_stream_bytes_total",
+			Help: "Bytes written to clients by video, MP4 and HLS file responses.",
+		}),
+		subscribers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "evd_watch_subscribers",
+			Help: "Open watch hub event subscriptions (SSE and WebSocket).",
+		}),
+		torrentErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "evd_torrent_rpc_errors_total",
+			Help: "Failed Transmission RPC calls by method.",
+		}, []string{"method"}),
+	}
+	r.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		r.conversions, r.streams, r.streamBytes, r.subscribers, r.torrentErrors,
+	)
+	return r
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (r *Recorder) Handler() http.Handler {
+	return promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{})
+}
+
+// ConversionFinished records a conversion that stopped with outcome after took.
+func (r *Recorder) ConversionFinished(jobType media.JobType, outcome media.JobState, took time.Duration) {
+	r.conversions.WithLabelValues(string(jobType), string(outcome)).Observe(took.Seconds())
+}
+
+// StreamOpened counts a stream response in progress.
+func (r *Recorder) StreamOpened() { r.streams.Inc() }
+
+// StreamClosed ends a stream counted by StreamOpened.
+func (r *Recorder) StreamClosed() { r.streams.Dec() }
+
+// StreamBytes adds n bytes written to a client.
+func (r *Recorder) StreamBytes(n int64) { r.streamBytes.Add(float64(n)) }
+
+// SubscriberJoined counts an open watch hub subscription.
+func (r *Recorder) SubscriberJoined() { r.subscribers.Inc() }
+
+// SubscriberLeft ends a subscription counted by SubscriberJoined.
+func (r *Recorder) SubscriberLeft() { r.subscribers.Dec() }
+
+// TorrentRPCFailed counts a failed Transmission RPC call.
+func (r *Recorder) TorrentRPCFailed(method string) {
+	r.torrentErrors.WithLabelValues(method).Inc()
+}
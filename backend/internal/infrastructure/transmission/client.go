@@ -23,11 +23,15 @@ type Client struct {
 	Pass        string
 	DownloadDir string
 	HTTP        *http.Client
-	mu          sync.Mutex
-	sessionID   string
-	focusMode   streamingFocusMode
-	lastPiece   map[string]int
-	store       *filesystem.Store
+	// OnRPCError, when set, is called with the method of every failed RPC call.
+	OnRPCError func(method string)
+
+	mu        sync.Mutex
+	sessionID string
+	focusMode streamingFocusMode
+	lastPiece map[string]int
+	lastFocus map[int]focusTarget
+	store     *filesystem.Store
 }
 
 // NewClient creates a Transmission RPC adapter.
@@ -39,6 +43,7 @@ func NewClient(url, user, pass, downloadDir string, store *filesystem.Store) *Cl
 		DownloadDir: downloadDir,
 		HTTP:        &http.Client{Timeout: 12 * time.Second},
 		lastPiece:   map[string]int{},
+		lastFocus:   map[int]focusTarget{},
 		store:       store,
 	}
 }
@@ -134,6 +139,45 @@ func (c *Client) List() ([]torrent.Info, error) {
 	return items, nil
 }
 
+// SessionStats fetches transfer rates, torrent counts and totals for the whole session.
+func (c *Client) SessionStats() (torrent.SessionStats, error) {
+	resp, err := c.request("session-stats", nil)
+	if err != nil {
+		return torrent.SessionStats{}, err
+	}
+
+	var args struct {
+		DownloadSpeed      int64         `json:"downloadSpeed"`
+		UploadSpeed        int64         `json:"uploadSpeed"`
+		TorrentCount       int           `json:"torrentCount"`
+		ActiveTorrentCount int           `json:"activeTorrentCount"`
+		PausedTorrentCount int           `json:"pausedTorrentCount"`
+		Current            transferStats `json:"current-stats"`
+		Cumulative         transferStats `json:"cumulative-stats"`
+	}
+	if err := json.Unmarshal(resp.Arguments, &args); err != nil {
+		return torrent.SessionStats{}, err
+	}
+	return torrent.SessionStats{
+		DownloadSpeed:      args.DownloadSpeed,
+		UploadSpeed:        args.UploadSpeed,
+		TorrentCount:       args.TorrentCount,
+		ActiveTorrentCount: args.ActiveTorrentCount,
+		PausedTorrentCount: args.PausedTorrentCount,
+		Current:            torrent.TransferStats(args.Current),
+		Cumulative:         torrent.TransferStats(args.Cumulative),
+	}, nil
+}
+
+// transferStats is the RPC form of torrent.TransferStats.
+type transferStats struct {
+	UploadedBytes   int64 `json:"uploadedBytes"`
+	DownloadedBytes int64 `json:"downloadedBytes"`
+	FilesAdded      int64 `json:"filesAdded"`
+	SessionCount    int64 `json:"sessionCount"`
+	SecondsActive   int64 `json:"secondsActive"`
+}
+
 // AddTorrent adds torrent metadata to Transmission.
 func (c *Client) AddTorrent(metainfo string) error {
 	_, err := c.request("torrent-add", map[string]interface{}{
@@ -144,6 +188,163 @@ func (c *Client) AddTorrent(metainfo string) error {
 	return err
 }
 
+// AddMagnet adds a torrent from a magnet URI; Transmission fetches the metadata itself.
+func (c *Client) AddMagnet(uri string) error {
+	_, err := c.request("torrent-add", map[string]interface{}{
+		"filename":     uri,
+		"download-dir": c.DownloadDir,
+		"paused":       false,
+	})
+	return err
+}
+
+// RemoveTorrent removes a torrent and, with deleteData, its downloaded files.
+func (c *Client) RemoveTorrent(id int, deleteData bool) error {
+	if err := c.ensureTorrent(id); err != nil {
+		return err
+	}
+
+	if _, err := c.request("torrent-remove", map[string]interface{}{
+		"ids":               []int{id},
+		"delete-local-data": deleteData,
+	}); err != nil {
+		return err
+	}
+
+	c.forgetFocus(id)
+	return nil
+}
+
+// SetPaused stops or starts a torrent.
+func (c *Client) SetPaused(id int, paused bool) error {
+	if err := c.ensureTorrent(id); err != nil {
+		return err
+	}
+
+	method := "torrent-start"
+	if paused {
+		method = "torrent-stop"
+	}
+	_, err := c.request(method, map[string]interface{}{
+		"ids": []int{id},
+	})
+	return err
+}
+
+// VerifyTorrent asks Transmission to recheck a torrent's data against its
+// piece hashes. The torrent reports check_wait and then checking meanwhile.
+func (c *Client) VerifyTorrent(id int) error {
+	if err := c.ensureTorrent(id); err != nil {
+		return err
+	}
+
+	_, err := c.request("torrent-verify", map[string]interface{}{
+		"ids": []int{id},
+	})
+	return err
+}
+
+// SetSeedRatioLimit stops seeding a torrent once it reaches ratio. A ratio of
+// 0 drops the per-torrent limit so the session's seeding settings apply.
+func (c *Client) SetSeedRatioLimit(id int, ratio float64) error {
+	if err := c.ensureTorrent(id); err != nil {
+		return err
+	}
+
+	args := map[string]interface{}{
+		"ids":           []int{id},
+		"seedRatioMode": 0,
+	}
+	if ratio > 0 {
+		args["seedRatioMode"] = 1
+		args["seedRatioLimit"] = ratio
+	}
+	_, err := c.request("torrent-set", args)
+	return err
+}
+
+// SetUploadLimit caps a torrent's upload speed in KB/s. A limit of 0 removes
+// the per-torrent cap; the session's speed limits still apply.
+func (c *Client) SetUploadLimit(id int, kbps int) error {
+	if err := c.ensureTorrent(id); err != nil {
+		return err
+	}
+
+	args := map[string]interface{}{
+		"ids":           []int{id},
+		"uploadLimited": kbps > 0,
+	}
+	if kbps > 0 {
+		args["uploadLimit"] = kbps
+	}
+	_, err := c.request("torrent-set", args)
+	return err
+}
+
+// SetFilesWanted marks files of a torrent, by index, as wanted or skipped.
+// Indices are checked against the torrent's file list first, since
+// Transmission rejects the whole call for an out-of-range index.
+func (c *Client) SetFilesWanted(id int, wanted, unwanted []int) error {
+	resp, err := c.request("torrent-get", map[string]interface{}{
+		"ids":    []int{id},
+		"fields": []string{"id", "files"},
+	})
+	if err != nil {
+		return err
+	}
+	var args struct {
+		Torrents []struct {
+			Files []json.RawMessage `json:"files"`
+		} `json:"torrents"`
+	}
+	if err := json.Unmarshal(resp.Arguments, &args); err != nil {
+		return err
+	}
+	if len(args.Torrents) == 0 {
+		return torrent.ErrNotFound
+	}
+	fileCount := len(args.Torrents[0].Files)
+	for _, index := range append(append([]int(nil), wanted...), unwanted...) {
+		if index < 0 || index >= fileCount {
+			return fmt.Errorf("%w: %d", torrent.ErrInvalidFileIndex, index)
+		}
+	}
+
+	arguments := map[string]interface{}{"ids": []int{id}}
+	if len(wanted) > 0 {
+		arguments["files-wanted"] = wanted
+	}
+	if len(unwanted) > 0 {
+		arguments["files-unwanted"] = unwanted
+	}
+	_, err = c.request("torrent-set", arguments)
+	return err
+}
+
+// ensureTorrent returns torrent.ErrNotFound unless Transmission knows the id;
+// Transmission itself silently ignores unknown ids in mutating calls.
+func (c *Client) ensureTorrent(id int) error {
+	resp, err := c.request("torrent-get", map[string]interface{}{
+		"ids":    []int{id},
+		"fields": []string{"id"},
+	})
+	if err != nil {
+		return err
+	}
+	var args struct {
+		Torrents []struct {
+			ID int `json:"id"`
+		} `json:"torrents"`
+	}
+	if err := json.Unmarshal(resp.Arguments, &args); err != nil {
+		return err
+	}
+	if len(args.Torrents) == 0 {
+		return torrent.ErrNotFound
+	}
+	return nil
+}
+
 // SetSequentialDownload toggles sequential mode for a torrent.
 func (c *Client) SetSequentialDownload(id int, enabled bool) error {
 	_, err := c.request("torrent-set", map[string]interface{}{
@@ -158,7 +359,7 @@ func (c *Client) SetSequentialDownload(id int, enabled bool) error {
 func (c *Client) SetStreamingFocus(id, fileIndex int, positionRatio float64) error {
 	mode := c.getFocusMode()
 	if mode == streamingFocusBasic {
-		return c.setBasicFocus(id, fileIndex)
+		return c.applyBasicFocus(id, fileIndex)
 	}
 
 	pieceInfo, err := c.fetchPieceInfo(id, fileIndex)
@@ -166,12 +367,12 @@ func (c *Client) SetStreamingFocus(id, fileIndex int, positionRatio float64) err
 		if isPieceInfoUnsupported(err) {
 			c.setFocusMode(streamingFocusBasic)
 		}
-		return c.setBasicFocus(id, fileIndex)
+		return c.applyBasicFocus(id, fileIndex)
 	}
 
 	startPiece, ok := pieceInfo.startPieceForRatio(positionRatio)
 	if !ok {
-		return c.setBasicFocus(id, fileIndex)
+		return c.applyBasicFocus(id, fileIndex)
 	}
 	if c.sameFocusedPiece(id, fileIndex, startPiece) {
 		return nil
@@ -189,7 +390,27 @@ func (c *Client) SetStreamingFocus(id, fileIndex int, positionRatio float64) err
 	}
 
 	c.setFocusMode(streamingFocusBasic)
-	return c.setBasicFocus(id, fileIndex)
+	return c.applyBasicFocus(id, fileIndex)
+}
+
+// FocusState reports the active focus mode and the last focused file/piece for a torrent.
+func (c *Client) FocusState(id int) (torrent.FocusState, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := torrent.FocusState{
+		TorrentID: id,
+		Mode:      c.focusMode.String(),
+	}
+	if target, ok := c.lastFocus[id]; ok {
+		fileIndex := target.fileIndex
+		state.FileIndex = &fileIndex
+		if target.piece >= 0 {
+			piece := target.piece
+			state.LastPiece = &piece
+		}
+	}
+	return state, nil
 }
 
 type streamingFocusMode uint8
@@ -200,6 +421,23 @@ const (
 	streamingFocusBasic
 )
 
+func (m streamingFocusMode) String() string {
+	switch m {
+	case streamingFocusAdvanced:
+		return "advanced"
+	case streamingFocusBasic:
+		return "basic"
+	default:
+		return "unknown"
+	}
+}
+
+// focusTarget remembers the last file (and piece, -1 when unknown) focused for a torrent.
+type focusTarget struct {
+	fileIndex int
+	piece     int
+}
+
 type pieceInfo struct {
 	length     int64
 	pieceSize  int64
@@ -339,6 +577,29 @@ func (c *Client) rememberPiece(id, fileIndex, piece int) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.lastPiece[key] = piece
+	c.lastFocus[id] = focusTarget{fileIndex: fileIndex, piece: piece}
+}
+
+func (c *Client) forgetFocus(id int) {
+	prefix := fmt.Sprintf("%d:", id)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.lastPiece {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.lastPiece, key)
+		}
+	}
+	delete(c.lastFocus, id)
+}
+
+func (c *Client) applyBasicFocus(id, fileIndex int) error {
+	if err := c.setBasicFocus(id, fileIndex); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastFocus[id] = focusTarget{fileIndex: fileIndex, piece: -1}
+	return nil
 }
 
 func (c *Client) setBasicFocus(id, fileIndex int) error {
@@ -388,10 +649,15 @@ type response struct {
 	Arguments json.RawMessage `json:"arguments"`
 }
 
-func (c *Client) request(method string, arguments map[string]interface{}) (response, error) {
+func (c *Client) request(method string, arguments map[string]interface{}) (_ response, err error) {
 	if !c.Enabled() {
 		return response{}, errors.New("Transmission is not configured")
 	}
+	defer func() {
+		if err != nil && c.OnRPCError != nil {
+			c.OnRPCError(method)
+		}
+	}()
 
 	payload := map[string]interface{}{
 		"method":    method,
@@ -0,0 +1,308 @@
+package transmission
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"evd/internal/domain/torrent"
+)
+
+type rpcCall struct {
+	Method    string                 `json:"method"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+func newRPCServer(t *testing.T, handle func(call rpcCall) (string, interface{})) (*httptest.Server, *[]rpcCall) {
+	t.Helper()
+	calls := &[]rpcCall{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var call rpcCall
+		if err := json.NewDecoder(r.Body).Decode(&call); err != nil {
+			t.Errorf("decode rpc call: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		*calls = append(*calls, call)
+		result, args := handle(call)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"result":    result,
+			"arguments": args,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, calls
+}
+
+func TestFocusState_ReportsBasicAfterFallback(t *testing.T) {
+	server, _ := newRPCServer(t, func(call rpcCall) (string, interface{}) {
+		if call.Method == "torrent-get" {
+			// Older Transmission versions don't report piece boundaries.
+			return "success", map[string]interface{}{
+				"torrents": []map[string]interface{}{
+					{"pieceSize": 1 << 20, "files": []map[string]interface{}{{"length": 1 << 30}}},
+				},
+			}
+		}
+		return "success", map[string]interface{}{}
+	})
+	client := NewClient(server.URL, "", "", "/downloads", nil)
+
+	state, err := client.FocusState(3)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if state.Mode != "unknown" || state.FileIndex != nil {
+		t.Fatalf("expected unknown focus before any request, got %+v", state)
+	}
+
+	if err := client.SetStreamingFocus(3, 0, 0.5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	state, err = client.FocusState(3)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if state.Mode != "basic" {
+		t.Fatalf("expected basic mode, got %q", state.Mode)
+	}
+	if state.FileIndex == nil || *state.FileIndex != 0 {
+		t.Fatalf("expected focused file 0, got %+v", state.FileIndex)
+	}
+	if state.LastPiece != nil {
+		t.Fatalf("expected no piece in basic mode, got %d", *state.LastPiece)
+	}
+}
+
+func TestFocusState_ReportsAdvancedPiece(t *testing.T) {
+	server, _ := newRPCServer(t, func(call rpcCall) (string, interface{}) {
+		if call.Method == "torrent-get" {
+			return "success", map[string]interface{}{
+				"torrents": []map[string]interface{}{
+					{"pieceSize": 100, "files": []map[string]interface{}{
+						{"length": 1000, "beginPiece": 10, "endPiece": 19},
+					}},
+				},
+			}
+		}
+		return "success", map[string]interface{}{}
+	})
+	client := NewClient(server.URL, "", "", "/downloads", nil)
+
+	if err := client.SetStreamingFocus(8, 0, 0.5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	state, _ := client.FocusState(8)
+	if state.Mode != "advanced" {
+		t.Fatalf("expected advanced mode, got %q", state.Mode)
+	}
+	if state.LastPiece == nil || *state.LastPiece != 14 {
+		t.Fatalf("expected last piece 14, got %+v", state.LastPiece)
+	}
+}
+
+func TestRemoveTorrent_DeletesDataAndReportsMissing(t *testing.T) {
+	server, calls := newRPCServer(t, func(call rpcCall) (string, interface{}) {
+		if call.Method == "torrent-get" {
+			ids, _ := call.Arguments["ids"].([]interface{})
+			if len(ids) == 1 && ids[0] == float64(5) {
+				return "success", map[string]interface{}{"torrents": []map[string]interface{}{{"id": 5}}}
+			}
+			return "success", map[string]interface{}{"torrents": []map[string]interface{}{}}
+		}
+		return "success", map[string]interface{}{}
+	})
+	client := NewClient(server.URL, "", "", "/downloads", nil)
+
+	if err := client.RemoveTorrent(5, true); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	last := (*calls)[len(*calls)-1]
+	if last.Method != "torrent-remove" || last.Arguments["delete-local-data"] != true {
+		t.Fatalf("expected torrent-remove with delete-local-data, got %+v", last)
+	}
+
+	if err := client.RemoveTorrent(9, false); !errors.Is(err, torrent.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for unknown torrent, got %v", err)
+	}
+}
+
+func TestSetPaused_UsesStopAndStart(t *testing.T) {
+	server, calls := newRPCServer(t, func(call rpcCall) (string, interface{}) {
+		if call.Method == "torrent-get" {
+			return "success", map[string]interface{}{"torrents": []map[string]interface{}{{"id": 2}}}
+		}
+		return "success", map[string]interface{}{}
+	})
+	client := NewClient(server.URL, "", "", "/downloads", nil)
+
+	for paused, want := range map[bool]string{true: "torrent-stop", false: "torrent-start"} {
+		if err := client.SetPaused(2, paused); err != nil {
+			t.Fatalf("set paused %v: %v", paused, err)
+		}
+		if got := (*calls)[len(*calls)-1].Method; got != want {
+			t.Fatalf("paused=%v: expected %s, got %s", paused, want, got)
+		}
+	}
+}
+
+func TestVerifyTorrent_SendsVerify(t *testing.T) {
+	server, calls := newRPCServer(t, func(call rpcCall) (string, interface{}) {
+		if call.Method == "torrent-get" {
+			if ids, _ := call.Arguments["ids"].([]interface{}); len(ids) == 1 && ids[0] == float64(3) {
+				return "success", map[string]interface{}{"torrents": []map[string]interface{}{{"id": 3}}}
+			}
+			return "success", map[string]interface{}{"torrents": []interface{}{}}
+		}
+		return "success", map[string]interface{}{}
+	})
+	client := NewClient(server.URL, "", "", "/downloads", nil)
+
+	if err := client.VerifyTorrent(9); !errors.Is(err, torrent.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for unknown torrent, got %v", err)
+	}
+	if err := client.VerifyTorrent(3); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if got := (*calls)[len(*calls)-1].Method; got != "torrent-verify" {
+		t.Fatalf("expected torrent-verify, got %s", got)
+	}
+}
+
+func TestSetLimits_SendsTorrentSetFields(t *testing.T) {
+	server, calls := newRPCServer(t, func(call rpcCall) (string, interface{}) {
+		if call.Method == "torrent-get" {
+			return "success", map[string]interface{}{"torrents": []map[string]interface{}{{"id": 6}}}
+		}
+		return "success", map[string]interface{}{}
+	})
+	client := NewClient(server.URL, "", "", "/downloads", nil)
+	lastSet := func() map[string]interface{} {
+		call := (*calls)[len(*calls)-1]
+		if call.Method != "torrent-set" {
+			t.Fatalf("expected torrent-set, got %s", call.Method)
+		}
+		return call.Arguments
+	}
+
+	if err := client.SetSeedRatioLimit(6, 1.5); err != nil {
+		t.Fatalf("set seed ratio: %v", err)
+	}
+	if args := lastSet(); args["seedRatioMode"] != float64(1) || args["seedRatioLimit"] != 1.5 {
+		t.Fatalf("unexpected seed ratio args: %v", args)
+	}
+	if err := client.SetSeedRatioLimit(6, 0); err != nil {
+		t.Fatalf("clear seed ratio: %v", err)
+	}
+	if args := lastSet(); args["seedRatioMode"] != float64(0) || args["seedRatioLimit"] != nil {
+		t.Fatalf("unexpected cleared seed ratio args: %v", args)
+	}
+
+	if err := client.SetUploadLimit(6, 512); err != nil {
+		t.Fatalf("set upload limit: %v", err)
+	}
+	if args := lastSet(); args["uploadLimited"] != true || args["uploadLimit"] != float64(512) {
+		t.Fatalf("unexpected upload limit args: %v", args)
+	}
+	if err := client.SetUploadLimit(6, 0); err != nil {
+		t.Fatalf("clear upload limit: %v", err)
+	}
+	if args := lastSet(); args["uploadLimited"] != false || args["uploadLimit"] != nil {
+		t.Fatalf("unexpected cleared upload limit args: %v", args)
+	}
+}
+
+func TestRequest_ReportsFailedRPCs(t *testing.T) {
+	server, _ := newRPCServer(t, func(call rpcCall) (string, interface{}) {
+		if call.Method == "torrent-get" {
+			return "success", map[string]interface{}{"torrents": []interface{}{}}
+		}
+		return "duplicate torrent", nil
+	})
+	client := NewClient(server.URL, "", "", "/downloads", nil)
+	var failed []string
+	client.OnRPCError = func(method string) { failed = append(failed, method) }
+
+	if _, err := client.List(); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if err := client.AddMagnet("magnet:?xt=urn:btih:abc"); err == nil {
+		t.Fatalf("expected add to fail")
+	}
+	if len(failed) != 1 || failed[0] != "torrent-add" {
+		t.Fatalf("expected only the failed add reported, got %v", failed)
+	}
+}
+
+func TestSessionStats_MapsRates(t *testing.T) {
+	server, calls := newRPCServer(t, func(call rpcCall) (string, interface{}) {
+		return "success", map[string]interface{}{
+			"downloadSpeed":      2048,
+			"uploadSpeed":        512,
+			"torrentCount":       3,
+			"activeTorrentCount": 2,
+			"pausedTorrentCount": 1,
+			"current-stats":      map[string]interface{}{"downloadedBytes": 100, "uploadedBytes": 10, "secondsActive": 60, "sessionCount": 1},
+			"cumulative-stats":   map[string]interface{}{"downloadedBytes": 5000, "uploadedBytes": 700, "filesAdded": 9, "sessionCount": 4},
+		}
+	})
+	client := NewClient(server.URL, "", "", "/downloads", nil)
+
+	stats, err := client.SessionStats()
+	if err != nil {
+		t.Fatalf("session stats: %v", err)
+	}
+	if len(*calls) != 1 || (*calls)[0].Method != "session-stats" {
+		t.Fatalf("expected one session-stats call, got %+v", *calls)
+	}
+	want := torrent.SessionStats{
+		DownloadSpeed:      2048,
+		UploadSpeed:        512,
+		TorrentCount:       3,
+		ActiveTorrentCount: 2,
+		PausedTorrentCount: 1,
+		Current:            torrent.TransferStats{DownloadedBytes: 100, UploadedBytes: 10, SecondsActive: 60, SessionCount: 1},
+		Cumulative:         torrent.TransferStats{DownloadedBytes: 5000, UploadedBytes: 700, FilesAdded: 9, SessionCount: 4},
+	}
+	if stats != want {
+		t.Fatalf("stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestSetFilesWanted_ChecksIndicesAndSetsFields(t *testing.T) {
+	server, calls := newRPCServer(t, func(call rpcCall) (string, interface{}) {
+		if call.Method == "torrent-get" {
+			return "success", map[string]interface{}{
+				"torrents": []map[string]interface{}{
+					{"id": 4, "files": []map[string]interface{}{{"name": "a.mkv"}, {"name": "sample.mkv"}, {"name": "info.nfo"}}},
+				},
+			}
+		}
+		return "success", map[string]interface{}{}
+	})
+	client := NewClient(server.URL, "", "", "/downloads", nil)
+
+	if err := client.SetFilesWanted(4, []int{0}, []int{3}); !errors.Is(err, torrent.ErrInvalidFileIndex) {
+		t.Fatalf("expected out-of-range index to be rejected, got %v", err)
+	}
+	if len(*calls) != 1 {
+		t.Fatalf("expected no torrent-set after a bad index, got %+v", *calls)
+	}
+
+	if err := client.SetFilesWanted(4, []int{0}, []int{1, 2}); err != nil {
+		t.Fatalf("set files wanted: %v", err)
+	}
+	set := (*calls)[len(*calls)-1]
+	if set.Method != "torrent-set" {
+		t.Fatalf("expected torrent-set, got %s", set.Method)
+	}
+	wanted, _ := set.Arguments["files-wanted"].([]interface{})
+	unwanted, _ := set.Arguments["files-unwanted"].([]interface{})
+	if len(wanted) != 1 || wanted[0] != 0.0 || len(unwanted) != 2 || unwanted[0] != 1.0 || unwanted[1] != 2.0 {
+		t.Fatalf("unexpected torrent-set arguments: %+v", set.Arguments)
+	}
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// countingWriter records the status code and body bytes actually written to the client.
+type countingWriter struct {
+	http.ResponseWriter
+	status  int
+	bytes   int64
+	metrics StreamMetrics
+}
+
+func (c *countingWriter) WriteHeader(code int) {
+	if c.status == 0 {
+		c.status = code
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	n, err := c.ResponseWriter.Write(p)
+	c.bytes += int64(n)
+	if c.metrics != nil {
+		c.metrics.StreamBytes(int64(n))
+	}
+	return n, err
+}
+
+func (c *countingWriter) Flush() {
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (c *countingWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// servedBytes aggregates streamed bytes per user since process start.
+type servedBytes struct {
+	mu     sync.Mutex
+	byUser map[string]int64
+}
+
+func newServedBytes() *servedBytes {
+	return &servedBytes{byUser: map[string]int64{}}
+}
+
+func (s *servedBytes) add(username string, n int64) {
+	if n <= 0 {
+		return
+	}
+	s.mu.Lock()
+	s.byUser[username] += n
+	s.mu.Unlock()
+}
+
+// userBytes is a per-user served-bytes total for reporting.
+type userBytes struct {
+	User  string `json:"user"`
+	Bytes int64  `json:"bytes"`
+}
+
+func (s *servedBytes) list() []userBytes {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]userBytes, 0, len(s.byUser))
+	for user, n := range s.byUser {
+		out = append(out, userBytes{User: user, Bytes: n})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].User < out[j].User })
+	return out
+}
+
+// EnableAccessLog turns on per-request stream logging to logger.
+func (h *Handler) EnableAccessLog(logger *log.Logger) {
+	h.accessLog = logger
+}
+
+// StreamAccessLog rejects callers over their monthly streaming quota, counts bytes
+// delivered by a streaming handler, adds them to the per-user totals, quota usage
+// and stream metrics and, when the access log is enabled, logs the finished stream.
+// It must run after RequireAuth.
+func (h *Handler) StreamAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := requestUser(r)
+		if err := h.quotas.CheckStream(user.ID); err != nil {
+			http.Error(w, "Monthly streaming quota exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		started := time.Now()
+		counter := &countingWriter{ResponseWriter: w, metrics: h.metrics}
+		if h.metrics != nil {
+			h.metrics.StreamOpened()
+			defer h.metrics.StreamClosed()
+		}
+		next.ServeHTTP(counter, r)
+
+		username := user.Username
+		h.served.add(username, counter.bytes)
+		h.quotas.AddStreamed(user.ID, username, counter.bytes)
+
+		if h.accessLog != nil {
+			h.accessLog.Printf("stream %s %s user=%q status=%d bytes=%d range=%q duration=%s",
+				r.Method, r.URL.Path, username, counter.status, counter.bytes,
+				r.Header.Get("Range"), time.Since(started).Round(time.Millisecond))
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"net/http"
+
+	mediadomain "evd/internal/domain/media"
+)
+
+// ClientConfig holds the non-sensitive runtime settings published to the frontend.
+// It must never carry secrets, credentials or filesystem paths.
+type ClientConfig struct {
+	HLSSegmentSeconds int
+	HLSFormat         mediadomain.HLSFormat
+	IngestEnabled     bool
+	// GuestAllowed reports whether anonymous guest sessions may be started.
+	GuestAllowed bool
+	// MaxUploadBytes is the largest file a single upload may produce; 0 means unlimited.
+	MaxUploadBytes int64
+	// MonthlyStreamBytes is the default per-user streaming quota; 0 means unlimited.
+	MonthlyStreamBytes int64
+}
+
+// clientConfigResponse is the GET /api/config body.
+type clientConfigResponse struct {
+	GuestAllowed       bool            `json:"guestAllowed"`
+	TorrentsEnabled    bool            `json:"torrentsEnabled"`
+	AllowedExtensions  []string        `json:"allowedExtensions"`
+	MaxUploadBytes     int64           `json:"maxUploadBytes"`
+	UploadChunkBytes   int64           `json:"uploadChunkBytes"`
+	MonthlyStreamBytes int64           `json:"monthlyStreamBytes"`
+	HLSSegmentSeconds  int             `json:"hlsSegmentSeconds"`
+	HLSFormat          string          `json:"hlsFormat"`
+	Features           map[string]bool `json:"features"`
+}
+
+// SetClientConfig sets the settings served by ClientConfig.
+func (h *Handler) SetClientConfig(cfg ClientConfig) {
+	h.client = cfg
+}
+
+// ClientConfig returns the runtime settings the frontend needs to configure itself.
+// It is public, so only values safe for unauthenticated callers belong here.
+func (h *Handler) ClientConfig(w http.ResponseWriter, _ *http.Request) {
+	torrentsEnabled := h.torrents != nil && h.torrents.Enabled()
+	format := h.client.HLSFormat
+	if format == "" {
+		format = mediadomain.HLSFormatTS
+	}
+
+	writeJSON(w, clientConfigResponse{
+		GuestAllowed:       h.client.GuestAllowed,
+		TorrentsEnabled:    torrentsEnabled,
+		AllowedExtensions:  mediadomain.SupportedVideoExts(),
+		MaxUploadBytes:     h.client.MaxUploadBytes,
+		UploadChunkBytes:   maxUploadChunkBytes,
+		MonthlyStreamBytes: h.client.MonthlyStreamBytes,
+		HLSSegmentSeconds:  h.client.HLSSegmentSeconds,
+		HLSFormat:          string(format),
+		Features: map[string]bool{
+			"torrents":   torrentsEnabled,
+			"ingest":     h.client.IngestEnabled,
+			"watchParty": h.watch != nil,
+			"fmp4":       true,
+			"quotas":     h.quotas != nil,
+		},
+	})
+}
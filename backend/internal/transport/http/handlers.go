@@ -6,17 +6,24 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"math"
-	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	authapp "evd/internal/application/auth"
+	mediaapp "evd/internal/application/media"
+	progressapp "evd/internal/application/progress"
+	quotaapp "evd/internal/application/quota"
+	torrentapp "evd/internal/application/torrent"
+	uploadapp "evd/internal/application/upload"
 	watchpartyapp "evd/internal/application/watchparty"
 	mediadomain "evd/internal/domain/media"
 	torrentdomain "evd/internal/domain/torrent"
@@ -25,25 +32,78 @@ import (
 
 type mediaUseCases interface {
 	ListVideos() ([]mediadomain.Video, error)
-	StartHLS(ctx context.Context, rawPath string, follow bool) (mediadomain.JobStatus, error)
-	HLSStatus(rawPath string) (mediadomain.JobStatus, error)
-	StartMP4(ctx context.Context, rawPath string) (mediadomain.JobStatus, error)
-	MP4Status(rawPath string) (mediadomain.JobStatus, error)
+	StartHLS(ctx context.Context, rawPath string, follow bool, format mediadomain.HLSFormat, audio int, idempotencyKey string) (mediadomain.JobStatus, error)
+	RedoHLS(ctx context.Context, rawPath string, format mediadomain.HLSFormat, audio int, idempotencyKey string) (mediadomain.JobStatus, error)
+	HLSStatus(rawPath string, format mediadomain.HLSFormat, audio int) (mediadomain.JobStatus, error)
+	PauseHLS(rawPath string, format mediadomain.HLSFormat, audio int) (mediadomain.JobStatus, error)
+	ResumeHLS(rawPath string, format mediadomain.HLSFormat, audio int) (mediadomain.JobStatus, error)
+	StartMP4(ctx context.Context, rawPath string, audio int, subs mediadomain.SubtitleSelection, idempotencyKey string) (mediadomain.JobStatus, error)
+	RedoMP4(ctx context.Context, rawPath string, audio int, subs mediadomain.SubtitleSelection, idempotencyKey string) (mediadomain.JobStatus, error)
+	MP4Status(rawPath string, audio int, subs mediadomain.SubtitleSelection) (mediadomain.JobStatus, error)
+	PauseMP4(rawPath string, audio int, subs mediadomain.SubtitleSelection) (mediadomain.JobStatus, error)
+	CancelJob(rawPath string, jobType mediadomain.JobType, audio int, subs mediadomain.SubtitleSelection) error
 	StreamMP4(ctx context.Context, rawPath string, follow bool, out io.Writer) error
+	ActiveJobs() []mediadomain.JobInfo
+	ArtifactStatuses(rawPaths []string) []mediadomain.ArtifactStatus
+	Ingest(ctx context.Context, rawURL, rawName string) (string, mediadomain.JobStatus, error)
+	IngestStatus(rawPath string) (mediadomain.JobStatus, error)
+	Thumbnail(ctx context.Context, rawPath string) (string, error)
+	Probe(ctx context.Context, rawPath string) (mediadomain.MediaInfo, error)
+	TrimHLS(rawPath string, format mediadomain.HLSFormat, before int) (int, error)
+	ClearArtifacts(rawPath string, clearHLS, clearMP4 bool) error
+	MoveVideo(rawFrom, rawTo string) (string, error)
+	DeleteVideo(rawPath string) (string, error)
+	MarkServed(path string)
 }
 
 type torrentUseCases interface {
 	Enabled() bool
 	List() ([]torrentdomain.Info, error)
+	SessionStats() (torrentdomain.SessionStats, error)
 	AddTorrent(r io.Reader) error
+	AddMagnet(uri string) error
+	RemoveTorrent(id int, deleteData bool) error
+	SetPaused(id int, paused bool) error
+	VerifyTorrent(id int) error
+	SetLimits(id int, seedRatio *float64, uploadKbps *int) error
+	SetFilesWanted(id int, wanted, unwanted []int) error
 	EnableStreaming(id int) error
 	SetStreamingFocus(id, fileIndex int, currentTime, duration float64) error
+	FocusState(id int) (torrentdomain.FocusState, error)
 }
 
 type mediaPathStore interface {
 	ResolveVideoPath(raw string) (string, string, error)
 	MP4Paths(relPath string) (string, string, string)
-	VideosRoot() string
+	HLSFileName(name string) string
+	DiskUsage() ([]mediadomain.DiskUsage, error)
+	ListDir(relDir string) ([]mediadomain.Entry, error)
+	SearchVideos(query string) ([]mediadomain.Video, error)
+	ListTrash() ([]mediadomain.TrashedVideo, error)
+	RestoreFromTrash(name string) (string, error)
+}
+
+type uploadUseCases interface {
+	WriteChunk(rawName string, chunkIndex, totalChunks int, checksum string, chunk io.Reader) (string, bool, error)
+	Cancel(rawName string) error
+	PartialSize(rawName string) int64
+	Status(rawName string) (uploadapp.Progress, error)
+	Limits() uploadapp.Limits
+}
+
+type quotaUseCases interface {
+	CheckUpload(userID string, additionalBytes int64) error
+	RecordUpload(userID, username, relPath string, size int64) error
+	ReleaseFile(relPath string) error
+	MoveFile(fromPath, toPath string) error
+	TrashFile(relPath, trashName string) error
+	CheckRestore(trashName string) error
+	RestoreFile(trashName, relPath string) error
+	CheckStream(userID string) error
+	AddStreamed(userID, username string, n int64)
+	Usage(userID string) quotaapp.Usage
+	List() []quotaapp.Usage
+	SetLimits(userID string, storageBytes, streamBytes *int64) (quotaapp.Usage, error)
 }
 
 type authUseCases interface {
@@ -53,26 +113,92 @@ type authUseCases interface {
 	Authenticate(token string) (authapp.User, error)
 	Logout(token string)
 	SessionTTL() time.Duration
+	SetSessionTTL(ttl time.Duration) error
+	PurgeSessions(exceptToken string) int
+	IsAdmin(user authapp.User) bool
+	SetRole(userID, role string) (authapp.User, error)
+	ChangePassword(userID, oldPassword, newPassword string) error
+	RevokeUserSessions(userID, exceptToken string) int
+	ListSessions(userID string) []authapp.SessionInfo
+	RevokeAllSessions(userID string) int
 }
 
 type watchPartyUseCases interface {
-	CreateHub(ownerID, ownerName, videoPath string, currentTime float64, playing bool) (watchpartyapp.Snapshot, error)
+	CreateHub(ownerID, ownerName, kind, videoPath string, currentTime float64, playing bool, password string) (watchpartyapp.Snapshot, error)
+	Authorize(hubID, userID, key string) error
 	GetHub(hubID string) (watchpartyapp.Snapshot, error)
+	ListHubsForUser(userID string) []watchpartyapp.Snapshot
 	Subscribe(hubID, userID, username string) (<-chan watchpartyapp.Event, func(), error)
+	SubscribeAfter(hubID, userID, username string, lastSeq uint64) (<-chan watchpartyapp.Event, func(), error)
 	Control(hubID, userID, username string, input watchpartyapp.ControlInput) (watchpartyapp.Event, error)
 	Chat(hubID, userID, username, text string) (watchpartyapp.Event, error)
+	Typing(hubID, userID, username string) error
+	React(hubID, userID, username, emoji string) error
+	Kick(hubID, ownerID, targetUserID string) error
+	TransferOwnership(hubID, currentOwnerID, newOwnerID string) error
+	SetPreparing(hubID string, preparing bool) error
 }
 
 type Handler struct {
 	media    mediaUseCases
 	torrents torrentUseCases
 	store    mediaPathStore
+	uploads  uploadUseCases
 	auth     authUseCases
 	watch    watchPartyUseCases
+	quotas   quotaUseCases
+	streams  *streamRegistry
+
+	served        *servedBytes
+	accessLog     *log.Logger
+	client        ClientConfig
+	userLibraries bool
+	statusPage    bool
+	readiness     []ReadinessCheck
+
+	metrics         StreamMetrics
+	metricsEndpoint http.Handler
+
+	progress ProgressTracker
+
+	// streamBytesPerSec caps each direct stream; 0 leaves them unthrottled.
+	streamBytesPerSec int64
+
+	authLimiter       *rateLimiter
+	trustProxyHeaders bool
 }
 
 const sessionCookieName = "evd_session"
 
+const maxIdempotencyKeyLength = 128
+
+// watchPreparePollInterval and watchPrepareTimeout pace the readiness check of
+// quickstart hubs.
+var (
+	watchPreparePollInterval = 2 * time.Second
+	watchPrepareTimeout      = 12 * time.Hour
+)
+
+// maxUploadChunkBytes is the multipart memory budget for a single upload chunk.
+const maxUploadChunkBytes = 10 << 20
+
+// uploadFormOverhead is allowed on top of the chunk size limit for the other
+// multipart fields and part headers of an upload request.
+const uploadFormOverhead = 64 << 10
+
+// maxArtifactStatusPaths bounds a single batch artifact status request.
+const maxArtifactStatusPaths = 500
+
+// Video search returns defaultSearchResults matches unless ?limit= asks for up
+// to maxSearchResults.
+const (
+	defaultSearchResults = 50
+	maxSearchResults     = 200
+)
+
+// growingStreamIdleTimeout ends follow-mode direct streams once the source stops growing.
+const growingStreamIdleTimeout = 2 * time.Minute
+
 type contextKey string
 
 const userContextKey contextKey = "user"
@@ -82,15 +208,21 @@ func NewHandler(
 	mediaService mediaUseCases,
 	torrentService torrentUseCases,
 	store mediaPathStore,
+	uploadService uploadUseCases,
 	authService authUseCases,
 	watchService watchPartyUseCases,
+	quotaService quotaUseCases,
 ) *Handler {
 	return &Handler{
 		media:    mediaService,
 		torrents: torrentService,
 		store:    store,
+		uploads:  uploadService,
 		auth:     authService,
 		watch:    watchService,
+		quotas:   quotaService,
+		streams:  newStreamRegistry(),
+		served:   newServedBytes(),
 	}
 }
 
@@ -119,6 +251,51 @@ func (h *Handler) RequireAuth(next http.Handler) http.Handler {
 	})
 }
 
+// RequireAdmin rejects requests from users without administrative rights.
+// It must run after RequireAuth.
+func (h *Handler) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, ok := requestUser(r)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !h.auth.IsAdmin(user) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireLibraryOwner guards changes to library files. With per-user libraries
+// every path is scoped to the caller's own folder, so any user may pass;
+// otherwise the library is shared and the change needs an admin. It must run
+// after RequireAuth.
+func (h *Handler) RequireLibraryOwner(next http.Handler) http.Handler {
+	admin := h.RequireAdmin(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.userLibraries {
+			next.ServeHTTP(w, r)
+			return
+		}
+		admin.ServeHTTP(w, r)
+	})
+}
+
+// isAdmin reports whether the request comes from an admin, for handlers that
+// only gate some of their options.
+func (h *Handler) isAdmin(r *http.Request) bool {
+	user, ok := requestUser(r)
+	return ok && h.auth.IsAdmin(user)
+}
+
 // Register handles account registration and starts a session.
 func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	var payload credentialsRequest
@@ -153,12 +330,17 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid payload", http.StatusBadRequest)
 		return
 	}
+	if !h.allowAuthAttempt(w, "user:"+strings.ToLower(strings.TrimSpace(payload.Username))) {
+		return
+	}
 
 	user, sessionToken, err := h.auth.Login(payload.Username, payload.Password)
 	if err != nil {
 		switch {
 		case errors.Is(err, authapp.ErrInvalidCredentials):
 			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, authapp.ErrAccountLocked):
+			http.Error(w, err.Error(), http.StatusLocked)
 		default:
 			http.Error(w, "Unable to login", http.StatusInternalServerError)
 		}
@@ -171,8 +353,12 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// LoginGuest starts an anonymous guest session.
+// LoginGuest starts an anonymous guest session unless guest login is disabled.
 func (h *Handler) LoginGuest(w http.ResponseWriter, _ *http.Request) {
+	if !h.client.GuestAllowed {
+		http.Error(w, "Guest login is disabled", http.StatusForbidden)
+		return
+	}
 	user, sessionToken, err := h.auth.LoginGuest()
 	if err != nil {
 		http.Error(w, "Unable to login as guest", http.StatusInternalServerError)
@@ -215,214 +401,1245 @@ func (h *Handler) Me(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// ListVideos handles GET /api/videos.
-func (h *Handler) ListVideos(w http.ResponseWriter, r *http.Request) {
-	videos, err := h.media.ListVideos()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+// ChangePassword updates the caller's password and signs out their other sessions.
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	resp := make([]map[string]interface{}, 0, len(videos))
-	for _, v := range videos {
-		resp = append(resp, map[string]interface{}{
-			"name":       v.Name,
-			"path":       v.Path,
-			"size":       v.Size,
-			"modifiedAt": v.ModifiedAt.Unix(),
-		})
+	var payload changePasswordRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(resp)
+	if err := h.auth.ChangePassword(user.ID, payload.OldPassword, payload.NewPassword); err != nil {
+		switch {
+		case errors.Is(err, authapp.ErrGuestAccount), errors.Is(err, authapp.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, authapp.ErrInvalidCredentials):
+			http.Error(w, "Current password is incorrect", http.StatusForbidden)
+		case errors.Is(err, authapp.ErrUnauthorized):
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		default:
+			http.Error(w, "Unable to change password", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	revoked := h.auth.RevokeUserSessions(user.ID, sessionTokenFromRequest(r))
+	writeJSON(w, map[string]interface{}{
+		"status":          "ok",
+		"revokedSessions": revoked,
+	})
 }
 
-// StreamVideo handles direct file streaming endpoint.
-func (h *Handler) StreamVideo(w http.ResponseWriter, r *http.Request) {
-	_, full, err := h.store.ResolveVideoPath(getPathParam(r))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+// ListSessions returns the caller's active sessions, marking the one making the request.
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(full)))
-	if contentType == "" {
-		contentType = "application/octet-stream"
+	currentID := authapp.SessionID(sessionTokenFromRequest(r))
+	sessions := h.auth.ListSessions(user.ID)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
 	}
-	streamFile(w, r, full, contentType)
+
+	writeJSON(w, map[string]interface{}{
+		"items": sessions,
+	})
 }
 
-// StreamPlay handles ffmpeg-based live mp4 stream endpoint.
-func (h *Handler) StreamPlay(w http.ResponseWriter, r *http.Request) {
-	follow := r.URL.Query().Get("follow") == "1"
-	path := getPathParam(r)
-	if path == "" {
-		http.Error(w, "invalid path", http.StatusBadRequest)
+// RevokeAllSessions signs the caller out of every session. With `keepCurrent=1`
+// the session making the request survives.
+func (h *Handler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	w.Header().Set("Content-Type", "video/mp4")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("X-Accel-Buffering", "no")
+	var revoked int
+	if r.URL.Query().Get("keepCurrent") == "1" {
+		revoked = h.auth.RevokeUserSessions(user.ID, sessionTokenFromRequest(r))
+	} else {
+		revoked = h.auth.RevokeAllSessions(user.ID)
+		clearSessionCookie(w)
+	}
 
-	_ = h.media.StreamMP4(r.Context(), path, follow, w)
+	writeJSON(w, map[string]interface{}{
+		"status":          "ok",
+		"revokedSessions": revoked,
+	})
 }
 
-// StreamMP4 handles seekable mp4 output endpoint.
-func (h *Handler) StreamMP4(w http.ResponseWriter, r *http.Request) {
-	rel, _, err := h.store.ResolveVideoPath(getPathParam(r))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	if strings.ToLower(filepath.Ext(rel)) == ".mp4" {
-		http.Error(w, "Unsupported file type", http.StatusBadRequest)
+// Usage returns the caller's storage and monthly streaming usage against their limits.
+func (h *Handler) Usage(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-	_, outputPath, _ := h.store.MP4Paths(rel)
-	status, err := h.media.MP4Status(rel)
-	if err != nil || !status.Ready {
-		http.Error(w, "MP4 not ready", http.StatusNotFound)
+
+	writeJSON(w, h.quotas.Usage(user.ID))
+}
+
+// AdminQuotas lists quota usage of every tracked user.
+func (h *Handler) AdminQuotas(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"items": h.quotas.List(),
+	})
+}
+
+// AdminSetQuota overrides a user's limits. Null restores the default, zero means unlimited.
+func (h *Handler) AdminSetQuota(w http.ResponseWriter, r *http.Request) {
+	var payload quotaLimitsRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
 		return
 	}
-	streamFile(w, r, outputPath, "video/mp4")
-}
 
-// StartHLS handles HLS conversion kickoff endpoint.
-func (h *Handler) StartHLS(w http.ResponseWriter, r *http.Request) {
-	follow := r.URL.Query().Get("follow") == "1"
-	status, err := h.media.StartHLS(r.Context(), getPathParam(r), follow)
+	usage, err := h.quotas.SetLimits(mux.Vars(r)["userId"], payload.StorageLimitBytes, payload.StreamLimitBytes)
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			http.Error(w, "Video not found", http.StatusNotFound)
-			return
+		switch {
+		case errors.Is(err, quotaapp.ErrInvalidLimit):
+			http.Error(w, "Invalid quota limit", http.StatusBadRequest)
+		default:
+			http.Error(w, "Unable to update quota", http.StatusInternalServerError)
 		}
-		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{
-		"status": string(status.State),
-		"url":    status.URL,
-	})
+	writeJSON(w, usage)
 }
 
-// HLSStatus handles HLS conversion status endpoint.
-func (h *Handler) HLSStatus(w http.ResponseWriter, r *http.Request) {
-	status, err := h.media.HLSStatus(getPathParam(r))
+// AdminSetUserRole promotes or demotes a registered user given {"role"}.
+func (h *Handler) AdminSetUserRole(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Role string `json:"role"`
+	}
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.auth.SetRole(mux.Vars(r)["id"], payload.Role)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		switch {
+		case errors.Is(err, authapp.ErrInvalidInput):
+			http.Error(w, "Invalid role", http.StatusBadRequest)
+		case errors.Is(err, authapp.ErrUserNotFound), errors.Is(err, authapp.ErrGuestAccount):
+			http.Error(w, "User not found", http.StatusNotFound)
+		case errors.Is(err, authapp.ErrLastAdmin):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "Unable to update role", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]interface{}{
-		"ready":      status.Ready,
-		"processing": status.Processing,
-		"segments":   status.Segments,
-		"url":        status.URL,
-		"state":      status.State,
-		"error":      status.Error,
+	writeJSON(w, map[string]interface{}{"user": user})
+}
+
+// AdminSessionTTL returns the effective session lifetime.
+func (h *Handler) AdminSessionTTL(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"ttlSeconds": int64(h.auth.SessionTTL() / time.Second),
 	})
 }
 
-// StartMP4 handles mp4 conversion kickoff endpoint.
-func (h *Handler) StartMP4(w http.ResponseWriter, r *http.Request) {
-	status, err := h.media.StartMP4(r.Context(), getPathParam(r))
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			http.Error(w, "Video not found", http.StatusNotFound)
-			return
+// AdminSetSessionTTL changes the session lifetime without a restart.
+func (h *Handler) AdminSetSessionTTL(w http.ResponseWriter, r *http.Request) {
+	var payload sessionTTLRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.auth.SetSessionTTL(time.Duration(payload.TTLSeconds) * time.Second); err != nil {
+		switch {
+		case errors.Is(err, authapp.ErrInvalidInput):
+			http.Error(w, "Invalid session TTL", http.StatusBadRequest)
+		default:
+			http.Error(w, "Unable to update session TTL", http.StatusInternalServerError)
 		}
-		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{
-		"status": string(status.State),
-		"url":    status.URL,
+	writeJSON(w, map[string]interface{}{
+		"ttlSeconds": int64(h.auth.SessionTTL() / time.Second),
 	})
 }
 
-// MP4Status handles mp4 conversion status endpoint.
-func (h *Handler) MP4Status(w http.ResponseWriter, r *http.Request) {
-	status, err := h.media.MP4Status(getPathParam(r))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+// AdminPurgeSessions logs out every session; `exceptSelf=1` keeps the caller signed in.
+func (h *Handler) AdminPurgeSessions(w http.ResponseWriter, r *http.Request) {
+	exceptToken := ""
+	if r.URL.Query().Get("exceptSelf") == "1" {
+		exceptToken = sessionTokenFromRequest(r)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]interface{}{
-		"ready":      status.Ready,
-		"processing": status.Processing,
-		"url":        status.URL,
-		"state":      status.State,
-		"error":      status.Error,
-		"progress":   status.Progress,
+	removed := h.auth.PurgeSessions(exceptToken)
+	if exceptToken == "" {
+		clearSessionCookie(w)
+	}
+	writeJSON(w, map[string]interface{}{
+		"status":  "ok",
+		"removed": removed,
 	})
 }
 
-// UploadChunk handles chunked file uploads endpoint.
-func (h *Handler) UploadChunk(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+// ListVideos handles GET /api/videos.
+func (h *Handler) ListVideos(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	paged := query.Has("limit") || query.Has("offset") || query.Has("sort") || query.Has("order")
+
+	limit, err := queryInt(query.Get("limit"))
+	if err != nil {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
 		return
 	}
-
-	fileName, err := mediadomain.NormalizeVideoPath(r.FormValue("fileName"))
+	offset, err := queryInt(query.Get("offset"))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, "Invalid offset", http.StatusBadRequest)
 		return
 	}
-
-	chunkIndex, err := strconv.Atoi(r.FormValue("chunkIndex"))
-	if err != nil || chunkIndex < 0 {
-		http.Error(w, "Invalid chunk index", http.StatusBadRequest)
+	less, err := videoOrder(query.Get("sort"), query.Get("order"))
+	if err != nil {
+		http.Error(w, "Invalid sort", http.StatusBadRequest)
 		return
 	}
 
-	totalChunks, err := strconv.Atoi(r.FormValue("totalChunks"))
-	if err != nil || totalChunks <= 0 {
-		http.Error(w, "Invalid total chunks", http.StatusBadRequest)
+	user, _ := requestUser(r)
+	videos, statuses, err := h.listVideosForUser(r, user.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if less != nil {
+		// The store shares its cached listing, so sort a copy.
+		videos = append([]mediadomain.Video(nil), videos...)
+		sort.SliceStable(videos, func(i, j int) bool { return less(videos[i], videos[j]) })
+	}
 
-	file, _, err := r.FormFile("chunk")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	// Without paging or sorting parameters the bare array is kept for older clients.
+	if !paged {
+		writeJSON(w, videosJSON(videos, statuses))
 		return
 	}
-	defer file.Close()
 
-	finalPath := filepath.Join(h.store.VideosRoot(), fileName)
-	if err := os.MkdirAll(filepath.Dir(finalPath), 0o755); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	total := len(videos)
+	page := videos[min(offset, total):]
+	if limit > 0 {
+		page = page[:min(limit, len(page))]
+	}
+	writeJSON(w, map[string]interface{}{
+		"items":  videosJSON(page, statuses),
+		"total":  total,
+		"offset": offset,
+		"limit":  limit,
+	})
+}
+
+// videoOrder returns the comparison for ?sort= and ?order=, or nil to keep the
+// store's newest-first order. Names sort ascending and sizes and times descending
+// unless an order is given.
+func videoOrder(field, order string) (func(a, b mediadomain.Video) bool, error) {
+	var asc func(a, b mediadomain.Video) bool
+	descByDefault := true
+	switch field {
+	case "":
+		if order == "" {
+			return nil, nil
+		}
+		field = "modified"
+		fallthrough
+	case "modified":
+		asc = func(a, b mediadomain.Video) bool { return a.ModifiedAt.Before(b.ModifiedAt) }
+	case "size":
+		asc = func(a, b mediadomain.Video) bool { return a.Size < b.Size }
+	case "name":
+		asc = func(a, b mediadomain.Video) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }
+		descByDefault = false
+	default:
+		return nil, fmt.Errorf("unknown sort field %q", field)
+	}
+
+	switch order {
+	case "":
+	case "asc":
+		descByDefault = false
+	case "desc":
+		descByDefault = true
+	default:
+		return nil, fmt.Errorf("unknown sort order %q", order)
+	}
+	if descByDefault {
+		return func(a, b mediadomain.Video) bool { return asc(b, a) }, nil
+	}
+	return asc, nil
+}
+
+// queryInt parses a non-negative integer query value; an absent value is zero.
+func queryInt(raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid value %q", raw)
+	}
+	return value, nil
+}
+
+// videosJSON renders videos for clients. With statuses, from listVideosForUser,
+// each video also carries the user's watched flag and position.
+func videosJSON(videos []mediadomain.Video, statuses map[string]progressapp.Status) []map[string]interface{} {
+	resp := make([]map[string]interface{}, 0, len(videos))
+	for _, v := range videos {
+		item := map[string]interface{}{
+			"name":       v.Name,
+			"path":       v.Path,
+			"size":       v.Size,
+			"modifiedAt": v.ModifiedAt.Unix(),
+		}
+		if statuses != nil {
+			status := statuses[v.Path]
+			item["watched"] = status.Watched
+			item["positionSeconds"] = status.PositionSeconds
+		}
+		resp = append(resp, item)
+	}
+	return resp
+}
+
+// SearchVideos finds library videos whose path contains every term of ?q=. The
+// response carries at most ?limit= results plus the total number of matches.
+func (h *Handler) SearchVideos(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "Missing search query", http.StatusBadRequest)
 		return
 	}
-	var dst *os.File
-	if chunkIndex == 0 {
-		dst, err = os.Create(finalPath)
-	} else {
-		dst, err = os.OpenFile(finalPath, os.O_APPEND|os.O_WRONLY, 0o644)
+	limit := defaultSearchResults
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(value, maxSearchResults)
 	}
+
+	videos, err := h.store.SearchVideos(query)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer dst.Close()
+	videos = h.scopeVideos(r, videos)
 
-	if _, err := dst.ReadFrom(file); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	total := len(videos)
+	writeJSON(w, map[string]interface{}{"total": total, "results": videosJSON(videos[:min(total, limit)], nil)})
+}
+
+// Browse lists the videos and subdirectories of one library folder (?path=, the
+// root when empty). Directories carry their child count.
+func (h *Handler) Browse(w http.ResponseWriter, r *http.Request) {
+	dir := h.scopePath(r, r.URL.Query().Get("path"))
+	entries, err := h.store.ListDir(dir)
+	if err != nil && errors.Is(err, os.ErrNotExist) && h.libraryPrefix(r) == dir {
+		// A user's folder appears with their first upload.
+		entries, err = nil, nil
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			http.Error(w, "Directory not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Invalid directory path", http.StatusBadRequest)
+		}
 		return
 	}
 
-	response := map[string]string{"status": "uploaded"}
-	if chunkIndex+1 == totalChunks {
+	resp := make([]map[string]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		rel, _ := h.unscopePath(r, entry.Path)
+		item := map[string]interface{}{
+			"name":       entry.Name,
+			"path":       rel,
+			"isDir":      entry.IsDir,
+			"modifiedAt": entry.ModifiedAt.Unix(),
+		}
+		if entry.IsDir {
+			item["childCount"] = entry.Children
+		} else {
+			item["size"] = entry.Size
+		}
+		resp = append(resp, item)
+	}
+
+	writeJSON(w, resp)
+}
+
+// StreamVideo handles direct file streaming endpoint.
+// With `follow=1` the file is treated as still growing (e.g. an active torrent download).
+// Both modes, ranged or not, honour the stream throttle.
+func (h *Handler) StreamVideo(w http.ResponseWriter, r *http.Request) {
+	_, full, err := h.store.ResolveVideoPath(h.pathParam(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	contentType := contentTypeFor(full)
+	defer h.trackStream(r, "direct", h.pathParam(r))()
+	w = h.throttleStream(w, r)
+	if r.URL.Query().Get("follow") == "1" {
+		streamGrowingFile(w, r, full, contentType, growingStreamIdleTimeout, nil)
+		return
+	}
+	streamFile(w, r, full, contentType)
+}
+
+// Probe reports a video's duration, resolution, codecs and audio tracks.
+func (h *Handler) Probe(w http.ResponseWriter, r *http.Request) {
+	info, err := h.media.Probe(r.Context(), h.pathParam(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			http.Error(w, "Video not found", http.StatusNotFound)
+		case errors.Is(err, mediaapp.ErrProbeFailed):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	writeJSON(w, info)
+}
+
+// Thumbnail serves a video's poster JPEG, extracting it on first request.
+func (h *Handler) Thumbnail(w http.ResponseWriter, r *http.Request) {
+	thumbPath, err := h.media.Thumbnail(r.Context(), h.pathParam(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			http.Error(w, "Video not found", http.StatusNotFound)
+		case errors.Is(err, mediaapp.ErrThumbnailUnavailable):
+			http.Error(w, "Thumbnail unavailable", http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	file, err := os.Open(thumbPath)
+	if err != nil {
+		http.Error(w, "Thumbnail unavailable", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The thumbnail is regenerated when its source changes, and Last-Modified
+	// follows it, so clients revalidate instead of pinning a stale poster.
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "private, max-age=3600, must-revalidate")
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
+
+// liveStreamRetryAfter is the Retry-After sent when every live stream slot is taken.
+const liveStreamRetryAfter = 10 * time.Second
+
+// StreamPlay handles ffmpeg-based live mp4 stream endpoint. When the live
+// stream cap is reached it answers 503 with Retry-After before writing video.
+func (h *Handler) StreamPlay(w http.ResponseWriter, r *http.Request) {
+	follow := r.URL.Query().Get("follow") == "1"
+	path := h.pathParam(r)
+	if path == "" {
+		http.Error(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "video/mp4")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	defer h.trackStream(r, "live", path)()
+	if err := h.media.StreamMP4(r.Context(), path, follow, w); errors.Is(err, mediaapp.ErrLiveStreamsFull) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(liveStreamRetryAfter.Seconds())))
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	}
+}
+
+// StreamMP4 handles seekable mp4 output endpoint.
+func (h *Handler) StreamMP4(w http.ResponseWriter, r *http.Request) {
+	rel, _, err := h.store.ResolveVideoPath(h.pathParam(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	audio, err := requestAudioTrack(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	subs, err := requestSubtitles(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, outputPath, _ := h.store.MP4Paths(mediadomain.SubtitleVariantPath(mediadomain.AudioVariantPath(rel, audio), subs))
+	status, err := h.media.MP4Status(rel, audio, subs)
+	if err != nil || !status.Ready {
+		http.Error(w, "MP4 not ready", http.StatusNotFound)
+		return
+	}
+	defer h.trackStream(r, "mp4", rel)()
+	h.media.MarkServed(outputPath)
+	streamFile(w, r, outputPath, "video/mp4")
+}
+
+// ArtifactStatuses reports HLS/MP4 readiness for a JSON array of library paths.
+func (h *Handler) ArtifactStatuses(w http.ResponseWriter, r *http.Request) {
+	var paths []string
+	if err := decodeJSON(r, &paths); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+	if len(paths) > maxArtifactStatusPaths {
+		http.Error(w, fmt.Sprintf("Too many paths (max %d)", maxArtifactStatusPaths), http.StatusBadRequest)
+		return
+	}
+
+	for i := range paths {
+		paths[i] = h.scopePath(r, paths[i])
+	}
+	statuses := h.media.ArtifactStatuses(paths)
+	for i := range statuses {
+		statuses[i].Path, _ = h.unscopePath(r, statuses[i].Path)
+	}
+	writeJSON(w, statuses)
+}
+
+// StartHLS handles HLS conversion kickoff endpoint. With ?force=1, which like
+// clearing artifacts is reserved to admins, existing output is discarded and
+// converted again.
+func (h *Handler) StartHLS(w http.ResponseWriter, r *http.Request) {
+	follow := r.URL.Query().Get("follow") == "1"
+	force := r.URL.Query().Get("force") == "1"
+	if force && !h.isAdmin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if r.URL.Query().Has("subs") {
+		http.Error(w, "Subtitles are only supported for MP4 output", http.StatusBadRequest)
+		return
+	}
+	key, ok := idempotencyKey(r)
+	if !ok {
+		http.Error(w, "Invalid Idempotency-Key", http.StatusBadRequest)
+		return
+	}
+	format, err := requestHLSFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	audio, err := requestAudioTrack(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var status mediadomain.JobStatus
+	if force {
+		status, err = h.media.RedoHLS(r.Context(), h.pathParam(r), format, audio, key)
+	} else {
+		status, err = h.media.StartHLS(r.Context(), h.pathParam(r), follow, format, audio, key)
+	}
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "Video not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, mediaapp.ErrJobRunning) || errors.Is(err, mediaapp.ErrJobPausing) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, mediaapp.ErrIdempotencyKeyReused) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if errors.Is(err, mediaapp.ErrInsufficientSpace) {
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"status": string(status.State),
+		"url":    status.URL,
+	})
+}
+
+// idempotencyKey returns the caller's Idempotency-Key scoped to the signed-in user.
+// ok is false when the header is present but malformed.
+func idempotencyKey(r *http.Request) (string, bool) {
+	raw := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if raw == "" {
+		return "", true
+	}
+	if len(raw) > maxIdempotencyKeyLength || strings.IndexFunc(raw, func(c rune) bool { return c < 0x21 || c > 0x7e }) >= 0 {
+		return "", false
+	}
+
+	userID := ""
+	if user, ok := requestUser(r); ok {
+		userID = user.ID
+	}
+	return userID + ":" + raw, true
+}
+
+// requestHLSFormat picks the HLS segment format for a request. An explicit
+// `format` query parameter wins; otherwise players that list fMP4 segments
+// (`video/iso.segment`) in Accept get fMP4. Anything else leaves the server default.
+func requestHLSFormat(r *http.Request) (mediadomain.HLSFormat, error) {
+	if raw := r.URL.Query().Get("format"); raw != "" {
+		return mediadomain.ParseHLSFormat(raw)
+	}
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accepted, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), "video/iso.segment") {
+			return mediadomain.HLSFormatFMP4, nil
+		}
+	}
+	return "", nil
+}
+
+// requestAudioTrack reads the `audio` query parameter selecting the source audio
+// track to convert. Without it the source's default track is used.
+func requestAudioTrack(r *http.Request) (int, error) {
+	return mediadomain.ParseAudioTrack(r.URL.Query().Get("audio"))
+}
+
+// requestSubtitles reads the `subs` query parameter selecting a source subtitle
+// stream for MP4 output, burned into the video with `burn=1`.
+func requestSubtitles(r *http.Request) (mediadomain.SubtitleSelection, error) {
+	query := r.URL.Query()
+	return mediadomain.ParseSubtitleSelection(query.Get("subs"), query.Get("burn") == "1")
+}
+
+// HLSStatus handles HLS conversion status endpoint.
+func (h *Handler) HLSStatus(w http.ResponseWriter, r *http.Request) {
+	format, err := requestHLSFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	audio, err := requestAudioTrack(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status, err := h.media.HLSStatus(h.pathParam(r), format, audio)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(withJobTiming(map[string]interface{}{
+		"ready":      status.Ready,
+		"processing": status.Processing,
+		"segments":   status.Segments,
+		"progress":   status.Progress,
+		"url":        status.URL,
+		"state":      status.State,
+		"error":      status.Error,
+		"resumable":  status.Resumable,
+	}, status))
+}
+
+// withJobTiming adds `startedAt`, `elapsedMs` and `etaMs` to a status response.
+// They are null when no conversion has run, and `etaMs` also while no estimate
+// is possible yet.
+func withJobTiming(fields map[string]interface{}, status mediadomain.JobStatus) map[string]interface{} {
+	fields["startedAt"], fields["elapsedMs"], fields["etaMs"] = nil, nil, nil
+	if status.StartedAt.IsZero() {
+		return fields
+	}
+	fields["startedAt"] = status.StartedAt.UTC()
+	fields["elapsedMs"] = status.Elapsed.Milliseconds()
+	if status.ETAKnown {
+		fields["etaMs"] = status.ETA.Milliseconds()
+	}
+	return fields
+}
+
+// PauseHLS stops a running HLS conversion, keeping its complete segments for a later resume.
+func (h *Handler) PauseHLS(w http.ResponseWriter, r *http.Request) {
+	format, err := requestHLSFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	audio, err := requestAudioTrack(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status, err := h.media.PauseHLS(h.pathParam(r), format, audio)
+	writeJobControlResult(w, status, err)
+}
+
+// ResumeHLS continues a paused HLS conversion from its last complete segment.
+func (h *Handler) ResumeHLS(w http.ResponseWriter, r *http.Request) {
+	format, err := requestHLSFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	audio, err := requestAudioTrack(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status, err := h.media.ResumeHLS(h.pathParam(r), format, audio)
+	writeJobControlResult(w, status, err)
+}
+
+// AdminTrimHLS deletes finished HLS segments numbered below ?before= to reclaim
+// disk space. Only enabled with HLS_TRIM_ENABLED.
+func (h *Handler) AdminTrimHLS(w http.ResponseWriter, r *http.Request) {
+	format, err := requestHLSFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	before, err := strconv.Atoi(r.URL.Query().Get("before"))
+	if err != nil || before <= 0 {
+		http.Error(w, "Invalid before index", http.StatusBadRequest)
+		return
+	}
+
+	removed, err := h.media.TrimHLS(h.pathParam(r), format, before)
+	if err != nil {
+		switch {
+		case errors.Is(err, mediaapp.ErrTrimDisabled):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, mediaapp.ErrTrimNotReady):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	writeJSON(w, map[string]int{"removed": removed})
+}
+
+// PauseMP4 stops a running MP4 conversion. MP4 jobs are not resumable; starting
+// the conversion again begins from scratch.
+func (h *Handler) PauseMP4(w http.ResponseWriter, r *http.Request) {
+	audio, err := requestAudioTrack(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	subs, err := requestSubtitles(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status, err := h.media.PauseMP4(h.pathParam(r), audio, subs)
+	writeJobControlResult(w, status, err)
+}
+
+// CancelHLS stops an HLS conversion and discards its output. Without an explicit
+// format it cancels whichever segment format is converting or paused.
+func (h *Handler) CancelHLS(w http.ResponseWriter, r *http.Request) {
+	format, err := requestHLSFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	audio, err := requestAudioTrack(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jobTypes := []mediadomain.JobType{mediadomain.JobHLS, mediadomain.JobHLSFMP4}
+	switch format {
+	case mediadomain.HLSFormatTS:
+		jobTypes = jobTypes[:1]
+	case mediadomain.HLSFormatFMP4:
+		jobTypes = jobTypes[1:]
+	}
+	for _, jobType := range jobTypes {
+		err = h.media.CancelJob(h.pathParam(r), jobType, audio, mediadomain.NoSubtitles)
+		if !errors.Is(err, mediaapp.ErrJobNotRunning) {
+			break
+		}
+	}
+	writeJobCancelResult(w, err)
+}
+
+// CancelMP4 stops an MP4 conversion and discards its partial output.
+func (h *Handler) CancelMP4(w http.ResponseWriter, r *http.Request) {
+	audio, err := requestAudioTrack(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	subs, err := requestSubtitles(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.media.CancelJob(h.pathParam(r), mediadomain.JobMP4, audio, subs)
+	writeJobCancelResult(w, err)
+}
+
+// ClearArtifacts deletes a video's HLS (?hls=1) and/or MP4 (?mp4=1) outputs while
+// keeping the source. Without either parameter both are cleared.
+func (h *Handler) ClearArtifacts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	clearHLS, clearMP4 := query.Get("hls") == "1", query.Get("mp4") == "1"
+	if !clearHLS && !clearMP4 {
+		clearHLS, clearMP4 = true, true
+	}
+
+	if err := h.media.ClearArtifacts(h.pathParam(r), clearHLS, clearMP4); err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			http.Error(w, "Video not found", http.StatusNotFound)
+		case errors.Is(err, mediaapp.ErrJobRunning):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	writeJSON(w, map[string]bool{"hls": clearHLS, "mp4": clearMP4})
+}
+
+// MoveVideo renames or moves a library video given {"from", "to"}. Derived
+// outputs of the old name are deleted. An existing destination or a running
+// conversion of the video yields 409.
+func (h *Handler) MoveVideo(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	from := h.scopePath(r, req.From)
+	path, err := h.media.MoveVideo(from, h.scopePath(r, req.To))
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrExist):
+			http.Error(w, "Destination already exists", http.StatusConflict)
+		case errors.Is(err, os.ErrNotExist):
+			http.Error(w, "Video not found", http.StatusNotFound)
+		case errors.Is(err, mediaapp.ErrJobRunning):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	if rel, err := mediadomain.NormalizeVideoPath(from); err == nil {
+		if err := h.quotas.MoveFile(rel, path); err != nil {
+			log.Printf("Quota accounting failed for %s: %v", path, err)
+		}
+		if h.progress != nil {
+			h.progress.MovePath(rel, path)
+		}
+	}
+
+	path, _ = h.unscopePath(r, path)
+	writeJSON(w, map[string]string{"path": path})
+}
+
+// DeleteVideo moves a library video into the trash and deletes its derived
+// outputs, and returns its size to the uploader's storage quota. It answers
+// with the trash name used to restore it.
+func (h *Handler) DeleteVideo(w http.ResponseWriter, r *http.Request) {
+	raw := h.pathParam(r)
+	name, err := h.media.DeleteVideo(raw)
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			http.Error(w, "Video not found", http.StatusNotFound)
+		case errors.Is(err, os.ErrExist), errors.Is(err, mediaapp.ErrJobRunning):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	if rel, err := mediadomain.NormalizeVideoPath(raw); err == nil {
+		if err := h.quotas.TrashFile(rel, name); err != nil {
+			log.Printf("Quota accounting failed for %s: %v", rel, err)
+		}
+		if h.progress != nil {
+			h.progress.RemovePath(rel)
+		}
+	}
+
+	name, _ = h.unscopePath(r, name)
+	writeJSON(w, map[string]string{"name": name})
+}
+
+// ListTrash lists deleted videos that can still be restored.
+func (h *Handler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	items, err := h.store.ListTrash()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		name, ok := h.unscopePath(r, item.Name)
+		if !ok {
+			continue
+		}
+		originalPath, _ := h.unscopePath(r, item.OriginalPath)
+		resp = append(resp, map[string]interface{}{
+			"name":         name,
+			"originalPath": originalPath,
+			"size":         item.Size,
+			"deletedAt":    item.DeletedAt.Unix(),
+		})
+	}
+	writeJSON(w, resp)
+}
+
+// RestoreFromTrash moves a trashed video given {"name"} back to where it was
+// deleted from and charges it to its uploader's storage quota again. An
+// existing file there yields 409, and a restore over the quota 413.
+func (h *Handler) RestoreFromTrash(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	name := h.scopePath(r, req.Name)
+	if err := h.quotas.CheckRestore(name); err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	path, err := h.store.RestoreFromTrash(name)
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrExist):
+			http.Error(w, "A video already exists at the original path", http.StatusConflict)
+		case errors.Is(err, os.ErrNotExist):
+			http.Error(w, "Trashed video not found", http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	if err := h.quotas.RestoreFile(name, path); err != nil {
+		log.Printf("Quota accounting failed for %s: %v", path, err)
+	}
+
+	path, _ = h.unscopePath(r, path)
+	writeJSON(w, map[string]string{"path": path})
+}
+
+func writeJobCancelResult(w http.ResponseWriter, err error) {
+	if err != nil {
+		writeJobControlError(w, err)
+		return
+	}
+	writeJSON(w, map[string]string{"status": string(mediadomain.StateIdle)})
+}
+
+func writeJobControlError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		http.Error(w, "Video not found", http.StatusNotFound)
+	case errors.Is(err, mediaapp.ErrJobNotRunning), errors.Is(err, mediaapp.ErrJobNotPaused), errors.Is(err, mediaapp.ErrJobPausing):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+func writeJobControlResult(w http.ResponseWriter, status mediadomain.JobStatus, err error) {
+	if err != nil {
+		writeJobControlError(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status":    status.State,
+		"url":       status.URL,
+		"segments":  status.Segments,
+		"resumable": status.Resumable,
+	})
+}
+
+// StartMP4 handles mp4 conversion kickoff endpoint. With ?force=1, which like
+// clearing artifacts is reserved to admins, existing output is discarded and
+// converted again.
+func (h *Handler) StartMP4(w http.ResponseWriter, r *http.Request) {
+	force := r.URL.Query().Get("force") == "1"
+	if force && !h.isAdmin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	key, ok := idempotencyKey(r)
+	if !ok {
+		http.Error(w, "Invalid Idempotency-Key", http.StatusBadRequest)
+		return
+	}
+	audio, err := requestAudioTrack(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	subs, err := requestSubtitles(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var status mediadomain.JobStatus
+	if force {
+		status, err = h.media.RedoMP4(r.Context(), h.pathParam(r), audio, subs, key)
+	} else {
+		status, err = h.media.StartMP4(r.Context(), h.pathParam(r), audio, subs, key)
+	}
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "Video not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, mediaapp.ErrJobRunning) || errors.Is(err, mediaapp.ErrJobPausing) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, mediaapp.ErrIdempotencyKeyReused) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if errors.Is(err, mediaapp.ErrInsufficientSpace) {
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"status": string(status.State),
+		"url":    status.URL,
+	})
+}
+
+// MP4Status handles mp4 conversion status endpoint.
+func (h *Handler) MP4Status(w http.ResponseWriter, r *http.Request) {
+	audio, err := requestAudioTrack(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	subs, err := requestSubtitles(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status, err := h.media.MP4Status(h.pathParam(r), audio, subs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(withJobTiming(map[string]interface{}{
+		"ready":         status.Ready,
+		"processing":    status.Processing,
+		"url":           status.URL,
+		"state":         status.State,
+		"error":         status.Error,
+		"progress":      status.Progress,
+		"queuePosition": status.QueuePosition,
+	}, status))
+}
+
+// IngestURL starts fetching a remote video URL into the library.
+func (h *Handler) IngestURL(w http.ResponseWriter, r *http.Request) {
+	var payload ingestRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	fileName := payload.FileName
+	if h.libraryPrefix(r) != "" {
+		// Derive the default name here so it lands in the caller's folder.
+		if strings.TrimSpace(fileName) == "" {
+			if source, err := url.Parse(payload.URL); err == nil {
+				fileName = path.Base(source.Path)
+			}
+		}
+		fileName = h.scopePath(r, fileName)
+	}
+	relPath, status, err := h.media.Ingest(r.Context(), payload.URL, fileName)
+	if err != nil {
+		switch {
+		case errors.Is(err, mediaapp.ErrIngestDisabled):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, mediaapp.ErrIngestExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, mediaapp.ErrIngestNotAllowed):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+		return
+	}
+
+	relPath, _ = h.unscopePath(r, relPath)
+	writeJSON(w, map[string]interface{}{
+		"path":     relPath,
+		"status":   status.State,
+		"url":      status.URL,
+		"progress": status.Progress,
+	})
+}
+
+// IngestStatus handles URL ingest status endpoint.
+func (h *Handler) IngestStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := h.media.IngestStatus(h.pathParam(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"ready":      status.Ready,
+		"processing": status.Processing,
+		"url":        status.URL,
+		"state":      status.State,
+		"error":      status.Error,
+		"progress":   status.Progress,
+	})
+}
+
+// UploadChunk handles chunked file uploads endpoint.
+func (h *Handler) UploadChunk(w http.ResponseWriter, r *http.Request) {
+	limits := h.uploads.Limits()
+	chunkTooLarge := fmt.Sprintf("Upload chunk too large: chunks are limited to %d bytes", limits.MaxChunkBytes)
+	if limits.MaxChunkBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, limits.MaxChunkBytes+uploadFormOverhead)
+	}
+	if err := r.ParseMultipartForm(maxUploadChunkBytes); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, chunkTooLarge, http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rawName := h.scopePath(r, r.FormValue("fileName"))
+	if _, err := mediadomain.NormalizeVideoPath(rawName); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	chunkIndex, err := strconv.Atoi(r.FormValue("chunkIndex"))
+	if err != nil || chunkIndex < 0 {
+		http.Error(w, "Invalid chunk index", http.StatusBadRequest)
+		return
+	}
+
+	totalChunks, err := strconv.Atoi(r.FormValue("totalChunks"))
+	if err != nil || totalChunks <= 0 {
+		http.Error(w, "Invalid total chunks", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("chunk")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	if limits.MaxChunkBytes > 0 && header.Size > limits.MaxChunkBytes {
+		http.Error(w, chunkTooLarge, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	user, _ := requestUser(r)
+	received := int64(0)
+	if chunkIndex > 0 {
+		received = h.uploads.PartialSize(rawName)
+	}
+	uploadedSize := received + header.Size
+	if err := h.quotas.CheckUpload(user.ID, uploadedSize); err != nil {
+		http.Error(w, "Storage quota exceeded", http.StatusForbidden)
+		return
+	}
+
+	fileName, complete, err := h.uploads.WriteChunk(rawName, chunkIndex, totalChunks, r.FormValue("sha256"), file)
+	if err != nil {
+		switch {
+		case errors.Is(err, uploadapp.ErrInvalidChunk), errors.Is(err, uploadapp.ErrInvalidChecksum):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, uploadapp.ErrChecksumMismatch):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case errors.Is(err, uploadapp.ErrChunkTooLarge), errors.Is(err, uploadapp.ErrUploadTooLarge):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		case errors.Is(err, uploadapp.ErrSessionNotFound), errors.Is(err, uploadapp.ErrChunkOutOfOrder):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := map[string]string{"status": "uploaded"}
+	if complete {
+		if err := h.quotas.RecordUpload(user.ID, user.Username, fileName, uploadedSize); err != nil {
+			log.Printf("Quota accounting failed for %s: %v", fileName, err)
+		}
 		if strings.ToLower(filepath.Ext(fileName)) != ".mp4" {
-			status, err := h.media.StartHLS(r.Context(), fileName, false)
+			status, err := h.media.StartHLS(r.Context(), fileName, false, "", mediadomain.DefaultAudioTrack, "")
 			if err == nil {
 				response["hlsStatus"] = string(status.State)
 				response["url"] = status.URL
@@ -435,6 +1652,38 @@ func (h *Handler) UploadChunk(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(response)
 }
 
+// UploadStatus reports the chunk an interrupted upload continues with, so clients
+// can resume instead of starting over.
+func (h *Handler) UploadStatus(w http.ResponseWriter, r *http.Request) {
+	progress, err := h.uploads.Status(h.scopePath(r, r.URL.Query().Get("fileName")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, progress)
+}
+
+// CancelUpload aborts an unfinished chunked upload and removes its partial file.
+func (h *Handler) CancelUpload(w http.ResponseWriter, r *http.Request) {
+	fileName, err := mediadomain.NormalizeVideoPath(h.scopePath(r, r.URL.Query().Get("fileName")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.uploads.Cancel(fileName); err != nil {
+		switch {
+		case errors.Is(err, uploadapp.ErrSessionNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, map[string]string{"status": "cancelled"})
+}
+
 // ListTorrents handles torrent listing endpoint.
 func (h *Handler) ListTorrents(w http.ResponseWriter, r *http.Request) {
 	if !h.torrents.Enabled() {
@@ -464,6 +1713,30 @@ func (h *Handler) ListTorrents(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// TorrentStats reports engine-wide transfer rates and torrent counts, in the
+// same enabled/error shape as ListTorrents.
+func (h *Handler) TorrentStats(w http.ResponseWriter, r *http.Request) {
+	if !h.torrents.Enabled() {
+		writeJSON(w, map[string]interface{}{
+			"enabled": false,
+		})
+		return
+	}
+
+	stats, err := h.torrents.SessionStats()
+	if err != nil {
+		writeJSON(w, map[string]interface{}{
+			"enabled": true,
+			"error":   err.Error(),
+		})
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"enabled": true,
+		"stats":   stats,
+	})
+}
+
 // UploadTorrent handles torrent file upload endpoint.
 func (h *Handler) UploadTorrent(w http.ResponseWriter, r *http.Request) {
 	if !h.torrents.Enabled() {
@@ -493,57 +1766,281 @@ func (h *Handler) UploadTorrent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": "queued"})
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "queued"})
+}
+
+// AddMagnet queues a torrent from a magnet URI.
+func (h *Handler) AddMagnet(w http.ResponseWriter, r *http.Request) {
+	if !h.torrents.Enabled() {
+		http.Error(w, "Transmission is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var payload torrentMagnetRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.torrents.AddMagnet(payload.Magnet); err != nil {
+		switch {
+		case errors.Is(err, torrentapp.ErrInvalidMagnet):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+		return
+	}
+
+	writeJSON(w, map[string]string{"status": "queued"})
+}
+
+// EnableTorrentStream handles sequential download toggle endpoint.
+func (h *Handler) EnableTorrentStream(w http.ResponseWriter, r *http.Request) {
+	if !h.torrents.Enabled() {
+		http.Error(w, "Transmission is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	idParam := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id <= 0 {
+		http.Error(w, "Invalid torrent id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.torrents.EnableStreaming(id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// FocusTorrentStream updates torrent download priority near current playback position.
+func (h *Handler) FocusTorrentStream(w http.ResponseWriter, r *http.Request) {
+	if !h.torrents.Enabled() {
+		http.Error(w, "Transmission is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var payload torrentFocusRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if payload.TorrentID <= 0 || payload.FileIndex < 0 {
+		http.Error(w, "Invalid torrent target", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.torrents.SetStreamingFocus(payload.TorrentID, payload.FileIndex, payload.CurrentTime, payload.Duration); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// RemoveTorrent deletes a torrent from Transmission; ?deleteData=1 also removes its files.
+func (h *Handler) RemoveTorrent(w http.ResponseWriter, r *http.Request) {
+	if !h.torrents.Enabled() {
+		http.Error(w, "Transmission is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		http.Error(w, "Invalid torrent id", http.StatusBadRequest)
+		return
+	}
+
+	deleteData := false
+	switch r.URL.Query().Get("deleteData") {
+	case "", "0", "false":
+	case "1", "true":
+		deleteData = true
+	default:
+		http.Error(w, "Invalid deleteData value", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.torrents.RemoveTorrent(id, deleteData); err != nil {
+		switch {
+		case errors.Is(err, torrentdomain.ErrNotFound):
+			http.Error(w, "Torrent not found", http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+		return
+	}
+
+	writeJSON(w, map[string]string{"status": "removed"})
+}
+
+// PauseTorrent stops a torrent download.
+func (h *Handler) PauseTorrent(w http.ResponseWriter, r *http.Request) {
+	h.setTorrentPaused(w, r, true)
+}
+
+// ResumeTorrent restarts a paused torrent download.
+func (h *Handler) ResumeTorrent(w http.ResponseWriter, r *http.Request) {
+	h.setTorrentPaused(w, r, false)
+}
+
+func (h *Handler) setTorrentPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	if !h.torrents.Enabled() {
+		http.Error(w, "Transmission is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		http.Error(w, "Invalid torrent id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.torrents.SetPaused(id, paused); err != nil {
+		switch {
+		case errors.Is(err, torrentdomain.ErrNotFound):
+			http.Error(w, "Torrent not found", http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+		return
+	}
+
+	status := "resumed"
+	if paused {
+		status = "paused"
+	}
+	writeJSON(w, map[string]string{"status": status})
+}
+
+// VerifyTorrent starts a recheck of a torrent's data. Its progress shows up as
+// the check_wait and checking statuses in the torrent list.
+func (h *Handler) VerifyTorrent(w http.ResponseWriter, r *http.Request) {
+	if !h.torrents.Enabled() {
+		http.Error(w, "Transmission is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		http.Error(w, "Invalid torrent id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.torrents.VerifyTorrent(id); err != nil {
+		switch {
+		case errors.Is(err, torrentdomain.ErrNotFound):
+			http.Error(w, "Torrent not found", http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+		return
+	}
+
+	writeJSON(w, map[string]string{"status": "verifying"})
+}
+
+type torrentFilesRequest struct {
+	Wanted   []int `json:"wanted"`
+	Unwanted []int `json:"unwanted"`
 }
 
-// EnableTorrentStream handles sequential download toggle endpoint.
-func (h *Handler) EnableTorrentStream(w http.ResponseWriter, r *http.Request) {
+// SetTorrentFiles chooses which files of a torrent are downloaded.
+func (h *Handler) SetTorrentFiles(w http.ResponseWriter, r *http.Request) {
 	if !h.torrents.Enabled() {
 		http.Error(w, "Transmission is not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	idParam := mux.Vars(r)["id"]
-	id, err := strconv.Atoi(idParam)
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil || id <= 0 {
 		http.Error(w, "Invalid torrent id", http.StatusBadRequest)
 		return
 	}
+	var payload torrentFilesRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
 
-	if err := h.torrents.EnableStreaming(id); err != nil {
-		http.Error(w, err.Error(), http.StatusBadGateway)
+	if err := h.torrents.SetFilesWanted(id, payload.Wanted, payload.Unwanted); err != nil {
+		switch {
+		case errors.Is(err, torrentdomain.ErrNotFound):
+			http.Error(w, "Torrent not found", http.StatusNotFound)
+		case errors.Is(err, torrentdomain.ErrInvalidFileIndex):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
 		return
 	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+type torrentLimitsRequest struct {
+	SeedRatio  *float64 `json:"seedRatio"`
+	UploadKbps *int     `json:"uploadKbps"`
 }
 
-// FocusTorrentStream updates torrent download priority near current playback position.
-func (h *Handler) FocusTorrentStream(w http.ResponseWriter, r *http.Request) {
+// SetTorrentLimits sets a torrent's seed ratio limit and upload speed cap.
+func (h *Handler) SetTorrentLimits(w http.ResponseWriter, r *http.Request) {
 	if !h.torrents.Enabled() {
 		http.Error(w, "Transmission is not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	var payload torrentFocusRequest
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		http.Error(w, "Invalid torrent id", http.StatusBadRequest)
+		return
+	}
+	var payload torrentLimitsRequest
 	if err := decodeJSON(r, &payload); err != nil {
 		http.Error(w, "Invalid payload", http.StatusBadRequest)
 		return
 	}
 
-	if payload.TorrentID <= 0 || payload.FileIndex < 0 {
-		http.Error(w, "Invalid torrent target", http.StatusBadRequest)
+	if err := h.torrents.SetLimits(id, payload.SeedRatio, payload.UploadKbps); err != nil {
+		switch {
+		case errors.Is(err, torrentdomain.ErrNotFound):
+			http.Error(w, "Torrent not found", http.StatusNotFound)
+		case errors.Is(err, torrentdomain.ErrInvalidLimit):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
 		return
 	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
 
-	if err := h.torrents.SetStreamingFocus(payload.TorrentID, payload.FileIndex, payload.CurrentTime, payload.Duration); err != nil {
+// TorrentFocusState reports the streaming focus mode applied to a torrent.
+func (h *Handler) TorrentFocusState(w http.ResponseWriter, r *http.Request) {
+	if !h.torrents.Enabled() {
+		http.Error(w, "Transmission is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		http.Error(w, "Invalid torrent id", http.StatusBadRequest)
+		return
+	}
+
+	state, err := h.torrents.FocusState(id)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadGateway)
 		return
 	}
 
-	writeJSON(w, map[string]string{"status": "ok"})
+	writeJSON(w, state)
 }
 
 // CreateWatchHub creates a collaborative watch hub.
@@ -566,10 +2063,10 @@ func (h *Handler) CreateWatchHub(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	relPath, _, err := h.store.ResolveVideoPath(videoPath)
-	if err != nil {
-		http.Error(w, "Video not found", http.StatusNotFound)
-		return
+	// Library paths are resolved and access-checked by the watch party service.
+	kind := strings.ToLower(strings.TrimSpace(payload.Kind))
+	if kind != watchpartyapp.HubKindExternal {
+		videoPath = h.scopePath(r, videoPath)
 	}
 
 	currentTime := payload.CurrentTime
@@ -581,21 +2078,129 @@ func (h *Handler) CreateWatchHub(w http.ResponseWriter, r *http.Request) {
 		playing = *payload.Playing
 	}
 
-	hub, err := h.watch.CreateHub(user.ID, user.Username, relPath, currentTime, playing)
+	hub, err := h.watch.CreateHub(user.ID, user.Username, kind, videoPath, currentTime, playing, payload.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, watchpartyapp.ErrInvalidExternalURL), errors.Is(err, watchpartyapp.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Unable to create watch hub", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"hub":        h.watchHubView(r, hub),
+		"invitePath": fmt.Sprintf("/watch-together?hub=%s", url.QueryEscape(hub.ID)),
+	})
+}
+
+// QuickstartWatchHub validates a library video, starts its MP4 conversion when
+// needed and creates a hub for it in one call. The hub is shareable at once and
+// reports preparing until the conversion is ready.
+func (h *Handler) QuickstartWatchHub(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload watchHubQuickstartRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	relPath, full, err := h.store.ResolveVideoPath(h.scopePath(r, payload.Path))
+	if err != nil {
+		http.Error(w, "Video not found", http.StatusNotFound)
+		return
+	}
+	if _, err := os.Stat(full); err != nil {
+		http.Error(w, "Video not found", http.StatusNotFound)
+		return
+	}
+
+	// MP4 sources stream directly; everything else needs the MP4 conversion.
+	status := mediadomain.JobStatus{State: mediadomain.StateReady, Ready: true}
+	if strings.ToLower(filepath.Ext(relPath)) != ".mp4" {
+		status, err = h.media.StartMP4(r.Context(), relPath, mediadomain.DefaultAudioTrack, mediadomain.NoSubtitles, "")
+		if errors.Is(err, mediaapp.ErrInsufficientSpace) {
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if status.State == mediadomain.StateFailed {
+			http.Error(w, "Conversion failed: "+status.Error, http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	hub, err := h.watch.CreateHub(user.ID, user.Username, watchpartyapp.HubKindLibrary, relPath, 0, false, "")
 	if err != nil {
 		http.Error(w, "Unable to create watch hub", http.StatusInternalServerError)
 		return
 	}
+	if !status.Ready {
+		if err := h.watch.SetPreparing(hub.ID, true); err == nil {
+			hub.Preparing = true
+			go h.awaitWatchSource(hub.ID, relPath)
+		}
+	}
 
 	writeJSON(w, map[string]interface{}{
-		"hub":        hub,
+		"hub":        h.watchHubView(r, hub),
+		"conversion": status,
 		"invitePath": fmt.Sprintf("/watch-together?hub=%s", url.QueryEscape(hub.ID)),
 	})
 }
 
+// awaitWatchSource clears a hub's preparing flag once its MP4 conversion ends,
+// whether it succeeded or failed, or gives up after watchPrepareTimeout.
+func (h *Handler) awaitWatchSource(hubID, relPath string) {
+	ticker := time.NewTicker(watchPreparePollInterval)
+	defer ticker.Stop()
+	deadline := time.Now().Add(watchPrepareTimeout)
+
+	for range ticker.C {
+		if _, err := h.watch.GetHub(hubID); err != nil {
+			return
+		}
+		status, err := h.media.MP4Status(relPath, mediadomain.DefaultAudioTrack, mediadomain.NoSubtitles)
+		if err != nil || status.Ready || status.State == mediadomain.StateFailed || !status.Processing || time.Now().After(deadline) {
+			_ = h.watch.SetPreparing(hubID, false)
+			return
+		}
+	}
+}
+
+// ListWatchHubs lists the hubs the caller owns or has joined, without chat history.
+func (h *Handler) ListWatchHubs(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	hubs := h.watch.ListHubsForUser(user.ID)
+	for i := range hubs {
+		hubs[i] = h.watchHubView(r, hubs[i])
+	}
+	writeJSON(w, map[string]interface{}{
+		"hubs": hubs,
+	})
+}
+
 // GetWatchHub returns the current hub state.
 func (h *Handler) GetWatchHub(w http.ResponseWriter, r *http.Request) {
 	hubID := strings.TrimSpace(mux.Vars(r)["id"])
+	user, _ := requestUser(r)
+	if !h.authorizeWatchHub(w, r, hubID, user.ID) {
+		return
+	}
 	hub, err := h.watch.GetHub(hubID)
 	if err != nil {
 		switch {
@@ -608,7 +2213,7 @@ func (h *Handler) GetWatchHub(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeJSON(w, map[string]interface{}{
-		"hub":        hub,
+		"hub":        h.watchHubView(r, hub),
 		"invitePath": fmt.Sprintf("/watch-together?hub=%s", url.QueryEscape(hub.ID)),
 	})
 }
@@ -622,33 +2227,29 @@ func (h *Handler) ControlWatchHub(w http.ResponseWriter, r *http.Request) {
 	}
 
 	hubID := strings.TrimSpace(mux.Vars(r)["id"])
+	if !h.authorizeWatchHub(w, r, hubID, user.ID) {
+		return
+	}
 	var payload watchHubControlRequest
 	if err := decodeJSON(r, &payload); err != nil {
 		http.Error(w, "Invalid payload", http.StatusBadRequest)
 		return
 	}
 
-	videoPath := strings.TrimSpace(payload.VideoPath)
-	if videoPath != "" {
-		relPath, _, err := h.store.ResolveVideoPath(videoPath)
-		if err != nil {
-			http.Error(w, "Video not found", http.StatusNotFound)
-			return
-		}
-		videoPath = relPath
+	input, err := h.watchControlInput(r, hubID, payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
 	}
 
-	event, err := h.watch.Control(hubID, user.ID, user.Username, watchpartyapp.ControlInput{
-		Action:      payload.Action,
-		VideoPath:   videoPath,
-		CurrentTime: payload.CurrentTime,
-		Playing:     payload.Playing,
-	})
+	event, err := h.watch.Control(hubID, user.ID, user.Username, input)
 	if err != nil {
 		switch {
 		case errors.Is(err, watchpartyapp.ErrHubNotFound):
 			http.Error(w, err.Error(), http.StatusNotFound)
-		case errors.Is(err, watchpartyapp.ErrInvalidInput):
+		case errors.Is(err, watchpartyapp.ErrKicked), errors.Is(err, watchpartyapp.ErrMemberNotFound):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, watchpartyapp.ErrInvalidInput), errors.Is(err, watchpartyapp.ErrInvalidExternalURL):
 			http.Error(w, err.Error(), http.StatusBadRequest)
 		default:
 			http.Error(w, "Unable to update hub state", http.StatusInternalServerError)
@@ -657,10 +2258,55 @@ func (h *Handler) ControlWatchHub(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeJSON(w, map[string]interface{}{
-		"event": event,
+		"event": h.watchEventView(r, event),
 	})
 }
 
+// watchControlInput converts a control request into service input. Library
+// video paths are mapped from the caller's view to library paths; the watch
+// party service resolves and access-checks them. It fails with the hub lookup
+// error.
+func (h *Handler) watchControlInput(r *http.Request, hubID string, payload watchHubControlRequest) (watchpartyapp.ControlInput, error) {
+	videoPath := strings.TrimSpace(payload.VideoPath)
+	if videoPath != "" {
+		hub, err := h.watch.GetHub(hubID)
+		if err != nil {
+			return watchpartyapp.ControlInput{}, err
+		}
+		// External hubs validate their URL in the watch party service.
+		if hub.Kind == watchpartyapp.HubKindLibrary {
+			videoPath = h.scopePath(r, videoPath)
+		}
+	}
+
+	return watchpartyapp.ControlInput{
+		Action:       payload.Action,
+		VideoPath:    videoPath,
+		CurrentTime:  payload.CurrentTime,
+		Playing:      payload.Playing,
+		PlaybackRate: payload.PlaybackRate,
+	}, nil
+}
+
+// watchHubView maps a library hub's video path to the caller's view. Hubs store
+// library paths; a video outside the caller's folder keeps its library path,
+// which the caller cannot open anyway.
+func (h *Handler) watchHubView(r *http.Request, hub watchpartyapp.Snapshot) watchpartyapp.Snapshot {
+	if hub.Kind != watchpartyapp.HubKindLibrary {
+		return hub
+	}
+	if rel, ok := h.unscopePath(r, hub.VideoPath); ok {
+		hub.VideoPath = rel
+	}
+	return hub
+}
+
+// watchEventView is watchHubView for the hub snapshot carried by an event.
+func (h *Handler) watchEventView(r *http.Request, event watchpartyapp.Event) watchpartyapp.Event {
+	event.Hub = h.watchHubView(r, event.Hub)
+	return event
+}
+
 // SendWatchHubChat appends a chat message into the hub.
 func (h *Handler) SendWatchHubChat(w http.ResponseWriter, r *http.Request) {
 	user, ok := requestUser(r)
@@ -670,6 +2316,9 @@ func (h *Handler) SendWatchHubChat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	hubID := strings.TrimSpace(mux.Vars(r)["id"])
+	if !h.authorizeWatchHub(w, r, hubID, user.ID) {
+		return
+	}
 	var payload watchHubChatRequest
 	if err := decodeJSON(r, &payload); err != nil {
 		http.Error(w, "Invalid payload", http.StatusBadRequest)
@@ -681,6 +2330,8 @@ func (h *Handler) SendWatchHubChat(w http.ResponseWriter, r *http.Request) {
 		switch {
 		case errors.Is(err, watchpartyapp.ErrHubNotFound):
 			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, watchpartyapp.ErrKicked), errors.Is(err, watchpartyapp.ErrMemberNotFound):
+			http.Error(w, err.Error(), http.StatusForbidden)
 		case errors.Is(err, watchpartyapp.ErrInvalidInput):
 			http.Error(w, err.Error(), http.StatusBadRequest)
 		default:
@@ -690,10 +2341,158 @@ func (h *Handler) SendWatchHubChat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeJSON(w, map[string]interface{}{
-		"event": event,
+		"event": h.watchEventView(r, event),
 	})
 }
 
+// WatchHubTyping tells hub members that the user is typing a chat message.
+func (h *Handler) WatchHubTyping(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	hubID := strings.TrimSpace(mux.Vars(r)["id"])
+	if !h.authorizeWatchHub(w, r, hubID, user.ID) {
+		return
+	}
+	if err := h.watch.Typing(hubID, user.ID, user.Username); err != nil {
+		writeWatchEphemeralError(w, err)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// ReactWatchHub broadcasts an emoji reaction to hub members.
+func (h *Handler) ReactWatchHub(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	hubID := strings.TrimSpace(mux.Vars(r)["id"])
+	if !h.authorizeWatchHub(w, r, hubID, user.ID) {
+		return
+	}
+	var payload watchHubReactRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.watch.React(hubID, user.ID, user.Username, payload.Emoji); err != nil {
+		writeWatchEphemeralError(w, err)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// writeWatchEphemeralError maps Typing and React errors to responses.
+func writeWatchEphemeralError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, watchpartyapp.ErrHubNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, watchpartyapp.ErrKicked), errors.Is(err, watchpartyapp.ErrMemberNotFound):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, watchpartyapp.ErrInvalidInput):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Unable to send hub event", http.StatusInternalServerError)
+	}
+}
+
+// watchHubKey returns the hub password sent in the X-Hub-Key header. It is
+// never read from the URL, which ends up in logs and browser history.
+func watchHubKey(r *http.Request) string {
+	return r.Header.Get("X-Hub-Key")
+}
+
+// authorizeWatchHub checks the request's hub key for private hubs and writes
+// the error response when userID may not use the hub.
+func (h *Handler) authorizeWatchHub(w http.ResponseWriter, r *http.Request, hubID, userID string) bool {
+	err := h.watch.Authorize(hubID, userID, watchHubKey(r))
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, watchpartyapp.ErrHubNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, watchpartyapp.ErrHubForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, watchpartyapp.ErrHubKeyRateLimited):
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+	return false
+}
+
+// KickWatchHubMember removes a member from a hub. Only the hub owner may kick.
+func (h *Handler) KickWatchHubMember(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	hubID := strings.TrimSpace(mux.Vars(r)["id"])
+	var payload watchHubMemberRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.watch.Kick(hubID, user.ID, payload.UserID); err != nil {
+		switch {
+		case errors.Is(err, watchpartyapp.ErrHubNotFound), errors.Is(err, watchpartyapp.ErrMemberNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, watchpartyapp.ErrNotHubOwner):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, watchpartyapp.ErrInvalidInput), errors.Is(err, watchpartyapp.ErrInvalidHubID):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Unable to kick member", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, map[string]string{"status": "kicked"})
+}
+
+// TransferWatchHub hands hub ownership to another member. Only the owner may transfer.
+func (h *Handler) TransferWatchHub(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	hubID := strings.TrimSpace(mux.Vars(r)["id"])
+	var payload watchHubMemberRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.watch.TransferOwnership(hubID, user.ID, payload.UserID); err != nil {
+		switch {
+		case errors.Is(err, watchpartyapp.ErrHubNotFound), errors.Is(err, watchpartyapp.ErrMemberNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, watchpartyapp.ErrNotHubOwner):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, watchpartyapp.ErrInvalidInput), errors.Is(err, watchpartyapp.ErrInvalidHubID):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Unable to transfer ownership", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, map[string]string{"status": "transferred"})
+}
+
 // WatchHubEvents streams SSE updates for a hub.
 func (h *Handler) WatchHubEvents(w http.ResponseWriter, r *http.Request) {
 	user, ok := requestUser(r)
@@ -703,17 +2502,27 @@ func (h *Handler) WatchHubEvents(w http.ResponseWriter, r *http.Request) {
 	}
 
 	hubID := strings.TrimSpace(mux.Vars(r)["id"])
-	events, done, err := h.watch.Subscribe(hubID, user.ID, user.Username)
+	if !h.authorizeWatchHub(w, r, hubID, user.ID) {
+		return
+	}
+	// Browsers send Last-Event-ID when an EventSource reconnects.
+	lastSeq, _ := strconv.ParseUint(strings.TrimSpace(r.Header.Get("Last-Event-ID")), 10, 64)
+	events, done, err := h.watch.SubscribeAfter(hubID, user.ID, user.Username, lastSeq)
 	if err != nil {
 		switch {
 		case errors.Is(err, watchpartyapp.ErrHubNotFound):
 			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, watchpartyapp.ErrKicked):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, watchpartyapp.ErrHubFull):
+			http.Error(w, err.Error(), http.StatusConflict)
 		default:
 			http.Error(w, err.Error(), http.StatusBadRequest)
 		}
 		return
 	}
 	defer done()
+	defer h.trackSubscriber()()
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -742,10 +2551,15 @@ func (h *Handler) WatchHubEvents(w http.ResponseWriter, r *http.Request) {
 			if !open {
 				return
 			}
-			payload, err := json.Marshal(event)
+			payload, err := json.Marshal(h.watchEventView(r, event))
 			if err != nil {
 				continue
 			}
+			if event.Seq > 0 {
+				if _, err := fmt.Fprintf(w, "id: %d\n", event.Seq); err != nil {
+					return
+				}
+			}
 			if _, err := io.WriteString(w, "data: "); err != nil {
 				return
 			}
@@ -830,23 +2644,63 @@ type credentialsRequest struct {
 	Password string `json:"password"`
 }
 
+type ingestRequest struct {
+	URL      string `json:"url"`
+	FileName string `json:"fileName"`
+}
+
+type changePasswordRequest struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+type sessionTTLRequest struct {
+	TTLSeconds int64 `json:"ttlSeconds"`
+}
+
+type quotaLimitsRequest struct {
+	StorageLimitBytes *int64 `json:"storageLimitBytes"`
+	StreamLimitBytes  *int64 `json:"streamLimitBytes"`
+}
+
 type watchHubCreateRequest struct {
+	Kind        string  `json:"kind"`
 	VideoPath   string  `json:"videoPath"`
 	CurrentTime float64 `json:"currentTime"`
 	Playing     *bool   `json:"playing"`
+	// Password, when set, makes the hub private.
+	Password string `json:"password"`
+}
+
+type watchHubQuickstartRequest struct {
+	Path string `json:"path"`
 }
 
 type watchHubControlRequest struct {
-	Action      string  `json:"action"`
-	VideoPath   string  `json:"videoPath"`
-	CurrentTime float64 `json:"currentTime"`
-	Playing     *bool   `json:"playing"`
+	Action       string  `json:"action"`
+	VideoPath    string  `json:"videoPath"`
+	CurrentTime  float64 `json:"currentTime"`
+	Playing      *bool   `json:"playing"`
+	PlaybackRate float64 `json:"playbackRate"`
 }
 
 type watchHubChatRequest struct {
 	Text string `json:"text"`
 }
 
+type watchHubReactRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+// watchHubMemberRequest names the member a kick or ownership transfer targets.
+type watchHubMemberRequest struct {
+	UserID string `json:"userId"`
+}
+
+type torrentMagnetRequest struct {
+	Magnet string `json:"magnet"`
+}
+
 type torrentFocusRequest struct {
 	TorrentID   int     `json:"torrentId"`
 	FileIndex   int     `json:"fileIndex"`
@@ -0,0 +1,777 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	authapp "evd/internal/application/auth"
+	mediaapp "evd/internal/application/media"
+	quotaapp "evd/internal/application/quota"
+	uploadapp "evd/internal/application/upload"
+	watchpartyapp "evd/internal/application/watchparty"
+	mediadomain "evd/internal/domain/media"
+	torrentdomain "evd/internal/domain/torrent"
+	"github.com/gorilla/mux"
+)
+
+// Fakes embed the use-case interfaces so tests only implement what they exercise.
+
+type fakeMedia struct {
+	mediaUseCases
+	jobs   []mediadomain.JobInfo
+	videos []mediadomain.Video
+
+	mu      sync.Mutex
+	mp4     mediadomain.JobStatus
+	started []string
+	// streamErr is returned by StreamMP4.
+	streamErr error
+}
+
+func (f *fakeMedia) StreamMP4(context.Context, string, bool, io.Writer) error { return f.streamErr }
+
+func (f *fakeMedia) ActiveJobs() []mediadomain.JobInfo { return f.jobs }
+
+// ListVideos shares its slice, like the store's cached listing.
+func (f *fakeMedia) ListVideos() ([]mediadomain.Video, error) {
+	return f.videos, nil
+}
+
+func (f *fakeMedia) StartMP4(_ context.Context, rawPath string, _ int, _ mediadomain.SubtitleSelection, _ string) (mediadomain.JobStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.started = append(f.started, rawPath)
+	return f.mp4, nil
+}
+
+func (f *fakeMedia) RedoMP4(_ context.Context, rawPath string, _ int, _ mediadomain.SubtitleSelection, _ string) (mediadomain.JobStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.mp4.Processing {
+		return mediadomain.JobStatus{}, mediaapp.ErrJobRunning
+	}
+	f.started = append(f.started, "redo:"+rawPath)
+	return mediadomain.JobStatus{State: mediadomain.StateQueued, Processing: true}, nil
+}
+
+func (f *fakeMedia) DeleteVideo(string) (string, error) { return "trashed", nil }
+
+func (f *fakeMedia) MoveVideo(_, rawTo string) (string, error) { return rawTo, nil }
+
+func (f *fakeMedia) MP4Status(string, int, mediadomain.SubtitleSelection) (mediadomain.JobStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.mp4, nil
+}
+
+func (f *fakeMedia) setMP4(status mediadomain.JobStatus) {
+	f.mu.Lock()
+	f.mp4 = status
+	f.mu.Unlock()
+}
+
+type fakeTorrents struct {
+	torrentUseCases
+	enabled bool
+	items   []torrentdomain.Info
+}
+
+func (f *fakeTorrents) Enabled() bool { return f.enabled }
+
+func (f *fakeTorrents) List() ([]torrentdomain.Info, error) { return f.items, nil }
+
+type fakePathStore struct {
+	mediaPathStore
+	disks []mediadomain.DiskUsage
+	root  string
+
+	restorePath string
+	restored    int
+}
+
+func (f *fakePathStore) RestoreFromTrash(string) (string, error) {
+	f.restored++
+	return f.restorePath, nil
+}
+
+func (f *fakePathStore) DiskUsage() ([]mediadomain.DiskUsage, error) { return f.disks, nil }
+
+func (f *fakePathStore) ResolveVideoPath(raw string) (string, string, error) {
+	return raw, filepath.Join(f.root, raw), nil
+}
+
+type fakeAuth struct {
+	authUseCases
+	admin bool
+}
+
+func (f *fakeAuth) IsAdmin(authapp.User) bool { return f.admin }
+
+func (f *fakeAuth) Authenticate(string) (authapp.User, error) {
+	return authapp.User{ID: "u1", Username: "alice"}, nil
+}
+
+func withUser(r *http.Request, user authapp.User) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), userContextKey, user))
+}
+
+func TestStatusPage_RendersWithTransmissionDisabled(t *testing.T) {
+	handler := NewHandler(
+		&fakeMedia{jobs: []mediadomain.JobInfo{{Type: mediadomain.JobMP4, Path: "movie.mkv", State: mediadomain.StateProcessing, Progress: 42}}},
+		&fakeTorrents{enabled: false},
+		&fakePathStore{disks: []mediadomain.DiskUsage{{Name: "videos", TotalBytes: 2048, FreeBytes: 1024, UsedBytes: 1024}}},
+		nil,
+		&fakeAuth{admin: true},
+		nil,
+		nil,
+	)
+
+	rec := httptest.NewRecorder()
+	req := withUser(httptest.NewRequest(http.MethodGet, "/status", nil), authapp.User{ID: "u1", Username: "root"})
+	handler.RequireAdmin(http.HandlerFunc(handler.StatusPage)).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"Transmission is not configured", "movie.mkv", "42%", "1.0 KB"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected status page to contain %q", want)
+		}
+	}
+}
+
+func TestStatusPage_RequiresAdmin(t *testing.T) {
+	handler := NewHandler(&fakeMedia{}, &fakeTorrents{}, &fakePathStore{}, nil, &fakeAuth{admin: false}, nil, nil)
+
+	rec := httptest.NewRecorder()
+	req := withUser(httptest.NewRequest(http.MethodGet, "/status", nil), authapp.User{ID: "u2", Username: "bob"})
+	handler.RequireAdmin(http.HandlerFunc(handler.StatusPage)).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rec.Code)
+	}
+}
+
+func TestStatusPage_OnlyRoutedWhenEnabled(t *testing.T) {
+	handler := NewHandler(&fakeMedia{}, &fakeTorrents{}, &fakePathStore{}, nil, &fakeAuth{}, nil, nil)
+	rec := httptest.NewRecorder()
+	NewRouter(handler, t.TempDir()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without the status page, got %d", rec.Code)
+	}
+
+	handler.EnableStatusPage()
+	rec = httptest.NewRecorder()
+	NewRouter(handler, t.TempDir()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the status page behind authentication, got %d", rec.Code)
+	}
+}
+
+func TestRequestHLSFormat(t *testing.T) {
+	cases := []struct {
+		query, accept string
+		want          mediadomain.HLSFormat
+		wantErr       bool
+	}{
+		{want: ""},
+		{accept: "application/vnd.apple.mpegurl, video/iso.segment;q=0.9", want: mediadomain.HLSFormatFMP4},
+		{query: "ts", accept: "video/iso.segment", want: mediadomain.HLSFormatTS},
+		{query: "fmp4", want: mediadomain.HLSFormatFMP4},
+		{query: "webm", wantErr: true},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/api/hls-start/movie.mkv?format="+tc.query, nil)
+		req.Header.Set("Accept", tc.accept)
+		got, err := requestHLSFormat(req)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Fatalf("query=%q accept=%q: got %q, %v", tc.query, tc.accept, got, err)
+		}
+	}
+}
+
+func TestRequestAudioTrack(t *testing.T) {
+	cases := []struct {
+		query   string
+		want    int
+		wantErr bool
+	}{
+		{want: mediadomain.DefaultAudioTrack},
+		{query: "0", want: 0},
+		{query: "2", want: 2},
+		{query: "-1", wantErr: true},
+		{query: "en", wantErr: true},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/api/mp4-start/movie.mkv?audio="+tc.query, nil)
+		got, err := requestAudioTrack(req)
+		if (err != nil) != tc.wantErr || (!tc.wantErr && got != tc.want) {
+			t.Fatalf("audio=%q: got %d, %v", tc.query, got, err)
+		}
+	}
+}
+
+func TestClientConfig_ExposesOnlyPublicSettings(t *testing.T) {
+	handler := NewHandler(nil, &fakeTorrents{enabled: true}, nil, nil, nil, nil, nil)
+	handler.SetClientConfig(ClientConfig{HLSSegmentSeconds: 6, IngestEnabled: true, MaxUploadBytes: 1 << 30})
+
+	rec := httptest.NewRecorder()
+	handler.ClientConfig(rec, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body["torrentsEnabled"] != true || body["hlsSegmentSeconds"] != float64(6) || body["hlsFormat"] != "ts" {
+		t.Fatalf("unexpected config: %v", body)
+	}
+
+	for key := range body {
+		lower := strings.ToLower(key)
+		for _, sensitive := range []string{"pass", "secret", "token", "dir", "file", "url", "addr", "admin"} {
+			if strings.Contains(lower, sensitive) {
+				t.Fatalf("config key %q looks sensitive", key)
+			}
+		}
+	}
+	if strings.Contains(rec.Body.String(), "/") {
+		t.Fatalf("config body must not contain paths: %s", rec.Body.String())
+	}
+	if body["guestAllowed"] != false || body["maxUploadBytes"] != float64(1<<30) {
+		t.Fatalf("expected guest login and upload cap from the config, got %v", body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.LoginGuest(rec, httptest.NewRequest(http.MethodPost, "/api/auth/guest", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected guest login refused when disabled, got %d", rec.Code)
+	}
+}
+
+func quickstart(t *testing.T, handler *Handler, path string) (watchpartyapp.Snapshot, mediadomain.JobStatus) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/watch-hubs/quickstart", strings.NewReader(`{"path":"`+path+`"}`))
+	rec := httptest.NewRecorder()
+	handler.QuickstartWatchHub(rec, withUser(req, authapp.User{ID: "u1", Username: "alice"}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Hub        watchpartyapp.Snapshot `json:"hub"`
+		Conversion mediadomain.JobStatus  `json:"conversion"`
+		InvitePath string                 `json:"invitePath"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.InvitePath != "/watch-together?hub="+body.Hub.ID {
+		t.Fatalf("unexpected invite path %q", body.InvitePath)
+	}
+	return body.Hub, body.Conversion
+}
+
+func TestQuickstartWatchHub_ReadySource(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "movie.mp4"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	media := &fakeMedia{}
+	watch := watchpartyapp.NewService(watchpartyapp.Options{})
+	defer watch.Close()
+	handler := NewHandler(media, nil, &fakePathStore{root: root}, nil, nil, watch, nil)
+
+	hub, status := quickstart(t, handler, "movie.mp4")
+	if hub.Preparing || !status.Ready {
+		t.Fatalf("expected a ready hub, got %+v / %+v", hub, status)
+	}
+	if hub.VideoPath != "movie.mp4" || hub.Kind != watchpartyapp.HubKindLibrary {
+		t.Fatalf("unexpected hub: %+v", hub)
+	}
+	if len(media.started) != 0 {
+		t.Fatalf("expected no conversion for an mp4 source, got %v", media.started)
+	}
+}
+
+func TestQuickstartWatchHub_PreparesUntilConverted(t *testing.T) {
+	prev := watchPreparePollInterval
+	watchPreparePollInterval = 5 * time.Millisecond
+	defer func() { watchPreparePollInterval = prev }()
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "movie.mkv"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	media := &fakeMedia{mp4: mediadomain.JobStatus{State: mediadomain.StateProcessing, Processing: true}}
+	watch := watchpartyapp.NewService(watchpartyapp.Options{})
+	defer watch.Close()
+	handler := NewHandler(media, nil, &fakePathStore{root: root}, nil, nil, watch, nil)
+
+	hub, status := quickstart(t, handler, "movie.mkv")
+	if !hub.Preparing || status.Ready {
+		t.Fatalf("expected a preparing hub, got %+v / %+v", hub, status)
+	}
+	if len(media.started) != 1 || media.started[0] != "movie.mkv" {
+		t.Fatalf("expected conversion kickoff, got %v", media.started)
+	}
+	if snap, err := watch.GetHub(hub.ID); err != nil || !snap.Preparing {
+		t.Fatalf("expected joiners to see preparing, got %+v, %v", snap, err)
+	}
+
+	media.setMP4(mediadomain.JobStatus{State: mediadomain.StateReady, Ready: true})
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		snap, err := watch.GetHub(hub.ID)
+		if err != nil {
+			t.Fatalf("get hub: %v", err)
+		}
+		if !snap.Preparing {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected hub to leave preparing once converted")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestListVideos_PaginatesAndSorts(t *testing.T) {
+	now := time.Now()
+	media := &fakeMedia{videos: []mediadomain.Video{
+		{Name: "b.mkv", Path: "b.mkv", Size: 30, ModifiedAt: now},
+		{Name: "C.mkv", Path: "C.mkv", Size: 10, ModifiedAt: now.Add(-time.Hour)},
+		{Name: "a.mkv", Path: "a.mkv", Size: 20, ModifiedAt: now.Add(-2 * time.Hour)},
+	}}
+	handler := NewHandler(media, nil, nil, nil, nil, nil, nil)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ListVideos(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	var bare []map[string]interface{}
+	if err := json.NewDecoder(get("/api/videos").Body).Decode(&bare); err != nil || len(bare) != 3 || bare[0]["path"] != "b.mkv" {
+		t.Fatalf("expected bare newest-first array, got %v (%v)", bare, err)
+	}
+
+	var page struct {
+		Items  []map[string]interface{} `json:"items"`
+		Total  int                      `json:"total"`
+		Offset int                      `json:"offset"`
+		Limit  int                      `json:"limit"`
+	}
+	if err := json.NewDecoder(get("/api/videos?sort=name&offset=1&limit=1").Body).Decode(&page); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if page.Total != 3 || page.Offset != 1 || page.Limit != 1 || len(page.Items) != 1 || page.Items[0]["path"] != "b.mkv" {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+
+	page.Items = nil
+	if err := json.NewDecoder(get("/api/videos?sort=size&order=asc&offset=5").Body).Decode(&page); err != nil || len(page.Items) != 0 || page.Total != 3 {
+		t.Fatalf("expected empty page past the end, got %+v (%v)", page, err)
+	}
+
+	if rec := get("/api/videos?sort=rating"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown sort, got %d", rec.Code)
+	}
+	if media.videos[0].Path != "b.mkv" || media.videos[2].Path != "a.mkv" {
+		t.Fatalf("expected sorting to leave the shared listing alone, got %v", media.videos)
+	}
+}
+
+type fakeUploads struct {
+	uploadUseCases
+	limits   uploadapp.Limits
+	progress map[string]uploadapp.Progress
+}
+
+func (f *fakeUploads) Limits() uploadapp.Limits { return f.limits }
+
+func (f *fakeUploads) Status(rawName string) (uploadapp.Progress, error) {
+	return f.progress[rawName], nil
+}
+
+func (f *fakeUploads) WriteChunk(rawName string, _, _ int, _ string, chunk io.Reader) (string, bool, error) {
+	_, err := io.Copy(io.Discard, chunk)
+	return rawName, true, err
+}
+
+func TestUploadChunk_RejectsOversizedChunk(t *testing.T) {
+	uploads := &fakeUploads{limits: uploadapp.Limits{MaxChunkBytes: 8}}
+	handler := NewHandler(nil, nil, nil, uploads, &fakeAuth{}, nil, unlimitedQuotas{})
+
+	for _, size := range []int{16, uploadFormOverhead + 16} {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		_ = form.WriteField("fileName", "movie.mkv")
+		_ = form.WriteField("chunkIndex", "0")
+		_ = form.WriteField("totalChunks", "1")
+		part, _ := form.CreateFormFile("chunk", "blob")
+		_, _ = part.Write(make([]byte, size))
+		_ = form.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/upload", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		rec := httptest.NewRecorder()
+		handler.UploadChunk(rec, withUser(req, authapp.User{ID: "u1", Username: "alice"}))
+
+		if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), "limited to 8 bytes") {
+			t.Fatalf("%d byte chunk: expected 413 naming the limit, got %d %q", size, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestUploadStatus_ReportsProgressInUserLibrary(t *testing.T) {
+	uploads := &fakeUploads{progress: map[string]uploadapp.Progress{
+		"u1/movie.mkv": {NextChunk: 4, TotalChunks: 10, ReceivedBytes: 4 << 20},
+	}}
+	handler := NewHandler(nil, nil, nil, uploads, &fakeAuth{}, nil, nil)
+	handler.EnableUserLibraries()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/upload/status?fileName=movie.mkv", nil)
+	handler.UploadStatus(rec, withUser(req, authapp.User{ID: "u1", Username: "alice"}))
+
+	var progress uploadapp.Progress
+	if err := json.NewDecoder(rec.Body).Decode(&progress); err != nil || progress.NextChunk != 4 || progress.TotalChunks != 10 {
+		t.Fatalf("expected progress of the user's upload, got %d %+v %v", rec.Code, progress, err)
+	}
+}
+
+func TestStreamVideo_SendsVideoContentTypes(t *testing.T) {
+	root := t.TempDir()
+	handler := NewHandler(nil, nil, &fakePathStore{root: root}, nil, &fakeAuth{}, nil, nil)
+
+	for name, want := range map[string]string{"clip.webm": "video/webm", "clip.mkv": "video/x-matroska", "clip.ts": "video/mp2t"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("data"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		rec := httptest.NewRecorder()
+		handler.StreamVideo(rec, withUser(httptest.NewRequest(http.MethodGet, "/api/stream?path="+name, nil), authapp.User{ID: "u1"}))
+		if got := rec.Header().Get("Content-Type"); got != want {
+			t.Fatalf("%s: expected %s, got %q (%d)", name, want, got, rec.Code)
+		}
+	}
+}
+
+func TestWatchHub_NonAdminUsesOwnListedVideos(t *testing.T) {
+	var resolved []string
+	watch := watchpartyapp.NewService(watchpartyapp.Options{
+		VideoAccess: func(userID, videoPath string) (string, error) {
+			resolved = append(resolved, videoPath)
+			if !strings.HasPrefix(videoPath, userID+"/") {
+				return "", errors.New("outside the user's library")
+			}
+			return videoPath, nil
+		},
+	})
+	defer watch.Close()
+	handler := NewHandler(&fakeMedia{}, nil, nil, nil, &fakeAuth{}, watch, nil)
+	handler.EnableUserLibraries()
+	alice := authapp.User{ID: "u1", Username: "alice"}
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"videoPath":"movie.mp4"}`)
+	handler.CreateWatchHub(rec, withUser(httptest.NewRequest(http.MethodPost, "/api/watch-hubs", body), alice))
+	var created struct {
+		Hub watchpartyapp.Snapshot `json:"hub"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("create: %d %v", rec.Code, err)
+	}
+	if created.Hub.VideoPath != "movie.mp4" {
+		t.Fatalf("expected the hub path in the caller's view, got %q", created.Hub.VideoPath)
+	}
+	if snap, _ := watch.GetHub(created.Hub.ID); snap.VideoPath != "u1/movie.mp4" {
+		t.Fatalf("expected the hub to store the library path, got %q", snap.VideoPath)
+	}
+
+	_, done, err := watch.Subscribe(created.Hub.ID, alice.ID, alice.Username)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer done()
+	rec = httptest.NewRecorder()
+	body = strings.NewReader(`{"action":"video","videoPath":"shows/ep1.mp4"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/watch-hubs/"+created.Hub.ID+"/control", body)
+	handler.ControlWatchHub(rec, withUser(mux.SetURLVars(req, map[string]string{"id": created.Hub.ID}), alice))
+	var switched struct {
+		Event watchpartyapp.Event `json:"event"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&switched); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("switch to own video: %d %v", rec.Code, err)
+	}
+	if switched.Event.Hub.VideoPath != "shows/ep1.mp4" {
+		t.Fatalf("expected the switched path in the caller's view, got %q", switched.Event.Hub.VideoPath)
+	}
+	if want := []string{"u1/movie.mp4", "u1/shows/ep1.mp4"}; !reflect.DeepEqual(resolved, want) {
+		t.Fatalf("expected VideoAccess to see the scoped paths %v, got %v", want, resolved)
+	}
+
+	rec = httptest.NewRecorder()
+	body = strings.NewReader(`{"videoPath":"clip.mp4"}`)
+	handler.CreateWatchHub(rec, withUser(httptest.NewRequest(http.MethodPost, "/api/watch-hubs", body), authapp.User{ID: "u2", Username: "bob"}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected another user to create a hub on their own video, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	body = strings.NewReader(`{"videoPath":"movie.txt"}`)
+	handler.CreateWatchHub(rec, withUser(httptest.NewRequest(http.MethodPost, "/api/watch-hubs", body), alice))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unsupported extension, got %d", rec.Code)
+	}
+}
+
+func TestKickWatchHubMember_OwnerOnly(t *testing.T) {
+	watch := watchpartyapp.NewService(watchpartyapp.Options{})
+	defer watch.Close()
+	hub, err := watch.CreateHub("owner", "olive", watchpartyapp.HubKindLibrary, "movie.mkv", 0, false, "")
+	if err != nil {
+		t.Fatalf("create hub: %v", err)
+	}
+	_, done, err := watch.Subscribe(hub.ID, "u2", "bob")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer done()
+	handler := NewHandler(nil, nil, nil, nil, nil, watch, nil)
+
+	kick := func(user authapp.User) int {
+		body := strings.NewReader(`{"userId":"u2"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/watch-hubs/"+hub.ID+"/kick", body)
+		req = withUser(mux.SetURLVars(req, map[string]string{"id": hub.ID}), user)
+		rec := httptest.NewRecorder()
+		handler.KickWatchHubMember(rec, req)
+		return rec.Code
+	}
+
+	if code := kick(authapp.User{ID: "u2", Username: "bob"}); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-owner, got %d", code)
+	}
+	if code := kick(authapp.User{ID: "owner", Username: "olive"}); code != http.StatusOK {
+		t.Fatalf("expected owner kick to succeed, got %d", code)
+	}
+	if code := kick(authapp.User{ID: "owner", Username: "olive"}); code != http.StatusNotFound {
+		t.Fatalf("expected 404 once the member is gone, got %d", code)
+	}
+}
+
+func TestGetWatchHub_PrivateHubNeedsKey(t *testing.T) {
+	watch := watchpartyapp.NewService(watchpartyapp.Options{})
+	defer watch.Close()
+	hub, err := watch.CreateHub("owner", "olive", watchpartyapp.HubKindLibrary, "movie.mkv", 0, false, "s3cret")
+	if err != nil {
+		t.Fatalf("create hub: %v", err)
+	}
+	handler := NewHandler(nil, nil, nil, nil, nil, watch, nil)
+
+	get := func(target, key string) int {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if key != "" {
+			req.Header.Set("X-Hub-Key", key)
+		}
+		req = withUser(mux.SetURLVars(req, map[string]string{"id": hub.ID}), authapp.User{ID: "u2", Username: "bob"})
+		rec := httptest.NewRecorder()
+		handler.GetWatchHub(rec, req)
+		return rec.Code
+	}
+
+	if code := get("/api/watch-hubs/"+hub.ID, ""); code != http.StatusForbidden {
+		t.Fatalf("expected 403 without a key, got %d", code)
+	}
+	if code := get("/api/watch-hubs/"+hub.ID, "wrong"); code != http.StatusForbidden {
+		t.Fatalf("expected 403 with a wrong key, got %d", code)
+	}
+	if code := get("/api/watch-hubs/"+hub.ID+"?key=s3cret", ""); code != http.StatusForbidden {
+		t.Fatalf("expected the key in the URL to be ignored, got %d", code)
+	}
+	if code := get("/api/watch-hubs/"+hub.ID, "s3cret"); code != http.StatusOK {
+		t.Fatalf("expected 200 with the key, got %d", code)
+	}
+}
+
+func TestTorrentStats_DisabledShape(t *testing.T) {
+	handler := NewHandler(nil, &fakeTorrents{enabled: false}, nil, nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	handler.TorrentStats(rec, httptest.NewRequest(http.MethodGet, "/api/torrents/stats", nil))
+	var payload map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if payload["enabled"] != false || payload["stats"] != nil {
+		t.Fatalf("expected the disabled shape, got %v", payload)
+	}
+}
+
+func TestStartMP4_ForceRedoesAndConflictsWhileRunning(t *testing.T) {
+	media := &fakeMedia{mp4: mediadomain.JobStatus{State: mediadomain.StateReady, Ready: true}}
+	auth := &fakeAuth{}
+	handler := NewHandler(media, nil, &fakePathStore{}, nil, auth, nil, nil)
+	start := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, nil)
+		req = mux.SetURLVars(req, map[string]string{"path": "movie.mkv"})
+		rec := httptest.NewRecorder()
+		handler.StartMP4(rec, withUser(req, authapp.User{ID: "u1", Username: "alice"}))
+		return rec
+	}
+
+	if rec := start("/api/mp4-start/movie.mkv?force=1"); rec.Code != http.StatusForbidden || len(media.started) != 0 {
+		t.Fatalf("expected 403 for a forced start by a non-admin, got %d %v", rec.Code, media.started)
+	}
+	auth.admin = true
+	if rec := start("/api/mp4-start/movie.mkv?force=1"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"queued"`) {
+		t.Fatalf("expected forced start to queue a conversion, got %d %s", rec.Code, rec.Body.String())
+	}
+	if len(media.started) != 1 || media.started[0] != "redo:movie.mkv" {
+		t.Fatalf("expected a redo, got %v", media.started)
+	}
+
+	media.mp4 = mediadomain.JobStatus{State: mediadomain.StateProcessing, Processing: true}
+	if rec := start("/api/mp4-start/movie.mkv?force=1"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 while converting, got %d", rec.Code)
+	}
+}
+
+func TestRouter_GatesLibraryAndTorrentChanges(t *testing.T) {
+	handler := NewHandler(&fakeMedia{}, &fakeTorrents{enabled: true}, &fakePathStore{}, nil, &fakeAuth{}, nil, nil)
+	request := func(target string) int {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		NewRouter(handler, t.TempDir()).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, target := range []string{
+		"/api/videos/move",
+		"/api/trash/restore",
+		"/api/torrent/1/pause",
+		"/api/torrent/1/resume",
+		"/api/torrent/1/files",
+		"/api/torrent/1/verify",
+		"/api/torrent/1/limits",
+	} {
+		if code := request(target); code != http.StatusForbidden {
+			t.Fatalf("%s: expected 403 for a non-admin, got %d", target, code)
+		}
+	}
+
+	handler.EnableUserLibraries()
+	if code := request("/api/videos/move"); code == http.StatusForbidden {
+		t.Fatalf("expected moves within a user library to be allowed")
+	}
+	if code := request("/api/torrent/1/pause"); code != http.StatusForbidden {
+		t.Fatalf("expected torrents to stay admin-only, got %d", code)
+	}
+}
+
+func TestStartHLS_RejectsSubtitles(t *testing.T) {
+	media := &fakeMedia{}
+	handler := NewHandler(media, nil, &fakePathStore{}, nil, &fakeAuth{}, nil, nil)
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/api/hls-start/movie.mkv?subs=0&burn=1", nil), map[string]string{"path": "movie.mkv"})
+	rec := httptest.NewRecorder()
+	handler.StartHLS(rec, withUser(req, authapp.User{ID: "u1", Username: "alice"}))
+
+	if rec.Code != http.StatusBadRequest || len(media.started) != 0 {
+		t.Fatalf("expected subtitles refused for HLS, got %d %v", rec.Code, media.started)
+	}
+}
+
+func TestStreamPlay_ReturnsServiceUnavailableWhenStreamsAreFull(t *testing.T) {
+	handler := NewHandler(&fakeMedia{streamErr: mediaapp.ErrLiveStreamsFull}, nil, &fakePathStore{}, nil, nil, nil, nil)
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/play/movie.mkv", nil), map[string]string{"path": "movie.mkv"})
+	rec := httptest.NewRecorder()
+	handler.StreamPlay(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "10" {
+		t.Fatalf("expected 503 with Retry-After, got %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestDeleteVideo_ReleasesUploaderStorage(t *testing.T) {
+	quotas, _ := quotaapp.NewService("", quotaapp.Limits{StorageBytes: 1 << 20})
+	store := &fakePathStore{restorePath: "films/movie.mp4"}
+	handler := NewHandler(&fakeMedia{}, nil, store, &fakeUploads{}, &fakeAuth{}, nil, quotas)
+	user := authapp.User{ID: "u1", Username: "alice"}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	_ = form.WriteField("fileName", "movie.mp4")
+	_ = form.WriteField("chunkIndex", "0")
+	_ = form.WriteField("totalChunks", "1")
+	part, _ := form.CreateFormFile("chunk", "blob")
+	_, _ = part.Write(make([]byte, 512))
+	_ = form.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/upload", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	handler.UploadChunk(httptest.NewRecorder(), withUser(req, user))
+	if used := quotas.Usage("u1").UploadedBytes; used != 512 {
+		t.Fatalf("expected the upload charged to the quota, got %d", used)
+	}
+
+	move := httptest.NewRequest(http.MethodPost, "/api/videos/move", strings.NewReader(`{"from":"movie.mp4","to":"films/movie.mp4"}`))
+	rec := httptest.NewRecorder()
+	handler.MoveVideo(rec, withUser(move, user))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("move: %d %s", rec.Code, rec.Body.String())
+	}
+	if used := quotas.Usage("u1").UploadedBytes; used != 512 {
+		t.Fatalf("expected a move to keep the file charged, got %d", used)
+	}
+
+	del := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/videos/films/movie.mp4", nil), map[string]string{"path": "films/movie.mp4"})
+	rec = httptest.NewRecorder()
+	handler.DeleteVideo(rec, withUser(del, user))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body.String())
+	}
+	if used := quotas.Usage("u1").UploadedBytes; used != 0 {
+		t.Fatalf("expected the deleted file released from the quota, got %d", used)
+	}
+
+	restore := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/api/trash/restore", strings.NewReader(`{"name":"trashed"}`))
+		rec := httptest.NewRecorder()
+		handler.RestoreFromTrash(rec, withUser(req, user))
+		return rec.Code
+	}
+	small := int64(100)
+	if _, err := quotas.SetLimits("u1", &small, nil); err != nil {
+		t.Fatalf("set limits: %v", err)
+	}
+	if code := restore(); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected a restore over the limit refused with 413, got %d", code)
+	}
+	if store.restored != 0 {
+		t.Fatalf("expected nothing restored over the limit")
+	}
+	large := int64(1 << 20)
+	if _, err := quotas.SetLimits("u1", &large, nil); err != nil {
+		t.Fatalf("set limits: %v", err)
+	}
+	if code := restore(); code != http.StatusOK {
+		t.Fatalf("restore: %d", code)
+	}
+	if used := quotas.Usage("u1").UploadedBytes; used != 512 {
+		t.Fatalf("expected the restored file charged again, got %d", used)
+	}
+}
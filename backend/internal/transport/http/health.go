@@ -0,0 +1,66 @@
+package http
+
+import (
+	"net/http"
+	"sync"
+)
+
+// ReadinessCheck is one dependency verified by Readyz. Check returns nil when
+// the dependency is usable. Detail, when set, adds information such as a
+// version to the result.
+type ReadinessCheck struct {
+	Name   string
+	Check  func() error
+	Detail func() string
+}
+
+// readinessResult is one entry of the GET /readyz body.
+type readinessResult struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// SetReadinessChecks sets the checks run by Readyz.
+func (h *Handler) SetReadinessChecks(checks ...ReadinessCheck) {
+	h.readiness = checks
+}
+
+// Healthz reports that the process is up and serving requests.
+func (h *Handler) Healthz(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// Readyz runs the readiness checks concurrently and answers 503 unless all pass.
+// Error details are included, so the endpoint should not be exposed publicly
+// beyond the load balancer.
+func (h *Handler) Readyz(w http.ResponseWriter, _ *http.Request) {
+	results := make([]readinessResult, len(h.readiness))
+	var wg sync.WaitGroup
+	for i, check := range h.readiness {
+		wg.Add(1)
+		go func(i int, check ReadinessCheck) {
+			defer wg.Done()
+			results[i] = readinessResult{Name: check.Name, OK: true}
+			if err := check.Check(); err != nil {
+				results[i] = readinessResult{Name: check.Name, Error: err.Error()}
+			}
+			if check.Detail != nil {
+				results[i].Detail = check.Detail()
+			}
+		}(i, check)
+	}
+	wg.Wait()
+
+	status := "ok"
+	for _, result := range results {
+		if !result.OK {
+			status = "unavailable"
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			break
+		}
+	}
+	writeJSON(w, map[string]interface{}{"status": status, "checks": results})
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadyz_ReportsFailingChecks(t *testing.T) {
+	handler := NewHandler(nil, nil, nil, nil, nil, nil, nil)
+	router := NewRouter(handler, t.TempDir())
+
+	ready := func() (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var body map[string]interface{}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return rec.Code, body
+	}
+
+	handler.SetReadinessChecks(ReadinessCheck{Name: "storage", Check: func() error { return nil }})
+	if code, body := ready(); code != http.StatusOK || body["status"] != "ok" {
+		t.Fatalf("expected ready, got %d %v", code, body)
+	}
+
+	handler.SetReadinessChecks(
+		ReadinessCheck{Name: "storage", Check: func() error { return nil }},
+		ReadinessCheck{Name: "ffmpeg", Check: func() error { return errors.New("ffmpeg not found") }},
+	)
+	code, body := ready()
+	checks, _ := body["checks"].([]interface{})
+	if code != http.StatusServiceUnavailable || len(checks) != 2 {
+		t.Fatalf("expected 503 with both checks, got %d %v", code, body)
+	}
+	if failed, _ := checks[1].(map[string]interface{}); failed["ok"] != false || failed["error"] != "ffmpeg not found" {
+		t.Fatalf("unexpected failed check %v", failed)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected healthz to pass without auth, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const hlsStartTag = "#EXT-X-START:"
+
+var errStartBeyondPlaylist = errors.New("start offset is beyond the playlist duration")
+
+// hlsFileServer serves HLS output from root. A playlist requested with ?t=<seconds>
+// is rewritten on the fly with #EXT-X-START so players begin near that position;
+// the file on disk is never changed. Files carry a weak ETag. Segments may be
+// cached but are revalidated, because a redo or reconversion rewrites them under
+// the same names; playlists, which grow during live conversions, are never reused
+// without revalidation either.
+func hlsFileServer(root http.FileSystem) http.Handler {
+	files := http.FileServer(root)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawOffset := strings.TrimSpace(r.URL.Query().Get("t"))
+		if rawOffset == "" || path.Ext(r.URL.Path) != ".m3u8" {
+			setHLSCacheHeaders(w, root, r.URL.Path)
+			files.ServeHTTP(w, r)
+			return
+		}
+
+		offset, err := strconv.ParseFloat(rawOffset, 64)
+		if err != nil || offset < 0 || math.IsNaN(offset) || math.IsInf(offset, 0) {
+			http.Error(w, "Invalid start offset", http.StatusBadRequest)
+			return
+		}
+
+		file, err := root.Open(path.Clean("/" + r.URL.Path))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer file.Close()
+		raw, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, "Unable to read playlist", http.StatusInternalServerError)
+			return
+		}
+
+		playlist, err := withHLSStart(raw, offset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(playlist))
+	})
+}
+
+// setHLSCacheHeaders sets Cache-Control for an HLS file by type and an ETag,
+// which http.FileServer then uses for If-None-Match.
+func setHLSCacheHeaders(w http.ResponseWriter, root http.FileSystem, name string) {
+	switch path.Ext(name) {
+	case ".m3u8":
+		w.Header().Set("Cache-Control", "no-cache")
+	case ".ts", ".m4s", ".mp4":
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+
+	file, err := root.Open(path.Clean("/" + name))
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err == nil && !info.IsDir() {
+		w.Header().Set("ETag", fileETag(info.Size(), info.ModTime()))
+	}
+}
+
+// hlsOutputs serves the HLS root at the URLs of the source paths. The store
+// may keep the output of an overlong name under a hashed folder instead.
+type hlsOutputs struct {
+	root  string
+	store mediaPathStore
+}
+
+func (o hlsOutputs) name(urlPath string) string {
+	name := path.Clean("/" + urlPath)
+	if o.store != nil {
+		name = o.store.HLSFileName(name)
+	}
+	return name
+}
+
+func (o hlsOutputs) Open(name string) (http.File, error) {
+	return http.Dir(o.root).Open(o.name(name))
+}
+
+// diskPath returns the file on disk served for urlPath.
+func (o hlsOutputs) diskPath(urlPath string) string {
+	return filepath.Join(o.root, filepath.FromSlash(o.name(urlPath)))
+}
+
+// markHLSServed reports each requested HLS file to the media service, keeping
+// recently watched outputs out of cache eviction.
+func (h *Handler) markHLSServed(outputs hlsOutputs) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.media.MarkServed(outputs.diskPath(strings.TrimPrefix(r.URL.Path, "/hls/")))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// withHLSStart returns playlist with a single #EXT-X-START tag at offset seconds.
+// Media playlists reject offsets past their summed segment durations; master
+// playlists carry no durations and accept any offset.
+func withHLSStart(playlist []byte, offset float64) ([]byte, error) {
+	lines := strings.Split(strings.TrimRight(string(playlist), "\n"), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "#EXTM3U" {
+		return nil, errors.New("not an HLS playlist")
+	}
+
+	duration, segments := 0.0, 0
+	out := make([]string, 0, len(lines)+1)
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, hlsStartTag) {
+			continue
+		}
+		if strings.HasPrefix(line, "#EXTINF:") {
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			if seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				duration += seconds
+				segments++
+			}
+		}
+		out = append(out, line)
+	}
+	if segments > 0 && offset >= duration {
+		return nil, errStartBeyondPlaylist
+	}
+
+	tag := fmt.Sprintf("%sTIME-OFFSET=%s,PRECISE=YES", hlsStartTag, strconv.FormatFloat(offset, 'f', 3, 64))
+	out = append(out[:1], append([]string{tag}, out[1:]...)...)
+	return []byte(strings.Join(out, "\n") + "\n"), nil
+}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testMediaPlaylist = "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:10\n#EXTINF:10.000,\nsegment00000.ts\n#EXTINF:10.000,\nsegment00001.ts\n#EXT-X-ENDLIST\n"
+
+func TestHLSFileServer_InjectsStartOffset(t *testing.T) {
+	dir := t.TempDir()
+	playlistPath := filepath.Join(dir, "movie", "index.m3u8")
+	if err := os.MkdirAll(filepath.Dir(playlistPath), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(playlistPath, []byte(testMediaPlaylist), 0o644); err != nil {
+		t.Fatalf("write playlist: %v", err)
+	}
+	server := hlsFileServer(http.Dir(dir))
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/movie/index.m3u8?t=12.5", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	lines := strings.Split(rec.Body.String(), "\n")
+	if lines[0] != "#EXTM3U" || lines[1] != "#EXT-X-START:TIME-OFFSET=12.500,PRECISE=YES" {
+		t.Fatalf("expected start tag after header, got %q", rec.Body.String())
+	}
+	if onDisk, _ := os.ReadFile(playlistPath); string(onDisk) != testMediaPlaylist {
+		t.Fatalf("expected playlist on disk to stay untouched, got %q", onDisk)
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/movie/index.m3u8?t=20", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected offset past the end to be rejected, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/movie/index.m3u8", nil))
+	if rec.Body.String() != testMediaPlaylist {
+		t.Fatalf("expected plain playlist without ?t=, got %q", rec.Body.String())
+	}
+}
+
+func TestHLSFileServer_CacheHeadersAndRevalidation(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{"index.m3u8": testMediaPlaylist, "segment00000.ts": "segment"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	server := hlsFileServer(http.Dir(dir))
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/segment00000.ts", nil))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" || rec.Header().Get("Cache-Control") != "private, no-cache" {
+		t.Fatalf("expected revalidated segment with ETag, got %d %v", rec.Code, rec.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/segment00000.ts", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for matching ETag, got %d", rec.Code)
+	}
+
+	// A reconversion rewrites the segment under the same name.
+	segment := filepath.Join(dir, "segment00000.ts")
+	if err := os.WriteFile(segment, []byte("new segment"), 0o644); err != nil {
+		t.Fatalf("rewrite segment: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(segment, later, later); err != nil {
+		t.Fatalf("touch segment: %v", err)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/segment00000.ts", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "new segment" {
+		t.Fatalf("expected the rewritten segment for a stale ETag, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/index.m3u8", nil))
+	if rec.Header().Get("Cache-Control") != "no-cache" || rec.Header().Get("ETag") == "" {
+		t.Fatalf("expected revalidated playlist, got %v", rec.Header())
+	}
+}
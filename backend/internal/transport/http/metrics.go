@@ -0,0 +1,29 @@
+package http
+
+import "net/http"
+
+// StreamMetrics records stream responses and watch hub subscriptions.
+type StreamMetrics interface {
+	StreamOpened()
+	StreamClosed()
+	StreamBytes(n int64)
+	SubscriberJoined()
+	SubscriberLeft()
+}
+
+// EnableMetrics records stream and watch hub activity with metrics and makes
+// the router serve endpoint at /metrics, unauthenticated and outside /api like
+// the health endpoints. It must be called before NewRouter.
+func (h *Handler) EnableMetrics(metrics StreamMetrics, endpoint http.Handler) {
+	h.metrics = metrics
+	h.metricsEndpoint = endpoint
+}
+
+// trackSubscriber counts an open watch hub subscription; the returned func ends it.
+func (h *Handler) trackSubscriber() func() {
+	if h.metrics == nil {
+		return func() {}
+	}
+	h.metrics.SubscriberJoined()
+	return h.metrics.SubscriberLeft
+}
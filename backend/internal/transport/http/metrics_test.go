@@ -0,0 +1,59 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// unlimitedQuotas lets every stream through; other quota calls are not expected.
+type unlimitedQuotas struct{ quotaUseCases }
+
+func (unlimitedQuotas) CheckStream(string) error          { return nil }
+func (unlimitedQuotas) AddStreamed(string, string, int64) {}
+
+type fakeStreamMetrics struct {
+	open, opened, bytes, subscribers int64
+}
+
+func (f *fakeStreamMetrics) StreamOpened()       { f.open++; f.opened++ }
+func (f *fakeStreamMetrics) StreamClosed()       { f.open-- }
+func (f *fakeStreamMetrics) StreamBytes(n int64) { f.bytes += n }
+func (f *fakeStreamMetrics) SubscriberJoined()   { f.subscribers++ }
+func (f *fakeStreamMetrics) SubscriberLeft()     { f.subscribers-- }
+
+func TestStreamAccessLog_RecordsStreamMetrics(t *testing.T) {
+	handler := NewHandler(nil, nil, nil, nil, &fakeAuth{}, nil, unlimitedQuotas{})
+	metrics := &fakeStreamMetrics{}
+	handler.EnableMetrics(metrics, nil)
+
+	var openWhileServing int64
+	stream := handler.StreamAccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		openWhileServing = metrics.open
+		_, _ = io.WriteString(w, "0123456789")
+	}))
+	stream.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/stream/movie.mp4", nil))
+
+	if openWhileServing != 1 || metrics.open != 0 || metrics.opened != 1 || metrics.bytes != 10 {
+		t.Fatalf("unexpected stream metrics %+v (open while serving %d)", metrics, openWhileServing)
+	}
+}
+
+func TestMetricsEndpoint_OnlyRoutedWhenEnabled(t *testing.T) {
+	handler := NewHandler(nil, nil, nil, nil, nil, nil, nil)
+	rec := httptest.NewRecorder()
+	NewRouter(handler, t.TempDir()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without metrics, got %d", rec.Code)
+	}
+
+	handler.EnableMetrics(&fakeStreamMetrics{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "evd_active_streams 0\n")
+	}))
+	rec = httptest.NewRecorder()
+	NewRouter(handler, t.TempDir()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "evd_active_streams 0\n" {
+		t.Fatalf("expected metrics without authentication, got %d %q", rec.Code, rec.Body.String())
+	}
+}
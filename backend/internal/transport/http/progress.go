@@ -0,0 +1,127 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	progressapp "evd/internal/application/progress"
+	mediadomain "evd/internal/domain/media"
+)
+
+// ProgressTracker remembers per-user playback positions.
+type ProgressTracker interface {
+	SavePosition(userID, path string, seconds, duration float64) error
+	GetPositions(userID string) []progressapp.Entry
+	Statuses(userID string) map[string]progressapp.Status
+	MovePath(from, to string)
+	RemovePath(path string)
+}
+
+// EnableProgress serves the continue-watching endpoints from progress.
+// Without it they report 404.
+func (h *Handler) EnableProgress(progress ProgressTracker) {
+	h.progress = progress
+}
+
+type progressRequest struct {
+	Path     string  `json:"path"`
+	Position float64 `json:"position"`
+	Duration float64 `json:"duration"`
+}
+
+// SaveProgress records the caller's position in a video. The player sends it as
+// a periodic heartbeat and when playback pauses or ends.
+func (h *Handler) SaveProgress(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.progress == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var payload progressRequest
+	if err := decodeJSON(r, &payload); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+	relPath, _, err := h.store.ResolveVideoPath(h.scopePath(r, payload.Path))
+	if err != nil {
+		http.Error(w, "Video not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.progress.SavePosition(user.ID, relPath, payload.Position, payload.Duration); err != nil {
+		if errors.Is(err, progressapp.ErrInvalidPosition) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Unable to save progress", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// ListProgress returns the caller's unfinished videos, most recent first.
+func (h *Handler) ListProgress(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.progress == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	entries := h.progress.GetPositions(user.ID)
+	items := make([]progressapp.Entry, 0, len(entries))
+	for _, entry := range entries {
+		rel, ok := h.unscopePath(r, entry.Path)
+		if !ok {
+			continue
+		}
+		entry.Path = rel
+		items = append(items, entry)
+	}
+	writeJSON(w, map[string]interface{}{
+		"items": items,
+	})
+}
+
+// listVideosForUser returns the videos the caller sees together with userID's
+// progress keyed by the same paths. The statuses are nil when progress
+// tracking is disabled or there is no user, keeping the plain listing.
+// Progress of videos that have left the library, such as files removed from
+// disk directly, is dropped here since no handler saw them go.
+func (h *Handler) listVideosForUser(r *http.Request, userID string) ([]mediadomain.Video, map[string]progressapp.Status, error) {
+	videos, err := h.media.ListVideos()
+	if err != nil {
+		return nil, nil, err
+	}
+	if h.progress == nil || userID == "" {
+		return h.scopeVideos(r, videos), nil, nil
+	}
+
+	library := make(map[string]struct{}, len(videos))
+	for _, video := range videos {
+		library[video.Path] = struct{}{}
+	}
+	statuses := map[string]progressapp.Status{}
+	for path, status := range h.progress.Statuses(userID) {
+		if _, ok := library[path]; !ok {
+			// An empty listing more likely means an unmounted library than
+			// one that was emptied, so nothing is dropped then.
+			if len(videos) > 0 {
+				h.progress.RemovePath(path)
+			}
+			continue
+		}
+		if rel, ok := h.unscopePath(r, path); ok {
+			statuses[rel] = status
+		}
+	}
+	return h.scopeVideos(r, videos), statuses, nil
+}
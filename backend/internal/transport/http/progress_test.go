@@ -0,0 +1,143 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authapp "evd/internal/application/auth"
+	progressapp "evd/internal/application/progress"
+	mediadomain "evd/internal/domain/media"
+)
+
+func TestProgress_SaveAndListWithinUserLibrary(t *testing.T) {
+	progress, err := progressapp.NewService("")
+	if err != nil {
+		t.Fatalf("new progress service: %v", err)
+	}
+	handler := NewHandler(nil, nil, &fakePathStore{}, nil, &fakeAuth{}, nil, nil)
+	handler.EnableUserLibraries()
+	handler.EnableProgress(progress)
+	alice := authapp.User{ID: "u1", Username: "alice"}
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"path":"shows/ep1.mkv","position":95.5,"duration":1200}`)
+	handler.SaveProgress(rec, withUser(httptest.NewRequest(http.MethodPost, "/api/progress", body), alice))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected save to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if entries := progress.GetPositions("u1"); len(entries) != 1 || entries[0].Path != "u1/shows/ep1.mkv" {
+		t.Fatalf("expected the position stored under the library path, got %+v", entries)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ListProgress(rec, withUser(httptest.NewRequest(http.MethodGet, "/api/progress", nil), alice))
+	var payload struct {
+		Items []progressapp.Entry `json:"items"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(payload.Items) != 1 || payload.Items[0].Path != "shows/ep1.mkv" || payload.Items[0].Position != 95.5 {
+		t.Fatalf("expected the entry relative to the user's folder, got %+v", payload.Items)
+	}
+
+	rec = httptest.NewRecorder()
+	body = strings.NewReader(`{"path":"shows/ep1.mkv","position":-3}`)
+	handler.SaveProgress(rec, withUser(httptest.NewRequest(http.MethodPost, "/api/progress", body), alice))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a negative position, got %d", rec.Code)
+	}
+}
+
+func TestListVideos_JoinsUserProgress(t *testing.T) {
+	progress, err := progressapp.NewService("")
+	if err != nil {
+		t.Fatalf("new progress service: %v", err)
+	}
+	if err := progress.SavePosition("u1", "u1/a.mp4", 990, 1000); err != nil {
+		t.Fatalf("finish a: %v", err)
+	}
+	if err := progress.SavePosition("u1", "u1/b.mp4", 30, 1000); err != nil {
+		t.Fatalf("save b: %v", err)
+	}
+	if err := progress.SavePosition("u2", "u1/c.mp4", 30, 1000); err != nil {
+		t.Fatalf("save other user: %v", err)
+	}
+	media := &fakeMedia{videos: []mediadomain.Video{
+		{Name: "a.mp4", Path: "u1/a.mp4"},
+		{Name: "b.mp4", Path: "u1/b.mp4"},
+		{Name: "c.mp4", Path: "u1/c.mp4"},
+	}}
+	handler := NewHandler(media, nil, nil, nil, &fakeAuth{}, nil, nil)
+	handler.EnableUserLibraries()
+
+	list := func() map[string]map[string]interface{} {
+		rec := httptest.NewRecorder()
+		handler.ListVideos(rec, withUser(httptest.NewRequest(http.MethodGet, "/api/videos", nil), authapp.User{ID: "u1", Username: "alice"}))
+		var items []map[string]interface{}
+		if err := json.NewDecoder(rec.Body).Decode(&items); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		byPath := map[string]map[string]interface{}{}
+		for _, item := range items {
+			byPath[item["path"].(string)] = item
+		}
+		return byPath
+	}
+
+	if items := list(); items["a.mp4"] == nil || items["a.mp4"]["watched"] != nil {
+		t.Fatalf("expected the plain listing without progress tracking, got %v", items)
+	}
+
+	handler.EnableProgress(progress)
+	items := list()
+	if items["a.mp4"]["watched"] != true || items["a.mp4"]["positionSeconds"] != 0.0 {
+		t.Fatalf("expected a.mp4 watched, got %v", items["a.mp4"])
+	}
+	if items["b.mp4"]["watched"] != false || items["b.mp4"]["positionSeconds"] != 30.0 {
+		t.Fatalf("expected b.mp4 in progress at 30s, got %v", items["b.mp4"])
+	}
+	if items["c.mp4"]["watched"] != false || items["c.mp4"]["positionSeconds"] != 0.0 {
+		t.Fatalf("expected another user's progress to be ignored, got %v", items["c.mp4"])
+	}
+}
+
+func TestListVideos_DropsProgressOfVideosGoneFromLibrary(t *testing.T) {
+	progress, err := progressapp.NewService("")
+	if err != nil {
+		t.Fatalf("new progress service: %v", err)
+	}
+	if err := progress.SavePosition("u1", "u1/a.mp4", 30, 1000); err != nil {
+		t.Fatalf("save a: %v", err)
+	}
+	if err := progress.SavePosition("u1", "u1/gone.mp4", 990, 1000); err != nil {
+		t.Fatalf("finish gone: %v", err)
+	}
+	media := &fakeMedia{}
+	handler := NewHandler(media, nil, nil, nil, &fakeAuth{}, nil, nil)
+	handler.EnableUserLibraries()
+	handler.EnableProgress(progress)
+	list := func() {
+		rec := httptest.NewRecorder()
+		handler.ListVideos(rec, withUser(httptest.NewRequest(http.MethodGet, "/api/videos", nil), authapp.User{ID: "u1", Username: "alice"}))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list: %d %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	// An empty library is treated as unavailable and keeps everything.
+	list()
+	if statuses := progress.Statuses("u1"); len(statuses) != 2 {
+		t.Fatalf("expected progress kept for an empty listing, got %+v", statuses)
+	}
+
+	media.videos = []mediadomain.Video{{Name: "a.mp4", Path: "u1/a.mp4"}}
+	list()
+	statuses := progress.Statuses("u1")
+	if _, ok := statuses["u1/gone.mp4"]; ok || len(statuses) != 1 {
+		t.Fatalf("expected progress of the removed video dropped, got %+v", statuses)
+	}
+}
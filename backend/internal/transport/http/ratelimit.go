@@ -0,0 +1,152 @@
+package http
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// authLimiterSweepInterval is how often idle auth rate limit buckets are dropped.
+const authLimiterSweepInterval = 5 * time.Minute
+
+// AuthRateLimit throttles the login, registration and guest endpoints per client
+// IP and, for logins, per username.
+type AuthRateLimit struct {
+	// PerMinute is the sustained number of attempts allowed; zero disables
+	// rate limiting.
+	PerMinute int
+	// Burst is how many attempts may be made back to back. Defaults to PerMinute.
+	Burst int
+	// TrustProxyHeaders takes the client IP from X-Real-IP, as set by the
+	// bundled nginx, instead of the connection address. Only enable it when
+	// clients cannot reach the backend directly.
+	TrustProxyHeaders bool
+}
+
+// EnableAuthRateLimit turns on auth endpoint throttling and starts dropping idle
+// buckets until ctx is done.
+func (h *Handler) EnableAuthRateLimit(ctx context.Context, limit AuthRateLimit) {
+	if limit.PerMinute <= 0 {
+		return
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = limit.PerMinute
+	}
+
+	h.authLimiter = newRateLimiter(float64(limit.PerMinute)/60, float64(limit.Burst))
+	h.trustProxyHeaders = limit.TrustProxyHeaders
+	go h.authLimiter.runSweeper(ctx, authLimiterSweepInterval)
+}
+
+// LimitAuth rejects requests from client IPs that exhausted their auth attempts
+// with 429 and Retry-After.
+func (h *Handler) LimitAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.allowAuthAttempt(w, "ip:"+h.clientIP(r)) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowAuthAttempt takes a token from key's bucket. Without one it writes the
+// 429 response and reports false.
+func (h *Handler) allowAuthAttempt(w http.ResponseWriter, key string) bool {
+	if h.authLimiter == nil {
+		return true
+	}
+	ok, retryAfter := h.authLimiter.allow(key)
+	if ok {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	http.Error(w, "Too many attempts, try again later", http.StatusTooManyRequests)
+	return false
+}
+
+func (h *Handler) clientIP(r *http.Request) string {
+	if h.trustProxyHeaders {
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimiter is a set of token buckets keyed by client, refilled at rate tokens
+// per second up to burst.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate, burst float64) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: burst, now: time.Now, buckets: make(map[string]*tokenBucket)}
+}
+
+// allow takes a token for key. When none is left it reports how long until the
+// next one.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// sweep drops buckets that have refilled completely; they behave like new ones.
+func (l *rateLimiter) sweep() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *rateLimiter) runSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.sweep()
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	authapp "evd/internal/application/auth"
+)
+
+type loginAuth struct {
+	fakeAuth
+}
+
+func (loginAuth) Login(string, string) (authapp.User, string, error) {
+	return authapp.User{}, "", authapp.ErrInvalidCredentials
+}
+
+func TestLimitAuth_RejectsAttemptsOverBurst(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler := NewHandler(nil, nil, nil, nil, &loginAuth{}, nil, nil)
+	handler.EnableAuthRateLimit(ctx, AuthRateLimit{PerMinute: 3})
+	login := handler.LimitAuth(http.HandlerFunc(handler.Login))
+
+	attempt := func(remoteAddr, username string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"username":"`+username+`","password":"x"}`))
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		login.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 3; i++ {
+		if rec := attempt("10.0.0.1:5000", "alice"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i+1, rec.Code)
+		}
+	}
+	rec := attempt("10.0.0.1:5001", "bob")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "20" {
+		t.Fatalf("expected 429 with Retry-After for the fourth attempt from one IP, got %d %v", rec.Code, rec.Header())
+	}
+
+	// The username bucket limits guessing one account from many addresses.
+	if rec := attempt("10.0.0.2:5000", "Alice"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected exhausted username to be limited from a new IP, got %d", rec.Code)
+	}
+	if rec := attempt("10.0.0.3:5000", "carol"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected other clients unaffected, got %d", rec.Code)
+	}
+}
+
+func TestRateLimiter_RefillsAndSweepsIdleBuckets(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter(1, 2)
+	limiter.now = func() time.Time { return now }
+
+	limiter.allow("a")
+	limiter.allow("a")
+	if ok, retry := limiter.allow("a"); ok || retry != time.Second {
+		t.Fatalf("expected empty bucket with 1s retry, got %t %s", ok, retry)
+	}
+
+	now = now.Add(time.Second)
+	if ok, _ := limiter.allow("a"); !ok {
+		t.Fatalf("expected a token after refill")
+	}
+	limiter.sweep()
+	if len(limiter.buckets) != 1 {
+		t.Fatalf("expected partially drained bucket kept")
+	}
+	now = now.Add(2 * time.Second)
+	limiter.sweep()
+	if len(limiter.buckets) != 0 {
+		t.Fatalf("expected refilled bucket dropped")
+	}
+}
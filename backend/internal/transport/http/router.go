@@ -9,35 +9,101 @@ import (
 // NewRouter configures HTTP routes and static HLS serving.
 func NewRouter(handler *Handler, hlsDir string) *mux.Router {
 	r := mux.NewRouter()
-	r.HandleFunc("/api/auth/register", handler.Register).Methods("POST")
-	r.HandleFunc("/api/auth/login", handler.Login).Methods("POST")
-	r.HandleFunc("/api/auth/guest", handler.LoginGuest).Methods("POST")
+	r.HandleFunc("/healthz", handler.Healthz).Methods("GET")
+	r.HandleFunc("/readyz", handler.Readyz).Methods("GET")
+	if handler.metricsEndpoint != nil {
+		r.Handle("/metrics", handler.metricsEndpoint).Methods("GET")
+	}
+	r.Handle("/api/auth/register", handler.LimitAuth(http.HandlerFunc(handler.Register))).Methods("POST")
+	r.Handle("/api/auth/login", handler.LimitAuth(http.HandlerFunc(handler.Login))).Methods("POST")
+	r.Handle("/api/auth/guest", handler.LimitAuth(http.HandlerFunc(handler.LoginGuest))).Methods("POST")
 	r.HandleFunc("/api/auth/logout", handler.Logout).Methods("POST")
 	r.HandleFunc("/api/auth/me", handler.Me).Methods("GET")
+	r.HandleFunc("/api/config", handler.ClientConfig).Methods("GET")
 
 	api := r.PathPrefix("/api").Subrouter()
 	api.Use(handler.RequireAuth)
+	api.HandleFunc("/auth/password", handler.ChangePassword).Methods("POST")
+	api.HandleFunc("/auth/usage", handler.Usage).Methods("GET")
+	api.HandleFunc("/auth/sessions", handler.ListSessions).Methods("GET")
+	api.HandleFunc("/auth/sessions/revoke-all", handler.RevokeAllSessions).Methods("POST")
+	api.Handle("/auth/users/{id}/role", handler.RequireAdmin(http.HandlerFunc(handler.AdminSetUserRole))).Methods("POST")
 	api.HandleFunc("/videos", handler.ListVideos).Methods("GET")
-	api.HandleFunc("/stream/{path:.*}", handler.StreamVideo).Methods("GET")
-	api.HandleFunc("/play/{path:.*}", handler.StreamPlay).Methods("GET")
-	api.HandleFunc("/stream-mp4/{path:.*}", handler.StreamMP4).Methods("GET")
+	api.HandleFunc("/videos/search", handler.SearchVideos).Methods("GET")
+	api.Handle("/videos/move", handler.RequireLibraryOwner(http.HandlerFunc(handler.MoveVideo))).Methods("POST")
+	api.Handle("/videos/{path:.*}", handler.RequireAdmin(http.HandlerFunc(handler.DeleteVideo))).Methods("DELETE")
+	api.HandleFunc("/trash", handler.ListTrash).Methods("GET")
+	api.Handle("/trash/restore", handler.RequireLibraryOwner(http.HandlerFunc(handler.RestoreFromTrash))).Methods("POST")
+	api.HandleFunc("/browse", handler.Browse).Methods("GET")
+	api.HandleFunc("/thumb/{path:.*}", handler.Thumbnail).Methods("GET")
+	api.HandleFunc("/probe/{path:.*}", handler.Probe).Methods("GET")
+	api.Handle("/stream/{path:.*}", handler.StreamAccessLog(http.HandlerFunc(handler.StreamVideo))).Methods("GET", "HEAD")
+	api.Handle("/play/{path:.*}", handler.StreamAccessLog(http.HandlerFunc(handler.StreamPlay))).Methods("GET")
+	api.Handle("/stream-mp4/{path:.*}", handler.StreamAccessLog(http.HandlerFunc(handler.StreamMP4))).Methods("GET", "HEAD")
 	api.HandleFunc("/hls-start/{path:.*}", handler.StartHLS).Methods("POST")
 	api.HandleFunc("/hls-status/{path:.*}", handler.HLSStatus).Methods("GET")
+	api.HandleFunc("/hls-pause/{path:.*}", handler.PauseHLS).Methods("POST")
+	api.HandleFunc("/hls-resume/{path:.*}", handler.ResumeHLS).Methods("POST")
+	api.HandleFunc("/hls-cancel/{path:.*}", handler.CancelHLS).Methods("POST")
 	api.HandleFunc("/mp4-start/{path:.*}", handler.StartMP4).Methods("POST")
+	api.HandleFunc("/mp4-pause/{path:.*}", handler.PauseMP4).Methods("POST")
+	api.HandleFunc("/mp4-cancel/{path:.*}", handler.CancelMP4).Methods("POST")
 	api.HandleFunc("/mp4-status/{path:.*}", handler.MP4Status).Methods("GET")
+	api.HandleFunc("/artifacts/status", handler.ArtifactStatuses).Methods("POST")
+	api.Handle("/artifacts/{path:.*}", handler.RequireAdmin(http.HandlerFunc(handler.ClearArtifacts))).Methods("DELETE")
+	api.HandleFunc("/ingest", handler.IngestURL).Methods("POST")
+	api.HandleFunc("/ingest-status/{path:.*}", handler.IngestStatus).Methods("GET")
 	api.HandleFunc("/upload", handler.UploadChunk).Methods("POST")
+	api.HandleFunc("/upload", handler.CancelUpload).Methods("DELETE")
+	api.HandleFunc("/upload/status", handler.UploadStatus).Methods("GET")
 	api.HandleFunc("/torrents", handler.ListTorrents).Methods("GET")
-	api.HandleFunc("/torrent/upload", handler.UploadTorrent).Methods("POST")
+	api.HandleFunc("/torrents/stats", handler.TorrentStats).Methods("GET")
+	api.Handle("/torrent/upload", handler.RequireAdmin(http.HandlerFunc(handler.UploadTorrent))).Methods("POST")
+	api.Handle("/torrent/magnet", handler.RequireAdmin(http.HandlerFunc(handler.AddMagnet))).Methods("POST")
 	api.HandleFunc("/torrent/stream/{id}", handler.EnableTorrentStream).Methods("POST")
+	api.Handle("/torrent/{id}", handler.RequireAdmin(http.HandlerFunc(handler.RemoveTorrent))).Methods("DELETE")
+	api.Handle("/torrent/{id}/pause", handler.RequireAdmin(http.HandlerFunc(handler.PauseTorrent))).Methods("POST")
+	api.Handle("/torrent/{id}/resume", handler.RequireAdmin(http.HandlerFunc(handler.ResumeTorrent))).Methods("POST")
+	api.Handle("/torrent/{id}/files", handler.RequireAdmin(http.HandlerFunc(handler.SetTorrentFiles))).Methods("POST")
+	api.Handle("/torrent/{id}/verify", handler.RequireAdmin(http.HandlerFunc(handler.VerifyTorrent))).Methods("POST")
+	api.Handle("/torrent/{id}/limits", handler.RequireAdmin(http.HandlerFunc(handler.SetTorrentLimits))).Methods("POST")
 	api.HandleFunc("/torrent/focus", handler.FocusTorrentStream).Methods("POST")
+	api.HandleFunc("/torrent/{id}/focus", handler.TorrentFocusState).Methods("GET")
+	api.HandleFunc("/progress", handler.ListProgress).Methods("GET")
+	api.HandleFunc("/progress", handler.SaveProgress).Methods("POST")
+	api.HandleFunc("/watch-hubs", handler.ListWatchHubs).Methods("GET")
 	api.HandleFunc("/watch-hubs", handler.CreateWatchHub).Methods("POST")
+	api.HandleFunc("/watch-hubs/quickstart", handler.QuickstartWatchHub).Methods("POST")
 	api.HandleFunc("/watch-hubs/{id}", handler.GetWatchHub).Methods("GET")
 	api.HandleFunc("/watch-hubs/{id}/control", handler.ControlWatchHub).Methods("POST")
 	api.HandleFunc("/watch-hubs/{id}/chat", handler.SendWatchHubChat).Methods("POST")
+	api.HandleFunc("/watch-hubs/{id}/typing", handler.WatchHubTyping).Methods("POST")
+	api.HandleFunc("/watch-hubs/{id}/react", handler.ReactWatchHub).Methods("POST")
+	api.HandleFunc("/watch-hubs/{id}/kick", handler.KickWatchHubMember).Methods("POST")
+	api.HandleFunc("/watch-hubs/{id}/transfer", handler.TransferWatchHub).Methods("POST")
 	api.HandleFunc("/watch-hubs/{id}/events", handler.WatchHubEvents).Methods("GET")
+	api.HandleFunc("/watch-hubs/{id}/ws", handler.WatchHubSocket).Methods("GET")
 
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(handler.RequireAdmin)
+	admin.HandleFunc("/session-ttl", handler.AdminSessionTTL).Methods("GET")
+	admin.HandleFunc("/session-ttl", handler.AdminSetSessionTTL).Methods("PUT")
+	admin.HandleFunc("/sessions/purge-all", handler.AdminPurgeSessions).Methods("POST")
+	admin.HandleFunc("/status", handler.AdminStatus).Methods("GET")
+	admin.HandleFunc("/quotas", handler.AdminQuotas).Methods("GET")
+	admin.HandleFunc("/quotas/{userId}", handler.AdminSetQuota).Methods("PUT")
+	admin.HandleFunc("/hls-trim/{path:.*}", handler.AdminTrimHLS).Methods("POST")
+
+	if handler.statusPage {
+		r.Handle("/status", handler.RequireAuth(handler.RequireAdmin(http.HandlerFunc(handler.StatusPage)))).Methods("GET")
+	}
+
+	outputs := hlsOutputs{root: hlsDir, store: handler.store}
 	hls := r.PathPrefix("/hls/").Subrouter()
 	hls.Use(handler.RequireAuth)
-	hls.PathPrefix("/").Handler(http.StripPrefix("/hls/", http.FileServer(http.Dir(hlsDir))))
+	hls.Use(handler.scopeHLSFiles)
+	hls.Use(handler.StreamAccessLog)
+	hls.Use(handler.markHLSServed(outputs))
+	hls.PathPrefix("/").Handler(http.StripPrefix("/hls/", hlsFileServer(outputs)))
 	return r
 }
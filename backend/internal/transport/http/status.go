@@ -0,0 +1,208 @@
+package http
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	mediadomain "evd/internal/domain/media"
+	torrentdomain "evd/internal/domain/torrent"
+)
+
+// activeStream describes a streaming response that is currently being served.
+type activeStream struct {
+	ID        uint64    `json:"id"`
+	Kind      string    `json:"kind"`
+	Path      string    `json:"path"`
+	User      string    `json:"user"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// streamRegistry tracks in-flight streaming responses for operational reporting.
+type streamRegistry struct {
+	mu     sync.Mutex
+	nextID uint64
+	active map[uint64]activeStream
+}
+
+func newStreamRegistry() *streamRegistry {
+	return &streamRegistry{active: map[uint64]activeStream{}}
+}
+
+// begin registers a stream and returns the callback that unregisters it.
+func (s *streamRegistry) begin(kind, path, user string) func() {
+	s.mu.Lock()
+	s.nextID++
+	id := s.nextID
+	s.active[id] = activeStream{ID: id, Kind: kind, Path: path, User: user, StartedAt: time.Now()}
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		delete(s.active, id)
+		s.mu.Unlock()
+	}
+}
+
+func (s *streamRegistry) list() []activeStream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]activeStream, 0, len(s.active))
+	for _, item := range s.active {
+		out = append(out, item)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func (h *Handler) trackStream(r *http.Request, kind, path string) func() {
+	username := ""
+	if user, ok := requestUser(r); ok {
+		username = user.Username
+	}
+	return h.streams.begin(kind, path, username)
+}
+
+type torrentStatus struct {
+	Enabled bool                 `json:"enabled"`
+	Error   string               `json:"error,omitempty"`
+	Items   []torrentdomain.Info `json:"items"`
+}
+
+type statusReport struct {
+	GeneratedAt time.Time               `json:"generatedAt"`
+	Streams     []activeStream          `json:"streams"`
+	ServedBytes []userBytes             `json:"servedBytes"`
+	Jobs        []mediadomain.JobInfo   `json:"jobs"`
+	Torrents    torrentStatus           `json:"torrents"`
+	Disks       []mediadomain.DiskUsage `json:"disks"`
+	DiskError   string                  `json:"diskError,omitempty"`
+}
+
+func (h *Handler) buildStatusReport() statusReport {
+	report := statusReport{
+		GeneratedAt: time.Now(),
+		Streams:     h.streams.list(),
+		ServedBytes: h.served.list(),
+		Jobs:        h.media.ActiveJobs(),
+		Torrents:    torrentStatus{Items: []torrentdomain.Info{}},
+		Disks:       []mediadomain.DiskUsage{},
+	}
+
+	if h.torrents.Enabled() {
+		report.Torrents.Enabled = true
+		items, err := h.torrents.List()
+		if err != nil {
+			report.Torrents.Error = err.Error()
+		} else {
+			report.Torrents.Items = items
+		}
+	}
+
+	disks, err := h.store.DiskUsage()
+	if err != nil {
+		report.DiskError = err.Error()
+	} else {
+		report.Disks = disks
+	}
+
+	return report
+}
+
+// AdminStatus returns active streams, jobs, torrents and disk usage as JSON.
+func (h *Handler) AdminStatus(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, h.buildStatusReport())
+}
+
+// EnableStatusPage routes the HTML status page at /status for admins.
+func (h *Handler) EnableStatusPage() {
+	h.statusPage = true
+}
+
+// StatusPage renders the admin status report as a self-contained HTML page.
+func (h *Handler) StatusPage(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := statusTemplate.Execute(w, h.buildStatusReport()); err != nil {
+		http.Error(w, "Unable to render status", http.StatusInternalServerError)
+	}
+}
+
+var statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"bytes": formatBytes,
+	"since": func(t time.Time) string { return time.Since(t).Round(time.Second).String() },
+}).Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="10">
+<title>EVD status</title>
+<style>
+body { font-family: sans-serif; margin: 2rem; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5rem; }
+th, td { border: 1px solid #ccc; padding: 0.3rem 0.6rem; text-align: left; }
+.muted { color: #777; }
+</style>
+</head>
+<body>
+<h1>EVD status</h1>
+<p class="muted">Generated {{.GeneratedAt.Format "2006-01-02 15:04:05"}}</p>
+
+<h2>Active streams ({{len .Streams}})</h2>
+{{if .Streams}}<table>
+<tr><th>Kind</th><th>Path</th><th>User</th><th>Duration</th></tr>
+{{range .Streams}}<tr><td>{{.Kind}}</td><td>{{.Path}}</td><td>{{.User}}</td><td>{{since .StartedAt}}</td></tr>
+{{end}}</table>{{else}}<p class="muted">No active streams.</p>{{end}}
+
+<h2>Running jobs ({{len .Jobs}})</h2>
+{{if .Jobs}}<table>
+<tr><th>Type</th><th>Path</th><th>State</th><th>Progress</th></tr>
+{{range .Jobs}}<tr><td>{{.Type}}</td><td>{{.Path}}</td><td>{{.State}}</td><td>{{.Progress}}%</td></tr>
+{{end}}</table>{{else}}<p class="muted">No running jobs.</p>{{end}}
+
+<h2>Torrents</h2>
+{{if not .Torrents.Enabled}}<p class="muted">Transmission is not configured.</p>
+{{else if .Torrents.Error}}<p>Transmission error: {{.Torrents.Error}}</p>
+{{else if .Torrents.Items}}<table>
+<tr><th>Name</th><th>Status</th><th>Progress</th><th>Download rate</th></tr>
+{{range .Torrents.Items}}<tr><td>{{.Name}}</td><td>{{.Status}}</td><td>{{.Progress}}%</td><td>{{bytes .RateDownload}}/s</td></tr>
+{{end}}</table>{{else}}<p class="muted">No torrents.</p>{{end}}
+
+<h2>Disk usage</h2>
+{{if .DiskError}}<p>Disk usage unavailable: {{.DiskError}}</p>
+{{else}}<table>
+<tr><th>Root</th><th>Path</th><th>Used</th><th>Free</th><th>Total</th></tr>
+{{range .Disks}}<tr><td>{{.Name}}</td><td>{{.Path}}</td><td>{{bytes .UsedBytes}}</td><td>{{bytes .FreeBytes}}</td><td>{{bytes .TotalBytes}}</td></tr>
+{{end}}</table>{{end}}
+</body>
+</html>
+`))
+
+func formatBytes(value interface{}) string {
+	var size float64
+	switch v := value.(type) {
+	case int64:
+		size = float64(v)
+	case uint64:
+		size = float64(v)
+	case int:
+		size = float64(v)
+	default:
+		return "?"
+	}
+
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	unit := 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%.0f %s", size, units[unit])
+	}
+	return fmt.Sprintf("%.1f %s", size, units[unit])
+}
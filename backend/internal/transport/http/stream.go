@@ -1,16 +1,50 @@
 package http
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+
+	mediadomain "evd/internal/domain/media"
 )
 
+// init registers the HLS and video MIME types that many systems' MIME
+// databases lack, for streams and the HLS file server alike.
+func init() {
+	_ = mime.AddExtensionType(".m3u8", "application/vnd.apple.mpegurl")
+	_ = mime.AddExtensionType(".m4s", "video/iso.segment")
+	for ext, contentType := range mediadomain.VideoContentTypes() {
+		_ = mime.AddExtensionType(ext, contentType)
+	}
+}
+
+// contentTypeFor returns the MIME type to stream fullPath with.
+func contentTypeFor(fullPath string) string {
+	ext := strings.ToLower(filepath.Ext(fullPath))
+	if contentType := mediadomain.VideoContentType(ext); contentType != "" {
+		return contentType
+	}
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// streamFile serves fullPath with range support. An empty contentType is
+// derived from the file extension.
 func streamFile(w http.ResponseWriter, r *http.Request, fullPath, contentType string) {
+	if contentType == "" {
+		contentType = contentTypeFor(fullPath)
+	}
 	file, err := os.Open(fullPath)
 	if err != nil {
 		http.Error(w, "Video not found", http.StatusNotFound)
@@ -24,55 +58,246 @@ func streamFile(w http.ResponseWriter, r *http.Request, fullPath, contentType st
 		return
 	}
 
-	fileSize := info.Size()
-	w.Header().Set("Accept-Ranges", "bytes")
-	w.Header().Set("Content-Type", contentType)
-
-	rangeHeader := r.Header.Get("Range")
-	if rangeHeader == "" {
-		w.Header().Set("Content-Length", strconv.FormatInt(fileSize, 10))
-		w.WriteHeader(http.StatusOK)
-		_, _ = io.Copy(w, file)
+	if notModified(w, r, info.Size(), info.ModTime()) {
 		return
 	}
+	serveFileRange(w, r, file, info.Size(), contentType)
+}
 
-	var start, end int64
-	if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-", &start); err != nil {
-		http.Error(w, "Invalid range", http.StatusRequestedRangeNotSatisfiable)
-		return
+// fileETag is a weak validator derived from a file's size and modification time.
+func fileETag(size int64, modTime time.Time) string {
+	return fmt.Sprintf(`W/"%x-%x"`, size, modTime.UnixNano())
+}
+
+// notModified sets ETag and Last-Modified for a file and, when the request's
+// If-None-Match or (without it) If-Modified-Since shows the client already has
+// this revision, answers 304 and reports true.
+func notModified(w http.ResponseWriter, r *http.Request, size int64, modTime time.Time) bool {
+	etag := fileETag(size, modTime)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
 	}
 
-	end = fileSize - 1
-	if strings.Contains(rangeHeader, "-") {
-		parts := strings.Split(strings.TrimPrefix(rangeHeader, "bytes="), "-")
-		if len(parts) == 2 && parts[1] != "" {
-			_, _ = fmt.Sscanf(parts[1], "%d", &end)
+	matched := false
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				matched = true
+				break
+			}
 		}
+	} else if ims, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil {
+		matched = !modTime.Truncate(time.Second).After(ims)
 	}
+	if !matched {
+		return false
+	}
+
+	w.Header().Del("Content-Type")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// maxByteRanges caps the ranges served as one multipart response; requests with
+// more get the whole file, as do ranges adding up to more than the file.
+const maxByteRanges = 32
+
+var (
+	errMalformedRange     = errors.New("malformed range")
+	errUnsatisfiableRange = errors.New("range not satisfiable")
+)
+
+// byteRange is an inclusive span of a file.
+type byteRange struct {
+	start, end int64
+}
+
+func (br byteRange) length() int64 { return br.end - br.start + 1 }
+
+func (br byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", br.start, br.end, size)
+}
 
-	if start < 0 || start >= fileSize {
+// serveFileRange writes content, the requested single range, or several ranges
+// as multipart/byteranges from file. Reads go through io.SectionReader, which
+// uses ReadAt and never moves a shared file offset, so one handle can safely
+// serve concurrent requests. HEAD requests get the same status and headers
+// without a body.
+func serveFileRange(w http.ResponseWriter, r *http.Request, file io.ReaderAt, fileSize int64, contentType string) {
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	ranges, err := parseByteRanges(r.Header.Get("Range"), fileSize)
+	if err != nil {
 		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", fileSize))
 		http.Error(w, "Invalid range", http.StatusRequestedRangeNotSatisfiable)
 		return
 	}
-	if end >= fileSize {
-		end = fileSize - 1
+	send := func(body io.Reader) {
+		if r.Method != http.MethodHead {
+			_, _ = io.Copy(w, body)
+		}
 	}
-	if start > end {
-		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", fileSize))
-		http.Error(w, "Invalid range", http.StatusRequestedRangeNotSatisfiable)
-		return
+
+	switch len(ranges) {
+	case 0:
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(fileSize, 10))
+		w.WriteHeader(http.StatusOK)
+		send(io.NewSectionReader(file, 0, fileSize))
+	case 1:
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(ranges[0].length(), 10))
+		w.Header().Set("Content-Range", ranges[0].contentRange(fileSize))
+		w.WriteHeader(http.StatusPartialContent)
+		send(io.NewSectionReader(file, ranges[0].start, ranges[0].length()))
+	default:
+		pr, pw := io.Pipe()
+		parts := multipart.NewWriter(pw)
+		w.Header().Set("Content-Type", "multipart/byteranges; boundary="+parts.Boundary())
+		w.Header().Set("Content-Length", strconv.FormatInt(multipartRangesSize(ranges, contentType, fileSize, parts.Boundary()), 10))
+		w.WriteHeader(http.StatusPartialContent)
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		go func() {
+			for _, br := range ranges {
+				part, err := parts.CreatePart(rangePartHeader(br, contentType, fileSize))
+				if err == nil {
+					_, err = io.Copy(part, io.NewSectionReader(file, br.start, br.length()))
+				}
+				if err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+			}
+			pw.CloseWithError(parts.Close())
+		}()
+		_, _ = io.Copy(w, pr)
+		// Unblocks the writer when the client went away mid-response.
+		_ = pr.Close()
 	}
+}
 
-	contentLength := end - start + 1
-	w.Header().Set("Content-Length", strconv.FormatInt(contentLength, 10))
-	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, fileSize))
-	w.WriteHeader(http.StatusPartialContent)
-	_, _ = file.Seek(start, 0)
-	_, _ = io.CopyN(w, file, contentLength)
+// parseByteRanges parses a Range header against a file of size bytes. It
+// understands `bytes=N-`, `bytes=N-M`, suffix ranges (`bytes=-N`, the last N
+// bytes) and comma-separated lists of them. Ranges starting past the end are
+// dropped; errUnsatisfiableRange is returned when none remains. No ranges and no
+// error mean the whole file should be sent: the header is absent, or it asks for
+// too many or too much overlapping data to be worth honouring.
+func parseByteRanges(header string, size int64) ([]byteRange, error) {
+	if header == "" {
+		return nil, nil
+	}
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok {
+		return nil, errMalformedRange
+	}
+
+	var ranges []byteRange
+	var total int64
+	for _, raw := range strings.Split(spec, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		first, last, ok := strings.Cut(raw, "-")
+		if !ok {
+			return nil, errMalformedRange
+		}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+
+		var br byteRange
+		if first == "" {
+			n, err := parseRangeInt(last)
+			if err != nil || n == 0 {
+				return nil, errMalformedRange
+			}
+			if size == 0 {
+				continue
+			}
+			br = byteRange{start: max(size-n, 0), end: size - 1}
+		} else {
+			start, err := parseRangeInt(first)
+			if err != nil {
+				return nil, errMalformedRange
+			}
+			br = byteRange{start: start, end: size - 1}
+			if last != "" {
+				end, err := parseRangeInt(last)
+				if err != nil || end < start {
+					return nil, errMalformedRange
+				}
+				br.end = min(end, size-1)
+			}
+			if start >= size {
+				continue
+			}
+		}
+		ranges = append(ranges, br)
+		total += br.length()
+	}
+
+	if len(ranges) == 0 {
+		return nil, errUnsatisfiableRange
+	}
+	if len(ranges) > maxByteRanges || total > size {
+		return nil, nil
+	}
+	return ranges, nil
+}
+
+func parseRangeInt(value string) (int64, error) {
+	if value == "" || strings.TrimLeft(value, "0123456789") != "" {
+		return 0, errMalformedRange
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func rangePartHeader(br byteRange, contentType string, size int64) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Type":  {contentType},
+		"Content-Range": {br.contentRange(size)},
+	}
 }
 
-func streamGrowingFile(w http.ResponseWriter, r *http.Request, fullPath, contentType string, done func() bool) {
+// multipartRangesSize returns the exact body length of a multipart/byteranges
+// response by writing its framing to a counter and adding the range lengths.
+func multipartRangesSize(ranges []byteRange, contentType string, size int64, boundary string) int64 {
+	var counter countingDiscard
+	parts := multipart.NewWriter(&counter)
+	_ = parts.SetBoundary(boundary)
+	for _, br := range ranges {
+		_, _ = parts.CreatePart(rangePartHeader(br, contentType, size))
+		counter += countingDiscard(br.length())
+	}
+	_ = parts.Close()
+	return int64(counter)
+}
+
+type countingDiscard int64
+
+func (c *countingDiscard) Write(p []byte) (int, error) {
+	*c += countingDiscard(len(p))
+	return len(p), nil
+}
+
+// growPollInterval is how often a growing file is re-checked for new data.
+var growPollInterval = 250 * time.Millisecond
+
+// streamGrowingFile serves a file that may still be written to. A request without a
+// range, or for `bytes=0-`, gets a 200 with no Content-Length that keeps reading as
+// the file grows, blocking at EOF until new data arrives, the client disconnects, done
+// reports completion, or no growth is seen for idleTimeout. Any other range waits
+// until its first byte exists and is then served from a fresh stat: an open-ended
+// range gets the bytes written so far and a bounded one gets its end, or whatever
+// exists once the file stops growing. Ranged responses advertise an unknown complete
+// length (`/*`), so players ask again for the next range.
+func streamGrowingFile(w http.ResponseWriter, r *http.Request, fullPath, contentType string, idleTimeout time.Duration, done func() bool) {
 	file, err := os.Open(fullPath)
 	if err != nil {
 		http.Error(w, "Video not found", http.StatusNotFound)
@@ -80,17 +305,70 @@ func streamGrowingFile(w http.ResponseWriter, r *http.Request, fullPath, content
 	}
 	defer file.Close()
 
+	var start int64
+	end := int64(-1)
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		start, end, err = parseGrowingRange(rangeHeader)
+		if err != nil {
+			http.Error(w, "Invalid range", http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Cache-Control", "no-cache")
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Accept-Ranges", "bytes")
+	if start == 0 && end < 0 {
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			followFile(w, r, file, idleTimeout, done)
+		}
+		return
+	}
+
+	if !waitForSize(r, file, start+1, idleTimeout, done) {
+		w.Header().Set("Content-Range", "bytes */*")
+		http.Error(w, "Invalid range", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	if end >= 0 {
+		// A bounded range past the current end waits for it; if the file stops
+		// short, the fresh stat below clamps the range to what was written.
+		waitForSize(r, file, end+1, idleTimeout, done)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if end < 0 || end >= info.Size() {
+		end = info.Size() - 1
+	}
+
+	br := byteRange{start: start, end: end}
+	w.Header().Set("Content-Length", strconv.FormatInt(br.length(), 10))
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/*", br.start, br.end))
+	w.WriteHeader(http.StatusPartialContent)
+	if r.Method != http.MethodHead {
+		_, _ = io.Copy(w, io.NewSectionReader(file, br.start, br.length()))
+	}
+}
 
+// followFile copies file to w from its current offset, flushing each chunk and
+// waiting at EOF for more data until done reports completion, the client goes away,
+// or nothing new arrives for idleTimeout.
+func followFile(w http.ResponseWriter, r *http.Request, file *os.File, idleTimeout time.Duration, done func() bool) {
 	flusher, _ := w.(http.Flusher)
 	buf := make([]byte, 32*1024)
+	lastGrow := time.Now()
 
 	for {
 		n, err := file.Read(buf)
 		if n > 0 {
-			_, _ = w.Write(buf[:n])
+			lastGrow = time.Now()
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
 			if flusher != nil {
 				flusher.Flush()
 			}
@@ -100,10 +378,13 @@ func streamGrowingFile(w http.ResponseWriter, r *http.Request, fullPath, content
 			if done != nil && done() {
 				return
 			}
+			if idleTimeout > 0 && time.Since(lastGrow) >= idleTimeout {
+				return
+			}
 			select {
 			case <-r.Context().Done():
 				return
-			case <-time.After(250 * time.Millisecond):
+			case <-time.After(growPollInterval):
 			}
 			continue
 		}
@@ -112,3 +393,58 @@ func streamGrowingFile(w http.ResponseWriter, r *http.Request, fullPath, content
 		}
 	}
 }
+
+// parseGrowingRange parses a single `bytes=N-` or `bytes=N-M` range; end is -1 when open-ended.
+func parseGrowingRange(header string) (int64, int64, error) {
+	spec := strings.TrimSpace(header)
+	if !strings.HasPrefix(spec, "bytes=") {
+		return 0, 0, fmt.Errorf("unsupported range unit")
+	}
+	parts := strings.SplitN(strings.TrimPrefix(spec, "bytes="), "-", 2)
+	if len(parts) != 2 || strings.Contains(parts[1], ",") {
+		return 0, 0, fmt.Errorf("malformed range")
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("malformed range start")
+	}
+	if strings.TrimSpace(parts[1]) == "" {
+		return start, -1, nil
+	}
+	end, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+	if err != nil || end < start {
+		return 0, 0, fmt.Errorf("malformed range end")
+	}
+	return start, end, nil
+}
+
+// waitForSize blocks until the file reaches minSize bytes, reporting false on idle timeout,
+// completion without reaching the size, or client disconnect.
+func waitForSize(r *http.Request, file *os.File, minSize int64, idleTimeout time.Duration, done func() bool) bool {
+	lastSize := int64(-1)
+	lastGrow := time.Now()
+	for {
+		info, err := file.Stat()
+		if err != nil {
+			return false
+		}
+		if info.Size() >= minSize {
+			return true
+		}
+		if info.Size() != lastSize {
+			lastSize = info.Size()
+			lastGrow = time.Now()
+		}
+		if done != nil && done() {
+			return false
+		}
+		if idleTimeout > 0 && time.Since(lastGrow) >= idleTimeout {
+			return false
+		}
+		select {
+		case <-r.Context().Done():
+			return false
+		case <-time.After(growPollInterval):
+		}
+	}
+}
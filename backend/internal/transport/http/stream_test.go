@@ -0,0 +1,457 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	authapp "evd/internal/application/auth"
+	quotaapp "evd/internal/application/quota"
+	"github.com/gorilla/mux"
+)
+
+func writeTempFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	return path
+}
+
+func TestStreamGrowingFile_FullRequestFollowsAppends(t *testing.T) {
+	prevPoll := growPollInterval
+	growPollInterval = 10 * time.Millisecond
+	defer func() { growPollInterval = prevPoll }()
+
+	path := writeTempFile(t, []byte("0123456789"))
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return
+		}
+		_, _ = file.WriteString("hello")
+		time.Sleep(50 * time.Millisecond)
+		_, _ = file.WriteString(" world")
+		_ = file.Close()
+	}()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stream/video.mp4?follow=1", nil)
+	req.Header.Set("Range", "bytes=0-")
+	rec := httptest.NewRecorder()
+
+	streamGrowingFile(rec, req, path, "video/mp4", 300*time.Millisecond, nil)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got := rec.Body.String(); got != "0123456789hello world" {
+		t.Fatalf("expected appended bytes, got %q", got)
+	}
+	if got := rec.Header().Get("Content-Length"); got != "" {
+		t.Fatalf("expected no Content-Length while following, got %q", got)
+	}
+}
+
+func TestStreamGrowingFile_OpenEndedRangeUsesFreshSize(t *testing.T) {
+	prevPoll := growPollInterval
+	growPollInterval = 10 * time.Millisecond
+	defer func() { growPollInterval = prevPoll }()
+
+	path := writeTempFile(t, []byte("0123456789"))
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = os.WriteFile(path, []byte("0123456789hello"), 0o644)
+	}()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stream/video.mp4?follow=1", nil)
+	req.Header.Set("Range", "bytes=10-")
+	rec := httptest.NewRecorder()
+
+	streamGrowingFile(rec, req, path, "video/mp4", time.Second, nil)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("expected 206, got %d", rec.Code)
+	}
+	if got := rec.Body.String(); got != "hello" {
+		t.Fatalf("expected bytes written so far, got %q", got)
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 10-14/*" {
+		t.Fatalf("expected Content-Range matching the body, got %q", got)
+	}
+	if got := rec.Header().Get("Content-Length"); got != "5" {
+		t.Fatalf("expected Content-Length 5, got %q", got)
+	}
+}
+
+func TestStreamGrowingFile_BoundedRangeClampsWhenGrowthStops(t *testing.T) {
+	prevPoll := growPollInterval
+	growPollInterval = 10 * time.Millisecond
+	defer func() { growPollInterval = prevPoll }()
+
+	path := writeTempFile(t, []byte("0123456789"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stream/video.mp4?follow=1", nil)
+	req.Header.Set("Range", "bytes=5-50")
+	rec := httptest.NewRecorder()
+
+	streamGrowingFile(rec, req, path, "video/mp4", 50*time.Millisecond, nil)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("expected 206, got %d", rec.Code)
+	}
+	if got := rec.Body.String(); got != "56789" {
+		t.Fatalf("expected the bytes that exist, got %q", got)
+	}
+	if got := rec.Header().Get("Content-Length"); got != "5" {
+		t.Fatalf("expected Content-Length 5, got %q", got)
+	}
+}
+
+func TestStreamGrowingFile_BoundedRangeStopsAtEnd(t *testing.T) {
+	path := writeTempFile(t, []byte("0123456789"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stream/video.mp4?follow=1", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	rec := httptest.NewRecorder()
+
+	streamGrowingFile(rec, req, path, "video/mp4", time.Second, nil)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("expected 206, got %d", rec.Code)
+	}
+	if got := rec.Body.String(); got != "2345" {
+		t.Fatalf("expected bytes 2-5, got %q", got)
+	}
+	if got := rec.Header().Get("Content-Length"); got != "4" {
+		t.Fatalf("expected Content-Length 4, got %q", got)
+	}
+}
+
+func TestStreamGrowingFile_RangePastEOFTimesOut(t *testing.T) {
+	prevPoll := growPollInterval
+	growPollInterval = 10 * time.Millisecond
+	defer func() { growPollInterval = prevPoll }()
+
+	path := writeTempFile(t, []byte("0123456789"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stream/video.mp4?follow=1", nil)
+	req.Header.Set("Range", "bytes=50-")
+	rec := httptest.NewRecorder()
+
+	streamGrowingFile(rec, req, path, "video/mp4", 50*time.Millisecond, nil)
+
+	if rec.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("expected 416 when file never grows, got %d", rec.Code)
+	}
+}
+
+func TestServeFileRange_ConcurrentRangesOnOneHandle(t *testing.T) {
+	data := make([]byte, 64*1024)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	file, err := os.Open(writeTempFile(t, data))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer file.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		start := int64(i * 4000)
+		end := start + 2999
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/api/stream/video.mp4", nil)
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+			rec := httptest.NewRecorder()
+
+			serveFileRange(rec, req, file, int64(len(data)), "video/mp4")
+
+			if rec.Code != http.StatusPartialContent {
+				t.Errorf("range %d-%d: expected 206, got %d", start, end, rec.Code)
+				return
+			}
+			if !bytes.Equal(rec.Body.Bytes(), data[start:end+1]) {
+				t.Errorf("range %d-%d: body mismatch", start, end)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestStreamFile_FullAndOpenEndedRanges(t *testing.T) {
+	path := writeTempFile(t, []byte("0123456789"))
+
+	rec := httptest.NewRecorder()
+	streamFile(rec, httptest.NewRequest(http.MethodGet, "/api/stream/video.mp4", nil), path, "video/mp4")
+	if rec.Code != http.StatusOK || rec.Body.String() != "0123456789" {
+		t.Fatalf("expected full body, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stream/video.mp4", nil)
+	req.Header.Set("Range", "bytes=7-")
+	rec = httptest.NewRecorder()
+	streamFile(rec, req, path, "video/mp4")
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "789" {
+		t.Fatalf("expected tail range, got %d %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 7-9/10" {
+		t.Fatalf("unexpected Content-Range %q", got)
+	}
+}
+
+func TestStreamFile_HeadSendsHeadersWithoutBody(t *testing.T) {
+	path := writeTempFile(t, []byte("0123456789"))
+
+	rec := httptest.NewRecorder()
+	streamFile(rec, httptest.NewRequest(http.MethodHead, "/api/stream/video.mp4", nil), path, "video/mp4")
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Fatalf("expected empty 200, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Content-Length") != "10" || rec.Header().Get("Accept-Ranges") != "bytes" || rec.Header().Get("Content-Type") != "video/mp4" {
+		t.Fatalf("unexpected headers %v", rec.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodHead, "/api/stream/video.mp4", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	rec = httptest.NewRecorder()
+	streamFile(rec, req, path, "video/mp4")
+	if rec.Code != http.StatusPartialContent || rec.Body.Len() != 0 {
+		t.Fatalf("expected empty 206, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Content-Range") != "bytes 2-5/10" || rec.Header().Get("Content-Length") != "4" {
+		t.Fatalf("unexpected range headers %v", rec.Header())
+	}
+}
+
+func TestStreamFile_ConditionalRequests(t *testing.T) {
+	path := writeTempFile(t, []byte("0123456789"))
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	streamFile(rec, httptest.NewRequest(http.MethodGet, "/api/stream/video.mp4", nil), path, "video/mp4")
+	etag := rec.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) || rec.Header().Get("Last-Modified") != modTime.Format(http.TimeFormat) {
+		t.Fatalf("expected validators, got %v", rec.Header())
+	}
+
+	cases := []struct {
+		header, value string
+		want          int
+	}{
+		{"If-None-Match", etag, http.StatusNotModified},
+		{"If-None-Match", `"other", ` + strings.TrimPrefix(etag, "W/"), http.StatusNotModified},
+		{"If-None-Match", `W/"stale"`, http.StatusOK},
+		{"If-Modified-Since", modTime.Format(http.TimeFormat), http.StatusNotModified},
+		{"If-Modified-Since", modTime.Add(-time.Minute).Format(http.TimeFormat), http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/stream/video.mp4", nil)
+		req.Header.Set(tc.header, tc.value)
+		rec := httptest.NewRecorder()
+		streamFile(rec, req, path, "video/mp4")
+		if rec.Code != tc.want {
+			t.Errorf("%s %q: expected %d, got %d", tc.header, tc.value, tc.want, rec.Code)
+		}
+		if tc.want == http.StatusNotModified && rec.Body.Len() != 0 {
+			t.Errorf("%s %q: expected empty 304 body", tc.header, tc.value)
+		}
+	}
+}
+
+func TestParseByteRanges(t *testing.T) {
+	cases := []struct {
+		header string
+		want   []byteRange
+		err    error
+	}{
+		{header: "", want: nil},
+		{header: "bytes=0-", want: []byteRange{{0, 9}}},
+		{header: "bytes=2-5", want: []byteRange{{2, 5}}},
+		{header: "bytes=8-100", want: []byteRange{{8, 9}}},
+		{header: "bytes=-3", want: []byteRange{{7, 9}}},
+		{header: "bytes=-50", want: []byteRange{{0, 9}}},
+		{header: "bytes=0-1, 4-5,-2", want: []byteRange{{0, 1}, {4, 5}, {8, 9}}},
+		{header: "bytes=0-1,20-30", want: []byteRange{{0, 1}}},
+		{header: "bytes=0-9,0-9", want: nil},
+		{header: "bytes=10-", err: errUnsatisfiableRange},
+		{header: "bytes=5-2", err: errMalformedRange},
+		{header: "bytes=-0", err: errMalformedRange},
+		{header: "bytes=a-b", err: errMalformedRange},
+		{header: "bytes=+1-2", err: errMalformedRange},
+		{header: "bytes=3", err: errMalformedRange},
+		{header: "items=0-1", err: errMalformedRange},
+	}
+	for _, tc := range cases {
+		got, err := parseByteRanges(tc.header, 10)
+		if err != tc.err || fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("%q: got %v, %v; want %v, %v", tc.header, got, err, tc.want, tc.err)
+		}
+	}
+}
+
+func TestStreamFile_SuffixAndMalformedRanges(t *testing.T) {
+	path := writeTempFile(t, []byte("0123456789"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stream/video.mp4", nil)
+	req.Header.Set("Range", "bytes=-4")
+	rec := httptest.NewRecorder()
+	streamFile(rec, req, path, "video/mp4")
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "6789" || rec.Header().Get("Content-Range") != "bytes 6-9/10" {
+		t.Fatalf("expected last four bytes, got %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+
+	req.Header.Set("Range", "bytes=x-")
+	rec = httptest.NewRecorder()
+	streamFile(rec, req, path, "video/mp4")
+	if rec.Code != http.StatusRequestedRangeNotSatisfiable || rec.Header().Get("Content-Range") != "bytes */10" {
+		t.Fatalf("expected 416 with unsatisfied Content-Range, got %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestStreamFile_MultipleRangesAsMultipart(t *testing.T) {
+	path := writeTempFile(t, []byte("0123456789"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stream/video.mp4", nil)
+	req.Header.Set("Range", "bytes=0-1,-2")
+	rec := httptest.NewRecorder()
+	streamFile(rec, req, path, "video/mp4")
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("expected 206, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(rec.Body.Len()) {
+		t.Fatalf("Content-Length %s does not match body length %d", got, rec.Body.Len())
+	}
+
+	mediaType, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("unexpected Content-Type %q (%v)", rec.Header().Get("Content-Type"), err)
+	}
+	reader := multipart.NewReader(rec.Body, params["boundary"])
+	for _, want := range []struct{ contentRange, body string }{{"bytes 0-1/10", "01"}, {"bytes 8-9/10", "89"}} {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("next part: %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		if part.Header.Get("Content-Range") != want.contentRange || string(body) != want.body || part.Header.Get("Content-Type") != "video/mp4" {
+			t.Fatalf("unexpected part %v %q", part.Header, body)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Fatalf("expected two parts, got %v", err)
+	}
+}
+
+func TestStreamAccessLog_CountsRangedBytes(t *testing.T) {
+	path := writeTempFile(t, []byte("0123456789"))
+	quotas, _ := quotaapp.NewService("", quotaapp.Limits{MonthlyStreamBytes: 4})
+	handler := NewHandler(nil, nil, nil, nil, nil, nil, quotas)
+	var logged bytes.Buffer
+	handler.EnableAccessLog(log.New(&logged, "", 0))
+
+	stream := handler.StreamAccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		streamFile(w, r, path, "video/mp4")
+	}))
+	req := withUser(httptest.NewRequest(http.MethodGet, "/api/stream/video.mp4", nil), authapp.User{ID: "u1", Username: "alice"})
+	req.Header.Set("Range", "bytes=2-5")
+	rec := httptest.NewRecorder()
+	stream.ServeHTTP(rec, req)
+
+	if rec.Body.Len() != 4 {
+		t.Fatalf("expected 4 body bytes, got %d", rec.Body.Len())
+	}
+	if line := logged.String(); !strings.Contains(line, "bytes=4 ") || !strings.Contains(line, "status=206") || !strings.Contains(line, `range="bytes=2-5"`) {
+		t.Fatalf("unexpected access log line %q", line)
+	}
+	if totals := handler.served.list(); len(totals) != 1 || totals[0] != (userBytes{User: "alice", Bytes: 4}) {
+		t.Fatalf("unexpected per-user totals %+v", totals)
+	}
+
+	if used := quotas.Usage("u1").StreamedBytes; used != 4 {
+		t.Fatalf("expected 4 streamed bytes charged to quota, got %d", used)
+	}
+
+	rec = httptest.NewRecorder()
+	stream.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the monthly quota is used up, got %d", rec.Code)
+	}
+}
+
+func TestContentTypeFor(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{"movie.mp4", "video/mp4"},
+		{"movie.MKV", "video/x-matroska"},
+		{"movie.avi", "video/x-msvideo"},
+		{"movie.mov", "video/quicktime"},
+		{"movie.m4v", "video/mp4"},
+		{"movie.webm", "video/webm"},
+		{"segment00001.ts", "video/mp2t"},
+		{"index.m3u8", "application/vnd.apple.mpegurl"},
+		{"segment00001.m4s", "video/iso.segment"},
+		{"movie.unknownext", "application/octet-stream"},
+	} {
+		if got := contentTypeFor(tc.path); got != tc.want {
+			t.Errorf("contentTypeFor(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestStreamVideo_ThrottleMatchesConfiguredRate(t *testing.T) {
+	path := writeTempFile(t, bytes.Repeat([]byte("x"), 60_000))
+	handler := NewHandler(nil, nil, &fakePathStore{root: filepath.Dir(path)}, nil, nil, nil, nil)
+	// 800 kbit/s is 100,000 bytes per second, with a 10,000 byte burst.
+	handler.EnableStreamThrottle(800)
+
+	stream := func(target, rangeHeader string) (*httptest.ResponseRecorder, time.Duration) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Range", rangeHeader)
+		req = mux.SetURLVars(req, map[string]string{"path": "video.mp4"})
+		rec := httptest.NewRecorder()
+		began := time.Now()
+		handler.StreamVideo(rec, req)
+		return rec, time.Since(began)
+	}
+
+	for _, tc := range []struct {
+		name, target, rangeHeader string
+		size                      int
+		want                      time.Duration
+	}{
+		{"range", "/api/stream/video.mp4", "bytes=10000-", 50_000, 400 * time.Millisecond},
+		{"growing", "/api/stream/video.mp4?follow=1", "bytes=0-29999", 30_000, 200 * time.Millisecond},
+	} {
+		rec, elapsed := stream(tc.target, tc.rangeHeader)
+		if rec.Code != http.StatusPartialContent || rec.Body.Len() != tc.size {
+			t.Fatalf("%s: expected %d bytes, got %d with %d", tc.name, tc.size, rec.Body.Len(), rec.Code)
+		}
+		if elapsed < tc.want*3/4 || elapsed > tc.want*3 {
+			t.Fatalf("%s: expected about %s at the configured rate, took %s", tc.name, tc.want, elapsed)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// minThrottleBurst keeps the burst of slow limits large enough for one write
+// of the file copy buffer to pass in a few pieces rather than byte by byte.
+const minThrottleBurst = 4 << 10
+
+// EnableStreamThrottle caps each direct file stream at kbps kilobits per
+// second. Zero or less leaves streams unthrottled.
+func (h *Handler) EnableStreamThrottle(kbps int) {
+	if kbps > 0 {
+		h.streamBytesPerSec = int64(kbps) * 1000 / 8
+	}
+}
+
+// throttleStream wraps w in the stream rate limit, if one is set.
+func (h *Handler) throttleStream(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	if h.streamBytesPerSec <= 0 {
+		return w
+	}
+	return newThrottledWriter(r.Context(), w, h.streamBytesPerSec)
+}
+
+// throttledWriter paces body writes with a token bucket that refills at
+// bytesPerSec and holds a tenth of a second's worth. Writes wait for tokens
+// and end early when ctx is done.
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	bytesPerSec int64
+	burst       int64
+	tokens      float64
+	last        time.Time
+}
+
+func newThrottledWriter(ctx context.Context, w http.ResponseWriter, bytesPerSec int64) *throttledWriter {
+	burst := max(bytesPerSec/10, minThrottleBurst)
+	return &throttledWriter{
+		ResponseWriter: w,
+		ctx:            ctx,
+		bytesPerSec:    bytesPerSec,
+		burst:          burst,
+		tokens:         float64(burst),
+		last:           time.Now(),
+	}
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(int64(len(p)), t.burst)]
+		if err := t.wait(len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := t.ResponseWriter.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// wait blocks until n tokens are available and takes them.
+func (t *throttledWriter) wait(n int) error {
+	now := time.Now()
+	t.tokens = min(float64(t.burst), t.tokens+now.Sub(t.last).Seconds()*float64(t.bytesPerSec))
+	t.last = now
+
+	t.tokens -= float64(n)
+	if t.tokens >= 0 {
+		return nil
+	}
+	delay := time.Duration(-t.tokens / float64(t.bytesPerSec) * float64(time.Second))
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-t.ctx.Done():
+		return t.ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (t *throttledWriter) Flush() {
+	if flusher, ok := t.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (t *throttledWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
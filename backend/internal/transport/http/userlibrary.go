@@ -0,0 +1,94 @@
+package http
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	mediadomain "evd/internal/domain/media"
+)
+
+// EnableUserLibraries confines each non-admin user to the library folder named
+// after their user ID: request paths are resolved below it and listed paths are
+// reported relative to it. Admins keep the whole library, so they can browse
+// every user's folder.
+func (h *Handler) EnableUserLibraries() {
+	h.userLibraries = true
+}
+
+// libraryPrefix returns the folder prefix, with trailing slash, that the caller's
+// paths live under, or "" when they see the whole library.
+func (h *Handler) libraryPrefix(r *http.Request) string {
+	if !h.userLibraries {
+		return ""
+	}
+	user, ok := requestUser(r)
+	if !ok || h.auth.IsAdmin(user) {
+		return ""
+	}
+	return user.ID + "/"
+}
+
+// scopePath maps a path as the caller sees it to a library path. The raw path is
+// cleaned first, so ".." cannot climb out of the caller's folder.
+func (h *Handler) scopePath(r *http.Request, raw string) string {
+	prefix := h.libraryPrefix(r)
+	if prefix == "" {
+		return raw
+	}
+	cleaned := strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(strings.TrimSpace(raw), "\\", "/")), "/")
+	return prefix + cleaned
+}
+
+// unscopePath maps a library path back to the caller's view. ok is false for
+// paths outside the caller's folder.
+func (h *Handler) unscopePath(r *http.Request, rel string) (string, bool) {
+	prefix := h.libraryPrefix(r)
+	if prefix == "" {
+		return rel, true
+	}
+	if !strings.HasPrefix(rel, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(rel, prefix), true
+}
+
+// pathParam returns the {path} route variable (or ?path=) as a library path.
+func (h *Handler) pathParam(r *http.Request) string {
+	return h.scopePath(r, getPathParam(r))
+}
+
+// scopeVideos keeps the videos inside the caller's folder, with paths relative to it.
+func (h *Handler) scopeVideos(r *http.Request, videos []mediadomain.Video) []mediadomain.Video {
+	if h.libraryPrefix(r) == "" {
+		return videos
+	}
+	scoped := make([]mediadomain.Video, 0, len(videos))
+	for _, video := range videos {
+		if rel, ok := h.unscopePath(r, video.Path); ok {
+			video.Path = rel
+			scoped = append(scoped, video)
+		}
+	}
+	return scoped
+}
+
+// scopeHLSFiles rejects requests for HLS output of another user's videos. HLS
+// URLs mirror library paths below an optional fMP4 folder, including outputs
+// stored under hashed long names, so anything outside the caller's folder is
+// refused: hashed folders themselves, other users' videos, and videos outside
+// every user folder such as torrent downloads.
+func (h *Handler) scopeHLSFiles(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := h.libraryPrefix(r)
+		if prefix != "" {
+			rel := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(r.URL.Path, "/hls/")), "/")
+			rel = strings.TrimPrefix(rel, "_fmp4/")
+			if !strings.HasPrefix(rel, prefix) {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authapp "evd/internal/application/auth"
+	mediadomain "evd/internal/domain/media"
+)
+
+func TestUserLibraries_ScopePaths(t *testing.T) {
+	handler := NewHandler(nil, nil, nil, nil, &fakeAuth{}, nil, nil)
+	req := withUser(httptest.NewRequest(http.MethodGet, "/", nil), authapp.User{ID: "u1", Username: "alice"})
+
+	if got := handler.scopePath(req, "movie.mp4"); got != "movie.mp4" {
+		t.Fatalf("expected shared library path while disabled, got %q", got)
+	}
+
+	handler.EnableUserLibraries()
+	for raw, want := range map[string]string{
+		"movie.mp4":          "u1/movie.mp4",
+		"shows/ep1.mkv":      "u1/shows/ep1.mkv",
+		"../u2/movie.mp4":    "u1/u2/movie.mp4",
+		"/../../etc/passwd":  "u1/etc/passwd",
+		`shows\..\movie.mp4`: "u1/movie.mp4",
+	} {
+		if got := handler.scopePath(req, raw); got != want {
+			t.Fatalf("scopePath(%q) = %q, want %q", raw, got, want)
+		}
+	}
+	if _, ok := handler.unscopePath(req, "u2/movie.mp4"); ok {
+		t.Fatalf("expected another user's path to be outside the library")
+	}
+}
+
+func TestUserLibraries_ListVideosShowsOwnFolderOnly(t *testing.T) {
+	media := &fakeMedia{videos: []mediadomain.Video{
+		{Name: "a.mp4", Path: "u1/a.mp4"},
+		{Name: "b.mp4", Path: "u2/b.mp4"},
+		{Name: "c.mp4", Path: "c.mp4"},
+	}}
+	auth := &fakeAuth{}
+	handler := NewHandler(media, nil, nil, nil, auth, nil, nil)
+	handler.EnableUserLibraries()
+
+	list := func() []map[string]interface{} {
+		rec := httptest.NewRecorder()
+		handler.ListVideos(rec, withUser(httptest.NewRequest(http.MethodGet, "/api/videos", nil), authapp.User{ID: "u1", Username: "alice"}))
+		var items []map[string]interface{}
+		if err := json.NewDecoder(rec.Body).Decode(&items); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return items
+	}
+
+	if items := list(); len(items) != 1 || items[0]["path"] != "a.mp4" {
+		t.Fatalf("expected only the user's video relative to their folder, got %v", items)
+	}
+	auth.admin = true
+	if items := list(); len(items) != 3 {
+		t.Fatalf("expected admins to see the whole library, got %v", items)
+	}
+}
+
+func TestUserLibraries_HLSFilesOfOtherUsersAreHidden(t *testing.T) {
+	handler := NewHandler(nil, nil, nil, nil, &fakeAuth{}, nil, nil)
+	handler.EnableUserLibraries()
+	served := handler.scopeHLSFiles(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for target, want := range map[string]int{
+		"/hls/u1/movie/index.m3u8":             http.StatusNoContent,
+		"/hls/_fmp4/u1/movie/index.m3u8":       http.StatusNoContent,
+		"/hls/_long/0123abcd/index.m3u8":       http.StatusNotFound,
+		"/hls/_fmp4/_long/0123abcd/index.m3u8": http.StatusNotFound,
+		"/hls/torrents/show/index.m3u8":        http.StatusNotFound,
+		"/hls/u2/movie/index.m3u8":             http.StatusNotFound,
+		"/hls/u1/../u2/movie/index.m3u8":       http.StatusNotFound,
+		"/hls/movie/index.m3u8":                http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		served.ServeHTTP(rec, withUser(httptest.NewRequest(http.MethodGet, target, nil), authapp.User{ID: "u1", Username: "alice"}))
+		if rec.Code != want {
+			t.Fatalf("%s: expected %d, got %d", target, want, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	watchpartyapp "evd/internal/application/watchparty"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	watchWSWriteWait    = 10 * time.Second
+	watchWSPongWait     = 60 * time.Second
+	watchWSPingInterval = 20 * time.Second
+	watchWSMaxFrame     = 16 << 10
+)
+
+// watchUpgrader keeps gorilla's default same-origin check: sessions are cookie
+// based, so cross-site pages must not be able to open a hub socket.
+var watchUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// watchWSFrame is a client frame on the hub socket. Type is "control", "chat",
+// "typing" or "reaction".
+type watchWSFrame struct {
+	Type string `json:"type"`
+	watchHubControlRequest
+	Text  string `json:"text"`
+	Emoji string `json:"emoji"`
+}
+
+// watchWSError is sent back when a client frame is rejected.
+type watchWSError struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// WatchHubSocket is the WebSocket counterpart of WatchHubEvents: it streams hub
+// events and accepts control and chat frames on the same connection.
+func (h *Handler) WatchHubSocket(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	hubID := strings.TrimSpace(mux.Vars(r)["id"])
+	if !h.authorizeWatchHub(w, r, hubID, user.ID) {
+		return
+	}
+
+	conn, err := watchUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied to the client.
+		return
+	}
+	defer conn.Close()
+
+	events, done, err := h.watch.Subscribe(hubID, user.ID, user.Username)
+	if err != nil {
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, err.Error()), time.Now().Add(watchWSWriteWait))
+		return
+	}
+	defer done()
+	defer h.trackSubscriber()()
+
+	replies := make(chan watchWSError, 8)
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		h.readWatchSocket(r, conn, hubID, user.ID, user.Username, replies)
+	}()
+
+	ping := time.NewTicker(watchWSPingInterval)
+	defer ping.Stop()
+
+	for {
+		var payload interface{}
+		select {
+		case <-readerDone:
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(watchWSWriteWait)); err != nil {
+				return
+			}
+			continue
+		case reply := <-replies:
+			payload = reply
+		case event, open := <-events:
+			if !open {
+				return
+			}
+			payload = h.watchEventView(r, event)
+		}
+
+		_ = conn.SetWriteDeadline(time.Now().Add(watchWSWriteWait))
+		if err := conn.WriteJSON(payload); err != nil {
+			return
+		}
+	}
+}
+
+// readWatchSocket applies client frames until the connection fails or closes.
+// Successful actions reach the client through the subscription like any other event.
+func (h *Handler) readWatchSocket(r *http.Request, conn *websocket.Conn, hubID, userID, username string, replies chan<- watchWSError) {
+	conn.SetReadLimit(watchWSMaxFrame)
+	_ = conn.SetReadDeadline(time.Now().Add(watchWSPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(watchWSPongWait))
+	})
+
+	for {
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		if err := h.applyWatchFrame(r, hubID, userID, username, raw); err != nil {
+			select {
+			case replies <- watchWSError{Type: "error", Error: err.Error()}:
+			default:
+			}
+		}
+	}
+}
+
+func (h *Handler) applyWatchFrame(r *http.Request, hubID, userID, username string, raw []byte) error {
+	var frame watchWSFrame
+	if err := json.Unmarshal(raw, &frame); err != nil {
+		return errors.New("Invalid payload")
+	}
+
+	switch frame.Type {
+	case "control":
+		input, err := h.watchControlInput(r, hubID, frame.watchHubControlRequest)
+		if err != nil {
+			return err
+		}
+		_, err = h.watch.Control(hubID, userID, username, input)
+		return err
+	case "chat":
+		_, err := h.watch.Chat(hubID, userID, username, frame.Text)
+		return err
+	case watchpartyapp.EventTyping:
+		return h.watch.Typing(hubID, userID, username)
+	case watchpartyapp.EventReaction:
+		return h.watch.React(hubID, userID, username, frame.Emoji)
+	default:
+		return errors.New("Unknown frame type")
+	}
+}
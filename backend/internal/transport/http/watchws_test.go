@@ -0,0 +1,80 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	authapp "evd/internal/application/auth"
+	watchpartyapp "evd/internal/application/watchparty"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+func TestWatchHubSocket_ChatAndPresence(t *testing.T) {
+	watch := watchpartyapp.NewService(watchpartyapp.Options{})
+	defer watch.Close()
+	hub, err := watch.CreateHub("u1", "alice", watchpartyapp.HubKindExternal, "https://vimeo.com/1", 0, false, "")
+	if err != nil {
+		t.Fatalf("create hub: %v", err)
+	}
+
+	handler := NewHandler(nil, nil, nil, nil, nil, watch, nil)
+	router := mux.NewRouter()
+	router.HandleFunc("/api/watch-hubs/{id}/ws", func(w http.ResponseWriter, r *http.Request) {
+		handler.WatchHubSocket(w, withUser(r, authapp.User{ID: "u1", Username: "alice"}))
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/watch-hubs/" + hub.ID + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	var event watchpartyapp.Event
+	if err := conn.ReadJSON(&event); err != nil || event.Type != "sync" {
+		t.Fatalf("expected sync event, got %+v, %v", event, err)
+	}
+
+	if err := conn.WriteJSON(map[string]string{"type": "chat", "text": "hi"}); err != nil {
+		t.Fatalf("write chat: %v", err)
+	}
+	for event.Type != "chat" {
+		event = watchpartyapp.Event{}
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+	}
+	if event.Chat == nil || event.Chat.Text != "hi" {
+		t.Fatalf("unexpected chat event: %+v", event)
+	}
+
+	var reply map[string]string
+	if err := conn.WriteJSON(map[string]string{"type": "bogus"}); err != nil {
+		t.Fatalf("write bogus frame: %v", err)
+	}
+	if err := conn.ReadJSON(&reply); err != nil || reply["type"] != "error" {
+		t.Fatalf("expected error reply, got %v, %v", reply, err)
+	}
+
+	conn.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		snapshot, err := watch.GetHub(hub.ID)
+		if err != nil {
+			t.Fatalf("get hub: %v", err)
+		}
+		if len(snapshot.Members) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected member to leave after disconnect, got %+v", snapshot.Members)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
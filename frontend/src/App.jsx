@@ -3,10 +3,17 @@ import { NavLink, Outlet, Route, Routes, useLocation, useNavigate, useOutletCont
 import brandImage from '../images/img.png'
 import { getOrCreateDeviceId, loadHistoryForDevice, mergeHistoryEntry, saveHistoryForDevice } from './watchHistory'
 
-const VIDEO_EXTS = ['mp4', 'mkv', 'avi', 'mov']
+// Defaults of the server; replaced with its configured list from /api/config.
+const VIDEO_EXTS = ['mp4', 'mkv', 'avi', 'mov', 'webm', 'm4v', 'ts']
 const HISTORY_FLUSH_INTERVAL_MS = 2000
+const PROGRESS_SYNC_INTERVAL_MS = 15000
 const RESUME_GUARD_SECONDS = 1
 const SEEK_STEP_SECONDS = 10
+// Same allowlist as the watch party service.
+const REACTION_EMOJIS = ['👍', '❤️', '😂', '😮', '😢', '👏', '🔥', '🎉']
+const TYPING_SEND_INTERVAL_MS = 2000
+const TYPING_DISPLAY_MS = 3000
+const REACTION_DISPLAY_MS = 4000
 
 const ROUTE_META = [
   {
@@ -427,6 +434,7 @@ function App() {
   const [torrents, setTorrents] = useState([])
   const [torrentEnabled, setTorrentEnabled] = useState(true)
   const [torrentError, setTorrentError] = useState('')
+  const [torrentStats, setTorrentStats] = useState(null)
 
   const [toast, setToast] = useState(null)
 
@@ -524,6 +532,52 @@ function App() {
     saveHistoryForDevice(deviceId, watchHistory)
   }, [deviceId, watchHistory])
 
+  // Server-side positions follow the account across devices; newer local entries win.
+  useEffect(() => {
+    if (!authUser?.id) return undefined
+    let cancelled = false
+    void (async () => {
+      try {
+        const res = await authedFetch('/api/progress')
+        if (!res.ok) return
+        const data = await readJsonSafe(res)
+        const items = Array.isArray(data?.items) ? data.items : []
+        if (cancelled || items.length === 0) return
+        setWatchHistory((prev) => items.reduce((entries, item) => {
+          const path = normalizePath(item.path)
+          const local = entries.find((entry) => entry.path === path)
+          if (local && local.lastWatchedAt >= item.updatedAt) return entries
+          return mergeHistoryEntry(entries, {
+            path,
+            title: displayName(path),
+            currentTime: item.position,
+            duration: item.duration,
+            lastWatchedAt: item.updatedAt
+          })
+        }, prev))
+      } catch (err) {
+        // local history still works offline
+      }
+    })()
+    return () => {
+      cancelled = true
+    }
+  }, [authUser?.id, authedFetch])
+
+  const lastProgressSyncRef = useRef(0)
+  const reportProgress = useCallback((entry, { force = false } = {}) => {
+    if (!entry?.path) return
+    const now = Date.now()
+    if (!force && now - lastProgressSyncRef.current < PROGRESS_SYNC_INTERVAL_MS) return
+    lastProgressSyncRef.current = now
+    void authedFetch('/api/progress', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ path: entry.path, position: entry.currentTime, duration: entry.duration }),
+      keepalive: true
+    }).catch(() => {})
+  }, [authedFetch])
+
   useEffect(() => {
     if (!toast) return undefined
     const timeout = setTimeout(() => setToast(null), 3800)
@@ -558,6 +612,14 @@ function App() {
       setTorrentError(data?.error ?? data?.Error ?? '')
       const items = Array.isArray(data?.items ?? data?.Items) ? (data.items ?? data.Items) : []
       setTorrents(items.map(normalizeTorrent))
+
+      if (data?.enabled && !data?.error) {
+        const statsRes = await authedFetch('/api/torrents/stats')
+        const statsData = statsRes.ok ? await readJsonSafe(statsRes) : null
+        setTorrentStats(statsData?.stats || null)
+      } else {
+        setTorrentStats(null)
+      }
     } catch (err) {
       if (String(err?.message || '').startsWith('torrents_401')) return
       setTorrentError('Transmission unavailable')
@@ -570,7 +632,17 @@ function App() {
 
   useEffect(() => {
     if (!authUser) return
-    void Promise.all([fetchVideos(), fetchTorrents()])
+    void (async () => {
+      try {
+        const config = await readJsonSafe(await fetch('/api/config', { credentials: 'include' }))
+        if (Array.isArray(config?.allowedExtensions) && config.allowedExtensions.length > 0) {
+          VIDEO_EXTS.splice(0, VIDEO_EXTS.length, ...config.allowedExtensions.map((ext) => ext.replace(/^\./, '')))
+        }
+      } catch (err) {
+        // Keep the built-in list.
+      }
+      await Promise.all([fetchVideos(), fetchTorrents()])
+    })()
   }, [authUser, fetchVideos, fetchTorrents])
 
   useEffect(() => {
@@ -658,7 +730,11 @@ function App() {
         return
       }
 
-      setVodState({ status: 'preparing', url: '', progress: data.progress ?? 0 })
+      if (data.state === 'queued') {
+        setVodState({ status: 'queued', url: '', progress: 0, queuePosition: data.queuePosition ?? 0 })
+      } else {
+        setVodState({ status: 'preparing', url: '', progress: data.progress ?? 0 })
+      }
     } catch (err) {
       if (String(err?.message || '').startsWith('vod_status_401')) return
       setVodState({ status: 'preparing', url: '', progress: 0 })
@@ -758,7 +834,19 @@ function App() {
     setUploadMessage('Uploading...')
 
     try {
-      for (let chunkIndex = 0; chunkIndex < totalChunks; chunkIndex += 1) {
+      // Continue an interrupted upload of the same file; chunks are located by
+      // index, so this only works with the same chunk size.
+      let firstChunk = 0
+      const statusRes = await authedFetch(`/api/upload/status?fileName=${encodeURIComponent(file.name)}`)
+      if (statusRes.ok) {
+        const status = await readJsonSafe(statusRes)
+        if (status?.totalChunks === totalChunks && status.receivedBytes === status.nextChunk * chunkSize) {
+          firstChunk = status.nextChunk
+          setUploadMessage('Resuming upload...')
+        }
+      }
+
+      for (let chunkIndex = firstChunk; chunkIndex < totalChunks; chunkIndex += 1) {
         const start = chunkIndex * chunkSize
         const end = Math.min(start + chunkSize, file.size)
         const chunk = file.slice(start, end)
@@ -896,6 +984,7 @@ function App() {
       torrents,
       torrentEnabled,
       torrentError,
+      torrentStats,
       activeVideo,
       playbackUrl,
       playbackKind,
@@ -919,6 +1008,7 @@ function App() {
       videoInputRef,
       torrentInputRef,
       updateWatchHistory,
+      reportProgress,
       setPlayerError,
       setPlayerState,
       playVideo,
@@ -942,6 +1032,7 @@ function App() {
       torrents,
       torrentEnabled,
       torrentError,
+      torrentStats,
       activeVideo,
       playbackUrl,
       playbackKind,
@@ -963,6 +1054,7 @@ function App() {
       watchHistory,
       watchHistoryByPath,
       updateWatchHistory,
+      reportProgress,
       playVideo,
       enableTorrentStreaming,
       reportTorrentFocus,
@@ -1462,7 +1554,10 @@ function LibraryLayout() {
                   >
                     <div className="tree-file-main text-break">
                       <strong>{displayName(video.path)}</strong>
-                      <span>{formatBytes(video.size)} · {formatDate(video.modifiedAt)}</span>
+                      <span>
+                        {formatBytes(video.size)} · {formatDate(video.modifiedAt)}
+                        {video.watched ? ' · Watched' : video.positionSeconds > 0 ? ` · Stopped at ${formatTime(video.positionSeconds)}` : ''}
+                      </span>
                     </div>
                   </NavLink>
                 )}
@@ -1610,6 +1705,7 @@ function TorrentsPage() {
     torrents,
     torrentEnabled,
     torrentError,
+    torrentStats,
     torrentLoading,
     torrentInputRef,
     handleTorrentSelect,
@@ -1674,6 +1770,12 @@ function TorrentsPage() {
 
         {!torrentEnabled && <p className="helper-note">Transmission is not configured.</p>}
         {torrentEnabled && torrentError && <p className="helper-note text-break">{torrentError}</p>}
+        {torrentEnabled && !torrentError && torrentStats && (
+          <p className="helper-note">
+            ↓ {formatBytes(torrentStats.downloadSpeed)}/s · ↑ {formatBytes(torrentStats.uploadSpeed)}/s
+            {` · ${torrentStats.activeTorrentCount} active, ${torrentStats.pausedTorrentCount} paused`}
+          </p>
+        )}
 
         {torrentLoading ? (
           <SkeletonList rows={5} />
@@ -1830,7 +1932,8 @@ function PlayerPage() {
     setPlaybackUrl,
     vodState,
     watchHistoryByPath,
-    updateWatchHistory
+    updateWatchHistory,
+    reportProgress
   } = useOutletContext()
 
   const [retrySeed, setRetrySeed] = useState(0)
@@ -1889,9 +1992,10 @@ function PlayerPage() {
       duration: nextDuration,
       lastWatchedAt: now
     })
+    reportProgress({ path: activeVideo.path, currentTime: nextCurrentTime, duration: nextDuration }, { force })
 
     lastHistorySaveRef.current = now
-  }, [activeVideo?.path, updateWatchHistory])
+  }, [activeVideo?.path, reportProgress, updateWatchHistory])
 
   const handleRetry = useCallback(() => {
     if (!activeVideo?.path) return
@@ -2203,6 +2307,9 @@ function PlayerPage() {
           {activeVideo && (
             <div className="status-item">Shortcuts: F fullscreen, Arrow Left/Right seek ±10s in fullscreen.</div>
           )}
+          {vodState.status === 'queued' && (
+            <div className="status-item">Direct: waiting for a free converter{vodState.queuePosition > 0 ? ` (position ${vodState.queuePosition} in queue)` : ''}...</div>
+          )}
           {vodState.status === 'preparing' && (
             <div className="status-item">Direct: preparing MP4 stream... {vodState.progress > 0 ? `${formatPercent(vodState.progress)}` : ''}</div>
           )}
@@ -2240,18 +2347,26 @@ function WatchTogetherPage() {
 
   const [selectedPath, setSelectedPath] = useState('')
   const [hubInput, setHubInput] = useState('')
+  const [hubKey, setHubKey] = useState('')
+  const [newHubPassword, setNewHubPassword] = useState('')
+  const [myHubs, setMyHubs] = useState([])
   const [hubState, setHubState] = useState(null)
   const [hubBusy, setHubBusy] = useState(false)
   const [hubError, setHubError] = useState('')
   const [connectionState, setConnectionState] = useState('Disconnected')
   const [chatInput, setChatInput] = useState('')
   const [chatSending, setChatSending] = useState(false)
+  const [typingUsers, setTypingUsers] = useState({})
+  const [reactions, setReactions] = useState([])
 
   const videoRef = useRef(null)
   const eventSourceRef = useRef(null)
+  const lastHubSeqRef = useRef(0)
   const suppressOutgoingRef = useRef(false)
   const suppressTimerRef = useRef(null)
   const lastSeekBroadcastRef = useRef(0)
+  const lastTypingSentRef = useRef(0)
+  const typingTimersRef = useRef({})
   const lastFocusSyncRef = useRef(0)
 
   const activeTorrentId = activeTorrentMatch?.torrentId || 0
@@ -2392,7 +2507,12 @@ function WatchTogetherPage() {
       }, { once: true })
     }
 
-    if (action === 'seek') {
+    const desiredRate = Number.isFinite(state.playbackRate) && state.playbackRate > 0 ? state.playbackRate : 1
+    if (video.playbackRate !== desiredRate) {
+      video.playbackRate = desiredRate
+    }
+
+    if (action === 'seek' || action === 'rate') {
       return
     }
 
@@ -2429,6 +2549,10 @@ function WatchTogetherPage() {
       payload.videoPath = overrides.videoPath
     }
 
+    if (Number.isFinite(overrides.playbackRate)) {
+      payload.playbackRate = overrides.playbackRate
+    }
+
     if (action === 'seek' && !Number.isFinite(payload.currentTime)) return
 
     const res = await authedFetch(`/api/watch-hubs/${encodeURIComponent(hubState.id)}/control`, {
@@ -2469,11 +2593,96 @@ function WatchTogetherPage() {
     }
   }, [authedFetch, chatInput, chatSending, hubState?.id, pushToast])
 
+  const showTyping = useCallback((userId, username) => {
+    setTypingUsers((current) => ({ ...current, [userId]: username }))
+    clearTimeout(typingTimersRef.current[userId])
+    typingTimersRef.current[userId] = setTimeout(() => {
+      delete typingTimersRef.current[userId]
+      setTypingUsers((current) => {
+        const next = { ...current }
+        delete next[userId]
+        return next
+      })
+    }, TYPING_DISPLAY_MS)
+  }, [])
+
+  const showReaction = useCallback((payload) => {
+    const key = `${payload.actorId}-${Date.now()}-${Math.random()}`
+    setReactions((current) => [...current.slice(-9), { key, emoji: payload.emoji, name: payload.actorName }])
+    setTimeout(() => {
+      setReactions((current) => current.filter((reaction) => reaction.key !== key))
+    }, REACTION_DISPLAY_MS)
+  }, [])
+
+  const sendTyping = useCallback(() => {
+    if (!hubState?.id) return
+    const now = Date.now()
+    if (now - lastTypingSentRef.current < TYPING_SEND_INTERVAL_MS) return
+    lastTypingSentRef.current = now
+    void authedFetch(`/api/watch-hubs/${encodeURIComponent(hubState.id)}/typing`, { method: 'POST' }).catch(() => {})
+  }, [authedFetch, hubState?.id])
+
+  const sendReaction = useCallback(async (emoji) => {
+    if (!hubState?.id) return
+    try {
+      const res = await authedFetch(`/api/watch-hubs/${encodeURIComponent(hubState.id)}/react`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ emoji })
+      })
+      if (!res.ok) {
+        pushToast(await readErrorMessage(res), 'error')
+      }
+    } catch (err) {
+      pushToast('Failed to send reaction.', 'error')
+    }
+  }, [authedFetch, hubState?.id, pushToast])
+
+  const transferOwnership = useCallback(async (userId) => {
+    if (!hubState?.id) return
+    try {
+      const res = await authedFetch(`/api/watch-hubs/${encodeURIComponent(hubState.id)}/transfer`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ userId })
+      })
+      if (!res.ok) {
+        pushToast(await readErrorMessage(res), 'error')
+      }
+    } catch (err) {
+      pushToast('Failed to transfer ownership.', 'error')
+    }
+  }, [authedFetch, hubState?.id, pushToast])
+
+  const kickMember = useCallback(async (userId) => {
+    if (!hubState?.id) return
+    try {
+      const res = await authedFetch(`/api/watch-hubs/${encodeURIComponent(hubState.id)}/kick`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ userId })
+      })
+      if (!res.ok) {
+        pushToast(await readErrorMessage(res), 'error')
+      }
+    } catch (err) {
+      pushToast('Failed to remove member.', 'error')
+    }
+  }, [authedFetch, hubState?.id, pushToast])
+
   const connectHubStream = useCallback((hubID) => {
     closeHubStream()
 
+    // Joining the hub with its key grants this user, so the stream needs no key.
     const stream = new EventSource(`/api/watch-hubs/${encodeURIComponent(hubID)}/events`)
     eventSourceRef.current = stream
+    lastHubSeqRef.current = 0
     setConnectionState('Connecting...')
 
     stream.onopen = () => {
@@ -2485,11 +2694,31 @@ function WatchTogetherPage() {
         const payload = JSON.parse(message.data)
         const nextHub = payload?.hub
         if (!nextHub) return
+        // Reconnects replay missed events; skip any already applied.
+        if (payload.seq) {
+          if (payload.type !== 'sync' && payload.seq <= lastHubSeqRef.current) return
+          lastHubSeqRef.current = payload.seq
+        }
         setHubState(nextHub)
 
         if (payload.type === 'chat') return
-        if (payload.type === 'presence') return
+        if (payload.type === 'typing') {
+          if (payload.actorId !== authUser?.id) showTyping(payload.actorId, payload.actorName)
+          return
+        }
+        if (payload.type === 'reaction') {
+          showReaction(payload)
+          return
+        }
+        if (payload.type === 'presence') {
+          if (payload.action === 'kick' && payload.actorId === authUser?.id) {
+            closeHubStream()
+            setHubError('You were removed from this hub by its owner.')
+          }
+          return
+        }
         if (payload.type === 'control' && payload.actorId === authUser?.id) return
+        if (payload.action === 'owner') return
         void applyHubEventToPlayer(payload)
       } catch (err) {
         // ignore malformed message
@@ -2499,7 +2728,7 @@ function WatchTogetherPage() {
     stream.onerror = () => {
       setConnectionState('Reconnecting...')
     }
-  }, [applyHubEventToPlayer, authUser?.id, closeHubStream])
+  }, [applyHubEventToPlayer, authUser?.id, closeHubStream, showReaction, showTyping])
 
   const joinHub = useCallback(async (hubID, options = {}) => {
     const normalizedHubID = extractHubID(hubID)
@@ -2509,9 +2738,12 @@ function WatchTogetherPage() {
     setHubError('')
 
     try {
-      const res = await authedFetch(`/api/watch-hubs/${encodeURIComponent(normalizedHubID)}`)
+      const key = hubKey.trim()
+      const res = await authedFetch(`/api/watch-hubs/${encodeURIComponent(normalizedHubID)}`, {
+        headers: key ? { 'X-Hub-Key': key } : {}
+      })
       if (!res.ok) {
-        setHubError(await readErrorMessage(res))
+        setHubError(res.status === 403 ? 'This hub is private. Enter its password to join.' : await readErrorMessage(res))
         return
       }
 
@@ -2535,7 +2767,7 @@ function WatchTogetherPage() {
     } finally {
       setHubBusy(false)
     }
-  }, [applyHubEventToPlayer, authedFetch, connectHubStream, navigate])
+  }, [applyHubEventToPlayer, authedFetch, connectHubStream, hubKey, navigate])
 
   const createHub = useCallback(async () => {
     const path = selectedPath || activeVideo?.path || ''
@@ -2562,7 +2794,8 @@ function WatchTogetherPage() {
         body: JSON.stringify({
           videoPath: path,
           currentTime: video?.currentTime || 0,
-          playing: !(video?.paused ?? true)
+          playing: !(video?.paused ?? true),
+          password: newHubPassword
         })
       })
 
@@ -2578,6 +2811,7 @@ function WatchTogetherPage() {
         return
       }
 
+      setNewHubPassword('')
       setHubState(nextHub)
       setHubInput(nextHub.id)
       connectHubStream(nextHub.id)
@@ -2588,17 +2822,36 @@ function WatchTogetherPage() {
     } finally {
       setHubBusy(false)
     }
-  }, [activeVideo?.path, authedFetch, connectHubStream, navigate, playVideo, pushToast, selectedPath, videoMap])
+  }, [activeVideo?.path, authedFetch, connectHubStream, navigate, newHubPassword, playVideo, pushToast, selectedPath, videoMap])
 
   const leaveHub = useCallback(() => {
     closeHubStream()
     setHubState(null)
     setHubError('')
     setHubInput('')
+    setHubKey('')
     setChatInput('')
     navigate('/watch-together', { replace: true })
   }, [closeHubStream, navigate])
 
+  useEffect(() => {
+    if (hubState?.id) return undefined
+    let cancelled = false
+    void (async () => {
+      try {
+        const res = await authedFetch('/api/watch-hubs')
+        if (!res.ok) return
+        const data = await readJsonSafe(res)
+        if (!cancelled) setMyHubs(Array.isArray(data?.hubs) ? data.hubs : [])
+      } catch (err) {
+        // the list is only a shortcut; joining by ID still works
+      }
+    })()
+    return () => {
+      cancelled = true
+    }
+  }, [authedFetch, hubState?.id])
+
   useEffect(() => {
     const queryHub = extractHubID(new URLSearchParams(location.search).get('hub') || '')
     if (!queryHub) return
@@ -2634,6 +2887,11 @@ function WatchTogetherPage() {
       void sendControl('seek', { currentTime: video.currentTime })
     }
 
+    const onRateChange = () => {
+      if (suppressOutgoingRef.current) return
+      void sendControl('rate', { playbackRate: video.playbackRate })
+    }
+
     const onTimeUpdate = () => {
       syncHubTorrentFocus(video, false)
     }
@@ -2641,12 +2899,14 @@ function WatchTogetherPage() {
     video.addEventListener('play', onPlay)
     video.addEventListener('pause', onPause)
     video.addEventListener('seeked', onSeeked)
+    video.addEventListener('ratechange', onRateChange)
     video.addEventListener('timeupdate', onTimeUpdate)
 
     return () => {
       video.removeEventListener('play', onPlay)
       video.removeEventListener('pause', onPause)
       video.removeEventListener('seeked', onSeeked)
+      video.removeEventListener('ratechange', onRateChange)
       video.removeEventListener('timeupdate', onTimeUpdate)
     }
   }, [hubState?.id, playbackUrl, sendControl, syncHubTorrentFocus])
@@ -2682,6 +2942,16 @@ function WatchTogetherPage() {
                 placeholder="Paste hub ID"
               />
             </label>
+            <label className="auth-field">
+              <span>Password</span>
+              <input
+                type="password"
+                value={hubKey}
+                onChange={(event) => setHubKey(event.target.value)}
+                placeholder="Private hubs only"
+                autoComplete="off"
+              />
+            </label>
             <Button type="button" onClick={() => void joinHub(hubInput)} disabled={hubBusy || !hubInput.trim()}>
               Join hub
             </Button>
@@ -2697,6 +2967,17 @@ function WatchTogetherPage() {
                 ))}
               </select>
             </label>
+            <label className="auth-field">
+              <span>Password (optional)</span>
+              <input
+                type="password"
+                value={newHubPassword}
+                onChange={(event) => setNewHubPassword(event.target.value)}
+                placeholder="Leave empty for an open hub"
+                autoComplete="new-password"
+                maxLength={128}
+              />
+            </label>
             <Button type="button" variant="primary" onClick={() => void createHub()} disabled={hubBusy || (!selectedPath && !activeVideo?.path)}>
               Create hub
             </Button>
@@ -2735,13 +3016,38 @@ function WatchTogetherPage() {
             </div>
           )}
 
+          {!hubState?.id && myHubs.length > 0 && (
+            <div className="status-list">
+              {myHubs.map((hub) => (
+                <div key={hub.id} className="inline-item">
+                  <div className="text-break">
+                    <strong>{hub.kind === 'external' ? hub.videoPath : fileTitle(hub.videoPath)}</strong>
+                    <p>
+                      {hub.ownerId === authUser?.id ? 'Your hub' : `Hosted by ${hub.ownerName}`}
+                      {` · ${hub.members?.length || 0} watching · ${hub.messageCount || 0} messages`}
+                    </p>
+                  </div>
+                  <Button type="button" size="sm" onClick={() => void joinHub(hub.id)} disabled={hubBusy}>
+                    Rejoin
+                  </Button>
+                </div>
+              ))}
+            </div>
+          )}
+
           {hubError && <div className="status-item danger">{hubError}</div>}
           {hubState?.id && (
             <div className="status-list">
-              <div className="status-item">Hub ID: {hubState.id}</div>
+              <div className="status-item">Hub ID: {hubState.id}{hubState.private ? ' (private)' : ''}</div>
               <div className="status-item">Connection: {connectionState}</div>
               <div className="status-item">Playback status: {playerState}</div>
               <div className="status-item">Members: {hubMembers.map((member) => member.username).join(', ') || authUser?.username}</div>
+              {hubState.ownerId === authUser?.id && hubMembers.filter((member) => member.id !== authUser?.id).map((member) => (
+                <div key={member.id} className="status-item">
+                  <Button type="button" variant="ghost" size="sm" onClick={() => void kickMember(member.id)}>Remove {member.username}</Button>
+                  <Button type="button" variant="ghost" size="sm" onClick={() => void transferOwnership(member.id)}>Make {member.username} owner</Button>
+                </div>
+              ))}
             </div>
           )}
         </div>
@@ -2774,6 +3080,23 @@ function WatchTogetherPage() {
               ))
             )}
           </div>
+          {Object.keys(typingUsers).length > 0 && (
+            <div className="watch-chat-typing">{Object.values(typingUsers).join(', ')} typing…</div>
+          )}
+          {reactions.length > 0 && (
+            <div className="watch-chat-reactions" aria-live="polite">
+              {reactions.map((reaction) => (
+                <span key={reaction.key} title={reaction.name}>{reaction.emoji}</span>
+              ))}
+            </div>
+          )}
+          <div className="watch-chat-react-bar">
+            {REACTION_EMOJIS.map((emoji) => (
+              <Button key={emoji} type="button" variant="ghost" size="sm" disabled={!hubState?.id} onClick={() => void sendReaction(emoji)}>
+                {emoji}
+              </Button>
+            ))}
+          </div>
           <form
             className="watch-chat-form"
             onSubmit={(event) => {
@@ -2784,7 +3107,10 @@ function WatchTogetherPage() {
             <input
               type="text"
               value={chatInput}
-              onChange={(event) => setChatInput(event.target.value)}
+              onChange={(event) => {
+                setChatInput(event.target.value)
+                sendTyping()
+              }}
               placeholder={hubState?.id ? 'Type a message...' : 'Join hub to chat'}
               maxLength={600}
               disabled={!hubState?.id || chatSending}